@@ -0,0 +1,304 @@
+package adminservice
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"ride-hail/pkg/apitime"
+	"ride-hail/pkg/auth"
+	"ride-hail/pkg/logger"
+	"ride-hail/pkg/validation"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// placeholderLicensePrefix marks the license numbers generated at driver signup
+// until a real one is on file
+const placeholderLicensePrefix = "FAKE-"
+
+type DriverUser struct {
+	UserID    string       `json:"user_id"`
+	Email     string       `json:"email"`
+	Role      string       `json:"role"`
+	Status    string       `json:"status"`
+	CreatedAt apitime.Time `json:"created_at"`
+}
+
+type DriverDetail struct {
+	DriverSummary
+	VehicleAttrs       json.RawMessage `json:"vehicle_attrs,omitempty"`
+	PlaceholderLicense bool            `json:"placeholder_license"`
+	CreatedAt          apitime.Time    `json:"created_at"`
+	User               DriverUser      `json:"user"`
+}
+
+// getDriverByLicense looks a driver up by license number: GET /admin/drivers/by-license/{license_number}
+func (h *AdminHandler) getDriverByLicense(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	license := strings.TrimSpace(r.PathValue("license_number"))
+	if license == "" {
+		writeError(w, http.StatusBadRequest, "license_number is required")
+		return
+	}
+	// Placeholders are unique per signup but say nothing about the driver; a bare
+	// prefix would otherwise look like a real search
+	if strings.EqualFold(license, placeholderLicensePrefix) {
+		writeError(w, http.StatusBadRequest, "A placeholder license prefix alone is not a license number")
+		return
+	}
+
+	var driver DriverDetail
+	var vehicleAttrs []byte
+	var lat, lng sql.NullFloat64
+	var address sql.NullString
+	var updatedAt sql.NullTime
+
+	// License numbers are matched case-insensitively, as typed from a document
+	err := h.pool.QueryRow(ctx, `
+		SELECT
+			d.id, u.email, d.license_number, COALESCE(d.vehicle_type, ''), COALESCE(d.status, ''),
+			COALESCE(d.rating, 0), COALESCE(d.total_rides, 0), COALESCE(d.total_earnings, 0),
+			COALESCE(d.is_verified, false), d.vehicle_attrs, d.created_at,
+			u.role, u.status, u.created_at,
+			c.latitude, c.longitude, c.address, c.updated_at
+		FROM drivers d
+		JOIN users u ON u.id = d.id
+		LEFT JOIN coordinates c ON c.entity_id = d.id
+			AND c.entity_type = 'driver'
+			AND c.is_current = true
+		WHERE UPPER(d.license_number) = UPPER($1)
+		LIMIT 1
+	`, license).Scan(
+		&driver.DriverID, &driver.Email, &driver.LicenseNumber, &driver.VehicleType, &driver.Status,
+		&driver.Rating, &driver.TotalRides, &driver.TotalEarnings,
+		&driver.IsVerified, &vehicleAttrs, &driver.CreatedAt.Time,
+		&driver.User.Role, &driver.User.Status, &driver.User.CreatedAt.Time,
+		&lat, &lng, &address, &updatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "Driver not found")
+		return
+	}
+	if err != nil {
+		h.log.Error("get_driver_by_license: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	driver.User.UserID = driver.DriverID
+	driver.User.Email = driver.Email
+	driver.PlaceholderLicense = strings.HasPrefix(strings.ToUpper(driver.LicenseNumber), placeholderLicensePrefix)
+	if len(vehicleAttrs) > 0 {
+		driver.VehicleAttrs = vehicleAttrs
+	}
+	if lat.Valid && lng.Valid {
+		driver.LastLocation = &DriverLocation{
+			Latitude:  lat.Float64,
+			Longitude: lng.Float64,
+			Address:   address.String,
+			UpdatedAt: apitime.New(updatedAt.Time),
+		}
+	}
+
+	writeJSON(w, http.StatusOK, driver)
+}
+
+type ForceOfflineRequest struct {
+	Reason string `json:"reason"`
+}
+
+type ForceOfflineResponse struct {
+	DriverID       string `json:"driver_id"`
+	PreviousStatus string `json:"previous_status"`
+	Status         string `json:"status"`
+	SessionEnded   bool   `json:"session_ended"` // The driver service ends the open session
+	RideID         string `json:"ride_id,omitempty"`
+	Message        string `json:"message"`
+}
+
+// forceDriverOffline takes a stuck or misbehaving driver offline:
+// POST /admin/drivers/{driver_id}/force-offline. A ride they were heading to is
+// sent back to matching and its passenger told so. The driver service is told to
+// close their WebSocket and end their session through its own offline path, so
+// the shift summary is written as for a driver who went offline themselves.
+func (h *AdminHandler) forceDriverOffline(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	driverID := r.PathValue("driver_id")
+	var req ForceOfflineRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, validation.DecodeErrorMessage(err))
+			return
+		}
+	}
+	claims, _ := auth.GetClaims(r.Context())
+
+	tx, err := h.pool.Begin(ctx)
+	if err != nil {
+		h.log.Error("force_offline: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var previousStatus string
+	err = tx.QueryRow(ctx, `SELECT COALESCE(status, '') FROM drivers WHERE id = $1 FOR UPDATE`, driverID).Scan(&previousStatus)
+	if errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "Driver not found")
+		return
+	}
+	if err != nil {
+		h.log.Error("force_offline_load: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// A ride the driver is heading to goes back to matching; once the passenger
+	// is on board there is nobody to hand it to
+	var ride *stuckRide
+	var rideID string
+	err = tx.QueryRow(ctx, `
+		SELECT id FROM rides
+		WHERE driver_id = $1 AND status IN ('MATCHED', 'EN_ROUTE', 'ARRIVED', 'IN_PROGRESS')
+		ORDER BY created_at DESC
+		LIMIT 1
+	`, driverID).Scan(&rideID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		h.log.Error("force_offline_find_ride: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if rideID != "" {
+		ride, err = loadRideForUpdate(ctx, tx, rideID)
+		if err != nil {
+			h.log.Error("force_offline_load_ride: ", err)
+			writeError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		if ride.Status == "IN_PROGRESS" {
+			writeError(w, http.StatusConflict, fmt.Sprintf("Driver has ride %s in progress; force-complete it first", ride.ID))
+			return
+		}
+
+		_, err = tx.Exec(ctx, `
+			UPDATE rides
+			SET status = 'REQUESTED', driver_id = NULL, matched_at = NULL, arrived_at = NULL, updated_at = NOW()
+			WHERE id = $1
+		`, ride.ID)
+		if err != nil {
+			h.log.Error("force_offline_reset_ride: ", err)
+			writeError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		err = insertAdminEvent(ctx, tx, ride.ID, "STATUS_CHANGED", map[string]interface{}{
+			"action":             "admin_force_offline",
+			"old_status":         ride.Status,
+			"new_status":         "REQUESTED",
+			"previous_driver_id": driverID,
+			"admin_id":           claims.UserID,
+			"reason":             req.Reason,
+		})
+		if err != nil {
+			h.log.Error("force_offline_event: ", err)
+			writeError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+	}
+
+	var sessionOpen bool
+	err = tx.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM driver_sessions WHERE driver_id = $1 AND ended_at IS NULL)
+	`, driverID).Scan(&sessionOpen)
+	if err != nil {
+		h.log.Error("force_offline_find_session: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// OFFLINE right away so matching skips the driver until the driver service
+	// has ended the session
+	_, err = tx.Exec(ctx, `
+		UPDATE drivers SET status = 'OFFLINE', current_ride_id = NULL, updated_at = NOW()
+		WHERE id = $1
+	`, driverID)
+	if err != nil {
+		h.log.Error("force_offline_update: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		h.log.Error("force_offline_commit_tx: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	h.log.WithFields(logger.LogFields{
+		"driver_id":       driverID,
+		"admin_id":        claims.UserID,
+		"previous_status": previousStatus,
+		"ride_id":         rideID,
+		"reason":          req.Reason,
+	}).Info("admin_force_offline", "Driver forced offline")
+
+	resp := ForceOfflineResponse{
+		DriverID:       driverID,
+		PreviousStatus: previousStatus,
+		Status:         "OFFLINE",
+		SessionEnded:   sessionOpen,
+		RideID:         rideID,
+		Message:        "Driver forced offline",
+	}
+
+	// The change is committed, so publish failures are reported in the message
+	// rather than as an error status
+	var failed []string
+	if err := h.publishForceOffline(ctx, driverID, claims.UserID, req.Reason); err != nil {
+		h.log.Error("force_offline_publish_control: ", err)
+		failed = append(failed, "the driver service could not be told to disconnect them and end their session")
+	}
+	if ride != nil {
+		if err := h.publishPassengerStatus(ctx, ride, "REQUESTED", "admin_force_offline"); err != nil {
+			h.log.Error("force_offline_notify_passenger: ", err)
+			failed = append(failed, "the passenger could not be notified")
+		}
+		// Re-enter matching
+		if err := h.publishRideRequest(ctx, ride); err != nil {
+			h.log.Error("force_offline_publish_ride: ", err)
+			failed = append(failed, "the ride's matching request could not be published")
+		} else {
+			resp.Message = "Driver forced offline and ride sent back to matching"
+		}
+	}
+	if len(failed) > 0 {
+		resp.Message = "Driver forced offline but " + strings.Join(failed, "; ")
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// publishForceOffline tells every driver service instance to drop the driver's
+// connection; the instances end the session and publish the OFFLINE status
+func (h *AdminHandler) publishForceOffline(ctx context.Context, driverID, adminID, reason string) error {
+	control, err := json.Marshal(map[string]interface{}{
+		"driver_id": driverID,
+		"action":    "force_offline",
+		"admin_id":  adminID,
+		"reason":    reason,
+		"timestamp": time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	return h.rabbit.Publish(ctx, "driver_topic", "driver.control."+driverID, control)
+}
@@ -0,0 +1,259 @@
+package adminservice
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ride-hail/pkg/auth"
+	"ride-hail/pkg/dbtest"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// byLicenseServer routes GET /admin/drivers/by-license/{license_number} the way main does
+func byLicenseServer(pool *pgxpool.Pool) http.Handler {
+	mux := http.NewServeMux()
+	h := NewAdminHandler(dbtest.Logger{}, pool, nil)
+	mux.Handle("GET /admin/drivers/by-license/{license_number}", adminRoute(h.getDriverByLicense))
+	return mux
+}
+
+// getByLicense looks license up as an admin and returns the recorder
+func getByLicense(t *testing.T, srv http.Handler, license string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, adminRequest(t, "/admin/drivers/by-license/"+license))
+	return w
+}
+
+func TestDriverByLicenseRejectsNonLicenses(t *testing.T) {
+	// Both are refused before any query runs
+	srv := byLicenseServer(nil)
+	for name, license := range map[string]string{
+		"blank":              "%20",
+		"placeholder prefix": "fake-",
+	} {
+		if w := getByLicense(t, srv, license); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", name, w.Code)
+		}
+	}
+}
+
+func TestDriverByLicenseRequiresAdmin(t *testing.T) {
+	srv := byLicenseServer(nil)
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/admin/drivers/by-license/DL-0012345", nil)
+	r.Header.Set("Authorization", "Bearer "+token(t, "22222222-2222-2222-2222-222222222222", auth.RoleDriver))
+	srv.ServeHTTP(w, r)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("driver token: status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestDriverByLicense(t *testing.T) {
+	pool := dbtest.Pool(t)
+	seedDrivers(t, pool, []seedDriver{
+		{email: "a@license.test", status: "AVAILABLE", verified: true, lat: 43.238949, lng: 76.889709},
+	})
+	var placeholderID string
+	if err := pool.QueryRow(context.Background(), `
+		WITH u AS (
+			INSERT INTO users (email, role, password_hash) VALUES ('new@license.test', 'DRIVER', 'x') RETURNING id
+		)
+		INSERT INTO drivers (id, license_number, vehicle_type, status)
+		SELECT id, 'FAKE-1a2b3c', 'ECONOMY', 'OFFLINE' FROM u
+		RETURNING id
+	`).Scan(&placeholderID); err != nil {
+		t.Fatalf("seed placeholder driver: %v", err)
+	}
+	srv := byLicenseServer(pool)
+
+	decode := func(t *testing.T, w *httptest.ResponseRecorder) DriverDetail {
+		t.Helper()
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s, want 200", w.Code, w.Body)
+		}
+		var d DriverDetail
+		if err := json.Unmarshal(w.Body.Bytes(), &d); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return d
+	}
+
+	t.Run("found", func(t *testing.T) {
+		// Typed in lower case from the document
+		d := decode(t, getByLicense(t, srv, "test-000"))
+		if d.LicenseNumber != "TEST-000" || d.Email != "a@license.test" || !d.IsVerified {
+			t.Errorf("driver = %+v, want the verified TEST-000 driver", d.DriverSummary)
+		}
+		if d.User.UserID != d.DriverID || d.User.Email != "a@license.test" || d.User.Role != "DRIVER" {
+			t.Errorf("user = %+v, want the driver's user record", d.User)
+		}
+		if d.LastLocation == nil || d.LastLocation.Latitude != 43.238949 {
+			t.Errorf("last_location = %+v, want the seeded position", d.LastLocation)
+		}
+		if d.PlaceholderLicense {
+			t.Error("real license flagged as a placeholder")
+		}
+	})
+
+	t.Run("placeholder license", func(t *testing.T) {
+		d := decode(t, getByLicense(t, srv, "FAKE-1a2b3c"))
+		if d.DriverID != placeholderID || !d.PlaceholderLicense {
+			t.Errorf("driver %s placeholder=%v, want %s flagged", d.DriverID, d.PlaceholderLicense, placeholderID)
+		}
+		if d.LastLocation != nil {
+			t.Errorf("last_location = %+v, want none", d.LastLocation)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		for _, license := range []string{"DL-9999999", "FAKE-000000"} {
+			if w := getByLicense(t, srv, license); w.Code != http.StatusNotFound {
+				t.Errorf("%s: status = %d, want 404", license, w.Code)
+			}
+		}
+	})
+}
+
+// forceOfflineServer routes POST /admin/drivers/{driver_id}/force-offline the way main does
+func forceOfflineServer(h *AdminHandler) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("POST /admin/drivers/{driver_id}/force-offline", adminRoute(h.forceDriverOffline))
+	return mux
+}
+
+func postForceOffline(t *testing.T, srv http.Handler, driverID, body string) (*httptest.ResponseRecorder, ForceOfflineResponse) {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/admin/drivers/"+driverID+"/force-offline", strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+token(t, "33333333-3333-3333-3333-333333333333", auth.RoleAdmin))
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, r)
+
+	var resp ForceOfflineResponse
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+	}
+	return w, resp
+}
+
+func TestForceOfflineRequiresAdmin(t *testing.T) {
+	srv := forceOfflineServer(NewAdminHandler(dbtest.Logger{}, nil, nil))
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/admin/drivers/22222222-2222-2222-2222-222222222222/force-offline", nil)
+	r.Header.Set("Authorization", "Bearer "+token(t, "22222222-2222-2222-2222-222222222222", auth.RoleDriver))
+	srv.ServeHTTP(w, r)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("driver token: status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestForceOfflineSendsRideBackToMatching(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ids := seedStuckRide(t, pool, "MATCHED", "BUSY")
+	ctx := context.Background()
+	if _, err := pool.Exec(ctx, `INSERT INTO driver_sessions (driver_id) VALUES ($1)`, ids.driver); err != nil {
+		t.Fatalf("seed session: %v", err)
+	}
+	pub := &fakePublisher{}
+	srv := forceOfflineServer(NewAdminHandler(dbtest.Logger{}, pool, pub))
+
+	w, resp := postForceOffline(t, srv, ids.driver, `{"reason":"stuck in the app"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if resp.PreviousStatus != "BUSY" || resp.Status != "OFFLINE" || !resp.SessionEnded || resp.RideID != ids.ride {
+		t.Errorf("response = %+v, want BUSY -> OFFLINE with the session and ride", resp)
+	}
+
+	var driverStatus string
+	var currentRide *string
+	if err := pool.QueryRow(ctx, `SELECT status, current_ride_id::text FROM drivers WHERE id = $1`, ids.driver).Scan(&driverStatus, &currentRide); err != nil {
+		t.Fatal(err)
+	}
+	if driverStatus != "OFFLINE" || currentRide != nil {
+		t.Errorf("driver = %s on ride %v, want OFFLINE with no ride", driverStatus, currentRide)
+	}
+
+	var rideStatus string
+	var rideDriver *string
+	if err := pool.QueryRow(ctx, `SELECT status, driver_id::text FROM rides WHERE id = $1`, ids.ride).Scan(&rideStatus, &rideDriver); err != nil {
+		t.Fatal(err)
+	}
+	if rideStatus != "REQUESTED" || rideDriver != nil {
+		t.Errorf("ride = %s with driver %v, want REQUESTED with no driver", rideStatus, rideDriver)
+	}
+
+	eventType, data := lastEvent(t, pool, ids.ride)
+	if eventType != "STATUS_CHANGED" || data["action"] != "admin_force_offline" || data["old_status"] != "MATCHED" ||
+		data["previous_driver_id"] != ids.driver || data["reason"] != "stuck in the app" {
+		t.Errorf("event = %s %v, want the forced offline audited", eventType, data)
+	}
+
+	if m, ok := pub.find("driver.control." + ids.driver); !ok || m.body["action"] != "force_offline" {
+		t.Errorf("control message = %v, want force_offline so the socket is closed", m.body)
+	}
+	if request, ok := pub.find("ride.request.ECONOMY"); !ok || request.body["ride_id"] != ids.ride {
+		t.Errorf("matching request = %v, want the ride re-published", request.body)
+	}
+	if m, ok := pub.find("driver.status." + ids.driver); !ok || m.body["new_status"] != "REQUESTED" {
+		t.Errorf("passenger update = %v, want the ride back in REQUESTED", m.body)
+	}
+}
+
+func TestForceOfflineWithoutARide(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ids := seedStuckRide(t, pool, "COMPLETED", "AVAILABLE")
+	pub := &fakePublisher{}
+	srv := forceOfflineServer(NewAdminHandler(dbtest.Logger{}, pool, pub))
+
+	w, resp := postForceOffline(t, srv, ids.driver, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if resp.RideID != "" || resp.SessionEnded {
+		t.Errorf("response = %+v, want no ride and no session", resp)
+	}
+	if _, ok := pub.find("driver.control." + ids.driver); !ok {
+		t.Error("driver service not told to disconnect the driver")
+	}
+	if _, ok := pub.find("ride.request.ECONOMY"); ok {
+		t.Error("a finished ride was sent back to matching")
+	}
+}
+
+func TestForceOfflineRejectsRideInProgress(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ids := seedStuckRide(t, pool, "IN_PROGRESS", "BUSY")
+	pub := &fakePublisher{}
+	srv := forceOfflineServer(NewAdminHandler(dbtest.Logger{}, pool, pub))
+
+	if w, _ := postForceOffline(t, srv, ids.driver, ""); w.Code != http.StatusConflict {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusConflict)
+	}
+	var status string
+	if err := pool.QueryRow(context.Background(), `SELECT status FROM drivers WHERE id = $1`, ids.driver).Scan(&status); err != nil {
+		t.Fatal(err)
+	}
+	if status != "BUSY" {
+		t.Errorf("driver = %s, want still BUSY", status)
+	}
+	if len(pub.messages) != 0 {
+		t.Errorf("published %d messages, want none", len(pub.messages))
+	}
+}
+
+func TestForceOfflineUnknownDriver(t *testing.T) {
+	srv := forceOfflineServer(NewAdminHandler(dbtest.Logger{}, dbtest.Pool(t), &fakePublisher{}))
+	if w, _ := postForceOffline(t, srv, "44444444-4444-4444-4444-444444444444", ""); w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
@@ -3,37 +3,58 @@ package adminservice
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"ride-hail/pkg/apitime"
 	"ride-hail/pkg/logger"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// publisher is the part of the RabbitMQ connection the handlers use
+type publisher interface {
+	Publish(ctx context.Context, exchange, routingKey string, body []byte) error
+}
+
 type AdminHandler struct {
-	log  logger.Logger
-	pool *pgxpool.Pool
+	log    logger.Logger
+	pool   *pgxpool.Pool
+	rabbit publisher
 }
 
 type OverviewMetrics struct {
-	ActiveRides         int `json:"active_rides"`
-	AvailableDrivers    int `json:"available_drivers"`
-	BusyDrivers         int `json:"busy_drivers"`
-	TotalRidesToday     int `json:"total_rides_today"`
-	TotalRevenueToday   int `json:"total_revenue_today"`
-	AverageWaitTime     int `json:"average_wait_time_minutes"`
-	AverageRideDuration int `json:"average_ride_duration_minutes"`
+	ActiveRides       int `json:"active_rides"`
+	AvailableDrivers  int `json:"available_drivers"`
+	BusyDrivers       int `json:"busy_drivers"`
+	TotalRidesToday   int `json:"total_rides_today"`
+	TotalRevenueToday int `json:"total_revenue_today"`
+
+	// Available drivers per vehicle type; the ride tiers are always present, other
+	// types only when some driver has them
+	AvailableByVehicleType map[string]int `json:"available_by_vehicle_type"`
+
+	// Averages in minutes, rounded to one decimal place; 0 when there is no data
+	AverageWaitTime     float64 `json:"average_wait_time_minutes"`
+	AverageRideDuration float64 `json:"average_ride_duration_minutes"`
+
+	// Reliability over rides requested today; rates are fractions in [0, 1]
+	CancellationRate    float64 `json:"cancellation_rate"`  // cancelled by a passenger, driver or admin
+	MatchFailureRate    float64 `json:"match_failure_rate"` // cancelled because no driver accepted in time
+	AverageMatchTimeSec float64 `json:"avg_match_time_seconds"`
 }
 type ActiveRide struct {
-	RideID             string    `json:"ride_id"`
-	RideNumber         string    `json:"ride_number"`
-	Status             string    `json:"status"`
-	PassengerID        string    `json:"passenger_id"`
-	DriverID           string    `json:"driver_id"`
-	PickupAddress      string    `json:"pickup_address"`
-	DestinationAddress string    `json:"destination_address"`
-	StartedAt          time.Time `json:"started_at"`
+	RideID             string       `json:"ride_id"`
+	RideNumber         string       `json:"ride_number"`
+	Status             string       `json:"status"`
+	PassengerID        string       `json:"passenger_id"`
+	DriverID           string       `json:"driver_id"`
+	PickupAddress      string       `json:"pickup_address"`
+	DestinationAddress string       `json:"destination_address"`
+	StartedAt          apitime.Time `json:"started_at"`
 }
 
 type ActiveRidesResponse struct {
@@ -43,10 +64,11 @@ type ActiveRidesResponse struct {
 	PageSize   int          `json:"page_size"`
 }
 
-func NewAdminHandler(log logger.Logger, pool *pgxpool.Pool) *AdminHandler {
+func NewAdminHandler(log logger.Logger, pool *pgxpool.Pool, rabbit publisher) *AdminHandler {
 	return &AdminHandler{
-		log:  log,
-		pool: pool,
+		log:    log,
+		pool:   pool,
+		rabbit: rabbit,
 	}
 }
 
@@ -66,7 +88,7 @@ func (h *AdminHandler) getOverviewMetrics(w http.ResponseWriter, r *http.Request
 
 	err = tx.QueryRow(ctx, `
 	SELECT COUNT(*) FROM rides
-	WHERE status IN ('REQUESTED', 'MATCHED', 'EN_ROUTE', 'ARRIVED', 'IN PROGRESS')
+	WHERE status IN ('REQUESTED', 'MATCHED', 'EN_ROUTE', 'ARRIVED', 'IN_PROGRESS')
 	`).Scan(&metrics.ActiveRides)
 	if err != nil {
 		h.log.Error("get_overview_query_active_rides: ", err)
@@ -84,6 +106,35 @@ func (h *AdminHandler) getOverviewMetrics(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	metrics.AvailableByVehicleType = map[string]int{"ECONOMY": 0, "PREMIUM": 0, "LUXURY": 0}
+	rows, err := tx.Query(ctx, `
+	SELECT vehicle_type, COUNT(*) FROM drivers
+	WHERE status = 'AVAILABLE' AND vehicle_type IS NOT NULL
+	GROUP BY vehicle_type
+	`)
+	if err != nil {
+		h.log.Error("get_overview_query_available_by_vehicle_type: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	for rows.Next() {
+		var vehicleType string
+		var count int
+		if err := rows.Scan(&vehicleType, &count); err != nil {
+			rows.Close()
+			h.log.Error("get_overview_scan_available_by_vehicle_type: ", err)
+			writeError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		metrics.AvailableByVehicleType[vehicleType] = count
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		h.log.Error("get_overview_query_available_by_vehicle_type: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
 	err = tx.QueryRow(ctx, `
 	SELECT COUNT(*) FROM drivers 
 	WHERE status IN ('BUSY', 'EN_ROUTE')
@@ -105,7 +156,7 @@ func (h *AdminHandler) getOverviewMetrics(w http.ResponseWriter, r *http.Request
 	}
 
 	err = tx.QueryRow(ctx, `
-	SELECT COALESCE(SUM(final_fare * 100), 0) FROM rides
+	SELECT COALESCE(SUM(final_fare * 100), 0)::bigint FROM rides
 	WHERE completed_at >= current_date AND status = 'COMPLETED'
 	`).Scan(&metrics.TotalRevenueToday)
 	if err != nil {
@@ -115,7 +166,7 @@ func (h *AdminHandler) getOverviewMetrics(w http.ResponseWriter, r *http.Request
 	}
 
 	err = tx.QueryRow(ctx, `
-	SELECT COALESCE(AVG(EXTRACT(EPOCH FROM (matched_at - requested_at))) / 60 ,0)
+	SELECT COALESCE(ROUND((AVG(EXTRACT(EPOCH FROM (matched_at - requested_at))) / 60)::numeric, 1), 0)::float
 	FROM rides
 	WHERE matched_at IS NOT NULL AND requested_at >= current_date
 	`).Scan(&metrics.AverageWaitTime)
@@ -126,7 +177,7 @@ func (h *AdminHandler) getOverviewMetrics(w http.ResponseWriter, r *http.Request
 	}
 
 	err = tx.QueryRow(ctx, `
-	SELECT COALESCE(AVG(EXTRACT(EPOCH FROM (completed_at - started_at))) / 60 ,0)
+	SELECT COALESCE(ROUND((AVG(EXTRACT(EPOCH FROM (completed_at - started_at))) / 60)::numeric, 1), 0)::float
 	FROM rides
 	WHERE status = 'COMPLETED' AND completed_at >= current_date
 	`).Scan(&metrics.AverageRideDuration)
@@ -136,6 +187,23 @@ func (h *AdminHandler) getOverviewMetrics(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// Rides that hit the matching deadline are cancelled with reason NO_DRIVER_FOUND
+	err = tx.QueryRow(ctx, `
+	SELECT
+		COALESCE(COUNT(*) FILTER (WHERE status = 'CANCELLED'
+			AND cancellation_reason IS DISTINCT FROM 'NO_DRIVER_FOUND')::float / NULLIF(COUNT(*), 0), 0),
+		COALESCE(COUNT(*) FILTER (WHERE status = 'CANCELLED'
+			AND cancellation_reason = 'NO_DRIVER_FOUND')::float / NULLIF(COUNT(*), 0), 0),
+		COALESCE(AVG(EXTRACT(EPOCH FROM (matched_at - requested_at))) FILTER (WHERE matched_at IS NOT NULL), 0)::float
+	FROM rides
+	WHERE requested_at >= current_date
+	`).Scan(&metrics.CancellationRate, &metrics.MatchFailureRate, &metrics.AverageMatchTimeSec)
+	if err != nil {
+		h.log.Error("get_overview_query_reliability: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
 	if err := tx.Commit(ctx); err != nil {
 		h.log.Error("get_overview_commit_tx: ", err)
 		writeError(w, http.StatusInternalServerError, "Database error")
@@ -167,7 +235,7 @@ func (h *AdminHandler) getActiveRides(w http.ResponseWriter, r *http.Request) {
 
 	err = tx.QueryRow(ctx, `
 	SELECT COUNT(*) FROM rides
-	WHERE status IN ('REQUESTED', 'MATCHED', 'EN_ROUTE', 'ARRIVED', 'IN_PROGRES')
+	WHERE status IN ('REQUESTED', 'MATCHED', 'EN_ROUTE', 'ARRIVED', 'IN_PROGRESS')
 	`).Scan(&response.TotalCount)
 	if err != nil {
 		h.log.Error("get_active_rides_total_count: ", err)
@@ -194,7 +262,7 @@ func (h *AdminHandler) getActiveRides(w http.ResponseWriter, r *http.Request) {
 		FROM rides AS r
 		LEFT JOIN coordinates pickup ON r.pickup_coordinate_id = pickup.id
 		LEFT JOIN coordinates destination ON r.destination_coordinate_id = destination.id
-		WHERE r.status IN ('REQUESTED', 'MATCHED', 'EN_ROUTE', 'ARRIVED', 'IN_PROGRES')
+		WHERE r.status IN ('REQUESTED', 'MATCHED', 'EN_ROUTE', 'ARRIVED', 'IN_PROGRESS')
 		ORDER BY r.requested_at DESC
 		LIMIT $1 OFFSET $2
 		`
@@ -232,7 +300,7 @@ func (h *AdminHandler) getActiveRides(w http.ResponseWriter, r *http.Request) {
 			ride.DriverID = driverID.String
 		}
 		if startedAt.Valid {
-			ride.StartedAt = startedAt.Time
+			ride.StartedAt = apitime.New(startedAt.Time)
 		}
 
 		response.Rides = append(response.Rides, ride)
@@ -250,3 +318,193 @@ func (h *AdminHandler) getActiveRides(w http.ResponseWriter, r *http.Request) {
 	}
 	writeJSON(w, http.StatusOK, response)
 }
+
+type DriverLocation struct {
+	Latitude  float64      `json:"latitude"`
+	Longitude float64      `json:"longitude"`
+	Address   string       `json:"address"`
+	UpdatedAt apitime.Time `json:"updated_at"`
+}
+
+type DriverSummary struct {
+	DriverID      string          `json:"driver_id"`
+	Email         string          `json:"email"`
+	LicenseNumber string          `json:"license_number"`
+	VehicleType   string          `json:"vehicle_type"`
+	Status        string          `json:"status"`
+	Rating        float64         `json:"rating"`
+	TotalRides    int             `json:"total_rides"`
+	TotalEarnings float64         `json:"total_earnings"`
+	IsVerified    bool            `json:"is_verified"`
+	LastLocation  *DriverLocation `json:"last_location"`
+}
+
+type DriversResponse struct {
+	Drivers    []DriverSummary `json:"drivers"`
+	TotalCount int             `json:"total_count"`
+	Page       int             `json:"page"`
+	PageSize   int             `json:"page_size"`
+}
+
+func (h *AdminHandler) searchDrivers(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	page, pageSize := parsePagination(r)
+	offset := (page - 1) * pageSize
+
+	where, args, err := buildDriverFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var response DriversResponse
+	response.Drivers = make([]DriverSummary, 0)
+	response.Page = page
+	response.PageSize = pageSize
+
+	tx, err := h.pool.Begin(ctx)
+	if err != nil {
+		h.log.Error("search_drivers: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+	SELECT COUNT(*) FROM drivers d
+	JOIN users u ON u.id = d.id
+	`+where, args...).Scan(&response.TotalCount)
+	if err != nil {
+		h.log.Error("search_drivers_total_count: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if response.TotalCount == 0 {
+		if err := tx.Commit(ctx); err != nil {
+			h.log.Error("search_drivers_commit_tx: ", err)
+			writeError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		writeJSON(w, http.StatusOK, response)
+		return
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			d.id, u.email, d.license_number, COALESCE(d.vehicle_type, ''), COALESCE(d.status, ''),
+			COALESCE(d.rating, 0), COALESCE(d.total_rides, 0), COALESCE(d.total_earnings, 0),
+			COALESCE(d.is_verified, false),
+			c.latitude, c.longitude, c.address, c.updated_at
+		FROM drivers d
+		JOIN users u ON u.id = d.id
+		LEFT JOIN coordinates c ON c.entity_id = d.id
+			AND c.entity_type = 'driver'
+			AND c.is_current = true
+		%s
+		ORDER BY u.email
+		LIMIT $%d OFFSET $%d
+		`, where, len(args)+1, len(args)+2)
+
+	rows, err := tx.Query(ctx, query, append(args, pageSize, offset)...)
+	if err != nil {
+		h.log.Error("search_drivers_rows: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var driver DriverSummary
+		var lat, lng sql.NullFloat64
+		var address sql.NullString
+		var updatedAt sql.NullTime
+
+		err := rows.Scan(
+			&driver.DriverID,
+			&driver.Email,
+			&driver.LicenseNumber,
+			&driver.VehicleType,
+			&driver.Status,
+			&driver.Rating,
+			&driver.TotalRides,
+			&driver.TotalEarnings,
+			&driver.IsVerified,
+			&lat,
+			&lng,
+			&address,
+			&updatedAt,
+		)
+		if err != nil {
+			h.log.Error("search_drivers_rows: ", err)
+			writeError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+
+		if lat.Valid && lng.Valid {
+			driver.LastLocation = &DriverLocation{
+				Latitude:  lat.Float64,
+				Longitude: lng.Float64,
+				Address:   address.String,
+				UpdatedAt: apitime.New(updatedAt.Time),
+			}
+		}
+
+		response.Drivers = append(response.Drivers, driver)
+	}
+	if err := rows.Err(); err != nil {
+		h.log.Error("search_drivers_rows: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		h.log.Error("search_drivers_commit_tx: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// buildDriverFilter turns the search query parameters into a WHERE clause with positional args.
+func buildDriverFilter(r *http.Request) (string, []interface{}, error) {
+	q := r.URL.Query()
+	var conds []string
+	var args []interface{}
+
+	if status := strings.ToUpper(q.Get("status")); status != "" {
+		switch status {
+		case "OFFLINE", "AVAILABLE", "BUSY", "EN_ROUTE", "ON_BREAK":
+		default:
+			return "", nil, fmt.Errorf("invalid status: %s", status)
+		}
+		args = append(args, status)
+		conds = append(conds, fmt.Sprintf("d.status = $%d", len(args)))
+	}
+
+	if vehicleType := strings.ToUpper(q.Get("vehicle_type")); vehicleType != "" {
+		args = append(args, vehicleType)
+		conds = append(conds, fmt.Sprintf("d.vehicle_type = $%d", len(args)))
+	}
+
+	if verified := q.Get("verified"); verified != "" {
+		v, err := strconv.ParseBool(verified)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid verified flag: %s", verified)
+		}
+		args = append(args, v)
+		conds = append(conds, fmt.Sprintf("COALESCE(d.is_verified, false) = $%d", len(args)))
+	}
+
+	if search := strings.TrimSpace(q.Get("q")); search != "" {
+		args = append(args, "%"+search+"%")
+		conds = append(conds, fmt.Sprintf("u.email ILIKE $%d", len(args)))
+	}
+
+	if len(conds) == 0 {
+		return "", args, nil
+	}
+	return "WHERE " + strings.Join(conds, " AND "), args, nil
+}
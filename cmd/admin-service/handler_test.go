@@ -0,0 +1,272 @@
+package adminservice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"ride-hail/pkg/auth"
+	"ride-hail/pkg/dbtest"
+)
+
+const testSecret = "test-secret"
+
+func TestBuildDriverFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		wantWhere string
+		wantArgs  []interface{}
+		wantErr   bool
+	}{
+		{name: "no filters", query: "", wantWhere: ""},
+		{
+			name:      "status is upper-cased",
+			query:     "status=available",
+			wantWhere: "WHERE d.status = $1",
+			wantArgs:  []interface{}{"AVAILABLE"},
+		},
+		{name: "unknown status", query: "status=sleeping", wantErr: true},
+		{
+			name:      "verified",
+			query:     "verified=true",
+			wantWhere: "WHERE COALESCE(d.is_verified, false) = $1",
+			wantArgs:  []interface{}{true},
+		},
+		{
+			name:      "unverified",
+			query:     "verified=false",
+			wantWhere: "WHERE COALESCE(d.is_verified, false) = $1",
+			wantArgs:  []interface{}{false},
+		},
+		{name: "bad verified flag", query: "verified=maybe", wantErr: true},
+		{
+			name:      "all filters numbered in order",
+			query:     "status=BUSY&vehicle_type=premium&verified=1&q=%20mike%20",
+			wantWhere: "WHERE d.status = $1 AND d.vehicle_type = $2 AND COALESCE(d.is_verified, false) = $3 AND u.email ILIKE $4",
+			wantArgs:  []interface{}{"BUSY", "PREMIUM", true, "%mike%"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/admin/drivers?"+tt.query, nil)
+			where, args, err := buildDriverFilter(r)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %q %v", where, args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("buildDriverFilter: %v", err)
+			}
+			if where != tt.wantWhere {
+				t.Errorf("where = %q, want %q", where, tt.wantWhere)
+			}
+			if len(args) != 0 || len(tt.wantArgs) != 0 {
+				if !reflect.DeepEqual(args, tt.wantArgs) {
+					t.Errorf("args = %v, want %v", args, tt.wantArgs)
+				}
+			}
+		})
+	}
+}
+
+func TestParsePagination(t *testing.T) {
+	tests := []struct {
+		query        string
+		wantPage     int
+		wantPageSize int
+	}{
+		{query: "", wantPage: 1, wantPageSize: 10},
+		{query: "page=3&page_size=25", wantPage: 3, wantPageSize: 25},
+		{query: "page=2&pageSize=5", wantPage: 2, wantPageSize: 5},
+		{query: "page=0&page_size=0", wantPage: 1, wantPageSize: 10},
+		{query: "page=x&page_size=101", wantPage: 1, wantPageSize: 10},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/admin/drivers?"+tt.query, nil)
+		page, size := parsePagination(r)
+		if page != tt.wantPage || size != tt.wantPageSize {
+			t.Errorf("parsePagination(%q) = %d, %d; want %d, %d", tt.query, page, size, tt.wantPage, tt.wantPageSize)
+		}
+	}
+}
+
+func TestSearchDriversRequiresAdmin(t *testing.T) {
+	h := NewAdminHandler(dbtest.Logger{}, nil, nil)
+	srv := adminRoute(h.searchDrivers)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/admin/drivers", nil)
+	r.Header.Set("Authorization", "Bearer "+token(t, "22222222-2222-2222-2222-222222222222", auth.RoleDriver))
+	srv.ServeHTTP(w, r)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("driver token: status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestSearchDriversFiltersAndPages(t *testing.T) {
+	pool := dbtest.Pool(t)
+	seedDrivers(t, pool, []seedDriver{
+		{email: "a@search.test", status: "AVAILABLE", verified: true, lat: 43.238949, lng: 76.889709},
+		{email: "b@search.test", status: "AVAILABLE", verified: false},
+		{email: "c@search.test", status: "AVAILABLE", verified: true},
+		{email: "d@search.test", status: "BUSY", verified: true},
+		{email: "e@search.test", status: "OFFLINE", verified: false},
+	})
+	srv := adminRoute(NewAdminHandler(dbtest.Logger{}, pool, nil).searchDrivers)
+
+	t.Run("status", func(t *testing.T) {
+		resp := getDrivers(t, srv, "q=search.test&status=available")
+		if resp.TotalCount != 3 || emails(resp) != "a b c" {
+			t.Errorf("got %d drivers %q, want the 3 available ones", resp.TotalCount, emails(resp))
+		}
+		for _, d := range resp.Drivers {
+			if d.Status != "AVAILABLE" {
+				t.Errorf("driver %s has status %s", d.Email, d.Status)
+			}
+		}
+	})
+
+	t.Run("verified", func(t *testing.T) {
+		resp := getDrivers(t, srv, "q=search.test&verified=true")
+		if resp.TotalCount != 3 || emails(resp) != "a c d" {
+			t.Errorf("got %d drivers %q, want the 3 verified ones", resp.TotalCount, emails(resp))
+		}
+		resp = getDrivers(t, srv, "q=search.test&verified=false&status=offline")
+		if resp.TotalCount != 1 || emails(resp) != "e" {
+			t.Errorf("got %d drivers %q, want only the unverified offline one", resp.TotalCount, emails(resp))
+		}
+	})
+
+	t.Run("pagination", func(t *testing.T) {
+		resp := getDrivers(t, srv, "q=search.test&page=2&page_size=2")
+		if resp.TotalCount != 5 || resp.Page != 2 || resp.PageSize != 2 {
+			t.Errorf("total/page/size = %d/%d/%d, want 5/2/2", resp.TotalCount, resp.Page, resp.PageSize)
+		}
+		if emails(resp) != "c d" {
+			t.Errorf("page 2 = %q, want the 3rd and 4th drivers by email", emails(resp))
+		}
+		if resp := getDrivers(t, srv, "q=search.test&page=4&page_size=2"); len(resp.Drivers) != 0 || resp.TotalCount != 5 {
+			t.Errorf("past the end: %d drivers of %d, want none of 5", len(resp.Drivers), resp.TotalCount)
+		}
+	})
+
+	t.Run("last location", func(t *testing.T) {
+		resp := getDrivers(t, srv, "q=a@search.test")
+		if len(resp.Drivers) != 1 || resp.Drivers[0].LastLocation == nil {
+			t.Fatalf("got %+v, want driver a with a location", resp.Drivers)
+		}
+		if loc := resp.Drivers[0].LastLocation; loc.Latitude != 43.238949 || loc.Longitude != 76.889709 {
+			t.Errorf("location = %+v", loc)
+		}
+		if resp := getDrivers(t, srv, "q=b@search.test"); resp.Drivers[0].LastLocation != nil {
+			t.Errorf("driver without coordinates has location %+v", resp.Drivers[0].LastLocation)
+		}
+	})
+
+	t.Run("bad filter", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, adminRequest(t, "/admin/drivers?status=sleeping"))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+		}
+	})
+}
+
+// adminRoute wraps handler the way main does
+func adminRoute(handler http.HandlerFunc) http.Handler {
+	jwt := auth.NewJWTManager(testSecret, time.Hour)
+	return jwt.AuthMiddleware(adminOnly(dbtest.Logger{}, handler))
+}
+
+func token(t *testing.T, userID string, role auth.Role) string {
+	t.Helper()
+	tok, err := auth.NewJWTManager(testSecret, time.Hour).GenerateToken(userID, role)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	return tok
+}
+
+func adminRequest(t *testing.T, target string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	r.Header.Set("Authorization", "Bearer "+token(t, "33333333-3333-3333-3333-333333333333", auth.RoleAdmin))
+	return r
+}
+
+func getDrivers(t *testing.T, srv http.Handler, query string) DriversResponse {
+	t.Helper()
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, adminRequest(t, "/admin/drivers?"+query))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /admin/drivers?%s: status %d: %s", query, w.Code, w.Body)
+	}
+	var resp DriversResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return resp
+}
+
+// emails returns the local parts of the listed drivers' emails, space separated
+func emails(resp DriversResponse) string {
+	var out string
+	for i, d := range resp.Drivers {
+		if i > 0 {
+			out += " "
+		}
+		local, _, _ := strings.Cut(d.Email, "@")
+		out += local
+	}
+	return out
+}
+
+type seedDriver struct {
+	email       string
+	status      string
+	verified    bool
+	lat, lng    float64 // 0, 0 = no current location
+	vehicleType string  // ECONOMY when empty
+}
+
+func seedDrivers(t *testing.T, pool *pgxpool.Pool, drivers []seedDriver) {
+	t.Helper()
+	ctx := context.Background()
+	for i, d := range drivers {
+		var id string
+		err := pool.QueryRow(ctx, `
+			INSERT INTO users (email, role, password_hash) VALUES ($1, 'DRIVER', 'x') RETURNING id
+		`, d.email).Scan(&id)
+		if err != nil {
+			t.Fatalf("seed user %s: %v", d.email, err)
+		}
+		_, err = pool.Exec(ctx, `
+			INSERT INTO drivers (id, license_number, vehicle_type, status, is_verified)
+			VALUES ($1, $2, COALESCE(NULLIF($5, ''), 'ECONOMY'), $3, $4)
+		`, id, fmt.Sprintf("TEST-%03d", i), d.status, d.verified, d.vehicleType)
+		if err != nil {
+			t.Fatalf("seed driver %s: %v", d.email, err)
+		}
+		if d.lat == 0 && d.lng == 0 {
+			continue
+		}
+		_, err = pool.Exec(ctx, `
+			INSERT INTO coordinates (entity_id, entity_type, address, latitude, longitude)
+			VALUES ($1, 'driver', 'Almaty', $2, $3)
+		`, id, d.lat, d.lng)
+		if err != nil {
+			t.Fatalf("seed location %s: %v", d.email, err)
+		}
+	}
+}
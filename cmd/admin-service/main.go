@@ -11,9 +11,12 @@ import (
 	"time"
 
 	"ride-hail/pkg/auth"
+	"ride-hail/pkg/buildinfo"
 	"ride-hail/pkg/config"
 	"ride-hail/pkg/db"
 	"ride-hail/pkg/logger"
+	"ride-hail/pkg/middleware"
+	"ride-hail/pkg/rabbitmq"
 )
 
 func AdminService() {
@@ -25,6 +28,10 @@ func AdminService() {
 		log.Error("startup", fmt.Errorf("Failed to load config: %w", err))
 		os.Exit(1)
 	}
+	if err := cfg.Validate(); err != nil {
+		log.Error("startup", err)
+		os.Exit(1)
+	}
 	log.Info("config_loaded", "Configuration loaded successfully: "+cfg.TestVariable)
 
 	pool, err := db.NewConnection(cfg, log)
@@ -34,29 +41,39 @@ func AdminService() {
 	}
 	defer pool.Close()
 
-	sKey := os.Getenv("JWT_SECRET_KEY")
-	if sKey == "" {
-		log.Error("startup", fmt.Errorf("JWT_SECRET_KEY environment variable not set"))
-		sKey = "someone"
+	rabbit, err := rabbitmq.NewConnection(cfg, log)
+	if err != nil {
+		log.Error("startup", fmt.Errorf("Failed to connect to RabbitMQ: %w", err))
+		os.Exit(1)
 	}
-	jwtManager := auth.NewJWTManager(sKey, 1*time.Hour)
+	defer rabbit.Close()
+
+	jwtManager := auth.NewJWTManager(cfg.Auth.JWTSecret, 1*time.Hour)
 
 	mux := http.NewServeMux()
-	adminHandler := NewAdminHandler(log, pool)
+	adminHandler := NewAdminHandler(log, pool, rabbit)
 
 	overviewHandler := jwtManager.AuthMiddleware(adminOnly(log, http.HandlerFunc(adminHandler.getOverviewMetrics)))
 	activeRidesHandler := jwtManager.AuthMiddleware(adminOnly(log, http.HandlerFunc(adminHandler.getActiveRides)))
-
+	driversHandler := jwtManager.AuthMiddleware(adminOnly(log, http.HandlerFunc(adminHandler.searchDrivers)))
+	driverByLicenseHandler := jwtManager.AuthMiddleware(adminOnly(log, http.HandlerFunc(adminHandler.getDriverByLicense)))
+	reassignRideHandler := jwtManager.AuthMiddleware(adminOnly(log, http.HandlerFunc(adminHandler.reassignRide)))
+	forceCompleteRideHandler := jwtManager.AuthMiddleware(adminOnly(log, http.HandlerFunc(adminHandler.forceCompleteRide)))
+	cancelRideHandler := jwtManager.AuthMiddleware(adminOnly(log, http.HandlerFunc(adminHandler.cancelRide)))
+	forceOfflineHandler := jwtManager.AuthMiddleware(adminOnly(log, http.HandlerFunc(adminHandler.forceDriverOffline)))
+
+	mux.Handle("GET /version", buildinfo.Handler("admin-service"))
 	mux.Handle("GET /admin/overview", overviewHandler)
 	mux.Handle("GET /admin/rides/active", activeRidesHandler)
+	mux.Handle("GET /admin/drivers", driversHandler)
+	mux.Handle("GET /admin/drivers/by-license/{license_number}", driverByLicenseHandler)
+	mux.Handle("POST /admin/drivers/{driver_id}/force-offline", forceOfflineHandler)
+	mux.Handle("POST /admin/rides/{ride_id}/reassign", reassignRideHandler)
+	mux.Handle("POST /admin/rides/{ride_id}/force-complete", forceCompleteRideHandler)
+	mux.Handle("POST /admin/rides/{ride_id}/cancel", cancelRideHandler)
 
-	server := &http.Server{
-		Addr:         fmt.Sprintf(":%d", cfg.Services.AdminService),
-		Handler:      mux,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  120 * time.Second,
-	}
+	handler := middleware.Chain(mux, middleware.Recovery(log), middleware.Logging(log))
+	server := cfg.HTTPServer.NewServer(fmt.Sprintf(":%d", cfg.Services.AdminService), handler)
 
 	serverErrors := make(chan error, 1)
 
@@ -77,7 +94,7 @@ func AdminService() {
 		log.Info("shutdown", "Shutdown signal received. Starting graceful shutdown...")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTPServer.ShutdownTimeout)
 	defer cancel()
 	if err := server.Shutdown(ctx); err != nil {
 		log.Error("shutdown", fmt.Errorf("failed to gracefully shutdown: %w", err))
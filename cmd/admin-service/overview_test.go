@@ -0,0 +1,217 @@
+package adminservice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"ride-hail/pkg/dbtest"
+)
+
+// seedOutcome is a ride requested now that ended up in status; matchedAfterS,
+// if set, is how many seconds after the request it was matched, and rideS how
+// many seconds it took from the match to completion
+type seedOutcome struct {
+	status, reason string
+	matchedAfterS  int
+	rideS          int
+}
+
+func seedOutcomes(t *testing.T, pool *pgxpool.Pool, outcomes []seedOutcome) {
+	t.Helper()
+	ctx := context.Background()
+	// Rides from the migrations' mock data count from earlier days
+	if _, err := pool.Exec(ctx, `UPDATE rides SET requested_at = requested_at - INTERVAL '2 days'`); err != nil {
+		t.Fatalf("age mock rides: %v", err)
+	}
+	var passengerID string
+	if err := pool.QueryRow(ctx, `
+		INSERT INTO users (email, role, password_hash) VALUES ('kpi-passenger@test', 'PASSENGER', 'x') RETURNING id
+	`).Scan(&passengerID); err != nil {
+		t.Fatalf("seed passenger: %v", err)
+	}
+	for i, o := range outcomes {
+		_, err := pool.Exec(ctx, `
+			INSERT INTO rides (ride_number, passenger_id, vehicle_type, status, cancellation_reason, requested_at, matched_at, started_at, completed_at)
+			VALUES ($1, $2, 'ECONOMY', $3, NULLIF($4, ''), NOW(),
+				CASE WHEN $5::int > 0 THEN NOW() + make_interval(secs => $5::int) END,
+				CASE WHEN $6::int > 0 THEN NOW() + make_interval(secs => $5::int) END,
+				CASE WHEN $6::int > 0 THEN NOW() + make_interval(secs => $5::int + $6::int) END)
+		`, fmt.Sprintf("RIDE_KPI_%03d", i), passengerID, o.status, o.reason, o.matchedAfterS, o.rideS)
+		if err != nil {
+			t.Fatalf("seed ride %d: %v", i, err)
+		}
+	}
+}
+
+func getOverview(t *testing.T, pool *pgxpool.Pool) OverviewMetrics {
+	t.Helper()
+	h := NewAdminHandler(dbtest.Logger{}, pool, nil)
+	w := httptest.NewRecorder()
+	adminRoute(h.getOverviewMetrics).ServeHTTP(w, adminRequest(t, "/admin/overview"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /admin/overview: status %d: %s", w.Code, w.Body)
+	}
+	var metrics OverviewMetrics
+	if err := json.Unmarshal(w.Body.Bytes(), &metrics); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return metrics
+}
+
+func TestOverviewReliabilityRates(t *testing.T) {
+	pool := dbtest.Pool(t)
+	seedOutcomes(t, pool, []seedOutcome{
+		{status: "COMPLETED", matchedAfterS: 60},
+		{status: "COMPLETED", matchedAfterS: 120},
+		{status: "IN_PROGRESS", matchedAfterS: 90},
+		{status: "CANCELLED", reason: "changed plans", matchedAfterS: 30},
+		{status: "CANCELLED", reason: "NO_DRIVER_FOUND"},
+		{status: "CANCELLED", reason: "NO_DRIVER_FOUND"},
+		{status: "CANCELLED"}, // no reason given still counts as a cancellation
+		{status: "REQUESTED"},
+	})
+
+	m := getOverview(t, pool)
+
+	// 8 rides today: 2 cancelled by someone, 2 never matched, 4 matched after 75 s on average
+	if math.Abs(m.CancellationRate-0.25) > 1e-9 {
+		t.Errorf("cancellation_rate = %v, want 0.25", m.CancellationRate)
+	}
+	if math.Abs(m.MatchFailureRate-0.25) > 1e-9 {
+		t.Errorf("match_failure_rate = %v, want 0.25", m.MatchFailureRate)
+	}
+	if math.Abs(m.AverageMatchTimeSec-75) > 0.5 {
+		t.Errorf("avg_match_time_seconds = %v, want 75", m.AverageMatchTimeSec)
+	}
+}
+
+func TestOverviewReliabilityWithoutRidesToday(t *testing.T) {
+	pool := dbtest.Pool(t)
+	seedOutcomes(t, pool, nil)
+
+	m := getOverview(t, pool)
+	if m.CancellationRate != 0 || m.MatchFailureRate != 0 || m.AverageMatchTimeSec != 0 {
+		t.Errorf("rates = %v, %v, %v s; want all 0 with no rides today", m.CancellationRate, m.MatchFailureRate, m.AverageMatchTimeSec)
+	}
+}
+
+func TestOverviewAveragesKeepFractionalMinutes(t *testing.T) {
+	pool := dbtest.Pool(t)
+	seedOutcomes(t, pool, []seedOutcome{
+		{status: "COMPLETED", matchedAfterS: 45, rideS: 130},
+		{status: "COMPLETED", matchedAfterS: 20, rideS: 200},
+	})
+
+	m := getOverview(t, pool)
+
+	// A 32.5 s wait is 0.54 minutes, which an int would have truncated to 0
+	if m.AverageWaitTime != 0.5 {
+		t.Errorf("average_wait_time_minutes = %v, want 0.5", m.AverageWaitTime)
+	}
+	// 165 s is 2.75 minutes, rounded to one decimal
+	if m.AverageRideDuration != 2.8 {
+		t.Errorf("average_ride_duration_minutes = %v, want 2.8", m.AverageRideDuration)
+	}
+}
+
+func TestOverviewAveragesWithoutRidesToday(t *testing.T) {
+	pool := dbtest.Pool(t)
+	seedOutcomes(t, pool, nil)
+
+	m := getOverview(t, pool)
+	if m.AverageWaitTime != 0 || m.AverageRideDuration != 0 {
+		t.Errorf("averages = %v, %v min; want 0 with no rides today", m.AverageWaitTime, m.AverageRideDuration)
+	}
+}
+
+func TestActiveRidesListsInProgressRides(t *testing.T) {
+	pool := dbtest.Pool(t)
+	seedOutcomes(t, pool, []seedOutcome{
+		{status: "IN_PROGRESS", matchedAfterS: 30},
+		{status: "COMPLETED", matchedAfterS: 30, rideS: 60},
+	})
+
+	h := NewAdminHandler(dbtest.Logger{}, pool, nil)
+	w := httptest.NewRecorder()
+	adminRoute(h.getActiveRides).ServeHTTP(w, adminRequest(t, "/admin/rides/active?page_size=100"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /admin/rides/active: status %d: %s", w.Code, w.Body)
+	}
+	var resp ActiveRidesResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+
+	listed := map[string]ActiveRide{}
+	for _, r := range resp.Rides {
+		listed[r.RideNumber] = r
+	}
+	inProgress, ok := listed["RIDE_KPI_000"]
+	if !ok {
+		t.Fatalf("in-progress ride not listed among %d rides", len(resp.Rides))
+	}
+	// Seeded without a driver, which must scan as empty rather than fail
+	if inProgress.DriverID != "" {
+		t.Errorf("driver_id = %q, want empty", inProgress.DriverID)
+	}
+	if _, ok := listed["RIDE_KPI_001"]; ok {
+		t.Error("completed ride listed as active")
+	}
+	if resp.TotalCount != len(resp.Rides) {
+		t.Errorf("total_count = %d, want the %d rides listed", resp.TotalCount, len(resp.Rides))
+	}
+}
+
+func TestOverviewCountsAvailableDriversPerVehicleType(t *testing.T) {
+	pool := dbtest.Pool(t)
+	// Drivers from the migrations' mock data would skew the counts
+	if _, err := pool.Exec(context.Background(), `UPDATE drivers SET status = 'OFFLINE'`); err != nil {
+		t.Fatalf("take mock drivers offline: %v", err)
+	}
+	seedDrivers(t, pool, []seedDriver{
+		{email: "eco1@test", status: "AVAILABLE"},
+		{email: "eco2@test", status: "AVAILABLE", vehicleType: "ECONOMY"},
+		{email: "eco3@test", status: "BUSY"},
+		{email: "prem1@test", status: "AVAILABLE", vehicleType: "PREMIUM"},
+		{email: "prem2@test", status: "OFFLINE", vehicleType: "PREMIUM"},
+		{email: "lux1@test", status: "EN_ROUTE", vehicleType: "LUXURY"},
+		{email: "xl1@test", status: "AVAILABLE", vehicleType: "XL"},
+	})
+
+	m := getOverview(t, pool)
+
+	want := map[string]int{"ECONOMY": 2, "PREMIUM": 1, "LUXURY": 0, "XL": 1}
+	if len(m.AvailableByVehicleType) != len(want) {
+		t.Errorf("available_by_vehicle_type = %v, want %v", m.AvailableByVehicleType, want)
+	}
+	for vehicleType, n := range want {
+		if got, ok := m.AvailableByVehicleType[vehicleType]; !ok || got != n {
+			t.Errorf("available_by_vehicle_type[%s] = %d (present %v), want %d", vehicleType, got, ok, n)
+		}
+	}
+	if m.AvailableDrivers != 4 {
+		t.Errorf("available_drivers = %d, want 4, the sum over types", m.AvailableDrivers)
+	}
+}
+
+func TestOverviewListsEveryTierWithoutAvailableDrivers(t *testing.T) {
+	pool := dbtest.Pool(t)
+	if _, err := pool.Exec(context.Background(), `UPDATE drivers SET status = 'OFFLINE'`); err != nil {
+		t.Fatalf("take mock drivers offline: %v", err)
+	}
+
+	m := getOverview(t, pool)
+
+	for _, tier := range []string{"ECONOMY", "PREMIUM", "LUXURY"} {
+		if got, ok := m.AvailableByVehicleType[tier]; !ok || got != 0 {
+			t.Errorf("available_by_vehicle_type[%s] = %d (present %v), want an explicit 0", tier, got, ok)
+		}
+	}
+}
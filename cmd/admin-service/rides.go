@@ -0,0 +1,511 @@
+package adminservice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"ride-hail/pkg/auth"
+	"ride-hail/pkg/validation"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type ReassignRideRequest struct {
+	Reason string `json:"reason"`
+}
+
+type ForceCompleteRequest struct {
+	FinalFare float64 `json:"final_fare"`
+	Reason    string  `json:"reason"`
+}
+
+type CancelRideRequest struct {
+	Reason string `json:"reason"`
+}
+
+// cancelledByAdmin is the rides.cancelled_by party of rides cancelled here
+const cancelledByAdmin = "ADMIN"
+
+type RideActionResponse struct {
+	RideID         string  `json:"ride_id"`
+	PreviousStatus string  `json:"previous_status"`
+	Status         string  `json:"status"`
+	DriverID       string  `json:"driver_id,omitempty"`
+	FinalFare      float64 `json:"final_fare,omitempty"`
+	Message        string  `json:"message"`
+}
+
+// stuckRide is the subset of a ride needed for admin recovery actions
+type stuckRide struct {
+	ID            string
+	RideNumber    string
+	Status        string
+	PassengerID   string
+	DriverID      string
+	VehicleType   string
+	EstimatedFare float64
+	PickupLat     float64
+	PickupLng     float64
+	PickupAddr    string
+	DestLat       float64
+	DestLng       float64
+	DestAddr      string
+	Passengers    int
+}
+
+// loadRideForUpdate locks the ride row for the rest of the transaction
+func loadRideForUpdate(ctx context.Context, tx pgx.Tx, rideID string) (*stuckRide, error) {
+	var ride stuckRide
+	err := tx.QueryRow(ctx, `
+		SELECT
+			r.id, r.ride_number, r.status, r.passenger_id, COALESCE(r.driver_id::text, ''),
+			r.vehicle_type, COALESCE(r.estimated_fare, 0),
+			COALESCE(cp.latitude, 0), COALESCE(cp.longitude, 0), COALESCE(cp.address, ''),
+			COALESCE(cd.latitude, 0), COALESCE(cd.longitude, 0), COALESCE(cd.address, ''),
+			r.passenger_count
+		FROM rides r
+		LEFT JOIN coordinates cp ON r.pickup_coordinate_id = cp.id
+		LEFT JOIN coordinates cd ON r.destination_coordinate_id = cd.id
+		WHERE r.id = $1
+		FOR UPDATE OF r
+	`, rideID).Scan(
+		&ride.ID, &ride.RideNumber, &ride.Status, &ride.PassengerID, &ride.DriverID,
+		&ride.VehicleType, &ride.EstimatedFare,
+		&ride.PickupLat, &ride.PickupLng, &ride.PickupAddr,
+		&ride.DestLat, &ride.DestLng, &ride.DestAddr, &ride.Passengers,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &ride, nil
+}
+
+// releaseDriver frees the driver held by a stuck ride; offline drivers stay offline
+func releaseDriver(ctx context.Context, tx pgx.Tx, driverID string) error {
+	if driverID == "" {
+		return nil
+	}
+	_, err := tx.Exec(ctx, `
+		UPDATE drivers
+		SET status = CASE WHEN status IN ('BUSY', 'EN_ROUTE') THEN 'AVAILABLE' ELSE status END,
+			current_ride_id = NULL,
+			updated_at = NOW()
+		WHERE id = $1
+	`, driverID)
+	return err
+}
+
+// insertAdminEvent records an admin action in the ride audit trail
+func insertAdminEvent(ctx context.Context, tx pgx.Tx, rideID, eventType string, data map[string]interface{}) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO ride_events (ride_id, event_type, event_data)
+		VALUES ($1, $2, $3)
+	`, rideID, eventType, body)
+	return err
+}
+
+// reassignRide sends a stuck ride back to matching: POST /admin/rides/{ride_id}/reassign
+func (h *AdminHandler) reassignRide(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	rideID := r.PathValue("ride_id")
+	var req ReassignRideRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, validation.DecodeErrorMessage(err))
+			return
+		}
+	}
+	claims, _ := auth.GetClaims(r.Context())
+
+	tx, err := h.pool.Begin(ctx)
+	if err != nil {
+		h.log.Error("reassign_ride: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	ride, err := loadRideForUpdate(ctx, tx, rideID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "Ride not found")
+		return
+	}
+	if err != nil {
+		h.log.Error("reassign_ride_load: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	switch ride.Status {
+	case "REQUESTED", "MATCHED", "EN_ROUTE", "ARRIVED":
+	default:
+		writeError(w, http.StatusConflict, fmt.Sprintf("Ride in status %s cannot be reassigned", ride.Status))
+		return
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE rides
+		SET status = 'REQUESTED', driver_id = NULL, matched_at = NULL, arrived_at = NULL, updated_at = NOW()
+		WHERE id = $1
+	`, ride.ID)
+	if err != nil {
+		h.log.Error("reassign_ride_update: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if err := releaseDriver(ctx, tx, ride.DriverID); err != nil {
+		h.log.Error("reassign_ride_release_driver: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	err = insertAdminEvent(ctx, tx, ride.ID, "STATUS_CHANGED", map[string]interface{}{
+		"action":             "admin_reassign",
+		"old_status":         ride.Status,
+		"new_status":         "REQUESTED",
+		"previous_driver_id": ride.DriverID,
+		"admin_id":           claims.UserID,
+		"reason":             req.Reason,
+	})
+	if err != nil {
+		h.log.Error("reassign_ride_event: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		h.log.Error("reassign_ride_commit_tx: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// The same messages as an expired assignment: the driver service frees and
+	// tells the driver, the ride service tells the passenger. The change is
+	// committed, so publish failures are reported in the message.
+	var failed []string
+	if ride.DriverID != "" {
+		if err := h.publishRideStatus(ctx, ride, "REQUESTED", "admin_reassign", 0); err != nil {
+			h.log.Error("reassign_ride_notify_driver: ", err)
+			failed = append(failed, "the driver could not be notified")
+		}
+		if err := h.publishPassengerStatus(ctx, ride, "REQUESTED", "admin_reassign"); err != nil {
+			h.log.Error("reassign_ride_notify_passenger: ", err)
+			failed = append(failed, "the passenger could not be notified")
+		}
+	}
+	// Re-enter matching
+	if err := h.publishRideRequest(ctx, ride); err != nil {
+		h.log.Error("reassign_ride_publish: ", err)
+		failed = append(failed, "the matching request could not be published")
+	}
+
+	message := "Ride sent back to matching"
+	if len(failed) > 0 {
+		message = "Ride reset but " + strings.Join(failed, "; ")
+	}
+	writeJSON(w, http.StatusOK, RideActionResponse{
+		RideID:         ride.ID,
+		PreviousStatus: ride.Status,
+		Status:         "REQUESTED",
+		DriverID:       ride.DriverID,
+		Message:        message,
+	})
+}
+
+// forceCompleteRide completes a stuck ride with a manual fare: POST /admin/rides/{ride_id}/force-complete
+func (h *AdminHandler) forceCompleteRide(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	rideID := r.PathValue("ride_id")
+	var req ForceCompleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, validation.DecodeErrorMessage(err))
+		return
+	}
+	if req.FinalFare < 0 {
+		writeError(w, http.StatusBadRequest, "final_fare must not be negative")
+		return
+	}
+	claims, _ := auth.GetClaims(r.Context())
+
+	tx, err := h.pool.Begin(ctx)
+	if err != nil {
+		h.log.Error("force_complete_ride: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	ride, err := loadRideForUpdate(ctx, tx, rideID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "Ride not found")
+		return
+	}
+	if err != nil {
+		h.log.Error("force_complete_ride_load: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if ride.Status == "COMPLETED" || ride.Status == "CANCELLED" {
+		writeError(w, http.StatusConflict, fmt.Sprintf("Ride is already %s", ride.Status))
+		return
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE rides
+		SET status = 'COMPLETED', final_fare = $1, completed_at = NOW(), updated_at = NOW()
+		WHERE id = $2
+	`, req.FinalFare, ride.ID)
+	if err != nil {
+		h.log.Error("force_complete_ride_update: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if err := releaseDriver(ctx, tx, ride.DriverID); err != nil {
+		h.log.Error("force_complete_ride_release_driver: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	err = insertAdminEvent(ctx, tx, ride.ID, "RIDE_COMPLETED", map[string]interface{}{
+		"action":         "admin_force_complete",
+		"old_status":     ride.Status,
+		"driver_id":      ride.DriverID,
+		"final_fare":     req.FinalFare,
+		"estimated_fare": ride.EstimatedFare,
+		"manual_fare":    true,
+		"admin_id":       claims.UserID,
+		"reason":         req.Reason,
+	})
+	if err != nil {
+		h.log.Error("force_complete_ride_event: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		h.log.Error("force_complete_ride_commit_tx: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// The driver service books the driver's share of the fare into their totals
+	// and tells them; the ride service tells the passenger
+	var failed []string
+	if ride.DriverID != "" {
+		if err := h.publishRideStatus(ctx, ride, "COMPLETED", "admin_force_complete", req.FinalFare); err != nil {
+			h.log.Error("force_complete_ride_notify_driver: ", err)
+			failed = append(failed, "the driver service could not be told, so the driver's totals are not updated")
+		}
+	}
+	if err := h.publishPassengerStatus(ctx, ride, "COMPLETED", "admin_force_complete"); err != nil {
+		h.log.Error("force_complete_ride_notify_passenger: ", err)
+		failed = append(failed, "the passenger could not be notified")
+	}
+
+	message := "Ride force-completed"
+	if len(failed) > 0 {
+		message = "Ride force-completed but " + strings.Join(failed, "; ")
+	}
+	writeJSON(w, http.StatusOK, RideActionResponse{
+		RideID:         ride.ID,
+		PreviousStatus: ride.Status,
+		Status:         "COMPLETED",
+		DriverID:       ride.DriverID,
+		FinalFare:      req.FinalFare,
+		Message:        message,
+	})
+}
+
+// cancelRide cancels a ride on the passenger's or driver's behalf: POST /admin/rides/{ride_id}/cancel
+func (h *AdminHandler) cancelRide(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	rideID := r.PathValue("ride_id")
+	var req CancelRideRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, validation.DecodeErrorMessage(err))
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		writeError(w, http.StatusBadRequest, "reason is required")
+		return
+	}
+	claims, _ := auth.GetClaims(r.Context())
+
+	tx, err := h.pool.Begin(ctx)
+	if err != nil {
+		h.log.Error("cancel_ride: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	ride, err := loadRideForUpdate(ctx, tx, rideID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "Ride not found")
+		return
+	}
+	if err != nil {
+		h.log.Error("cancel_ride_load: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if ride.Status == "COMPLETED" || ride.Status == "CANCELLED" {
+		writeError(w, http.StatusConflict, fmt.Sprintf("Ride is already %s", ride.Status))
+		return
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE rides
+		SET status = 'CANCELLED', cancelled_at = NOW(), cancellation_reason = $1, cancelled_by = $2, updated_at = NOW()
+		WHERE id = $3
+	`, req.Reason, cancelledByAdmin, ride.ID)
+	if err != nil {
+		h.log.Error("cancel_ride_update: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if err := releaseDriver(ctx, tx, ride.DriverID); err != nil {
+		h.log.Error("cancel_ride_release_driver: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	err = insertAdminEvent(ctx, tx, ride.ID, "RIDE_CANCELLED", map[string]interface{}{
+		"action":       "admin_cancel",
+		"old_status":   ride.Status,
+		"driver_id":    ride.DriverID,
+		"cancelled_by": cancelledByAdmin,
+		"admin_id":     claims.UserID,
+		"reason":       req.Reason,
+	})
+	if err != nil {
+		h.log.Error("cancel_ride_event: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		h.log.Error("cancel_ride_commit_tx: ", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// The driver service frees and tells the driver, or withdraws the offers
+	// still out for a ride being matched; the ride service tells the passenger
+	var failed []string
+	if err := h.publishRideStatus(ctx, ride, "CANCELLED", req.Reason, 0); err != nil {
+		h.log.Error("cancel_ride_notify_driver: ", err)
+		failed = append(failed, "the driver service could not be told")
+	}
+	if err := h.publishPassengerStatus(ctx, ride, "CANCELLED", req.Reason); err != nil {
+		h.log.Error("cancel_ride_notify_passenger: ", err)
+		failed = append(failed, "the passenger could not be notified")
+	}
+
+	message := "Ride cancelled"
+	if len(failed) > 0 {
+		message = "Ride cancelled but " + strings.Join(failed, "; ")
+	}
+	writeJSON(w, http.StatusOK, RideActionResponse{
+		RideID:         ride.ID,
+		PreviousStatus: ride.Status,
+		Status:         "CANCELLED",
+		DriverID:       ride.DriverID,
+		Message:        message,
+	})
+}
+
+// publishRideStatus publishes the ride's new status to ride_topic for the
+// driver service, in the format the ride service uses for its own updates
+func (h *AdminHandler) publishRideStatus(ctx context.Context, ride *stuckRide, status, reason string, finalFare float64) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"ride_id":      ride.ID,
+		"driver_id":    ride.DriverID,
+		"passenger_id": ride.PassengerID,
+		"status":       status,
+		"final_fare":   finalFare,
+		"reason":       reason,
+		"timestamp":    time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	return h.rabbit.Publish(ctx, "ride_topic", "ride.status."+status, body)
+}
+
+// publishPassengerStatus hands a ride status the admin service has already
+// written to the ride service, which passes it on to the passenger. It goes out
+// as a driver status because that is the queue the ride service reads ride
+// progress from; "recorded" tells it not to write the ride again.
+func (h *AdminHandler) publishPassengerStatus(ctx context.Context, ride *stuckRide, status, reason string) error {
+	driverKey := ride.DriverID
+	if driverKey == "" {
+		driverKey = "unassigned"
+	}
+	msg := map[string]interface{}{
+		"driver_id":    ride.DriverID,
+		"ride_id":      ride.ID,
+		"passenger_id": ride.PassengerID,
+		"old_status":   ride.Status,
+		"new_status":   status,
+		"reason":       reason,
+		"recorded":     true,
+		"timestamp":    time.Now(),
+	}
+	if status == "CANCELLED" {
+		msg["cancelled_by"] = cancelledByAdmin
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return h.rabbit.Publish(ctx, "driver_topic", "driver.status."+driverKey, body)
+}
+
+// publishRideRequest publishes the ride to ride_topic in the ride service's matching format
+func (h *AdminHandler) publishRideRequest(ctx context.Context, ride *stuckRide) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"ride_id":     ride.ID,
+		"ride_number": ride.RideNumber,
+		"pickup_location": map[string]interface{}{
+			"latitude":  ride.PickupLat,
+			"longitude": ride.PickupLng,
+			"address":   ride.PickupAddr,
+		},
+		"destination_location": map[string]interface{}{
+			"latitude":  ride.DestLat,
+			"longitude": ride.DestLng,
+			"address":   ride.DestAddr,
+		},
+		"ride_type":       ride.VehicleType,
+		"estimated_fare":  ride.EstimatedFare,
+		"passenger_count": ride.Passengers,
+		"passenger_id":    ride.PassengerID,
+		"correlation_id":  ride.ID,
+	})
+	if err != nil {
+		return err
+	}
+	return h.rabbit.Publish(ctx, "ride_topic", "ride.request."+ride.VehicleType, body)
+}
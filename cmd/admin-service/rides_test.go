@@ -0,0 +1,331 @@
+package adminservice
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"ride-hail/pkg/auth"
+	"ride-hail/pkg/dbtest"
+)
+
+// fakePublisher records what the handlers publish
+type fakePublisher struct {
+	mu       sync.Mutex
+	messages []publishedMessage
+}
+
+type publishedMessage struct {
+	exchange, routingKey string
+	body                 map[string]interface{}
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, exchange, routingKey string, body []byte) error {
+	var m map[string]interface{}
+	if err := json.Unmarshal(body, &m); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.messages = append(p.messages, publishedMessage{exchange, routingKey, m})
+	return nil
+}
+
+// find returns the message published with routingKey
+func (p *fakePublisher) find(routingKey string) (publishedMessage, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, m := range p.messages {
+		if m.routingKey == routingKey {
+			return m, true
+		}
+	}
+	return publishedMessage{}, false
+}
+
+// rideActionServer routes the admin ride actions the way main does
+func rideActionServer(h *AdminHandler) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("POST /admin/rides/{ride_id}/reassign", adminRoute(h.reassignRide))
+	mux.Handle("POST /admin/rides/{ride_id}/force-complete", adminRoute(h.forceCompleteRide))
+	mux.Handle("POST /admin/rides/{ride_id}/cancel", adminRoute(h.cancelRide))
+	return mux
+}
+
+func postRideAction(t *testing.T, srv http.Handler, rideID, action, body string) (*httptest.ResponseRecorder, RideActionResponse) {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/admin/rides/"+rideID+"/"+action, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+token(t, "33333333-3333-3333-3333-333333333333", auth.RoleAdmin))
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, r)
+
+	var resp RideActionResponse
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+	}
+	return w, resp
+}
+
+// stuckRideIDs are the rows seedStuckRide creates
+type stuckRideIDs struct {
+	ride, passenger, driver string
+}
+
+// seedStuckRide stores an economy ride in status with a driver bound to it
+func seedStuckRide(t *testing.T, pool *pgxpool.Pool, status, driverStatus string) stuckRideIDs {
+	t.Helper()
+	ctx := context.Background()
+	var ids stuckRideIDs
+	must := func(what string, err error) {
+		if err != nil {
+			t.Fatalf("seed %s: %v", what, err)
+		}
+	}
+
+	must("passenger", pool.QueryRow(ctx, `
+		INSERT INTO users (email, role, password_hash) VALUES ('stuck-passenger@test', 'PASSENGER', 'x') RETURNING id
+	`).Scan(&ids.passenger))
+	must("driver user", pool.QueryRow(ctx, `
+		INSERT INTO users (email, role, password_hash) VALUES ('stuck-driver@test', 'DRIVER', 'x') RETURNING id
+	`).Scan(&ids.driver))
+	_, err := pool.Exec(ctx, `
+		INSERT INTO drivers (id, license_number, vehicle_type, status, is_verified)
+		VALUES ($1, 'TEST-STUCK', 'ECONOMY', $2, true)
+	`, ids.driver, driverStatus)
+	must("driver", err)
+
+	var pickup, dest string
+	must("pickup", pool.QueryRow(ctx, `
+		INSERT INTO coordinates (entity_id, entity_type, address, latitude, longitude, is_current)
+		VALUES ($1, 'passenger', 'Abay Ave 10', 43.238949, 76.889709, false) RETURNING id
+	`, ids.passenger).Scan(&pickup))
+	must("destination", pool.QueryRow(ctx, `
+		INSERT INTO coordinates (entity_id, entity_type, address, latitude, longitude, is_current)
+		VALUES ($1, 'passenger', 'Dostyk Ave 5', 43.222015, 76.851511, false) RETURNING id
+	`, ids.passenger).Scan(&dest))
+
+	must("ride", pool.QueryRow(ctx, `
+		INSERT INTO rides (ride_number, passenger_id, driver_id, vehicle_type, status, matched_at,
+			estimated_fare, pickup_coordinate_id, destination_coordinate_id)
+		VALUES ('RIDE_20241216_STUCK', $1, $2, 'ECONOMY', $3, NOW(), 1450, $4, $5) RETURNING id
+	`, ids.passenger, ids.driver, status, pickup, dest).Scan(&ids.ride))
+	_, err = pool.Exec(ctx, `UPDATE drivers SET current_ride_id = $1 WHERE id = $2`, ids.ride, ids.driver)
+	must("current ride", err)
+	return ids
+}
+
+// lastEvent returns the type and data of the ride's newest ride_events row
+func lastEvent(t *testing.T, pool *pgxpool.Pool, rideID string) (string, map[string]interface{}) {
+	t.Helper()
+	var eventType string
+	var data map[string]interface{}
+	err := pool.QueryRow(context.Background(), `
+		SELECT event_type, event_data FROM ride_events WHERE ride_id = $1 ORDER BY created_at DESC LIMIT 1
+	`, rideID).Scan(&eventType, &data)
+	if err != nil {
+		t.Fatalf("load ride event: %v", err)
+	}
+	return eventType, data
+}
+
+func TestReassignSendsStuckRideBackToMatching(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ids := seedStuckRide(t, pool, "EN_ROUTE", "EN_ROUTE")
+	pub := &fakePublisher{}
+	srv := rideActionServer(NewAdminHandler(dbtest.Logger{}, pool, pub))
+
+	w, resp := postRideAction(t, srv, ids.ride, "reassign", `{"reason":"driver unreachable"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if resp.PreviousStatus != "EN_ROUTE" || resp.Status != "REQUESTED" || resp.DriverID != ids.driver {
+		t.Errorf("response = %+v, want EN_ROUTE -> REQUESTED naming the previous driver", resp)
+	}
+
+	ctx := context.Background()
+	var status string
+	var driverID, matchedAt *string
+	if err := pool.QueryRow(ctx, `SELECT status, driver_id::text, matched_at::text FROM rides WHERE id = $1`, ids.ride).Scan(&status, &driverID, &matchedAt); err != nil {
+		t.Fatal(err)
+	}
+	if status != "REQUESTED" || driverID != nil || matchedAt != nil {
+		t.Errorf("ride = %s driver %v matched %v, want REQUESTED with no driver", status, driverID, matchedAt)
+	}
+
+	var driverStatus string
+	var currentRide *string
+	if err := pool.QueryRow(ctx, `SELECT status, current_ride_id::text FROM drivers WHERE id = $1`, ids.driver).Scan(&driverStatus, &currentRide); err != nil {
+		t.Fatal(err)
+	}
+	if driverStatus != "AVAILABLE" || currentRide != nil {
+		t.Errorf("driver = %s on ride %v, want AVAILABLE with no ride", driverStatus, currentRide)
+	}
+
+	eventType, data := lastEvent(t, pool, ids.ride)
+	if eventType != "STATUS_CHANGED" || data["action"] != "admin_reassign" || data["old_status"] != "EN_ROUTE" ||
+		data["new_status"] != "REQUESTED" || data["previous_driver_id"] != ids.driver || data["reason"] != "driver unreachable" {
+		t.Errorf("event = %s %v, want the reassignment audited", eventType, data)
+	}
+
+	request, ok := pub.find("ride.request.ECONOMY")
+	if !ok {
+		t.Fatal("no matching request published")
+	}
+	if request.body["ride_id"] != ids.ride || request.body["passenger_id"] != ids.passenger {
+		t.Errorf("matching request = %v", request.body)
+	}
+	if _, ok := pub.find("ride.status.REQUESTED"); !ok {
+		t.Error("driver service not told the driver was unassigned")
+	}
+	if m, ok := pub.find("driver.status." + ids.driver); !ok || m.body["recorded"] != true {
+		t.Errorf("passenger update = %v, want a recorded status for the ride service", m.body)
+	}
+}
+
+func TestReassignRejectsRideInProgress(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ids := seedStuckRide(t, pool, "IN_PROGRESS", "BUSY")
+	pub := &fakePublisher{}
+	srv := rideActionServer(NewAdminHandler(dbtest.Logger{}, pool, pub))
+
+	if w, _ := postRideAction(t, srv, ids.ride, "reassign", ""); w.Code != http.StatusConflict {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusConflict)
+	}
+	if len(pub.messages) != 0 {
+		t.Errorf("published %d messages, want none", len(pub.messages))
+	}
+}
+
+func TestForceCompleteRecordsManualFare(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ids := seedStuckRide(t, pool, "IN_PROGRESS", "BUSY")
+	pub := &fakePublisher{}
+	srv := rideActionServer(NewAdminHandler(dbtest.Logger{}, pool, pub))
+
+	w, resp := postRideAction(t, srv, ids.ride, "force-complete", `{"final_fare":2500,"reason":"app crashed mid-trip"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if resp.Status != "COMPLETED" || resp.FinalFare != 2500 {
+		t.Errorf("response = %+v, want COMPLETED at 2500", resp)
+	}
+
+	ctx := context.Background()
+	var status string
+	var finalFare float64
+	if err := pool.QueryRow(ctx, `SELECT status, final_fare FROM rides WHERE id = $1`, ids.ride).Scan(&status, &finalFare); err != nil {
+		t.Fatal(err)
+	}
+	if status != "COMPLETED" || finalFare != 2500 {
+		t.Errorf("ride = %s at %v, want COMPLETED at 2500", status, finalFare)
+	}
+
+	eventType, data := lastEvent(t, pool, ids.ride)
+	if eventType != "RIDE_COMPLETED" || data["action"] != "admin_force_complete" || data["final_fare"] != 2500.0 ||
+		data["estimated_fare"] != 1450.0 || data["manual_fare"] != true || data["reason"] != "app crashed mid-trip" {
+		t.Errorf("event = %s %v, want a completion with the manual fare", eventType, data)
+	}
+
+	if m, ok := pub.find("ride.status.COMPLETED"); !ok || m.body["final_fare"] != 2500.0 {
+		t.Errorf("driver service update = %v, want the final fare", m.body)
+	}
+}
+
+func TestForceCompleteRejectsFinishedRide(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ids := seedStuckRide(t, pool, "COMPLETED", "AVAILABLE")
+	srv := rideActionServer(NewAdminHandler(dbtest.Logger{}, pool, &fakePublisher{}))
+
+	if w, _ := postRideAction(t, srv, ids.ride, "force-complete", `{"final_fare":2500}`); w.Code != http.StatusConflict {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusConflict)
+	}
+}
+
+func TestForceCompleteRejectsNegativeFare(t *testing.T) {
+	srv := rideActionServer(NewAdminHandler(dbtest.Logger{}, nil, &fakePublisher{}))
+
+	w, _ := postRideAction(t, srv, "6f1c2c1e-0000-4000-8000-000000000001", "force-complete", `{"final_fare":-1}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestAdminCancelRecordsAdminAsParty(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ids := seedStuckRide(t, pool, "EN_ROUTE", "EN_ROUTE")
+	pub := &fakePublisher{}
+	srv := rideActionServer(NewAdminHandler(dbtest.Logger{}, pool, pub))
+
+	w, resp := postRideAction(t, srv, ids.ride, "cancel", `{"reason":"Duplicate booking"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if resp.PreviousStatus != "EN_ROUTE" || resp.Status != "CANCELLED" {
+		t.Errorf("response = %+v, want EN_ROUTE -> CANCELLED", resp)
+	}
+
+	ctx := context.Background()
+	var status, reason, cancelledBy string
+	var cancelledAt *string
+	err := pool.QueryRow(ctx, `
+		SELECT status, cancellation_reason, cancelled_by, cancelled_at::text FROM rides WHERE id = $1
+	`, ids.ride).Scan(&status, &reason, &cancelledBy, &cancelledAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != "CANCELLED" || reason != "Duplicate booking" || cancelledBy != cancelledByAdmin || cancelledAt == nil {
+		t.Errorf("ride = %s %q by %s at %v, want CANCELLED by ADMIN with the reason", status, reason, cancelledBy, cancelledAt)
+	}
+
+	var driverStatus string
+	if err := pool.QueryRow(ctx, `SELECT status FROM drivers WHERE id = $1`, ids.driver).Scan(&driverStatus); err != nil {
+		t.Fatal(err)
+	}
+	if driverStatus != "AVAILABLE" {
+		t.Errorf("driver = %s, want AVAILABLE", driverStatus)
+	}
+
+	eventType, data := lastEvent(t, pool, ids.ride)
+	if eventType != "RIDE_CANCELLED" || data["action"] != "admin_cancel" || data["cancelled_by"] != cancelledByAdmin ||
+		data["reason"] != "Duplicate booking" || data["old_status"] != "EN_ROUTE" {
+		t.Errorf("event = %s %v, want the admin cancellation audited", eventType, data)
+	}
+
+	if _, ok := pub.find("ride.status.CANCELLED"); !ok {
+		t.Error("driver service not told the ride was cancelled")
+	}
+	if m, ok := pub.find("driver.status." + ids.driver); !ok || m.body["recorded"] != true || m.body["cancelled_by"] != cancelledByAdmin {
+		t.Errorf("passenger update = %v, want a recorded cancellation by ADMIN", m.body)
+	}
+}
+
+func TestAdminCancelRejectsFinishedRide(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ids := seedStuckRide(t, pool, "COMPLETED", "AVAILABLE")
+	pub := &fakePublisher{}
+	srv := rideActionServer(NewAdminHandler(dbtest.Logger{}, pool, pub))
+
+	if w, _ := postRideAction(t, srv, ids.ride, "cancel", `{"reason":"Duplicate booking"}`); w.Code != http.StatusConflict {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusConflict)
+	}
+	if len(pub.messages) != 0 {
+		t.Errorf("published %d messages, want none", len(pub.messages))
+	}
+}
+
+func TestAdminCancelRequiresReason(t *testing.T) {
+	srv := rideActionServer(NewAdminHandler(dbtest.Logger{}, nil, &fakePublisher{}))
+
+	w, _ := postRideAction(t, srv, "6f1c2c1e-0000-4000-8000-000000000001", "cancel", `{"reason":"  "}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
@@ -25,6 +25,9 @@ func writeError(w http.ResponseWriter, code int, msg string) {
 func parsePagination(r *http.Request) (page int, pageSize int) {
 	pageStr := r.URL.Query().Get("page")
 	pageSizeStr := r.URL.Query().Get("pageSize")
+	if pageSizeStr == "" {
+		pageSizeStr = r.URL.Query().Get("page_size")
+	}
 	var err error
 	page, err = strconv.Atoi(pageStr)
 	if err != nil || page < 1 {
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -15,10 +16,14 @@ import (
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"ride-hail/pkg/apitime"
 	"ride-hail/pkg/auth"
+	"ride-hail/pkg/buildinfo"
 	"ride-hail/pkg/config"
 	"ride-hail/pkg/db"
 	"ride-hail/pkg/logger"
+	"ride-hail/pkg/middleware"
+	"ride-hail/pkg/validation"
 )
 
 // --- Structs for API responses ---
@@ -70,6 +75,30 @@ func writeError(w http.ResponseWriter, status int, message string) {
 	})
 }
 
+// defaultMaxBodyBytes caps auth request bodies unless AUTH_MAX_BODY_BYTES is set.
+const defaultMaxBodyBytes = 64 << 10
+
+// decodeJSON strictly decodes a size-limited request body into v.
+func (h *Handler) decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxBodyBytes)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid json payload: %w", err)
+	}
+	return nil
+}
+
+// writeDecodeError maps a decodeJSON error to 413 for oversized bodies and 400 otherwise.
+func writeDecodeError(w http.ResponseWriter, err error) {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body exceeds %d bytes", maxErr.Limit))
+		return
+	}
+	writeError(w, http.StatusBadRequest, validation.DecodeErrorMessage(err))
+}
+
 func main() {
 	log := logger.NewLogger("auth-service")
 	log.Info("startup", "Starting auth-service")
@@ -79,6 +108,10 @@ func main() {
 		log.Error("startup", fmt.Errorf("failed to load config: %w", err))
 		os.Exit(1)
 	}
+	if err := cfg.Validate(); err != nil {
+		log.Error("startup", err)
+		os.Exit(1)
+	}
 
 	pool, err := db.NewConnection(cfg, log)
 	if err != nil {
@@ -88,35 +121,31 @@ func main() {
 	defer pool.Close()
 
 	// Initialize JWTManager
-	sKey := os.Getenv("JWT_SECRET_KEY")
-	if sKey == "" {
-		log.Error("startup", fmt.Errorf("JWT_SECRET_KEY environment variable not set"))
-		sKey = "someone"
-	}
-	jwtManager := auth.NewJWTManager(sKey, 1*time.Hour)
+	jwtManager := auth.NewJWTManager(cfg.Auth.JWTSecret, 1*time.Hour)
 
 	// Setup HTTP Server and Handlers
 	mux := http.NewServeMux()
 	authHandler := NewHandler(pool, log, jwtManager)
+	if v := os.Getenv("AUTH_MAX_BODY_BYTES"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			log.Error("startup", fmt.Errorf("invalid AUTH_MAX_BODY_BYTES %q, using default %d", v, defaultMaxBodyBytes))
+		} else {
+			authHandler.maxBodyBytes = n
+		}
+	}
 
 	mux.HandleFunc("POST /register", authHandler.SignUp)
 	mux.HandleFunc("POST /login", authHandler.Login)
+	mux.HandleFunc("GET /version", buildinfo.Handler("auth-service"))
 
 	// Configure and Start Server
-	// We use a different port, e.g., 3005, or get it from config
-	authPort := os.Getenv("AUTH_SERVICE_PORT")
-
-	server := &http.Server{
-		Addr:         fmt.Sprintf(":%s", authPort),
-		Handler:      mux,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  120 * time.Second,
-	}
+	handler := middleware.Chain(mux, middleware.Recovery(log), middleware.Logging(log), middleware.CORS())
+	server := cfg.HTTPServer.NewServer(fmt.Sprintf(":%d", cfg.Services.AuthService), handler)
 
 	serverErrors := make(chan error, 1)
 	go func() {
-		log.Info("startup", fmt.Sprintf("Auth service listening on port %s", authPort))
+		log.Info("startup", fmt.Sprintf("Auth service listening on port %d", cfg.Services.AuthService))
 		serverErrors <- server.ListenAndServe()
 	}()
 
@@ -134,7 +163,7 @@ func main() {
 	}
 
 	// Shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTPServer.ShutdownTimeout)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
@@ -150,6 +179,8 @@ type Handler struct {
 	log     logger.Logger
 	jwtMng  *auth.JWTManager
 	testEnv bool // Flag to bypass password hashing in test
+
+	maxBodyBytes int64 // Request body size cap
 }
 
 // NewHandler creates a new Handler.
@@ -158,6 +189,8 @@ func NewHandler(pool *pgxpool.Pool, log logger.Logger, jwtMng *auth.JWTManager)
 		pool:   pool,
 		log:    log,
 		jwtMng: jwtMng,
+
+		maxBodyBytes: defaultMaxBodyBytes,
 	}
 }
 
@@ -167,9 +200,9 @@ func (h *Handler) SignUp(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	var req RegisterRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := h.decodeJSON(w, r, &req); err != nil {
 		h.log.Error("signup_decode_error", err)
-		writeError(w, http.StatusBadRequest, "Invalid request format")
+		writeDecodeError(w, err)
 		return
 	}
 
@@ -188,7 +221,7 @@ func (h *Handler) SignUp(w http.ResponseWriter, r *http.Request) {
 		role = auth.RoleDriver
 	case "ADMIN":
 		// Do not allow admin signups via API
-		h.log.Error("signup_admin_attempt", fmt.Errorf("attempt to register admin: %s", req.Email))
+		h.log.Error("signup_admin_attempt", fmt.Errorf("attempt to register admin: %s", logger.MaskEmail(req.Email)))
 		writeError(w, http.StatusForbidden, "Admin registration is not allowed")
 		return
 	default:
@@ -257,7 +290,7 @@ func (h *Handler) SignUp(w http.ResponseWriter, r *http.Request) {
 	// 8. Send successful response
 	writeJSON(w, http.StatusCreated, TokenResponse{
 		Token:     token,
-		ExpiresAt: time.Now().Add(24 * time.Hour).Format(time.RFC3339),
+		ExpiresAt: apitime.Format(time.Now().Add(24 * time.Hour)),
 		UserID:    userID,
 		Role:      string(role),
 	})
@@ -269,9 +302,9 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	var req LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := h.decodeJSON(w, r, &req); err != nil {
 		h.log.Error("login_decode_error", err)
-		writeError(w, http.StatusBadRequest, "Invalid request format")
+		writeDecodeError(w, err)
 		return
 	}
 
@@ -280,7 +313,8 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log := h.log.WithFields(logger.LogFields{"email": req.Email})
+	// Only a masked email is logged, so failed logins don't build a list of addresses
+	log := h.log.WithFields(logger.LogFields{"email": logger.MaskEmail(req.Email)})
 
 	// 1. Find user by email
 	var userID, storedPassword, userRole string // storedPassword is plain text
@@ -319,7 +353,7 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	log.Info("login_success", "User authenticated successfully")
 	writeJSON(w, http.StatusOK, TokenResponse{
 		Token:     token,
-		ExpiresAt: time.Now().Add(24 * time.Hour).Format(time.RFC3339),
+		ExpiresAt: apitime.Format(time.Now().Add(24 * time.Hour)),
 		UserID:    userID,
 		Role:      string(role),
 	})
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ride-hail/pkg/auth"
+	"ride-hail/pkg/dbtest"
+	"ride-hail/pkg/logger"
+)
+
+// newTestHandler returns a handler with no database; the requests here are
+// rejected before any query runs
+func newTestHandler() *Handler {
+	return NewHandler(nil, dbtest.Logger{}, auth.NewJWTManager("test-secret", time.Hour))
+}
+
+// post sends body to handler and returns the status and error message
+func post(t *testing.T, handler http.HandlerFunc, body string) (int, string) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+
+	var resp struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response %q: %v", w.Body, err)
+	}
+	if resp.Error != http.StatusText(w.Code) {
+		t.Errorf("error = %q, want %q", resp.Error, http.StatusText(w.Code))
+	}
+	return w.Code, resp.Message
+}
+
+func TestAuthRejectsOversizedBody(t *testing.T) {
+	h := newTestHandler()
+	// Valid JSON, padded past the limit
+	body := `{"email":"a@example.com","password":"` + strings.Repeat("x", defaultMaxBodyBytes) + `"}`
+
+	for name, handler := range map[string]http.HandlerFunc{"signup": h.SignUp, "login": h.Login} {
+		t.Run(name, func(t *testing.T) {
+			status, message := post(t, handler, body)
+			if status != http.StatusRequestEntityTooLarge {
+				t.Errorf("status = %d, want %d", status, http.StatusRequestEntityTooLarge)
+			}
+			if !strings.Contains(message, "65536 bytes") {
+				t.Errorf("message = %q, want it to name the limit", message)
+			}
+		})
+	}
+}
+
+func TestAuthBodyLimitIsConfigurable(t *testing.T) {
+	h := newTestHandler()
+	h.maxBodyBytes = 64
+
+	// An unknown role is only reported once the body has been decoded
+	under := `{"email":"a@example.com","password":"pw","role":"X"}`
+	if status, message := post(t, h.SignUp, under); status != http.StatusBadRequest || !strings.Contains(message, "Invalid role") {
+		t.Errorf("%d-byte body: %d %q, want it decoded and the role rejected", len(under), status, message)
+	}
+
+	over := `{"email":"a@example.com","password":"a-longer-password","role":"X"}`
+	if status, _ := post(t, h.SignUp, over); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("%d-byte body: status = %d, want %d", len(over), status, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestAuthRejectsUnknownFields(t *testing.T) {
+	h := newTestHandler()
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		body    string
+		field   string
+	}{
+		{"signup", h.SignUp, `{"email":"a@example.com","password":"pw","role":"PASSENGER","is_admin":true}`, `"is_admin"`},
+		{"login", h.Login, `{"email":"a@example.com","password":"pw","remember_me":true}`, `"remember_me"`},
+		{"login with signup fields", h.Login, `{"email":"a@example.com","password":"pw","role":"ADMIN"}`, `"role"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, message := post(t, tt.handler, tt.body)
+			if status != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", status, http.StatusBadRequest)
+			}
+			if message != "Unknown field "+tt.field {
+				t.Errorf("message = %q, want it to name %s", message, tt.field)
+			}
+		})
+	}
+}
+
+func TestAuthExplainsUndecodableBodies(t *testing.T) {
+	h := newTestHandler()
+	tests := []struct {
+		name, body, want string
+	}{
+		{"empty", ``, "Request body is empty"},
+		{"malformed", `{"email":"a@example.com",}`, "Request body is malformed JSON at byte 26: invalid character '}' looking for beginning of object key string"},
+		{"wrong type", `{"email":"a@example.com","password":12345}`, `Field "password" must be a string, got number`},
+	}
+	for name, handler := range map[string]http.HandlerFunc{"signup": h.SignUp, "login": h.Login} {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				status, message := post(t, handler, tt.body)
+				if status != http.StatusBadRequest || message != tt.want {
+					t.Errorf("got %d %q, want 400 %q", status, message, tt.want)
+				}
+			})
+		}
+	}
+}
+
+// loggedEntry is one call to a fieldsLogger
+type loggedEntry struct {
+	action, message string
+	fields          logger.LogFields
+}
+
+// fieldsLogger records what the handler logs, with the fields it was given
+type fieldsLogger struct {
+	entries *[]loggedEntry
+	fields  logger.LogFields
+}
+
+func (l fieldsLogger) WithFields(fields logger.LogFields) logger.Logger {
+	merged := logger.LogFields{}
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return fieldsLogger{entries: l.entries, fields: merged}
+}
+func (l fieldsLogger) record(action, message string) {
+	*l.entries = append(*l.entries, loggedEntry{action, message, l.fields})
+}
+func (l fieldsLogger) Info(action, message string)    { l.record(action, message) }
+func (l fieldsLogger) Debug(action, message string)   { l.record(action, message) }
+func (l fieldsLogger) Warn(action, message string)    { l.record(action, message) }
+func (l fieldsLogger) Error(action string, err error) { l.record(action, err.Error()) }
+
+// assertNoCredentials fails if any entry mentions the raw email or password
+func assertNoCredentials(t *testing.T, entries []loggedEntry, email, password string) {
+	t.Helper()
+	for _, e := range entries {
+		line := fmt.Sprintf("%s %s %v", e.action, e.message, e.fields)
+		if strings.Contains(line, email) || strings.Contains(line, password) {
+			t.Errorf("logged %q, want no raw credentials", line)
+		}
+	}
+}
+
+func TestAdminSignupAttemptLogsMaskedEmail(t *testing.T) {
+	var entries []loggedEntry
+	h := NewHandler(nil, fieldsLogger{entries: &entries}, auth.NewJWTManager("test-secret", time.Hour))
+
+	status, _ := post(t, h.SignUp, `{"email":"jane.doe@example.com","password":"hunter2","role":"ADMIN"}`)
+	if status != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", status)
+	}
+	if len(entries) != 1 || !strings.Contains(entries[0].message, "j***@example.com") {
+		t.Errorf("logged %v, want the masked email", entries)
+	}
+	assertNoCredentials(t, entries, "jane.doe@example.com", "hunter2")
+}
+
+func TestFailedLoginLogsMaskedEmail(t *testing.T) {
+	var entries []loggedEntry
+	h := NewHandler(dbtest.Pool(t), fieldsLogger{entries: &entries}, auth.NewJWTManager("test-secret", time.Hour))
+
+	status, _ := post(t, h.Login, `{"email":"nobody.here@example.com","password":"hunter2"}`)
+	if status != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401", status)
+	}
+	if len(entries) == 0 || entries[0].fields["email"] != "n***@example.com" {
+		t.Errorf("logged %v, want the masked email", entries)
+	}
+	assertNoCredentials(t, entries, "nobody.here@example.com", "hunter2")
+}
@@ -14,10 +14,16 @@ import (
 	"ride-hail/internal/driver_location_service/adapter/rest"
 	wsadapter "ride-hail/internal/driver_location_service/adapter/websocket"
 	"ride-hail/internal/driver_location_service/app"
+	"ride-hail/internal/driver_location_service/domain"
 	"ride-hail/pkg/auth"
 	"ride-hail/pkg/config"
+	"ride-hail/pkg/geocode"
 	"ride-hail/pkg/logger"
 	pkgRabbit "ride-hail/pkg/rabbitmq"
+	"ride-hail/pkg/ratelimit"
+	pkgWS "ride-hail/pkg/websocket"
+
+	"github.com/redis/go-redis/v9"
 )
 
 func main() {
@@ -32,6 +38,10 @@ func main() {
 		log.Error("config_load_failed", err)
 		os.Exit(1)
 	}
+	if err := cfg.Validate(); err != nil {
+		log.Error("config_invalid", err)
+		os.Exit(1)
+	}
 
 	repo, err := db.NewPostgresDriverLocationRepository(log, cfg)
 	if err != nil {
@@ -49,21 +59,62 @@ func main() {
 
 	publisher := internalRabbit.NewDriverLocationPublisher(rabbitConn)
 
-	sKey := os.Getenv("JWT_SECRET_KEY")
-	if sKey == "" {
-		log.Error("startup", fmt.Errorf("JWT_SECRET_KEY environment variable not set"))
-		sKey = "someone"
-	}
-	jwtMgr := auth.NewJWTManager(sKey, 1*time.Hour)
+	jwtMgr := auth.NewJWTManager(cfg.Auth.JWTSecret, 1*time.Hour)
 
+	pkgWS.SetAllowedOrigins(cfg.Websocket.AllowedOrigins, cfg.IsDevelopment())
+	if err := pkgWS.SetCompression(cfg.Websocket.Compression, cfg.Websocket.CompressionLevel); err != nil {
+		log.Error("config_invalid", err)
+		os.Exit(1)
+	}
 	wsAdapter := wsadapter.NewDriverWSAdapter(log, jwtMgr)
 
 	// 2. Initialize the Service injecting the adapter
 	service := app.NewDriverLocationService(log, repo, publisher, wsAdapter)
+	service.SetHeadingRerank(cfg.Matching.HeadingRerank)
+	service.SetMaxLocationAge(time.Duration(cfg.Matching.MaxLocationAgeS) * time.Second)
+	service.SetStaleLocationAge(time.Duration(cfg.Matching.StaleLocationAgeS) * time.Second)
+	service.SetOfferAckTimeout(time.Duration(cfg.Matching.OfferAckTimeoutMs) * time.Millisecond)
+	service.SetMaxOffersPerRide(cfg.Matching.MaxOffersPerRide)
+	service.SetSearchRadius(cfg.Matching.DefaultSearchRadiusKm, cfg.Matching.MaxSearchRadiusKm)
+	service.SetRadiusExpansion(cfg.Matching.RadiusStepKm, cfg.Matching.MaxRadiusKm, time.Duration(cfg.Matching.ExpansionDelayS)*time.Second)
+	service.SetMinRating(domain.VehicleTypePremium, cfg.Matching.MinRatingPremium)
+	service.SetMinRating(domain.VehicleTypeLuxury, cfg.Matching.MinRatingLuxury)
+	service.SetMinOnlineRating(cfg.Drivers.MinOnlineRating)
+	service.SetPublishEpsilon(cfg.Location.PublishEpsilonMeters)
+	service.SetNoShowPolicy(time.Duration(cfg.NoShow.WaitS)*time.Second, cfg.NoShow.Fee)
+	service.SetGeocoder(geocode.NewCache(geocode.Noop{})) // swap Noop for a real provider
+	if cfg.RateLimit.Backend == config.RateLimitRedis {
+		redisClient := redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Addr,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+		})
+		defer redisClient.Close()
+		if err := redisClient.Ping(ctx).Err(); err != nil {
+			log.Error("redis_init_failed", err)
+			os.Exit(1)
+		}
+		service.SetLocationRateLimiter(ratelimit.NewRedisRateLimiter(redisClient, "ratelimit:location:"))
+	}
+	if err := service.SetDriverSharePercent(cfg.Pricing.DriverSharePercent); err != nil {
+		log.Error("startup", fmt.Errorf("invalid PRICING_DRIVER_SHARE_PERCENT, using default: %w", err))
+	}
+	if fareTable, err := cfg.FareTable(); err != nil {
+		log.Error("startup", fmt.Errorf("invalid pricing, completed rides pay the estimated fare: %w", err))
+	} else {
+		service.SetFareTable(fareTable)
+	}
 
 	// 3. Register WebSocket Message Handlers
 	// This connects incoming WS messages to the Service logic
 	wsAdapter.SetService(service)
+	wsAdapter.StartIdleSweeper(ctx)
+	if restored, err := service.RestorePendingOffers(ctx); err != nil {
+		log.Error("restore_offers_failed", err)
+	} else if restored > 0 {
+		log.Info("offers_restored", fmt.Sprintf("Restored %d pending ride offers", restored))
+	}
+	service.StartOfferSweeper(ctx)
 
 	consumer := internalRabbit.NewDriverLocationConsumer(rabbitConn, service, log)
 	if err := consumer.ConsumeDriverMatching(ctx); err != nil {
@@ -74,8 +125,21 @@ func main() {
 		log.Error("consumer_ride_status_failed", err)
 		os.Exit(1)
 	}
+	if err := consumer.ConsumeRideMessages(ctx); err != nil {
+		log.Error("consumer_ride_messages_failed", err)
+		os.Exit(1)
+	}
+	if err := consumer.ConsumeRideDestinations(ctx); err != nil {
+		log.Error("consumer_ride_destinations_failed", err)
+		os.Exit(1)
+	}
+	if err := consumer.ConsumeDriverControl(ctx); err != nil {
+		log.Error("consumer_driver_control_failed", err)
+		os.Exit(1)
+	}
 
 	handler := rest.NewHandler(service, jwtMgr, log)
+	handler.SetServiceToken(cfg.Auth.ServiceToken)
 
 	// register function will mount REST routes and websocket route
 	register := func(mux *http.ServeMux) {
@@ -83,13 +147,16 @@ func main() {
 
 		// WebSocket route for drivers
 		// Note the trailing slash: This enables matching /ws/drivers/{driverID}
-		mux.HandleFunc("/ws/drivers/", wsAdapter.ServeHTTP)
+		mux.HandleFunc("GET /ws/drivers/", wsAdapter.ServeHTTP)
+		mux.HandleFunc("GET /metrics/websocket", wsAdapter.MetricsHandler)
 	}
 
 	server := rest.New(
 		fmt.Sprintf(":%d", cfg.Services.DriverLocationService),
+		cfg.HTTPServer,
 		log,
 		register,
+		rabbitConn.CheckTopology,
 	)
 
 	serverErr := make(chan error, 1)
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"ride-hail/pkg/config"
+	"ride-hail/pkg/db"
+	"ride-hail/pkg/logger"
+	"ride-hail/pkg/migrate"
+)
+
+func main() {
+	dir := flag.String("dir", "migrations", "directory containing ordered *.sql migration files")
+	flag.Parse()
+
+	log := logger.NewLogger("migrate")
+	log.Info("startup", "Starting migrations")
+
+	cfg, err := config.LoadConfig(".env")
+	if err != nil {
+		log.Error("startup", fmt.Errorf("failed to load config: %w", err))
+		os.Exit(1)
+	}
+	if err := cfg.ValidateDB(); err != nil {
+		log.Error("startup", err)
+		os.Exit(1)
+	}
+
+	pool, err := db.NewConnection(cfg, log)
+	if err != nil {
+		log.Error("startup", fmt.Errorf("failed to connect to database: %w", err))
+		os.Exit(1)
+	}
+	defer pool.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	applied, err := migrate.New(pool, *dir, log).Up(ctx)
+	if err != nil {
+		log.Error("migrate_failed", err)
+		os.Exit(1)
+	}
+
+	if len(applied) == 0 {
+		log.Info("migrate_done", "Database is up to date")
+		return
+	}
+	log.Info("migrate_done", fmt.Sprintf("Applied %d migration(s): %s", len(applied), strings.Join(applied, ", ")))
+}
@@ -15,15 +15,22 @@ import (
 	ridehttp "ride-hail/internal/ride-service/handlers"
 	"ride-hail/internal/ride-service/infrastructure/messaging"
 	"ride-hail/internal/ride-service/infrastructure/repository"
+	"ride-hail/internal/ride-service/infrastructure/stream"
 
 	// Legacy imports (still needed for consumers, users, websocket)
 	"ride-hail/internal/ride-service/infrastructure/consumer"
 	"ride-hail/pkg/auth"
+	"ride-hail/pkg/buildinfo"
 	"ride-hail/pkg/config"
 	"ride-hail/pkg/db"
+	"ride-hail/pkg/geocode"
 	"ride-hail/pkg/logger"
+	"ride-hail/pkg/middleware"
 	"ride-hail/pkg/rabbitmq"
+	"ride-hail/pkg/ratelimit"
 	"ride-hail/pkg/websocket"
+
+	"github.com/redis/go-redis/v9"
 )
 
 func main() {
@@ -37,6 +44,10 @@ func main() {
 	log := logger.NewLogger("ride-service")
 	log.Info("service_starting", "Ride Service starting on port 3000")
 
+	if err := cfg.Validate(); err != nil {
+		log.Error("config_invalid", err)
+		os.Exit(1)
+	}
 	log.Info("config_loaded", "Configuration loaded successfully: "+cfg.TestVariable)
 	// Connect to database
 	dbConn, err := db.NewConnection(cfg, log)
@@ -44,7 +55,6 @@ func main() {
 		log.Error("db_connect_failed", err)
 		os.Exit(1)
 	}
-	defer dbConn.Close()
 
 	// Connect to RabbitMQ
 	rabbit, err := rabbitmq.NewConnection(cfg, log)
@@ -52,19 +62,20 @@ func main() {
 		log.Error("rabbitmq_connect_failed", err)
 		os.Exit(1)
 	}
-	defer rabbit.Close()
 
 	// Initialize JWT manager
-	sKey := os.Getenv("JWT_SECRET_KEY")
-
-	if sKey == "" {
-		log.Error("startup", fmt.Errorf("JWT_SECRET_KEY environment variable not set"))
-		sKey = "someone"
-	}
-	jwtManager := auth.NewJWTManager(sKey, 1*time.Hour)
+	jwtManager := auth.NewJWTManager(cfg.Auth.JWTSecret, 1*time.Hour)
 	// Initialize WebSocket manager
+	websocket.SetAllowedOrigins(cfg.Websocket.AllowedOrigins, cfg.IsDevelopment())
+	if err := websocket.SetCompression(cfg.Websocket.Compression, cfg.Websocket.CompressionLevel); err != nil {
+		log.Error("config_invalid", err)
+		os.Exit(1)
+	}
 	wsManager := websocket.NewManager(log)
 
+	// Initialize SSE hub (WebSocket fallback for ride updates)
+	rideStreams := stream.NewHub(log)
+
 	// Initialize old handler (still needed for users, websocket, and token generation)
 	h := ridehttp.New(dbConn, rabbit, log)
 
@@ -74,10 +85,16 @@ func main() {
 
 	// 1. Create Infrastructure (Adapters)
 	rideRepo := repository.NewPostgresRideRepository(dbConn)
+	rideRepo.SetQueryTimeout(cfg.DB.QueryTimeout)
 	eventPublisher := messaging.NewRabbitMQEventPublisher(rabbit, log)
 
 	// 2. Create Domain Services
-	fareCalculator := domain.NewFareCalculator()
+	fareTable, err := cfg.FareTable()
+	if err != nil {
+		log.Error("startup", fmt.Errorf("invalid pricing: %w", err))
+		os.Exit(1)
+	}
+	fareCalculator := domain.NewFareCalculatorWithTable(fareTable)
 
 	// 3. Create Application Use Cases
 	createRideUseCase := application.NewCreateRideUseCase(
@@ -86,11 +103,55 @@ func main() {
 		fareCalculator,
 		log,
 	)
+	createRideUseCase.SetGeocoder(geocode.NewCache(geocode.Noop{})) // swap Noop for a real provider
+	createRideUseCase.SetServiceArea(domain.ServiceArea{
+		MinLat: cfg.ServiceArea.MinLat,
+		MinLng: cfg.ServiceArea.MinLng,
+		MaxLat: cfg.ServiceArea.MaxLat,
+		MaxLng: cfg.ServiceArea.MaxLng,
+	})
+	createRideUseCase.SetMinDistance(cfg.ServiceArea.MinRideDistanceKm)
+	createRideUseCase.SetMaxDistance(cfg.ServiceArea.MaxRideDistanceKm)
+	var rideRequestLimiter ratelimit.RateLimiter = ratelimit.NewMemoryRateLimiter()
+	if cfg.RateLimit.Backend == config.RateLimitRedis {
+		redisClient := redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Addr,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+		})
+		defer redisClient.Close()
+		if err := redisClient.Ping(context.Background()).Err(); err != nil {
+			log.Error("redis_init_failed", err)
+			os.Exit(1)
+		}
+		rideRequestLimiter = ratelimit.NewRedisRateLimiter(redisClient, "ratelimit:rides:")
+	}
+	createRideUseCase.SetRequestLimit(rideRequestLimiter, cfg.RateLimit.RideRequests, cfg.RateLimit.RideRequestWindow)
 	cancelRideUseCase := application.NewCancelRideUseCase(
 		rideRepo,
 		eventPublisher,
 		log,
 	)
+	cancelRideUseCase.SetRideStreams(rideStreams)
+	changeDestinationUseCase := application.NewChangeDestinationUseCase(
+		rideRepo,
+		eventPublisher,
+		fareCalculator,
+		log,
+	)
+	changeDestinationUseCase.SetGeocoder(geocode.NewCache(geocode.Noop{}))
+	changeDestinationUseCase.SetServiceArea(domain.ServiceArea{
+		MinLat: cfg.ServiceArea.MinLat,
+		MinLng: cfg.ServiceArea.MinLng,
+		MaxLat: cfg.ServiceArea.MaxLat,
+		MaxLng: cfg.ServiceArea.MaxLng,
+	})
+	sendMessageUseCase := application.NewSendMessageUseCase(
+		rideRepo,
+		eventPublisher,
+		wsManager,
+		log,
+	)
 
 	// 4. Create HTTP Handlers (Clean Architecture)
 	rideHandler := ridehttp.NewRideHandler(
@@ -98,6 +159,11 @@ func main() {
 		cancelRideUseCase,
 		log,
 	)
+	streamHandler := ridehttp.NewStreamHandler(rideRepo, rideStreams, log)
+	routeHandler := ridehttp.NewRouteHandler(rideRepo, log)
+	messageHandler := ridehttp.NewMessageHandler(sendMessageUseCase, log)
+	destinationHandler := ridehttp.NewDestinationHandler(changeDestinationUseCase, log)
+	activeRidesHandler := ridehttp.NewActiveRidesHandler(rideRepo, log)
 
 	log.Info("clean_architecture_initialized", "Clean Architecture components initialized")
 
@@ -106,35 +172,28 @@ func main() {
 	// ========================================
 
 	// Initialize and start message consumers (using new repository)
-	messageConsumer := consumer.New(rabbit, log, wsManager, rideRepo)
-	ctx := context.Background()
+	messageConsumer := consumer.New(rabbit, log, wsManager, rideRepo, rideStreams)
+	messageConsumer.SetArrivingRadius(cfg.Notifications.ArrivingRadiusMeters)
+	messageConsumer.SetAssignmentTimeout(time.Duration(cfg.Matching.AssignmentTimeoutS) * time.Second)
+	messageConsumer.SetMatchingDeadline(time.Duration(cfg.Matching.DeadlineS) * time.Second)
+	messageConsumer.SetRideExpiry(time.Duration(cfg.Matching.RideExpiryS) * time.Second)
+	ctx, stopBackground := context.WithCancel(context.Background())
 	if err := messageConsumer.StartConsuming(ctx); err != nil {
 		log.Error("consumer_start_failed", err)
 		os.Exit(1)
 	}
+	wsManager.StartIdleSweeper(ctx, nil)
 
 	// Setup routes
 	mux := http.NewServeMux()
 
-	// CORS middleware function
-	corsHandler := func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Allow all origins for development (restrict in production)
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-
-			// Handle preflight requests
-			if r.Method == "OPTIONS" {
-				w.WriteHeader(http.StatusOK)
-				return
-			}
-
-			next.ServeHTTP(w, r)
-		})
-	}
+	// Any origin may call the API; requests carry a bearer token, not cookies
+	corsHandler := middleware.CORS()
+	requireAuth := middleware.Auth(jwtManager)
 
 	mux.Handle("/health", corsHandler(http.HandlerFunc(h.Health)))
+	mux.Handle("GET /version", corsHandler(buildinfo.Handler("ride-service")))
+	mux.HandleFunc("GET /metrics/websocket", wsManager.MetricsHandler)
 
 	// Public endpoints - User Management
 	// mux.Handle("POST /users", corsHandler(http.HandlerFunc(h.CreateUser)))             // Register new user
@@ -144,8 +203,13 @@ func main() {
 
 	// Protected endpoints - require JWT authentication
 	// Using Clean Architecture handlers for rides
-	mux.Handle("POST /rides", corsHandler(jwtManager.AuthMiddleware(http.HandlerFunc(rideHandler.CreateRide))))
-	mux.Handle("POST /rides/{ride_id}/cancel", corsHandler(jwtManager.AuthMiddleware(http.HandlerFunc(rideHandler.CancelRide))))
+	mux.Handle("POST /rides", corsHandler(requireAuth(http.HandlerFunc(rideHandler.CreateRide))))
+	mux.Handle("GET /rides/active", corsHandler(requireAuth(http.HandlerFunc(activeRidesHandler.ListActiveRides))))
+	mux.Handle("POST /rides/{ride_id}/cancel", corsHandler(requireAuth(http.HandlerFunc(rideHandler.CancelRide))))
+	mux.Handle("GET /rides/{ride_id}/stream", corsHandler(requireAuth(http.HandlerFunc(streamHandler.StreamRide))))
+	mux.Handle("GET /rides/{ride_id}/route.geojson", corsHandler(requireAuth(http.HandlerFunc(routeHandler.ExportRoute))))
+	mux.Handle("POST /rides/{ride_id}/messages", corsHandler(requireAuth(http.HandlerFunc(messageHandler.SendMessage))))
+	mux.Handle("PATCH /rides/{ride_id}/destination", corsHandler(requireAuth(http.HandlerFunc(destinationHandler.ChangeDestination))))
 
 	// WebSocket endpoint for passengers with passenger_id in path
 	mux.HandleFunc("GET /ws/passengers/{passenger_id}", func(w http.ResponseWriter, r *http.Request) {
@@ -168,7 +232,7 @@ func main() {
 						"url_passenger_id": passengerID,
 						"jwt_user_id":      conn.Claims.UserID,
 					}).Error("websocket_passenger_id_mismatch", fmt.Errorf("passenger_id mismatch"))
-					conn.Close()
+					conn.CloseWithReason(websocket.CloseAuthFailed, "passenger_id mismatch")
 					return
 				}
 
@@ -187,7 +251,7 @@ func main() {
 						}).Debug("passenger_ws_message", "Message from passenger")
 					},
 					func() {
-						wsManager.RemoveConnection(passengerID)
+						wsManager.ReleaseConnection(passengerID, conn)
 						log.WithFields(logger.LogFields{
 							"passenger_id": passengerID,
 						}).Info("websocket_passenger_disconnected", "Passenger WebSocket disconnected")
@@ -201,10 +265,7 @@ func main() {
 	})
 
 	// Start server
-	srv := &http.Server{
-		Addr:    ":3000",
-		Handler: mux,
-	}
+	srv := cfg.HTTPServer.NewServer(":3000", middleware.Chain(mux, middleware.Recovery(log), middleware.Logging(log)))
 
 	// Graceful shutdown
 	go func() {
@@ -222,8 +283,7 @@ func main() {
 	<-quit
 
 	log.Info("server_shutdown", "Shutting down server...")
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	srv.Shutdown(ctx)
+	runShutdown(log, cfg.HTTPServer.ShutdownTimeout,
+		shutdownSteps(messageConsumer.Stop, stopBackground, srv.Shutdown, rabbit.Close, dbConn.Close))
 	log.Info("server_stopped", "Server stopped gracefully")
 }
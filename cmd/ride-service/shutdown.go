@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ride-hail/pkg/logger"
+)
+
+// shutdownStep is one stage of the ordered shutdown
+type shutdownStep struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// shutdownSteps returns the ride service's stop order: nothing new is consumed,
+// then in-flight HTTP requests finish, and only then are RabbitMQ and the DB
+// pool closed
+func shutdownSteps(stopConsumers func(ctx context.Context) error, stopBackground context.CancelFunc,
+	shutdownHTTP func(ctx context.Context) error, closeRabbit, closeDB func()) []shutdownStep {
+	return []shutdownStep{
+		{"consumers", func(ctx context.Context) error {
+			// Handlers run with the background context, so it is cancelled only
+			// once they have drained
+			err := stopConsumers(ctx)
+			stopBackground()
+			return err
+		}},
+		{"http_server", shutdownHTTP},
+		{"rabbitmq", closeWithin(closeRabbit)},
+		{"database", closeWithin(closeDB)},
+	}
+}
+
+// runShutdown runs the steps in order, each with its own timeout. A step that
+// fails or times out is logged and the next one still runs.
+func runShutdown(log logger.Logger, timeout time.Duration, steps []shutdownStep) {
+	for _, step := range steps {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := step.stop(ctx)
+		cancel()
+
+		if err != nil {
+			log.WithFields(logger.LogFields{"step": step.name}).Error("shutdown_step_failed", err)
+			continue
+		}
+		log.WithFields(logger.LogFields{"step": step.name}).Info("shutdown_step_done", fmt.Sprintf("Stopped %s", step.name))
+	}
+}
+
+// closeWithin adapts a blocking Close to a shutdown step that gives up at the deadline
+func closeWithin(closeFn func()) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		done := make(chan struct{})
+		go func() {
+			closeFn()
+			close(done)
+		}()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"ride-hail/pkg/dbtest"
+	"ride-hail/pkg/logger"
+)
+
+// stopLog records the order in which the fake dependencies were stopped
+type stopLog struct {
+	mu    sync.Mutex
+	order []string
+}
+
+func (l *stopLog) add(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.order = append(l.order, name)
+}
+
+func (l *stopLog) step(name string, err error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		l.add(name)
+		return err
+	}
+}
+
+func (l *stopLog) close(name string) func() {
+	return func() { l.add(name) }
+}
+
+// failureLogger records the steps logged as failed
+type failureLogger struct {
+	dbtest.Logger
+	fields logger.LogFields
+	failed *[]string
+}
+
+func (l failureLogger) WithFields(fields logger.LogFields) logger.Logger {
+	return failureLogger{fields: fields, failed: l.failed}
+}
+
+func (l failureLogger) Error(action string, err error) {
+	*l.failed = append(*l.failed, l.fields["step"].(string))
+}
+
+func TestShutdownStopsInDependencyOrder(t *testing.T) {
+	var stopped stopLog
+	steps := shutdownSteps(
+		stopped.step("consumers", nil),
+		func() { stopped.add("background") },
+		stopped.step("http_server", nil),
+		stopped.close("rabbitmq"),
+		stopped.close("database"),
+	)
+
+	runShutdown(dbtest.Logger{}, time.Second, steps)
+
+	// The background context outlives the consumers' in-flight handlers, and
+	// the connections outlive everything that uses them
+	want := []string{"consumers", "background", "http_server", "rabbitmq", "database"}
+	if !reflect.DeepEqual(stopped.order, want) {
+		t.Errorf("stopped %v, want %v", stopped.order, want)
+	}
+}
+
+func TestShutdownContinuesPastAFailedStep(t *testing.T) {
+	var stopped stopLog
+	var failed []string
+	steps := shutdownSteps(
+		stopped.step("consumers", errors.New("consumers did not drain")),
+		func() { stopped.add("background") },
+		stopped.step("http_server", nil),
+		stopped.close("rabbitmq"),
+		stopped.close("database"),
+	)
+
+	runShutdown(failureLogger{failed: &failed}, time.Second, steps)
+
+	want := []string{"consumers", "background", "http_server", "rabbitmq", "database"}
+	if !reflect.DeepEqual(stopped.order, want) {
+		t.Errorf("stopped %v, want %v", stopped.order, want)
+	}
+	if !reflect.DeepEqual(failed, []string{"consumers"}) {
+		t.Errorf("logged failures for %v, want consumers", failed)
+	}
+}
+
+func TestShutdownGivesEachStepItsOwnTimeout(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+	var failed []string
+	var secondErr error
+	runShutdown(failureLogger{failed: &failed}, timeout, []shutdownStep{
+		{"stuck", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+		{"next", func(ctx context.Context) error {
+			secondErr = ctx.Err()
+			deadline, _ := ctx.Deadline()
+			if left := time.Until(deadline); left < timeout/2 {
+				t.Errorf("next step got %v of its timeout, want a fresh %v", left, timeout)
+			}
+			return nil
+		}},
+	})
+
+	if secondErr != nil {
+		t.Errorf("next step started with its context done: %v", secondErr)
+	}
+	if !reflect.DeepEqual(failed, []string{"stuck"}) {
+		t.Errorf("logged failures for %v, want stuck", failed)
+	}
+}
+
+func TestCloseWithinGivesUpAtTheDeadline(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := closeWithin(func() { <-release })(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the deadline", err)
+	}
+
+	if err := closeWithin(func() {})(context.Background()); err != nil {
+		t.Errorf("prompt close: err = %v", err)
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -12,6 +15,7 @@ import (
 	"ride-hail/internal/driver_location_service/domain"
 	"ride-hail/pkg/config"
 	"ride-hail/pkg/db"
+	"ride-hail/pkg/geo"
 	"ride-hail/pkg/logger"
 )
 
@@ -19,6 +23,12 @@ type PostgresDriverLocationRepository struct {
 	log  logger.Logger
 	cfg  *config.Config
 	pool *pgxpool.Pool
+
+	// Set when PostGIS is missing and DB_POSTGIS_FALLBACK allows the haversine path
+	useHaversine bool
+
+	// Upper bound on each method call (DB_QUERY_TIMEOUT_MS), 0 = only the caller's deadline applies
+	queryTimeout time.Duration
 }
 
 func NewPostgresDriverLocationRepository(log logger.Logger, cfg *config.Config) (*PostgresDriverLocationRepository, error) {
@@ -27,18 +37,46 @@ func NewPostgresDriverLocationRepository(log logger.Logger, cfg *config.Config)
 		log.Error("db_connection_failed", err)
 		return nil, fmt.Errorf("failed to create db connection: %w", err)
 	}
-	return &PostgresDriverLocationRepository{
-		log:  log,
-		cfg:  cfg,
-		pool: pool,
-	}, nil
+	repo := &PostgresDriverLocationRepository{
+		log:          log,
+		cfg:          cfg,
+		pool:         pool,
+		queryTimeout: cfg.DB.QueryTimeout,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if !repo.hasPostGIS(ctx) {
+		if cfg.DB.PostGISFallback {
+			repo.useHaversine = true
+			log.Warn("postgis_unavailable", "PostGIS not installed, matching drivers with the haversine fallback")
+		} else {
+			log.Error("postgis_unavailable", fmt.Errorf("PostGIS not installed; driver matching will fail (set DB_POSTGIS_FALLBACK=true to use the haversine fallback)"))
+		}
+	}
+	return repo, nil
+}
+
+// hasPostGIS reports whether the PostGIS extension is installed
+func (r *PostgresDriverLocationRepository) hasPostGIS(ctx context.Context) bool {
+	var installed bool
+	err := r.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'postgis')`).Scan(&installed)
+	if err != nil {
+		r.log.Error("postgis_probe_failed", err)
+		return false
+	}
+	return installed
 }
 
 // GetDriver retrieves driver information
 func (r *PostgresDriverLocationRepository) GetDriver(ctx context.Context, driverID string) (*domain.Driver, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `
 		SELECT d.id, u.email, d.license_number, d.vehicle_type, d.vehicle_attrs, 
-		       d.rating, d.total_rides, d.total_earnings, d.status, d.is_verified
+		       d.rating, d.total_rides, d.total_earnings, d.status, d.is_verified,
+		       COALESCE(d.current_ride_id::text, '')
 		FROM drivers d
 		JOIN users u ON d.id = u.id
 		WHERE d.id = $1
@@ -49,7 +87,7 @@ func (r *PostgresDriverLocationRepository) GetDriver(ctx context.Context, driver
 	err := r.pool.QueryRow(ctx, query, driverID).Scan(
 		&driver.ID, &driver.Email, &driver.LicenseNumber, &driver.VehicleType,
 		&vehicleAttrsJSON, &driver.Rating, &driver.TotalRides, &driver.TotalEarnings,
-		&driver.Status, &driver.IsVerified,
+		&driver.Status, &driver.IsVerified, &driver.CurrentRideID,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -70,8 +108,10 @@ func (r *PostgresDriverLocationRepository) GetDriver(ctx context.Context, driver
 
 // UpdateDriverStatus changes the driver's status
 func (r *PostgresDriverLocationRepository) UpdateDriverStatus(ctx context.Context, driverID string, status string) error {
-	if status != domain.DriverStatusOffline && status != domain.DriverStatusAvailable &&
-		status != domain.DriverStatusBusy && status != domain.DriverStatusEnRoute {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	if !validDriverStatus(status) {
 		return fmt.Errorf("invalid status value: %s", status)
 	}
 
@@ -83,11 +123,32 @@ func (r *PostgresDriverLocationRepository) UpdateDriverStatus(ctx context.Contex
 	return nil
 }
 
-// UpdateDriverSessionStats updates total rides and earnings for a driver
+// validDriverStatus reports whether status is one of the driver_status values
+func validDriverStatus(status string) bool {
+	switch status {
+	case domain.DriverStatusOffline, domain.DriverStatusAvailable, domain.DriverStatusBusy,
+		domain.DriverStatusEnRoute, domain.DriverStatusOnBreak:
+		return true
+	}
+	return false
+}
+
+// UpdateDriverSessionStats adds rides and earnings to the driver's lifetime totals
+// and to their open session, if any. Both are incremented in SQL in a single
+// statement, so concurrent completions never lose an update.
 func (r *PostgresDriverLocationRepository) UpdateDriverSessionStats(ctx context.Context, driverID string, rides int, earnings float64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `
-		UPDATE drivers 
-		SET total_rides = total_rides + $1, 
+		WITH open_session AS (
+			UPDATE driver_sessions
+			SET total_rides = COALESCE(total_rides, 0) + $1,
+			    total_earnings = COALESCE(total_earnings, 0) + $2
+			WHERE driver_id = $3 AND ended_at IS NULL
+		)
+		UPDATE drivers
+		SET total_rides = total_rides + $1,
 		    total_earnings = total_earnings + $2,
 		    updated_at = now()
 		WHERE id = $3
@@ -99,45 +160,125 @@ func (r *PostgresDriverLocationRepository) UpdateDriverSessionStats(ctx context.
 	return nil
 }
 
-// CreateDriverSession creates a new session when driver goes online
+// CreateDriverSession creates a new session when driver goes online. The partial
+// unique index on open sessions means a concurrent go-online (possibly on another
+// instance) cannot open a second one; the loser gets the winner's session ID.
 func (r *PostgresDriverLocationRepository) CreateDriverSession(ctx context.Context, driverID string) (string, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `
-		INSERT INTO driver_sessions (driver_id, started_at, total_rides, total_earnings)
-		VALUES ($1, now(), 0, 0)
-		RETURNING id
+		WITH created AS (
+			INSERT INTO driver_sessions (driver_id, started_at, total_rides, total_earnings)
+			VALUES ($1, now(), 0, 0)
+			ON CONFLICT (driver_id) WHERE ended_at IS NULL DO NOTHING
+			RETURNING id
+		)
+		SELECT id FROM created
+		UNION ALL
+		SELECT id FROM driver_sessions
+		WHERE driver_id = $1 AND ended_at IS NULL AND NOT EXISTS (SELECT 1 FROM created)
+		LIMIT 1
 	`
-	var sessionID string
-	err := r.pool.QueryRow(ctx, query, driverID).Scan(&sessionID)
-	if err != nil {
-		return "", fmt.Errorf("failed to create driver session: %w", err)
+	// A conflicting session committed after this statement began is not visible to
+	// its snapshot (or it may have just ended); a second attempt sees the current state
+	for attempt := 0; attempt < 2; attempt++ {
+		var sessionID string
+		err := r.pool.QueryRow(ctx, query, driverID).Scan(&sessionID)
+		if err == nil {
+			return sessionID, nil
+		}
+		if err != pgx.ErrNoRows {
+			return "", fmt.Errorf("failed to create driver session: %w", err)
+		}
 	}
-	return sessionID, nil
+	return "", fmt.Errorf("failed to create driver session: conflicting session for driver %s", driverID)
 }
 
-// EndDriverSession ends the current session and returns summary
-func (r *PostgresDriverLocationRepository) EndDriverSession(ctx context.Context, sessionID string) (*domain.DriverSession, error) {
+// EndDriverSession ends the current session at endedAt and returns summary
+func (r *PostgresDriverLocationRepository) EndDriverSession(ctx context.Context, sessionID string, endedAt time.Time) (*domain.DriverSession, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `
 		UPDATE driver_sessions 
-		SET ended_at = now()
+		SET ended_at = $2
 		WHERE id = $1 AND ended_at IS NULL
 		RETURNING id, driver_id, started_at, ended_at, total_rides, total_earnings
 	`
 	var session domain.DriverSession
-	err := r.pool.QueryRow(ctx, query, sessionID).Scan(
+	err := r.pool.QueryRow(ctx, query, sessionID, endedAt).Scan(
 		&session.ID, &session.DriverID, &session.StartedAt, &session.EndedAt,
 		&session.TotalRides, &session.TotalEarnings,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("session not found or already ended: %s", sessionID)
+			return nil, fmt.Errorf("%w: session %s not found or already ended", domain.ErrNoActiveSession, sessionID)
 		}
 		return nil, fmt.Errorf("failed to end driver session: %w", err)
 	}
 	return &session, nil
 }
 
+// SaveShiftSummary stores the summary of an ended session; saving the same
+// session again keeps the first row
+func (r *PostgresDriverLocationRepository) SaveShiftSummary(ctx context.Context, summary *domain.ShiftSummary) error {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO shift_summaries (session_id, driver_id, started_at, ended_at, rides_completed, earnings, busy_seconds)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (session_id) DO NOTHING
+	`, summary.SessionID, summary.DriverID, summary.StartedAt, summary.EndedAt,
+		summary.RidesCompleted, summary.Earnings, int(summary.BusyDuration.Seconds()))
+	if err != nil {
+		return fmt.Errorf("failed to save shift summary: %w", err)
+	}
+	return nil
+}
+
+// ListShiftSummaries returns a page of the driver's past shifts, newest first
+func (r *PostgresDriverLocationRepository) ListShiftSummaries(ctx context.Context, driverID string, limit, offset int) ([]*domain.ShiftSummary, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT session_id, driver_id, started_at, ended_at, rides_completed, earnings, busy_seconds
+		FROM shift_summaries
+		WHERE driver_id = $1
+		ORDER BY ended_at DESC, session_id
+		LIMIT $2 OFFSET $3
+	`, driverID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list shift summaries: %w", err)
+	}
+	defer rows.Close()
+
+	shifts := make([]*domain.ShiftSummary, 0)
+	for rows.Next() {
+		var s domain.ShiftSummary
+		var busySeconds int
+		if err := rows.Scan(
+			&s.SessionID, &s.DriverID, &s.StartedAt, &s.EndedAt,
+			&s.RidesCompleted, &s.Earnings, &busySeconds,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan shift summary: %w", err)
+		}
+		s.BusyDuration = time.Duration(busySeconds) * time.Second
+		shifts = append(shifts, &s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read shift summaries: %w", err)
+	}
+	return shifts, nil
+}
+
 // GetActiveSession retrieves the active session for a driver
 func (r *PostgresDriverLocationRepository) GetActiveSession(ctx context.Context, driverID string) (*domain.DriverSession, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `
 		SELECT id, driver_id, started_at, ended_at, total_rides, total_earnings
 		FROM driver_sessions
@@ -161,6 +302,9 @@ func (r *PostgresDriverLocationRepository) GetActiveSession(ctx context.Context,
 
 // SaveDriverLocation saves a new location coordinate for driver
 func (r *PostgresDriverLocationRepository) SaveDriverLocation(ctx context.Context, driverID string, latitude, longitude float64, address string) (string, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	// Start transaction to update old location and insert new one
 	tx, err := r.pool.Begin(ctx)
 	if err != nil {
@@ -200,6 +344,9 @@ func (r *PostgresDriverLocationRepository) SaveDriverLocation(ctx context.Contex
 
 // UpdateLocationWithMetrics updates location metrics (not used for primary coordinate table)
 func (r *PostgresDriverLocationRepository) UpdateLocationWithMetrics(ctx context.Context, coordinateID string, accuracy, speed, heading float64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	// This could be used if we add these fields to coordinates table
 	// For now we store these in location_history
 	return nil
@@ -207,6 +354,9 @@ func (r *PostgresDriverLocationRepository) UpdateLocationWithMetrics(ctx context
 
 // ArchiveLocation stores historical location data
 func (r *PostgresDriverLocationRepository) ArchiveLocation(ctx context.Context, driverID string, lat, lng, accuracy, speed, heading float64, rideID string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `
 		INSERT INTO location_history (driver_id, latitude, longitude, accuracy_meters, speed_kmh, heading_degrees, recorded_at, ride_id)
 		VALUES ($1, $2, $3, $4, $5, $6, now(), $7)
@@ -222,8 +372,77 @@ func (r *PostgresDriverLocationRepository) ArchiveLocation(ctx context.Context,
 	return nil
 }
 
+// SaveLocationBatch archives a batch of buffered points and makes the newest one current.
+// Points must be ordered by timestamp; the whole batch is written in one transaction.
+func (r *PostgresDriverLocationRepository) SaveLocationBatch(ctx context.Context, driverID string, points []domain.LocationUpdate, address string) (string, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	if len(points) == 0 {
+		return "", fmt.Errorf("empty location batch")
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	updateQuery := `
+		UPDATE coordinates
+		SET is_current = false, updated_at = now()
+		WHERE entity_id = $1 AND entity_type = 'driver' AND is_current = true
+	`
+	_, err = tx.Exec(ctx, updateQuery, driverID)
+	if err != nil {
+		return "", fmt.Errorf("failed to update old location: %w", err)
+	}
+
+	latest := points[len(points)-1]
+	insertQuery := `
+		INSERT INTO coordinates (entity_id, entity_type, address, latitude, longitude, is_current, created_at, updated_at)
+		VALUES ($1, 'driver', $2, $3, $4, true, $5, $5)
+		RETURNING id
+	`
+	var coordinateID string
+	err = tx.QueryRow(ctx, insertQuery, driverID, address, latest.Latitude, latest.Longitude, latest.Timestamp).Scan(&coordinateID)
+	if err != nil {
+		return "", fmt.Errorf("failed to insert new location: %w", err)
+	}
+
+	archiveQuery := `
+		INSERT INTO location_history (coordinate_id, driver_id, latitude, longitude, accuracy_meters, speed_kmh, heading_degrees, recorded_at, ride_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+	for i, p := range points {
+		var coordIDPtr *string
+		if i == len(points)-1 {
+			coordIDPtr = &coordinateID
+		}
+		var rideIDPtr *string
+		if p.RideID != "" {
+			rideID := p.RideID
+			rideIDPtr = &rideID
+		}
+		_, err = tx.Exec(ctx, archiveQuery, coordIDPtr, driverID, p.Latitude, p.Longitude,
+			p.AccuracyMeters, p.SpeedKmh, p.HeadingDegrees, p.Timestamp, rideIDPtr)
+		if err != nil {
+			return "", fmt.Errorf("failed to archive location %d: %w", i, err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return coordinateID, nil
+}
+
 // GetCurrentLocation retrieves the driver's current location
 func (r *PostgresDriverLocationRepository) GetCurrentLocation(ctx context.Context, driverID string) (*domain.Coordinate, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `
 		SELECT id, entity_id, entity_type, address, latitude, longitude, is_current, created_at, updated_at
 		FROM coordinates
@@ -247,6 +466,9 @@ func (r *PostgresDriverLocationRepository) GetCurrentLocation(ctx context.Contex
 
 // GetLastLocationUpdate retrieves timestamp of last location update
 func (r *PostgresDriverLocationRepository) GetLastLocationUpdate(ctx context.Context, driverID string) (*time.Time, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `
 		SELECT recorded_at
 		FROM location_history
@@ -265,31 +487,119 @@ func (r *PostgresDriverLocationRepository) GetLastLocationUpdate(ctx context.Con
 	return &lastUpdate, nil
 }
 
-// FindNearbyDrivers finds drivers within radius using PostGIS
-func (r *PostgresDriverLocationRepository) FindNearbyDrivers(ctx context.Context, latitude, longitude float64, vehicleType string, radiusMeters float64, limit int) ([]*domain.NearbyDriver, error) {
+// GetLocationHistory returns a page of the driver's archived locations ordered by
+// recorded_at then id, so pages never overlap or skip rows with equal timestamps
+func (r *PostgresDriverLocationRepository) GetLocationHistory(ctx context.Context, driverID string, q domain.LocationHistoryQuery) ([]*domain.LocationHistory, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	conds := []string{"driver_id = $1"}
+	args := []interface{}{driverID}
+	if q.RideID != "" {
+		args = append(args, q.RideID)
+		conds = append(conds, fmt.Sprintf("ride_id = $%d", len(args)))
+	}
+	if !q.From.IsZero() {
+		args = append(args, q.From)
+		conds = append(conds, fmt.Sprintf("recorded_at >= $%d", len(args)))
+	}
+	if !q.To.IsZero() {
+		args = append(args, q.To)
+		conds = append(conds, fmt.Sprintf("recorded_at < $%d", len(args)))
+	}
+	args = append(args, q.Limit, q.Offset)
+	query := fmt.Sprintf(`
+		SELECT id, COALESCE(coordinate_id::text, ''), driver_id, latitude, longitude,
+		       COALESCE(accuracy_meters, 0), COALESCE(speed_kmh, 0), COALESCE(heading_degrees, 0),
+		       recorded_at, COALESCE(ride_id::text, '')
+		FROM location_history
+		WHERE %s
+		ORDER BY recorded_at, id
+		LIMIT $%d OFFSET $%d
+	`, strings.Join(conds, " AND "), len(args)-1, len(args))
+
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get location history: %w", err)
+	}
+	defer rows.Close()
+
+	points := make([]*domain.LocationHistory, 0)
+	for rows.Next() {
+		var p domain.LocationHistory
+		if err := rows.Scan(
+			&p.ID, &p.CoordinateID, &p.DriverID, &p.Latitude, &p.Longitude,
+			&p.AccuracyMeters, &p.SpeedKmh, &p.HeadingDegrees,
+			&p.RecordedAt.Time, &p.RideID,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan location history: %w", err)
+		}
+		points = append(points, &p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read location history: %w", err)
+	}
+	return points, nil
+}
+
+// vehicleSeatsSQL is a driver's seat count from vehicle_attrs, or
+// domain.DefaultVehicleSeats when the attribute is missing or not a number
+var vehicleSeatsSQL = fmt.Sprintf(`COALESCE(
+    CASE WHEN jsonb_typeof(d.vehicle_attrs->'seats') = 'number' THEN (d.vehicle_attrs->>'seats')::numeric END,
+    %d)`, domain.DefaultVehicleSeats)
+
+// FindNearbyDrivers finds drivers within radius using PostGIS.
+// A driver's last update is the newer of the current coordinate and the latest
+// location_history point.
+// Drivers rated below minRating are skipped; pass 0 for no floor.
+// Vehicles with fewer than minSeats seats are skipped, as are the drivers in
+// excludeDriverIDs.
+func (r *PostgresDriverLocationRepository) FindNearbyDrivers(ctx context.Context, latitude, longitude float64, vehicleType string, radiusMeters, minRating float64, minSeats, limit int, excludeDriverIDs []string) ([]*domain.NearbyDriver, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	// A nil slice is sent as NULL, which <> ALL would treat as excluding everyone
+	if excludeDriverIDs == nil {
+		excludeDriverIDs = []string{}
+	}
+	if r.useHaversine {
+		return r.findNearbyDriversHaversine(ctx, latitude, longitude, vehicleType, radiusMeters, minRating, minSeats, limit, excludeDriverIDs)
+	}
+
 	query := `
 		SELECT d.id, u.email, d.rating, c.latitude, c.longitude,
        ST_Distance(
          ST_MakePoint(c.longitude, c.latitude)::geography,
          ST_MakePoint($2, $1)::geography
-       ) / 1000 as distance_km
+       ) / 1000 as distance_km,
+       lh.heading_degrees, COALESCE(lh.speed_kmh, 0),
+       GREATEST(c.updated_at, lh.recorded_at) AS last_updated_at
 FROM drivers d
 JOIN users u ON d.id = u.id
 JOIN coordinates c ON c.entity_id = d.id
   AND c.entity_type = 'driver'
   AND c.is_current = true
+LEFT JOIN LATERAL (
+  SELECT heading_degrees, speed_kmh, recorded_at
+  FROM location_history
+  WHERE driver_id = d.id
+  ORDER BY recorded_at DESC
+  LIMIT 1
+) lh ON true
 WHERE d.status = 'AVAILABLE'
   AND d.vehicle_type = $3
+  AND d.rating >= $6
+  AND ` + vehicleSeatsSQL + ` >= $7
+  AND d.id::text <> ALL($8::text[])
   AND ST_DWithin(
         ST_MakePoint(c.longitude, c.latitude)::geography,
         ST_MakePoint( $2, $1)::geography,
-        5000  -- 5km radius
+        $4
       )
 ORDER BY distance_km, d.rating DESC
-LIMIT 10
+LIMIT $5
 	`
-	fmt.Println(longitude, latitude)
-	rows, err := r.pool.Query(ctx, query, latitude, longitude, vehicleType)
+	rows, err := r.pool.Query(ctx, query, latitude, longitude, vehicleType, radiusMeters, limit, minRating, minSeats, excludeDriverIDs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find nearby drivers: %w", err)
 	}
@@ -303,6 +613,7 @@ LIMIT 10
 		err := rows.Scan(
 			&driver.DriverID, &driver.Email, &driver.Rating,
 			&driver.Latitude, &driver.Longitude, &driver.DistanceKm,
+			&driver.HeadingDegrees, &driver.SpeedKmh, &driver.LocationUpdatedAt,
 		)
 		if err != nil {
 			r.log.Error("scan_nearby_driver_failed", err)
@@ -322,38 +633,241 @@ LIMIT 10
 	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating nearby drivers: %w", err)
 	}
-	fmt.Println(drivers)
 	return drivers, nil
 }
 
-// SetDriverCurrentRide assigns a ride to a driver
+// findNearbyDriversHaversine is FindNearbyDrivers for databases without PostGIS:
+// a bounding box narrows the candidates in SQL, then distance is computed in Go
+func (r *PostgresDriverLocationRepository) findNearbyDriversHaversine(ctx context.Context, latitude, longitude float64, vehicleType string, radiusMeters, minRating float64, minSeats, limit int, excludeDriverIDs []string) ([]*domain.NearbyDriver, error) {
+	radiusKm := radiusMeters / 1000
+	minLat, maxLat, minLng, maxLng, wrapsLng := geo.BoundingBox(latitude, longitude, radiusKm)
+
+	query := `
+		SELECT d.id, u.email, d.rating, c.latitude, c.longitude,
+       lh.heading_degrees, COALESCE(lh.speed_kmh, 0),
+       GREATEST(c.updated_at, lh.recorded_at) AS last_updated_at
+FROM drivers d
+JOIN users u ON d.id = u.id
+JOIN coordinates c ON c.entity_id = d.id
+  AND c.entity_type = 'driver'
+  AND c.is_current = true
+LEFT JOIN LATERAL (
+  SELECT heading_degrees, speed_kmh, recorded_at
+  FROM location_history
+  WHERE driver_id = d.id
+  ORDER BY recorded_at DESC
+  LIMIT 1
+) lh ON true
+WHERE d.status = 'AVAILABLE'
+  AND d.vehicle_type = $1
+  AND d.rating >= $2
+  AND c.latitude BETWEEN $3 AND $4
+  AND ($7 OR c.longitude BETWEEN $5 AND $6)
+  AND ` + vehicleSeatsSQL + ` >= $8
+  AND d.id::text <> ALL($9::text[])
+	`
+	rows, err := r.pool.Query(ctx, query, vehicleType, minRating, minLat, maxLat, minLng, maxLng, wrapsLng, minSeats, excludeDriverIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find nearby drivers: %w", err)
+	}
+	defer rows.Close()
+
+	var drivers []*domain.NearbyDriver
+	for rows.Next() {
+		var driver domain.NearbyDriver
+		err := rows.Scan(
+			&driver.DriverID, &driver.Email, &driver.Rating,
+			&driver.Latitude, &driver.Longitude,
+			&driver.HeadingDegrees, &driver.SpeedKmh, &driver.LocationUpdatedAt,
+		)
+		if err != nil {
+			r.log.Error("scan_nearby_driver_failed", err)
+			continue
+		}
+
+		driver.DistanceKm = geo.HaversineKm(latitude, longitude, driver.Latitude, driver.Longitude)
+		if driver.DistanceKm <= radiusKm {
+			drivers = append(drivers, &driver)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating nearby drivers: %w", err)
+	}
+
+	// Same ordering as the PostGIS query
+	sort.SliceStable(drivers, func(i, j int) bool {
+		if drivers[i].DistanceKm != drivers[j].DistanceKm {
+			return drivers[i].DistanceKm < drivers[j].DistanceKm
+		}
+		return drivers[i].Rating > drivers[j].Rating
+	})
+	if len(drivers) > limit {
+		drivers = drivers[:limit]
+	}
+	return drivers, nil
+}
+
+// SetDriverCurrentRide binds a driver to the ride they accepted and marks them BUSY
 func (r *PostgresDriverLocationRepository) SetDriverCurrentRide(ctx context.Context, driverID string, rideID string) error {
-	// We can store this in driver state or use a separate tracking table
-	// For now, we'll just update the driver status to BUSY
-	return r.UpdateDriverStatus(ctx, driverID, domain.DriverStatusBusy)
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `UPDATE drivers SET status = $1, current_ride_id = $2, updated_at = now() WHERE id = $3`
+	if _, err := r.pool.Exec(ctx, query, domain.DriverStatusBusy, rideID, driverID); err != nil {
+		return fmt.Errorf("failed to set driver current ride: %w", err)
+	}
+	return nil
 }
 
 // ClearDriverCurrentRide clears the ride assignment
 func (r *PostgresDriverLocationRepository) ClearDriverCurrentRide(ctx context.Context, driverID string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	// Reset driver to AVAILABLE
-	return r.UpdateDriverStatus(ctx, driverID, domain.DriverStatusAvailable)
+	query := `UPDATE drivers SET status = $1, current_ride_id = NULL, updated_at = now() WHERE id = $2`
+	if _, err := r.pool.Exec(ctx, query, domain.DriverStatusAvailable, driverID); err != nil {
+		return fmt.Errorf("failed to clear driver current ride: %w", err)
+	}
+	return nil
+}
+
+// GetRideFareBasis returns the fare, vehicle type and endpoints a ride was
+// priced from, or nil if the ride does not exist
+func (r *PostgresDriverLocationRepository) GetRideFareBasis(ctx context.Context, rideID string) (*domain.RideFareBasis, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT COALESCE(r.estimated_fare, 0), COALESCE(r.vehicle_type, ''),
+			COALESCE(cp.latitude, 0), COALESCE(cp.longitude, 0),
+			COALESCE(cd.latitude, 0), COALESCE(cd.longitude, 0)
+		FROM rides r
+		LEFT JOIN coordinates cp ON cp.id = r.pickup_coordinate_id
+		LEFT JOIN coordinates cd ON cd.id = r.destination_coordinate_id
+		WHERE r.id = $1
+	`
+	var basis domain.RideFareBasis
+	err := r.pool.QueryRow(ctx, query, rideID).Scan(
+		&basis.EstimatedFare, &basis.VehicleType,
+		&basis.Pickup.Lat, &basis.Pickup.Lng,
+		&basis.Destination.Lat, &basis.Destination.Lng,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get ride fare basis: %w", err)
+	}
+	return &basis, nil
+}
+
+// GetDriverBusyDuration sums the time the driver spent on rides (matched until
+// completed/cancelled) within [from, to]
+func (r *PostgresDriverLocationRepository) GetDriverBusyDuration(ctx context.Context, driverID string, from, to time.Time) (time.Duration, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT COALESCE(SUM(EXTRACT(EPOCH FROM (
+			LEAST(COALESCE(completed_at, cancelled_at, $3), $3) - GREATEST(matched_at, $2)
+		))), 0)::float8
+		FROM rides
+		WHERE driver_id = $1
+		  AND matched_at IS NOT NULL
+		  AND matched_at < $3
+		  AND COALESCE(completed_at, cancelled_at, $3) > $2
+	`
+	var seconds float64
+	if err := r.pool.QueryRow(ctx, query, driverID, from, to).Scan(&seconds); err != nil {
+		return 0, fmt.Errorf("failed to get driver busy duration: %w", err)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
 }
 
-func (r *PostgresDriverLocationRepository) GetEstimatedFare(ctx context.Context, rideID string) (float64, error) {
+// GetRideAssignment retrieves the ride's passenger, assigned driver and status
+func (r *PostgresDriverLocationRepository) GetRideAssignment(ctx context.Context, rideID string) (*domain.RideAssignment, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `
-		SELECT estimated_fare
+		SELECT id, ride_number, passenger_id, COALESCE(driver_id::text, ''), COALESCE(status, ''),
+			CASE WHEN status = 'ARRIVED' THEN COALESCE(arrived_at, updated_at) END
 		FROM rides
 		WHERE id = $1
 	`
-	var fare float64
-	err := r.pool.QueryRow(ctx, query, rideID).Scan(&fare)
+	var ride domain.RideAssignment
+	err := r.pool.QueryRow(ctx, query, rideID).Scan(
+		&ride.RideID, &ride.RideNumber, &ride.PassengerID, &ride.DriverID, &ride.Status, &ride.ArrivedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get ride assignment: %w", err)
+	}
+	return &ride, nil
+}
+
+// GetDriverCurrentRide returns the driver's active ride, or nil if they have none
+func (r *PostgresDriverLocationRepository) GetDriverCurrentRide(ctx context.Context, driverID string) (*domain.CurrentRide, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT r.id, r.ride_number, r.status, r.passenger_id,
+			COALESCE(u.attrs->>'name', ''), COALESCE(u.attrs->>'phone', ''),
+			COALESCE(cp.latitude, 0), COALESCE(cp.longitude, 0), COALESCE(cp.address, ''),
+			COALESCE(cd.latitude, 0), COALESCE(cd.longitude, 0), COALESCE(cd.address, ''),
+			COALESCE(r.estimated_fare, 0), r.matched_at
+		FROM rides r
+		JOIN users u ON u.id = r.passenger_id
+		LEFT JOIN coordinates cp ON cp.id = r.pickup_coordinate_id
+		LEFT JOIN coordinates cd ON cd.id = r.destination_coordinate_id
+		WHERE r.driver_id = $1
+		  AND r.status IN ('MATCHED', 'EN_ROUTE', 'ARRIVED', 'IN_PROGRESS')
+		ORDER BY r.matched_at DESC NULLS LAST
+		LIMIT 1
+	`
+	var ride domain.CurrentRide
+	var name, phone string
+	err := r.pool.QueryRow(ctx, query, driverID).Scan(
+		&ride.RideID, &ride.RideNumber, &ride.Status, &ride.PassengerID,
+		&name, &phone,
+		&ride.Pickup.Lat, &ride.Pickup.Lng, &ride.Pickup.Address,
+		&ride.Destination.Lat, &ride.Destination.Lng, &ride.Destination.Address,
+		&ride.EstimatedFare, &ride.MatchedAt,
+	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return 0, nil
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get driver current ride: %w", err)
+	}
+	ride.Passenger = domain.NewPassengerContact(ride.RideID, ride.PassengerID, name, phone)
+	return &ride, nil
+}
+
+// GetPassengerContact returns the masked contact of the ride's passenger
+func (r *PostgresDriverLocationRepository) GetPassengerContact(ctx context.Context, rideID string) (*domain.PassengerContact, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT r.passenger_id, COALESCE(u.attrs->>'name', ''), COALESCE(u.attrs->>'phone', '')
+		FROM rides r
+		JOIN users u ON u.id = r.passenger_id
+		WHERE r.id = $1
+	`
+	var passengerID, name, phone string
+	if err := r.pool.QueryRow(ctx, query, rideID).Scan(&passengerID, &name, &phone); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("ride not found: %s", rideID)
 		}
-		return 0, fmt.Errorf("failed to get estimated fare: %w", err)
+		return nil, fmt.Errorf("failed to get passenger contact: %w", err)
 	}
-	return fare, nil
+	contact := domain.NewPassengerContact(rideID, passengerID, name, phone)
+	return &contact, nil
 }
 
 // Close releases the underlying database pool.
@@ -362,3 +876,211 @@ func (r *PostgresDriverLocationRepository) Close() {
 		r.pool.Close()
 	}
 }
+
+// CountRequestedRidesByCell buckets the pickups of rides still waiting for a driver
+// into a grid of cellSizeDeg-degree squares inside the box
+func (r *PostgresDriverLocationRepository) CountRequestedRidesByCell(ctx context.Context, box domain.BoundingBox, cellSizeDeg float64) ([]domain.HeatmapCell, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT (FLOOR(c.latitude / $5) + 0.5) * $5 AS cell_lat,
+			(FLOOR(c.longitude / $5) + 0.5) * $5 AS cell_lng,
+			COUNT(*)
+		FROM rides r
+		JOIN coordinates c ON c.id = r.pickup_coordinate_id
+		WHERE r.status = 'REQUESTED'
+			AND c.latitude BETWEEN $1 AND $3
+			AND c.longitude BETWEEN $2 AND $4
+		GROUP BY cell_lat, cell_lng
+		ORDER BY COUNT(*) DESC
+	`
+	rows, err := r.pool.Query(ctx, query, box.MinLat, box.MinLng, box.MaxLat, box.MaxLng, cellSizeDeg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count requested rides: %w", err)
+	}
+	defer rows.Close()
+
+	var cells []domain.HeatmapCell
+	for rows.Next() {
+		var cell domain.HeatmapCell
+		if err := rows.Scan(&cell.Latitude, &cell.Longitude, &cell.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan heatmap cell: %w", err)
+		}
+		cells = append(cells, cell)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating heatmap cells: %w", err)
+	}
+	return cells, nil
+}
+
+// RecordOfferResponse stores how a driver answered (or ignored) a ride offer, and
+// logs it to the ride's audit trail as an OFFER_<response> event. The audit event
+// is written separately so a failure there (e.g. its event type missing) never
+// costs the response used for acceptance stats; it is only logged.
+func (r *PostgresDriverLocationRepository) RecordOfferResponse(ctx context.Context, driverID, rideID, offerID, response string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO driver_offer_responses (driver_id, ride_id, offer_id, response)
+		VALUES ($1, $2, $3, $4)
+	`
+	if _, err := r.pool.Exec(ctx, query, driverID, rideID, offerID, response); err != nil {
+		return fmt.Errorf("failed to record offer response: %w", err)
+	}
+
+	eventQuery := `
+		INSERT INTO ride_events (ride_id, event_type, event_data)
+		VALUES ($1, 'OFFER_' || $2::text, jsonb_build_object('offer_id', $3::text, 'driver_id', $4::text))
+	`
+	if _, err := r.pool.Exec(ctx, eventQuery, rideID, response, offerID, driverID); err != nil {
+		r.log.WithFields(logger.LogFields{
+			"ride_id":  rideID,
+			"offer_id": offerID,
+			"response": response,
+		}).Error("record_offer_event_failed", err)
+	}
+	return nil
+}
+
+// RecordOfferSent logs a delivered offer to the ride's audit trail
+func (r *PostgresDriverLocationRepository) RecordOfferSent(ctx context.Context, driverID, rideID, offerID string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO ride_events (ride_id, event_type, event_data)
+		VALUES ($1, 'OFFER_SENT', jsonb_build_object('offer_id', $2::text, 'driver_id', $3::text))
+	`
+	if _, err := r.pool.Exec(ctx, query, rideID, offerID, driverID); err != nil {
+		return fmt.Errorf("failed to record offer sent: %w", err)
+	}
+	return nil
+}
+
+// CountOffersSent returns how many offers a ride has had over its whole matching lifecycle
+func (r *PostgresDriverLocationRepository) CountOffersSent(ctx context.Context, rideID string) (int, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var count int
+	err := r.pool.QueryRow(ctx, `
+		SELECT COUNT(*) FROM ride_events WHERE ride_id = $1 AND event_type = 'OFFER_SENT'
+	`, rideID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count offers sent: %w", err)
+	}
+	return count, nil
+}
+
+// SavePendingOffer stores an outstanding ride offer; re-offering the same ride to
+// the same driver replaces the previous row
+func (r *PostgresDriverLocationRepository) SavePendingOffer(ctx context.Context, offer *domain.PendingOffer) error {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	request, err := json.Marshal(offer.Request)
+	if err != nil {
+		return fmt.Errorf("failed to marshal offer request: %w", err)
+	}
+	query := `
+		INSERT INTO pending_ride_offers (offer_id, ride_id, driver_id, request, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (offer_id) DO UPDATE
+		SET request = EXCLUDED.request, expires_at = EXCLUDED.expires_at, created_at = NOW()
+	`
+	if _, err := r.pool.Exec(ctx, query, offer.OfferID, offer.RideID, offer.DriverID, request, offer.ExpiresAt); err != nil {
+		return fmt.Errorf("failed to save pending offer: %w", err)
+	}
+	return nil
+}
+
+// DeletePendingOffers removes answered, expired or withdrawn offers
+func (r *PostgresDriverLocationRepository) DeletePendingOffers(ctx context.Context, offerIDs []string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	if len(offerIDs) == 0 {
+		return nil
+	}
+	if _, err := r.pool.Exec(ctx, `DELETE FROM pending_ride_offers WHERE offer_id = ANY($1)`, offerIDs); err != nil {
+		return fmt.Errorf("failed to delete pending offers: %w", err)
+	}
+	return nil
+}
+
+// ListPendingOffers returns every stored offer, including ones that have already expired
+func (r *PostgresDriverLocationRepository) ListPendingOffers(ctx context.Context) ([]*domain.PendingOffer, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT offer_id, ride_id, driver_id, request, expires_at
+		FROM pending_ride_offers
+		ORDER BY expires_at
+	`
+	rows, err := r.pool.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending offers: %w", err)
+	}
+	defer rows.Close()
+
+	var offers []*domain.PendingOffer
+	for rows.Next() {
+		var (
+			offer   domain.PendingOffer
+			request []byte
+		)
+		if err := rows.Scan(&offer.OfferID, &offer.RideID, &offer.DriverID, &request, &offer.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan pending offer: %w", err)
+		}
+		offer.Request = &domain.RideMatchingRequest{}
+		if err := json.Unmarshal(request, offer.Request); err != nil {
+			return nil, fmt.Errorf("failed to decode pending offer %s: %w", offer.OfferID, err)
+		}
+		offers = append(offers, &offer)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating pending offers: %w", err)
+	}
+	return offers, nil
+}
+
+// GetDriverStats computes acceptance and completion rates since the given time.
+// Acceptance counts recorded offer responses; completion compares rides matched to
+// the driver (DRIVER_MATCHED events) with those they completed.
+func (r *PostgresDriverLocationRepository) GetDriverStats(ctx context.Context, driverID string, since time.Time) (*domain.DriverStats, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM driver_offer_responses
+			 WHERE driver_id = $1 AND created_at >= $2 AND response <> 'ACCEPT_FAILED'),
+			(SELECT COUNT(*) FROM driver_offer_responses
+			 WHERE driver_id = $1 AND created_at >= $2 AND response = 'ACCEPTED'),
+			(SELECT COUNT(DISTINCT ride_id) FROM ride_events
+			 WHERE event_type = 'DRIVER_MATCHED' AND event_data->>'driver_id' = $1::text AND created_at >= $2),
+			(SELECT COUNT(*) FROM rides
+			 WHERE driver_id = $1 AND status = 'COMPLETED' AND completed_at >= $2)
+	`
+	stats := domain.DriverStats{Since: since}
+	err := r.pool.QueryRow(ctx, query, driverID, since).Scan(
+		&stats.OffersReceived, &stats.OffersAccepted,
+		&stats.RidesMatched, &stats.RidesCompleted,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get driver stats: %w", err)
+	}
+
+	if stats.OffersReceived > 0 {
+		stats.AcceptanceRatePercent = float64(stats.OffersAccepted) / float64(stats.OffersReceived) * 100
+	}
+	// Rides matched before the window can complete inside it; cap at 100%
+	if stats.RidesMatched > 0 {
+		stats.CompletionRatePercent = math.Min(float64(stats.RidesCompleted)/float64(stats.RidesMatched)*100, 100)
+	}
+	return &stats, nil
+}
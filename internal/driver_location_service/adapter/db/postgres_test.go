@@ -0,0 +1,951 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"ride-hail/internal/driver_location_service/app"
+	"ride-hail/internal/driver_location_service/domain"
+	riderepo "ride-hail/internal/ride-service/infrastructure/repository"
+	"ride-hail/pkg/config"
+	"ride-hail/pkg/dbtest"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// newTestRepo returns a repository over a fresh test database
+func newTestRepo(t *testing.T) *PostgresDriverLocationRepository {
+	t.Helper()
+	repo := &PostgresDriverLocationRepository{
+		log:  dbtest.Logger{},
+		cfg:  &config.Config{},
+		pool: dbtest.Pool(t),
+	}
+	repo.useHaversine = !repo.hasPostGIS(context.Background())
+	return repo
+}
+
+var seededDrivers int
+
+// seedDriver adds an available economy driver and returns its ID
+func seedDriver(t *testing.T, repo *PostgresDriverLocationRepository) string {
+	t.Helper()
+	ctx := context.Background()
+	seededDrivers++
+	var id string
+	err := repo.pool.QueryRow(ctx, `
+		INSERT INTO users (email, role, password_hash) VALUES ($1, 'DRIVER', 'x') RETURNING id
+	`, fmt.Sprintf("driver%d@repo.test", seededDrivers)).Scan(&id)
+	if err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+	_, err = repo.pool.Exec(ctx, `
+		INSERT INTO drivers (id, license_number, vehicle_type, status, is_verified)
+		VALUES ($1, $2, 'ECONOMY', 'AVAILABLE', true)
+	`, id, fmt.Sprintf("REPO-%04d", seededDrivers))
+	if err != nil {
+		t.Fatalf("seed driver: %v", err)
+	}
+	return id
+}
+
+func TestCreateDriverSessionConcurrentlyOpensOne(t *testing.T) {
+	repo := newTestRepo(t)
+	driverID := seedDriver(t, repo)
+	ctx := context.Background()
+
+	const devices = 8
+	ids := make([]string, devices)
+	var wg sync.WaitGroup
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id, err := repo.CreateDriverSession(ctx, driverID)
+			if err != nil {
+				t.Errorf("CreateDriverSession: %v", err)
+			}
+			ids[i] = id
+		}(i)
+	}
+	wg.Wait()
+
+	for _, id := range ids[1:] {
+		if id != ids[0] {
+			t.Errorf("got sessions %s and %s, want every caller to get the one open session", ids[0], id)
+		}
+	}
+	var open int
+	if err := repo.pool.QueryRow(ctx,
+		`SELECT COUNT(*) FROM driver_sessions WHERE driver_id = $1 AND ended_at IS NULL`, driverID,
+	).Scan(&open); err != nil {
+		t.Fatalf("count sessions: %v", err)
+	}
+	if open != 1 {
+		t.Errorf("%d open sessions, want 1", open)
+	}
+}
+
+func TestOpenSessionsAreUniquePerDriver(t *testing.T) {
+	repo := newTestRepo(t)
+	driverID := seedDriver(t, repo)
+	ctx := context.Background()
+
+	// The index holds for writers that bypass CreateDriverSession too
+	insert := `INSERT INTO driver_sessions (driver_id, started_at) VALUES ($1, now())`
+	if _, err := repo.pool.Exec(ctx, insert, driverID); err != nil {
+		t.Fatalf("first session: %v", err)
+	}
+	var pgErr *pgconn.PgError
+	_, err := repo.pool.Exec(ctx, insert, driverID)
+	if !errors.As(err, &pgErr) || pgErr.Code != "23505" {
+		t.Fatalf("second open session: err = %v, want a unique violation", err)
+	}
+
+	// Closed sessions don't count
+	if _, err := repo.pool.Exec(ctx, `UPDATE driver_sessions SET ended_at = now() WHERE driver_id = $1`, driverID); err != nil {
+		t.Fatalf("end session: %v", err)
+	}
+	if _, err := repo.pool.Exec(ctx, insert, driverID); err != nil {
+		t.Errorf("session after the first ended: %v", err)
+	}
+}
+
+func TestCreateDriverSessionAfterEndingOpensANewOne(t *testing.T) {
+	repo := newTestRepo(t)
+	driverID := seedDriver(t, repo)
+	ctx := context.Background()
+
+	first, err := repo.CreateDriverSession(ctx, driverID)
+	if err != nil {
+		t.Fatalf("CreateDriverSession: %v", err)
+	}
+	if again, err := repo.CreateDriverSession(ctx, driverID); err != nil || again != first {
+		t.Fatalf("second CreateDriverSession = %q, %v; want the open session %q", again, err, first)
+	}
+	if _, err := repo.EndDriverSession(ctx, first, time.Now()); err != nil {
+		t.Fatalf("EndDriverSession: %v", err)
+	}
+
+	next, err := repo.CreateDriverSession(ctx, driverID)
+	if err != nil {
+		t.Fatalf("CreateDriverSession after ending: %v", err)
+	}
+	if next == first {
+		t.Errorf("got the ended session %s back, want a new one", first)
+	}
+}
+
+func TestShiftSummariesAreStoredOnceAndListedNewestFirst(t *testing.T) {
+	repo := newTestRepo(t)
+	driverID := seedDriver(t, repo)
+	ctx := context.Background()
+	start := time.Date(2024, 12, 16, 8, 0, 0, 0, time.UTC)
+
+	// Two shifts, each with a ride counted in its open session
+	var summaries []*domain.ShiftSummary
+	for i := 0; i < 2; i++ {
+		sessionID, err := repo.CreateDriverSession(ctx, driverID)
+		if err != nil {
+			t.Fatalf("CreateDriverSession: %v", err)
+		}
+		if err := repo.UpdateDriverSessionStats(ctx, driverID, 1, 1160); err != nil {
+			t.Fatalf("UpdateDriverSessionStats: %v", err)
+		}
+		session, err := repo.EndDriverSession(ctx, sessionID, time.Now())
+		if err != nil {
+			t.Fatalf("EndDriverSession: %v", err)
+		}
+		summary := domain.NewShiftSummary(session)
+		summary.StartedAt = start.Add(time.Duration(i) * 24 * time.Hour)
+		summary.EndedAt = summary.StartedAt.Add(4 * time.Hour)
+		summary.BusyDuration = 90 * time.Minute
+		if err := repo.SaveShiftSummary(ctx, summary); err != nil {
+			t.Fatalf("SaveShiftSummary: %v", err)
+		}
+		summaries = append(summaries, summary)
+	}
+	// A retry for the same session keeps the first row
+	retry := *summaries[0]
+	retry.RidesCompleted = 99
+	if err := repo.SaveShiftSummary(ctx, &retry); err != nil {
+		t.Fatalf("SaveShiftSummary retry: %v", err)
+	}
+
+	shifts, err := repo.ListShiftSummaries(ctx, driverID, 10, 0)
+	if err != nil {
+		t.Fatalf("ListShiftSummaries: %v", err)
+	}
+	if len(shifts) != 2 {
+		t.Fatalf("listed %d shifts, want 2", len(shifts))
+	}
+	for i, want := range []*domain.ShiftSummary{summaries[1], summaries[0]} {
+		got := shifts[i]
+		if got.SessionID != want.SessionID || !got.EndedAt.Equal(want.EndedAt) || got.RidesCompleted != 1 ||
+			got.Earnings != 1160 || got.BusyDuration != 90*time.Minute {
+			t.Errorf("shift %d = %+v, want %+v", i, *got, *want)
+		}
+	}
+
+	if page, err := repo.ListShiftSummaries(ctx, driverID, 1, 1); err != nil || len(page) != 1 || page[0].SessionID != summaries[0].SessionID {
+		t.Errorf("second page = %v, %v; want the older shift", page, err)
+	}
+}
+
+func TestFindNearbyDriversAppliesRatingFloor(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	const lat, lng = 43.2389, 76.8897
+
+	place := func(vehicleType string, rating float64) string {
+		t.Helper()
+		id := seedDriver(t, repo)
+		if _, err := repo.pool.Exec(ctx, `UPDATE drivers SET vehicle_type = $2, rating = $3 WHERE id = $1`, id, vehicleType, rating); err != nil {
+			t.Fatalf("set driver rating: %v", err)
+		}
+		if _, err := repo.SaveDriverLocation(ctx, id, lat, lng, ""); err != nil {
+			t.Fatalf("save location: %v", err)
+		}
+		return id
+	}
+	luxLow := place("LUXURY", 4.2)
+	luxHigh := place("LUXURY", 4.9)
+	ecoLow := place("ECONOMY", 4.2)
+
+	ids := func(vehicleType string, minRating float64) []string {
+		t.Helper()
+		drivers, err := repo.FindNearbyDrivers(ctx, lat, lng, vehicleType, 1000, minRating, 1, 10, nil)
+		if err != nil {
+			t.Fatalf("FindNearbyDrivers(%s, %v): %v", vehicleType, minRating, err)
+		}
+		var found []string
+		for _, d := range drivers {
+			found = append(found, d.DriverID)
+		}
+		return found
+	}
+
+	if got := ids("LUXURY", 4.7); len(got) != 1 || got[0] != luxHigh {
+		t.Errorf("luxury with floor found %v, want only %s", got, luxHigh)
+	}
+	if got := ids("LUXURY", 0); len(got) != 2 {
+		t.Errorf("luxury without floor found %v, want %s and %s", got, luxHigh, luxLow)
+	}
+	if got := ids("ECONOMY", 0); len(got) != 1 || got[0] != ecoLow {
+		t.Errorf("economy found %v, want %s", got, ecoLow)
+	}
+}
+
+func TestFindNearbyDriversSkipsDriversOnBreak(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	const lat, lng = 43.2389, 76.8897
+
+	id := seedDriver(t, repo)
+	if _, err := repo.SaveDriverLocation(ctx, id, lat, lng, ""); err != nil {
+		t.Fatalf("save location: %v", err)
+	}
+	found := func() int {
+		t.Helper()
+		drivers, err := repo.FindNearbyDrivers(ctx, lat, lng, "ECONOMY", 1000, 0, 1, 10, nil)
+		if err != nil {
+			t.Fatalf("FindNearbyDrivers: %v", err)
+		}
+		return len(drivers)
+	}
+
+	if err := repo.UpdateDriverStatus(ctx, id, "ON_BREAK"); err != nil {
+		t.Fatalf("start break: %v", err)
+	}
+	if n := found(); n != 0 {
+		t.Errorf("found %d drivers while on break, want none", n)
+	}
+	if err := repo.UpdateDriverStatus(ctx, id, "AVAILABLE"); err != nil {
+		t.Fatalf("end break: %v", err)
+	}
+	if n := found(); n != 1 {
+		t.Errorf("found %d drivers after the break, want 1", n)
+	}
+}
+
+func TestFindNearbyDriversSkipsExcludedDrivers(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	const lat, lng = 43.2389, 76.8897
+
+	var ids []string
+	for _, dLat := range []float64{0.001, 0.002, 0.003} {
+		id := seedDriver(t, repo)
+		if _, err := repo.SaveDriverLocation(ctx, id, lat+dLat, lng, ""); err != nil {
+			t.Fatalf("save location: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	for _, haversine := range []bool{false, true} {
+		if !haversine && !repo.hasPostGIS(ctx) {
+			continue
+		}
+		repo.useHaversine = haversine
+		found := func(exclude []string) string {
+			t.Helper()
+			drivers, err := repo.FindNearbyDrivers(ctx, lat, lng, "ECONOMY", 1000, 0, 1, 10, exclude)
+			if err != nil {
+				t.Fatalf("FindNearbyDrivers(haversine=%v): %v", haversine, err)
+			}
+			var got []string
+			for _, d := range drivers {
+				got = append(got, d.DriverID)
+			}
+			return fmt.Sprint(got)
+		}
+
+		if got := found([]string{ids[0], ids[2]}); got != fmt.Sprint(ids[1:2]) {
+			t.Errorf("haversine=%v: found %s excluding the first and last, want %v", haversine, got, ids[1:2])
+		}
+		// nil and empty both exclude no one
+		if got := found(nil); got != fmt.Sprint(ids) {
+			t.Errorf("haversine=%v: found %s with nil exclusions, want %v", haversine, got, ids)
+		}
+		if got := found([]string{}); got != fmt.Sprint(ids) {
+			t.Errorf("haversine=%v: found %s with no exclusions, want %v", haversine, got, ids)
+		}
+	}
+}
+
+func TestHaversineFallbackMatchesPostGIS(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	if !repo.hasPostGIS(ctx) {
+		t.Skip("PostGIS not installed; nothing to compare the fallback with")
+	}
+	const lat, lng = 43.2389, 76.8897
+
+	// North of the pickup at increasing distances, up to just past 5 km
+	for _, dLat := range []float64{0.001, 0.01, 0.03, 0.0448, 0.046, 0.1} {
+		id := seedDriver(t, repo)
+		if _, err := repo.SaveDriverLocation(ctx, id, lat+dLat, lng, ""); err != nil {
+			t.Fatalf("save location: %v", err)
+		}
+	}
+
+	find := func(haversine bool) []string {
+		t.Helper()
+		repo.useHaversine = haversine
+		drivers, err := repo.FindNearbyDrivers(ctx, lat, lng, "ECONOMY", 5000, 0, 1, 10, nil)
+		if err != nil {
+			t.Fatalf("FindNearbyDrivers(haversine=%v): %v", haversine, err)
+		}
+		var ids []string
+		for _, d := range drivers {
+			ids = append(ids, d.DriverID)
+		}
+		return ids
+	}
+	postgis, fallback := find(false), find(true)
+
+	if len(postgis) != 4 {
+		t.Errorf("PostGIS found %d drivers within 5 km, want 4", len(postgis))
+	}
+	if fmt.Sprint(fallback) != fmt.Sprint(postgis) {
+		t.Errorf("fallback found %v, PostGIS %v; want the same drivers in the same order", fallback, postgis)
+	}
+}
+
+func TestFindNearbyDriversReportsLatestUpdate(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	const lat, lng = 43.2389, 76.8897
+
+	id := seedDriver(t, repo)
+	if _, err := repo.SaveDriverLocation(ctx, id, lat, lng, ""); err != nil {
+		t.Fatalf("save location: %v", err)
+	}
+	var coordinateAt time.Time
+	err := repo.pool.QueryRow(ctx, `
+		SELECT updated_at FROM coordinates WHERE entity_id = $1 AND entity_type = 'driver' AND is_current
+	`, id).Scan(&coordinateAt)
+	if err != nil {
+		t.Fatalf("read coordinate: %v", err)
+	}
+
+	updatedAt := func() []time.Time {
+		t.Helper()
+		var got []time.Time
+		for _, haversine := range []bool{false, true} {
+			if !haversine && !repo.hasPostGIS(ctx) {
+				continue
+			}
+			repo.useHaversine = haversine
+			drivers, err := repo.FindNearbyDrivers(ctx, lat, lng, "ECONOMY", 1000, 0, 1, 10, nil)
+			if err != nil || len(drivers) != 1 {
+				t.Fatalf("FindNearbyDrivers(haversine=%v) = %d drivers, %v; want 1", haversine, len(drivers), err)
+			}
+			got = append(got, drivers[0].LocationUpdatedAt)
+		}
+		return got
+	}
+
+	for _, got := range updatedAt() {
+		if !got.Equal(coordinateAt) {
+			t.Errorf("last update %v, want the coordinate's %v", got, coordinateAt)
+		}
+	}
+
+	// A history point newer than the coordinate is the driver's last update
+	later := coordinateAt.Add(time.Minute)
+	_, err = repo.pool.Exec(ctx, `
+		INSERT INTO location_history (driver_id, latitude, longitude, recorded_at) VALUES ($1, $2, $3, $4)
+	`, id, lat, lng, later)
+	if err != nil {
+		t.Fatalf("seed history: %v", err)
+	}
+	for _, got := range updatedAt() {
+		if !got.Equal(later) {
+			t.Errorf("last update %v, want the history point's %v", got, later)
+		}
+	}
+}
+
+var seededRides int
+
+// seedRide adds a ride in status picked up at lat, lng and returns its ID
+func seedRide(t *testing.T, repo *PostgresDriverLocationRepository, status string, lat, lng float64) string {
+	t.Helper()
+	ctx := context.Background()
+	seededRides++
+	var passengerID, pickupID string
+	err := repo.pool.QueryRow(ctx, `
+		INSERT INTO users (email, role, password_hash) VALUES ($1, 'PASSENGER', 'x') RETURNING id
+	`, fmt.Sprintf("passenger%d@repo.test", seededRides)).Scan(&passengerID)
+	if err != nil {
+		t.Fatalf("seed passenger: %v", err)
+	}
+	err = repo.pool.QueryRow(ctx, `
+		INSERT INTO coordinates (entity_id, entity_type, address, latitude, longitude)
+		VALUES ($1, 'passenger', 'pickup', $2, $3) RETURNING id
+	`, passengerID, lat, lng).Scan(&pickupID)
+	if err != nil {
+		t.Fatalf("seed pickup: %v", err)
+	}
+	var rideID string
+	err = repo.pool.QueryRow(ctx, `
+		INSERT INTO rides (ride_number, passenger_id, vehicle_type, status, pickup_coordinate_id)
+		VALUES ($1, $2, 'ECONOMY', $3, $4) RETURNING id
+	`, fmt.Sprintf("RIDE_REPO_%04d", seededRides), passengerID, status, pickupID).Scan(&rideID)
+	if err != nil {
+		t.Fatalf("seed ride: %v", err)
+	}
+	return rideID
+}
+
+func TestCountRequestedRidesByCell(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+
+	// Three waiting in one cell, one in the next cell east
+	seedRide(t, repo, "REQUESTED", 43.2412, 76.8813)
+	seedRide(t, repo, "REQUESTED", 43.2455, 76.8861)
+	seedRide(t, repo, "REQUESTED", 43.2498, 76.8899)
+	seedRide(t, repo, "REQUESTED", 43.2431, 76.8950)
+	// Not demand: already matched, or outside the box
+	seedRide(t, repo, "MATCHED", 43.2420, 76.8820)
+	seedRide(t, repo, "REQUESTED", 43.3500, 76.8850)
+
+	box := domain.BoundingBox{MinLat: 43.20, MinLng: 76.85, MaxLat: 43.30, MaxLng: 76.95}
+	cells, err := repo.CountRequestedRidesByCell(ctx, box, 0.01)
+	if err != nil {
+		t.Fatalf("CountRequestedRidesByCell: %v", err)
+	}
+
+	want := []domain.HeatmapCell{
+		{Latitude: 43.245, Longitude: 76.885, Count: 3},
+		{Latitude: 43.245, Longitude: 76.895, Count: 1},
+	}
+	if len(cells) != len(want) {
+		t.Fatalf("cells = %+v, want %+v", cells, want)
+	}
+	for i, c := range cells {
+		w := want[i]
+		if c.Count != w.Count || math.Abs(c.Latitude-w.Latitude) > 1e-9 || math.Abs(c.Longitude-w.Longitude) > 1e-9 {
+			t.Errorf("cell %d = %+v, want %+v", i, c, w)
+		}
+	}
+}
+
+func TestGetDriverStatsComputesRates(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	driverID := seedDriver(t, repo)
+	since := time.Now().Add(-time.Hour)
+
+	respond := func(response string, at time.Time) {
+		t.Helper()
+		rideID := seedRide(t, repo, "REQUESTED", 43.24, 76.88)
+		_, err := repo.pool.Exec(ctx, `
+			INSERT INTO driver_offer_responses (driver_id, ride_id, offer_id, response, created_at)
+			VALUES ($1, $2, $3, $4, $5)
+		`, driverID, rideID, "offer-"+rideID, response, at)
+		if err != nil {
+			t.Fatalf("seed %s response: %v", response, err)
+		}
+	}
+	for _, r := range []string{"ACCEPTED", "ACCEPTED", "ACCEPTED", "REJECTED", "EXPIRED", "ACCEPT_FAILED"} {
+		respond(r, time.Now())
+	}
+	respond("REJECTED", since.Add(-time.Hour)) // before the window
+
+	// Four rides matched to the driver, three of them completed
+	for i := 0; i < 4; i++ {
+		rideID := seedRide(t, repo, "MATCHED", 43.24, 76.88)
+		_, err := repo.pool.Exec(ctx, `
+			INSERT INTO ride_events (ride_id, event_type, event_data)
+			VALUES ($1, 'DRIVER_MATCHED', jsonb_build_object('driver_id', $2::text))
+		`, rideID, driverID)
+		if err != nil {
+			t.Fatalf("seed match event: %v", err)
+		}
+		if i < 3 {
+			_, err = repo.pool.Exec(ctx, `
+				UPDATE rides SET status = 'COMPLETED', driver_id = $2, completed_at = NOW() WHERE id = $1
+			`, rideID, driverID)
+			if err != nil {
+				t.Fatalf("complete ride: %v", err)
+			}
+		}
+	}
+
+	stats, err := repo.GetDriverStats(ctx, driverID, since)
+	if err != nil {
+		t.Fatalf("GetDriverStats: %v", err)
+	}
+
+	// ACCEPT_FAILED counts neither way
+	if stats.OffersReceived != 5 || stats.OffersAccepted != 3 || stats.AcceptanceRatePercent != 60 {
+		t.Errorf("acceptance %d/%d = %v%%, want 3/5 = 60%%", stats.OffersAccepted, stats.OffersReceived, stats.AcceptanceRatePercent)
+	}
+	if stats.RidesMatched != 4 || stats.RidesCompleted != 3 || stats.CompletionRatePercent != 75 {
+		t.Errorf("completion %d/%d = %v%%, want 3/4 = 75%%", stats.RidesCompleted, stats.RidesMatched, stats.CompletionRatePercent)
+	}
+}
+
+func TestGetDriverStatsWithoutHistory(t *testing.T) {
+	repo := newTestRepo(t)
+	driverID := seedDriver(t, repo)
+
+	stats, err := repo.GetDriverStats(context.Background(), driverID, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("GetDriverStats: %v", err)
+	}
+	if stats.AcceptanceRatePercent != 0 || stats.CompletionRatePercent != 0 {
+		t.Errorf("rates = %v%%, %v%%; want 0 for a new driver", stats.AcceptanceRatePercent, stats.CompletionRatePercent)
+	}
+}
+
+func TestGetPassengerContactIsMasked(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	rideID := seedRide(t, repo, "MATCHED", 43.2390, 76.8900)
+	var passengerID string
+	err := repo.pool.QueryRow(ctx, `
+		UPDATE users SET attrs = '{"name": "Aigerim Sadykova", "phone": "+7 701 123 45 67"}'
+		WHERE id = (SELECT passenger_id FROM rides WHERE id = $1)
+		RETURNING id
+	`, rideID).Scan(&passengerID)
+	if err != nil {
+		t.Fatalf("set passenger attrs: %v", err)
+	}
+
+	contact, err := repo.GetPassengerContact(ctx, rideID)
+	if err != nil {
+		t.Fatalf("GetPassengerContact: %v", err)
+	}
+	want := domain.PassengerContact{PassengerID: passengerID, FirstName: "Aigerim", MaskedPhone: "*********67", ChatID: "ride:" + rideID}
+	if *contact != want {
+		t.Errorf("contact = %+v, want %+v", *contact, want)
+	}
+}
+
+func TestFindNearbyDriversRequiresEnoughSeats(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	const lat, lng = 43.2389, 76.8897
+
+	place := func(attrs string) string {
+		t.Helper()
+		id := seedDriver(t, repo)
+		if _, err := repo.pool.Exec(ctx, `UPDATE drivers SET vehicle_attrs = $2::jsonb WHERE id = $1`, id, attrs); err != nil {
+			t.Fatalf("set vehicle attrs: %v", err)
+		}
+		if _, err := repo.SaveDriverLocation(ctx, id, lat, lng, ""); err != nil {
+			t.Fatalf("save location: %v", err)
+		}
+		return id
+	}
+	twoSeat := place(`{"seats": 2}`)
+	fiveSeat := place(`{"seats": 5}`)
+	unknown := place(`{"color": "white"}`)
+
+	find := func(minSeats int) map[string]bool {
+		t.Helper()
+		drivers, err := repo.FindNearbyDrivers(ctx, lat, lng, "ECONOMY", 1000, 0, minSeats, 10, nil)
+		if err != nil {
+			t.Fatalf("FindNearbyDrivers(seats=%d): %v", minSeats, err)
+		}
+		found := make(map[string]bool)
+		for _, d := range drivers {
+			found[d.DriverID] = true
+		}
+		return found
+	}
+
+	paths := []bool{repo.useHaversine}
+	if !repo.useHaversine {
+		paths = append(paths, true)
+	}
+	for _, haversine := range paths {
+		repo.useHaversine = haversine
+
+		four := find(4)
+		if four[twoSeat] || !four[fiveSeat] {
+			t.Errorf("haversine=%v: 4 seats found %v, want the 5-seat but not the 2-seat vehicle", haversine, four)
+		}
+		if !four[unknown] {
+			t.Errorf("haversine=%v: 4 seats skipped a vehicle without a seat count, want it assumed to have %d", haversine, domain.DefaultVehicleSeats)
+		}
+		if one := find(1); len(one) != 3 {
+			t.Errorf("haversine=%v: 1 seat found %d drivers, want all 3", haversine, len(one))
+		}
+		if six := find(6); len(six) != 0 {
+			t.Errorf("haversine=%v: 6 seats found %v, want none", haversine, six)
+		}
+	}
+}
+
+func TestPendingOffersSurviveAReload(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	driverID := seedDriver(t, repo)
+	rideID := seedRide(t, repo, "REQUESTED", 43.2389, 76.8897)
+	expiresAt := time.Now().Add(20 * time.Second).Truncate(time.Microsecond)
+
+	offer := &domain.PendingOffer{
+		OfferID:  "offer_" + rideID + "_" + driverID,
+		RideID:   rideID,
+		DriverID: driverID,
+		Request: &domain.RideMatchingRequest{
+			RideID:         rideID,
+			RideType:       "ECONOMY",
+			PassengerCount: 3,
+			TimeoutSeconds: 30,
+		},
+		ExpiresAt: expiresAt,
+	}
+	if err := repo.SavePendingOffer(ctx, offer); err != nil {
+		t.Fatalf("SavePendingOffer: %v", err)
+	}
+	// Saving again, as a re-offer does, keeps one row
+	if err := repo.SavePendingOffer(ctx, offer); err != nil {
+		t.Fatalf("SavePendingOffer again: %v", err)
+	}
+
+	offers, err := repo.ListPendingOffers(ctx)
+	if err != nil {
+		t.Fatalf("ListPendingOffers: %v", err)
+	}
+	if len(offers) != 1 {
+		t.Fatalf("listed %d offers, want 1", len(offers))
+	}
+	got := offers[0]
+	if got.OfferID != offer.OfferID || got.RideID != rideID || got.DriverID != driverID || !got.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("reloaded %+v, want %+v", got, offer)
+	}
+	if got.Request == nil || got.Request.PassengerCount != 3 || got.Request.TimeoutSeconds != 30 {
+		t.Errorf("reloaded request %+v, want the original", got.Request)
+	}
+
+	if err := repo.DeletePendingOffers(ctx, []string{offer.OfferID}); err != nil {
+		t.Fatalf("DeletePendingOffers: %v", err)
+	}
+	if offers, err := repo.ListPendingOffers(ctx); err != nil || len(offers) != 0 {
+		t.Errorf("after delete listed %v (err %v), want none", offers, err)
+	}
+}
+
+func TestOfferTelemetryIsLoggedToRideEvents(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	rideID := seedRide(t, repo, "REQUESTED", 43.2389, 76.8897)
+	d1, d2 := seedDriver(t, repo), seedDriver(t, repo)
+
+	for _, d := range []string{d1, d2} {
+		if err := repo.RecordOfferSent(ctx, d, rideID, "offer_"+d); err != nil {
+			t.Fatalf("RecordOfferSent: %v", err)
+		}
+	}
+	if err := repo.RecordOfferResponse(ctx, d1, rideID, "offer_"+d1, domain.OfferResponseRejected); err != nil {
+		t.Fatalf("RecordOfferResponse(rejected): %v", err)
+	}
+	if err := repo.RecordOfferResponse(ctx, d2, rideID, "offer_"+d2, domain.OfferResponseAccepted); err != nil {
+		t.Fatalf("RecordOfferResponse(accepted): %v", err)
+	}
+
+	if n, err := repo.CountOffersSent(ctx, rideID); err != nil || n != 2 {
+		t.Errorf("CountOffersSent = %d, %v; want 2", n, err)
+	}
+	rows, err := repo.pool.Query(ctx, `
+		SELECT event_type, COUNT(*) FROM ride_events
+		WHERE ride_id = $1 AND event_type LIKE 'OFFER_%'
+		GROUP BY event_type
+	`, rideID)
+	if err != nil {
+		t.Fatalf("count events: %v", err)
+	}
+	defer rows.Close()
+	counts := make(map[string]int)
+	for rows.Next() {
+		var eventType string
+		var n int
+		if err := rows.Scan(&eventType, &n); err != nil {
+			t.Fatal(err)
+		}
+		counts[eventType] = n
+	}
+	want := map[string]int{"OFFER_SENT": 2, "OFFER_REJECTED": 1, "OFFER_ACCEPTED": 1}
+	if fmt.Sprint(counts) != fmt.Sprint(want) {
+		t.Errorf("offer events = %v, want %v", counts, want)
+	}
+}
+
+// seedHistory archives one point for driverID at the given time, on rideID if set
+func seedHistory(t *testing.T, repo *PostgresDriverLocationRepository, driverID, rideID string, lat float64, at time.Time) {
+	t.Helper()
+	var ride *string
+	if rideID != "" {
+		ride = &rideID
+	}
+	_, err := repo.pool.Exec(context.Background(), `
+		INSERT INTO location_history (driver_id, latitude, longitude, recorded_at, ride_id)
+		VALUES ($1, $2, 76.88, $3, $4)
+	`, driverID, lat, at, ride)
+	if err != nil {
+		t.Fatalf("seed location history: %v", err)
+	}
+}
+
+func TestGetLocationHistoryFiltersByTimeRange(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	driverID := seedDriver(t, repo)
+	other := seedDriver(t, repo)
+	rideID := seedRide(t, repo, "IN_PROGRESS", 43.24, 76.88)
+	start := time.Date(2024, 12, 16, 10, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 6; i++ {
+		ride := ""
+		if i%2 == 0 {
+			ride = rideID
+		}
+		seedHistory(t, repo, driverID, ride, 43.20+float64(i)/100, start.Add(time.Duration(i)*time.Minute))
+	}
+	seedHistory(t, repo, other, "", 43.99, start.Add(2*time.Minute))
+
+	tests := []struct {
+		name string
+		q    domain.LocationHistoryQuery
+		want []float64 // latitudes, in order
+	}{
+		{"everything", domain.LocationHistoryQuery{Limit: 100}, []float64{43.20, 43.21, 43.22, 43.23, 43.24, 43.25}},
+		{"from is inclusive", domain.LocationHistoryQuery{From: start.Add(4 * time.Minute), Limit: 100}, []float64{43.24, 43.25}},
+		{"to is exclusive", domain.LocationHistoryQuery{To: start.Add(2 * time.Minute), Limit: 100}, []float64{43.20, 43.21}},
+		{"window", domain.LocationHistoryQuery{From: start.Add(time.Minute), To: start.Add(4 * time.Minute), Limit: 100}, []float64{43.21, 43.22, 43.23}},
+		{"window on a ride", domain.LocationHistoryQuery{RideID: rideID, From: start.Add(time.Minute), Limit: 100}, []float64{43.22, 43.24}},
+		{"empty window", domain.LocationHistoryQuery{From: start.Add(time.Hour), Limit: 100}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			points, err := repo.GetLocationHistory(ctx, driverID, tt.q)
+			if err != nil {
+				t.Fatalf("GetLocationHistory: %v", err)
+			}
+			if points == nil {
+				t.Fatal("points = nil, want an empty slice")
+			}
+			got := make([]float64, len(points))
+			for i, p := range points {
+				got[i] = math.Round(p.Latitude*100) / 100
+				if p.DriverID != driverID {
+					t.Errorf("point %s belongs to %s", p.ID, p.DriverID)
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) && !(len(got) == 0 && len(tt.want) == 0) {
+				t.Errorf("latitudes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetLocationHistoryPagesWithoutGapsOrOverlap(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	driverID := seedDriver(t, repo)
+	start := time.Date(2024, 12, 16, 10, 0, 0, 0, time.UTC)
+
+	// Pairs of points share a timestamp, so only the id tie-break keeps pages stable
+	const total = 10
+	for i := 0; i < total; i++ {
+		seedHistory(t, repo, driverID, "", 43.20+float64(i)/100, start.Add(time.Duration(i/2)*time.Second))
+	}
+
+	all, err := repo.GetLocationHistory(ctx, driverID, domain.LocationHistoryQuery{Limit: 100})
+	if err != nil {
+		t.Fatalf("GetLocationHistory: %v", err)
+	}
+	if len(all) != total {
+		t.Fatalf("got %d points, want %d", len(all), total)
+	}
+
+	var paged []*domain.LocationHistory
+	for offset := 0; offset < total+3; offset += 3 {
+		page, err := repo.GetLocationHistory(ctx, driverID, domain.LocationHistoryQuery{Limit: 3, Offset: offset})
+		if err != nil {
+			t.Fatalf("page at %d: %v", offset, err)
+		}
+		if len(page) > 3 {
+			t.Fatalf("page at %d has %d points, want at most 3", offset, len(page))
+		}
+		paged = append(paged, page...)
+	}
+	if len(paged) != total {
+		t.Fatalf("paged through %d points, want %d", len(paged), total)
+	}
+	for i := range all {
+		if paged[i].ID != all[i].ID {
+			t.Fatalf("point %d = %s when paging, %s in one query", i, paged[i].ID, all[i].ID)
+		}
+		if i > 0 && paged[i].RecordedAt.Time.Before(paged[i-1].RecordedAt.Time) {
+			t.Errorf("point %d recorded before point %d", i, i-1)
+		}
+	}
+}
+
+func TestSlowQueryIsCancelledByQueryTimeout(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	driverID := seedDriver(t, repo)
+
+	// Another transaction holds the driver's row, so the update waits on it
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `SELECT id FROM drivers WHERE id = $1 FOR UPDATE`, driverID); err != nil {
+		t.Fatalf("lock driver: %v", err)
+	}
+
+	repo.queryTimeout = 100 * time.Millisecond
+	start := time.Now()
+	err = repo.UpdateDriverStatus(ctx, driverID, domain.DriverStatusOffline)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want the query timeout to cancel the update", err)
+	}
+	if took := time.Since(start); took > 5*time.Second {
+		t.Errorf("update gave up after %v, want about the 100ms timeout", took)
+	}
+}
+
+func TestConcurrentRideCompletionsAreAllCounted(t *testing.T) {
+	repo := newTestRepo(t)
+	driverID := seedDriver(t, repo)
+	ctx := context.Background()
+	sessionID, err := repo.CreateDriverSession(ctx, driverID)
+	if err != nil {
+		t.Fatalf("CreateDriverSession: %v", err)
+	}
+
+	const rides = 20
+	var wg sync.WaitGroup
+	for i := 0; i < rides; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := repo.UpdateDriverSessionStats(ctx, driverID, 1, 1450); err != nil {
+				t.Errorf("UpdateDriverSessionStats: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var totalRides, sessionRides int
+	var totalEarnings, sessionEarnings float64
+	err = repo.pool.QueryRow(ctx, `
+		SELECT d.total_rides, d.total_earnings, s.total_rides, s.total_earnings
+		FROM drivers d JOIN driver_sessions s ON s.driver_id = d.id
+		WHERE d.id = $1 AND s.id = $2
+	`, driverID, sessionID).Scan(&totalRides, &totalEarnings, &sessionRides, &sessionEarnings)
+	if err != nil {
+		t.Fatalf("read totals: %v", err)
+	}
+	if totalRides != rides || totalEarnings != rides*1450 {
+		t.Errorf("driver totals = %d rides, %v earned; want %d, %d", totalRides, totalEarnings, rides, rides*1450)
+	}
+	if sessionRides != rides || sessionEarnings != rides*1450 {
+		t.Errorf("session totals = %d rides, %v earned; want %d, %d", sessionRides, sessionEarnings, rides, rides*1450)
+	}
+}
+
+func TestValidDriverStatusMatchesTheDriverStatusTable(t *testing.T) {
+	// Every value migrations 02 and 04 insert into driver_status
+	for _, status := range []string{"OFFLINE", "AVAILABLE", "BUSY", "EN_ROUTE", "ON_BREAK"} {
+		if !validDriverStatus(status) {
+			t.Errorf("validDriverStatus(%q) = false, want true", status)
+		}
+	}
+	for _, status := range []string{"", "on_break", "DRIVING"} {
+		if validDriverStatus(status) {
+			t.Errorf("validDriverStatus(%q) = true, want false", status)
+		}
+	}
+}
+
+// nopPublisher drops everything the driver service publishes
+type nopPublisher struct{}
+
+func (nopPublisher) PublishDriverResponse(ctx context.Context, exchange, routingKey string, body []byte) error {
+	return nil
+}
+func (nopPublisher) PublishDriverStatus(ctx context.Context, exchange, routingKey string, body []byte) error {
+	return nil
+}
+func (nopPublisher) PublishLocationUpdate(ctx context.Context, exchange string, body []byte) error {
+	return nil
+}
+
+func TestLiveLocationUpdatesBuildTheRideRoute(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	driverID := seedDriver(t, repo)
+	rideID := seedRide(t, repo, "IN_PROGRESS", 43.2389, 76.8897)
+	if _, err := repo.pool.Exec(ctx, `UPDATE rides SET driver_id = $2, matched_at = NOW() WHERE id = $1`, rideID, driverID); err != nil {
+		t.Fatalf("assign ride: %v", err)
+	}
+
+	svc := app.NewDriverLocationService(dbtest.Logger{}, repo, nopPublisher{}, nil)
+	if _, err := svc.UpdateDriverLocation(ctx, driverID, 43.2400, 76.8900, 5, 30, 90, "Abay Ave 10"); err != nil {
+		t.Fatalf("UpdateDriverLocation: %v", err)
+	}
+
+	route, err := riderepo.NewPostgresRideRepository(repo.pool).FindRoute(ctx, rideID)
+	if err != nil {
+		t.Fatalf("FindRoute: %v", err)
+	}
+	if len(route) != 1 || route[0].Latitude != 43.2400 || route[0].Longitude != 76.8900 {
+		t.Errorf("route = %+v, want the reported location", route)
+	}
+}
@@ -13,14 +13,21 @@ import (
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
+// broker is the part of the RabbitMQ connection the consumer uses
+type broker interface {
+	Consume(queueName string, handler func(amqp.Delivery)) error
+	DeclareInstanceQueue(base, exchange string, routingKeys ...string) (string, error)
+	Settle(queue string, d amqp.Delivery, err error) bool
+}
+
 type DriverLocationConsumer struct {
-	conn *rabbitmq.Connection
+	conn broker
 	svc  domain.DriverLocationService
 	log  logger.Logger
 }
 
 // NewDriverLocationConsumer wires a driver-location service to the RabbitMQ connection.
-func NewDriverLocationConsumer(conn *rabbitmq.Connection, svc domain.DriverLocationService, log logger.Logger) *DriverLocationConsumer {
+func NewDriverLocationConsumer(conn broker, svc domain.DriverLocationService, log logger.Logger) *DriverLocationConsumer {
 	return &DriverLocationConsumer{
 		conn: conn,
 		svc:  svc,
@@ -40,17 +47,15 @@ func (c *DriverLocationConsumer) driverMatchingHandler(ctx context.Context) func
 		var req domain.RideMatchingRequest
 		if err := json.Unmarshal(d.Body, &req); err != nil {
 			c.log.Error("driver_matching_unmarshal_failed", err)
-			d.Nack(false, false)
+			c.conn.Settle("driver_matching", d, rabbitmq.Fatal(err))
 			return
 		}
 		fmt.Println(req)
-		if err := c.svc.HandleRideMatchingRequest(handlerCtx, &req); err != nil {
+		err := c.svc.HandleRideMatchingRequest(handlerCtx, &req)
+		if err != nil {
 			c.log.Error("driver_matching_handle_failed", err)
-			d.Nack(false, true)
-			return
 		}
-
-		d.Ack(false)
+		c.conn.Settle("driver_matching", d, err)
 	}
 }
 
@@ -75,17 +80,105 @@ func (c *DriverLocationConsumer) rideStatusHandler(ctx context.Context) func(amq
 		var msg rideStatusMessage
 		if err := json.Unmarshal(d.Body, &msg); err != nil {
 			c.log.Error("ride_status_unmarshal_failed", err)
-			d.Nack(false, false)
+			c.conn.Settle("ride_status", d, rabbitmq.Fatal(err))
 			return
 		}
 
-		if err := c.svc.HandleRideStatusUpdate(handlerCtx, msg.RideID, msg.DriverID, msg.Status, msg.FinalFare); err != nil {
+		err := c.svc.HandleRideStatusUpdate(handlerCtx, msg.RideID, msg.DriverID, msg.Status, msg.FinalFare)
+		if err != nil {
 			c.log.Error("ride_status_handle_failed", err)
-			d.Nack(false, true)
+		}
+		c.conn.Settle("ride_status", d, err)
+	}
+}
+
+// ConsumeRideMessages listens for passenger chat messages addressed to drivers.
+// The driver's WebSocket may be on any instance, so each instance reads its own
+// queue and delivers only to drivers connected to it.
+func (c *DriverLocationConsumer) ConsumeRideMessages(ctx context.Context) error {
+	queue, err := c.conn.DeclareInstanceQueue("ride_messages", "ride_topic", "ride.message.*")
+	if err != nil {
+		return err
+	}
+	return c.conn.Consume(queue, c.rideMessageHandler(ctx, queue))
+}
+
+func (c *DriverLocationConsumer) rideMessageHandler(ctx context.Context, queue string) func(amqp.Delivery) {
+	return func(d amqp.Delivery) {
+		handlerCtx := c.baseCtx(ctx)
+
+		var msg domain.RideMessage
+		if err := json.Unmarshal(d.Body, &msg); err != nil {
+			c.log.Error("ride_message_unmarshal_failed", err)
+			c.conn.Settle(queue, d, rabbitmq.Fatal(err))
 			return
 		}
 
-		d.Ack(false)
+		err := c.svc.HandleRideMessage(handlerCtx, &msg)
+		if err != nil {
+			c.log.Error("ride_message_handle_failed", err)
+		}
+		c.conn.Settle(queue, d, err)
+	}
+}
+
+// ConsumeRideDestinations listens for passengers changing the drop-off of a ride
+// in progress. Like chat, each instance reads its own queue and pushes the change
+// only to drivers connected to it.
+func (c *DriverLocationConsumer) ConsumeRideDestinations(ctx context.Context) error {
+	queue, err := c.conn.DeclareInstanceQueue("ride_destinations", "ride_topic", "ride.destination.*")
+	if err != nil {
+		return err
+	}
+	return c.conn.Consume(queue, c.rideDestinationHandler(ctx, queue))
+}
+
+func (c *DriverLocationConsumer) rideDestinationHandler(ctx context.Context, queue string) func(amqp.Delivery) {
+	return func(d amqp.Delivery) {
+		handlerCtx := c.baseCtx(ctx)
+
+		var change domain.RideDestinationChange
+		if err := json.Unmarshal(d.Body, &change); err != nil {
+			c.log.Error("ride_destination_unmarshal_failed", err)
+			c.conn.Settle(queue, d, rabbitmq.Fatal(err))
+			return
+		}
+
+		err := c.svc.HandleRideDestinationChange(handlerCtx, &change)
+		if err != nil {
+			c.log.Error("ride_destination_handle_failed", err)
+		}
+		c.conn.Settle(queue, d, err)
+	}
+}
+
+// ConsumeDriverControl listens for admin actions on drivers, such as a forced
+// offline. Each instance reads its own queue so the one holding the driver's
+// WebSocket is the one that closes it.
+func (c *DriverLocationConsumer) ConsumeDriverControl(ctx context.Context) error {
+	queue, err := c.conn.DeclareInstanceQueue("driver_control", "driver_topic", "driver.control.*")
+	if err != nil {
+		return err
+	}
+	return c.conn.Consume(queue, c.driverControlHandler(ctx, queue))
+}
+
+func (c *DriverLocationConsumer) driverControlHandler(ctx context.Context, queue string) func(amqp.Delivery) {
+	return func(d amqp.Delivery) {
+		handlerCtx := c.baseCtx(ctx)
+
+		var msg domain.DriverControl
+		if err := json.Unmarshal(d.Body, &msg); err != nil {
+			c.log.Error("driver_control_unmarshal_failed", err)
+			c.conn.Settle(queue, d, rabbitmq.Fatal(err))
+			return
+		}
+
+		err := c.svc.HandleDriverControl(handlerCtx, &msg)
+		if err != nil {
+			c.log.Error("driver_control_handle_failed", err)
+		}
+		c.conn.Settle(queue, d, err)
 	}
 }
 
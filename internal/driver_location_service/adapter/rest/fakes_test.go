@@ -0,0 +1,182 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ride-hail/internal/driver_location_service/domain"
+	"ride-hail/pkg/auth"
+	"ride-hail/pkg/dbtest"
+)
+
+const (
+	testSecret       = "test-secret"
+	testServiceToken = "service-token"
+)
+
+// fakeService answers the calls the handler tests make. Methods a test does not
+// set up fall through to the nil embedded interface and panic.
+type fakeService struct {
+	domain.DriverLocationService
+
+	currentRides map[string]*domain.CurrentRide   // driverID -> active ride
+	nearby       []*domain.NearbyDriver           // returned by FindNearbyDrivers
+	connected    map[string]bool                  // drivers with a live socket
+	offers       map[string][]*domain.DriverOffer // returned by ListDriverOffers
+	respondErr   error                            // returned by HandleDriverRideResponse
+	enRouteErr   error                            // returned by DriverEnRoute
+	startErr     error                            // returned by StartRide
+	historyErr   error                            // returned by GetLocationHistory
+	locationErr  error                            // returned by UpdateDriverLocation
+	onlineErr    error                            // returned by DriverGoOnline
+	history      []*domain.LocationHistory        // points GetLocationHistory returns
+	shifts       []*domain.ShiftSummary           // returned by ListShifts, newest first
+
+	mu        sync.Mutex
+	locations []string                      // driver IDs passed to UpdateDriverLocation
+	answers   []string                      // "driverID/offerID/accepted" per HandleDriverRideResponse
+	enRoutes  []string                      // "driverID/rideID" per DriverEnRoute
+	histories []domain.LocationHistoryQuery // GetLocationHistory queries
+	radii     []float64                     // radiusKm per FindNearbyDrivers call
+}
+
+func (s *fakeService) GetCurrentRide(ctx context.Context, driverID string) (*domain.CurrentRide, error) {
+	return s.currentRides[driverID], nil
+}
+
+func (s *fakeService) FindNearbyDrivers(ctx context.Context, latitude, longitude float64, vehicleType string, radiusKm float64, minSeats, limit int) ([]*domain.NearbyDriver, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.radii = append(s.radii, radiusKm)
+	return s.nearby, nil
+}
+
+func (s *fakeService) UpdateDriverLocation(ctx context.Context, driverID string, latitude, longitude, accuracy, speed, heading float64, address string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.locations = append(s.locations, driverID)
+	if s.locationErr != nil {
+		return "", s.locationErr
+	}
+	return "coord-1", nil
+}
+
+func (s *fakeService) DriverGoOnline(ctx context.Context, driverID string, latitude, longitude float64, address string) (string, error) {
+	if s.onlineErr != nil {
+		return "", s.onlineErr
+	}
+	return "session-1", nil
+}
+
+func (s *fakeService) IsDriverConnected(driverID string) bool {
+	return s.connected[driverID]
+}
+
+func (s *fakeService) ListDriverOffers(driverID string) []*domain.DriverOffer {
+	return s.offers[driverID]
+}
+
+func (s *fakeService) HandleDriverRideResponse(ctx context.Context, driverID, offerID, rideID string, accepted bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.answers = append(s.answers, fmt.Sprintf("%s/%s/%v", driverID, offerID, accepted))
+	return s.respondErr
+}
+
+func (s *fakeService) DriverEnRoute(ctx context.Context, driverID, rideID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enRoutes = append(s.enRoutes, driverID+"/"+rideID)
+	return s.enRouteErr
+}
+
+func (s *fakeService) StartRide(ctx context.Context, driverID, rideID string) error {
+	return s.startErr
+}
+
+func (s *fakeService) GetLocationHistory(ctx context.Context, driverID string, q domain.LocationHistoryQuery) (*domain.LocationHistoryPage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.histories = append(s.histories, q)
+	if s.historyErr != nil {
+		return nil, s.historyErr
+	}
+	points := s.history
+	if points == nil {
+		points = []*domain.LocationHistory{}
+	}
+	return &domain.LocationHistoryPage{Points: points, Limit: q.Limit, Offset: q.Offset}, nil
+}
+
+// ListShifts pages through shifts, checking limit like the service does
+func (s *fakeService) ListShifts(ctx context.Context, driverID string, limit, offset int) (*domain.ShiftPage, error) {
+	if limit < 0 || offset < 0 {
+		return nil, fmt.Errorf("%w: bad paging", domain.ErrInvalidShiftQuery)
+	}
+	if limit == 0 {
+		limit = 20
+	}
+	page := &domain.ShiftPage{Limit: limit, Offset: offset}
+	if offset < len(s.shifts) {
+		page.Shifts = s.shifts[offset:]
+	}
+	if len(page.Shifts) > limit {
+		page.Shifts, page.HasMore = page.Shifts[:limit], true
+	}
+	return page, nil
+}
+
+// newTestServer serves the handler's routes over svc
+func newTestServer(t *testing.T, svc *fakeService) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	h := NewHandler(svc, auth.NewJWTManager(testSecret, time.Hour), dbtest.Logger{})
+	h.SetServiceToken(testServiceToken)
+	h.RegisterRoutes(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// bearer returns an Authorization header value for userID in role
+func bearer(t *testing.T, userID string, role auth.Role) string {
+	t.Helper()
+	tok, err := auth.NewJWTManager(testSecret, time.Hour).GenerateToken(userID, role)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	return "Bearer " + tok
+}
+
+// get requests path with the given Authorization header
+func get(t *testing.T, srv *httptest.Server, path, authorization string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+	req.Header.Set("Authorization", authorization)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// post sends body as JSON to path with the given Authorization header
+func post(t *testing.T, srv *httptest.Server, path, authorization, body string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", authorization)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST %s: %v", path, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
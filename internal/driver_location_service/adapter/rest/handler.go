@@ -1,14 +1,21 @@
 package rest
 
 import (
+	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"ride-hail/internal/driver_location_service/domain"
+	"ride-hail/pkg/apitime"
 	"ride-hail/pkg/auth"
 	"ride-hail/pkg/logger"
+	"ride-hail/pkg/validation"
 )
 
 // Handler hosts REST endpoints for driver operations.
@@ -16,6 +23,7 @@ type Handler struct {
 	driverLocationService domain.DriverLocationService
 	log                   logger.Logger
 	jwt                   *auth.JWTManager
+	serviceToken          string // Bearer token for /internal/ endpoints; empty = closed
 }
 
 // NewHandler creates a handler with all required dependencies.
@@ -27,13 +35,124 @@ func NewHandler(dls domain.DriverLocationService, jwt *auth.JWTManager, log logg
 	}
 }
 
+// SetServiceToken sets the shared token the /internal/ endpoints require.
+// Until one is set they reject every call.
+func (h *Handler) SetServiceToken(token string) {
+	h.serviceToken = token
+}
+
 // RegisterRoutes mounts REST routes on the given router.
 func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /drivers/{driver_id}", h.HandleGetDriver)
 	mux.HandleFunc("POST /drivers/{driver_id}/online", h.HandleGoOnline)
 	mux.HandleFunc("POST /drivers/{driver_id}/offline", h.HandleGoOffline)
+	mux.HandleFunc("POST /drivers/{driver_id}/break", h.HandleStartBreak)
+	mux.HandleFunc("POST /drivers/{driver_id}/resume", h.HandleEndBreak)
 	mux.HandleFunc("POST /drivers/{driver_id}/location", h.HandleUpdateLocation)
+	mux.HandleFunc("POST /drivers/{driver_id}/locations", h.HandleBulkLocationUpdate)
+	mux.HandleFunc("GET /drivers/{driver_id}/locations", h.HandleLocationHistory)
+	mux.HandleFunc("GET /drivers/{driver_id}/shifts", h.HandleListShifts)
+	mux.HandleFunc("POST /drivers/{driver_id}/enroute", h.HandleEnRoute)
+	mux.HandleFunc("POST /drivers/{driver_id}/arrived", h.HandleArrived)
 	mux.HandleFunc("POST /drivers/{driver_id}/start", h.HandleStartRide)
 	mux.HandleFunc("POST /drivers/{driver_id}/complete", h.HandleCompleteRide)
+	mux.HandleFunc("POST /drivers/{driver_id}/rides/{ride_id}/cancel", h.HandleCancelRide)
+	mux.HandleFunc("POST /drivers/{driver_id}/rides/{ride_id}/no-show", h.HandleNoShow)
+	mux.HandleFunc("GET /drivers/{driver_id}/current-ride", h.HandleCurrentRide)
+	mux.HandleFunc("GET /drivers/{driver_id}/offers", h.HandleListOffers)
+	mux.HandleFunc("POST /drivers/{driver_id}/offers/{offer_id}/respond", h.HandleRespondToOffer)
+	mux.HandleFunc("POST /drivers/{driver_id}/offers/{offer_id}/accept", h.HandleAcceptOffer)
+	mux.HandleFunc("POST /drivers/{driver_id}/offers/{offer_id}/reject", h.HandleRejectOffer)
+	mux.HandleFunc("GET /drivers/heatmap", h.HandleHeatmap)
+	mux.HandleFunc("GET /metrics/offers", h.HandleOfferMetrics)
+
+	// Service-to-service endpoints; they require the service token, and
+	// /internal/ should stay off the public ingress as well
+	mux.HandleFunc("GET /internal/drivers/nearby", h.HandleInternalNearbyDrivers)
+	mux.HandleFunc("GET /internal/drivers/{driver_id}/connected", h.HandleInternalDriverConnected)
+}
+
+type driverSessionResponse struct {
+	SessionID      string  `json:"session_id"`
+	StartedAt      string  `json:"started_at"`
+	RidesCompleted int     `json:"rides_completed"`
+	Earnings       float64 `json:"earnings"`
+}
+
+type driverStatsResponse struct {
+	Since                 string  `json:"since"`
+	OffersReceived        int     `json:"offers_received"`
+	OffersAccepted        int     `json:"offers_accepted"`
+	AcceptanceRatePercent float64 `json:"acceptance_rate_percent"`
+	RidesMatched          int     `json:"rides_matched"`
+	RidesCompleted        int     `json:"rides_completed"`
+	CompletionRatePercent float64 `json:"completion_rate_percent"`
+}
+
+type driverResponse struct {
+	DriverID       string                 `json:"driver_id"`
+	Email          string                 `json:"email"`
+	Status         string                 `json:"status"`
+	VehicleType    string                 `json:"vehicle_type"`
+	VehicleAttrs   map[string]interface{} `json:"vehicle_attrs,omitempty"`
+	Rating         float64                `json:"rating"`
+	TotalRides     int                    `json:"total_rides"`
+	TotalEarnings  float64                `json:"total_earnings"`
+	IsVerified     bool                   `json:"is_verified"`
+	CurrentSession *driverSessionResponse `json:"current_session,omitempty"`
+	Stats          *driverStatsResponse   `json:"stats,omitempty"`
+}
+
+// HandleGetDriver returns the driver's profile with session and recent acceptance/completion rates.
+func (h *Handler) HandleGetDriver(w http.ResponseWriter, r *http.Request) {
+	driverID, err := driverIDFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid driver path")
+		return
+	}
+
+	if err := h.authenticateDriver(r, driverID); err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	driver, svcErr := h.driverLocationService.GetDriver(r.Context(), driverID)
+	if svcErr != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get driver")
+		return
+	}
+
+	resp := driverResponse{
+		DriverID:      driver.ID,
+		Email:         driver.Email,
+		Status:        driver.Status,
+		VehicleType:   driver.VehicleType,
+		VehicleAttrs:  driver.VehicleAttrs,
+		Rating:        driver.Rating,
+		TotalRides:    driver.TotalRides,
+		TotalEarnings: driver.TotalEarnings,
+		IsVerified:    driver.IsVerified,
+	}
+	if s := driver.CurrentSession; s != nil {
+		resp.CurrentSession = &driverSessionResponse{
+			SessionID:      s.ID,
+			StartedAt:      apitime.Format(s.StartedAt),
+			RidesCompleted: s.TotalRides,
+			Earnings:       s.TotalEarnings,
+		}
+	}
+	if st := driver.Stats; st != nil {
+		resp.Stats = &driverStatsResponse{
+			Since:                 apitime.Format(st.Since),
+			OffersReceived:        st.OffersReceived,
+			OffersAccepted:        st.OffersAccepted,
+			AcceptanceRatePercent: st.AcceptanceRatePercent,
+			RidesMatched:          st.RidesMatched,
+			RidesCompleted:        st.RidesCompleted,
+			CompletionRatePercent: st.CompletionRatePercent,
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
 }
 
 type onlinePayload struct {
@@ -63,17 +182,23 @@ func (h *Handler) HandleGoOnline(w http.ResponseWriter, r *http.Request) {
 
 	var p onlinePayload
 	if err := decodeJSON(r, &p); err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
+		writeError(w, decodeErrorStatus(err), err.Error())
 		return
 	}
 
-	if !validateCoordinates(p.Latitude, p.Longitude) {
-		writeError(w, http.StatusBadRequest, "invalid coordinates")
+	var errs validation.Errors
+	checkCoordinates(&errs, p.Latitude, p.Longitude)
+	if len(errs) > 0 {
+		writeValidationError(w, errs)
 		return
 	}
 
 	sessionID, svcErr := h.driverLocationService.DriverGoOnline(r.Context(), driverID, p.Latitude, p.Longitude, p.Address)
 	if svcErr != nil {
+		if errors.Is(svcErr, domain.ErrRatingTooLow) {
+			writeError(w, http.StatusForbidden, svcErr.Error())
+			return
+		}
 		h.log.Error("driver_online_failed", svcErr)
 		writeError(w, http.StatusInternalServerError, "failed to bring driver online")
 		return
@@ -87,9 +212,11 @@ func (h *Handler) HandleGoOnline(w http.ResponseWriter, r *http.Request) {
 }
 
 type offlineSessionSummary struct {
-	DurationHours  float64 `json:"duration_hours"`
-	RidesCompleted int     `json:"rides_completed"`
-	Earnings       float64 `json:"earnings"`
+	DurationHours      float64 `json:"duration_hours"`
+	RidesCompleted     int     `json:"rides_completed"`
+	Earnings           float64 `json:"earnings"`
+	UtilizationPercent float64 `json:"utilization_percent"`
+	IdleHours          float64 `json:"idle_hours"`
 }
 
 type offlineResponse struct {
@@ -119,20 +246,17 @@ func (h *Handler) HandleGoOffline(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var durationHours float64
-	if session.EndedAt != nil {
-		durationHours = session.EndedAt.Sub(session.StartedAt).Hours()
-	} else {
-		durationHours = time.Since(session.StartedAt).Hours()
-	}
+	utilization, idleHours := session.Utilization()
 
 	writeJSON(w, http.StatusOK, offlineResponse{
 		Status:    domain.DriverStatusOffline,
 		SessionID: session.ID,
 		SessionSummary: offlineSessionSummary{
-			DurationHours:  durationHours,
-			RidesCompleted: session.TotalRides,
-			Earnings:       session.TotalEarnings,
+			DurationHours:      session.Duration().Hours(),
+			RidesCompleted:     session.TotalRides,
+			Earnings:           session.TotalEarnings,
+			UtilizationPercent: utilization,
+			IdleHours:          idleHours,
 		},
 		Message: "You are now offline",
 	})
@@ -167,12 +291,17 @@ func (h *Handler) HandleUpdateLocation(w http.ResponseWriter, r *http.Request) {
 
 	var p updateLocationPayload
 	if err := decodeJSON(r, &p); err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
+		writeError(w, decodeErrorStatus(err), err.Error())
 		return
 	}
 
-	if !validateCoordinates(p.Latitude, p.Longitude) {
-		writeError(w, http.StatusBadRequest, "invalid coordinates")
+	var errs validation.Errors
+	checkCoordinates(&errs, p.Latitude, p.Longitude)
+	errs.Check(p.AccuracyMeters >= 0, "accuracy_meters", "must not be negative")
+	errs.Check(p.SpeedKmh >= 0, "speed_kmh", "must not be negative")
+	errs.Check(p.HeadingDegrees >= 0 && p.HeadingDegrees <= 360, "heading_degrees", "must be between 0 and 360")
+	if len(errs) > 0 {
+		writeValidationError(w, errs)
 		return
 	}
 
@@ -187,6 +316,10 @@ func (h *Handler) HandleUpdateLocation(w http.ResponseWriter, r *http.Request) {
 		p.Address,
 	)
 	if svcErr != nil {
+		if errors.Is(svcErr, domain.ErrLocationRateLimited) {
+			writeError(w, http.StatusTooManyRequests, svcErr.Error())
+			return
+		}
 		h.log.Error("update_location_failed", svcErr)
 		writeError(w, http.StatusInternalServerError, "failed to update driver location")
 		return
@@ -198,6 +331,187 @@ func (h *Handler) HandleUpdateLocation(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+type locationPointPayload struct {
+	Latitude       float64   `json:"latitude"`
+	Longitude      float64   `json:"longitude"`
+	AccuracyMeters float64   `json:"accuracy_meters"`
+	SpeedKmh       float64   `json:"speed_kmh"`
+	HeadingDegrees float64   `json:"heading_degrees"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+type bulkLocationPayload struct {
+	Locations []locationPointPayload `json:"locations"`
+	Address   string                 `json:"address"`
+}
+
+type bulkLocationResponse struct {
+	CoordinateID   string `json:"coordinate_id"`
+	PointsAccepted int    `json:"points_accepted"`
+	UpdatedAt      string `json:"updated_at"`
+}
+
+// HandleBulkLocationUpdate flushes a batch of buffered locations recorded while the driver was offline.
+func (h *Handler) HandleBulkLocationUpdate(w http.ResponseWriter, r *http.Request) {
+	driverID, err := driverIDFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid driver path")
+		return
+	}
+
+	if err := h.authenticateDriver(r, driverID); err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var p bulkLocationPayload
+	if err := decodeJSON(r, &p); err != nil {
+		writeError(w, decodeErrorStatus(err), err.Error())
+		return
+	}
+
+	if len(p.Locations) == 0 {
+		writeError(w, http.StatusBadRequest, "locations must not be empty")
+		return
+	}
+	if len(p.Locations) > domain.MaxLocationBatchSize {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("at most %d locations per batch", domain.MaxLocationBatchSize))
+		return
+	}
+
+	points := make([]domain.LocationUpdate, 0, len(p.Locations))
+	for i, loc := range p.Locations {
+		if !validateCoordinates(loc.Latitude, loc.Longitude) {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid coordinates at index %d", i))
+			return
+		}
+		if loc.Timestamp.IsZero() {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("timestamp is required at index %d", i))
+			return
+		}
+		if i > 0 && loc.Timestamp.Before(p.Locations[i-1].Timestamp) {
+			writeError(w, http.StatusBadRequest, "locations must be ordered by timestamp")
+			return
+		}
+		points = append(points, domain.LocationUpdate{
+			DriverID:       driverID,
+			Latitude:       loc.Latitude,
+			Longitude:      loc.Longitude,
+			AccuracyMeters: loc.AccuracyMeters,
+			SpeedKmh:       loc.SpeedKmh,
+			HeadingDegrees: loc.HeadingDegrees,
+			Timestamp:      loc.Timestamp,
+		})
+	}
+
+	coordinateID, svcErr := h.driverLocationService.UpdateDriverLocationBatch(r.Context(), driverID, points, p.Address)
+	if svcErr != nil {
+		h.log.Error("update_location_batch_failed", svcErr)
+		writeError(w, http.StatusInternalServerError, "failed to update driver locations")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, bulkLocationResponse{
+		CoordinateID:   coordinateID,
+		PointsAccepted: len(points),
+		UpdatedAt:      nowISO(),
+	})
+}
+
+type enRouteResponse struct {
+	RideID  string `json:"ride_id"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// HandleEnRoute marks a matched driver as heading to the pickup.
+func (h *Handler) HandleEnRoute(w http.ResponseWriter, r *http.Request) {
+	driverID, err := driverIDFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid driver path")
+		return
+	}
+
+	if err := h.authenticateDriver(r, driverID); err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var p startRidePayload
+	if err := decodeJSON(r, &p); err != nil {
+		writeError(w, decodeErrorStatus(err), err.Error())
+		return
+	}
+
+	if p.RideID == "" {
+		writeError(w, http.StatusBadRequest, "ride_id is required")
+		return
+	}
+
+	if svcErr := h.driverLocationService.DriverEnRoute(r.Context(), driverID, p.RideID); svcErr != nil {
+		switch {
+		case errors.Is(svcErr, domain.ErrRideNotAssigned):
+			writeError(w, http.StatusNotFound, svcErr.Error())
+		case errors.Is(svcErr, domain.ErrRideNotMatched):
+			writeError(w, http.StatusConflict, svcErr.Error())
+		default:
+			h.log.Error("driver_en_route_failed", svcErr)
+			writeError(w, http.StatusInternalServerError, "failed to update ride")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, enRouteResponse{
+		RideID:  p.RideID,
+		Status:  domain.DriverStatusEnRoute,
+		Message: "Passenger notified that you are on the way",
+	})
+}
+
+// HandleArrived marks the driver as waiting at the pickup.
+func (h *Handler) HandleArrived(w http.ResponseWriter, r *http.Request) {
+	driverID, err := driverIDFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid driver path")
+		return
+	}
+
+	if err := h.authenticateDriver(r, driverID); err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var p startRidePayload
+	if err := decodeJSON(r, &p); err != nil {
+		writeError(w, decodeErrorStatus(err), err.Error())
+		return
+	}
+
+	if p.RideID == "" {
+		writeError(w, http.StatusBadRequest, "ride_id is required")
+		return
+	}
+
+	if svcErr := h.driverLocationService.DriverArrived(r.Context(), driverID, p.RideID); svcErr != nil {
+		switch {
+		case errors.Is(svcErr, domain.ErrRideNotAssigned):
+			writeError(w, http.StatusNotFound, svcErr.Error())
+		case errors.Is(svcErr, domain.ErrRideNotPickingUp):
+			writeError(w, http.StatusConflict, svcErr.Error())
+		default:
+			h.log.Error("driver_arrived_failed", svcErr)
+			writeError(w, http.StatusInternalServerError, "failed to update ride")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, enRouteResponse{
+		RideID:  p.RideID,
+		Status:  "ARRIVED",
+		Message: "Passenger notified that you have arrived",
+	})
+}
+
 type startRidePayload struct {
 	RideID string `json:"ride_id"`
 }
@@ -224,7 +538,7 @@ func (h *Handler) HandleStartRide(w http.ResponseWriter, r *http.Request) {
 
 	var p startRidePayload
 	if err := decodeJSON(r, &p); err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
+		writeError(w, decodeErrorStatus(err), err.Error())
 		return
 	}
 
@@ -234,8 +548,15 @@ func (h *Handler) HandleStartRide(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if svcErr := h.driverLocationService.StartRide(r.Context(), driverID, p.RideID); svcErr != nil {
-		h.log.Error("start_ride_failed", svcErr)
-		writeError(w, http.StatusInternalServerError, "failed to start ride")
+		switch {
+		case errors.Is(svcErr, domain.ErrRideNotAssigned):
+			writeError(w, http.StatusNotFound, svcErr.Error())
+		case errors.Is(svcErr, domain.ErrRideNotStartable):
+			writeError(w, http.StatusConflict, svcErr.Error())
+		default:
+			h.log.Error("start_ride_failed", svcErr)
+			writeError(w, http.StatusInternalServerError, "failed to start ride")
+		}
 		return
 	}
 
@@ -258,11 +579,17 @@ type completeRidePayload struct {
 }
 
 type completeRideResponse struct {
-	RideID         string  `json:"ride_id"`
-	Status         string  `json:"status"`
-	CompletedAt    string  `json:"completed_at"`
-	DriverEarnings float64 `json:"driver_earnings"`
-	Message        string  `json:"message"`
+	RideID              string  `json:"ride_id"`
+	Status              string  `json:"status"`
+	CompletedAt         string  `json:"completed_at"`
+	DriverEarnings      float64 `json:"driver_earnings"`
+	EarningsPreview     float64 `json:"earnings_preview"`    // As shown in the ride offer
+	DistanceAdjustment  float64 `json:"distance_adjustment"` // driver_earnings - earnings_preview
+	EstimatedFare       float64 `json:"estimated_fare"`
+	FinalFare           float64 `json:"final_fare"`
+	EstimatedDistanceKm float64 `json:"estimated_distance_km"`
+	ActualDistanceKm    float64 `json:"actual_distance_km"`
+	Message             string  `json:"message"`
 }
 
 // HandleCompleteRide finalises a ride and records metrics.
@@ -280,7 +607,7 @@ func (h *Handler) HandleCompleteRide(w http.ResponseWriter, r *http.Request) {
 
 	var p completeRidePayload
 	if err := decodeJSON(r, &p); err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
+		writeError(w, decodeErrorStatus(err), err.Error())
 		return
 	}
 
@@ -308,45 +635,736 @@ func (h *Handler) HandleCompleteRide(w http.ResponseWriter, r *http.Request) {
 	}
 
 	writeJSON(w, http.StatusOK, completeRideResponse{
-		RideID:         p.RideID,
-		Status:         domain.DriverStatusAvailable,
-		CompletedAt:    nowISO(),
-		DriverEarnings: earnings,
-		Message:        "Ride completed successfully",
+		RideID:              p.RideID,
+		Status:              domain.DriverStatusAvailable,
+		CompletedAt:         nowISO(),
+		DriverEarnings:      earnings.Earnings,
+		EarningsPreview:     earnings.PreviewEarnings,
+		DistanceAdjustment:  earnings.DistanceAdjustment,
+		EstimatedFare:       earnings.EstimatedFare,
+		FinalFare:           earnings.FinalFare,
+		EstimatedDistanceKm: earnings.EstimatedDistanceKm,
+		ActualDistanceKm:    earnings.ActualDistanceKm,
+		Message:             "Ride completed successfully",
+	})
+}
+
+type breakResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// HandleStartBreak pauses ride offers while keeping the driver's session open.
+func (h *Handler) HandleStartBreak(w http.ResponseWriter, r *http.Request) {
+	h.handleBreak(w, r, h.driverLocationService.DriverStartBreak, breakResponse{
+		Status:  domain.DriverStatusOnBreak,
+		Message: "You are on break and won't receive ride offers",
+	})
+}
+
+// HandleEndBreak makes the driver eligible for ride offers again.
+func (h *Handler) HandleEndBreak(w http.ResponseWriter, r *http.Request) {
+	h.handleBreak(w, r, h.driverLocationService.DriverEndBreak, breakResponse{
+		Status:  domain.DriverStatusAvailable,
+		Message: "You are back online and ready to accept rides",
+	})
+}
+
+func (h *Handler) handleBreak(w http.ResponseWriter, r *http.Request, action func(context.Context, string) error, resp breakResponse) {
+	driverID, err := driverIDFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid driver path")
+		return
+	}
+
+	if err := h.authenticateDriver(r, driverID); err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	if svcErr := action(r.Context(), driverID); svcErr != nil {
+		h.log.Error("driver_break_failed", svcErr)
+		switch {
+		case errors.Is(svcErr, domain.ErrNoActiveSession),
+			errors.Is(svcErr, domain.ErrDriverNotAvailable),
+			errors.Is(svcErr, domain.ErrDriverNotOnBreak):
+			writeError(w, http.StatusConflict, svcErr.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, "failed to change driver status")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+type currentRideResponse struct {
+	RideID        string                  `json:"ride_id"`
+	RideNumber    string                  `json:"ride_number"`
+	Status        string                  `json:"status"`
+	Pickup        domain.Location         `json:"pickup_location"`
+	Destination   domain.Location         `json:"destination_location"`
+	Passenger     domain.PassengerContact `json:"passenger"`
+	EstimatedFare float64                 `json:"estimated_fare"`
+	MatchedAt     string                  `json:"matched_at,omitempty"`
+}
+
+// HandleCurrentRide returns the driver's active ride, or 204 when they have none.
+func (h *Handler) HandleCurrentRide(w http.ResponseWriter, r *http.Request) {
+	driverID, err := driverIDFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid driver path")
+		return
+	}
+
+	if err := h.authenticateDriver(r, driverID); err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	ride, svcErr := h.driverLocationService.GetCurrentRide(r.Context(), driverID)
+	if svcErr != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get current ride")
+		return
+	}
+	if ride == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	resp := currentRideResponse{
+		RideID:        ride.RideID,
+		RideNumber:    ride.RideNumber,
+		Status:        ride.Status,
+		Pickup:        ride.Pickup,
+		Destination:   ride.Destination,
+		Passenger:     ride.Passenger,
+		EstimatedFare: ride.EstimatedFare,
+	}
+	if ride.MatchedAt != nil {
+		resp.MatchedAt = apitime.Format(*ride.MatchedAt)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+type offerResponse struct {
+	OfferID             string          `json:"offer_id"`
+	RideID              string          `json:"ride_id"`
+	RideNumber          string          `json:"ride_number"`
+	PickupLocation      domain.Location `json:"pickup_location"`
+	DestinationLocation domain.Location `json:"destination_location"`
+	EstimatedFare       float64         `json:"estimated_fare"`
+	DriverEarnings      float64         `json:"driver_earnings"`
+	ExpiresAt           string          `json:"expires_at"`
+}
+
+// HandleListOffers lists the driver's unanswered offers so a client that missed a
+// WebSocket push can poll for them: GET /drivers/{driver_id}/offers
+func (h *Handler) HandleListOffers(w http.ResponseWriter, r *http.Request) {
+	driverID, err := driverIDFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid driver path")
+		return
+	}
+
+	if err := h.authenticateDriver(r, driverID); err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	offers := h.driverLocationService.ListDriverOffers(driverID)
+	resp := make([]offerResponse, 0, len(offers))
+	for _, o := range offers {
+		resp = append(resp, offerResponse{
+			OfferID:             o.OfferID,
+			RideID:              o.RideID,
+			RideNumber:          o.RideNumber,
+			PickupLocation:      o.PickupLocation,
+			DestinationLocation: o.DestinationLocation,
+			EstimatedFare:       o.EstimatedFare,
+			DriverEarnings:      o.DriverEarnings,
+			ExpiresAt:           apitime.Format(o.ExpiresAt),
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"offers": resp})
+}
+
+type respondToOfferPayload struct {
+	Accepted *bool `json:"accepted"`
+}
+
+// HandleRespondToOffer accepts or rejects an offer over REST, the fallback for
+// the WebSocket ride_response: POST /drivers/{driver_id}/offers/{offer_id}/respond
+func (h *Handler) HandleRespondToOffer(w http.ResponseWriter, r *http.Request) {
+	driverID, err := driverIDFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid driver path")
+		return
+	}
+
+	if err := h.authenticateDriver(r, driverID); err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var p respondToOfferPayload
+	if err := decodeJSON(r, &p); err != nil {
+		writeError(w, decodeErrorStatus(err), err.Error())
+		return
+	}
+	if p.Accepted == nil {
+		writeError(w, http.StatusBadRequest, "accepted is required")
+		return
+	}
+
+	h.respondToOffer(w, r, driverID, *p.Accepted)
+}
+
+// HandleAcceptOffer accepts an offer over REST, independent of the driver's
+// WebSocket: POST /drivers/{driver_id}/offers/{offer_id}/accept
+func (h *Handler) HandleAcceptOffer(w http.ResponseWriter, r *http.Request) {
+	h.handleOfferDecision(w, r, true)
+}
+
+// HandleRejectOffer declines an offer over REST: POST /drivers/{driver_id}/offers/{offer_id}/reject
+func (h *Handler) HandleRejectOffer(w http.ResponseWriter, r *http.Request) {
+	h.handleOfferDecision(w, r, false)
+}
+
+// handleOfferDecision serves the body-less accept and reject routes
+func (h *Handler) handleOfferDecision(w http.ResponseWriter, r *http.Request, accepted bool) {
+	driverID, err := driverIDFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid driver path")
+		return
+	}
+
+	if err := h.authenticateDriver(r, driverID); err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	h.respondToOffer(w, r, driverID, accepted)
+}
+
+// respondToOffer answers the path's offer for an authenticated driver. Offers
+// belonging to another driver are reported as not found; offers that expired or
+// were withdrawn before the answer arrived are a conflict.
+func (h *Handler) respondToOffer(w http.ResponseWriter, r *http.Request, driverID string, accepted bool) {
+	offerID := r.PathValue("offer_id")
+	if offerID == "" {
+		writeError(w, http.StatusBadRequest, "offer_id is required")
+		return
+	}
+
+	if svcErr := h.driverLocationService.HandleDriverRideResponse(r.Context(), driverID, offerID, "", accepted); svcErr != nil {
+		switch {
+		case errors.Is(svcErr, domain.ErrOfferNotFound):
+			writeError(w, http.StatusNotFound, svcErr.Error())
+		case errors.Is(svcErr, domain.ErrOfferExpired), errors.Is(svcErr, domain.ErrDriverAssigned):
+			writeError(w, http.StatusConflict, svcErr.Error())
+		default:
+			h.log.Error("driver_offer_response_failed", svcErr)
+			writeError(w, http.StatusInternalServerError, "failed to respond to offer")
+		}
+		return
+	}
+
+	status := "REJECTED"
+	if accepted {
+		status = "ACCEPTED"
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"offer_id": offerID,
+		"status":   status,
+	})
+}
+
+type cancelRidePayload struct {
+	Reason string `json:"reason"`
+}
+
+type cancelRideResponse struct {
+	RideID      string `json:"ride_id"`
+	Status      string `json:"status"`
+	CancelledBy string `json:"cancelled_by"`
+	CancelledAt string `json:"cancelled_at"`
+	Message     string `json:"message"`
+}
+
+// HandleCancelRide lets a driver abandon a ride they accepted.
+func (h *Handler) HandleCancelRide(w http.ResponseWriter, r *http.Request) {
+	driverID, err := driverIDFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid driver path")
+		return
+	}
+
+	if err := h.authenticateDriver(r, driverID); err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	rideID := r.PathValue("ride_id")
+	if rideID == "" {
+		writeError(w, http.StatusBadRequest, "ride_id is required")
+		return
+	}
+
+	// Body is optional; only a reason may be supplied
+	var p cancelRidePayload
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &p); err != nil {
+			writeError(w, decodeErrorStatus(err), err.Error())
+			return
+		}
+	}
+	if p.Reason == "" {
+		p.Reason = "Cancelled by driver"
+	}
+
+	if svcErr := h.driverLocationService.CancelRide(r.Context(), driverID, rideID, p.Reason); svcErr != nil {
+		h.log.Error("driver_cancel_ride_failed", svcErr)
+		switch {
+		case errors.Is(svcErr, domain.ErrRideNotAssigned):
+			writeError(w, http.StatusNotFound, svcErr.Error())
+		case errors.Is(svcErr, domain.ErrRideNotCancellable):
+			writeError(w, http.StatusConflict, svcErr.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, "failed to cancel ride")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, cancelRideResponse{
+		RideID:      rideID,
+		Status:      "CANCELLED",
+		CancelledBy: domain.CancelledByDriver,
+		CancelledAt: nowISO(),
+		Message:     "Ride cancelled successfully",
+	})
+}
+
+type noShowResponse struct {
+	RideID         string  `json:"ride_id"`
+	Status         string  `json:"status"`
+	Reason         string  `json:"reason"`
+	NoShowFee      float64 `json:"no_show_fee"`
+	DriverEarnings float64 `json:"driver_earnings"`
+	CancelledAt    string  `json:"cancelled_at"`
+	Message        string  `json:"message"`
+}
+
+// HandleNoShow lets a driver waiting at the pickup cancel a ride whose passenger never came.
+func (h *Handler) HandleNoShow(w http.ResponseWriter, r *http.Request) {
+	driverID, err := driverIDFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid driver path")
+		return
+	}
+
+	if err := h.authenticateDriver(r, driverID); err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	rideID := r.PathValue("ride_id")
+	if rideID == "" {
+		writeError(w, http.StatusBadRequest, "ride_id is required")
+		return
+	}
+
+	noShow, svcErr := h.driverLocationService.ReportPassengerNoShow(r.Context(), driverID, rideID)
+	if svcErr != nil {
+		switch {
+		case errors.Is(svcErr, domain.ErrRideNotAssigned):
+			writeError(w, http.StatusNotFound, svcErr.Error())
+		case errors.Is(svcErr, domain.ErrRideNotArrived), errors.Is(svcErr, domain.ErrNoShowTooEarly):
+			writeError(w, http.StatusConflict, svcErr.Error())
+		default:
+			h.log.Error("driver_no_show_failed", svcErr)
+			writeError(w, http.StatusInternalServerError, "failed to report no-show")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, noShowResponse{
+		RideID:         noShow.RideID,
+		Status:         "CANCELLED",
+		Reason:         domain.ReasonPassengerNoShow,
+		NoShowFee:      noShow.Fee,
+		DriverEarnings: noShow.Earnings,
+		CancelledAt:    apitime.Format(noShow.CancelledAt),
+		Message:        "Ride cancelled, passenger charged a no-show fee",
 	})
 }
 
 func (h *Handler) authenticateDriver(r *http.Request, driverID string) error {
-	token, err := extractBearerToken(r)
+	claims, err := h.authenticateDriverRole(r)
 	if err != nil {
 		return err
 	}
 
+	if claims.UserID != driverID {
+		return fmt.Errorf("token does not belong to driver %s", driverID)
+	}
+
+	return nil
+}
+
+// authenticateDriverRole accepts any valid driver token, for endpoints not scoped to one driver
+func (h *Handler) authenticateDriverRole(r *http.Request) (*auth.AppClaims, error) {
+	token, err := extractBearerToken(r)
+	if err != nil {
+		return nil, err
+	}
+
 	if h.jwt == nil {
-		return fmt.Errorf("jwt manager not configured")
+		return nil, fmt.Errorf("jwt manager not configured")
 	}
 
 	claims, err := h.jwt.ParseToken(token)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if claims.Role != auth.RoleDriver {
-		return fmt.Errorf("token not issued for driver role")
+		return nil, fmt.Errorf("token not issued for driver role")
 	}
 
-	if claims.UserID != driverID {
-		return fmt.Errorf("token does not belong to driver %s", driverID)
-	}
+	return claims, nil
+}
 
+// authenticateService checks the shared service token of an /internal/ call
+func (h *Handler) authenticateService(r *http.Request) error {
+	if h.serviceToken == "" {
+		return fmt.Errorf("internal endpoints are disabled")
+	}
+	token, err := extractBearerToken(r)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.serviceToken)) != 1 {
+		return fmt.Errorf("invalid service token")
+	}
 	return nil
 }
 
 func decodeJSON(r *http.Request, v interface{}) error {
+	if err := requireJSON(r); err != nil {
+		return err
+	}
 	dec := json.NewDecoder(r.Body)
 	dec.DisallowUnknownFields()
 	if err := dec.Decode(v); err != nil {
-		return fmt.Errorf("invalid json payload: %w", err)
+		// The message goes straight into the 400 response
+		return errors.New(validation.DecodeErrorMessage(err))
 	}
 	return nil
 }
+
+// HandleLocationHistory pages through the driver's archived locations:
+// GET /drivers/{driver_id}/locations?ride_id=..&from=..&to=..&limit=..&offset=..
+// from/to are RFC 3339 timestamps; from is inclusive and to exclusive.
+func (h *Handler) HandleLocationHistory(w http.ResponseWriter, r *http.Request) {
+	driverID, err := driverIDFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid driver path")
+		return
+	}
+
+	if err := h.authenticateDriver(r, driverID); err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	q := r.URL.Query()
+	query := domain.LocationHistoryQuery{RideID: q.Get("ride_id")}
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{
+		{"from", &query.From},
+		{"to", &query.To},
+	} {
+		raw := q.Get(p.name)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("%s must be an RFC 3339 timestamp", p.name))
+			return
+		}
+		*p.dst = t
+	}
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{
+		{"limit", &query.Limit},
+		{"offset", &query.Offset},
+	} {
+		raw := q.Get(p.name)
+		if raw == "" {
+			continue
+		}
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("%s must be an integer", p.name))
+			return
+		}
+		*p.dst = v
+	}
+
+	page, svcErr := h.driverLocationService.GetLocationHistory(r.Context(), driverID, query)
+	if svcErr != nil {
+		if errors.Is(svcErr, domain.ErrInvalidHistoryQuery) {
+			writeError(w, http.StatusBadRequest, svcErr.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to get location history")
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+type shiftResponse struct {
+	SessionID          string  `json:"session_id"`
+	StartedAt          string  `json:"started_at"`
+	EndedAt            string  `json:"ended_at"`
+	DurationHours      float64 `json:"duration_hours"`
+	RidesCompleted     int     `json:"rides_completed"`
+	Earnings           float64 `json:"earnings"`
+	UtilizationPercent float64 `json:"utilization_percent"`
+	IdleHours          float64 `json:"idle_hours"`
+}
+
+type shiftPageResponse struct {
+	Shifts  []shiftResponse `json:"shifts"`
+	Limit   int             `json:"limit"`
+	Offset  int             `json:"offset"`
+	HasMore bool            `json:"has_more"`
+}
+
+// HandleListShifts lists the driver's past shifts, newest first:
+// GET /drivers/{driver_id}/shifts[?limit=20&offset=0]
+func (h *Handler) HandleListShifts(w http.ResponseWriter, r *http.Request) {
+	driverID, err := driverIDFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid driver path")
+		return
+	}
+
+	if err := h.authenticateDriver(r, driverID); err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	q := r.URL.Query()
+	var limit, offset int
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{
+		{"limit", &limit},
+		{"offset", &offset},
+	} {
+		raw := q.Get(p.name)
+		if raw == "" {
+			continue
+		}
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("%s must be an integer", p.name))
+			return
+		}
+		*p.dst = v
+	}
+
+	page, svcErr := h.driverLocationService.ListShifts(r.Context(), driverID, limit, offset)
+	if svcErr != nil {
+		if errors.Is(svcErr, domain.ErrInvalidShiftQuery) {
+			writeError(w, http.StatusBadRequest, svcErr.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to list shifts")
+		return
+	}
+
+	resp := shiftPageResponse{
+		Shifts:  make([]shiftResponse, 0, len(page.Shifts)),
+		Limit:   page.Limit,
+		Offset:  page.Offset,
+		HasMore: page.HasMore,
+	}
+	for _, s := range page.Shifts {
+		utilization, idleHours := s.Utilization()
+		resp.Shifts = append(resp.Shifts, shiftResponse{
+			SessionID:          s.SessionID,
+			StartedAt:          apitime.Format(s.StartedAt),
+			EndedAt:            apitime.Format(s.EndedAt),
+			DurationHours:      s.Duration().Hours(),
+			RidesCompleted:     s.RidesCompleted,
+			Earnings:           s.Earnings,
+			UtilizationPercent: utilization,
+			IdleHours:          idleHours,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleOfferMetrics reports the size of the pending ride offer map.
+func (h *Handler) HandleOfferMetrics(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.driverLocationService.OfferStats())
+}
+
+// HandleHeatmap returns counts of rides waiting for a driver, bucketed into grid cells:
+// GET /drivers/heatmap?min_lat=..&min_lng=..&max_lat=..&max_lng=..[&cell_size=..]
+func (h *Handler) HandleHeatmap(w http.ResponseWriter, r *http.Request) {
+	if _, err := h.authenticateDriverRole(r); err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	q := r.URL.Query()
+	var box domain.BoundingBox
+	var cellSize float64
+	for _, p := range []struct {
+		name     string
+		dst      *float64
+		optional bool
+	}{
+		{"min_lat", &box.MinLat, false},
+		{"min_lng", &box.MinLng, false},
+		{"max_lat", &box.MaxLat, false},
+		{"max_lng", &box.MaxLng, false},
+		{"cell_size", &cellSize, true},
+	} {
+		raw := q.Get(p.name)
+		if raw == "" && p.optional {
+			continue
+		}
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("%s must be a number", p.name))
+			return
+		}
+		*p.dst = v
+	}
+
+	heatmap, svcErr := h.driverLocationService.GetDemandHeatmap(r.Context(), box, cellSize)
+	if svcErr != nil {
+		if errors.Is(svcErr, domain.ErrInvalidHeatmapArea) {
+			writeError(w, http.StatusBadRequest, svcErr.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to build heatmap")
+		return
+	}
+	writeJSON(w, http.StatusOK, heatmap)
+}
+
+type nearbyDriverResponse struct {
+	DriverID         string       `json:"driver_id"`
+	Rating           float64      `json:"rating"`
+	Latitude         float64      `json:"latitude"`
+	Longitude        float64      `json:"longitude"`
+	DistanceKm       float64      `json:"distance_km"`
+	LastUpdatedAt    apitime.Time `json:"last_updated_at"`
+	StalenessSeconds float64      `json:"staleness_seconds"`
+	Connected        bool         `json:"connected"`
+	VehicleType      string       `json:"vehicle_type"`
+}
+
+// HandleInternalNearbyDrivers lists available drivers near a point with their connection state:
+// GET /internal/drivers/nearby?latitude=..&longitude=..&vehicle_type=..[&radius_km=5&limit=10]
+// A missing radius_km uses the configured default; one above the maximum is clamped.
+func (h *Handler) HandleInternalNearbyDrivers(w http.ResponseWriter, r *http.Request) {
+	if err := h.authenticateService(r); err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	q := r.URL.Query()
+	lat, latErr := strconv.ParseFloat(q.Get("latitude"), 64)
+	lng, lngErr := strconv.ParseFloat(q.Get("longitude"), 64)
+	if latErr != nil || lngErr != nil || !validateCoordinates(lat, lng) {
+		writeError(w, http.StatusBadRequest, "invalid coordinates")
+		return
+	}
+	vehicleType := q.Get("vehicle_type")
+	if vehicleType == "" {
+		writeError(w, http.StatusBadRequest, "vehicle_type is required")
+		return
+	}
+
+	var radiusKm float64 // 0 = the service's default
+	if v := q.Get("radius_km"); v != "" {
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil || !(parsed > 0) || math.IsInf(parsed, 0) {
+			writeError(w, http.StatusBadRequest, "radius_km must be a positive number")
+			return
+		}
+		radiusKm = parsed
+	}
+	limit := 10
+	if v := q.Get("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 || parsed > 100 {
+			writeError(w, http.StatusBadRequest, "limit must be between 1 and 100")
+			return
+		}
+		limit = parsed
+	}
+
+	seats := 1
+	if v := q.Get("seats"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 {
+			writeError(w, http.StatusBadRequest, "seats must be a positive integer")
+			return
+		}
+		seats = parsed
+	}
+
+	drivers, svcErr := h.driverLocationService.FindNearbyDrivers(r.Context(), lat, lng, vehicleType, radiusKm, seats, limit)
+	if svcErr != nil {
+		writeError(w, http.StatusInternalServerError, "failed to find nearby drivers")
+		return
+	}
+
+	resp := make([]nearbyDriverResponse, 0, len(drivers))
+	for _, d := range drivers {
+		resp = append(resp, nearbyDriverResponse{
+			DriverID:         d.DriverID,
+			Rating:           d.Rating,
+			Latitude:         d.Latitude,
+			Longitude:        d.Longitude,
+			DistanceKm:       d.DistanceKm,
+			LastUpdatedAt:    apitime.New(d.LocationUpdatedAt),
+			StalenessSeconds: math.Round(d.StalenessSeconds),
+			Connected:        d.Connected,
+			VehicleType:      vehicleType,
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"drivers": resp})
+}
+
+// HandleInternalDriverConnected reports whether a driver has a live WebSocket.
+func (h *Handler) HandleInternalDriverConnected(w http.ResponseWriter, r *http.Request) {
+	if err := h.authenticateService(r); err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	driverID, err := driverIDFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid driver path")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"driver_id": driverID,
+		"connected": h.driverLocationService.IsDriverConnected(driverID),
+	})
+}
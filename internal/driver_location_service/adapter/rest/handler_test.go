@@ -0,0 +1,184 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"ride-hail/internal/driver_location_service/domain"
+	"ride-hail/pkg/auth"
+)
+
+func TestCurrentRideReturnsAssignment(t *testing.T) {
+	matchedAt := time.Date(2024, 12, 16, 10, 0, 0, 0, time.UTC)
+	srv := newTestServer(t, &fakeService{currentRides: map[string]*domain.CurrentRide{
+		"driver-1": {
+			RideID:        "ride-1",
+			RideNumber:    "RIDE_20241216_001",
+			Status:        "EN_ROUTE",
+			PassengerID:   "passenger-1",
+			Passenger:     domain.PassengerContact{PassengerID: "passenger-1", FirstName: "Aigerim", MaskedPhone: "+7 *** *** 12 34", ChatID: "ride-1"},
+			Pickup:        domain.Location{Lat: 43.2389, Lng: 76.8897, Address: "Abay Ave 10"},
+			Destination:   domain.Location{Lat: 43.2220, Lng: 76.8512, Address: "Dostyk Ave 5"},
+			EstimatedFare: 1450,
+			MatchedAt:     &matchedAt,
+		},
+	}})
+
+	resp := get(t, srv, "/drivers/driver-1/current-ride", bearer(t, "driver-1", auth.RoleDriver))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	var got currentRideResponse
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.RideID != "ride-1" || got.Status != "EN_ROUTE" || got.EstimatedFare != 1450 {
+		t.Errorf("ride = %+v, want ride-1 EN_ROUTE for 1450", got)
+	}
+	if got.Pickup.Address != "Abay Ave 10" || got.Destination.Address != "Dostyk Ave 5" {
+		t.Errorf("pickup %+v, destination %+v; want the ride's", got.Pickup, got.Destination)
+	}
+	if got.Passenger.FirstName != "Aigerim" || got.Passenger.MaskedPhone != "+7 *** *** 12 34" {
+		t.Errorf("passenger = %+v, want their contact", got.Passenger)
+	}
+	if got.MatchedAt == "" {
+		t.Error("matched_at missing")
+	}
+}
+
+func TestCurrentRideIdleDriverGetsNoContent(t *testing.T) {
+	srv := newTestServer(t, &fakeService{})
+
+	resp := get(t, srv, "/drivers/driver-1/current-ride", bearer(t, "driver-1", auth.RoleDriver))
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("status = %d, want 204", resp.StatusCode)
+	}
+}
+
+func TestCurrentRideOnlyForTheDriver(t *testing.T) {
+	srv := newTestServer(t, &fakeService{currentRides: map[string]*domain.CurrentRide{
+		"driver-1": {RideID: "ride-1", Status: "MATCHED"},
+	}})
+
+	tests := []struct {
+		name, authorization string
+	}{
+		{"no token", ""},
+		{"another driver", bearer(t, "driver-2", auth.RoleDriver)},
+		{"passenger", bearer(t, "driver-1", auth.RolePassenger)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := get(t, srv, "/drivers/driver-1/current-ride", tt.authorization)
+			if resp.StatusCode != http.StatusUnauthorized {
+				t.Errorf("status = %d, want 401", resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestEnRoute(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		enRouteErr error
+		wantStatus int
+		wantCalled bool
+	}{
+		{"matched ride", `{"ride_id": "ride-1"}`, nil, http.StatusOK, true},
+		{"missing ride", `{}`, nil, http.StatusBadRequest, false},
+		{"not the driver's ride", `{"ride_id": "ride-1"}`, domain.ErrRideNotAssigned, http.StatusNotFound, true},
+		{"past the pickup leg", `{"ride_id": "ride-1"}`, domain.ErrRideNotMatched, http.StatusConflict, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeService{enRouteErr: tt.enRouteErr}
+			srv := newTestServer(t, svc)
+
+			resp := post(t, srv, "/drivers/driver-1/enroute", bearer(t, "driver-1", auth.RoleDriver), tt.body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if called := len(svc.enRoutes) == 1 && svc.enRoutes[0] == "driver-1/ride-1"; called != tt.wantCalled {
+				t.Errorf("service calls = %v, want called %v", svc.enRoutes, tt.wantCalled)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var got enRouteResponse
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if got.RideID != "ride-1" || got.Status != domain.DriverStatusEnRoute {
+				t.Errorf("response = %+v, want ride-1 EN_ROUTE", got)
+			}
+		})
+	}
+}
+
+func TestStartRideStatuses(t *testing.T) {
+	tests := []struct {
+		name       string
+		startErr   error
+		wantStatus int
+	}{
+		{"at the pickup", nil, http.StatusOK},
+		{"not the driver's ride", domain.ErrRideNotAssigned, http.StatusNotFound},
+		{"out of order", domain.ErrRideNotStartable, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t, &fakeService{startErr: tt.startErr})
+
+			resp := post(t, srv, "/drivers/driver-1/start", bearer(t, "driver-1", auth.RoleDriver), `{"ride_id": "ride-1"}`)
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestEnRouteOnlyForTheDriver(t *testing.T) {
+	svc := &fakeService{}
+	srv := newTestServer(t, svc)
+
+	resp := post(t, srv, "/drivers/driver-1/enroute", bearer(t, "driver-2", auth.RoleDriver), `{"ride_id": "ride-1"}`)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", resp.StatusCode)
+	}
+	if len(svc.enRoutes) != 0 {
+		t.Errorf("service calls = %v, want none", svc.enRoutes)
+	}
+}
+
+func TestGoOnlineBelowMinimumRating(t *testing.T) {
+	svc := &fakeService{onlineErr: fmt.Errorf("%w: rating 3.20, minimum 4.00", domain.ErrRatingTooLow)}
+	srv := newTestServer(t, svc)
+
+	resp := post(t, srv, "/drivers/driver-1/online", bearer(t, "driver-1", auth.RoleDriver), `{"latitude":43.2,"longitude":76.8}`)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", resp.StatusCode)
+	}
+	var body struct {
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !strings.Contains(body.Message, "rating 3.20, minimum 4.00") {
+		t.Errorf("message = %q, want the driver's rating and the minimum", body.Message)
+	}
+}
+
+func TestGoOnlineAboveMinimumRating(t *testing.T) {
+	srv := newTestServer(t, &fakeService{})
+
+	resp := post(t, srv, "/drivers/driver-1/online", bearer(t, "driver-1", auth.RoleDriver), `{"latitude":43.2,"longitude":76.8}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+}
@@ -3,9 +3,14 @@ package rest
 import (
 	"encoding/json"
 	"errors"
+	"mime"
 	"net/http"
 	"strings"
 	"time"
+
+	"ride-hail/pkg/apitime"
+	"ride-hail/pkg/geo"
+	"ride-hail/pkg/validation"
 )
 
 func driverIDFromRequest(r *http.Request) (string, error) {
@@ -28,6 +33,27 @@ func extractBearerToken(r *http.Request) (string, error) {
 	return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer ")), nil
 }
 
+// errUnsupportedMediaType rejects request bodies that are not declared as JSON
+var errUnsupportedMediaType = errors.New("Content-Type must be application/json")
+
+// requireJSON checks that the request body is declared as application/json
+// (parameters such as charset are allowed)
+func requireJSON(r *http.Request) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return errUnsupportedMediaType
+	}
+	return nil
+}
+
+// decodeErrorStatus maps a decodeJSON error to 415 for a wrong Content-Type, 400 otherwise
+func decodeErrorStatus(err error) int {
+	if errors.Is(err, errUnsupportedMediaType) {
+		return http.StatusUnsupportedMediaType
+	}
+	return http.StatusBadRequest
+}
+
 func writeJSON(w http.ResponseWriter, status int, payload interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -41,10 +67,25 @@ func writeError(w http.ResponseWriter, status int, msg string) {
 	})
 }
 
+// writeValidationError writes a 400 listing every invalid field under "errors"
+func writeValidationError(w http.ResponseWriter, errs validation.Errors) {
+	writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+		"error":   http.StatusText(http.StatusBadRequest),
+		"message": errs.Error(),
+		"errors":  errs,
+	})
+}
+
 func validateCoordinates(lat, lng float64) bool {
-	return lat >= -90 && lat <= 90 && lng >= -180 && lng <= 180
+	return geo.ValidCoordinates(lat, lng)
+}
+
+// checkCoordinates records range errors for a latitude/longitude pair
+func checkCoordinates(errs *validation.Errors, lat, lng float64) {
+	errs.Check(geo.ValidLatitude(lat), "latitude", "must be between -90 and 90")
+	errs.Check(geo.ValidLongitude(lng), "longitude", "must be between -180 and 180")
 }
 
 func nowISO() string {
-	return time.Now().UTC().Format(time.RFC3339)
+	return apitime.Format(time.Now())
 }
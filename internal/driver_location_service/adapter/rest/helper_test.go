@@ -0,0 +1,90 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ride-hail/pkg/auth"
+)
+
+func TestRequireJSON(t *testing.T) {
+	tests := []struct {
+		contentType string
+		ok          bool
+	}{
+		{"application/json", true},
+		{"application/json; charset=utf-8", true},
+		{"Application/JSON", true},
+		{"", false},
+		{"text/plain", false},
+		{"application/x-www-form-urlencoded", false},
+		{"application/json-patch+json", false},
+		{"application/json; charset", false}, // malformed parameter
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		if tt.contentType != "" {
+			r.Header.Set("Content-Type", tt.contentType)
+		}
+		if err := requireJSON(r); (err == nil) != tt.ok {
+			t.Errorf("requireJSON(%q) = %v, want ok=%v", tt.contentType, err, tt.ok)
+		}
+	}
+}
+
+// postAs sends body to path as driver-1 with the given Content-Type, or none if empty
+func postAs(t *testing.T, srv *httptest.Server, path, contentType, body string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+path, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Authorization", bearer(t, "driver-1", auth.RoleDriver))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST %s: %v", path, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// jsonBodyRoutes are the driver routes that read a JSON body
+var jsonBodyRoutes = []string{
+	"/drivers/driver-1/online",
+	"/drivers/driver-1/location",
+	"/drivers/driver-1/locations",
+	"/drivers/driver-1/enroute",
+	"/drivers/driver-1/arrived",
+	"/drivers/driver-1/start",
+	"/drivers/driver-1/complete",
+	"/drivers/driver-1/rides/ride-1/cancel",
+	"/drivers/driver-1/offers/offer-1/respond",
+}
+
+func TestJSONRoutesRejectOtherContentTypes(t *testing.T) {
+	// The service is never reached; any call into it would panic
+	srv := newTestServer(t, &fakeService{})
+
+	for _, path := range jsonBodyRoutes {
+		for _, contentType := range []string{"", "text/plain", "application/x-www-form-urlencoded"} {
+			resp := postAs(t, srv, path, contentType, `{"latitude": 43.2389, "longitude": 76.8897}`)
+			if resp.StatusCode != http.StatusUnsupportedMediaType {
+				t.Errorf("POST %s as %q: status = %d, want 415", path, contentType, resp.StatusCode)
+			}
+		}
+	}
+}
+
+func TestJSONRoutesAcceptCharsetParameter(t *testing.T) {
+	srv := newTestServer(t, &fakeService{})
+
+	// A malformed body gets past the Content-Type check and fails to decode
+	for _, path := range jsonBodyRoutes {
+		resp := postAs(t, srv, path, "application/json; charset=utf-8", `{`)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("POST %s: status = %d, want 400", path, resp.StatusCode)
+		}
+	}
+}
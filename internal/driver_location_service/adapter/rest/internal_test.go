@@ -0,0 +1,128 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"ride-hail/internal/driver_location_service/domain"
+	"ride-hail/pkg/auth"
+)
+
+func TestInternalNearbyDriversIncludeConnection(t *testing.T) {
+	updated := time.Date(2024, 12, 16, 10, 0, 0, 0, time.UTC)
+	srv := newTestServer(t, &fakeService{nearby: []*domain.NearbyDriver{
+		{DriverID: "driver-2", Rating: 4.9, Latitude: 43.24, Longitude: 76.89, DistanceKm: 1.2, LocationUpdatedAt: updated, Connected: true},
+		{DriverID: "driver-1", Rating: 4.7, Latitude: 43.239, Longitude: 76.89, DistanceKm: 0.4, LocationUpdatedAt: updated},
+	}})
+
+	resp := get(t, srv, "/internal/drivers/nearby?latitude=43.2390&longitude=76.8900&vehicle_type=ECONOMY", "Bearer "+testServiceToken)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	var got struct {
+		Drivers []struct {
+			DriverID  string `json:"driver_id"`
+			Connected bool   `json:"connected"`
+		} `json:"drivers"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(got.Drivers) != 2 {
+		t.Fatalf("got %d drivers, want 2", len(got.Drivers))
+	}
+	if d := got.Drivers[0]; d.DriverID != "driver-2" || !d.Connected {
+		t.Errorf("first driver %+v, want connected driver-2", d)
+	}
+	if d := got.Drivers[1]; d.DriverID != "driver-1" || d.Connected {
+		t.Errorf("second driver %+v, want unconnected driver-1", d)
+	}
+}
+
+func TestInternalNearbyDriversIncludeStaleness(t *testing.T) {
+	updated := time.Date(2024, 12, 16, 10, 0, 0, 0, time.UTC)
+	srv := newTestServer(t, &fakeService{nearby: []*domain.NearbyDriver{
+		{DriverID: "driver-1", LocationUpdatedAt: updated, StalenessSeconds: 41.6},
+	}})
+
+	resp := get(t, srv, "/internal/drivers/nearby?latitude=43.2390&longitude=76.8900&vehicle_type=ECONOMY", "Bearer "+testServiceToken)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	var got struct {
+		Drivers []struct {
+			LastUpdatedAt    time.Time `json:"last_updated_at"`
+			StalenessSeconds float64   `json:"staleness_seconds"`
+		} `json:"drivers"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(got.Drivers) != 1 {
+		t.Fatalf("got %d drivers, want 1", len(got.Drivers))
+	}
+	if d := got.Drivers[0]; !d.LastUpdatedAt.Equal(updated) || d.StalenessSeconds != 42 {
+		t.Errorf("driver updated %v, %vs stale; want %v, 42s", d.LastUpdatedAt, d.StalenessSeconds, updated)
+	}
+}
+
+func TestInternalDriverConnected(t *testing.T) {
+	srv := newTestServer(t, &fakeService{connected: map[string]bool{"driver-1": true}})
+
+	for driverID, want := range map[string]bool{"driver-1": true, "driver-2": false} {
+		resp := get(t, srv, "/internal/drivers/"+driverID+"/connected", "Bearer "+testServiceToken)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: status = %d, want 200", driverID, resp.StatusCode)
+		}
+		var got struct {
+			DriverID  string `json:"driver_id"`
+			Connected bool   `json:"connected"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if got.DriverID != driverID || got.Connected != want {
+			t.Errorf("got %+v, want %s connected=%v", got, driverID, want)
+		}
+	}
+}
+
+func TestInternalEndpointsRequireServiceToken(t *testing.T) {
+	srv := newTestServer(t, &fakeService{})
+
+	for _, authorization := range []string{"", "Bearer wrong", bearer(t, "driver-1", auth.RoleDriver)} {
+		for _, path := range []string{
+			"/internal/drivers/nearby?latitude=43.2390&longitude=76.8900&vehicle_type=ECONOMY",
+			"/internal/drivers/driver-1/connected",
+		} {
+			if resp := get(t, srv, path, authorization); resp.StatusCode != http.StatusUnauthorized {
+				t.Errorf("%s with %q: status = %d, want 401", path, authorization, resp.StatusCode)
+			}
+		}
+	}
+}
+
+func TestInternalNearbyDriversLeavesRadiusBoundsToTheService(t *testing.T) {
+	svc := &fakeService{}
+	srv := newTestServer(t, svc)
+	const path = "/internal/drivers/nearby?latitude=43.2390&longitude=76.8900&vehicle_type=ECONOMY"
+
+	// No radius asks for the service's default; a large one is no longer
+	// rejected here but clamped by the service
+	for _, query := range []string{"", "&radius_km=1000"} {
+		if resp := get(t, srv, path+query, "Bearer "+testServiceToken); resp.StatusCode != http.StatusOK {
+			t.Errorf("%q: status = %d, want 200", query, resp.StatusCode)
+		}
+	}
+	if len(svc.radii) != 2 || svc.radii[0] != 0 || svc.radii[1] != 1000 {
+		t.Errorf("service asked for radii %v km, want [0 1000]", svc.radii)
+	}
+
+	for _, radius := range []string{"0", "-3", "abc", "Inf"} {
+		if resp := get(t, srv, path+"&radius_km="+radius, "Bearer "+testServiceToken); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("radius_km=%s: status = %d, want 400", radius, resp.StatusCode)
+		}
+	}
+}
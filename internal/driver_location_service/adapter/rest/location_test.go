@@ -0,0 +1,159 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"ride-hail/internal/driver_location_service/domain"
+	"ride-hail/pkg/auth"
+)
+
+const locationBody = `{"latitude":43.2390,"longitude":76.8900,"accuracy_meters":5,"speed_kmh":30,"heading_degrees":90}`
+
+func TestUpdateLocationUsesTheTokensDriver(t *testing.T) {
+	svc := &fakeService{}
+	srv := newTestServer(t, svc)
+
+	resp := post(t, srv, "/drivers/driver-1/location", bearer(t, "driver-1", auth.RoleDriver), locationBody)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if len(svc.locations) != 1 || svc.locations[0] != "driver-1" {
+		t.Errorf("locations updated for %v, want driver-1", svc.locations)
+	}
+}
+
+func TestUpdateLocationRejectsAnotherDriversPath(t *testing.T) {
+	svc := &fakeService{}
+	srv := newTestServer(t, svc)
+
+	tests := []struct {
+		name, authorization string
+	}{
+		{"no token", ""},
+		{"another driver", bearer(t, "driver-2", auth.RoleDriver)},
+		{"passenger with the same id", bearer(t, "driver-1", auth.RolePassenger)},
+		{"garbage token", "Bearer not-a-jwt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := post(t, srv, "/drivers/driver-1/location", tt.authorization, locationBody)
+			if resp.StatusCode != http.StatusUnauthorized {
+				t.Errorf("status = %d, want 401", resp.StatusCode)
+			}
+		})
+	}
+	if len(svc.locations) != 0 {
+		t.Errorf("locations updated for %v, want none", svc.locations)
+	}
+}
+
+func TestRateLimitedLocationUpdate(t *testing.T) {
+	svc := &fakeService{locationErr: domain.ErrLocationRateLimited}
+	srv := newTestServer(t, svc)
+
+	resp := post(t, srv, "/drivers/driver-1/location", bearer(t, "driver-1", auth.RoleDriver), locationBody)
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", resp.StatusCode)
+	}
+	var body struct {
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	// The socket reports the same text in its error frame
+	if body.Message != domain.ErrLocationRateLimited.Error() {
+		t.Errorf("message = %q, want %q", body.Message, domain.ErrLocationRateLimited.Error())
+	}
+}
+
+func TestLocationHistoryParsesTheQuery(t *testing.T) {
+	svc := &fakeService{}
+	srv := newTestServer(t, svc)
+
+	resp := get(t, srv, "/drivers/driver-1/locations?ride_id=ride-1&from=2024-12-16T10:00:00Z&to=2024-12-16T11:00:00%2B01:00&limit=50&offset=100",
+		bearer(t, "driver-1", auth.RoleDriver))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	from := time.Date(2024, 12, 16, 10, 0, 0, 0, time.UTC)
+	if len(svc.histories) != 1 {
+		t.Fatalf("service queried %d times, want once", len(svc.histories))
+	}
+	q := svc.histories[0]
+	if q.RideID != "ride-1" || !q.From.Equal(from) || !q.To.Equal(from) || q.Limit != 50 || q.Offset != 100 {
+		t.Errorf("query = %+v, want ride-1 from 10:00Z to 10:00Z, limit 50 offset 100", q)
+	}
+}
+
+func TestLocationHistoryBadParameters(t *testing.T) {
+	tests := []struct {
+		name, query string
+		historyErr  error
+	}{
+		{"from not RFC 3339", "from=2024-12-16", nil},
+		{"to not RFC 3339", "to=yesterday", nil},
+		{"limit not a number", "limit=ten", nil},
+		{"offset not a number", "offset=1.5", nil},
+		{"rejected by the service", "limit=5000", fmt.Errorf("%w: limit too large", domain.ErrInvalidHistoryQuery)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t, &fakeService{historyErr: tt.historyErr})
+			resp := get(t, srv, "/drivers/driver-1/locations?"+tt.query, bearer(t, "driver-1", auth.RoleDriver))
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestLocationHistoryOnlyForTheDriver(t *testing.T) {
+	svc := &fakeService{}
+	srv := newTestServer(t, svc)
+
+	resp := get(t, srv, "/drivers/driver-1/locations", bearer(t, "driver-2", auth.RoleDriver))
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", resp.StatusCode)
+	}
+	if len(svc.histories) != 0 {
+		t.Errorf("service queried %v, want never", svc.histories)
+	}
+}
+
+func TestUpdateLocationExplainsUndecodableBodies(t *testing.T) {
+	svc := &fakeService{}
+	srv := newTestServer(t, svc)
+
+	tests := []struct {
+		name, body, want string
+	}{
+		{"empty", ``, "Request body is empty"},
+		{"malformed", `{"latitude": 43.2389,`, "Request body is malformed JSON: unexpected end of input"},
+		{"wrong type", `{"latitude": "43.2389", "longitude": 76.8897}`, `Field "latitude" must be a number, got string`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := post(t, srv, "/drivers/driver-1/location", bearer(t, "driver-1", auth.RoleDriver), tt.body)
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400", resp.StatusCode)
+			}
+			var body struct {
+				Message string `json:"message"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if body.Message != tt.want {
+				t.Errorf("message = %q, want %q", body.Message, tt.want)
+			}
+		})
+	}
+	if len(svc.locations) != 0 {
+		t.Errorf("locations updated for %v, want none", svc.locations)
+	}
+}
@@ -0,0 +1,184 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"ride-hail/internal/driver_location_service/domain"
+	"ride-hail/pkg/auth"
+)
+
+func TestListOffersReturnsTheDriversOffers(t *testing.T) {
+	expires := time.Date(2024, 12, 16, 10, 0, 30, 0, time.UTC)
+	srv := newTestServer(t, &fakeService{offers: map[string][]*domain.DriverOffer{
+		"driver-1": {{
+			OfferID:        "offer-1",
+			RideID:         "ride-1",
+			RideNumber:     "RIDE_20241216_001",
+			PickupLocation: domain.Location{Lat: 43.2389, Lng: 76.8897, Address: "Abay Ave 10"},
+			EstimatedFare:  1450,
+			DriverEarnings: 1160,
+			ExpiresAt:      expires,
+		}},
+	}})
+
+	resp := get(t, srv, "/drivers/driver-1/offers", bearer(t, "driver-1", auth.RoleDriver))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	var got struct {
+		Offers []offerResponse `json:"offers"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(got.Offers) != 1 {
+		t.Fatalf("offers = %+v, want one", got.Offers)
+	}
+	o := got.Offers[0]
+	if o.OfferID != "offer-1" || o.RideID != "ride-1" || o.DriverEarnings != 1160 || o.PickupLocation.Address != "Abay Ave 10" {
+		t.Errorf("offer = %+v, want offer-1 for ride-1", o)
+	}
+	if o.ExpiresAt == "" {
+		t.Error("expires_at missing")
+	}
+}
+
+func TestListOffersWithNoneIsAnEmptyList(t *testing.T) {
+	srv := newTestServer(t, &fakeService{offers: map[string][]*domain.DriverOffer{"driver-1": {}}})
+
+	resp := get(t, srv, "/drivers/driver-1/offers", bearer(t, "driver-1", auth.RoleDriver))
+	var got map[string]json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if string(got["offers"]) != "[]" {
+		t.Errorf("offers = %s, want []", got["offers"])
+	}
+}
+
+func TestListOffersOnlyForTheDriver(t *testing.T) {
+	srv := newTestServer(t, &fakeService{})
+
+	for name, authorization := range map[string]string{
+		"another driver": bearer(t, "driver-2", auth.RoleDriver),
+		"a passenger":    bearer(t, "driver-1", auth.RolePassenger),
+		"no token":       "",
+	} {
+		resp := get(t, srv, "/drivers/driver-1/offers", authorization)
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s: status = %d, want 401", name, resp.StatusCode)
+		}
+	}
+}
+
+func TestRespondToOffer(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		respondErr error
+		wantStatus int
+		wantAnswer string
+	}{
+		{"accept", `{"accepted": true}`, nil, http.StatusOK, "driver-1/offer-1/true"},
+		{"reject", `{"accepted": false}`, nil, http.StatusOK, "driver-1/offer-1/false"},
+		{"missing answer", `{}`, nil, http.StatusBadRequest, ""},
+		{"expired or unknown", `{"accepted": true}`, domain.ErrOfferNotFound, http.StatusNotFound, "driver-1/offer-1/true"},
+		{"already on a ride", `{"accepted": true}`, fmt.Errorf("%w (status BUSY)", domain.ErrDriverAssigned), http.StatusConflict, "driver-1/offer-1/true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeService{respondErr: tt.respondErr}
+			srv := newTestServer(t, svc)
+
+			resp := post(t, srv, "/drivers/driver-1/offers/offer-1/respond", bearer(t, "driver-1", auth.RoleDriver), tt.body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := fmt.Sprint(svc.answers); tt.wantAnswer != "" && got != "["+tt.wantAnswer+"]" {
+				t.Errorf("answers = %s, want [%s]", got, tt.wantAnswer)
+			} else if tt.wantAnswer == "" && len(svc.answers) != 0 {
+				t.Errorf("answers = %s, want none", got)
+			}
+		})
+	}
+}
+
+func TestRespondToOfferOnlyForTheDriver(t *testing.T) {
+	svc := &fakeService{}
+	srv := newTestServer(t, svc)
+
+	resp := post(t, srv, "/drivers/driver-1/offers/offer-1/respond", bearer(t, "driver-2", auth.RoleDriver), `{"accepted": true}`)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", resp.StatusCode)
+	}
+	if len(svc.answers) != 0 {
+		t.Errorf("answers = %v, want none", svc.answers)
+	}
+}
+
+func TestAcceptAndRejectOffer(t *testing.T) {
+	tests := []struct {
+		name       string
+		action     string
+		respondErr error
+		wantStatus int
+		wantAnswer string
+		wantBody   string
+	}{
+		{"accept", "accept", nil, http.StatusOK, "driver-1/offer-1/true", "ACCEPTED"},
+		{"reject", "reject", nil, http.StatusOK, "driver-1/offer-1/false", "REJECTED"},
+		{"accept expired", "accept", domain.ErrOfferExpired, http.StatusConflict, "driver-1/offer-1/true", ""},
+		{"reject withdrawn", "reject", domain.ErrOfferExpired, http.StatusConflict, "driver-1/offer-1/false", ""},
+		{"accept unknown", "accept", domain.ErrOfferNotFound, http.StatusNotFound, "driver-1/offer-1/true", ""},
+		{"accept while on a ride", "accept", domain.ErrDriverAssigned, http.StatusConflict, "driver-1/offer-1/true", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeService{respondErr: tt.respondErr}
+			srv := newTestServer(t, svc)
+
+			resp := post(t, srv, "/drivers/driver-1/offers/offer-1/"+tt.action, bearer(t, "driver-1", auth.RoleDriver), "")
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := fmt.Sprint(svc.answers); got != "["+tt.wantAnswer+"]" {
+				t.Errorf("answers = %s, want [%s]", got, tt.wantAnswer)
+			}
+			if tt.wantBody == "" {
+				return
+			}
+			var got map[string]string
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if got["offer_id"] != "offer-1" || got["status"] != tt.wantBody {
+				t.Errorf("body = %v, want offer-1 %s", got, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAcceptOfferOnlyForTheDriver(t *testing.T) {
+	for name, authorization := range map[string]string{
+		"another driver": "driver-2",
+		"no token":       "",
+	} {
+		svc := &fakeService{}
+		srv := newTestServer(t, svc)
+		if authorization != "" {
+			authorization = bearer(t, authorization, auth.RoleDriver)
+		}
+
+		resp := post(t, srv, "/drivers/driver-1/offers/offer-1/accept", authorization, "")
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s: status = %d, want 401", name, resp.StatusCode)
+		}
+		if len(svc.answers) != 0 {
+			t.Errorf("%s: answers = %v, want none", name, svc.answers)
+		}
+	}
+}
@@ -6,17 +6,22 @@ import (
 	"net/http"
 	"time"
 
+	"ride-hail/pkg/buildinfo"
+	"ride-hail/pkg/config"
 	"ride-hail/pkg/logger"
+	"ride-hail/pkg/middleware"
 )
 
 // Server is a simple HTTP server for driver locations.
 type Server struct {
-	srv *http.Server
-	log logger.Logger
+	srv             *http.Server
+	shutdownTimeout time.Duration
+	log             logger.Logger
 }
 
-// New creates a new Server listening on addr (e.g. ":8080").
-func New(addr string, log logger.Logger, register func(mux *http.ServeMux)) *Server {
+// New creates a new Server listening on addr (e.g. ":8080") with the configured timeouts.
+// ready, when set, is run on every /health request; an error marks the service degraded.
+func New(addr string, httpCfg config.HTTPServerConfig, log logger.Logger, register func(mux *http.ServeMux), ready func() error) *Server {
 	mux := http.NewServeMux()
 
 	// call the handler's registration function
@@ -25,19 +30,18 @@ func New(addr string, log logger.Logger, register func(mux *http.ServeMux)) *Ser
 	}
 
 	// still register your health endpoint or internal endpoints
-	mux.HandleFunc("/health", healthHandler)
+	mux.HandleFunc("GET /health", healthHandler(ready, log))
+	mux.HandleFunc("GET /version", buildinfo.Handler("driver-location-service"))
 
 	return &Server{
-		srv: &http.Server{
-			Addr:    addr,
-			Handler: mux,
-		},
-		log: log,
+		srv:             httpCfg.NewServer(addr, middleware.Chain(mux, middleware.Recovery(log), middleware.Logging(log))),
+		shutdownTimeout: httpCfg.ShutdownTimeout,
+		log:             log,
 	}
 }
 
 // Start runs the server and returns when ctx is cancelled or server fails.
-// It will attempt a graceful shutdown with a 5s timeout when ctx is done.
+// It will attempt a graceful shutdown within the shutdown timeout when ctx is done.
 func (s *Server) Start(ctx context.Context) error {
 	errCh := make(chan error, 1)
 
@@ -52,7 +56,7 @@ func (s *Server) Start(ctx context.Context) error {
 	select {
 	case <-ctx.Done():
 		// graceful shutdown
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
 		defer cancel()
 		s.log.Info("http_server_shutdown", "Shutting down HTTP server")
 		return s.srv.Shutdown(shutdownCtx)
@@ -61,8 +65,17 @@ func (s *Server) Start(ctx context.Context) error {
 	}
 }
 
-func healthHandler(w http.ResponseWriter, _ *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write([]byte(`{"status":"ok"}`))
+// healthHandler reports degraded when ready fails. The error is only logged,
+// since the endpoint is public.
+func healthHandler(ready func() error, log logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		if ready != nil {
+			if err := ready(); err != nil {
+				log.Error("health_check", err)
+				writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "degraded"})
+				return
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	}
 }
@@ -0,0 +1,126 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ride-hail/pkg/auth"
+	"ride-hail/pkg/config"
+	"ride-hail/pkg/dbtest"
+)
+
+func TestNewServerUsesConfiguredTimeouts(t *testing.T) {
+	cfg := config.HTTPServerConfig{
+		ReadTimeout:     3 * time.Second,
+		WriteTimeout:    7 * time.Second,
+		IdleTimeout:     90 * time.Second,
+		ShutdownTimeout: 15 * time.Second,
+	}
+
+	s := New(":3001", cfg, dbtest.Logger{}, nil, nil)
+
+	if s.srv.ReadTimeout != 3*time.Second || s.srv.WriteTimeout != 7*time.Second || s.srv.IdleTimeout != 90*time.Second {
+		t.Errorf("timeouts read=%v write=%v idle=%v, want 3s, 7s and 90s", s.srv.ReadTimeout, s.srv.WriteTimeout, s.srv.IdleTimeout)
+	}
+	if s.shutdownTimeout != 15*time.Second {
+		t.Errorf("shutdown timeout = %v, want 15s", s.shutdownTimeout)
+	}
+}
+
+func TestRoutesRejectWrongMethods(t *testing.T) {
+	h := NewHandler(&fakeService{}, auth.NewJWTManager(testSecret, time.Hour), dbtest.Logger{})
+	s := New(":3001", config.HTTPServerConfig{}, dbtest.Logger{}, h.RegisterRoutes, nil)
+	srv := httptest.NewServer(s.srv.Handler)
+	t.Cleanup(srv.Close)
+
+	tests := []struct{ method, path string }{
+		{http.MethodDelete, "/health"},
+		{http.MethodPost, "/version"},
+		{http.MethodPost, "/drivers/driver-1"},
+		{http.MethodGet, "/drivers/driver-1/online"},
+		{http.MethodGet, "/drivers/driver-1/offline"},
+		{http.MethodGet, "/drivers/driver-1/location"},
+		{http.MethodDelete, "/drivers/driver-1/locations"},
+		{http.MethodPut, "/drivers/driver-1/complete"},
+		{http.MethodGet, "/drivers/driver-1/rides/ride-1/cancel"},
+		{http.MethodPost, "/drivers/driver-1/current-ride"},
+		{http.MethodDelete, "/drivers/driver-1/offers"},
+		{http.MethodGet, "/drivers/driver-1/offers/offer-1/accept"},
+		{http.MethodPost, "/metrics/offers"},
+		{http.MethodPost, "/internal/drivers/nearby"},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, srv.URL+tt.path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", tt.method, tt.path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("%s %s: status = %d, want 405", tt.method, tt.path, resp.StatusCode)
+		}
+		if resp.Header.Get("Allow") == "" {
+			t.Errorf("%s %s: no Allow header", tt.method, tt.path)
+		}
+	}
+}
+
+func TestHealthReportsTopologyDrift(t *testing.T) {
+	tests := []struct {
+		name       string
+		ready      func() error
+		wantStatus int
+		want       string
+	}{
+		{"no check", nil, http.StatusOK, "ok"},
+		{"topology in place", func() error { return nil }, http.StatusOK, "ok"},
+		{"queue missing", func() error { return errors.New("RabbitMQ topology drift: queue driver_matching: NOT_FOUND") }, http.StatusServiceUnavailable, "degraded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(":3001", config.HTTPServerConfig{}, dbtest.Logger{}, nil, tt.ready)
+			rec := httptest.NewRecorder()
+			s.srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			var body map[string]string
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if body["status"] != tt.want {
+				t.Errorf("status field = %q, want %q", body["status"], tt.want)
+			}
+			if len(body) != 1 {
+				t.Errorf("body = %v, want only the status; the drift is logged", body)
+			}
+		})
+	}
+}
+
+func TestVersionIsServedWithoutAuth(t *testing.T) {
+	h := NewHandler(&fakeService{}, auth.NewJWTManager(testSecret, time.Hour), dbtest.Logger{})
+	s := New(":3001", config.HTTPServerConfig{}, dbtest.Logger{}, h.RegisterRoutes, nil)
+	srv := httptest.NewServer(s.srv.Handler)
+	t.Cleanup(srv.Close)
+
+	resp := get(t, srv, "/version", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	var got struct {
+		Service string `json:"service"`
+		Version string `json:"version"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.Service != "driver-location-service" || got.Version == "" {
+		t.Errorf("GET /version = %+v, want the driver-location-service build", got)
+	}
+}
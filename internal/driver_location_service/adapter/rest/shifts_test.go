@@ -0,0 +1,77 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"ride-hail/internal/driver_location_service/domain"
+	"ride-hail/pkg/auth"
+)
+
+func TestListShifts(t *testing.T) {
+	ended := time.Date(2024, 12, 16, 18, 0, 0, 0, time.UTC)
+	srv := newTestServer(t, &fakeService{shifts: []*domain.ShiftSummary{
+		{SessionID: "s2", DriverID: "driver-1", StartedAt: ended.Add(-4 * time.Hour), EndedAt: ended, RidesCompleted: 5, Earnings: 6200, BusyDuration: 3 * time.Hour},
+		{SessionID: "s1", DriverID: "driver-1", StartedAt: ended.Add(-28 * time.Hour), EndedAt: ended.Add(-24 * time.Hour)},
+	}})
+
+	resp := get(t, srv, "/drivers/driver-1/shifts?limit=1", bearer(t, "driver-1", auth.RoleDriver))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	var got shiftPageResponse
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.Limit != 1 || got.Offset != 0 || !got.HasMore || len(got.Shifts) != 1 {
+		t.Fatalf("page = %+v, want the first of two shifts", got)
+	}
+	want := shiftResponse{
+		SessionID:          "s2",
+		StartedAt:          "2024-12-16T14:00:00Z",
+		EndedAt:            "2024-12-16T18:00:00Z",
+		DurationHours:      4,
+		RidesCompleted:     5,
+		Earnings:           6200,
+		UtilizationPercent: 75,
+		IdleHours:          1,
+	}
+	if got.Shifts[0] != want {
+		t.Errorf("shift = %+v, want %+v", got.Shifts[0], want)
+	}
+}
+
+func TestListShiftsWithNoneIsAnEmptyList(t *testing.T) {
+	srv := newTestServer(t, &fakeService{})
+
+	resp := get(t, srv, "/drivers/driver-1/shifts", bearer(t, "driver-1", auth.RoleDriver))
+	var got map[string]json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if string(got["shifts"]) != "[]" {
+		t.Errorf("shifts = %s, want []", got["shifts"])
+	}
+}
+
+func TestListShiftsRejections(t *testing.T) {
+	srv := newTestServer(t, &fakeService{})
+	tests := []struct {
+		name, path, authorization string
+		want                      int
+	}{
+		{"another driver", "/drivers/driver-1/shifts", bearer(t, "driver-2", auth.RoleDriver), http.StatusUnauthorized},
+		{"no token", "/drivers/driver-1/shifts", "", http.StatusUnauthorized},
+		{"limit not a number", "/drivers/driver-1/shifts?limit=ten", bearer(t, "driver-1", auth.RoleDriver), http.StatusBadRequest},
+		{"negative offset", "/drivers/driver-1/shifts?offset=-1", bearer(t, "driver-1", auth.RoleDriver), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp := get(t, srv, tt.path, tt.authorization); resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"ride-hail/internal/driver_location_service/domain"
+	"ride-hail/pkg/apitime"
+	"ride-hail/pkg/auth"
+)
+
+// localTime is 2024-12-16T10:30:00Z in a non-UTC zone with a fraction of a
+// second, as a database or clock might hand it to a handler
+var localTime = time.Date(2024, 12, 16, 15, 30, 0, 250_000_000, time.FixedZone("ALMT", 5*60*60))
+
+// wantAPITime fails unless got is t as RFC 3339 UTC with whole seconds
+func wantAPITime(t *testing.T, field, got string, want time.Time) {
+	t.Helper()
+	parsed, err := time.Parse(time.RFC3339, got)
+	if err != nil {
+		t.Errorf("%s = %q, not RFC 3339: %v", field, got, err)
+		return
+	}
+	if got != apitime.Format(want) || parsed.Location() != time.UTC {
+		t.Errorf("%s = %q, want %q", field, got, apitime.Format(want))
+	}
+}
+
+func TestNearbyDriverTimestampIsUTC(t *testing.T) {
+	srv := newTestServer(t, &fakeService{nearby: []*domain.NearbyDriver{
+		{DriverID: "driver-1", LocationUpdatedAt: localTime},
+	}})
+
+	resp := get(t, srv, "/internal/drivers/nearby?latitude=43.2390&longitude=76.8900&vehicle_type=ECONOMY", "Bearer "+testServiceToken)
+	var got struct {
+		Drivers []struct {
+			LastUpdatedAt string `json:"last_updated_at"`
+		} `json:"drivers"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(got.Drivers) != 1 {
+		t.Fatalf("got %d drivers, want 1", len(got.Drivers))
+	}
+	wantAPITime(t, "last_updated_at", got.Drivers[0].LastUpdatedAt, localTime)
+}
+
+func TestOfferTimestampIsUTC(t *testing.T) {
+	srv := newTestServer(t, &fakeService{offers: map[string][]*domain.DriverOffer{
+		"driver-1": {{OfferID: "offer-1", RideID: "ride-1", ExpiresAt: localTime}},
+	}})
+
+	resp := get(t, srv, "/drivers/driver-1/offers", bearer(t, "driver-1", auth.RoleDriver))
+	var got struct {
+		Offers []offerResponse `json:"offers"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(got.Offers) != 1 {
+		t.Fatalf("got %d offers, want 1", len(got.Offers))
+	}
+	wantAPITime(t, "expires_at", got.Offers[0].ExpiresAt, localTime)
+}
+
+func TestLocationHistoryTimestampIsUTC(t *testing.T) {
+	srv := newTestServer(t, &fakeService{history: []*domain.LocationHistory{
+		{ID: "point-1", DriverID: "driver-1", RecordedAt: apitime.New(localTime)},
+	}})
+
+	resp := get(t, srv, "/drivers/driver-1/locations", bearer(t, "driver-1", auth.RoleDriver))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	var got struct {
+		Points []struct {
+			RecordedAt string `json:"recorded_at"`
+		} `json:"points"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(got.Points) != 1 {
+		t.Fatalf("got %d points, want 1", len(got.Points))
+	}
+	wantAPITime(t, "recorded_at", got.Points[0].RecordedAt, localTime)
+}
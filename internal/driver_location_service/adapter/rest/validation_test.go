@@ -0,0 +1,70 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"ride-hail/pkg/auth"
+)
+
+// fieldErrors decodes a 400 validation response into the fields it names
+func fieldErrors(t *testing.T, resp *http.Response) []string {
+	t.Helper()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", resp.StatusCode)
+	}
+	var body struct {
+		Message string `json:"message"`
+		Errors  []struct {
+			Field   string `json:"field"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Message == "" {
+		t.Error("message is empty")
+	}
+	fields := make([]string, len(body.Errors))
+	for i, e := range body.Errors {
+		if e.Message == "" {
+			t.Errorf("%s has no message", e.Field)
+		}
+		fields[i] = e.Field
+	}
+	return fields
+}
+
+func TestUpdateLocationReportsEveryInvalidField(t *testing.T) {
+	svc := &fakeService{}
+	srv := newTestServer(t, svc)
+
+	body := `{"latitude":91,"longitude":200,"accuracy_meters":-1,"speed_kmh":-5,"heading_degrees":400}`
+	resp := post(t, srv, "/drivers/driver-1/location", bearer(t, "driver-1", auth.RoleDriver), body)
+	got := fieldErrors(t, resp)
+
+	want := []string{"latitude", "longitude", "accuracy_meters", "speed_kmh", "heading_degrees"}
+	if len(got) != len(want) {
+		t.Fatalf("fields = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("fields[%d] = %s, want %s", i, got[i], want[i])
+		}
+	}
+	if len(svc.locations) != 0 {
+		t.Errorf("locations updated for %v, want none", svc.locations)
+	}
+}
+
+func TestGoOnlineReportsInvalidCoordinates(t *testing.T) {
+	srv := newTestServer(t, &fakeService{})
+
+	resp := post(t, srv, "/drivers/driver-1/online", bearer(t, "driver-1", auth.RoleDriver), `{"latitude":-100,"longitude":181}`)
+	got := fieldErrors(t, resp)
+	if len(got) != 2 || got[0] != "latitude" || got[1] != "longitude" {
+		t.Errorf("fields = %v, want [latitude longitude]", got)
+	}
+}
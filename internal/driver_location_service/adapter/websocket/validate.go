@@ -0,0 +1,103 @@
+package ws
+
+import (
+	"fmt"
+
+	"ride-hail/pkg/geo"
+)
+
+// fieldError describes the first invalid field of an inbound message
+type fieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *fieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// point is an optional {"latitude", "longitude"} pair sent by the driver app
+type point struct {
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+}
+
+// rideResponsePayload is the data of a ride_response message.
+// Pointers distinguish a missing field from its zero value.
+type rideResponsePayload struct {
+	OfferID         string `json:"offer_id"`
+	RideID          string `json:"ride_id"`
+	Accepted        *bool  `json:"accepted"`
+	CurrentLocation *point `json:"current_location,omitempty"`
+}
+
+func (p rideResponsePayload) validate() error {
+	if p.OfferID == "" {
+		return &fieldError{"offer_id", "is required"}
+	}
+	if p.RideID == "" {
+		return &fieldError{"ride_id", "is required"}
+	}
+	if p.Accepted == nil {
+		return &fieldError{"accepted", "is required"}
+	}
+	if p.CurrentLocation != nil {
+		return validatePoint("current_location.", p.CurrentLocation.Latitude, p.CurrentLocation.Longitude)
+	}
+	return nil
+}
+
+// offerAckPayload is the data of an offer_ack message
+type offerAckPayload struct {
+	OfferID string `json:"offer_id"`
+}
+
+func (p offerAckPayload) validate() error {
+	if p.OfferID == "" {
+		return &fieldError{"offer_id", "is required"}
+	}
+	return nil
+}
+
+// locationUpdatePayload is the data of a location_update message
+type locationUpdatePayload struct {
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+	Accuracy  float64  `json:"accuracy_meters"`
+	Speed     float64  `json:"speed_kmh"`
+	Heading   float64  `json:"heading_degrees"`
+	Address   string   `json:"address"`
+}
+
+func (p locationUpdatePayload) validate() error {
+	if err := validatePoint("", p.Latitude, p.Longitude); err != nil {
+		return err
+	}
+	if p.Accuracy < 0 {
+		return &fieldError{"accuracy_meters", "must not be negative"}
+	}
+	if p.Speed < 0 {
+		return &fieldError{"speed_kmh", "must not be negative"}
+	}
+	if p.Heading < 0 || p.Heading >= 360 {
+		return &fieldError{"heading_degrees", "must be between 0 and 360"}
+	}
+	return nil
+}
+
+// validatePoint checks a required coordinate pair; prefix names the enclosing object
+func validatePoint(prefix string, lat, lng *float64) error {
+	if lat == nil {
+		return &fieldError{prefix + "latitude", "is required"}
+	}
+	if lng == nil {
+		return &fieldError{prefix + "longitude", "is required"}
+	}
+	if !geo.ValidLatitude(*lat) {
+		return &fieldError{prefix + "latitude", "must be between -90 and 90"}
+	}
+	if !geo.ValidLongitude(*lng) {
+		return &fieldError{prefix + "longitude", "must be between -180 and 180"}
+	}
+	return nil
+}
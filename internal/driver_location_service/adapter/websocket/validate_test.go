@@ -0,0 +1,50 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestInboundMessageValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		into  interface{ validate() error }
+		data  string
+		field string // empty when the message is valid
+	}{
+		{"ride_response valid", &rideResponsePayload{}, `{"offer_id":"o1","ride_id":"r1","accepted":false}`, ""},
+		{"ride_response with location", &rideResponsePayload{}, `{"offer_id":"o1","ride_id":"r1","accepted":true,"current_location":{"latitude":43.2,"longitude":76.8}}`, ""},
+		{"ride_response no offer", &rideResponsePayload{}, `{"ride_id":"r1","accepted":true}`, "offer_id"},
+		{"ride_response no ride", &rideResponsePayload{}, `{"offer_id":"o1","accepted":true}`, "ride_id"},
+		{"ride_response no answer", &rideResponsePayload{}, `{"offer_id":"o1","ride_id":"r1"}`, "accepted"},
+		{"ride_response location without longitude", &rideResponsePayload{}, `{"offer_id":"o1","ride_id":"r1","accepted":true,"current_location":{"latitude":43.2}}`, "current_location.longitude"},
+		{"ride_response location out of range", &rideResponsePayload{}, `{"offer_id":"o1","ride_id":"r1","accepted":true,"current_location":{"latitude":91,"longitude":76.8}}`, "current_location.latitude"},
+		{"offer_ack valid", &offerAckPayload{}, `{"offer_id":"o1"}`, ""},
+		{"offer_ack no offer", &offerAckPayload{}, `{}`, "offer_id"},
+		{"location valid", &locationUpdatePayload{}, `{"latitude":0,"longitude":0,"heading_degrees":359.9}`, ""},
+		{"location no latitude", &locationUpdatePayload{}, `{"longitude":76.8}`, "latitude"},
+		{"location longitude out of range", &locationUpdatePayload{}, `{"latitude":43.2,"longitude":-180.5}`, "longitude"},
+		{"location negative accuracy", &locationUpdatePayload{}, `{"latitude":43.2,"longitude":76.8,"accuracy_meters":-1}`, "accuracy_meters"},
+		{"location negative speed", &locationUpdatePayload{}, `{"latitude":43.2,"longitude":76.8,"speed_kmh":-3}`, "speed_kmh"},
+		{"location heading wraps", &locationUpdatePayload{}, `{"latitude":43.2,"longitude":76.8,"heading_degrees":360}`, "heading_degrees"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := json.Unmarshal([]byte(tt.data), tt.into); err != nil {
+				t.Fatal(err)
+			}
+			err := tt.into.validate()
+			if tt.field == "" {
+				if err != nil {
+					t.Errorf("validate: %v, want valid", err)
+				}
+				return
+			}
+			var fe *fieldError
+			if !errors.As(err, &fe) || fe.Field != tt.field {
+				t.Errorf("validate: %v, want an error for %s", err, tt.field)
+			}
+		})
+	}
+}
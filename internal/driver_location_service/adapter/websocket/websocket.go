@@ -2,10 +2,11 @@ package ws
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gorilla/websocket"
 
@@ -13,19 +14,15 @@ import (
 	"ride-hail/pkg/auth"
 	"ride-hail/pkg/logger"
 	pkgws "ride-hail/pkg/websocket"
+	"ride-hail/pkg/wsmsg"
 )
 
-type IncomingMessage struct {
-	Type string          `json:"type"`
-	Data json.RawMessage `json:"data"`
-}
-
 type DriverWSAdapter struct {
 	manager  *pkgws.Manager
 	log      logger.Logger
 	jwtMgr   *auth.JWTManager
 	service  domain.DriverLocationService
-	handlers map[string]func(driverID string, data json.RawMessage)
+	handlers map[wsmsg.Type]func(driverID string, msg wsmsg.Envelope)
 }
 
 func NewDriverWSAdapter(log logger.Logger, jwtMgr *auth.JWTManager) *DriverWSAdapter {
@@ -33,7 +30,7 @@ func NewDriverWSAdapter(log logger.Logger, jwtMgr *auth.JWTManager) *DriverWSAda
 		manager:  pkgws.NewManager(log),
 		log:      log,
 		jwtMgr:   jwtMgr,
-		handlers: make(map[string]func(string, json.RawMessage)),
+		handlers: make(map[wsmsg.Type]func(string, wsmsg.Envelope)),
 	}
 }
 
@@ -43,25 +40,37 @@ func (a *DriverWSAdapter) SetService(service domain.DriverLocationService) {
 }
 
 func (a *DriverWSAdapter) registerDomainHandlers() {
-	a.handlers["ride_response"] = a.handleRideResponse
-	a.handlers["location_update"] = a.handleLocationUpdate
+	a.handlers[wsmsg.TypeRideResponse] = a.handleRideResponse
+	a.handlers[wsmsg.TypeOfferAck] = a.handleOfferAck
+	a.handlers[wsmsg.TypeLocationUpdate] = a.handleLocationUpdate
 }
 
 func (a *DriverWSAdapter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Verify route pattern /ws/drivers/{driverID}
-	// The ID in the URL is primarily for routing compliance;
-	// actual identity is extracted securely from the JWT token.
-	if strings.TrimPrefix(r.URL.Path, "/ws/drivers/") == "" {
+	// Identity comes from the JWT; the ID in the URL must match it.
+	pathDriverID := strings.Trim(strings.TrimPrefix(r.URL.Path, "/ws/drivers/"), "/")
+	if pathDriverID == "" {
 		http.Error(w, "Driver ID required in URL path", http.StatusBadRequest)
 		return
 	}
 
-	handler := pkgws.NewHandler(a.log, a.jwtMgr, a.onConnect, auth.RoleDriver)
+	handler := pkgws.NewHandler(a.log, a.jwtMgr, func(conn *pkgws.Connection) {
+		a.onConnect(pathDriverID, conn)
+	}, auth.RoleDriver)
 	handler.ServeHTTP(w, r)
 }
 
-func (a *DriverWSAdapter) onConnect(conn *pkgws.Connection) {
+func (a *DriverWSAdapter) onConnect(pathDriverID string, conn *pkgws.Connection) {
 	driverID := conn.Claims.UserID
+	if driverID != pathDriverID {
+		a.log.WithFields(logger.LogFields{
+			"url_driver_id": pathDriverID,
+			"jwt_user_id":   driverID,
+		}).Error("ws_driver_id_mismatch", fmt.Errorf("driver_id mismatch"))
+		conn.CloseWithReason(pkgws.CloseAuthFailed, "driver_id mismatch")
+		return
+	}
+
 	a.manager.AddConnection(driverID, conn)
 	a.log.WithFields(logger.LogFields{"driver_id": driverID}).Info("ws_connect", "Driver connected")
 
@@ -71,101 +80,180 @@ func (a *DriverWSAdapter) onConnect(conn *pkgws.Connection) {
 		}
 		a.handleMessage(driverID, payload)
 	}, func() {
-		a.manager.RemoveConnection(driverID)
+		a.manager.ReleaseConnection(driverID, conn)
 		a.log.WithFields(logger.LogFields{"driver_id": driverID}).Info("ws_disconnect", "Driver disconnected")
 	})
 }
 
 func (a *DriverWSAdapter) handleMessage(driverID string, payload []byte) {
-	var msg IncomingMessage
-	if err := json.Unmarshal(payload, &msg); err != nil {
+	msg, err := wsmsg.Decode(payload)
+	if err != nil {
 		a.log.Error("ws_json_error", err)
+		a.sendError(driverID, err.Error())
 		return
 	}
 
 	if handler, exists := a.handlers[msg.Type]; exists {
-		handler(driverID, msg.Data)
+		handler(driverID, msg)
 	} else {
 		a.log.WithFields(logger.LogFields{"type": msg.Type}).Debug("ws_unknown_message", "Received unknown message type")
+		a.sendError(driverID, fmt.Sprintf("unknown message type %q", msg.Type))
 	}
 }
 
+// decodeValid decodes msg's data into v and validates it, reporting any problem
+// back to the driver. It returns false if the message must be dropped.
+func (a *DriverWSAdapter) decodeValid(driverID string, msg wsmsg.Envelope, v interface{ validate() error }) bool {
+	if err := msg.Into(v); err != nil {
+		a.log.Error("ws_handler_error", err)
+		a.sendError(driverID, err.Error())
+		return false
+	}
+	if err := v.validate(); err != nil {
+		var fe *fieldError
+		if errors.As(err, &fe) {
+			a.sendValidationError(driverID, msg.Type, fe)
+		} else {
+			a.sendError(driverID, err.Error())
+		}
+		return false
+	}
+	return true
+}
+
 // --- Handlers ---
 
-func (a *DriverWSAdapter) handleRideResponse(driverID string, data json.RawMessage) {
-	var req struct {
-		OfferID  string `json:"offer_id"`
-		RideID   string `json:"ride_id"`
-		Accepted bool   `json:"accepted"`
-	}
-	if err := json.Unmarshal(data, &req); err != nil {
-		a.log.Error("ws_handler_error", fmt.Errorf("invalid ride_response format: %w", err))
+func (a *DriverWSAdapter) handleRideResponse(driverID string, msg wsmsg.Envelope) {
+	var req rideResponsePayload
+	if !a.decodeValid(driverID, msg, &req) {
 		return
 	}
 
 	ctx := context.Background()
-	if err := a.service.HandleDriverRideResponse(ctx, driverID, req.OfferID, req.RideID, req.Accepted); err != nil {
+	if err := a.service.HandleDriverRideResponse(ctx, driverID, req.OfferID, req.RideID, *req.Accepted); err != nil {
 		a.log.Error("ws_handler_failed", err)
+		a.sendError(driverID, err.Error())
 	}
 }
 
-func (a *DriverWSAdapter) handleLocationUpdate(driverID string, data json.RawMessage) {
-	var req struct {
-		Latitude  float64 `json:"latitude"`
-		Longitude float64 `json:"longitude"`
-		Accuracy  float64 `json:"accuracy_meters"`
-		Speed     float64 `json:"speed_kmh"`
-		Heading   float64 `json:"heading_degrees"`
+func (a *DriverWSAdapter) handleOfferAck(driverID string, msg wsmsg.Envelope) {
+	var req offerAckPayload
+	if !a.decodeValid(driverID, msg, &req) {
+		return
+	}
+
+	if err := a.service.AcknowledgeOffer(driverID, req.OfferID); err != nil {
+		a.log.Debug("ws_offer_ack_failed", err.Error())
+		a.sendError(driverID, err.Error())
 	}
-	if err := json.Unmarshal(data, &req); err != nil {
-		a.log.Error("ws_handler_error", fmt.Errorf("invalid location_update format: %w", err))
+}
+
+func (a *DriverWSAdapter) handleLocationUpdate(driverID string, msg wsmsg.Envelope) {
+	var req locationUpdatePayload
+	if !a.decodeValid(driverID, msg, &req) {
 		return
 	}
 
 	ctx := context.Background()
-	// Address is typically not sent in high-frequency updates, defaulting to empty or unknown
+	// Same service path as the REST endpoint, so both share one rate limit per
+	// driver and an empty address is geocoded the same way
 	_, err := a.service.UpdateDriverLocation(
 		ctx,
 		driverID,
-		req.Latitude,
-		req.Longitude,
+		*req.Latitude,
+		*req.Longitude,
 		req.Accuracy,
 		req.Speed,
 		req.Heading,
-		"Unknown",
+		req.Address,
 	)
-	if err != nil {
-		a.log.Debug("ws_location_update_failed", err.Error())
+	switch {
+	case errors.Is(err, domain.ErrLocationRateLimited):
+		a.sendRequestError(driverID, wsmsg.TypeLocationUpdate, err.Error())
+	case err != nil:
+		a.log.WithFields(logger.LogFields{"driver_id": driverID}).Error("ws_location_update_failed", err)
+		a.sendRequestError(driverID, wsmsg.TypeLocationUpdate, "failed to update driver location")
+	}
+}
+
+// sendError reports a failed request back to the driver
+func (a *DriverWSAdapter) sendError(driverID string, message string) {
+	if err := a.manager.SendToUser(driverID, wsmsg.NewError(message)); err != nil {
+		a.log.Error("ws_send_error_failed", err)
+	}
+}
+
+// sendRequestError reports that a well-formed request of requestType failed
+func (a *DriverWSAdapter) sendRequestError(driverID string, requestType wsmsg.Type, message string) {
+	msg := wsmsg.ErrorMessage{Type: wsmsg.TypeError, Message: message, RequestType: requestType}
+	if err := a.manager.SendToUser(driverID, msg); err != nil {
+		a.log.Error("ws_send_error_failed", err)
 	}
 }
 
+// sendValidationError reports the invalid field of a rejected message
+func (a *DriverWSAdapter) sendValidationError(driverID string, requestType wsmsg.Type, fe *fieldError) {
+	msg := wsmsg.NewValidationError(requestType, fe.Field, fe.Error())
+	if err := a.manager.SendToUser(driverID, msg); err != nil {
+		a.log.Error("ws_send_error_failed", err)
+	}
+}
+
+// StartIdleSweeper closes driver connections that stopped answering pings.
+// A reaped driver no longer counts as connected, so matching skips them, and
+// the service takes a driver without a ride offline.
+func (a *DriverWSAdapter) StartIdleSweeper(ctx context.Context) {
+	a.manager.StartIdleSweeper(ctx, func(driverID string) {
+		a.log.WithFields(logger.LogFields{"driver_id": driverID}).Info("ws_idle_disconnect", "Idle driver disconnected")
+
+		idleCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		if err := a.service.HandleDriverIdle(idleCtx, driverID); err != nil {
+			a.log.WithFields(logger.LogFields{"driver_id": driverID}).Error("ws_idle_offline_failed", err)
+		}
+	})
+}
+
 // --- WebSocketManager Interface ---
 
 func (a *DriverWSAdapter) SendRideOffer(driverID string, offer interface{}) error {
-	msg := map[string]interface{}{
-		"type": "ride_offer",
-		"data": offer,
-	}
-	return a.manager.SendToUser(driverID, msg)
+	return a.manager.SendToUser(driverID, wsmsg.New(wsmsg.TypeRideOffer, offer))
 }
 
 func (a *DriverWSAdapter) SendRideDetails(driverID string, details interface{}) error {
-	msg := map[string]interface{}{
-		"type": "ride_details",
-		"data": details,
-	}
-	return a.manager.SendToUser(driverID, msg)
+	return a.manager.SendToUser(driverID, wsmsg.New(wsmsg.TypeRideDetails, details))
 }
 
 func (a *DriverWSAdapter) SendRideCancelled(driverID string, rideID string) error {
-	msg := map[string]interface{}{
-		"type": "ride_cancelled",
-		"data": map[string]string{
-			"ride_id": rideID,
-			"message": "Ride cancelled by passenger",
-		},
-	}
-	return a.manager.SendToUser(driverID, msg)
+	return a.manager.SendToUser(driverID, wsmsg.New(wsmsg.TypeRideCancelled, map[string]string{
+		"ride_id": rideID,
+		"message": "Ride cancelled by passenger",
+	}))
+}
+
+// SendRideStatus tells the driver their ride changed status without their doing,
+// e.g. support completed it
+func (a *DriverWSAdapter) SendRideStatus(driverID string, update interface{}) error {
+	return a.manager.SendToUser(driverID, wsmsg.New(wsmsg.TypeRideStatusUpdate, update))
+}
+
+// SendOfferCancelled tells a driver that an offer they hold is void, and why
+func (a *DriverWSAdapter) SendOfferCancelled(driverID, offerID, rideID, reason string) error {
+	return a.manager.SendToUser(driverID, wsmsg.New(wsmsg.TypeOfferCancelled, map[string]string{
+		"offer_id": offerID,
+		"ride_id":  rideID,
+		"message":  reason,
+	}))
+}
+
+// SendRideMessage relays a passenger's chat message to the driver
+func (a *DriverWSAdapter) SendRideMessage(driverID string, message interface{}) error {
+	return a.manager.SendToUser(driverID, wsmsg.New(wsmsg.TypeRideMessage, message))
+}
+
+// SendDestinationChanged tells the driver the passenger has a new drop-off
+func (a *DriverWSAdapter) SendDestinationChanged(driverID string, change interface{}) error {
+	return a.manager.SendToUser(driverID, wsmsg.New(wsmsg.TypeRideDestinationChanged, change))
 }
 
 func (a *DriverWSAdapter) BroadcastToAll(message interface{}) error {
@@ -173,6 +261,16 @@ func (a *DriverWSAdapter) BroadcastToAll(message interface{}) error {
 	return nil
 }
 
+// MetricsHandler serves driver connection send-queue metrics
+func (a *DriverWSAdapter) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	a.manager.MetricsHandler(w, r)
+}
+
 func (a *DriverWSAdapter) IsDriverConnected(driverID string) bool {
 	return a.manager.IsUserConnected(driverID)
 }
+
+// ForceDisconnect closes the connection of a driver an admin forced offline
+func (a *DriverWSAdapter) ForceDisconnect(driverID string) {
+	a.manager.Disconnect(driverID, pkgws.CloseSessionEnded, pkgws.ReasonForcedOffline)
+}
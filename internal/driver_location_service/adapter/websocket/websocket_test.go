@@ -0,0 +1,293 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"ride-hail/internal/driver_location_service/domain"
+	"ride-hail/pkg/auth"
+	"ride-hail/pkg/dbtest"
+	pkgws "ride-hail/pkg/websocket"
+	"ride-hail/pkg/wsmsg"
+)
+
+const testSecret = "test-secret"
+
+// rideResponse is one HandleDriverRideResponse call
+type rideResponse struct {
+	driverID, offerID, rideID string
+	accepted                  bool
+}
+
+// fakeService records the calls inbound messages make. Methods a test does not
+// expect fall through to the nil embedded interface and panic.
+type fakeService struct {
+	domain.DriverLocationService
+
+	responses chan rideResponse
+	locations chan [2]float64
+	addresses chan string    // address passed with each location
+	acks      chan [2]string // driver and offer IDs
+
+	locationErr error // returned by UpdateDriverLocation
+}
+
+func newFakeService() *fakeService {
+	return &fakeService{
+		responses: make(chan rideResponse, 4),
+		locations: make(chan [2]float64, 4),
+		addresses: make(chan string, 4),
+		acks:      make(chan [2]string, 4),
+	}
+}
+
+func (s *fakeService) AcknowledgeOffer(driverID, offerID string) error {
+	s.acks <- [2]string{driverID, offerID}
+	if offerID == "gone" {
+		return domain.ErrOfferNotFound
+	}
+	return nil
+}
+
+func (s *fakeService) HandleDriverRideResponse(ctx context.Context, driverID, offerID, rideID string, accepted bool) error {
+	s.responses <- rideResponse{driverID, offerID, rideID, accepted}
+	return nil
+}
+
+func (s *fakeService) UpdateDriverLocation(ctx context.Context, driverID string, latitude, longitude, accuracy, speed, heading float64, address string) (string, error) {
+	s.locations <- [2]float64{latitude, longitude}
+	s.addresses <- address
+	if s.locationErr != nil {
+		return "", s.locationErr
+	}
+	return "coord-1", nil
+}
+
+// connectDriver serves the adapter over svc and returns a socket authenticated as driver d1
+func connectDriver(t *testing.T, svc *fakeService) *websocket.Conn {
+	t.Helper()
+	return dialDriver(t, svc, "d1", "d1")
+}
+
+// dialDriver opens /ws/drivers/{pathID} and authenticates with a token for userID
+func dialDriver(t *testing.T, svc *fakeService, pathID, userID string) *websocket.Conn {
+	t.Helper()
+	jwt := auth.NewJWTManager(testSecret, time.Hour)
+	a := NewDriverWSAdapter(dbtest.Logger{}, jwt)
+	a.SetService(svc)
+	srv := httptest.NewServer(a)
+	t.Cleanup(srv.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws/drivers/"+pathID, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	tok, err := jwt.GenerateToken(userID, auth.RoleDriver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.WriteJSON(map[string]interface{}{"type": wsmsg.TypeAuth, "message": "Bearer " + tok}); err != nil {
+		t.Fatalf("send auth: %v", err)
+	}
+	return conn
+}
+
+// send writes a message of type typ with the given data
+func send(t *testing.T, conn *websocket.Conn, typ wsmsg.Type, data string) {
+	t.Helper()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"`+string(typ)+`","data":`+data+`}`)); err != nil {
+		t.Fatalf("send %s: %v", typ, err)
+	}
+}
+
+// readError reads the next frame, which must be an error
+func readError(t *testing.T, conn *websocket.Conn) wsmsg.ErrorMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg wsmsg.ErrorMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("read error frame: %v", err)
+	}
+	if msg.Type != wsmsg.TypeError {
+		t.Fatalf("got a %s frame, want an error", msg.Type)
+	}
+	return msg
+}
+
+func TestMalformedRideResponseGetsErrorFrame(t *testing.T) {
+	svc := newFakeService()
+	conn := connectDriver(t, svc)
+
+	send(t, conn, wsmsg.TypeRideResponse, `{"ride_id":"ride-1","accepted":true}`)
+
+	msg := readError(t, conn)
+	if msg.RequestType != wsmsg.TypeRideResponse || msg.Field != "offer_id" {
+		t.Errorf("error = %+v, want offer_id of ride_response rejected", msg)
+	}
+	if msg.Message != "offer_id: is required" {
+		t.Errorf("message = %q", msg.Message)
+	}
+	select {
+	case r := <-svc.responses:
+		t.Errorf("service got %+v from an invalid message", r)
+	default:
+	}
+}
+
+func TestValidRideResponseProceeds(t *testing.T) {
+	svc := newFakeService()
+	conn := connectDriver(t, svc)
+
+	send(t, conn, wsmsg.TypeRideResponse, `{"offer_id":"offer-1","ride_id":"ride-1","accepted":false}`)
+
+	select {
+	case r := <-svc.responses:
+		if r != (rideResponse{"d1", "offer-1", "ride-1", false}) {
+			t.Errorf("service got %+v, want d1 declining offer-1", r)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("ride_response never reached the service")
+	}
+}
+
+func TestLocationUpdateValidation(t *testing.T) {
+	svc := newFakeService()
+	conn := connectDriver(t, svc)
+
+	send(t, conn, wsmsg.TypeLocationUpdate, `{"latitude":"north","longitude":76.8}`)
+	if msg := readError(t, conn); msg.Field != "" || msg.Message == "" {
+		t.Errorf("undecodable data: error = %+v, want a decode error", msg)
+	}
+
+	send(t, conn, wsmsg.TypeLocationUpdate, `{"latitude":95,"longitude":76.8}`)
+	if msg := readError(t, conn); msg.RequestType != wsmsg.TypeLocationUpdate || msg.Field != "latitude" {
+		t.Errorf("out of range: error = %+v, want latitude of location_update rejected", msg)
+	}
+
+	send(t, conn, wsmsg.TypeLocationUpdate, `{"latitude":43.2,"longitude":76.8}`)
+	select {
+	case loc := <-svc.locations:
+		if loc != [2]float64{43.2, 76.8} {
+			t.Errorf("service got %v, want 43.2, 76.8", loc)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("location_update never reached the service")
+	}
+	if len(svc.locations) != 0 {
+		t.Error("an invalid location reached the service")
+	}
+}
+
+func TestRateLimitedLocationUpdateGetsErrorFrame(t *testing.T) {
+	svc := newFakeService()
+	svc.locationErr = domain.ErrLocationRateLimited
+	conn := connectDriver(t, svc)
+
+	send(t, conn, wsmsg.TypeLocationUpdate, `{"latitude":43.2,"longitude":76.8}`)
+
+	msg := readError(t, conn)
+	// The same text the REST endpoint answers with a 429
+	if msg.RequestType != wsmsg.TypeLocationUpdate || msg.Message != domain.ErrLocationRateLimited.Error() {
+		t.Errorf("error = %+v, want location_update rate limited", msg)
+	}
+	if len(svc.locations) != 1 {
+		t.Errorf("service got %d updates, want the one it rejected", len(svc.locations))
+	}
+}
+
+func TestFailedLocationUpdateGetsErrorFrame(t *testing.T) {
+	svc := newFakeService()
+	svc.locationErr = errors.New("connection reset")
+	conn := connectDriver(t, svc)
+
+	send(t, conn, wsmsg.TypeLocationUpdate, `{"latitude":43.2,"longitude":76.8}`)
+
+	msg := readError(t, conn)
+	if msg.RequestType != wsmsg.TypeLocationUpdate || strings.Contains(msg.Message, "connection reset") {
+		t.Errorf("error = %+v, want a location_update failure without the internal cause", msg)
+	}
+}
+
+func TestLocationUpdateAddressIsPassedThrough(t *testing.T) {
+	svc := newFakeService()
+	conn := connectDriver(t, svc)
+
+	send(t, conn, wsmsg.TypeLocationUpdate, `{"latitude":43.2,"longitude":76.8,"address":"Abay Ave 10"}`)
+	send(t, conn, wsmsg.TypeLocationUpdate, `{"latitude":43.2,"longitude":76.8}`)
+
+	// An empty address is left for the service to geocode, as for REST
+	for _, want := range []string{"Abay Ave 10", ""} {
+		select {
+		case got := <-svc.addresses:
+			if got != want {
+				t.Errorf("address = %q, want %q", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("location_update never reached the service")
+		}
+	}
+}
+
+func TestDriverSocketRejectsAnotherDriversPath(t *testing.T) {
+	conn := dialDriver(t, newFakeService(), "d2", "d1")
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var err error
+	for err == nil {
+		_, _, err = conn.ReadMessage()
+	}
+	if !websocket.IsCloseError(err, pkgws.CloseAuthFailed) {
+		t.Fatalf("read: %v, want the socket closed with %d", err, pkgws.CloseAuthFailed)
+	}
+
+}
+
+func TestDriverSocketTakesIdentityFromToken(t *testing.T) {
+	svc := newFakeService()
+	conn := connectDriver(t, svc)
+
+	send(t, conn, wsmsg.TypeRideResponse, `{"offer_id":"offer-1","ride_id":"ride-1","accepted":true}`)
+
+	select {
+	case r := <-svc.responses:
+		if r.driverID != "d1" {
+			t.Errorf("response attributed to %s, want d1 from the token", r.driverID)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("response never reached the service")
+	}
+}
+
+func TestOfferAckReachesService(t *testing.T) {
+	svc := newFakeService()
+	conn := connectDriver(t, svc)
+
+	send(t, conn, wsmsg.TypeOfferAck, `{"offer_id":"offer-1"}`)
+
+	select {
+	case ack := <-svc.acks:
+		if ack != [2]string{"d1", "offer-1"} {
+			t.Errorf("service got ack %v, want d1 acknowledging offer-1", ack)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("offer_ack never reached the service")
+	}
+}
+
+func TestOfferAckForUnknownOfferGetsErrorFrame(t *testing.T) {
+	svc := newFakeService()
+	conn := connectDriver(t, svc)
+
+	send(t, conn, wsmsg.TypeOfferAck, `{"offer_id":"gone"}`)
+
+	if msg := readError(t, conn); msg.Message != domain.ErrOfferNotFound.Error() {
+		t.Errorf("error = %+v, want %q", msg, domain.ErrOfferNotFound)
+	}
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ride-hail/internal/driver_location_service/domain"
+)
+
+// matchAsync runs matching for req in the background; the returned channel
+// gets its result
+func (ts *testService) matchAsync(req *domain.RideMatchingRequest) <-chan error {
+	done := make(chan error, 1)
+	go func() { done <- ts.HandleRideMatchingRequest(context.Background(), req) }()
+	return done
+}
+
+// awaitAckWait waits until driverID has been sent an offer and matching is
+// waiting on the clock for its ack
+func (ts *testService) awaitAckWait(t *testing.T, driverID string) {
+	t.Helper()
+	waitFor(t, "an offer to "+driverID+" awaiting its ack", func() bool {
+		return len(ts.ws.sentTo(driverID)) > 0 && ts.clock.Waiters() == 1
+	})
+}
+
+func matchResult(t *testing.T, done <-chan error) {
+	t.Helper()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("HandleRideMatchingRequest: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("matching did not finish")
+	}
+}
+
+func TestUnacknowledgedOfferMovesToNextDriver(t *testing.T) {
+	s := newTestService(t)
+	s.SetOfferAckTimeout(3 * time.Second)
+	s.onlineDriver("d1", 43.2390, 76.8900)
+	s.onlineDriver("d2", 43.2410, 76.8920)
+
+	done := s.matchAsync(rideRequest("A", 43.2390, 76.8900))
+
+	// d1's client never confirms the offer
+	s.awaitAckWait(t, "d1")
+	s.clock.Advance(3 * time.Second)
+
+	s.awaitAckWait(t, "d2")
+	if err := s.AcknowledgeOffer("d2", offerID("A", "d2")); err != nil {
+		t.Fatalf("AcknowledgeOffer(d2): %v", err)
+	}
+	matchResult(t, done)
+
+	if got := s.repo.offeredTo("A"); len(got) != 1 || got[0] != "d2" {
+		t.Errorf("offers counted for %v, want only d2", got)
+	}
+	if err := s.AcknowledgeOffer("d1", offerID("A", "d1")); !errors.Is(err, domain.ErrOfferNotFound) {
+		t.Errorf("late ack from d1: err = %v, want ErrOfferNotFound for the withdrawn offer", err)
+	}
+	if n := s.OfferStats().Pending; n != 1 {
+		t.Errorf("%d offers pending, want d2's", n)
+	}
+}
+
+func TestAcknowledgedOfferStaysPendingForResponseWindow(t *testing.T) {
+	s := newTestService(t)
+	s.SetOfferAckTimeout(3 * time.Second)
+	s.onlineDriver("d1", 43.2390, 76.8900)
+
+	done := s.matchAsync(rideRequest("A", 43.2390, 76.8900)) // 30s to answer
+	s.awaitAckWait(t, "d1")
+	if err := s.AcknowledgeOffer("d1", offerID("A", "d1")); err != nil {
+		t.Fatalf("AcknowledgeOffer: %v", err)
+	}
+	matchResult(t, done)
+
+	// Well past the ack timeout, the offer can still be answered. The clock
+	// still holds the unneeded ack timer alongside the offer timeout.
+	waitFor(t, "the offer timeout to start", func() bool { return s.clock.Waiters() == 2 })
+	s.clock.Advance(20 * time.Second)
+	if n := s.OfferStats().Pending; n != 1 {
+		t.Fatalf("%d offers pending 20s in, want 1", n)
+	}
+	if n := s.pub.rejections("A"); n != 0 {
+		t.Errorf("%d rejections published, want none", n)
+	}
+	if err := s.HandleDriverRideResponse(context.Background(), "d1", offerID("A", "d1"), "A", true); err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	if got := s.repo.currentRideID("d1"); got != "A" {
+		t.Errorf("d1 bound to ride %q, want A", got)
+	}
+}
+
+func TestAnswerBeforeAckCountsAsDelivered(t *testing.T) {
+	s := newTestService(t)
+	s.SetOfferAckTimeout(3 * time.Second)
+	s.onlineDriver("d1", 43.2390, 76.8900)
+	s.onlineDriver("d2", 43.2410, 76.8920)
+
+	done := s.matchAsync(rideRequest("A", 43.2390, 76.8900))
+	s.awaitAckWait(t, "d1")
+
+	// The client skips the ack and accepts straight away
+	if err := s.HandleDriverRideResponse(context.Background(), "d1", offerID("A", "d1"), "A", true); err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	matchResult(t, done)
+
+	if got := s.ws.sentTo("d2"); len(got) != 0 {
+		t.Errorf("d2 was sent %v after d1 accepted, want nothing", got)
+	}
+	if got := s.repo.currentRideID("d1"); got != "A" {
+		t.Errorf("d1 bound to ride %q, want A", got)
+	}
+}
+
+func TestAcknowledgeOfferRejectsAnotherDriver(t *testing.T) {
+	s := newTestService(t)
+	offer := s.pendingOffer("A", time.Minute)
+	if err := s.storeOffer(offer); err != nil {
+		t.Fatalf("storeOffer: %v", err)
+	}
+
+	if err := s.AcknowledgeOffer("d2", offer.OfferID); !errors.Is(err, domain.ErrOfferNotFound) {
+		t.Errorf("err = %v, want ErrOfferNotFound", err)
+	}
+	if offer.acked {
+		t.Error("offer acknowledged by a driver it was not sent to")
+	}
+}
@@ -0,0 +1,97 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"ride-hail/internal/driver_location_service/domain"
+)
+
+func TestCancellingMidMatchVoidsEveryOffer(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	s.onlineDriver("d1", 43.2390, 76.8900)
+	s.onlineDriver("d2", 43.2400, 76.8910)
+	if err := s.HandleRideMatchingRequest(ctx, rideRequest("A", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("HandleRideMatchingRequest: %v", err)
+	}
+	if n := s.OfferStats().Pending; n != 2 {
+		t.Fatalf("%d offers pending, want 2", n)
+	}
+
+	// The passenger cancels before anyone answers
+	if err := s.HandleRideStatusUpdate(ctx, "A", "", "CANCELLED", 0); err != nil {
+		t.Fatalf("HandleRideStatusUpdate: %v", err)
+	}
+
+	if n := s.OfferStats().Pending; n != 0 {
+		t.Errorf("%d offers pending after the cancel, want none", n)
+	}
+	for _, d := range []string{"d1", "d2"} {
+		void, ok := s.ws.lastSent(d, "offer_cancelled").(map[string]string)
+		if !ok || void["offer_id"] != offerID("A", d) || void["ride_id"] != "A" {
+			t.Errorf("%s was sent %v, want its offer for A voided", d, s.ws.sentTo(d))
+		}
+	}
+	waitFor(t, "the voided offers to be deleted", func() bool {
+		return s.repo.storedOffer(offerID("A", "d1")) == nil && s.repo.storedOffer(offerID("A", "d2")) == nil
+	})
+
+	// A driver whose client missed the void still tries to accept
+	err := s.HandleDriverRideResponse(ctx, "d1", offerID("A", "d1"), "A", true)
+	if !errors.Is(err, domain.ErrOfferNotFound) && !errors.Is(err, domain.ErrOfferExpired) {
+		t.Fatalf("late accept: err = %v, want the offer gone", err)
+	}
+	if got := s.repo.currentRideID("d1"); got != "" {
+		t.Errorf("d1 bound to ride %q, want none", got)
+	}
+	for _, m := range s.pub.to("driver_topic") {
+		if m.routingKey == "driver.response.A" && m.body["accepted"] == true {
+			t.Errorf("cancelled ride reported as accepted: %v", m.body)
+		}
+	}
+}
+
+func TestMatchingAfterCancelSendsNoOffers(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	s.onlineDriver("d1", 43.2390, 76.8900)
+
+	// The cancel is handled before a queued matching request for the ride
+	if err := s.HandleRideStatusUpdate(ctx, "A", "", "CANCELLED", 0); err != nil {
+		t.Fatalf("HandleRideStatusUpdate: %v", err)
+	}
+	if err := s.HandleRideMatchingRequest(ctx, rideRequest("A", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("HandleRideMatchingRequest: %v", err)
+	}
+
+	if got := s.ws.sentTo("d1"); len(got) != 0 {
+		t.Errorf("d1 was sent %v for a cancelled ride, want nothing", got)
+	}
+	if n := s.OfferStats().Pending; n != 0 {
+		t.Errorf("%d offers pending, want none", n)
+	}
+}
+
+func TestCancellingOneRideKeepsOtherOffers(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	s.onlineDriver("d1", 43.2390, 76.8900)
+	for _, rideID := range []string{"A", "B"} {
+		if err := s.HandleRideMatchingRequest(ctx, rideRequest(rideID, 43.2390, 76.8900)); err != nil {
+			t.Fatalf("match %s: %v", rideID, err)
+		}
+	}
+
+	if err := s.HandleRideStatusUpdate(ctx, "A", "", "CANCELLED", 0); err != nil {
+		t.Fatalf("HandleRideStatusUpdate: %v", err)
+	}
+
+	if n := s.OfferStats().Pending; n != 1 {
+		t.Errorf("%d offers pending, want B's", n)
+	}
+	if err := s.HandleDriverRideResponse(ctx, "d1", offerID("B", "d1"), "B", true); err != nil {
+		t.Errorf("accept B: %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"ride-hail/internal/driver_location_service/domain"
+)
+
+// forceOffline is the control message the admin service sends for driverID
+func forceOffline(driverID string) *domain.DriverControl {
+	return &domain.DriverControl{
+		DriverID: driverID,
+		Action:   domain.DriverControlForceOffline,
+		AdminID:  "admin-1",
+		Reason:   "unresponsive",
+	}
+}
+
+func TestForceOfflineClosesSocketAndEndsSession(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	s.repo.addDriver("d1")
+	if _, err := s.DriverGoOnline(ctx, "d1", 43.2389, 76.8897, ""); err != nil {
+		t.Fatalf("DriverGoOnline: %v", err)
+	}
+	s.ws.mu.Lock()
+	s.ws.connected["d1"] = true
+	s.ws.mu.Unlock()
+
+	if err := s.HandleDriverControl(ctx, forceOffline("d1")); err != nil {
+		t.Fatalf("HandleDriverControl: %v", err)
+	}
+	if s.IsDriverConnected("d1") {
+		t.Error("d1 still connected")
+	}
+	if got := s.ws.disconnected; len(got) != 1 || got[0] != "d1" {
+		t.Errorf("disconnected %v, want d1", got)
+	}
+	if n := len(s.repo.openSessions("d1")); n != 0 {
+		t.Errorf("%d open sessions, want the session ended", n)
+	}
+	if got := s.repo.status("d1"); got != domain.DriverStatusOffline {
+		t.Errorf("status = %s, want OFFLINE", got)
+	}
+	msgs := s.pub.to("driver_topic")
+	if len(msgs) == 0 || msgs[len(msgs)-1].body["status"] != domain.DriverStatusOffline {
+		t.Errorf("published %v, want an OFFLINE driver status last", msgs)
+	}
+}
+
+func TestForceOfflineOnAnotherInstanceIsANoOp(t *testing.T) {
+	// Every instance gets the message; by the time this one handles it the
+	// session is over and the socket is elsewhere
+	s := newTestService(t)
+	s.repo.addDriver("d1")
+
+	if err := s.HandleDriverControl(context.Background(), forceOffline("d1")); err != nil {
+		t.Fatalf("HandleDriverControl: %v", err)
+	}
+	if msgs := s.pub.to("driver_topic"); len(msgs) != 0 {
+		t.Errorf("published %v, want nothing for a driver with no session", msgs)
+	}
+}
+
+func TestUnknownDriverControlIsIgnored(t *testing.T) {
+	s := newTestService(t)
+	s.onlineDriver("d1", 43.2389, 76.8897)
+
+	msg := forceOffline("d1")
+	msg.Action = "reboot"
+	if err := s.HandleDriverControl(context.Background(), msg); err != nil {
+		t.Fatalf("HandleDriverControl: %v", err)
+	}
+	if !s.IsDriverConnected("d1") {
+		t.Error("d1 disconnected by an unknown action")
+	}
+}
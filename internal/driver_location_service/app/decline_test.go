@@ -0,0 +1,152 @@
+package app
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// offersTo counts the ride offers driverID has been sent
+func (ts *testService) offersTo(driverID string) int {
+	n := 0
+	for _, kind := range ts.ws.sentTo(driverID) {
+		if kind == "ride_offer" {
+			n++
+		}
+	}
+	return n
+}
+
+// excluded returns the drivers the last search left out, sorted
+func (ts *testService) excluded() string {
+	exclude := append([]string(nil), ts.repo.lastSearch().exclude...)
+	sort.Strings(exclude)
+	return strings.Join(exclude, ",")
+}
+
+func TestDeclinedDriversAreNotReoffered(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	s.onlineDriver("d1", 43.2390, 76.8900)
+	s.onlineDriver("d2", 43.2400, 76.8910)
+	if err := s.HandleRideMatchingRequest(ctx, rideRequest("A", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("HandleRideMatchingRequest: %v", err)
+	}
+
+	// d2's offer is still pending, so d1's decline doesn't re-offer yet
+	if err := s.HandleDriverRideResponse(ctx, "d1", offerID("A", "d1"), "A", false); err != nil {
+		t.Fatalf("d1 declines: %v", err)
+	}
+	s.onlineDriver("d3", 43.2410, 76.8920)
+	if err := s.HandleDriverRideResponse(ctx, "d2", offerID("A", "d2"), "A", false); err != nil {
+		t.Fatalf("d2 declines: %v", err)
+	}
+
+	waitFor(t, "the ride to be re-offered to d3", func() bool { return s.offersTo("d3") == 1 })
+	if got := s.excluded(); got != "d1,d2" {
+		t.Errorf("re-offer excluded %q, want d1,d2", got)
+	}
+	for _, d := range []string{"d1", "d2"} {
+		if n := s.offersTo(d); n != 1 {
+			t.Errorf("%s was offered the ride %d times, want once", d, n)
+		}
+	}
+}
+
+func TestOnlyDeclinedDriverLeftGetsNoReoffer(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	s.onlineDriver("d1", 43.2390, 76.8900)
+	if err := s.HandleRideMatchingRequest(ctx, rideRequest("A", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("HandleRideMatchingRequest: %v", err)
+	}
+
+	if err := s.HandleDriverRideResponse(ctx, "d1", offerID("A", "d1"), "A", false); err != nil {
+		t.Fatalf("d1 declines: %v", err)
+	}
+
+	waitFor(t, "a no-driver result", func() bool { return s.pub.rejections("A") >= 1 })
+	if n := s.offersTo("d1"); n != 1 {
+		t.Errorf("d1 was offered the ride %d times, want once", n)
+	}
+}
+
+func TestExpiredOfferExcludesTheDriver(t *testing.T) {
+	s := newTestService(t)
+	s.onlineDriver("d1", 43.2390, 76.8900)
+	if err := s.HandleRideMatchingRequest(context.Background(), rideRequest("A", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("HandleRideMatchingRequest: %v", err)
+	}
+	s.onlineDriver("d2", 43.2400, 76.8910)
+
+	// d1 never answers the 30s offer
+	waitFor(t, "the offer timeout to start", func() bool { return s.clock.Waiters() >= 1 })
+	s.clock.Advance(30 * time.Second)
+
+	waitFor(t, "the ride to be re-offered to d2", func() bool { return s.offersTo("d2") == 1 })
+	if got := s.excluded(); got != "d1" {
+		t.Errorf("re-offer excluded %q, want d1", got)
+	}
+	if n := s.offersTo("d1"); n != 1 {
+		t.Errorf("d1 was offered the ride %d times, want once", n)
+	}
+}
+
+func TestRematchKeepsExclusionsUntilTheRideIsTaken(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	s.onlineDriver("d1", 43.2390, 76.8900)
+	if err := s.HandleRideMatchingRequest(ctx, rideRequest("A", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("HandleRideMatchingRequest: %v", err)
+	}
+	if err := s.HandleDriverRideResponse(ctx, "d1", offerID("A", "d1"), "A", false); err != nil {
+		t.Fatalf("d1 declines: %v", err)
+	}
+	waitFor(t, "a no-driver result", func() bool { return s.pub.rejections("A") >= 1 })
+
+	// The ride service asks again; d1 is still left out
+	s.onlineDriver("d2", 43.2400, 76.8910)
+	if err := s.HandleRideMatchingRequest(ctx, rideRequest("A", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("rematch: %v", err)
+	}
+	if got := s.excluded(); got != "d1" {
+		t.Errorf("rematch excluded %q, want d1", got)
+	}
+	if err := s.HandleDriverRideResponse(ctx, "d2", offerID("A", "d2"), "A", true); err != nil {
+		t.Fatalf("d2 accepts: %v", err)
+	}
+
+	if ids := s.declinedDriverIDs("A"); len(ids) != 0 {
+		t.Errorf("exclusions %v kept after the ride was taken, want none", ids)
+	}
+	// Another ride is offered to d1 as usual
+	if err := s.HandleRideMatchingRequest(ctx, rideRequest("B", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("match B: %v", err)
+	}
+	if got := s.excluded(); got != "" {
+		t.Errorf("ride B excluded %q, want no one", got)
+	}
+}
+
+func TestExclusionsAreSweptAfterTTL(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	s.onlineDriver("d1", 43.2390, 76.8900)
+	if err := s.HandleRideMatchingRequest(ctx, rideRequest("A", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("HandleRideMatchingRequest: %v", err)
+	}
+	if err := s.HandleDriverRideResponse(ctx, "d1", offerID("A", "d1"), "A", false); err != nil {
+		t.Fatalf("d1 declines: %v", err)
+	}
+
+	s.sweepExpiredOffers(testNow.Add(declinedDriversTTL - time.Second))
+	if ids := s.declinedDriverIDs("A"); len(ids) != 1 {
+		t.Errorf("exclusions = %v before the TTL, want d1", ids)
+	}
+	s.sweepExpiredOffers(testNow.Add(declinedDriversTTL + time.Second))
+	if ids := s.declinedDriverIDs("A"); len(ids) != 0 {
+		t.Errorf("exclusions = %v after the TTL, want none", ids)
+	}
+}
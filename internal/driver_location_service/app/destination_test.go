@@ -0,0 +1,57 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"ride-hail/internal/driver_location_service/domain"
+)
+
+func destinationChange(driverID string) *domain.RideDestinationChange {
+	return &domain.RideDestinationChange{
+		RideID:                "A",
+		PassengerID:           "passenger-A",
+		DriverID:              driverID,
+		DestinationLocation:   domain.Location{Lat: 43.20, Lng: 76.80, Address: "Al-Farabi Ave 77"},
+		PreviousDestination:   domain.Location{Lat: 43.222015, Lng: 76.851511, Address: "Dostyk Ave 5"},
+		EstimatedFare:         2100,
+		PreviousEstimatedFare: 1450,
+		ChangedAt:             testNow,
+	}
+}
+
+func TestDestinationChangeReachesTheConnectedDriver(t *testing.T) {
+	s := newTestService(t)
+	s.onlineDriver("d1", 43.2389, 76.8897)
+	s.onlineDriver("d2", 43.2389, 76.8897)
+
+	if err := s.HandleRideDestinationChange(context.Background(), destinationChange("d1")); err != nil {
+		t.Fatalf("HandleRideDestinationChange: %v", err)
+	}
+	got, ok := s.ws.lastSent("d1", "destination_changed").(*domain.RideDestinationChange)
+	if !ok || got.DestinationLocation.Address != "Al-Farabi Ave 77" || got.EstimatedFare != 2100 {
+		t.Errorf("d1 got %v, want the new drop-off and fare", s.ws.lastSent("d1", "destination_changed"))
+	}
+	if sent := s.ws.sentTo("d2"); len(sent) != 0 {
+		t.Errorf("d2 was sent %v, want nothing", sent)
+	}
+}
+
+func TestDestinationChangeForADriverOnAnotherInstanceIsSkipped(t *testing.T) {
+	s := newTestService(t)
+
+	if err := s.HandleRideDestinationChange(context.Background(), destinationChange("d1")); err != nil {
+		t.Fatalf("HandleRideDestinationChange: %v", err)
+	}
+	if sent := s.ws.sentTo("d1"); len(sent) != 0 {
+		t.Errorf("sent %v to a driver not connected here", sent)
+	}
+}
+
+func TestDestinationChangeWithoutDriverIsRejected(t *testing.T) {
+	s := newTestService(t)
+
+	if err := s.HandleRideDestinationChange(context.Background(), destinationChange("")); err == nil {
+		t.Error("HandleRideDestinationChange accepted a change with no driver")
+	}
+}
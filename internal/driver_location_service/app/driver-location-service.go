@@ -3,12 +3,20 @@ package app
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"sort"
 	"sync"
 	"time"
 
 	"ride-hail/internal/driver_location_service/domain"
+	"ride-hail/pkg/apitime"
+	"ride-hail/pkg/clock"
+	"ride-hail/pkg/geocode"
 	"ride-hail/pkg/logger"
+	"ride-hail/pkg/pricing"
+	"ride-hail/pkg/ratelimit"
 )
 
 // DriverLocationService is the application service handling driver location business logic
@@ -19,10 +27,82 @@ type DriverLocationService struct {
 	wsMgr     domain.WebSocketManager
 
 	// Track pending ride offers with timeouts
-	pendingOffers   map[string]*RideOffer // offerID -> RideOffer
-	offerMu         sync.RWMutex
-	locationLimiter map[string]time.Time // driverID -> last update time
-	limiterMu       sync.RWMutex
+	pendingOffers  map[string]*RideOffer // offerID -> RideOffer
+	offerMu        sync.RWMutex
+	offersSwept    uint64                 // guarded by offerMu
+	offersRejected uint64                 // guarded by offerMu
+	voidedRides    map[string]time.Time   // rideID -> cancelled at, guarded by offerMu
+	takenRides     map[string]time.Time   // rideID -> accepted at, guarded by offerMu
+	closedOffers   map[string]closedOffer // offerID -> expired or withdrawn offer, guarded by offerMu
+
+	// Drivers who declined a ride or let its offer expire, so re-offers skip them;
+	// rideID -> drivers, guarded by offerMu
+	declinedBy map[string]*declinedDrivers
+
+	// Caps live location updates at one per locationUpdateInterval per driver
+	locationLimiter ratelimit.RateLimiter
+
+	// Last position broadcast per driver; moves under publishEpsilonMeters are not re-published
+	lastPublished        map[string][2]float64 // driverID -> {lat, lng}
+	lastPublishedMu      sync.Mutex
+	publishEpsilonMeters float64
+
+	// Per-driver locks so a driver can only bind to one ride at a time
+	driverLocks   map[string]*sync.Mutex
+	driverLocksMu sync.Mutex
+
+	// Re-rank nearby drivers by heading-aware ETA instead of plain distance
+	headingRerank bool
+
+	// Drivers whose location is older than this are not offered rides, 0 = no limit
+	maxLocationAge time.Duration
+	// Drivers whose location is older than this are ranked after fresher ones
+	staleLocationAge time.Duration
+
+	// Receives each shift summary after it is stored, nil = store only
+	shiftNotifier domain.ShiftSummaryNotifier
+
+	// How long a driver's client has to acknowledge an offer, 0 = don't wait
+	offerAckTimeout time.Duration
+
+	// Offers a ride may receive across all matching attempts, 0 = no cap
+	maxOffersPerRide int
+
+	// Search radius expansion when no driver is found
+	radiusStepKm   float64
+	maxRadiusKm    float64
+	expansionDelay time.Duration
+
+	// Radius used when a search names none, and the largest one it may ask for
+	defaultSearchRadiusKm float64
+	maxSearchRadiusKm     float64
+
+	// Minimum driver rating per vehicle type; types not listed have no floor
+	minRatings map[string]float64
+
+	// Drivers rated below this may not go online, 0 = no floor
+	minOnlineRating float64
+
+	// Driver's share of the fare in percent
+	driverSharePercent float64
+
+	// Pricing per vehicle type used to adjust the fare for the distance actually
+	// driven; nil means the estimated fare is final
+	fareTable *pricing.Table
+
+	// Wait at the pickup before a no-show may be reported, and the fee charged for it
+	noShowWait time.Duration
+	noShowFee  float64
+
+	// Fills empty addresses on saved locations
+	geocoder geocode.Geocoder
+
+	// Source of the current time for offer expiry, session summaries and timestamps
+	clock clock.Clock
+
+	// Short-lived demand heatmaps keyed by snapped box and cell size
+	heatmapCache map[string]heatmapCacheEntry
+	heatmapMu    sync.Mutex
 }
 
 // RideOffer represents a pending ride offer to a driver
@@ -33,6 +113,9 @@ type RideOffer struct {
 	RideRequest *domain.RideMatchingRequest
 	ExpiresAt   time.Time
 	Cancelled   bool
+
+	ack   chan struct{} // closed when the driver confirms delivery
+	acked bool          // guarded by offerMu
 }
 
 func NewDriverLocationService(
@@ -47,8 +130,152 @@ func NewDriverLocationService(
 		publisher:       publisher,
 		wsMgr:           wsMgr,
 		pendingOffers:   make(map[string]*RideOffer),
-		locationLimiter: make(map[string]time.Time),
+		voidedRides:     make(map[string]time.Time),
+		takenRides:      make(map[string]time.Time),
+		closedOffers:    make(map[string]closedOffer),
+		declinedBy:      make(map[string]*declinedDrivers),
+		locationLimiter: ratelimit.NewMemoryRateLimiter(),
+		lastPublished:   make(map[string][2]float64),
+		driverLocks:     make(map[string]*sync.Mutex),
+		heatmapCache:    make(map[string]heatmapCacheEntry),
+
+		driverSharePercent: defaultDriverSharePercent,
+		noShowWait:         defaultNoShowWait,
+		noShowFee:          defaultNoShowFee,
+		geocoder:           geocode.Noop{},
+		clock:              clock.New(),
+		radiusStepKm:       5,
+		maxRadiusKm:        15,
+		expansionDelay:     2 * time.Second,
+		maxLocationAge:     defaultMaxLocationAge,
+		staleLocationAge:   defaultStaleLocationAge,
+		offerAckTimeout:    defaultOfferAckTimeout,
+		maxOffersPerRide:   defaultMaxOffersPerRide,
+		minRatings:         make(map[string]float64),
+
+		defaultSearchRadiusKm: defaultSearchRadiusKm,
+		maxSearchRadiusKm:     defaultMaxSearchRadiusKm,
+	}
+}
+
+// SetMinRating sets the lowest driver rating matched for a vehicle type; 0 removes the floor
+func (s *DriverLocationService) SetMinRating(vehicleType string, rating float64) {
+	if rating <= 0 {
+		delete(s.minRatings, vehicleType)
+		return
 	}
+	s.minRatings[vehicleType] = rating
+}
+
+// SetMinOnlineRating sets the lowest driver rating allowed to go online; 0 removes the floor
+func (s *DriverLocationService) SetMinOnlineRating(rating float64) {
+	s.minOnlineRating = rating
+}
+
+// SetRadiusExpansion configures how matching widens its search when no driver is found.
+// A maxKm at or below the initial radius disables expansion.
+func (s *DriverLocationService) SetRadiusExpansion(stepKm, maxKm float64, delay time.Duration) {
+	s.radiusStepKm = stepKm
+	s.maxRadiusKm = maxKm
+	s.expansionDelay = delay
+}
+
+// Search radius bounds when none are configured
+const (
+	defaultSearchRadiusKm    = 5.0
+	defaultMaxSearchRadiusKm = 50.0
+)
+
+// SetSearchRadius sets the radius used when a ride request or nearby-driver
+// lookup names none and the largest radius either may ask for; larger ones
+// are clamped to maxKm
+func (s *DriverLocationService) SetSearchRadius(defaultKm, maxKm float64) {
+	s.defaultSearchRadiusKm = defaultKm
+	s.maxSearchRadiusKm = maxKm
+}
+
+// searchRadiusKm returns the radius to search for a requested one: the default
+// when none (or a non-positive one) was given, otherwise at most the maximum
+func (s *DriverLocationService) searchRadiusKm(requestedKm float64) float64 {
+	if requestedKm <= 0 {
+		return s.defaultSearchRadiusKm
+	}
+	return math.Min(requestedKm, s.maxSearchRadiusKm)
+}
+
+// SetPublishEpsilon sets the minimum move in meters before a location update is
+// broadcast again; 0 publishes every update
+func (s *DriverLocationService) SetPublishEpsilon(meters float64) {
+	s.publishEpsilonMeters = meters
+}
+
+// shouldPublishLocation reports whether the driver moved far enough since the last
+// broadcast, and if so records the new position as published
+func (s *DriverLocationService) shouldPublishLocation(driverID string, lat, lng float64) bool {
+	s.lastPublishedMu.Lock()
+	defer s.lastPublishedMu.Unlock()
+
+	if last, ok := s.lastPublished[driverID]; ok && s.publishEpsilonMeters > 0 &&
+		distanceMeters(last[0], last[1], lat, lng) < s.publishEpsilonMeters {
+		return false
+	}
+	s.lastPublished[driverID] = [2]float64{lat, lng}
+	return true
+}
+
+// SetClock replaces the system clock, e.g. with a clock.Fake to expire offers
+// without waiting
+func (s *DriverLocationService) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
+// SetGeocoder sets the reverse geocoder used to fill empty location addresses
+func (s *DriverLocationService) SetGeocoder(g geocode.Geocoder) {
+	s.geocoder = g
+}
+
+// locationUpdateInterval is the shortest gap allowed between a driver's live location updates
+const locationUpdateInterval = 3 * time.Second
+
+// SetLocationRateLimiter sets the limiter for live location updates, e.g. a
+// Redis-backed one so the limit holds across instances
+func (s *DriverLocationService) SetLocationRateLimiter(l ratelimit.RateLimiter) {
+	s.locationLimiter = l
+}
+
+// defaultDriverSharePercent is the driver's cut when none is configured
+const defaultDriverSharePercent = 80.0
+
+// SetDriverSharePercent sets the driver's share of the fare; values outside 0-100 are rejected
+func (s *DriverLocationService) SetDriverSharePercent(percent float64) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("driver share must be between 0 and 100, got %v", percent)
+	}
+	s.driverSharePercent = percent
+	return nil
+}
+
+// CalculateDriverEarnings returns the driver's cut of a fare
+func (s *DriverLocationService) CalculateDriverEarnings(fare float64) float64 {
+	return fare * s.driverSharePercent / 100
+}
+
+// SetHeadingRerank toggles heading-aware ranking of nearby drivers
+func (s *DriverLocationService) SetHeadingRerank(enabled bool) {
+	s.headingRerank = enabled
+}
+
+// driverLock returns the lock guarding ride assignment for a driver
+func (s *DriverLocationService) driverLock(driverID string) *sync.Mutex {
+	s.driverLocksMu.Lock()
+	defer s.driverLocksMu.Unlock()
+
+	lock, ok := s.driverLocks[driverID]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.driverLocks[driverID] = lock
+	}
+	return lock
 }
 
 // DriverGoOnline handles driver going online
@@ -57,7 +284,7 @@ func (s *DriverLocationService) DriverGoOnline(ctx context.Context, driverID str
 	log.Info("driver_going_online", "Driver attempting to go online")
 
 	// Validate driver exists
-	_, err := s.repo.GetDriver(ctx, driverID)
+	driver, err := s.repo.GetDriver(ctx, driverID)
 	if err != nil {
 		log.Error("get_driver_failed", err)
 		return "", fmt.Errorf("failed to get driver: %w", err)
@@ -67,20 +294,50 @@ func (s *DriverLocationService) DriverGoOnline(ctx context.Context, driverID str
 	// 	return "", fmt.Errorf("driver not verified")
 	// }
 
-	// Create new session
-	sessionID, err := s.repo.CreateDriverSession(ctx, driverID)
+	if s.minOnlineRating > 0 && driver.Rating < s.minOnlineRating {
+		log.WithFields(logger.LogFields{
+			"rating":     driver.Rating,
+			"min_rating": s.minOnlineRating,
+		}).Warn("driver_online_rejected", "Driver rating is below the minimum to go online")
+		return "", fmt.Errorf("%w: rating %.2f, minimum %.2f", domain.ErrRatingTooLow, driver.Rating, s.minOnlineRating)
+	}
+
+	// Reuse the active session if the driver is already online (e.g. another device)
+	// so that a driver never accumulates more than one open session. A concurrent
+	// go-online that slips past this check is resolved by CreateDriverSession.
+	activeSession, err := s.repo.GetActiveSession(ctx, driverID)
 	if err != nil {
-		log.Error("create_session_failed", err)
-		return "", fmt.Errorf("failed to create session: %w", err)
+		log.Error("get_session_failed", err)
+		return "", fmt.Errorf("failed to get session: %w", err)
+	}
+
+	var sessionID string
+	if activeSession != nil {
+		sessionID = activeSession.ID
+		log.Info("driver_session_reused", fmt.Sprintf("Driver already online, reusing session %s", sessionID))
+	} else {
+		sessionID, err = s.repo.CreateDriverSession(ctx, driverID)
+		if err != nil {
+			log.Error("create_session_failed", err)
+			return "", fmt.Errorf("failed to create session: %w", err)
+		}
 	}
 
 	// Save initial location
+	address = geocode.FillAddress(s.geocoder, address, latitude, longitude)
 	_, err = s.repo.SaveDriverLocation(ctx, driverID, latitude, longitude, address)
 	if err != nil {
 		log.Error("save_location_failed", err)
 		return "", fmt.Errorf("failed to save location: %w", err)
 	}
 
+	// A repeated go-online during a ride (app restart, second device) must not
+	// free the driver for new offers while they still have a passenger
+	if activeSession != nil && (driver.Status == domain.DriverStatusBusy || driver.Status == domain.DriverStatusEnRoute) {
+		log.Info("driver_online_success", fmt.Sprintf("Driver already online on a ride, keeping status %s", driver.Status))
+		return sessionID, nil
+	}
+
 	// Update driver status to AVAILABLE
 	err = s.repo.UpdateDriverStatus(ctx, driverID, domain.DriverStatusAvailable)
 	if err != nil {
@@ -92,7 +349,7 @@ func (s *DriverLocationService) DriverGoOnline(ctx context.Context, driverID str
 	statusUpdate := map[string]interface{}{
 		"driver_id": driverID,
 		"status":    domain.DriverStatusAvailable,
-		"timestamp": time.Now().Format(time.RFC3339),
+		"timestamp": apitime.Format(s.clock.Now()),
 	}
 	statusData, _ := json.Marshal(statusUpdate)
 	if err := s.publisher.PublishDriverStatus(ctx, "driver_topic", fmt.Sprintf("driver.status.%s", driverID), statusData); err != nil {
@@ -116,16 +373,33 @@ func (s *DriverLocationService) DriverGoOffline(ctx context.Context, driverID st
 	}
 
 	if session == nil {
-		return nil, fmt.Errorf("no active session found")
+		return nil, domain.ErrNoActiveSession
 	}
 
 	// End session
-	endedSession, err := s.repo.EndDriverSession(ctx, session.ID)
+	endedSession, err := s.repo.EndDriverSession(ctx, session.ID, s.clock.Now())
+	if errors.Is(err, domain.ErrNoActiveSession) {
+		return nil, err
+	}
 	if err != nil {
 		log.Error("end_session_failed", err)
 		return nil, fmt.Errorf("failed to end session: %w", err)
 	}
 
+	// Time on rides during the session, for the utilization summary
+	if endedSession.EndedAt != nil {
+		busy, err := s.repo.GetDriverBusyDuration(ctx, driverID, endedSession.StartedAt, *endedSession.EndedAt)
+		if err != nil {
+			log.Error("get_busy_duration_failed", err)
+		} else {
+			endedSession.BusyDuration = busy
+		}
+	}
+
+	// The session is over whatever happens next; a retry finds no open session,
+	// so this is the only chance to write its summary
+	s.recordShiftSummary(ctx, endedSession)
+
 	// Update driver status to OFFLINE
 	err = s.repo.UpdateDriverStatus(ctx, driverID, domain.DriverStatusOffline)
 	if err != nil {
@@ -137,47 +411,145 @@ func (s *DriverLocationService) DriverGoOffline(ctx context.Context, driverID st
 	statusUpdate := map[string]interface{}{
 		"driver_id": driverID,
 		"status":    domain.DriverStatusOffline,
-		"timestamp": time.Now().Format(time.RFC3339),
+		"timestamp": apitime.Format(s.clock.Now()),
 	}
 	statusData, _ := json.Marshal(statusUpdate)
 	if err := s.publisher.PublishDriverStatus(ctx, "driver_topic", fmt.Sprintf("driver.status.%s", driverID), statusData); err != nil {
 		log.Error("publish_driver_status_failed", err)
 	}
 
+	// Broadcast the first position after coming back online even if unchanged
+	s.lastPublishedMu.Lock()
+	delete(s.lastPublished, driverID)
+	s.lastPublishedMu.Unlock()
+
 	log.Info("driver_offline_success", "Driver now offline")
 	return endedSession, nil
 }
 
+// HandleDriverIdle takes a driver whose WebSocket was reaped as idle offline, so
+// they stop showing as available with no way to receive offers. A driver on a
+// ride keeps it and their status; they are expected to reconnect.
+func (s *DriverLocationService) HandleDriverIdle(ctx context.Context, driverID string) error {
+	log := s.log.WithFields(logger.LogFields{"driver_id": driverID})
+
+	ride, err := s.repo.GetDriverCurrentRide(ctx, driverID)
+	if err != nil {
+		return fmt.Errorf("failed to get current ride: %w", err)
+	}
+	if ride != nil {
+		log.Info("idle_driver_on_ride", "Idle driver has an active ride, leaving them online")
+		return nil
+	}
+
+	if _, err := s.DriverGoOffline(ctx, driverID); err != nil {
+		if errors.Is(err, domain.ErrNoActiveSession) {
+			return nil
+		}
+		return err
+	}
+	log.Info("idle_driver_offline", "Idle driver taken offline")
+	return nil
+}
+
+// DriverStartBreak pauses ride offers for an available driver without ending the session.
+// FindNearbyDrivers only matches AVAILABLE drivers, so ON_BREAK drivers are skipped.
+func (s *DriverLocationService) DriverStartBreak(ctx context.Context, driverID string) error {
+	return s.switchBreakStatus(ctx, driverID, domain.DriverStatusAvailable, domain.DriverStatusOnBreak, domain.ErrDriverNotAvailable)
+}
+
+// DriverEndBreak makes a driver on break eligible for offers again in the same session
+func (s *DriverLocationService) DriverEndBreak(ctx context.Context, driverID string) error {
+	return s.switchBreakStatus(ctx, driverID, domain.DriverStatusOnBreak, domain.DriverStatusAvailable, domain.ErrDriverNotOnBreak)
+}
+
+// switchBreakStatus moves an online driver from one status to another, returning
+// errWrongStatus when the driver is not currently in the expected status
+func (s *DriverLocationService) switchBreakStatus(ctx context.Context, driverID, from, to string, errWrongStatus error) error {
+	log := s.log.WithFields(logger.LogFields{"driver_id": driverID, "new_status": to})
+
+	// Serialize with ride assignment so a break can't start mid-offer acceptance
+	lock := s.driverLock(driverID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	session, err := s.repo.GetActiveSession(ctx, driverID)
+	if err != nil {
+		log.Error("get_session_failed", err)
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil {
+		return domain.ErrNoActiveSession
+	}
+
+	driver, err := s.repo.GetDriver(ctx, driverID)
+	if err != nil {
+		log.Error("get_driver_failed", err)
+		return fmt.Errorf("failed to get driver: %w", err)
+	}
+	if driver.Status != from {
+		return errWrongStatus
+	}
+
+	if err := s.repo.UpdateDriverStatus(ctx, driverID, to); err != nil {
+		log.Error("update_status_failed", err)
+		return fmt.Errorf("failed to update status: %w", err)
+	}
+
+	statusUpdate := map[string]interface{}{
+		"driver_id": driverID,
+		"status":    to,
+		"timestamp": apitime.Format(s.clock.Now()),
+	}
+	statusData, _ := json.Marshal(statusUpdate)
+	if err := s.publisher.PublishDriverStatus(ctx, "driver_topic", fmt.Sprintf("driver.status.%s", driverID), statusData); err != nil {
+		log.Error("publish_driver_status_failed", err)
+	}
+
+	log.Info("driver_break_status_changed", fmt.Sprintf("Driver status changed from %s to %s", from, to))
+	return nil
+}
+
 // UpdateDriverLocation updates driver's current location with rate limiting
 func (s *DriverLocationService) UpdateDriverLocation(ctx context.Context, driverID string, latitude, longitude, accuracy, speed, heading float64, address string) (string, error) {
 	log := s.log.WithFields(logger.LogFields{"driver_id": driverID})
 
-	// Rate limit: max 1 update per 3 seconds
-	s.limiterMu.Lock()
-	lastUpdate, exists := s.locationLimiter[driverID]
-	if exists && time.Since(lastUpdate) < 3*time.Second {
-		s.limiterMu.Unlock()
-		return "", fmt.Errorf("rate limit exceeded: max 1 update per 3 seconds")
+	// Rate limit: max 1 update per locationUpdateInterval. If the limiter's
+	// backend is down the update goes through rather than freezing every driver.
+	allowed, err := s.locationLimiter.Allow(ctx, driverID, locationUpdateInterval)
+	if err != nil {
+		log.Error("location_rate_limit_failed", err)
+	} else if !allowed {
+		return "", domain.ErrLocationRateLimited
 	}
-	s.locationLimiter[driverID] = time.Now()
-	s.limiterMu.Unlock()
 
 	// Save location as current
+	address = geocode.FillAddress(s.geocoder, address, latitude, longitude)
 	coordinateID, err := s.repo.SaveDriverLocation(ctx, driverID, latitude, longitude, address)
 	if err != nil {
 		log.Error("save_location_failed", err)
 		return "", fmt.Errorf("failed to save location: %w", err)
 	}
 
-	// Archive to location history with metrics
-	// Get current ride ID if driver is busy
-	rideID := "" // Could be retrieved from driver state
+	// Archive to location history with metrics, under the driver's active ride
+	// so the ride's route can be rebuilt from it
+	ride := s.currentRide(ctx, driverID)
+	rideID := ""
+	if ride != nil {
+		rideID = ride.RideID
+	}
 	err = s.repo.ArchiveLocation(ctx, driverID, latitude, longitude, accuracy, speed, heading, rideID)
 	if err != nil {
 		log.Error("archive_location_failed", err)
 		// Don't fail the request if archiving fails
 	}
 
+	// Stationary drivers (e.g. at a red light) are archived but not re-broadcast
+	if !s.shouldPublishLocation(driverID, latitude, longitude) {
+		log.Debug("location_publish_skipped", "Driver has not moved since last broadcast")
+		return coordinateID, nil
+	}
+
 	// Publish location update to fanout exchange
 	locationUpdate := map[string]interface{}{
 		"driver_id":       driverID,
@@ -185,8 +557,9 @@ func (s *DriverLocationService) UpdateDriverLocation(ctx context.Context, driver
 		"location":        map[string]float64{"latitude": latitude, "longitude": longitude},
 		"speed_kmh":       speed,
 		"heading_degrees": heading,
-		"timestamp":       time.Now().Format(time.RFC3339),
+		"timestamp":       apitime.Format(s.clock.Now()),
 	}
+	addLocationAudience(locationUpdate, ride)
 	updateData, _ := json.Marshal(locationUpdate)
 	if err := s.publisher.PublishLocationUpdate(ctx, "location_fanout", updateData); err != nil {
 		log.Error("publish_location_failed", err)
@@ -196,21 +569,173 @@ func (s *DriverLocationService) UpdateDriverLocation(ctx context.Context, driver
 	return coordinateID, nil
 }
 
-// HandleRideMatchingRequest processes incoming ride requests for matching
+// currentRide returns the driver's active ride, or nil if they have none or it
+// can't be looked up; a location update goes ahead either way
+func (s *DriverLocationService) currentRide(ctx context.Context, driverID string) *domain.CurrentRide {
+	ride, err := s.repo.GetDriverCurrentRide(ctx, driverID)
+	if err != nil {
+		s.log.WithFields(logger.LogFields{"driver_id": driverID}).Error("location_audience_failed", err)
+		return nil
+	}
+	return ride
+}
+
+// addLocationAudience adds the driver's active ride, its passenger and its status
+// to a location update. Every ride service instance receives the update, and
+// with these fields the one holding the passenger's WebSocket can deliver it
+// without having seen the match. Drivers without a ride are published as is.
+func addLocationAudience(update map[string]interface{}, ride *domain.CurrentRide) {
+	if ride == nil {
+		return
+	}
+	update["ride_id"] = ride.RideID
+	update["passenger_id"] = ride.PassengerID
+	update["ride_status"] = ride.Status
+}
+
+// UpdateDriverLocationBatch flushes a driver's offline-buffered locations.
+// It bypasses the live rate limiter: every point is archived and only the newest becomes current.
+func (s *DriverLocationService) UpdateDriverLocationBatch(ctx context.Context, driverID string, points []domain.LocationUpdate, address string) (string, error) {
+	log := s.log.WithFields(logger.LogFields{"driver_id": driverID, "points": len(points)})
+
+	if len(points) == 0 {
+		return "", fmt.Errorf("location batch is empty")
+	}
+	if len(points) > domain.MaxLocationBatchSize {
+		return "", fmt.Errorf("location batch too large: max %d points", domain.MaxLocationBatchSize)
+	}
+	for i := 1; i < len(points); i++ {
+		if points[i].Timestamp.Before(points[i-1].Timestamp) {
+			return "", fmt.Errorf("location batch must be ordered by timestamp")
+		}
+	}
+
+	// The address belongs to the newest point, which becomes current
+	latest := points[len(points)-1]
+	address = geocode.FillAddress(s.geocoder, address, latest.Latitude, latest.Longitude)
+	coordinateID, err := s.repo.SaveLocationBatch(ctx, driverID, points, address)
+	if err != nil {
+		log.Error("save_location_batch_failed", err)
+		return "", fmt.Errorf("failed to save location batch: %w", err)
+	}
+
+	// Only the newest point is relevant for live tracking
+	locationUpdate := map[string]interface{}{
+		"driver_id":       driverID,
+		"ride_id":         latest.RideID,
+		"location":        map[string]float64{"latitude": latest.Latitude, "longitude": latest.Longitude},
+		"speed_kmh":       latest.SpeedKmh,
+		"heading_degrees": latest.HeadingDegrees,
+		"timestamp":       apitime.Format(latest.Timestamp),
+	}
+	addLocationAudience(locationUpdate, s.currentRide(ctx, driverID))
+	updateData, _ := json.Marshal(locationUpdate)
+	if err := s.publisher.PublishLocationUpdate(ctx, "location_fanout", updateData); err != nil {
+		log.Error("publish_location_failed", err)
+	}
+
+	log.Info("location_batch_saved", "Buffered location batch flushed")
+	return coordinateID, nil
+}
+
+// findDriversExpanding searches for drivers, widening the radius step by step
+// (with a short wait so new drivers can come online) until drivers are found
+// or the configured maximum radius has been searched.
+// Drivers in exclude are never returned.
+func (s *DriverLocationService) findDriversExpanding(ctx context.Context, req *domain.RideMatchingRequest, radiusMeters float64, exclude []string) ([]*domain.NearbyDriver, error) {
+	for {
+		drivers, err := s.repo.FindNearbyDrivers(ctx, req.PickupLocation.Lat, req.PickupLocation.Lng, req.RideType, radiusMeters, s.minRatings[req.RideType], req.PassengerCount, 10, exclude)
+		if err != nil {
+			return nil, err
+		}
+
+		maxRadiusMeters := math.Min(s.maxRadiusKm, s.maxSearchRadiusKm) * 1000
+		if len(drivers) > 0 || s.radiusStepKm <= 0 || radiusMeters >= maxRadiusMeters {
+			return drivers, nil
+		}
+
+		radiusMeters += s.radiusStepKm * 1000
+		if radiusMeters > maxRadiusMeters {
+			radiusMeters = maxRadiusMeters
+		}
+		s.log.WithFields(logger.LogFields{
+			"ride_id":       req.RideID,
+			"radius_meters": radiusMeters,
+		}).Info("matching_radius_expanded", "No drivers found, expanding search radius")
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-s.clock.After(s.expansionDelay):
+		}
+	}
+}
+
+// FindNearbyDrivers lists available drivers around a point with their connection state,
+// connected drivers first since only they can be offered rides. minSeats <= 1 matches any vehicle.
+// radiusKm <= 0 searches the default radius; larger than the maximum is clamped.
+func (s *DriverLocationService) FindNearbyDrivers(ctx context.Context, latitude, longitude float64, vehicleType string, radiusKm float64, minSeats, limit int) ([]*domain.NearbyDriver, error) {
+	radiusKm = s.searchRadiusKm(radiusKm)
+	drivers, err := s.repo.FindNearbyDrivers(ctx, latitude, longitude, vehicleType, radiusKm*1000, s.minRatings[vehicleType], minSeats, limit, nil)
+	if err != nil {
+		s.log.Error("find_nearby_drivers_failed", err)
+		return nil, err
+	}
+	s.markStaleness(drivers)
+	s.markConnected(drivers)
+	return drivers, nil
+}
+
+// IsDriverConnected reports whether the driver has a live WebSocket
+func (s *DriverLocationService) IsDriverConnected(driverID string) bool {
+	return s.wsMgr.IsDriverConnected(driverID)
+}
+
+// markConnected sets each driver's Connected flag and moves connected drivers to
+// the front, keeping the existing order within each group
+func (s *DriverLocationService) markConnected(drivers []*domain.NearbyDriver) {
+	for _, d := range drivers {
+		d.Connected = s.wsMgr.IsDriverConnected(d.DriverID)
+	}
+	sort.SliceStable(drivers, func(i, j int) bool {
+		return drivers[i].Connected && !drivers[j].Connected
+	})
+}
+
+// HandleRideMatchingRequest processes incoming ride requests for matching. A new
+// request means the ride needs a driver again, so an earlier acceptance no
+// longer stops offers for it.
 func (s *DriverLocationService) HandleRideMatchingRequest(ctx context.Context, req *domain.RideMatchingRequest) error {
+	s.releaseRide(req.RideID)
+	return s.matchRide(ctx, req)
+}
+
+// matchRide offers the ride to nearby drivers one by one until the offer cap is
+// reached, the candidates run out or one of them accepts
+func (s *DriverLocationService) matchRide(ctx context.Context, req *domain.RideMatchingRequest) error {
 	log := s.log.WithFields(logger.LogFields{
 		"ride_id":        req.RideID,
 		"correlation_id": req.CorrelationID,
 	})
 	log.Info("ride_matching_request", "Processing ride matching request")
 
-	// Find nearby available drivers
-	radiusMeters := 5000.0 // 5km default
-	if req.MaxDistanceKM > 0 {
-		radiusMeters = req.MaxDistanceKM * 1000
+	offerBudget, ok := s.remainingOffers(ctx, req.RideID)
+	if !ok {
+		log.Info("offer_cap_reached", fmt.Sprintf("Ride already had %d offers, giving up", s.maxOffersPerRide))
+		s.sendDriverResponse(ctx, req, "", false, "No drivers available")
+		return nil
+	}
+
+	// Find nearby available drivers within the requested radius, bounded server-side
+	radiusMeters := s.searchRadiusKm(req.MaxDistanceKM) * 1000
+
+	// Drivers who declined this ride or let an offer for it expire aren't asked again
+	exclude := s.declinedDriverIDs(req.RideID)
+	if len(exclude) > 0 {
+		log.Debug("drivers_excluded", fmt.Sprintf("Excluding %d drivers who already passed on the ride", len(exclude)))
 	}
 
-	nearbyDrivers, err := s.repo.FindNearbyDrivers(ctx, req.PickupLocation.Lat, req.PickupLocation.Lng, req.RideType, radiusMeters, 10)
+	nearbyDrivers, err := s.findDriversExpanding(ctx, req, radiusMeters, exclude)
 	if err != nil {
 		log.Error("find_drivers_failed", err)
 		return fmt.Errorf("failed to find nearby drivers: %w", err)
@@ -219,22 +744,50 @@ func (s *DriverLocationService) HandleRideMatchingRequest(ctx context.Context, r
 	if len(nearbyDrivers) == 0 {
 		log.Info("no_drivers_available", "No drivers found for matching")
 		// Send rejection response
-		s.sendDriverResponse(ctx, req.RideID, "", false, "No drivers available", req.CorrelationID)
+		s.sendDriverResponse(ctx, req, "", false, "No drivers available")
 		return nil
 	}
 
 	log.Info("drivers_found", fmt.Sprintf("Found %d nearby drivers", len(nearbyDrivers)))
 
+	if s.headingRerank {
+		rankDriversByETA(nearbyDrivers, req.PickupLocation.Lat, req.PickupLocation.Lng)
+	}
+
+	s.markStaleness(nearbyDrivers)
+	s.markConnected(nearbyDrivers)
+	if !nearbyDrivers[0].Connected {
+		log.Info("no_connected_drivers", fmt.Sprintf("None of %d nearby drivers is connected", len(nearbyDrivers)))
+		s.sendDriverResponse(ctx, req, "", false, "No drivers available")
+		return nil
+	}
+
 	// Send ride offers to drivers (with timeout)
 	timeout := 30 * time.Second
 	if req.TimeoutSeconds > 0 {
 		timeout = time.Duration(req.TimeoutSeconds) * time.Second
 	}
 
-	for _, driver := range nearbyDrivers {
-		// Check if driver is WebSocket connected
-		if !s.wsMgr.IsDriverConnected(driver.DriverID) {
-			log.Debug("driver_not_connected", fmt.Sprintf("Driver %s not connected", driver.DriverID))
+	offered := 0
+	for i, driver := range nearbyDrivers {
+		if offerBudget >= 0 && offered >= offerBudget {
+			log.Info("offer_cap_reached", fmt.Sprintf("Stopping after %d offers, the per-ride cap", s.maxOffersPerRide))
+			break
+		}
+		// Connected drivers come first; the rest can't receive an offer
+		if !driver.Connected {
+			log.Debug("drivers_not_connected", fmt.Sprintf("Skipping %d nearby drivers without a connection", len(nearbyDrivers)-i))
+			break
+		}
+		if s.rideTaken(req.RideID) {
+			log.Info("ride_taken_during_matching", "Ride was accepted, no further offers sent")
+			return nil
+		}
+		if reason := s.checkDriverPresence(driver, s.clock.Now()); reason != "" {
+			log.WithFields(logger.LogFields{
+				"driver_id": driver.DriverID,
+				"reason":    reason,
+			}).Info("driver_skipped", "Skipping driver that failed the presence check")
 			continue
 		}
 
@@ -245,13 +798,21 @@ func (s *DriverLocationService) HandleRideMatchingRequest(ctx context.Context, r
 			RideID:      req.RideID,
 			DriverID:    driver.DriverID,
 			RideRequest: req,
-			ExpiresAt:   time.Now().Add(timeout),
+			ExpiresAt:   s.clock.Now().Add(timeout),
 		}
 
 		// Store pending offer
-		s.offerMu.Lock()
-		s.pendingOffers[offerID] = offer
-		s.offerMu.Unlock()
+		if err := s.storeOffer(offer); errors.Is(err, errRideVoided) {
+			log.Info("ride_cancelled_during_matching", "Ride was cancelled, no further offers sent")
+			return nil
+		} else if errors.Is(err, errRideTaken) {
+			log.Info("ride_taken_during_matching", "Ride was accepted, no further offers sent")
+			return nil
+		} else if err != nil {
+			log.Error("store_offer_failed", fmt.Errorf("%w (%d), offer to driver %s dropped", err, maxPendingOffers, driver.DriverID))
+			continue
+		}
+		s.persistOffer(ctx, offer)
 
 		// Send offer via WebSocket
 		offerMsg := map[string]interface{}{
@@ -261,30 +822,55 @@ func (s *DriverLocationService) HandleRideMatchingRequest(ctx context.Context, r
 			"pickup_location":                 req.PickupLocation,
 			"destination_location":            req.DestinationLocation,
 			"estimated_fare":                  req.EstimatedFare,
-			"driver_earnings":                 req.EstimatedFare * 0.8, // 80% for driver
+			"driver_earnings":                 s.CalculateDriverEarnings(req.EstimatedFare),
 			"distance_to_pickup_km":           driver.DistanceKm,
 			"estimated_ride_duration_minutes": 15, // Placeholder
-			"expires_at":                      offer.ExpiresAt.Format(time.RFC3339),
+			"expires_at":                      apitime.Format(offer.ExpiresAt),
 		}
 
 		err = s.wsMgr.SendRideOffer(driver.DriverID, offerMsg)
 		if err != nil {
 			log.Error("send_offer_failed", err)
+			s.withdrawOffer(offerID)
+			continue
+		}
+
+		// Only count the offer once the driver's client confirms it arrived; an
+		// answer counts as confirmation
+		if !s.awaitOfferAck(ctx, offer) {
+			log.WithFields(logger.LogFields{
+				"driver_id": driver.DriverID,
+			}).Info("offer_not_acknowledged", "Driver did not acknowledge the offer, moving on")
+			s.withdrawOffer(offerID)
 			continue
 		}
 
 		log.Info("offer_sent", fmt.Sprintf("Ride offer sent to driver %s", driver.DriverID))
+		offered++
+		if err := s.repo.RecordOfferSent(ctx, driver.DriverID, req.RideID, offerID); err != nil {
+			log.Error("record_offer_sent_failed", err)
+		}
 
 		// Set timeout to cancel offer
 		go s.handleOfferTimeout(offer)
+
+		// The driver may have accepted before acknowledging
+		if s.rideTaken(req.RideID) {
+			log.Info("ride_taken_during_matching", "Ride was accepted, no further offers sent")
+			return nil
+		}
 	}
 
+	if offered == 0 {
+		log.Info("no_present_drivers", "No nearby driver could be offered the ride")
+		s.sendDriverResponse(ctx, req, "", false, "No drivers available")
+	}
 	return nil
 }
 
 // handleOfferTimeout cancels offer if not accepted within timeout
 func (s *DriverLocationService) handleOfferTimeout(offer *RideOffer) {
-	time.Sleep(time.Until(offer.ExpiresAt))
+	<-s.clock.After(offer.ExpiresAt.Sub(s.clock.Now()))
 
 	s.offerMu.Lock()
 	defer s.offerMu.Unlock()
@@ -293,7 +879,28 @@ func (s *DriverLocationService) handleOfferTimeout(offer *RideOffer) {
 	if existingOffer, exists := s.pendingOffers[offer.OfferID]; exists && !existingOffer.Cancelled {
 		existingOffer.Cancelled = true
 		delete(s.pendingOffers, offer.OfferID)
+		s.closeOfferLocked(existingOffer, s.clock.Now())
+		s.declineLocked(existingOffer, s.clock.Now())
 		s.log.Info("offer_expired", fmt.Sprintf("Offer %s expired", offer.OfferID))
+		go s.recordOfferResponse(offer, domain.OfferResponseExpired)
+		go s.forgetOffers(offer.OfferID)
+		if s.reofferNeededLocked(offer.RideID) {
+			go s.reofferRide(offer.RideRequest)
+		}
+	}
+}
+
+// recordOfferResponse stores the driver's answer for acceptance-rate stats; failures are only logged
+func (s *DriverLocationService) recordOfferResponse(offer *RideOffer, response string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := s.repo.RecordOfferResponse(ctx, offer.DriverID, offer.RideID, offer.OfferID, response); err != nil {
+		s.log.WithFields(logger.LogFields{
+			"driver_id": offer.DriverID,
+			"offer_id":  offer.OfferID,
+			"response":  response,
+		}).Error("record_offer_response_failed", err)
 	}
 }
 
@@ -308,53 +915,131 @@ func (s *DriverLocationService) HandleDriverRideResponse(ctx context.Context, dr
 	// Get offer
 	s.offerMu.Lock()
 	offer, exists := s.pendingOffers[offerID]
-	if !exists || offer.Cancelled {
+	if !exists || offer.DriverID != driverID || (rideID != "" && offer.RideID != rideID) {
+		closed, wasClosed := s.closedOffers[offerID]
 		s.offerMu.Unlock()
+		if wasClosed && closed.driverID == driverID {
+			log.Info("offer_expired", "Offer already expired or withdrawn")
+			return domain.ErrOfferExpired
+		}
 		log.Info("offer_not_found", "Offer not found or expired")
-		return fmt.Errorf("offer not found or expired")
+		return domain.ErrOfferNotFound
+	}
+	// Past its expiry but not yet removed by the timeout; leave that to it
+	if offer.Cancelled || !s.clock.Now().Before(offer.ExpiresAt) {
+		s.offerMu.Unlock()
+		log.Info("offer_expired", "Offer already expired or withdrawn")
+		return domain.ErrOfferExpired
 	}
 
-	// Mark as handled
+	// Mark as handled; an answer also proves the offer was delivered, so a
+	// matching pass waiting for its ack moves on
+	now := s.clock.Now()
 	delete(s.pendingOffers, offerID)
+	s.ackLocked(offer)
+	var reoffer bool
+	var siblings []*RideOffer
+	if !accepted {
+		s.declineLocked(offer, now)
+		reoffer = s.reofferNeededLocked(offer.RideID)
+	} else if s.takeRideLocked(offer.RideID, now) {
+		// The other drivers holding an offer for this ride can no longer get it
+		siblings = s.withdrawRideOffersLocked(offer.RideID, now)
+	} else {
+		s.closeOfferLocked(offer, now)
+		s.offerMu.Unlock()
+		go s.forgetOffers(offerID)
+		log.Info("ride_already_taken", "Another driver accepted the ride first")
+		go s.recordOfferResponse(offer, domain.OfferResponseAcceptFailed)
+		return domain.ErrOfferExpired
+	}
 	s.offerMu.Unlock()
+	go s.forgetOffers(offerID)
+	rideID = offer.RideID // REST responses identify the offer only
 
 	if !accepted {
 		log.Info("driver_rejected", "Driver rejected ride offer")
-		// Could try next driver in the list
+		go s.recordOfferResponse(offer, domain.OfferResponseRejected)
+		if reoffer {
+			go s.reofferRide(offer.RideRequest)
+		}
 		return nil
 	}
 
+	for _, sibling := range siblings {
+		if err := s.wsMgr.SendOfferCancelled(sibling.DriverID, sibling.OfferID, rideID, offerTakenReason); err != nil {
+			log.Error("send_offer_cancelled_failed", err)
+		}
+	}
+
 	log.Info("driver_accepted", "Driver accepted ride offer")
 
-	// Update driver status to EN_ROUTE
-	err := s.repo.UpdateDriverStatus(ctx, driverID, domain.DriverStatusEnRoute)
+	// Serialize acceptances per driver so two offers can't both bind the same driver
+	lock := s.driverLock(driverID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	current, err := s.repo.GetDriver(ctx, driverID)
 	if err != nil {
-		log.Error("update_status_failed", err)
-		return fmt.Errorf("failed to update driver status: %w", err)
+		log.Error("get_driver_failed", err)
+		go s.recordOfferResponse(offer, domain.OfferResponseAcceptFailed)
+		s.releaseRide(rideID)
+		go s.reofferRide(offer.RideRequest)
+		return fmt.Errorf("failed to get driver: %w", err)
+	}
+	if current.Status == domain.DriverStatusEnRoute || current.Status == domain.DriverStatusBusy {
+		log.Info("driver_already_assigned", "Driver already on another ride, rejecting offer")
+		go s.recordOfferResponse(offer, domain.OfferResponseAcceptFailed)
+		s.releaseRide(rideID)
+		go s.reofferRide(offer.RideRequest)
+		return fmt.Errorf("%w (status %s)", domain.ErrDriverAssigned, current.Status)
+	}
+	// The ride may have been cancelled after the offer was taken off the map
+	if s.rideVoided(offer.RideID) {
+		log.Info("accept_after_cancel", "Ride was cancelled before the acceptance went through")
+		go s.recordOfferResponse(offer, domain.OfferResponseAcceptFailed)
+		return domain.ErrOfferExpired
 	}
 
-	// Set current ride
+	// Bind the driver to the ride (BUSY); EN_ROUTE only follows once the driver
+	// reports heading to the pickup through DriverEnRoute
 	err = s.repo.SetDriverCurrentRide(ctx, driverID, rideID)
 	if err != nil {
 		log.Error("set_ride_failed", err)
+		go s.recordOfferResponse(offer, domain.OfferResponseAcceptFailed)
+		s.releaseRide(rideID)
+		go s.reofferRide(offer.RideRequest)
+		return fmt.Errorf("failed to update driver status: %w", err)
 	}
 
-	// Get driver info
+	// Only a bound driver counts as having accepted
+	go s.recordOfferResponse(offer, domain.OfferResponseAccepted)
+
+	// Get driver info for the ride details. The driver is already bound, so a
+	// failure here must not keep the match from the ride service.
 	driver, err := s.repo.GetDriver(ctx, driverID)
 	if err != nil {
 		log.Error("get_driver_failed", err)
-		return fmt.Errorf("failed to get driver: %w", err)
+		driver = nil
 	}
 
 	// Send driver response to ride service
-	s.sendDriverResponse(ctx, rideID, driverID, true, "", offer.RideRequest.CorrelationID)
+	s.sendDriverResponse(ctx, offer.RideRequest, driverID, true, "")
+	s.forgetDeclines(rideID)
 
 	// Send ride details back to driver via WebSocket
 	rideDetails := map[string]interface{}{
 		"ride_id":         rideID,
-		"passenger_name":  "Passenger", // Would fetch from ride service
+		"passenger_name":  "Passenger",
 		"pickup_location": offer.RideRequest.PickupLocation,
 	}
+	// Masked contact only: drivers never receive the passenger's email or real phone
+	if contact, err := s.repo.GetPassengerContact(ctx, rideID); err != nil {
+		log.Error("get_passenger_contact_failed", err)
+	} else {
+		rideDetails["passenger_name"] = contact.FirstName
+		rideDetails["passenger_contact"] = contact
+	}
 	if driver != nil {
 		rideDetails["driver_info"] = map[string]interface{}{
 			"email":   driver.Email,
@@ -364,12 +1049,12 @@ func (s *DriverLocationService) HandleDriverRideResponse(ctx context.Context, dr
 	}
 	s.wsMgr.SendRideDetails(driverID, rideDetails)
 
-	// Publish driver status
+	// Publish driver availability; the ride itself stays MATCHED
 	statusUpdate := map[string]interface{}{
 		"driver_id": driverID,
-		"status":    domain.DriverStatusEnRoute,
+		"status":    domain.DriverStatusBusy,
 		"ride_id":   rideID,
-		"timestamp": time.Now().Format(time.RFC3339),
+		"timestamp": apitime.Format(s.clock.Now()),
 	}
 	statusData, _ := json.Marshal(statusUpdate)
 	if err := s.publisher.PublishDriverStatus(ctx, "driver_topic", fmt.Sprintf("driver.status.%s", driverID), statusData); err != nil {
@@ -381,14 +1066,34 @@ func (s *DriverLocationService) HandleDriverRideResponse(ctx context.Context, dr
 	return nil
 }
 
-// sendDriverResponse sends the driver match response back to ride service
-func (s *DriverLocationService) sendDriverResponse(ctx context.Context, rideID, driverID string, accepted bool, reason string, correlationID string) {
+// reofferRide routes a ride to other drivers once an offer for it fell through.
+// If other offers for the ride are still pending, those drivers keep their chance
+// instead, and a ride that was accepted or cancelled meanwhile is left alone.
+func (s *DriverLocationService) reofferRide(req *domain.RideMatchingRequest) {
+	s.offerMu.RLock()
+	needed := s.reofferNeededLocked(req.RideID)
+	s.offerMu.RUnlock()
+	if !needed {
+		return
+	}
+
+	s.log.WithFields(logger.LogFields{"ride_id": req.RideID}).Info("ride_reoffer", "Re-offering ride to other drivers")
+	if err := s.matchRide(context.Background(), req); err != nil {
+		s.log.Error("ride_reoffer_failed", err)
+	}
+}
+
+// sendDriverResponse sends the driver match response for req back to ride service.
+// It names the passenger, whom the ride service notifies of the match.
+func (s *DriverLocationService) sendDriverResponse(ctx context.Context, req *domain.RideMatchingRequest, driverID string, accepted bool, reason string) {
+	rideID := req.RideID
 	response := map[string]interface{}{
 		"ride_id":        rideID,
 		"driver_id":      driverID,
+		"passenger_id":   req.PassengerID,
 		"accepted":       accepted,
-		"correlation_id": correlationID,
-		"timestamp":      time.Now().Format(time.RFC3339),
+		"correlation_id": req.CorrelationID,
+		"timestamp":      apitime.Format(s.clock.Now()),
 	}
 
 	if !accepted {
@@ -421,28 +1126,139 @@ func (s *DriverLocationService) sendDriverResponse(ctx context.Context, rideID,
 	}
 }
 
-// StartRide handles driver starting the ride
+// DriverEnRoute records that a matched driver has set off for the pickup. The
+// ride moves to EN_ROUTE so the passenger sees the driver on the way; repeating
+// the call while already EN_ROUTE is a no-op.
+func (s *DriverLocationService) DriverEnRoute(ctx context.Context, driverID string, rideID string) error {
+	log := s.log.WithFields(logger.LogFields{"driver_id": driverID, "ride_id": rideID})
+
+	lock := s.driverLock(driverID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	ride, err := s.repo.GetRideAssignment(ctx, rideID)
+	if err != nil {
+		log.Error("get_ride_failed", err)
+		return fmt.Errorf("failed to get ride: %w", err)
+	}
+	bound, err := s.boundToRide(ctx, driverID, ride)
+	if err != nil {
+		log.Error("get_driver_failed", err)
+		return fmt.Errorf("failed to get driver: %w", err)
+	}
+	if !bound {
+		return domain.ErrRideNotAssigned
+	}
+	switch ride.Status {
+	// REQUESTED: the ride service hasn't recorded the accept yet
+	case "MATCHED", "REQUESTED":
+	case "EN_ROUTE":
+		return nil
+	default:
+		return domain.ErrRideNotMatched
+	}
+
+	if err := s.repo.UpdateDriverStatus(ctx, driverID, domain.DriverStatusEnRoute); err != nil {
+		log.Error("update_status_failed", err)
+		return fmt.Errorf("failed to update status: %w", err)
+	}
+
+	// The ride service moves the ride to EN_ROUTE and notifies the passenger on this status
+	statusUpdate := map[string]interface{}{
+		"driver_id":    driverID,
+		"ride_id":      rideID,
+		"passenger_id": ride.PassengerID,
+		"status":       domain.DriverStatusEnRoute,
+		"old_status":   ride.Status,
+		"new_status":   domain.DriverStatusEnRoute,
+		"timestamp":    apitime.Format(s.clock.Now()),
+	}
+	if location, err := s.repo.GetCurrentLocation(ctx, driverID); err == nil && location != nil {
+		statusUpdate["latitude"] = location.Latitude
+		statusUpdate["longitude"] = location.Longitude
+	}
+	statusData, _ := json.Marshal(statusUpdate)
+	if err := s.publisher.PublishDriverStatus(ctx, "driver_topic", fmt.Sprintf("driver.status.%s", driverID), statusData); err != nil {
+		log.Error("publish_driver_status_failed", err)
+		return fmt.Errorf("failed to publish status: %w", err)
+	}
+
+	log.Info("driver_en_route", "Driver is on the way to the pickup")
+	return nil
+}
+
+// boundToRide reports whether the ride is the driver's. The ride service records
+// the driver on the ride only after consuming the accept, so until then the
+// driver's own binding (current_ride_id, set when the accept went through)
+// is taken as proof of assignment.
+func (s *DriverLocationService) boundToRide(ctx context.Context, driverID string, ride *domain.RideAssignment) (bool, error) {
+	if ride == nil {
+		return false, nil
+	}
+	if ride.DriverID != "" {
+		return ride.DriverID == driverID, nil
+	}
+	driver, err := s.repo.GetDriver(ctx, driverID)
+	if err != nil {
+		return false, err
+	}
+	return driver.CurrentRideID == ride.RideID, nil
+}
+
+// StartRide records that the driver picked up the passenger. Only the ride's
+// driver can start it, and only once they are on the way to or at the pickup;
+// the ride service moves it to IN_PROGRESS and tells the passenger. Repeating
+// the call once IN_PROGRESS is a no-op.
 func (s *DriverLocationService) StartRide(ctx context.Context, driverID string, rideID string) error {
 	log := s.log.WithFields(logger.LogFields{"driver_id": driverID, "ride_id": rideID})
 	log.Info("ride_starting", "Driver starting ride")
 
+	lock := s.driverLock(driverID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	ride, err := s.repo.GetRideAssignment(ctx, rideID)
+	if err != nil {
+		log.Error("get_ride_failed", err)
+		return fmt.Errorf("failed to get ride: %w", err)
+	}
+	bound, err := s.boundToRide(ctx, driverID, ride)
+	if err != nil {
+		log.Error("get_driver_failed", err)
+		return fmt.Errorf("failed to get driver: %w", err)
+	}
+	if !bound {
+		return domain.ErrRideNotAssigned
+	}
+	switch ride.Status {
+	case "EN_ROUTE", "ARRIVED":
+	case "IN_PROGRESS":
+		return nil
+	default:
+		return domain.ErrRideNotStartable
+	}
+
 	// Update driver status to BUSY (in progress)
-	err := s.repo.UpdateDriverStatus(ctx, driverID, domain.DriverStatusBusy)
+	err = s.repo.UpdateDriverStatus(ctx, driverID, domain.DriverStatusBusy)
 	if err != nil {
 		log.Error("update_status_failed", err)
 		return fmt.Errorf("failed to update status: %w", err)
 	}
 
-	// Publish status update
+	// The ride service moves the ride to IN_PROGRESS and notifies the passenger on this status
 	statusUpdate := map[string]interface{}{
-		"driver_id": driverID,
-		"ride_id":   rideID,
-		"status":    "IN_PROGRESS",
-		"timestamp": time.Now().Format(time.RFC3339),
+		"driver_id":    driverID,
+		"ride_id":      rideID,
+		"passenger_id": ride.PassengerID,
+		"status":       "IN_PROGRESS",
+		"old_status":   ride.Status,
+		"new_status":   "IN_PROGRESS",
+		"timestamp":    apitime.Format(s.clock.Now()),
 	}
 	statusData, _ := json.Marshal(statusUpdate)
 	if err := s.publisher.PublishDriverStatus(ctx, "driver_topic", fmt.Sprintf("driver.status.%s", driverID), statusData); err != nil {
 		log.Error("publish_driver_status_failed", err)
+		return fmt.Errorf("failed to publish status: %w", err)
 	}
 
 	log.Info("ride_started", "Ride started successfully")
@@ -450,22 +1266,24 @@ func (s *DriverLocationService) StartRide(ctx context.Context, driverID string,
 }
 
 // CompleteRide handles driver completing the ride
-func (s *DriverLocationService) CompleteRide(ctx context.Context, driverID string, rideID string, actualDistanceKM float64, actualDurationMin int) (float64, error) {
+func (s *DriverLocationService) CompleteRide(ctx context.Context, driverID string, rideID string, actualDistanceKM float64, actualDurationMin int) (*domain.RideEarnings, error) {
 	log := s.log.WithFields(logger.LogFields{"driver_id": driverID, "ride_id": rideID})
 	log.Info("ride_completing", "Driver completing ride")
 
-	// Calculate earnings (80% of fare)
-	// In real implementation, would fetch actual fare from ride service
-	// earnings := 1216.0 // Placeholder
-	earnings, err := s.repo.GetEstimatedFare(ctx, rideID)
+	// Price the ride the same way the offer preview was, adjusted for the
+	// distance actually driven
+	basis, err := s.repo.GetRideFareBasis(ctx, rideID)
 	if err != nil {
 		log.Error("get_fare_failed", err)
-		return 0, fmt.Errorf("failed to get fare: %w", err)
+		return nil, fmt.Errorf("failed to get fare: %w", err)
+	}
+	if basis == nil {
+		basis = &domain.RideFareBasis{}
 	}
-	earnings = earnings * 0.8
+	earnings := s.calculateRideEarnings(basis, actualDistanceKM)
 
 	// Update session stats
-	err = s.repo.UpdateDriverSessionStats(ctx, driverID, 1, earnings)
+	err = s.repo.UpdateDriverSessionStats(ctx, driverID, 1, earnings.Earnings)
 	if err != nil {
 		log.Error("update_stats_failed", err)
 	}
@@ -474,25 +1292,109 @@ func (s *DriverLocationService) CompleteRide(ctx context.Context, driverID strin
 	err = s.repo.ClearDriverCurrentRide(ctx, driverID)
 	if err != nil {
 		log.Error("clear_ride_failed", err)
-		return 0, fmt.Errorf("failed to clear ride: %w", err)
+		return nil, fmt.Errorf("failed to clear ride: %w", err)
 	}
 
 	// Publish status update
 	statusUpdate := map[string]interface{}{
-		"driver_id": driverID,
-		"ride_id":   rideID,
-		"status":    "COMPLETED",
-		"timestamp": time.Now().Format(time.RFC3339),
+		"driver_id":  driverID,
+		"ride_id":    rideID,
+		"status":     "COMPLETED",
+		"final_fare": earnings.FinalFare,
+		"timestamp":  apitime.Format(s.clock.Now()),
 	}
 	statusData, _ := json.Marshal(statusUpdate)
 	if err := s.publisher.PublishDriverStatus(ctx, "driver_topic", fmt.Sprintf("driver.status.%s", driverID), statusData); err != nil {
 		log.Error("publish_driver_status_failed", err)
 	}
 
-	log.Info("ride_completed", fmt.Sprintf("Ride completed, driver earned %.2f", earnings))
+	log.Info("ride_completed", fmt.Sprintf("Ride completed, driver earned %.2f (%+.2f for distance)", earnings.Earnings, earnings.DistanceAdjustment))
 	return earnings, nil
 }
 
+// CancelRide handles a driver abandoning a ride they accepted (e.g. passenger no-show)
+func (s *DriverLocationService) CancelRide(ctx context.Context, driverID string, rideID string, reason string) error {
+	log := s.log.WithFields(logger.LogFields{"driver_id": driverID, "ride_id": rideID})
+	log.Info("ride_cancelling", "Driver cancelling ride")
+
+	lock := s.driverLock(driverID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	ride, err := s.repo.GetRideAssignment(ctx, rideID)
+	if err != nil {
+		log.Error("get_ride_failed", err)
+		return fmt.Errorf("failed to get ride: %w", err)
+	}
+	if ride == nil || ride.DriverID != driverID {
+		return domain.ErrRideNotAssigned
+	}
+	if ride.Status == "COMPLETED" || ride.Status == "CANCELLED" {
+		return domain.ErrRideNotCancellable
+	}
+
+	// Free the driver for new rides
+	if err := s.repo.ClearDriverCurrentRide(ctx, driverID); err != nil {
+		log.Error("clear_ride_failed", err)
+		return fmt.Errorf("failed to clear ride: %w", err)
+	}
+
+	// The ride service cancels the ride and notifies the passenger on this status
+	statusUpdate := map[string]interface{}{
+		"driver_id":    driverID,
+		"ride_id":      rideID,
+		"passenger_id": ride.PassengerID,
+		"status":       "CANCELLED",
+		"old_status":   ride.Status,
+		"new_status":   "CANCELLED",
+		"reason":       reason,
+		"cancelled_by": domain.CancelledByDriver,
+		"timestamp":    apitime.Format(s.clock.Now()),
+	}
+	statusData, _ := json.Marshal(statusUpdate)
+	if err := s.publisher.PublishDriverStatus(ctx, "driver_topic", fmt.Sprintf("driver.status.%s", driverID), statusData); err != nil {
+		log.Error("publish_driver_status_failed", err)
+		return fmt.Errorf("failed to publish cancellation: %w", err)
+	}
+
+	log.Info("ride_cancelled_by_driver", "Ride cancelled by driver")
+	return nil
+}
+
+// GetDriver returns the driver with their active session and recent offer/ride stats
+func (s *DriverLocationService) GetDriver(ctx context.Context, driverID string) (*domain.Driver, error) {
+	log := s.log.WithFields(logger.LogFields{"driver_id": driverID})
+
+	driver, err := s.repo.GetDriver(ctx, driverID)
+	if err != nil {
+		log.Error("get_driver_failed", err)
+		return nil, err
+	}
+
+	driver.CurrentSession, err = s.repo.GetActiveSession(ctx, driverID)
+	if err != nil {
+		log.Error("get_session_failed", err)
+		return nil, err
+	}
+
+	driver.Stats, err = s.repo.GetDriverStats(ctx, driverID, s.clock.Now().Add(-driverStatsWindow))
+	if err != nil {
+		// Stats are informational; still return the profile
+		log.Error("get_driver_stats_failed", err)
+	}
+	return driver, nil
+}
+
+// GetCurrentRide returns the driver's active ride, or nil when they are idle
+func (s *DriverLocationService) GetCurrentRide(ctx context.Context, driverID string) (*domain.CurrentRide, error) {
+	ride, err := s.repo.GetDriverCurrentRide(ctx, driverID)
+	if err != nil {
+		s.log.WithFields(logger.LogFields{"driver_id": driverID}).Error("get_current_ride_failed", err)
+		return nil, err
+	}
+	return ride, nil
+}
+
 // HandleRideStatusUpdate processes ride status updates from ride service
 func (s *DriverLocationService) HandleRideStatusUpdate(ctx context.Context, rideID string, driverID string, status string, finalFare float64) error {
 	log := s.log.WithFields(logger.LogFields{"ride_id": rideID, "driver_id": driverID})
@@ -500,8 +1402,24 @@ func (s *DriverLocationService) HandleRideStatusUpdate(ctx context.Context, ride
 
 	// Handle different statuses
 	switch status {
-	case "CANCELLED":
-		log.Info("ride_cancelled", "Ride was cancelled")
+	case "CANCELLED", "REQUESTED":
+		// REQUESTED with a driver means the ride service took the ride back from
+		// the driver (e.g. assignment expired); free them the same way as a cancel
+		if status == "CANCELLED" {
+			log.Info("ride_cancelled", "Ride was cancelled")
+			// Offers still out for the ride (passenger cancelled mid-match, or it hit the
+			// matching deadline) can no longer be accepted; tell those drivers
+			if dropped := s.cancelOffersForRide(rideID); len(dropped) > 0 {
+				log.Info("ride_offers_cancelled", fmt.Sprintf("Withdrew %d pending offers", len(dropped)))
+				for _, offer := range dropped {
+					if err := s.wsMgr.SendOfferCancelled(offer.DriverID, offer.OfferID, rideID, offerCancelledReason); err != nil {
+						log.Error("send_offer_cancelled_failed", err)
+					}
+				}
+			}
+		} else {
+			log.Info("ride_unassigned", "Ride was taken back from the driver for re-matching")
+		}
 
 		// Only act if we have a valid driver ID
 		if driverID != "" {
@@ -526,7 +1444,7 @@ func (s *DriverLocationService) HandleRideStatusUpdate(ctx context.Context, ride
 			statusUpdate := map[string]interface{}{
 				"driver_id": driverID,
 				"status":    domain.DriverStatusAvailable,
-				"timestamp": time.Now().Format(time.RFC3339),
+				"timestamp": apitime.Format(s.clock.Now()),
 			}
 			statusData, _ := json.Marshal(statusUpdate)
 			if err := s.publisher.PublishDriverStatus(ctx, "driver_topic", fmt.Sprintf("driver.status.%s", driverID), statusData); err != nil {
@@ -535,9 +1453,114 @@ func (s *DriverLocationService) HandleRideStatusUpdate(ctx context.Context, ride
 		}
 
 	case "COMPLETED":
-		// Update earnings if needed
+		// Drivers complete rides through CompleteRide, which books their earnings
+		// itself; this is a ride completed for them, e.g. by support
 		log.Info("ride_completed_confirmed", fmt.Sprintf("Ride completed with fare %.2f", finalFare))
+		if driverID == "" {
+			return nil
+		}
+		earnings := s.CalculateDriverEarnings(finalFare)
+		if err := s.repo.UpdateDriverSessionStats(ctx, driverID, 1, earnings); err != nil {
+			log.Error("update_stats_failed", err)
+			return err
+		}
+		if s.wsMgr.IsDriverConnected(driverID) {
+			err := s.wsMgr.SendRideStatus(driverID, map[string]interface{}{
+				"ride_id":         rideID,
+				"status":          "COMPLETED",
+				"final_fare":      finalFare,
+				"driver_earnings": earnings,
+				"message":         "Ride completed by support",
+			})
+			if err != nil {
+				log.Error("send_ride_completed_failed", err)
+			}
+		}
 	}
 
 	return nil
 }
+
+// HandleRideMessage pushes a passenger's chat message to the assigned driver.
+// The ride service has already checked the parties and stored the thread, so a
+// driver who is offline reads it later and the message is not requeued.
+func (s *DriverLocationService) HandleRideMessage(ctx context.Context, msg *domain.RideMessage) error {
+	log := s.log.WithFields(logger.LogFields{
+		"ride_id":    msg.RideID,
+		"driver_id":  msg.RecipientID,
+		"message_id": msg.MessageID,
+	})
+	if msg.RecipientID == "" {
+		return fmt.Errorf("ride message %s has no recipient", msg.MessageID)
+	}
+	// Every instance gets the message; the one holding the driver's socket sends it
+	if !s.wsMgr.IsDriverConnected(msg.RecipientID) {
+		log.Debug("ride_message_not_local", "Driver is not connected to this instance")
+		return nil
+	}
+	if err := s.wsMgr.SendRideMessage(msg.RecipientID, msg); err != nil {
+		log.Error("send_ride_message_failed", err)
+		return nil
+	}
+	log.Info("ride_message_delivered", "Ride message sent to driver")
+	return nil
+}
+
+// HandleRideDestinationChange pushes a passenger's new drop-off to the driver.
+// The ride service has already stored it, so a driver who is offline sees it in
+// their current ride and the message is not requeued.
+func (s *DriverLocationService) HandleRideDestinationChange(ctx context.Context, change *domain.RideDestinationChange) error {
+	log := s.log.WithFields(logger.LogFields{
+		"ride_id":   change.RideID,
+		"driver_id": change.DriverID,
+	})
+	if change.DriverID == "" {
+		return fmt.Errorf("destination change for ride %s has no driver", change.RideID)
+	}
+	if !s.wsMgr.IsDriverConnected(change.DriverID) {
+		log.Debug("ride_destination_not_local", "Driver is not connected to this instance")
+		return nil
+	}
+	if err := s.wsMgr.SendDestinationChanged(change.DriverID, change); err != nil {
+		log.Error("send_ride_destination_failed", err)
+		return nil
+	}
+	log.Info("ride_destination_delivered", "Destination change sent to driver")
+	return nil
+}
+
+// HandleDriverControl applies an admin action to a driver's live connection.
+// The admin service has already updated the database, so only in-memory state
+// and the socket are touched here.
+func (s *DriverLocationService) HandleDriverControl(ctx context.Context, msg *domain.DriverControl) error {
+	log := s.log.WithFields(logger.LogFields{
+		"driver_id": msg.DriverID,
+		"action":    msg.Action,
+		"admin_id":  msg.AdminID,
+	})
+	switch msg.Action {
+	case domain.DriverControlForceOffline:
+		// Every instance gets the message; the one holding the socket closes it
+		s.wsMgr.ForceDisconnect(msg.DriverID)
+
+		// Broadcast the first position after coming back online even if unchanged
+		s.lastPublishedMu.Lock()
+		delete(s.lastPublished, msg.DriverID)
+		s.lastPublishedMu.Unlock()
+
+		// The session ends once, through the same path as going offline, so the
+		// shift summary is written. Instances that lose the race find it ended.
+		if _, err := s.DriverGoOffline(ctx, msg.DriverID); err != nil {
+			if errors.Is(err, domain.ErrNoActiveSession) {
+				log.Debug("driver_session_already_ended", "Driver has no open session")
+				return nil
+			}
+			return err
+		}
+		log.Info("driver_forced_offline", "Driver disconnected and session ended by admin")
+		return nil
+	default:
+		log.Warn("unknown_driver_control", "Unknown driver control action, ignoring")
+		return nil
+	}
+}
@@ -0,0 +1,1220 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ride-hail/internal/driver_location_service/domain"
+	"ride-hail/pkg/clock"
+	"ride-hail/pkg/geocode"
+	"ride-hail/pkg/ratelimit"
+)
+
+func locationBatch(driverID string, n int) []domain.LocationUpdate {
+	points := make([]domain.LocationUpdate, n)
+	for i := range points {
+		points[i] = domain.LocationUpdate{
+			DriverID:  driverID,
+			Latitude:  43.2 + float64(i)*0.001,
+			Longitude: 76.8 + float64(i)*0.001,
+			SpeedKmh:  float64(20 + i),
+			Timestamp: testNow.Add(time.Duration(i) * 10 * time.Second),
+		}
+	}
+	return points
+}
+
+func TestUpdateDriverLocationBatchArchivesAllAndPublishesLatest(t *testing.T) {
+	s := newTestService(t)
+	points := locationBatch("d1", 3)
+
+	coordID, err := s.UpdateDriverLocationBatch(context.Background(), "d1", points, "Abay Ave 10")
+	if err != nil {
+		t.Fatalf("UpdateDriverLocationBatch: %v", err)
+	}
+	if coordID != "coord-d1" {
+		t.Errorf("coordinate id = %q, want the repository's", coordID)
+	}
+
+	if len(s.repo.batches) != 1 || len(s.repo.batches[0]) != 3 {
+		t.Fatalf("archived batches = %v, want one batch of 3 points", s.repo.batches)
+	}
+	if got := s.repo.currentLocation["d1"]; got != points[2] {
+		t.Errorf("current location = %+v, want the newest point %+v", got, points[2])
+	}
+	if s.repo.batchAddresses[0] != "Abay Ave 10" {
+		t.Errorf("address = %q, want it kept for the newest point", s.repo.batchAddresses[0])
+	}
+
+	updates := s.pub.to("location_fanout")
+	if len(updates) != 1 {
+		t.Fatalf("published %d location updates, want only the newest point", len(updates))
+	}
+	loc := updates[0].body["location"].(map[string]interface{})
+	if loc["latitude"] != points[2].Latitude || loc["longitude"] != points[2].Longitude {
+		t.Errorf("published location = %v, want the newest point", loc)
+	}
+}
+
+func TestUpdateDriverLocationBatchAddsActiveRide(t *testing.T) {
+	s := newTestService(t)
+	s.repo.currentRides["d1"] = &domain.CurrentRide{RideID: "r1", PassengerID: "p1", Status: "EN_ROUTE"}
+
+	if _, err := s.UpdateDriverLocationBatch(context.Background(), "d1", locationBatch("d1", 2), ""); err != nil {
+		t.Fatalf("UpdateDriverLocationBatch: %v", err)
+	}
+
+	body := s.pub.to("location_fanout")[0].body
+	if body["ride_id"] != "r1" || body["passenger_id"] != "p1" || body["ride_status"] != "EN_ROUTE" {
+		t.Errorf("published update = %v, want the driver's ride and passenger", body)
+	}
+}
+
+func TestUpdateDriverLocationBatchRejectsBadBatches(t *testing.T) {
+	unordered := locationBatch("d1", 2)
+	unordered[0], unordered[1] = unordered[1], unordered[0]
+
+	tests := []struct {
+		name   string
+		points []domain.LocationUpdate
+	}{
+		{"empty", nil},
+		{"too large", locationBatch("d1", domain.MaxLocationBatchSize+1)},
+		{"out of order", unordered},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t)
+			if _, err := s.UpdateDriverLocationBatch(context.Background(), "d1", tt.points, ""); err == nil {
+				t.Fatal("expected an error")
+			}
+			if len(s.repo.batches) != 0 || len(s.pub.messages) != 0 {
+				t.Error("a rejected batch must not be saved or published")
+			}
+		})
+	}
+}
+
+func TestDriverGoOnlineTwiceReusesSession(t *testing.T) {
+	s := newTestService(t)
+	s.repo.addDriver("d1")
+	ctx := context.Background()
+
+	first, err := s.DriverGoOnline(ctx, "d1", 43.2, 76.8, "Abay Ave 10")
+	if err != nil {
+		t.Fatalf("first DriverGoOnline: %v", err)
+	}
+	second, err := s.DriverGoOnline(ctx, "d1", 43.21, 76.81, "Abay Ave 12")
+	if err != nil {
+		t.Fatalf("second DriverGoOnline: %v", err)
+	}
+
+	if first != second {
+		t.Errorf("second go-online got session %s, want the open session %s", second, first)
+	}
+	if s.repo.sessionsCreated != 1 || len(s.repo.openSessions("d1")) != 1 {
+		t.Errorf("created %d sessions, %d open; want one", s.repo.sessionsCreated, len(s.repo.openSessions("d1")))
+	}
+	if got := s.repo.status("d1"); got != domain.DriverStatusAvailable {
+		t.Errorf("status = %s, want AVAILABLE", got)
+	}
+	if got := s.repo.currentLocation["d1"]; got.Latitude != 43.21 {
+		t.Errorf("location = %+v, want the second go-online's", got)
+	}
+}
+
+func TestDriverGoOnlineConcurrentlyOpensOneSession(t *testing.T) {
+	s := newTestService(t)
+	s.repo.addDriver("d1")
+
+	const devices = 5
+	ids := make(chan string, devices)
+	var wg sync.WaitGroup
+	for i := 0; i < devices; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id, err := s.DriverGoOnline(context.Background(), "d1", 43.2, 76.8, "")
+			if err != nil {
+				t.Errorf("DriverGoOnline: %v", err)
+			}
+			ids <- id
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	want := <-ids
+	for id := range ids {
+		if id != want {
+			t.Errorf("devices got sessions %s and %s, want one shared session", want, id)
+		}
+	}
+	if n := len(s.repo.openSessions("d1")); n != 1 {
+		t.Errorf("%d open sessions, want 1", n)
+	}
+}
+
+func TestDriverGoOnlineAgainDuringRideKeepsStatus(t *testing.T) {
+	for _, status := range []string{domain.DriverStatusBusy, domain.DriverStatusEnRoute} {
+		t.Run(status, func(t *testing.T) {
+			s := newTestService(t)
+			s.repo.addDriver("d1")
+			ctx := context.Background()
+
+			session, err := s.DriverGoOnline(ctx, "d1", 43.2, 76.8, "")
+			if err != nil {
+				t.Fatalf("DriverGoOnline: %v", err)
+			}
+			s.repo.UpdateDriverStatus(ctx, "d1", status)
+			published := len(s.pub.to("driver_topic"))
+
+			again, err := s.DriverGoOnline(ctx, "d1", 43.2, 76.8, "")
+			if err != nil {
+				t.Fatalf("repeated DriverGoOnline: %v", err)
+			}
+			if again != session {
+				t.Errorf("session = %s, want %s", again, session)
+			}
+			if got := s.repo.status("d1"); got != status {
+				t.Errorf("status = %s, want %s kept", got, status)
+			}
+			if n := len(s.pub.to("driver_topic")); n != published {
+				t.Errorf("published %d more status updates, want none", n-published)
+			}
+		})
+	}
+}
+
+func TestDriverGoOnlineWithoutSessionResetsStaleRideStatus(t *testing.T) {
+	s := newTestService(t)
+	s.repo.addDriver("d1")
+	ctx := context.Background()
+	// Left BUSY by a ride that ended while the driver was offline
+	s.repo.UpdateDriverStatus(ctx, "d1", domain.DriverStatusBusy)
+
+	if _, err := s.DriverGoOnline(ctx, "d1", 43.2, 76.8, ""); err != nil {
+		t.Fatalf("DriverGoOnline: %v", err)
+	}
+	if got := s.repo.status("d1"); got != domain.DriverStatusAvailable {
+		t.Errorf("status = %s, want AVAILABLE for a fresh session", got)
+	}
+	if n := len(s.repo.openSessions("d1")); n != 1 {
+		t.Errorf("%d open sessions, want 1", n)
+	}
+}
+
+func TestDriverGoOnlineAfterOfflineStartsNewSession(t *testing.T) {
+	s := newTestService(t)
+	s.repo.addDriver("d1")
+	ctx := context.Background()
+
+	first, err := s.DriverGoOnline(ctx, "d1", 43.2, 76.8, "")
+	if err != nil {
+		t.Fatalf("DriverGoOnline: %v", err)
+	}
+	if _, err := s.DriverGoOffline(ctx, "d1"); err != nil {
+		t.Fatalf("DriverGoOffline: %v", err)
+	}
+	second, err := s.DriverGoOnline(ctx, "d1", 43.2, 76.8, "")
+	if err != nil {
+		t.Fatalf("DriverGoOnline: %v", err)
+	}
+	if first == second {
+		t.Error("going online after going offline reused the ended session")
+	}
+}
+
+func TestAcceptingSecondOfferIsRejectedAndReoffered(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	s.onlineDriver("d1", 43.2389, 76.8897)
+
+	// Both rides match in the same window and reach the only nearby driver
+	for _, rideID := range []string{"A", "B"} {
+		if err := s.HandleRideMatchingRequest(ctx, rideRequest(rideID, 43.2390, 76.8900)); err != nil {
+			t.Fatalf("match %s: %v", rideID, err)
+		}
+	}
+	if got := s.ws.sentTo("d1"); len(got) != 2 || got[0] != "ride_offer" || got[1] != "ride_offer" {
+		t.Fatalf("d1 was sent %v, want two offers", got)
+	}
+	s.onlineDriver("d2", 43.2450, 76.9000)
+
+	if err := s.HandleDriverRideResponse(ctx, "d1", offerID("A", "d1"), "A", true); err != nil {
+		t.Fatalf("accept A: %v", err)
+	}
+	err := s.HandleDriverRideResponse(ctx, "d1", offerID("B", "d1"), "B", true)
+	if !errors.Is(err, domain.ErrDriverAssigned) {
+		t.Fatalf("accept B: err = %v, want ErrDriverAssigned", err)
+	}
+
+	if got := s.repo.currentRideID("d1"); got != "A" {
+		t.Errorf("d1 bound to ride %q, want A", got)
+	}
+	waitFor(t, "ride B to be offered to d2", func() bool {
+		offered := s.repo.offeredTo("B")
+		return len(offered) == 2 && offered[1] == "d2"
+	})
+	waitFor(t, "the failed acceptance to be recorded", func() bool {
+		got := s.repo.responsesFor(offerID("B", "d1"))
+		return len(got) == 1 && got[0] == domain.OfferResponseAcceptFailed
+	})
+
+	for _, m := range s.pub.to("driver_topic") {
+		if m.routingKey == "driver.response.B" && m.body["accepted"] == true {
+			t.Errorf("ride B reported as accepted: %v", m.body)
+		}
+	}
+	if s.rideTaken("B") {
+		t.Error("ride B still marked taken, so matching would never offer it again")
+	}
+}
+
+func TestAcceptingOffersConcurrentlyBindsOneRide(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	s.onlineDriver("d1", 43.2389, 76.8897)
+
+	rides := []string{"A", "B", "C", "D"}
+	for _, rideID := range rides {
+		if err := s.HandleRideMatchingRequest(ctx, rideRequest(rideID, 43.2390, 76.8900)); err != nil {
+			t.Fatalf("match %s: %v", rideID, err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(rides))
+	for _, rideID := range rides {
+		wg.Add(1)
+		go func(rideID string) {
+			defer wg.Done()
+			errs <- s.HandleDriverRideResponse(ctx, "d1", offerID(rideID, "d1"), rideID, true)
+		}(rideID)
+	}
+	wg.Wait()
+	close(errs)
+
+	accepted := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			accepted++
+		case !errors.Is(err, domain.ErrDriverAssigned):
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if accepted != 1 {
+		t.Errorf("%d acceptances went through, want exactly 1", accepted)
+	}
+	if s.repo.currentRideID("d1") == "" {
+		t.Error("driver not bound to any ride")
+	}
+}
+
+func TestDriverCancelFreesDriverAndReportsCancellation(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	s.onlineDriver("d1", 43.2389, 76.8897)
+	if err := s.repo.SetDriverCurrentRide(ctx, "d1", "r1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.CancelRide(ctx, "d1", "r1", "Passenger unreachable"); err != nil {
+		t.Fatalf("CancelRide: %v", err)
+	}
+
+	if got := s.repo.status("d1"); got != domain.DriverStatusAvailable {
+		t.Errorf("status = %s, want AVAILABLE", got)
+	}
+	if got := s.repo.currentRideID("d1"); got != "" {
+		t.Errorf("driver still bound to ride %q", got)
+	}
+
+	statuses := s.pub.to("driver_topic")
+	if len(statuses) != 1 {
+		t.Fatalf("published %d status updates, want 1", len(statuses))
+	}
+	body := statuses[0].body
+	want := map[string]interface{}{
+		"ride_id":      "r1",
+		"passenger_id": "passenger-r1",
+		"new_status":   "CANCELLED",
+		"cancelled_by": domain.CancelledByDriver,
+		"reason":       "Passenger unreachable",
+	}
+	for k, v := range want {
+		if body[k] != v {
+			t.Errorf("%s = %v, want %v", k, body[k], v)
+		}
+	}
+}
+
+func TestDriverCancelRejectsRidesNotTheirs(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	s.onlineDriver("d1", 43.2389, 76.8897)
+	s.onlineDriver("d2", 43.2389, 76.8897)
+	if err := s.repo.SetDriverCurrentRide(ctx, "d1", "r1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.CancelRide(ctx, "d2", "r1", ""); !errors.Is(err, domain.ErrRideNotAssigned) {
+		t.Errorf("another driver cancelling: err = %v, want ErrRideNotAssigned", err)
+	}
+	if err := s.CancelRide(ctx, "d1", "unknown", ""); !errors.Is(err, domain.ErrRideNotAssigned) {
+		t.Errorf("cancelling an unknown ride: err = %v, want ErrRideNotAssigned", err)
+	}
+	if got := s.repo.currentRideID("d1"); got != "r1" {
+		t.Errorf("d1 bound to %q, want r1 kept", got)
+	}
+	if n := len(s.pub.to("driver_topic")); n != 0 {
+		t.Errorf("published %d status updates, want none", n)
+	}
+}
+
+func TestDriverCancelRejectsFinishedRide(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	s.onlineDriver("d1", 43.2389, 76.8897)
+	if err := s.repo.SetDriverCurrentRide(ctx, "d1", "r1"); err != nil {
+		t.Fatal(err)
+	}
+	s.repo.currentRides["d1"].Status = "COMPLETED"
+
+	if err := s.CancelRide(ctx, "d1", "r1", ""); !errors.Is(err, domain.ErrRideNotCancellable) {
+		t.Errorf("err = %v, want ErrRideNotCancellable", err)
+	}
+}
+
+// countingGeocoder resolves every point to the same address and counts lookups
+type countingGeocoder struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (g *countingGeocoder) ReverseGeocode(lat, lng float64) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.calls++
+	return "Abay Ave 10", nil
+}
+
+func TestSavedLocationsGetGeocodedAddress(t *testing.T) {
+	s := newTestService(t)
+	g := &countingGeocoder{}
+	s.SetGeocoder(geocode.NewCache(g))
+	s.repo.addDriver("d1")
+	ctx := context.Background()
+
+	if _, err := s.DriverGoOnline(ctx, "d1", 43.2389, 76.8897, ""); err != nil {
+		t.Fatalf("DriverGoOnline: %v", err)
+	}
+	if got := s.repo.addresses[0]; got != "Abay Ave 10" {
+		t.Errorf("go-online address = %q, want the geocoded one", got)
+	}
+
+	// The batch ends where the driver went online, so the cache answers
+	points := []domain.LocationUpdate{{DriverID: "d1", Latitude: 43.2389, Longitude: 76.8897, Timestamp: testNow}}
+	if _, err := s.UpdateDriverLocationBatch(ctx, "d1", points, ""); err != nil {
+		t.Fatalf("UpdateDriverLocationBatch: %v", err)
+	}
+	if got := s.repo.batchAddresses[0]; got != "Abay Ave 10" {
+		t.Errorf("batch address = %q, want the geocoded one", got)
+	}
+	if g.calls != 1 {
+		t.Errorf("geocoder called %d times, want the repeat served from the cache", g.calls)
+	}
+
+	// A client-supplied address is kept as is
+	if _, err := s.UpdateDriverLocationBatch(ctx, "d1", points, "Dostyk Ave 5"); err != nil {
+		t.Fatalf("UpdateDriverLocationBatch: %v", err)
+	}
+	if got := s.repo.batchAddresses[1]; got != "Dostyk Ave 5" {
+		t.Errorf("batch address = %q, want the one given", got)
+	}
+}
+
+// searchRadii returns the radius of every FindNearbyDrivers call, in km
+func (r *fakeRepo) searchRadii() []float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	radii := make([]float64, len(r.searches))
+	for i, s := range r.searches {
+		radii[i] = s.radiusMeters / 1000
+	}
+	return radii
+}
+
+// rejections returns the "no driver" responses published for the ride
+func (p *fakePublisher) rejections(rideID string) int {
+	n := 0
+	for _, m := range p.to("driver_topic") {
+		if m.routingKey == "driver.response."+rideID && m.body["accepted"] == false {
+			n++
+		}
+	}
+	return n
+}
+
+func TestMatchingExpandsRadiusToFindDriver(t *testing.T) {
+	s := newTestService(t)
+	s.SetRadiusExpansion(5, 15, 0)
+	// ~7 km north of the pickup, outside the initial 5 km
+	s.onlineDriver("d1", 43.2390+0.063, 76.8900)
+
+	if err := s.HandleRideMatchingRequest(context.Background(), rideRequest("A", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("match: %v", err)
+	}
+
+	if got := s.repo.searchRadii(); len(got) != 2 || got[0] != 5 || got[1] != 10 {
+		t.Errorf("searched radii %v km, want [5 10]", got)
+	}
+	if got := s.ws.sentTo("d1"); len(got) != 1 || got[0] != "ride_offer" {
+		t.Errorf("d1 was sent %v, want an offer", got)
+	}
+	if n := s.pub.rejections("A"); n != 0 {
+		t.Errorf("%d rejections published, want none", n)
+	}
+}
+
+func TestMatchingRejectsOnlyAfterLastExpansion(t *testing.T) {
+	s := newTestService(t)
+	s.SetRadiusExpansion(5, 12, 0)
+	// ~20 km away, beyond the maximum
+	s.onlineDriver("d1", 43.2390+0.18, 76.8900)
+
+	if err := s.HandleRideMatchingRequest(context.Background(), rideRequest("A", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("match: %v", err)
+	}
+
+	// The last step is clamped to the maximum
+	if got := s.repo.searchRadii(); len(got) != 3 || got[0] != 5 || got[1] != 10 || got[2] != 12 {
+		t.Errorf("searched radii %v km, want [5 10 12]", got)
+	}
+	if n := s.pub.rejections("A"); n != 1 {
+		t.Errorf("%d rejections published, want exactly 1", n)
+	}
+}
+
+func TestMatchingWithoutExpansionSearchesOnce(t *testing.T) {
+	s := newTestService(t)
+	s.onlineDriver("d1", 43.2390+0.063, 76.8900)
+
+	if err := s.HandleRideMatchingRequest(context.Background(), rideRequest("A", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("match: %v", err)
+	}
+
+	if got := s.repo.searchRadii(); len(got) != 1 {
+		t.Errorf("searched radii %v km, want a single search", got)
+	}
+	if n := s.pub.rejections("A"); n != 1 {
+		t.Errorf("%d rejections published, want 1", n)
+	}
+}
+
+func TestMatchingWaitsBetweenExpansions(t *testing.T) {
+	s := newTestService(t)
+	s.SetRadiusExpansion(5, 15, 10*time.Second)
+	ctx := context.Background()
+
+	done := make(chan error, 1)
+	go func() { done <- s.HandleRideMatchingRequest(ctx, rideRequest("A", 43.2390, 76.8900)) }()
+	waitFor(t, "the first search to wait for the expansion delay", func() bool { return s.clock.Waiters() == 1 })
+
+	// A driver comes online within 5 km during the delay
+	s.onlineDriver("d1", 43.2390+0.01, 76.8900)
+	s.clock.Advance(10 * time.Second)
+	if err := <-done; err != nil {
+		t.Fatalf("match: %v", err)
+	}
+
+	if got := s.repo.searchRadii(); len(got) != 2 {
+		t.Errorf("searched radii %v km, want a second search after the delay", got)
+	}
+	if got := s.ws.sentTo("d1"); len(got) != 1 || got[0] != "ride_offer" {
+		t.Errorf("d1 was sent %v, want an offer", got)
+	}
+}
+
+func TestMatchingExpansionStopsWhenCancelled(t *testing.T) {
+	s := newTestService(t)
+	s.SetRadiusExpansion(5, 15, time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error, 1)
+	go func() { done <- s.HandleRideMatchingRequest(ctx, rideRequest("A", 43.2390, 76.8900)) }()
+	waitFor(t, "the expansion delay", func() bool { return s.clock.Waiters() == 1 })
+	cancel()
+
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if got := s.repo.searchRadii(); len(got) != 1 {
+		t.Errorf("searched radii %v km, want no search after cancelling", got)
+	}
+}
+
+func TestDriverGoOfflineReportsUtilization(t *testing.T) {
+	s := newTestService(t)
+	s.repo.addDriver("d1")
+	ctx := context.Background()
+
+	if _, err := s.DriverGoOnline(ctx, "d1", 43.2, 76.8, "Abay Ave 10"); err != nil {
+		t.Fatalf("DriverGoOnline: %v", err)
+	}
+	s.clock.Advance(4 * time.Hour)
+	s.repo.busy = 3 * time.Hour
+
+	session, err := s.DriverGoOffline(ctx, "d1")
+	if err != nil {
+		t.Fatalf("DriverGoOffline: %v", err)
+	}
+
+	if want := [2]time.Time{testNow, testNow.Add(4 * time.Hour)}; s.repo.busyWindow != want {
+		t.Errorf("busy time asked for %v, want the session %v", s.repo.busyWindow, want)
+	}
+	if session.BusyDuration != 3*time.Hour {
+		t.Errorf("busy = %v, want 3h", session.BusyDuration)
+	}
+	if percent, idle := session.Utilization(); percent != 75 || idle != 1 {
+		t.Errorf("utilization = %v%%, %v idle hours; want 75%%, 1", percent, idle)
+	}
+}
+
+func TestMatchingAppliesRatingFloorPerVehicleType(t *testing.T) {
+	s := newTestService(t)
+	s.SetMinRating(domain.VehicleTypeLuxury, 4.7)
+	ctx := context.Background()
+
+	for _, d := range []struct {
+		id, vehicleType string
+		rating          float64
+	}{
+		{"lux-low", domain.VehicleTypeLuxury, 4.2},
+		{"lux-high", domain.VehicleTypeLuxury, 4.9},
+		{"eco-low", "ECONOMY", 4.2},
+	} {
+		driver := s.onlineDriver(d.id, 43.2389, 76.8897)
+		driver.VehicleType = d.vehicleType
+		driver.Rating = d.rating
+	}
+
+	luxury := rideRequest("L", 43.2390, 76.8900)
+	luxury.RideType = domain.VehicleTypeLuxury
+	if err := s.HandleRideMatchingRequest(ctx, luxury); err != nil {
+		t.Fatalf("match luxury: %v", err)
+	}
+	if got := s.repo.offeredTo("L"); len(got) != 1 || got[0] != "lux-high" {
+		t.Errorf("luxury ride offered to %v, want only lux-high", got)
+	}
+	if got := s.repo.lastSearch().minRating; got != 4.7 {
+		t.Errorf("luxury search floor = %v, want 4.7", got)
+	}
+
+	if err := s.HandleRideMatchingRequest(ctx, rideRequest("E", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("match economy: %v", err)
+	}
+	if got := s.repo.offeredTo("E"); len(got) != 1 || got[0] != "eco-low" {
+		t.Errorf("economy ride offered to %v, want eco-low", got)
+	}
+	if got := s.repo.lastSearch().minRating; got != 0 {
+		t.Errorf("economy search floor = %v, want none", got)
+	}
+
+	// Removing the floor lets the low-rated luxury driver match again
+	s.SetMinRating(domain.VehicleTypeLuxury, 0)
+	nearby, err := s.FindNearbyDrivers(ctx, 43.2390, 76.8900, domain.VehicleTypeLuxury, 5, 0, 10)
+	if err != nil {
+		t.Fatalf("FindNearbyDrivers: %v", err)
+	}
+	if len(nearby) != 2 {
+		t.Errorf("found %d luxury drivers without a floor, want 2", len(nearby))
+	}
+}
+
+// metersNorth is about how many degrees of latitude make up m meters
+func metersNorth(m float64) float64 {
+	return m / 111_320
+}
+
+// newLocationTestService returns a service that broadcasts moves of epsilon
+// meters or more, rate limited on the fake clock
+func newLocationTestService(t *testing.T, epsilon float64) *testService {
+	t.Helper()
+	s := newTestService(t)
+	limiter := ratelimit.NewMemoryRateLimiter()
+	limiter.SetClock(s.clock)
+	s.SetLocationRateLimiter(limiter)
+	s.SetPublishEpsilon(epsilon)
+	return s
+}
+
+// update reports d1 at lat, 76.8 one rate-limit interval after the last report
+func (ts *testService) update(t *testing.T, lat float64) {
+	t.Helper()
+	ts.clock.Advance(locationUpdateInterval)
+	if _, err := ts.UpdateDriverLocation(context.Background(), "d1", lat, 76.8, 5, 0, 0, "Abay Ave 10"); err != nil {
+		t.Fatalf("UpdateDriverLocation: %v", err)
+	}
+}
+
+func TestLocationRateLimitIsPerDriver(t *testing.T) {
+	s := newLocationTestService(t, 5)
+	ctx := context.Background()
+	report := func(driverID string) error {
+		_, err := s.UpdateDriverLocation(ctx, driverID, 43.2, 76.8, 5, 0, 0, "Abay Ave 10")
+		return err
+	}
+
+	// REST and socket updates both come through here, so neither can skip the limit
+	if err := report("d1"); err != nil {
+		t.Fatalf("first update: %v", err)
+	}
+	if err := report("d1"); !errors.Is(err, domain.ErrLocationRateLimited) {
+		t.Errorf("second update: err = %v, want ErrLocationRateLimited", err)
+	}
+	if err := report("d2"); err != nil {
+		t.Errorf("another driver: %v", err)
+	}
+
+	s.clock.Advance(locationUpdateInterval - time.Millisecond)
+	if err := report("d1"); !errors.Is(err, domain.ErrLocationRateLimited) {
+		t.Errorf("just inside the interval: err = %v, want ErrLocationRateLimited", err)
+	}
+	s.clock.Advance(time.Millisecond)
+	if err := report("d1"); err != nil {
+		t.Errorf("after the interval: %v", err)
+	}
+	if n := len(s.repo.locations); n != 3 {
+		t.Errorf("saved %d locations, want only the 3 allowed", n)
+	}
+}
+
+func TestSubEpsilonMoveIsArchivedButNotPublished(t *testing.T) {
+	s := newLocationTestService(t, 5)
+
+	s.update(t, 43.2)
+	s.update(t, 43.2+metersNorth(2))
+	s.update(t, 43.2+metersNorth(4))
+
+	if n := len(s.repo.archived); n != 3 {
+		t.Errorf("archived %d locations, want every update", n)
+	}
+	if n := len(s.repo.locations); n != 3 {
+		t.Errorf("saved %d current locations, want every update", n)
+	}
+	if n := len(s.pub.to("location_fanout")); n != 1 {
+		t.Errorf("published %d location updates, want only the first", n)
+	}
+}
+
+func TestLocationsAreArchivedUnderTheActiveRide(t *testing.T) {
+	s := newLocationTestService(t, 5)
+	s.onlineDriver("d1", 43.2, 76.8)
+
+	s.update(t, 43.2)
+	if err := s.repo.SetDriverCurrentRide(context.Background(), "d1", "A"); err != nil {
+		t.Fatal(err)
+	}
+	s.update(t, 43.21)
+
+	if n := len(s.repo.archived); n != 2 {
+		t.Fatalf("archived %d locations, want 2", n)
+	}
+	if got := s.repo.archived[0].RideID; got != "" {
+		t.Errorf("location before the ride archived under %q, want none", got)
+	}
+	if got := s.repo.archived[1].RideID; got != "A" {
+		t.Errorf("location during the ride archived under %q, want A", got)
+	}
+}
+
+func TestRealMovePublishes(t *testing.T) {
+	s := newLocationTestService(t, 5)
+
+	s.update(t, 43.2)
+	s.update(t, 43.2+metersNorth(3))
+	// 6m from the last broadcast, though only 3m from the last update
+	s.update(t, 43.2+metersNorth(6))
+
+	updates := s.pub.to("location_fanout")
+	if len(updates) != 2 {
+		t.Fatalf("published %d location updates, want 2", len(updates))
+	}
+	loc := updates[1].body["location"].(map[string]interface{})
+	if loc["latitude"] != 43.2+metersNorth(6) {
+		t.Errorf("published latitude = %v, want the moved position", loc["latitude"])
+	}
+}
+
+func TestZeroEpsilonPublishesEveryUpdate(t *testing.T) {
+	s := newLocationTestService(t, 0)
+
+	for i := 0; i < 3; i++ {
+		s.update(t, 43.2)
+	}
+
+	if n := len(s.pub.to("location_fanout")); n != 3 {
+		t.Errorf("published %d location updates, want 3", n)
+	}
+}
+
+func TestStationaryDriverIsPublishedAgainAfterGoingOffline(t *testing.T) {
+	s := newLocationTestService(t, 5)
+	s.repo.addDriver("d1")
+	ctx := context.Background()
+	if _, err := s.DriverGoOnline(ctx, "d1", 43.2, 76.8, "Abay Ave 10"); err != nil {
+		t.Fatalf("DriverGoOnline: %v", err)
+	}
+
+	s.update(t, 43.2)
+	if _, err := s.DriverGoOffline(ctx, "d1"); err != nil {
+		t.Fatalf("DriverGoOffline: %v", err)
+	}
+	before := len(s.pub.to("location_fanout"))
+	s.update(t, 43.2)
+
+	if n := len(s.pub.to("location_fanout")) - before; n != 1 {
+		t.Errorf("published %d location updates after going offline, want 1", n)
+	}
+}
+
+// breakingDriver puts d1 online near the pickup used by rideRequest
+func breakingDriver(t *testing.T) *testService {
+	t.Helper()
+	s := newTestService(t)
+	s.repo.addDriver("d1")
+	s.ws.mu.Lock()
+	s.ws.connected["d1"] = true
+	s.ws.mu.Unlock()
+	if _, err := s.DriverGoOnline(context.Background(), "d1", 43.2390, 76.8900, "Abay Ave 10"); err != nil {
+		t.Fatalf("DriverGoOnline: %v", err)
+	}
+	return s
+}
+
+func TestDriverOnBreakIsNotMatched(t *testing.T) {
+	s := breakingDriver(t)
+	ctx := context.Background()
+
+	if err := s.DriverStartBreak(ctx, "d1"); err != nil {
+		t.Fatalf("DriverStartBreak: %v", err)
+	}
+	if got := s.repo.status("d1"); got != domain.DriverStatusOnBreak {
+		t.Fatalf("status = %s, want ON_BREAK", got)
+	}
+	if err := s.HandleRideMatchingRequest(ctx, rideRequest("A", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("match: %v", err)
+	}
+
+	if got := s.ws.sentTo("d1"); len(got) != 0 {
+		t.Errorf("d1 was sent %v while on break, want nothing", got)
+	}
+	if n := s.pub.rejections("A"); n != 1 {
+		t.Errorf("%d rejections published, want 1", n)
+	}
+}
+
+func TestResumingFromBreakKeepsSession(t *testing.T) {
+	s := breakingDriver(t)
+	ctx := context.Background()
+	before, _ := s.repo.GetActiveSession(ctx, "d1")
+
+	if err := s.DriverStartBreak(ctx, "d1"); err != nil {
+		t.Fatalf("DriverStartBreak: %v", err)
+	}
+	if err := s.DriverEndBreak(ctx, "d1"); err != nil {
+		t.Fatalf("DriverEndBreak: %v", err)
+	}
+	if err := s.HandleRideMatchingRequest(ctx, rideRequest("A", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("match: %v", err)
+	}
+
+	if got := s.ws.sentTo("d1"); len(got) != 1 || got[0] != "ride_offer" {
+		t.Errorf("d1 was sent %v after resuming, want an offer", got)
+	}
+	after, _ := s.repo.GetActiveSession(ctx, "d1")
+	if s.repo.sessionsCreated != 1 || after == nil || after.ID != before.ID {
+		t.Errorf("%d sessions created, open session %v; want the first one kept", s.repo.sessionsCreated, after)
+	}
+}
+
+func TestBreakRequiresMatchingStatus(t *testing.T) {
+	s := breakingDriver(t)
+	s.repo.addDriver("d2")
+	ctx := context.Background()
+
+	if err := s.DriverEndBreak(ctx, "d1"); !errors.Is(err, domain.ErrDriverNotOnBreak) {
+		t.Errorf("resume while available: err = %v, want ErrDriverNotOnBreak", err)
+	}
+	if err := s.DriverStartBreak(ctx, "d1"); err != nil {
+		t.Fatalf("DriverStartBreak: %v", err)
+	}
+	if err := s.DriverStartBreak(ctx, "d1"); !errors.Is(err, domain.ErrDriverNotAvailable) {
+		t.Errorf("second break: err = %v, want ErrDriverNotAvailable", err)
+	}
+	if err := s.DriverStartBreak(ctx, "d2"); !errors.Is(err, domain.ErrNoActiveSession) {
+		t.Errorf("break while offline: err = %v, want ErrNoActiveSession", err)
+	}
+}
+
+func TestRideTakenBackFreesDriver(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	s.onlineDriver("d1", 43.2390, 76.8900)
+	if err := s.repo.SetDriverCurrentRide(ctx, "d1", "A"); err != nil {
+		t.Fatal(err)
+	}
+
+	// What the ride service publishes when the assignment expires
+	if err := s.HandleRideStatusUpdate(ctx, "A", "d1", "REQUESTED", 0); err != nil {
+		t.Fatalf("HandleRideStatusUpdate: %v", err)
+	}
+
+	if got := s.repo.status("d1"); got != domain.DriverStatusAvailable {
+		t.Errorf("status = %s, want AVAILABLE", got)
+	}
+	if d, _ := s.repo.GetDriver(ctx, "d1"); d.CurrentRideID != "" {
+		t.Errorf("current ride = %q, want it cleared", d.CurrentRideID)
+	}
+	if got := s.ws.sentTo("d1"); len(got) != 1 || got[0] != "ride_cancelled" {
+		t.Errorf("d1 was sent %v, want the ride taken away", got)
+	}
+
+	// Free again, the driver gets the next ride
+	if err := s.HandleRideMatchingRequest(ctx, rideRequest("B", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("match: %v", err)
+	}
+	if got := s.ws.sentTo("d1"); got[len(got)-1] != "ride_offer" {
+		t.Errorf("d1 was sent %v, want an offer for the next ride", got)
+	}
+}
+
+func TestGetDriverIncludesRecentStats(t *testing.T) {
+	s := newTestService(t)
+	s.repo.addDriver("d1")
+	s.repo.stats = &domain.DriverStats{OffersReceived: 5, OffersAccepted: 3, AcceptanceRatePercent: 60}
+	ctx := context.Background()
+	if _, err := s.DriverGoOnline(ctx, "d1", 43.2, 76.8, "Abay Ave 10"); err != nil {
+		t.Fatalf("DriverGoOnline: %v", err)
+	}
+
+	driver, err := s.GetDriver(ctx, "d1")
+	if err != nil {
+		t.Fatalf("GetDriver: %v", err)
+	}
+
+	if driver.Stats == nil || driver.Stats.AcceptanceRatePercent != 60 {
+		t.Errorf("stats = %+v, want the repository's", driver.Stats)
+	}
+	if want := testNow.Add(-30 * 24 * time.Hour); !s.repo.statsSince.Equal(want) {
+		t.Errorf("stats since %v, want the last 30 days from %v", s.repo.statsSince, want)
+	}
+	if driver.CurrentSession == nil {
+		t.Error("session missing from the profile")
+	}
+}
+
+func TestGetDriverWithoutStatsStillReturnsProfile(t *testing.T) {
+	s := newTestService(t)
+	s.repo.addDriver("d1")
+	s.repo.statsErr = errors.New("connection reset")
+
+	driver, err := s.GetDriver(context.Background(), "d1")
+	if err != nil {
+		t.Fatalf("GetDriver: %v", err)
+	}
+	if driver.ID != "d1" || driver.Stats != nil {
+		t.Errorf("driver %s with stats %+v, want d1 without stats", driver.ID, driver.Stats)
+	}
+}
+
+// dropSocket marks driverID as online but without a live WebSocket
+func (ts *testService) dropSocket(driverID string) {
+	ts.ws.mu.Lock()
+	defer ts.ws.mu.Unlock()
+	delete(ts.ws.connected, driverID)
+}
+
+func TestNearbyDriversFlagConnectionConnectedFirst(t *testing.T) {
+	s := newTestService(t)
+	s.onlineDriver("d1", 43.2390+0.005, 76.8900) // nearest, but no socket
+	s.onlineDriver("d2", 43.2390+0.010, 76.8900)
+	s.onlineDriver("d3", 43.2390+0.020, 76.8900)
+	s.dropSocket("d1")
+
+	drivers, err := s.FindNearbyDrivers(context.Background(), 43.2390, 76.8900, "ECONOMY", 5, 1, 10)
+	if err != nil {
+		t.Fatalf("FindNearbyDrivers: %v", err)
+	}
+
+	var got []string
+	for _, d := range drivers {
+		got = append(got, fmt.Sprintf("%s/%v", d.DriverID, d.Connected))
+	}
+	if want := "[d2/true d3/true d1/false]"; fmt.Sprint(got) != want {
+		t.Errorf("nearby drivers %v, want %s", got, want)
+	}
+	if s.IsDriverConnected("d1") || !s.IsDriverConnected("d2") {
+		t.Error("IsDriverConnected disagrees with the sockets")
+	}
+}
+
+func TestMatchingOffersConnectedDriverOverNearerUnconnected(t *testing.T) {
+	s := newTestService(t)
+	s.onlineDriver("d1", 43.2390+0.005, 76.8900)
+	s.onlineDriver("d2", 43.2390+0.010, 76.8900)
+	s.dropSocket("d1")
+
+	if err := s.HandleRideMatchingRequest(context.Background(), rideRequest("A", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("match: %v", err)
+	}
+
+	if got := s.ws.sentTo("d2"); len(got) != 1 || got[0] != "ride_offer" {
+		t.Errorf("d2 was sent %v, want an offer", got)
+	}
+	if got := s.ws.sentTo("d1"); len(got) != 0 {
+		t.Errorf("unconnected d1 was sent %v, want nothing", got)
+	}
+}
+
+func TestMatchingWithNoConnectedDriverRejects(t *testing.T) {
+	s := newTestService(t)
+	s.onlineDriver("d1", 43.2390+0.005, 76.8900)
+	s.dropSocket("d1")
+
+	if err := s.HandleRideMatchingRequest(context.Background(), rideRequest("A", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("match: %v", err)
+	}
+
+	if n := s.pub.rejections("A"); n != 1 {
+		t.Errorf("%d rejections published, want 1", n)
+	}
+}
+
+func TestRideDetailsCarryMaskedPassengerContact(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	s.onlineDriver("d1", 43.2389, 76.8897)
+	if err := s.HandleRideMatchingRequest(ctx, rideRequest("A", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("match: %v", err)
+	}
+
+	if err := s.HandleDriverRideResponse(ctx, "d1", offerID("A", "d1"), "A", true); err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+
+	details, err := json.Marshal(s.ws.lastSent("d1", "ride_details"))
+	if err != nil {
+		t.Fatalf("marshal ride details: %v", err)
+	}
+	var got struct {
+		PassengerName string                  `json:"passenger_name"`
+		Contact       domain.PassengerContact `json:"passenger_contact"`
+	}
+	if err := json.Unmarshal(details, &got); err != nil {
+		t.Fatalf("unmarshal ride details: %v", err)
+	}
+	want := domain.PassengerContact{PassengerID: "passenger-A", FirstName: "Aigerim", MaskedPhone: "*********67", ChatID: "ride:A"}
+	if got.PassengerName != "Aigerim" || got.Contact != want {
+		t.Errorf("passenger %q with contact %+v, want Aigerim with %+v", got.PassengerName, got.Contact, want)
+	}
+	// The fake repository's passenger is Aigerim Sadykova, +7 701 123 4567
+	for _, raw := range []string{"Sadykova", "+77011234567", "7011234567", "123456"} {
+		if strings.Contains(string(details), raw) {
+			t.Errorf("ride details %s expose %q", details, raw)
+		}
+	}
+}
+
+func TestIdleDriverIsTakenOffline(t *testing.T) {
+	s := breakingDriver(t)
+	ctx := context.Background()
+
+	if err := s.HandleDriverIdle(ctx, "d1"); err != nil {
+		t.Fatalf("HandleDriverIdle: %v", err)
+	}
+
+	if got := s.repo.status("d1"); got != domain.DriverStatusOffline {
+		t.Errorf("status = %s, want OFFLINE", got)
+	}
+	if open := s.repo.openSessions("d1"); len(open) != 0 {
+		t.Errorf("%d sessions still open, want the shift ended", len(open))
+	}
+	published := false
+	for _, m := range s.pub.to("driver_topic") {
+		if m.routingKey == "driver.status.d1" && m.body["status"] == string(domain.DriverStatusOffline) {
+			published = true
+		}
+	}
+	if !published {
+		t.Error("OFFLINE status not published")
+	}
+
+	// A second reap of the same driver finds no session and is not an error
+	if err := s.HandleDriverIdle(ctx, "d1"); err != nil {
+		t.Errorf("HandleDriverIdle for an offline driver: %v", err)
+	}
+}
+
+func TestIdleDriverOnRideStaysOnline(t *testing.T) {
+	s := breakingDriver(t)
+	s.repo.mu.Lock()
+	s.repo.currentRides["d1"] = &domain.CurrentRide{RideID: "A", Status: "IN_PROGRESS"}
+	s.repo.mu.Unlock()
+
+	if err := s.HandleDriverIdle(context.Background(), "d1"); err != nil {
+		t.Fatalf("HandleDriverIdle: %v", err)
+	}
+	if got := s.repo.status("d1"); got != domain.DriverStatusAvailable {
+		t.Errorf("status = %s, want it left alone while the driver has a ride", got)
+	}
+	if open := s.repo.openSessions("d1"); len(open) != 1 {
+		t.Errorf("%d sessions open, want the shift kept", len(open))
+	}
+}
+
+func TestMatchingSkipsVehiclesWithTooFewSeats(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	// The two-seater is nearer, so only the seat check keeps it from the offer
+	s.onlineDriver("two-seat", 43.2390, 76.8900).VehicleAttrs = map[string]interface{}{"seats": 2}
+	s.onlineDriver("five-seat", 43.2410, 76.8920).VehicleAttrs = map[string]interface{}{"seats": 5}
+
+	req := rideRequest("G", 43.2390, 76.8900)
+	req.PassengerCount = 4
+	if err := s.HandleRideMatchingRequest(ctx, req); err != nil {
+		t.Fatalf("HandleRideMatchingRequest: %v", err)
+	}
+	if got := s.repo.offeredTo("G"); len(got) != 1 || got[0] != "five-seat" {
+		t.Errorf("ride offered to %v, want only five-seat", got)
+	}
+	if got := s.repo.lastSearch().minSeats; got != 4 {
+		t.Errorf("search minSeats = %d, want 4", got)
+	}
+}
+
+func TestAcceptanceReachesRideServiceWhenDriverReloadFails(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	s.onlineDriver("d1", 43.2390, 76.8900)
+	if err := s.HandleRideMatchingRequest(ctx, rideRequest("A", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("match: %v", err)
+	}
+	s.repo.mu.Lock()
+	s.repo.boundErr = errors.New("connection reset")
+	s.repo.mu.Unlock()
+
+	if err := s.HandleDriverRideResponse(ctx, "d1", offerID("A", "d1"), "A", true); err != nil {
+		t.Fatalf("accept: %v, want the bound driver's acceptance to go through", err)
+	}
+
+	accepted := false
+	for _, m := range s.pub.to("driver_topic") {
+		if m.routingKey == "driver.response.A" && m.body["accepted"] == true {
+			accepted = true
+		}
+	}
+	if !accepted {
+		t.Error("ride service never told driver d1 accepted ride A")
+	}
+	if got := s.ws.sentTo("d1"); len(got) == 0 || got[len(got)-1] != "ride_details" {
+		t.Errorf("d1 was sent %v, want the ride details last", got)
+	}
+}
+
+func TestDriverResponsesNameThePassenger(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	s.onlineDriver("d1", 43.2390, 76.8900)
+	if err := s.HandleRideMatchingRequest(ctx, rideRequest("A", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("match A: %v", err)
+	}
+	if err := s.HandleDriverRideResponse(ctx, "d1", offerID("A", "d1"), "A", true); err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	// Nobody is left for B
+	if err := s.HandleRideMatchingRequest(ctx, rideRequest("B", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("match B: %v", err)
+	}
+
+	want := map[string]interface{}{"driver.response.A": true, "driver.response.B": false}
+	for _, m := range s.pub.to("driver_topic") {
+		accepted, ok := want[m.routingKey]
+		if !ok {
+			continue
+		}
+		delete(want, m.routingKey)
+		rideID := strings.TrimPrefix(m.routingKey, "driver.response.")
+		if m.body["accepted"] != accepted || m.body["passenger_id"] != "passenger-"+rideID {
+			t.Errorf("%s = %v, want accepted=%v naming passenger-%s", m.routingKey, m.body, accepted, rideID)
+		}
+	}
+	if len(want) != 0 {
+		t.Errorf("no response published for %v", want)
+	}
+}
+
+func TestRideOfferExpiryIsUTCWholeSeconds(t *testing.T) {
+	s := newTestService(t)
+	// The service clock runs in local time with a fraction of a second
+	s.clock = clock.NewFake(testNow.In(time.FixedZone("ALMT", 5*60*60)).Add(500 * time.Millisecond))
+	s.SetClock(s.clock)
+	s.onlineDriver("d1", 43.2390, 76.8900)
+
+	if err := s.HandleRideMatchingRequest(context.Background(), rideRequest("A", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("match: %v", err)
+	}
+
+	s.ws.mu.Lock()
+	defer s.ws.mu.Unlock()
+	if len(s.ws.sent) != 1 {
+		t.Fatalf("sent %d messages, want the offer", len(s.ws.sent))
+	}
+	offer, _ := s.ws.sent[0].payload.(map[string]interface{})
+	raw, _ := offer["expires_at"].(string)
+	expiresAt, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		t.Fatalf("expires_at %q: %v", raw, err)
+	}
+	if !strings.HasSuffix(raw, "Z") || expiresAt.Nanosecond() != 0 {
+		t.Errorf("expires_at = %q, want UTC with whole seconds", raw)
+	}
+}
+
+func TestPublishedTimestampsAreUTCWholeSeconds(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	s.clock = clock.NewFake(testNow.In(time.FixedZone("ALMT", 5*60*60)).Add(500 * time.Millisecond))
+	s.SetClock(s.clock)
+	matchedDriver(t, s)
+
+	if err := s.DriverEnRoute(ctx, "d1", "A"); err != nil {
+		t.Fatalf("DriverEnRoute: %v", err)
+	}
+	if _, err := s.UpdateDriverLocation(ctx, "d1", 43.2390, 76.8900, 30, 90, 0, ""); err != nil {
+		t.Fatalf("UpdateDriverLocation: %v", err)
+	}
+
+	statuses, locations := s.pub.to("driver_topic"), s.pub.to("location_fanout")
+	if len(statuses) == 0 || len(locations) != 1 {
+		t.Fatalf("published %d status and %d location messages, want both", len(statuses), len(locations))
+	}
+	for _, m := range append(statuses, locations...) {
+		raw, _ := m.body["timestamp"].(string)
+		ts, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			t.Fatalf("%s timestamp %q: %v", m.routingKey, raw, err)
+		}
+		if !strings.HasSuffix(raw, "Z") || ts.Nanosecond() != 0 {
+			t.Errorf("%s timestamp = %q, want UTC with whole seconds", m.routingKey, raw)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package app
+
+import (
+	"math"
+
+	"ride-hail/internal/driver_location_service/domain"
+	"ride-hail/pkg/geo"
+	"ride-hail/pkg/pricing"
+)
+
+// SetFareTable sets the pricing used to adjust a ride's fare for the distance
+// actually driven. It must be the table the ride service prices estimates with,
+// or the adjustment will not reflect its fares.
+func (s *DriverLocationService) SetFareTable(t *pricing.Table) {
+	s.fareTable = t
+}
+
+// calculateRideEarnings prices a completed ride. The offer previewed
+// CalculateDriverEarnings of the estimated fare; the final fare is that same
+// estimate plus the price of the distance driven beyond (or short of) the
+// estimated pickup-to-destination distance, so a ride that goes exactly as
+// estimated pays exactly the preview. Without a reported distance or known
+// rates the estimate is final.
+func (s *DriverLocationService) calculateRideEarnings(basis *domain.RideFareBasis, actualDistanceKm float64) *domain.RideEarnings {
+	estimatedKm := geo.HaversineKm(basis.Pickup.Lat, basis.Pickup.Lng, basis.Destination.Lat, basis.Destination.Lng)
+
+	finalFare := basis.EstimatedFare
+	if s.fareTable != nil && actualDistanceKm > 0 {
+		if rates, ok := s.fareTable.RatesFor(basis.VehicleType); ok {
+			adjustment := s.fareTable.Fare(rates, actualDistanceKm) - s.fareTable.Fare(rates, estimatedKm)
+			finalFare = math.Max(basis.EstimatedFare+adjustment, 0)
+		}
+	}
+
+	preview := s.CalculateDriverEarnings(basis.EstimatedFare)
+	earnings := s.CalculateDriverEarnings(finalFare)
+	return &domain.RideEarnings{
+		EstimatedFare:       basis.EstimatedFare,
+		FinalFare:           finalFare,
+		EstimatedDistanceKm: estimatedKm,
+		ActualDistanceKm:    actualDistanceKm,
+		PreviewEarnings:     preview,
+		Earnings:            earnings,
+		DistanceAdjustment:  earnings - preview,
+	}
+}
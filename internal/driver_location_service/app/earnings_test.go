@@ -0,0 +1,254 @@
+package app
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"ride-hail/internal/driver_location_service/domain"
+	"ride-hail/pkg/geo"
+	"ride-hail/pkg/pricing"
+)
+
+// testSharePercent is deliberately not the 80% default, so a path that still
+// hardcodes the old cut shows up as a wrong amount
+const testSharePercent = 70.0
+
+func newShareTestService(t *testing.T) *testService {
+	t.Helper()
+	s := newTestService(t)
+	if err := s.SetDriverSharePercent(testSharePercent); err != nil {
+		t.Fatalf("SetDriverSharePercent: %v", err)
+	}
+	return s
+}
+
+// sessionEarnings returns the earnings booked to the driver's open session
+func (r *fakeRepo) sessionEarnings(driverID string) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.sessions {
+		if s.DriverID == driverID && s.EndedAt == nil {
+			return s.TotalEarnings
+		}
+	}
+	return 0
+}
+
+func assertAmount(t *testing.T, what string, got, want float64) {
+	t.Helper()
+	if math.Abs(got-want) > 1e-9 {
+		t.Errorf("%s = %v, want %v", what, got, want)
+	}
+}
+
+func TestSetDriverSharePercentRejectsOutOfRange(t *testing.T) {
+	s := newTestService(t)
+	assertAmount(t, "default earnings on 1000", s.CalculateDriverEarnings(1000), 800)
+
+	for _, percent := range []float64{-1, 100.5} {
+		if err := s.SetDriverSharePercent(percent); err == nil {
+			t.Errorf("SetDriverSharePercent(%v) accepted", percent)
+		}
+	}
+	assertAmount(t, "earnings after rejected changes", s.CalculateDriverEarnings(1000), 800)
+
+	for _, percent := range []float64{0, 100} {
+		if err := s.SetDriverSharePercent(percent); err != nil {
+			t.Errorf("SetDriverSharePercent(%v): %v", percent, err)
+		}
+		assertAmount(t, "earnings on 1000", s.CalculateDriverEarnings(1000), 10*percent)
+	}
+}
+
+func TestOffersUseConfiguredShare(t *testing.T) {
+	s := newShareTestService(t)
+	s.onlineDriver("d1", 43.2389, 76.8897)
+	req := rideRequest("A", 43.2390, 76.8900)
+	want := req.EstimatedFare * testSharePercent / 100
+
+	if err := s.HandleRideMatchingRequest(context.Background(), req); err != nil {
+		t.Fatalf("match: %v", err)
+	}
+
+	var offer map[string]interface{}
+	s.ws.mu.Lock()
+	for _, m := range s.ws.sent {
+		if m.driverID == "d1" && m.kind == "ride_offer" {
+			offer = m.payload.(map[string]interface{})
+		}
+	}
+	s.ws.mu.Unlock()
+	if offer == nil {
+		t.Fatal("no offer sent to d1")
+	}
+	assertAmount(t, "offer driver_earnings", offer["driver_earnings"].(float64), want)
+
+	listed := s.ListDriverOffers("d1")
+	if len(listed) != 1 {
+		t.Fatalf("%d offers listed, want 1", len(listed))
+	}
+	assertAmount(t, "listed DriverEarnings", listed[0].DriverEarnings, want)
+}
+
+func TestRideEarningsUseConfiguredShare(t *testing.T) {
+	s := newShareTestService(t)
+	basis := &domain.RideFareBasis{
+		EstimatedFare: 2000,
+		Pickup:        domain.Location{Lat: 43.2390, Lng: 76.8900},
+		Destination:   domain.Location{Lat: 43.2190, Lng: 76.8600},
+		VehicleType:   "ECONOMY",
+	}
+
+	// No fare table, so the estimate is final
+	e := s.calculateRideEarnings(basis, 5)
+	assertAmount(t, "PreviewEarnings", e.PreviewEarnings, 1400)
+	assertAmount(t, "Earnings", e.Earnings, 1400)
+	assertAmount(t, "DistanceAdjustment", e.DistanceAdjustment, 0)
+}
+
+func TestSupportCompletionUsesConfiguredShare(t *testing.T) {
+	s := newShareTestService(t)
+	ctx := context.Background()
+	s.onlineDriver("d1", 43.2389, 76.8897)
+	if _, err := s.repo.CreateDriverSession(ctx, "d1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.HandleRideStatusUpdate(ctx, "A", "d1", "COMPLETED", 1500); err != nil {
+		t.Fatalf("HandleRideStatusUpdate: %v", err)
+	}
+
+	assertAmount(t, "session earnings", s.repo.sessionEarnings("d1"), 1050)
+	s.ws.mu.Lock()
+	defer s.ws.mu.Unlock()
+	if len(s.ws.sent) != 1 || s.ws.sent[0].kind != "ride_status_update" {
+		t.Fatalf("driver was sent %v, want one ride_status_update", s.ws.sent)
+	}
+	update := s.ws.sent[0].payload.(map[string]interface{})
+	assertAmount(t, "status driver_earnings", update["driver_earnings"].(float64), 1050)
+}
+
+func TestNoShowFeeUsesConfiguredShare(t *testing.T) {
+	s := newShareTestService(t)
+	ctx := context.Background()
+	s.SetNoShowPolicy(0, 200)
+	s.onlineDriver("d1", 43.2389, 76.8897)
+	if _, err := s.repo.CreateDriverSession(ctx, "d1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.repo.SetDriverCurrentRide(ctx, "d1", "A"); err != nil {
+		t.Fatal(err)
+	}
+	s.repo.mu.Lock()
+	s.repo.currentRides["d1"].Status = "ARRIVED"
+	s.repo.arrivedAt["A"] = testNow
+	s.repo.mu.Unlock()
+
+	noShow, err := s.ReportPassengerNoShow(ctx, "d1", "A")
+	if err != nil {
+		t.Fatalf("ReportPassengerNoShow: %v", err)
+	}
+	assertAmount(t, "no-show Earnings", noShow.Earnings, 140)
+	assertAmount(t, "session earnings", s.repo.sessionEarnings("d1"), 140)
+}
+
+// offeredAndCompleted offers ride A to d1, who accepts it, and completes it
+// having driven drivenKm. It returns the offer's driver_earnings and the payout.
+func offeredAndCompleted(t *testing.T, s *testService, drivenKm func(estimatedKm float64) float64) (float64, *domain.RideEarnings) {
+	t.Helper()
+	ctx := context.Background()
+	s.onlineDriver("d1", 43.2389, 76.8897)
+	if _, err := s.repo.CreateDriverSession(ctx, "d1"); err != nil {
+		t.Fatal(err)
+	}
+	req := rideRequest("A", 43.2390, 76.8900)
+	if err := s.HandleRideMatchingRequest(ctx, req); err != nil {
+		t.Fatalf("match: %v", err)
+	}
+	offer, ok := s.ws.lastSent("d1", "ride_offer").(map[string]interface{})
+	if !ok {
+		t.Fatal("no offer sent to d1")
+	}
+	if err := s.repo.SetDriverCurrentRide(ctx, "d1", "A"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The ride as the ride service priced it
+	s.repo.fareBases = map[string]*domain.RideFareBasis{"A": {
+		EstimatedFare: req.EstimatedFare,
+		VehicleType:   req.RideType,
+		Pickup:        req.PickupLocation,
+		Destination:   req.DestinationLocation,
+	}}
+	estimatedKm := geo.HaversineKm(req.PickupLocation.Lat, req.PickupLocation.Lng, req.DestinationLocation.Lat, req.DestinationLocation.Lng)
+
+	earnings, err := s.CompleteRide(ctx, "d1", "A", drivenKm(estimatedKm), 12)
+	if err != nil {
+		t.Fatalf("CompleteRide: %v", err)
+	}
+	return offer["driver_earnings"].(float64), earnings
+}
+
+func TestPreviewAndPayoutAgreeWhenDrivenAsEstimated(t *testing.T) {
+	s := newShareTestService(t)
+	s.SetFareTable(pricing.DefaultTable())
+
+	preview, e := offeredAndCompleted(t, s, func(estimatedKm float64) float64 { return estimatedKm })
+
+	assertAmount(t, "payout", e.Earnings, preview)
+	assertAmount(t, "PreviewEarnings", e.PreviewEarnings, preview)
+	assertAmount(t, "DistanceAdjustment", e.DistanceAdjustment, 0)
+	assertAmount(t, "FinalFare", e.FinalFare, e.EstimatedFare)
+	assertAmount(t, "session earnings", s.repo.sessionEarnings("d1"), preview)
+
+	msgs := s.pub.to("driver_topic")
+	if len(msgs) == 0 || msgs[len(msgs)-1].body["status"] != "COMPLETED" {
+		t.Fatalf("published %v, want a COMPLETED status last", msgs)
+	}
+	assertAmount(t, "published final_fare", msgs[len(msgs)-1].body["final_fare"].(float64), e.EstimatedFare)
+}
+
+func TestPayoutAdjustsForDistanceDriven(t *testing.T) {
+	table := pricing.DefaultTable()
+	economy, _ := table.RatesFor("ECONOMY")
+
+	for _, tt := range []struct {
+		name  string
+		extra float64 // km beyond the estimate
+	}{
+		{"detour", 2},
+		{"shortcut", -0.5},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newShareTestService(t)
+			s.SetFareTable(table)
+
+			var estimatedKm float64
+			preview, e := offeredAndCompleted(t, s, func(km float64) float64 {
+				estimatedKm = km
+				return km + tt.extra
+			})
+
+			fareDelta := table.Fare(economy, estimatedKm+tt.extra) - table.Fare(economy, estimatedKm)
+			assertAmount(t, "PreviewEarnings", e.PreviewEarnings, preview)
+			assertAmount(t, "FinalFare", e.FinalFare, e.EstimatedFare+fareDelta)
+			assertAmount(t, "DistanceAdjustment", e.DistanceAdjustment, fareDelta*testSharePercent/100)
+			assertAmount(t, "payout", e.Earnings, preview+e.DistanceAdjustment)
+			if (e.DistanceAdjustment > 0) != (tt.extra > 0) {
+				t.Errorf("adjustment %v for %+v km, want the same sign", e.DistanceAdjustment, tt.extra)
+			}
+			assertAmount(t, "ActualDistanceKm", e.ActualDistanceKm, estimatedKm+tt.extra)
+		})
+	}
+}
+
+func TestPayoutIsThePreviewWithoutADistance(t *testing.T) {
+	s := newShareTestService(t)
+	s.SetFareTable(pricing.DefaultTable())
+
+	preview, e := offeredAndCompleted(t, s, func(float64) float64 { return 0 })
+
+	assertAmount(t, "payout", e.Earnings, preview)
+	assertAmount(t, "DistanceAdjustment", e.DistanceAdjustment, 0)
+}
@@ -0,0 +1,191 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"ride-hail/internal/driver_location_service/domain"
+)
+
+// matchedDriver binds d1 to ride A, which the ride service has marked MATCHED
+func matchedDriver(t *testing.T, s *testService) {
+	t.Helper()
+	s.onlineDriver("d1", 43.2389, 76.8897)
+	if err := s.repo.SetDriverCurrentRide(context.Background(), "d1", "A"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// rideMovesTo stands in for the ride service recording a new status on d1's ride
+func rideMovesTo(s *testService, status string) {
+	s.repo.mu.Lock()
+	s.repo.currentRides["d1"].Status = status
+	s.repo.mu.Unlock()
+}
+
+func TestEnRouteArrivedStartProgression(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	matchedDriver(t, s)
+
+	if err := s.DriverEnRoute(ctx, "d1", "A"); err != nil {
+		t.Fatalf("DriverEnRoute: %v", err)
+	}
+	if got := s.repo.status("d1"); got != domain.DriverStatusEnRoute {
+		t.Errorf("driver status after en route = %s, want EN_ROUTE", got)
+	}
+	rideMovesTo(s, "EN_ROUTE")
+
+	if err := s.DriverArrived(ctx, "d1", "A"); err != nil {
+		t.Fatalf("DriverArrived: %v", err)
+	}
+	rideMovesTo(s, "ARRIVED")
+
+	if err := s.StartRide(ctx, "d1", "A"); err != nil {
+		t.Fatalf("StartRide: %v", err)
+	}
+	if got := s.repo.status("d1"); got != domain.DriverStatusBusy {
+		t.Errorf("driver status after start = %s, want BUSY", got)
+	}
+
+	msgs := s.pub.to("driver_topic")
+	want := []struct{ status, old string }{
+		{"EN_ROUTE", "MATCHED"},
+		{"ARRIVED", "EN_ROUTE"},
+		{"IN_PROGRESS", "ARRIVED"},
+	}
+	if len(msgs) != len(want) {
+		t.Fatalf("published %d driver status messages, want %d", len(msgs), len(want))
+	}
+	for i, w := range want {
+		body := msgs[i].body
+		if msgs[i].routingKey != "driver.status.d1" || body["status"] != w.status {
+			t.Errorf("message %d = %s %v, want status %s", i, msgs[i].routingKey, body["status"], w.status)
+		}
+		if w.old != "" && body["old_status"] != w.old {
+			t.Errorf("message %d old_status = %v, want %s", i, body["old_status"], w.old)
+		}
+	}
+	enRoute := msgs[0].body
+	if enRoute["passenger_id"] != "passenger-A" || enRoute["latitude"] != 43.2389 || enRoute["longitude"] != 76.8897 {
+		t.Errorf("en route message = %v, want the passenger and the driver's location", enRoute)
+	}
+	if start := msgs[2].body; start["passenger_id"] != "passenger-A" || start["new_status"] != "IN_PROGRESS" {
+		t.Errorf("start message = %v, want the passenger told the trip started", start)
+	}
+}
+
+func TestStartRideRejections(t *testing.T) {
+	tests := []struct {
+		name     string
+		driverID string
+		rideID   string
+		status   string
+		want     error
+	}{
+		{"another driver's ride", "d2", "A", "ARRIVED", domain.ErrRideNotAssigned},
+		{"unknown ride", "d1", "B", "ARRIVED", domain.ErrRideNotAssigned},
+		{"before setting off", "d1", "A", "MATCHED", domain.ErrRideNotStartable},
+		{"already completed", "d1", "A", "COMPLETED", domain.ErrRideNotStartable},
+		{"cancelled", "d1", "A", "CANCELLED", domain.ErrRideNotStartable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t)
+			matchedDriver(t, s)
+			s.onlineDriver("d2", 43.2389, 76.8897)
+			rideMovesTo(s, tt.status)
+			before := s.repo.status(tt.driverID)
+
+			err := s.StartRide(context.Background(), tt.driverID, tt.rideID)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if msgs := s.pub.to("driver_topic"); len(msgs) != 0 {
+				t.Errorf("published %d messages, want none", len(msgs))
+			}
+			if got := s.repo.status(tt.driverID); got != before {
+				t.Errorf("%s went from %s to %s despite the rejection", tt.driverID, before, got)
+			}
+		})
+	}
+}
+
+func TestStartRideAgainIsANoOp(t *testing.T) {
+	s := newTestService(t)
+	matchedDriver(t, s)
+	rideMovesTo(s, "IN_PROGRESS")
+
+	if err := s.StartRide(context.Background(), "d1", "A"); err != nil {
+		t.Fatalf("StartRide: %v", err)
+	}
+	if msgs := s.pub.to("driver_topic"); len(msgs) != 0 {
+		t.Errorf("published %d messages for a ride already in progress, want none", len(msgs))
+	}
+}
+
+func TestEnRouteAgainIsANoOp(t *testing.T) {
+	s := newTestService(t)
+	matchedDriver(t, s)
+	rideMovesTo(s, "EN_ROUTE")
+
+	if err := s.DriverEnRoute(context.Background(), "d1", "A"); err != nil {
+		t.Fatalf("DriverEnRoute: %v", err)
+	}
+	if msgs := s.pub.to("driver_topic"); len(msgs) != 0 {
+		t.Errorf("published %d messages for a ride already en route, want none", len(msgs))
+	}
+}
+
+func TestEnRouteRejections(t *testing.T) {
+	tests := []struct {
+		name     string
+		driverID string
+		rideID   string
+		status   string
+		want     error
+	}{
+		{"another driver's ride", "d2", "A", "MATCHED", domain.ErrRideNotAssigned},
+		{"unknown ride", "d1", "B", "MATCHED", domain.ErrRideNotAssigned},
+		{"after arriving", "d1", "A", "ARRIVED", domain.ErrRideNotMatched},
+		{"trip started", "d1", "A", "IN_PROGRESS", domain.ErrRideNotMatched},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t)
+			matchedDriver(t, s)
+			s.onlineDriver("d2", 43.2389, 76.8897)
+			rideMovesTo(s, tt.status)
+
+			err := s.DriverEnRoute(context.Background(), tt.driverID, tt.rideID)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if msgs := s.pub.to("driver_topic"); len(msgs) != 0 {
+				t.Errorf("published %d messages, want none", len(msgs))
+			}
+			if got := s.repo.status(tt.driverID); got == domain.DriverStatusEnRoute {
+				t.Errorf("%s marked EN_ROUTE despite the rejection", tt.driverID)
+			}
+		})
+	}
+}
+
+func TestEnRouteBeforeRideServiceRecordsTheAccept(t *testing.T) {
+	s := newTestService(t)
+	matchedDriver(t, s)
+	s.onlineDriver("d2", 43.2389, 76.8897)
+	s.repo.unrecorded = map[string]bool{"A": true}
+
+	if err := s.DriverEnRoute(context.Background(), "d2", "A"); !errors.Is(err, domain.ErrRideNotAssigned) {
+		t.Fatalf("other driver: err = %v, want ErrRideNotAssigned", err)
+	}
+	if err := s.DriverEnRoute(context.Background(), "d1", "A"); err != nil {
+		t.Fatalf("DriverEnRoute: %v", err)
+	}
+	msgs := s.pub.to("driver_topic")
+	if len(msgs) != 1 || msgs[0].body["old_status"] != "REQUESTED" || msgs[0].body["new_status"] != "EN_ROUTE" {
+		t.Errorf("published %v, want one REQUESTED -> EN_ROUTE update", msgs)
+	}
+}
@@ -0,0 +1,736 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"ride-hail/internal/driver_location_service/domain"
+	"ride-hail/pkg/clock"
+	"ride-hail/pkg/logger"
+)
+
+// testNow is where every test's fake clock starts
+var testNow = time.Date(2024, 12, 16, 10, 0, 0, 0, time.UTC)
+
+type nopLogger struct{}
+
+func (l nopLogger) WithFields(logger.LogFields) logger.Logger { return l }
+func (nopLogger) Info(action, message string)                 {}
+func (nopLogger) Debug(action, message string)                {}
+func (nopLogger) Warn(action, message string)                 {}
+func (nopLogger) Error(action string, err error)              {}
+
+// fakeRepo keeps drivers, sessions and rides in memory. Methods a test does not
+// set up fall through to the nil embedded interface and panic.
+type fakeRepo struct {
+	domain.DriverLocationRepository
+
+	mu           sync.Mutex
+	clock        *clock.Fake
+	drivers      map[string]*domain.Driver
+	sessions     map[string]*domain.DriverSession // by session ID
+	nextID       int
+	currentRides map[string]*domain.CurrentRide // driverID -> active ride
+	unrecorded   map[string]bool                // rides whose accept the ride service hasn't consumed yet
+
+	sessionsCreated int
+	locations       []domain.LocationUpdate // SaveDriverLocation calls
+	addresses       []string                // and the address each saved
+	archived        []domain.LocationUpdate // ArchiveLocation calls
+	busy            time.Duration           // returned by GetDriverBusyDuration
+	busyWindow      [2]time.Time            // and the window it was last asked for
+	shifts          []*domain.ShiftSummary  // newest last
+
+	searches       []nearbySearch      // FindNearbyDrivers calls
+	offersSent     map[string][]string // rideID -> drivers offered it
+	offerResponses []offerResponse     // RecordOfferResponse calls
+	pendingOffers  map[string]*domain.PendingOffer
+
+	batches         [][]domain.LocationUpdate
+	batchAddresses  []string
+	currentLocation map[string]domain.LocationUpdate
+	headings        map[string]float64   // last known heading, absent = unknown
+	arrivedAt       map[string]time.Time // rideID -> when the driver reported arriving
+
+	boundErr error // returned by GetDriver once the driver is bound to a ride
+
+	stats      *domain.DriverStats // returned by GetDriverStats
+	statsErr   error
+	statsSince time.Time // and the window start it was last asked for
+
+	heatmapCells   []domain.HeatmapCell // returned by CountRequestedRidesByCell
+	heatmapQueries []domain.BoundingBox // and the box of each call
+
+	fareBases map[string]*domain.RideFareBasis // rideID -> returned by GetRideFareBasis
+
+	history        []*domain.LocationHistory     // paged by GetLocationHistory, oldest first
+	historyQueries []domain.LocationHistoryQuery // and the query of each call
+}
+
+func newFakeRepo(c *clock.Fake) *fakeRepo {
+	return &fakeRepo{
+		clock:           c,
+		drivers:         make(map[string]*domain.Driver),
+		sessions:        make(map[string]*domain.DriverSession),
+		currentRides:    make(map[string]*domain.CurrentRide),
+		currentLocation: make(map[string]domain.LocationUpdate),
+		headings:        make(map[string]float64),
+		arrivedAt:       make(map[string]time.Time),
+		offersSent:      make(map[string][]string),
+		pendingOffers:   make(map[string]*domain.PendingOffer),
+	}
+}
+
+// nearbySearch is the arguments of one FindNearbyDrivers call
+type nearbySearch struct {
+	vehicleType  string
+	radiusMeters float64
+	minRating    float64
+	minSeats     int
+	exclude      []string
+}
+
+// offerResponse is one RecordOfferResponse call
+type offerResponse struct {
+	driverID, rideID, offerID, response string
+}
+
+// id returns a fresh identifier with prefix
+func (r *fakeRepo) id(prefix string) string {
+	r.nextID++
+	return fmt.Sprintf("%s-%d", prefix, r.nextID)
+}
+
+// addDriver stores an offline economy driver rated 5 and returns it for tweaking
+func (r *fakeRepo) addDriver(driverID string) *domain.Driver {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d := &domain.Driver{
+		ID:          driverID,
+		Email:       driverID + "@example.com",
+		VehicleType: "ECONOMY",
+		Rating:      5,
+		Status:      domain.DriverStatusOffline,
+	}
+	r.drivers[driverID] = d
+	return d
+}
+
+// status returns the driver's stored status
+func (r *fakeRepo) status(driverID string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.drivers[driverID].Status
+}
+
+// openSessions returns the driver's sessions that have not ended
+func (r *fakeRepo) openSessions(driverID string) []*domain.DriverSession {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var open []*domain.DriverSession
+	for _, s := range r.sessions {
+		if s.DriverID == driverID && s.EndedAt == nil {
+			open = append(open, s)
+		}
+	}
+	return open
+}
+
+func (r *fakeRepo) GetDriver(ctx context.Context, driverID string) (*domain.Driver, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d, ok := r.drivers[driverID]
+	if !ok {
+		return nil, fmt.Errorf("driver not found: %s", driverID)
+	}
+	if r.boundErr != nil && d.CurrentRideID != "" {
+		return nil, r.boundErr
+	}
+	copied := *d
+	return &copied, nil
+}
+
+func (r *fakeRepo) UpdateDriverStatus(ctx context.Context, driverID string, status string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d, ok := r.drivers[driverID]
+	if !ok {
+		return fmt.Errorf("driver not found: %s", driverID)
+	}
+	d.Status = status
+	return nil
+}
+
+// CreateDriverSession returns the open session instead of adding a second one,
+// like the unique index on open sessions does in Postgres
+func (r *fakeRepo) CreateDriverSession(ctx context.Context, driverID string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.sessions {
+		if s.DriverID == driverID && s.EndedAt == nil {
+			return s.ID, nil
+		}
+	}
+	s := &domain.DriverSession{ID: r.id("session"), DriverID: driverID, StartedAt: r.clock.Now()}
+	r.sessions[s.ID] = s
+	r.sessionsCreated++
+	return s.ID, nil
+}
+
+func (r *fakeRepo) EndDriverSession(ctx context.Context, sessionID string, endedAt time.Time) (*domain.DriverSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sessions[sessionID]
+	if !ok || s.EndedAt != nil {
+		return nil, fmt.Errorf("%w: session %s not found or already ended", domain.ErrNoActiveSession, sessionID)
+	}
+	s.EndedAt = &endedAt
+	copied := *s
+	return &copied, nil
+}
+
+func (r *fakeRepo) GetActiveSession(ctx context.Context, driverID string) (*domain.DriverSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.sessions {
+		if s.DriverID == driverID && s.EndedAt == nil {
+			copied := *s
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+// UpdateDriverSessionStats adds to the open session's totals
+func (r *fakeRepo) UpdateDriverSessionStats(ctx context.Context, driverID string, rides int, earnings float64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.sessions {
+		if s.DriverID == driverID && s.EndedAt == nil {
+			s.TotalRides += rides
+			s.TotalEarnings += earnings
+		}
+	}
+	return nil
+}
+
+func (r *fakeRepo) GetDriverBusyDuration(ctx context.Context, driverID string, from, to time.Time) (time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.busyWindow = [2]time.Time{from, to}
+	return r.busy, nil
+}
+
+// SaveShiftSummary keeps the first summary saved for a session
+func (r *fakeRepo) SaveShiftSummary(ctx context.Context, summary *domain.ShiftSummary) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.shifts {
+		if s.SessionID == summary.SessionID {
+			return nil
+		}
+	}
+	r.shifts = append(r.shifts, summary)
+	return nil
+}
+
+// ListShiftSummaries pages through the driver's shifts, newest first
+func (r *fakeRepo) ListShiftSummaries(ctx context.Context, driverID string, limit, offset int) ([]*domain.ShiftSummary, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var mine []*domain.ShiftSummary
+	for i := len(r.shifts) - 1; i >= 0; i-- {
+		if r.shifts[i].DriverID == driverID {
+			mine = append(mine, r.shifts[i])
+		}
+	}
+	if offset >= len(mine) {
+		return nil, nil
+	}
+	mine = mine[offset:]
+	if len(mine) > limit {
+		mine = mine[:limit]
+	}
+	return mine, nil
+}
+
+// FindNearbyDrivers returns AVAILABLE drivers with a location inside the radius,
+// nearest first, applying the same filters as the Postgres query
+func (r *fakeRepo) FindNearbyDrivers(ctx context.Context, latitude, longitude float64, vehicleType string, radiusMeters, minRating float64, minSeats, limit int, excludeDriverIDs []string) ([]*domain.NearbyDriver, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.searches = append(r.searches, nearbySearch{vehicleType, radiusMeters, minRating, minSeats, excludeDriverIDs})
+
+	excluded := make(map[string]bool)
+	for _, id := range excludeDriverIDs {
+		excluded[id] = true
+	}
+	var found []*domain.NearbyDriver
+	for id, d := range r.drivers {
+		loc, ok := r.currentLocation[id]
+		if !ok || excluded[id] || d.Status != domain.DriverStatusAvailable || d.VehicleType != vehicleType || d.Rating < minRating {
+			continue
+		}
+		if seats, ok := d.VehicleAttrs["seats"].(int); ok && minSeats > 1 && seats < minSeats {
+			continue
+		}
+		meters := distanceMeters(latitude, longitude, loc.Latitude, loc.Longitude)
+		if meters > radiusMeters {
+			continue
+		}
+		nd := &domain.NearbyDriver{
+			DriverID:          id,
+			Email:             d.Email,
+			Rating:            d.Rating,
+			VehicleType:       d.VehicleType,
+			Latitude:          loc.Latitude,
+			Longitude:         loc.Longitude,
+			DistanceKm:        meters / 1000,
+			SpeedKmh:          loc.SpeedKmh,
+			LocationUpdatedAt: loc.Timestamp,
+		}
+		if heading, ok := r.headings[id]; ok {
+			nd.HeadingDegrees = &heading
+		}
+		found = append(found, nd)
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].DistanceKm < found[j].DistanceKm })
+	if limit > 0 && len(found) > limit {
+		found = found[:limit]
+	}
+	return found, nil
+}
+
+// lastSearch returns the most recent FindNearbyDrivers call
+func (r *fakeRepo) lastSearch() nearbySearch {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.searches[len(r.searches)-1]
+}
+
+// SetDriverCurrentRide binds the driver to the ride and marks them BUSY
+func (r *fakeRepo) SetDriverCurrentRide(ctx context.Context, driverID string, rideID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d, ok := r.drivers[driverID]
+	if !ok {
+		return fmt.Errorf("driver not found: %s", driverID)
+	}
+	d.Status = domain.DriverStatusBusy
+	d.CurrentRideID = rideID
+	r.currentRides[driverID] = &domain.CurrentRide{RideID: rideID, Status: "MATCHED", PassengerID: "passenger-" + rideID}
+	return nil
+}
+
+// GetRideFareBasis returns the basis the test set for the ride, or nil
+func (r *fakeRepo) GetRideFareBasis(ctx context.Context, rideID string) (*domain.RideFareBasis, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.fareBases[rideID], nil
+}
+
+// ClearDriverCurrentRide releases the driver back to AVAILABLE
+func (r *fakeRepo) ClearDriverCurrentRide(ctx context.Context, driverID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if d, ok := r.drivers[driverID]; ok {
+		d.Status = domain.DriverStatusAvailable
+		d.CurrentRideID = ""
+	}
+	delete(r.currentRides, driverID)
+	return nil
+}
+
+// currentRideID returns the ride the driver is bound to, "" if none
+func (r *fakeRepo) currentRideID(driverID string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.drivers[driverID].CurrentRideID
+}
+
+// GetRideAssignment finds the ride among the drivers' current rides
+func (r *fakeRepo) GetRideAssignment(ctx context.Context, rideID string) (*domain.RideAssignment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for driverID, ride := range r.currentRides {
+		if ride.RideID == rideID {
+			assignment := &domain.RideAssignment{RideID: rideID, PassengerID: ride.PassengerID, DriverID: driverID, Status: ride.Status}
+			if r.unrecorded[rideID] {
+				assignment.DriverID, assignment.Status = "", "REQUESTED"
+			}
+			if at, ok := r.arrivedAt[rideID]; ok {
+				assignment.ArrivedAt = &at
+			}
+			return assignment, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeRepo) GetCurrentLocation(ctx context.Context, driverID string) (*domain.Coordinate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	loc, ok := r.currentLocation[driverID]
+	if !ok {
+		return nil, nil
+	}
+	return &domain.Coordinate{EntityID: driverID, EntityType: "driver", Latitude: loc.Latitude, Longitude: loc.Longitude, IsCurrent: true, UpdatedAt: loc.Timestamp}, nil
+}
+
+// GetLocationHistory pages through history; the time and ride filters are the
+// database's job and are only recorded here
+func (r *fakeRepo) GetLocationHistory(ctx context.Context, driverID string, q domain.LocationHistoryQuery) ([]*domain.LocationHistory, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.historyQueries = append(r.historyQueries, q)
+	points := make([]*domain.LocationHistory, 0)
+	for i := q.Offset; i < len(r.history) && len(points) < q.Limit; i++ {
+		points = append(points, r.history[i])
+	}
+	return points, nil
+}
+
+func (r *fakeRepo) GetPassengerContact(ctx context.Context, rideID string) (*domain.PassengerContact, error) {
+	contact := domain.NewPassengerContact(rideID, "passenger-"+rideID, "Aigerim Sadykova", "+77011234567")
+	return &contact, nil
+}
+
+func (r *fakeRepo) RecordOfferSent(ctx context.Context, driverID, rideID, offerID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.offersSent[rideID] = append(r.offersSent[rideID], driverID)
+	return nil
+}
+
+func (r *fakeRepo) CountOffersSent(ctx context.Context, rideID string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.offersSent[rideID]), nil
+}
+
+// offeredTo returns the drivers offered the ride, in order
+func (r *fakeRepo) offeredTo(rideID string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.offersSent[rideID]...)
+}
+
+func (r *fakeRepo) RecordOfferResponse(ctx context.Context, driverID, rideID, offerID, response string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.offerResponses = append(r.offerResponses, offerResponse{driverID, rideID, offerID, response})
+	return nil
+}
+
+// responsesFor returns the responses recorded for offerID
+func (r *fakeRepo) responsesFor(offerID string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []string
+	for _, resp := range r.offerResponses {
+		if resp.offerID == offerID {
+			out = append(out, resp.response)
+		}
+	}
+	return out
+}
+
+func (r *fakeRepo) SavePendingOffer(ctx context.Context, offer *domain.PendingOffer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pendingOffers[offer.OfferID] = offer
+	return nil
+}
+
+func (r *fakeRepo) DeletePendingOffers(ctx context.Context, offerIDs []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, id := range offerIDs {
+		delete(r.pendingOffers, id)
+	}
+	return nil
+}
+
+func (r *fakeRepo) ListPendingOffers(ctx context.Context) ([]*domain.PendingOffer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*domain.PendingOffer
+	for _, o := range r.pendingOffers {
+		out = append(out, o)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].OfferID < out[j].OfferID })
+	return out, nil
+}
+
+func (r *fakeRepo) SaveDriverLocation(ctx context.Context, driverID string, latitude, longitude float64, address string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	loc := domain.LocationUpdate{DriverID: driverID, Latitude: latitude, Longitude: longitude, Timestamp: r.clock.Now()}
+	r.locations = append(r.locations, loc)
+	r.addresses = append(r.addresses, address)
+	r.currentLocation[driverID] = loc
+	return r.id("coord"), nil
+}
+
+func (r *fakeRepo) ArchiveLocation(ctx context.Context, driverID string, latitude, longitude, accuracy, speed, heading float64, rideID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.archived = append(r.archived, domain.LocationUpdate{DriverID: driverID, RideID: rideID, Latitude: latitude, Longitude: longitude, SpeedKmh: speed, Timestamp: r.clock.Now()})
+	return nil
+}
+
+func (r *fakeRepo) GetDriverStats(ctx context.Context, driverID string, since time.Time) (*domain.DriverStats, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statsSince = since
+	return r.stats, r.statsErr
+}
+
+func (r *fakeRepo) CountRequestedRidesByCell(ctx context.Context, box domain.BoundingBox, cellSizeDeg float64) ([]domain.HeatmapCell, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.heatmapQueries = append(r.heatmapQueries, box)
+	return r.heatmapCells, nil
+}
+
+func (r *fakeRepo) GetDriverCurrentRide(ctx context.Context, driverID string) (*domain.CurrentRide, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.currentRides[driverID], nil
+}
+
+// SaveLocationBatch archives every point and makes the newest the driver's
+// current location, as the Postgres repository does in one transaction
+func (r *fakeRepo) SaveLocationBatch(ctx context.Context, driverID string, points []domain.LocationUpdate, address string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, points)
+	r.batchAddresses = append(r.batchAddresses, address)
+	r.currentLocation[driverID] = points[len(points)-1]
+	return "coord-" + driverID, nil
+}
+
+// published is one message handed to the publisher
+type published struct {
+	exchange   string
+	routingKey string
+	body       map[string]interface{}
+}
+
+// fakePublisher records everything published
+type fakePublisher struct {
+	mu       sync.Mutex
+	messages []published
+}
+
+func (p *fakePublisher) record(exchange, routingKey string, body []byte) {
+	var m map[string]interface{}
+	_ = json.Unmarshal(body, &m)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.messages = append(p.messages, published{exchange: exchange, routingKey: routingKey, body: m})
+}
+
+func (p *fakePublisher) PublishDriverResponse(ctx context.Context, exchange, routingKey string, body []byte) error {
+	p.record(exchange, routingKey, body)
+	return nil
+}
+
+func (p *fakePublisher) PublishDriverStatus(ctx context.Context, exchange, routingKey string, body []byte) error {
+	p.record(exchange, routingKey, body)
+	return nil
+}
+
+func (p *fakePublisher) PublishLocationUpdate(ctx context.Context, exchange string, body []byte) error {
+	p.record(exchange, "", body)
+	return nil
+}
+
+// to returns the messages published to exchange, oldest first
+func (p *fakePublisher) to(exchange string) []published {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var out []published
+	for _, m := range p.messages {
+		if m.exchange == exchange {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// sent is one message handed to a driver's WebSocket
+type sent struct {
+	driverID string
+	kind     string
+	payload  interface{}
+}
+
+// fakeWS records what drivers were sent; drivers in connected have a live socket
+type fakeWS struct {
+	mu           sync.Mutex
+	connected    map[string]bool
+	sent         []sent
+	disconnected []string
+}
+
+func newFakeWS() *fakeWS {
+	return &fakeWS{connected: make(map[string]bool)}
+}
+
+func (w *fakeWS) record(driverID, kind string, payload interface{}) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.sent = append(w.sent, sent{driverID: driverID, kind: kind, payload: payload})
+	return nil
+}
+
+func (w *fakeWS) SendRideOffer(driverID string, offer interface{}) error {
+	return w.record(driverID, "ride_offer", offer)
+}
+
+func (w *fakeWS) SendRideDetails(driverID string, details interface{}) error {
+	return w.record(driverID, "ride_details", details)
+}
+
+func (w *fakeWS) SendRideCancelled(driverID string, rideID string) error {
+	return w.record(driverID, "ride_cancelled", rideID)
+}
+
+func (w *fakeWS) SendRideStatus(driverID string, update interface{}) error {
+	return w.record(driverID, "ride_status_update", update)
+}
+
+func (w *fakeWS) SendOfferCancelled(driverID, offerID, rideID, reason string) error {
+	return w.record(driverID, "offer_cancelled", map[string]string{"offer_id": offerID, "ride_id": rideID, "reason": reason})
+}
+
+func (w *fakeWS) SendRideMessage(driverID string, message interface{}) error {
+	return w.record(driverID, "ride_message", message)
+}
+
+func (w *fakeWS) SendDestinationChanged(driverID string, change interface{}) error {
+	return w.record(driverID, "destination_changed", change)
+}
+
+func (w *fakeWS) BroadcastToAll(message interface{}) error {
+	return w.record("", "broadcast", message)
+}
+
+func (w *fakeWS) IsDriverConnected(driverID string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.connected[driverID]
+}
+
+func (w *fakeWS) ForceDisconnect(driverID string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.connected, driverID)
+	w.disconnected = append(w.disconnected, driverID)
+}
+
+// sentTo returns the kinds of message sent to driverID, oldest first
+func (w *fakeWS) sentTo(driverID string) []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var kinds []string
+	for _, s := range w.sent {
+		if s.driverID == driverID {
+			kinds = append(kinds, s.kind)
+		}
+	}
+	return kinds
+}
+
+// lastSent returns the payload of the latest message of kind sent to driverID
+func (w *fakeWS) lastSent(driverID, kind string) interface{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i := len(w.sent) - 1; i >= 0; i-- {
+		if s := w.sent[i]; s.driverID == driverID && s.kind == kind {
+			return s.payload
+		}
+	}
+	return nil
+}
+
+// testService wires a DriverLocationService to fakes and a fake clock
+type testService struct {
+	*DriverLocationService
+	repo  *fakeRepo
+	pub   *fakePublisher
+	ws    *fakeWS
+	clock *clock.Fake
+}
+
+func newTestService(t *testing.T) *testService {
+	t.Helper()
+	c := clock.NewFake(testNow)
+	ts := &testService{
+		repo:  newFakeRepo(c),
+		pub:   &fakePublisher{},
+		ws:    newFakeWS(),
+		clock: c,
+	}
+	ts.DriverLocationService = NewDriverLocationService(nopLogger{}, ts.repo, ts.pub, ts.ws)
+	ts.SetClock(ts.clock)
+	// Nothing in a test acknowledges offers or waits out an expansion delay
+	// unless it sets these itself
+	ts.SetOfferAckTimeout(0)
+	ts.SetRadiusExpansion(0, 0, 0)
+	return ts
+}
+
+// onlineDriver adds an AVAILABLE, connected driver whose location was just reported
+func (ts *testService) onlineDriver(driverID string, lat, lng float64) *domain.Driver {
+	d := ts.repo.addDriver(driverID)
+	d.Status = domain.DriverStatusAvailable
+	ts.repo.mu.Lock()
+	ts.repo.currentLocation[driverID] = domain.LocationUpdate{DriverID: driverID, Latitude: lat, Longitude: lng, Timestamp: ts.clock.Now()}
+	ts.repo.mu.Unlock()
+	ts.ws.mu.Lock()
+	ts.ws.connected[driverID] = true
+	ts.ws.mu.Unlock()
+	return d
+}
+
+// rideRequest returns a matching request for an economy ride picked up at lat, lng
+func rideRequest(rideID string, lat, lng float64) *domain.RideMatchingRequest {
+	return &domain.RideMatchingRequest{
+		RideID:              rideID,
+		RideNumber:          "RIDE_20241216_" + rideID,
+		PassengerID:         "passenger-" + rideID,
+		PickupLocation:      domain.Location{Lat: lat, Lng: lng, Address: "Abay Ave 10"},
+		DestinationLocation: domain.Location{Lat: lat - 0.02, Lng: lng - 0.03, Address: "Dostyk Ave 5"},
+		RideType:            "ECONOMY",
+		EstimatedFare:       1450,
+		PassengerCount:      1,
+		MaxDistanceKM:       5,
+		TimeoutSeconds:      30,
+		CorrelationID:       "corr-" + rideID,
+	}
+}
+
+// offerID is the ID matchRide gives the driver's offer for the ride
+func offerID(rideID, driverID string) string {
+	return fmt.Sprintf("offer_%s_%s", rideID, driverID)
+}
+
+// waitFor polls cond until it holds, failing the test after a second. Offer
+// bookkeeping and re-offers run on their own goroutines.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+}
@@ -0,0 +1,108 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"ride-hail/internal/driver_location_service/domain"
+	"ride-hail/pkg/apitime"
+	"ride-hail/pkg/geo"
+	"ride-hail/pkg/logger"
+)
+
+const (
+	// Grid size used when the caller does not pick one (~1.1 km of latitude)
+	defaultHeatmapCellDeg = 0.01
+	minHeatmapCellDeg     = 0.001
+	maxHeatmapCellDeg     = 0.5
+	// Largest box side in degrees a driver may request
+	maxHeatmapSpanDeg = 2.0
+	// How long a computed heatmap is served from memory
+	heatmapCacheTTL = 30 * time.Second
+)
+
+type heatmapCacheEntry struct {
+	heatmap   *domain.Heatmap
+	expiresAt time.Time
+}
+
+// GetDemandHeatmap counts REQUESTED rides per grid cell inside the box. The box is
+// snapped outward to the grid so nearby requests share a cached result.
+func (s *DriverLocationService) GetDemandHeatmap(ctx context.Context, box domain.BoundingBox, cellSizeDeg float64) (*domain.Heatmap, error) {
+	if cellSizeDeg == 0 {
+		cellSizeDeg = defaultHeatmapCellDeg
+	}
+	if err := validateHeatmapArea(box, cellSizeDeg); err != nil {
+		return nil, err
+	}
+	box = snapToGrid(box, cellSizeDeg)
+
+	key := fmt.Sprintf("%g:%g:%g:%g:%g", box.MinLat, box.MinLng, box.MaxLat, box.MaxLng, cellSizeDeg)
+	now := s.clock.Now()
+
+	s.heatmapMu.Lock()
+	if entry, ok := s.heatmapCache[key]; ok && now.Before(entry.expiresAt) {
+		s.heatmapMu.Unlock()
+		return entry.heatmap, nil
+	}
+	s.heatmapMu.Unlock()
+
+	cells, err := s.repo.CountRequestedRidesByCell(ctx, box, cellSizeDeg)
+	if err != nil {
+		s.log.WithFields(logger.LogFields{"cell_size_deg": cellSizeDeg}).Error("heatmap_query_failed", err)
+		return nil, err
+	}
+
+	heatmap := &domain.Heatmap{
+		Bounds:      box,
+		CellSizeDeg: cellSizeDeg,
+		Cells:       cells,
+		GeneratedAt: apitime.New(now),
+	}
+	if heatmap.Cells == nil {
+		heatmap.Cells = []domain.HeatmapCell{}
+	}
+	for _, c := range cells {
+		heatmap.TotalRides += c.Count
+	}
+
+	s.heatmapMu.Lock()
+	for k, entry := range s.heatmapCache {
+		if !now.Before(entry.expiresAt) {
+			delete(s.heatmapCache, k)
+		}
+	}
+	s.heatmapCache[key] = heatmapCacheEntry{heatmap: heatmap, expiresAt: now.Add(heatmapCacheTTL)}
+	s.heatmapMu.Unlock()
+
+	return heatmap, nil
+}
+
+// validateHeatmapArea rejects boxes that are inverted, off the map or too large to aggregate
+func validateHeatmapArea(box domain.BoundingBox, cellSizeDeg float64) error {
+	if !geo.ValidCoordinates(box.MinLat, box.MinLng) || !geo.ValidCoordinates(box.MaxLat, box.MaxLng) {
+		return fmt.Errorf("%w: coordinates out of range", domain.ErrInvalidHeatmapArea)
+	}
+	if box.MinLat >= box.MaxLat || box.MinLng >= box.MaxLng {
+		return fmt.Errorf("%w: min bounds must be below max bounds", domain.ErrInvalidHeatmapArea)
+	}
+	if box.MaxLat-box.MinLat > maxHeatmapSpanDeg || box.MaxLng-box.MinLng > maxHeatmapSpanDeg {
+		return fmt.Errorf("%w: box may span at most %g degrees", domain.ErrInvalidHeatmapArea, maxHeatmapSpanDeg)
+	}
+	if !(cellSizeDeg >= minHeatmapCellDeg && cellSizeDeg <= maxHeatmapCellDeg) {
+		return fmt.Errorf("%w: cell size must be between %g and %g degrees", domain.ErrInvalidHeatmapArea, minHeatmapCellDeg, maxHeatmapCellDeg)
+	}
+	return nil
+}
+
+// snapToGrid widens the box to whole cells so edge cells are counted in full
+func snapToGrid(box domain.BoundingBox, cellSizeDeg float64) domain.BoundingBox {
+	return domain.BoundingBox{
+		MinLat: math.Max(math.Floor(box.MinLat/cellSizeDeg)*cellSizeDeg, -90),
+		MinLng: math.Max(math.Floor(box.MinLng/cellSizeDeg)*cellSizeDeg, -180),
+		MaxLat: math.Min(math.Ceil(box.MaxLat/cellSizeDeg)*cellSizeDeg, 90),
+		MaxLng: math.Min(math.Ceil(box.MaxLng/cellSizeDeg)*cellSizeDeg, 180),
+	}
+}
@@ -0,0 +1,133 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"ride-hail/internal/driver_location_service/domain"
+)
+
+// almatyBox is a box around central Almaty that is not aligned to the grid
+var almatyBox = domain.BoundingBox{MinLat: 43.2341, MinLng: 76.8712, MaxLat: 43.2759, MaxLng: 76.9288}
+
+func TestHeatmapTotalsCells(t *testing.T) {
+	s := newTestService(t)
+	s.repo.heatmapCells = []domain.HeatmapCell{
+		{Latitude: 43.245, Longitude: 76.885, Count: 4},
+		{Latitude: 43.255, Longitude: 76.905, Count: 1},
+	}
+
+	heatmap, err := s.GetDemandHeatmap(context.Background(), almatyBox, 0)
+	if err != nil {
+		t.Fatalf("GetDemandHeatmap: %v", err)
+	}
+
+	if heatmap.TotalRides != 5 || len(heatmap.Cells) != 2 {
+		t.Errorf("heatmap has %d rides in %d cells, want 5 in 2", heatmap.TotalRides, len(heatmap.Cells))
+	}
+	if heatmap.CellSizeDeg != defaultHeatmapCellDeg {
+		t.Errorf("cell size = %v, want the default %v", heatmap.CellSizeDeg, defaultHeatmapCellDeg)
+	}
+}
+
+func TestHeatmapSnapsBoxToGrid(t *testing.T) {
+	s := newTestService(t)
+
+	heatmap, err := s.GetDemandHeatmap(context.Background(), almatyBox, 0.01)
+	if err != nil {
+		t.Fatalf("GetDemandHeatmap: %v", err)
+	}
+
+	want := domain.BoundingBox{MinLat: 43.23, MinLng: 76.87, MaxLat: 43.28, MaxLng: 76.93}
+	got := s.repo.heatmapQueries[0]
+	for _, pair := range [][2]float64{{got.MinLat, want.MinLat}, {got.MinLng, want.MinLng}, {got.MaxLat, want.MaxLat}, {got.MaxLng, want.MaxLng}} {
+		if math.Abs(pair[0]-pair[1]) > 1e-9 {
+			t.Fatalf("queried box %+v, want it widened to whole cells %+v", got, want)
+		}
+	}
+	if heatmap.Bounds != got {
+		t.Errorf("bounds = %+v, want the queried box", heatmap.Bounds)
+	}
+	if heatmap.Cells == nil {
+		t.Error("cells = nil, want an empty list for an area without demand")
+	}
+}
+
+func TestHeatmapIsCached(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := s.GetDemandHeatmap(ctx, almatyBox, 0.01); err != nil {
+			t.Fatalf("GetDemandHeatmap: %v", err)
+		}
+	}
+	// A box snapping to the same cells shares the entry
+	nearby := almatyBox
+	nearby.MinLat += 0.001
+	if _, err := s.GetDemandHeatmap(ctx, nearby, 0.01); err != nil {
+		t.Fatalf("GetDemandHeatmap: %v", err)
+	}
+	if n := len(s.repo.heatmapQueries); n != 1 {
+		t.Fatalf("queried %d times, want the cached heatmap reused", n)
+	}
+
+	s.clock.Advance(heatmapCacheTTL)
+	if _, err := s.GetDemandHeatmap(ctx, almatyBox, 0.01); err != nil {
+		t.Fatalf("GetDemandHeatmap: %v", err)
+	}
+	if n := len(s.repo.heatmapQueries); n != 2 {
+		t.Errorf("queried %d times, want a fresh query once the cache expired", n)
+	}
+
+	// Another grid size is computed separately
+	if _, err := s.GetDemandHeatmap(ctx, almatyBox, 0.02); err != nil {
+		t.Fatalf("GetDemandHeatmap: %v", err)
+	}
+	if n := len(s.repo.heatmapQueries); n != 3 {
+		t.Errorf("queried %d times, want a query for the new cell size", n)
+	}
+}
+
+func TestHeatmapRejectsInvalidAreas(t *testing.T) {
+	tests := []struct {
+		name string
+		box  domain.BoundingBox
+		cell float64
+	}{
+		{"inverted", domain.BoundingBox{MinLat: 43.3, MinLng: 76.8, MaxLat: 43.2, MaxLng: 76.9}, 0.01},
+		{"off the map", domain.BoundingBox{MinLat: 89.5, MinLng: 76.8, MaxLat: 90.5, MaxLng: 76.9}, 0.01},
+		{"too large", domain.BoundingBox{MinLat: 42, MinLng: 76, MaxLat: 44.5, MaxLng: 77}, 0.01},
+		{"cell too small", almatyBox, 0.0001},
+		{"cell too large", almatyBox, 1},
+		{"negative cell", almatyBox, -0.01},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t)
+			_, err := s.GetDemandHeatmap(context.Background(), tt.box, tt.cell)
+			if !errors.Is(err, domain.ErrInvalidHeatmapArea) {
+				t.Errorf("err = %v, want ErrInvalidHeatmapArea", err)
+			}
+			if len(s.repo.heatmapQueries) != 0 {
+				t.Error("an invalid area reached the repository")
+			}
+		})
+	}
+}
+
+func TestHeatmapGeneratedAtUsesClock(t *testing.T) {
+	s := newTestService(t)
+	s.clock.Advance(time.Minute)
+
+	heatmap, err := s.GetDemandHeatmap(context.Background(), almatyBox, 0)
+	if err != nil {
+		t.Fatalf("GetDemandHeatmap: %v", err)
+	}
+	if !heatmap.GeneratedAt.Time.Equal(testNow.Add(time.Minute)) {
+		t.Errorf("generated at %v, want the service clock", heatmap.GeneratedAt.Time)
+	}
+}
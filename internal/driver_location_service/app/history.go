@@ -0,0 +1,50 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"ride-hail/internal/driver_location_service/domain"
+	"ride-hail/pkg/logger"
+)
+
+const (
+	defaultHistoryPageSize = 100
+	maxHistoryPageSize     = 1000
+)
+
+// GetLocationHistory returns one page of the driver's archived locations, oldest
+// first. One extra row is fetched to tell whether another page follows.
+func (s *DriverLocationService) GetLocationHistory(ctx context.Context, driverID string, q domain.LocationHistoryQuery) (*domain.LocationHistoryPage, error) {
+	if q.Limit == 0 {
+		q.Limit = defaultHistoryPageSize
+	}
+	if q.Limit < 0 || q.Limit > maxHistoryPageSize {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", domain.ErrInvalidHistoryQuery, maxHistoryPageSize)
+	}
+	if q.Offset < 0 {
+		return nil, fmt.Errorf("%w: offset must not be negative", domain.ErrInvalidHistoryQuery)
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		return nil, fmt.Errorf("%w: from must be before to", domain.ErrInvalidHistoryQuery)
+	}
+
+	limit := q.Limit
+	q.Limit++
+	points, err := s.repo.GetLocationHistory(ctx, driverID, q)
+	if err != nil {
+		s.log.WithFields(logger.LogFields{"driver_id": driverID}).Error("location_history_query_failed", err)
+		return nil, err
+	}
+
+	page := &domain.LocationHistoryPage{
+		Points: points,
+		Limit:  limit,
+		Offset: q.Offset,
+	}
+	if len(points) > limit {
+		page.Points = points[:limit]
+		page.HasMore = true
+	}
+	return page, nil
+}
@@ -0,0 +1,108 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"ride-hail/internal/driver_location_service/domain"
+)
+
+// seedHistory gives the fake repo n archived points with IDs p0, p1, ...
+func seedHistory(s *testService, n int) {
+	s.repo.history = nil
+	for i := 0; i < n; i++ {
+		s.repo.history = append(s.repo.history, &domain.LocationHistory{ID: fmt.Sprintf("p%d", i), DriverID: "d1"})
+	}
+}
+
+func pointIDs(points []*domain.LocationHistory) []string {
+	ids := make([]string, len(points))
+	for i, p := range points {
+		ids[i] = p.ID
+	}
+	return ids
+}
+
+func TestLocationHistoryPages(t *testing.T) {
+	s := newTestService(t)
+	seedHistory(s, 5)
+
+	tests := []struct {
+		offset  int
+		want    []string
+		hasMore bool
+	}{
+		{0, []string{"p0", "p1"}, true},
+		{2, []string{"p2", "p3"}, true},
+		{4, []string{"p4"}, false},
+		{6, []string{}, false},
+	}
+	for _, tt := range tests {
+		page, err := s.GetLocationHistory(context.Background(), "d1", domain.LocationHistoryQuery{Limit: 2, Offset: tt.offset})
+		if err != nil {
+			t.Fatalf("offset %d: %v", tt.offset, err)
+		}
+		if fmt.Sprint(pointIDs(page.Points)) != fmt.Sprint(tt.want) || page.HasMore != tt.hasMore {
+			t.Errorf("offset %d: points %v has_more %v, want %v %v", tt.offset, pointIDs(page.Points), page.HasMore, tt.want, tt.hasMore)
+		}
+		if page.Limit != 2 || page.Offset != tt.offset {
+			t.Errorf("offset %d: page reports limit %d offset %d", tt.offset, page.Limit, page.Offset)
+		}
+	}
+}
+
+func TestLocationHistoryExactlyOnePageHasNoMore(t *testing.T) {
+	s := newTestService(t)
+	seedHistory(s, 3)
+
+	page, err := s.GetLocationHistory(context.Background(), "d1", domain.LocationHistoryQuery{Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Points) != 3 || page.HasMore {
+		t.Errorf("points %v has_more %v, want all three and no more", pointIDs(page.Points), page.HasMore)
+	}
+}
+
+func TestLocationHistoryPassesFiltersToTheRepo(t *testing.T) {
+	s := newTestService(t)
+	from := time.Date(2024, 12, 16, 10, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+
+	if _, err := s.GetLocationHistory(context.Background(), "d1", domain.LocationHistoryQuery{RideID: "A", From: from, To: to, Offset: 20}); err != nil {
+		t.Fatal(err)
+	}
+	want := domain.LocationHistoryQuery{RideID: "A", From: from, To: to, Limit: defaultHistoryPageSize + 1, Offset: 20}
+	if len(s.repo.historyQueries) != 1 || s.repo.historyQueries[0] != want {
+		t.Errorf("repo queries = %+v, want [%+v]", s.repo.historyQueries, want)
+	}
+}
+
+func TestLocationHistoryRejectsBadQueries(t *testing.T) {
+	from := time.Date(2024, 12, 16, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		q    domain.LocationHistoryQuery
+	}{
+		{"negative limit", domain.LocationHistoryQuery{Limit: -1}},
+		{"limit over the cap", domain.LocationHistoryQuery{Limit: maxHistoryPageSize + 1}},
+		{"negative offset", domain.LocationHistoryQuery{Offset: -1}},
+		{"empty window", domain.LocationHistoryQuery{From: from, To: from}},
+		{"window backwards", domain.LocationHistoryQuery{From: from, To: from.Add(-time.Minute)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t)
+			_, err := s.GetLocationHistory(context.Background(), "d1", tt.q)
+			if !errors.Is(err, domain.ErrInvalidHistoryQuery) {
+				t.Fatalf("err = %v, want ErrInvalidHistoryQuery", err)
+			}
+			if len(s.repo.historyQueries) != 0 {
+				t.Error("queried the repo for an invalid query")
+			}
+		})
+	}
+}
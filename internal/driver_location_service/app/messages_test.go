@@ -0,0 +1,56 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"ride-hail/internal/driver_location_service/domain"
+)
+
+func passengerMessage(recipient string) *domain.RideMessage {
+	return &domain.RideMessage{
+		MessageID:   "m1",
+		RideID:      "A",
+		SenderID:    "passenger-A",
+		SenderRole:  "PASSENGER",
+		RecipientID: recipient,
+		Text:        "I'm by the blue gate",
+		SentAt:      testNow,
+	}
+}
+
+func TestRideMessageReachesTheConnectedDriver(t *testing.T) {
+	s := newTestService(t)
+	s.onlineDriver("d1", 43.2389, 76.8897)
+	s.onlineDriver("d2", 43.2389, 76.8897)
+	msg := passengerMessage("d1")
+
+	if err := s.HandleRideMessage(context.Background(), msg); err != nil {
+		t.Fatalf("HandleRideMessage: %v", err)
+	}
+	if got, ok := s.ws.lastSent("d1", "ride_message").(*domain.RideMessage); !ok || got.Text != msg.Text || got.SenderID != "passenger-A" {
+		t.Errorf("d1 got %v, want the passenger's message", s.ws.lastSent("d1", "ride_message"))
+	}
+	if sent := s.ws.sentTo("d2"); len(sent) != 0 {
+		t.Errorf("d2 was sent %v, want nothing", sent)
+	}
+}
+
+func TestRideMessageForADriverOnAnotherInstanceIsSkipped(t *testing.T) {
+	s := newTestService(t)
+
+	if err := s.HandleRideMessage(context.Background(), passengerMessage("d1")); err != nil {
+		t.Fatalf("HandleRideMessage: %v", err)
+	}
+	if sent := s.ws.sentTo("d1"); len(sent) != 0 {
+		t.Errorf("sent %v to a driver not connected here", sent)
+	}
+}
+
+func TestRideMessageWithoutRecipientIsRejected(t *testing.T) {
+	s := newTestService(t)
+
+	if err := s.HandleRideMessage(context.Background(), passengerMessage("")); err == nil {
+		t.Error("HandleRideMessage accepted a message with no recipient")
+	}
+}
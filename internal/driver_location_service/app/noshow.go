@@ -0,0 +1,148 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"ride-hail/internal/driver_location_service/domain"
+	"ride-hail/pkg/apitime"
+	"ride-hail/pkg/logger"
+)
+
+// Defaults for the no-show policy when none is configured
+const (
+	defaultNoShowWait = 5 * time.Minute
+	defaultNoShowFee  = 100.0
+)
+
+// SetNoShowPolicy sets how long a driver must wait at the pickup before reporting
+// a no-show, and the fee charged to the passenger for it
+func (s *DriverLocationService) SetNoShowPolicy(wait time.Duration, fee float64) {
+	s.noShowWait = wait
+	s.noShowFee = fee
+}
+
+// DriverArrived records that the driver is waiting at the pickup. The ride service
+// moves the ride to ARRIVED, which stamps arrived_at and starts the no-show wait,
+// and tells the passenger. Repeating it once ARRIVED is a no-op.
+func (s *DriverLocationService) DriverArrived(ctx context.Context, driverID, rideID string) error {
+	log := s.log.WithFields(logger.LogFields{"driver_id": driverID, "ride_id": rideID})
+
+	lock := s.driverLock(driverID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	ride, err := s.repo.GetRideAssignment(ctx, rideID)
+	if err != nil {
+		log.Error("get_ride_failed", err)
+		return fmt.Errorf("failed to get ride: %w", err)
+	}
+	bound, err := s.boundToRide(ctx, driverID, ride)
+	if err != nil {
+		log.Error("get_driver_failed", err)
+		return fmt.Errorf("failed to get driver: %w", err)
+	}
+	if !bound {
+		return domain.ErrRideNotAssigned
+	}
+	switch ride.Status {
+	case "REQUESTED", "MATCHED", "EN_ROUTE":
+	case "ARRIVED":
+		return nil
+	default:
+		return domain.ErrRideNotPickingUp
+	}
+
+	statusUpdate := map[string]interface{}{
+		"driver_id":    driverID,
+		"ride_id":      rideID,
+		"passenger_id": ride.PassengerID,
+		"status":       "ARRIVED",
+		"old_status":   ride.Status,
+		"new_status":   "ARRIVED",
+		"timestamp":    apitime.Format(s.clock.Now()),
+	}
+	if location, err := s.repo.GetCurrentLocation(ctx, driverID); err == nil && location != nil {
+		statusUpdate["latitude"] = location.Latitude
+		statusUpdate["longitude"] = location.Longitude
+	}
+	statusData, _ := json.Marshal(statusUpdate)
+	if err := s.publisher.PublishDriverStatus(ctx, "driver_topic", fmt.Sprintf("driver.status.%s", driverID), statusData); err != nil {
+		log.Error("publish_driver_status_failed", err)
+		return fmt.Errorf("failed to publish status: %w", err)
+	}
+
+	log.Info("driver_arrived", "Driver arrived at the pickup")
+	return nil
+}
+
+// ReportPassengerNoShow cancels a ride whose passenger never came to the pickup.
+// The driver must have reported arriving (DriverArrived) at least the no-show wait
+// ago; the passenger
+// is charged the no-show fee, the driver keeps their share of it and is freed.
+func (s *DriverLocationService) ReportPassengerNoShow(ctx context.Context, driverID, rideID string) (*domain.NoShow, error) {
+	log := s.log.WithFields(logger.LogFields{"driver_id": driverID, "ride_id": rideID})
+
+	lock := s.driverLock(driverID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	ride, err := s.repo.GetRideAssignment(ctx, rideID)
+	if err != nil {
+		log.Error("get_ride_failed", err)
+		return nil, fmt.Errorf("failed to get ride: %w", err)
+	}
+	if ride == nil || ride.DriverID != driverID {
+		return nil, domain.ErrRideNotAssigned
+	}
+	if ride.Status != "ARRIVED" || ride.ArrivedAt == nil {
+		return nil, domain.ErrRideNotArrived
+	}
+	now := s.clock.Now()
+	if waited := now.Sub(*ride.ArrivedAt); waited < s.noShowWait {
+		return nil, fmt.Errorf("%w: wait %.0f more seconds", domain.ErrNoShowTooEarly,
+			math.Ceil((s.noShowWait - waited).Seconds()))
+	}
+
+	noShow := &domain.NoShow{
+		RideID:      rideID,
+		Fee:         s.noShowFee,
+		Earnings:    s.CalculateDriverEarnings(s.noShowFee),
+		CancelledAt: now,
+	}
+
+	if err := s.repo.ClearDriverCurrentRide(ctx, driverID); err != nil {
+		log.Error("clear_ride_failed", err)
+		return nil, fmt.Errorf("failed to clear ride: %w", err)
+	}
+	if noShow.Earnings > 0 {
+		if err := s.repo.UpdateDriverSessionStats(ctx, driverID, 0, noShow.Earnings); err != nil {
+			log.Error("update_stats_failed", err)
+		}
+	}
+
+	// The ride service cancels the ride, charges the fee and notifies the passenger
+	statusUpdate := map[string]interface{}{
+		"driver_id":    driverID,
+		"ride_id":      rideID,
+		"passenger_id": ride.PassengerID,
+		"status":       "CANCELLED",
+		"old_status":   ride.Status,
+		"new_status":   "CANCELLED",
+		"reason":       domain.ReasonPassengerNoShow,
+		"cancelled_by": domain.CancelledByDriver,
+		"no_show_fee":  noShow.Fee,
+		"timestamp":    apitime.Format(now),
+	}
+	statusData, _ := json.Marshal(statusUpdate)
+	if err := s.publisher.PublishDriverStatus(ctx, "driver_topic", fmt.Sprintf("driver.status.%s", driverID), statusData); err != nil {
+		log.Error("publish_driver_status_failed", err)
+		return nil, fmt.Errorf("failed to publish no-show: %w", err)
+	}
+
+	log.WithFields(logger.LogFields{"fee": noShow.Fee}).Info("ride_passenger_no_show", "Ride cancelled for passenger no-show")
+	return noShow, nil
+}
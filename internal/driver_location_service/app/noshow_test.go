@@ -0,0 +1,104 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ride-hail/internal/driver_location_service/domain"
+)
+
+// arrivedDriver binds d1 to ride A and has them arrive at the pickup now
+func arrivedDriver(t *testing.T, s *testService) {
+	t.Helper()
+	ctx := context.Background()
+	s.onlineDriver("d1", 43.2389, 76.8897)
+	if _, err := s.repo.CreateDriverSession(ctx, "d1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.repo.SetDriverCurrentRide(ctx, "d1", "A"); err != nil {
+		t.Fatal(err)
+	}
+	s.repo.mu.Lock()
+	s.repo.currentRides["d1"].Status = "ARRIVED"
+	s.repo.arrivedAt["A"] = s.clock.Now()
+	s.repo.mu.Unlock()
+}
+
+func TestNoShowBeforeWaitIsRejected(t *testing.T) {
+	s := newTestService(t)
+	s.SetNoShowPolicy(5*time.Minute, 100)
+	arrivedDriver(t, s)
+	s.clock.Advance(4 * time.Minute)
+
+	_, err := s.ReportPassengerNoShow(context.Background(), "d1", "A")
+	if !errors.Is(err, domain.ErrNoShowTooEarly) {
+		t.Fatalf("err = %v, want ErrNoShowTooEarly", err)
+	}
+	if got := s.repo.currentRideID("d1"); got != "A" {
+		t.Errorf("driver's ride = %q, want still A", got)
+	}
+	if msgs := s.pub.to("driver_topic"); len(msgs) != 0 {
+		t.Errorf("published %v, want nothing", msgs)
+	}
+}
+
+func TestNoShowAfterWaitCancelsRideAndFreesDriver(t *testing.T) {
+	s := newTestService(t)
+	s.SetNoShowPolicy(5*time.Minute, 100)
+	arrivedDriver(t, s)
+	s.clock.Advance(5 * time.Minute)
+
+	noShow, err := s.ReportPassengerNoShow(context.Background(), "d1", "A")
+	if err != nil {
+		t.Fatalf("ReportPassengerNoShow: %v", err)
+	}
+	if noShow.Fee != 100 || noShow.RideID != "A" {
+		t.Errorf("no-show = %+v, want ride A with fee 100", noShow)
+	}
+	if got := s.repo.status("d1"); got != domain.DriverStatusAvailable {
+		t.Errorf("driver status = %s, want AVAILABLE", got)
+	}
+	if got := s.repo.currentRideID("d1"); got != "" {
+		t.Errorf("driver's ride = %q, want none", got)
+	}
+
+	msgs := s.pub.to("driver_topic")
+	if len(msgs) != 1 {
+		t.Fatalf("published %d driver status messages, want 1", len(msgs))
+	}
+	body := msgs[0].body
+	if msgs[0].routingKey != "driver.status.d1" || body["new_status"] != "CANCELLED" ||
+		body["reason"] != domain.ReasonPassengerNoShow || body["no_show_fee"] != 100.0 {
+		t.Errorf("published %s %v, want a CANCELLED no-show with fee 100", msgs[0].routingKey, body)
+	}
+}
+
+func TestNoShowBeforeArrivingIsRejected(t *testing.T) {
+	s := newTestService(t)
+	s.SetNoShowPolicy(0, 100)
+	arrivedDriver(t, s)
+	s.repo.mu.Lock()
+	s.repo.currentRides["d1"].Status = "EN_ROUTE"
+	delete(s.repo.arrivedAt, "A")
+	s.repo.mu.Unlock()
+
+	if _, err := s.ReportPassengerNoShow(context.Background(), "d1", "A"); !errors.Is(err, domain.ErrRideNotArrived) {
+		t.Errorf("err = %v, want ErrRideNotArrived", err)
+	}
+}
+
+func TestNoShowForAnotherDriversRideIsRejected(t *testing.T) {
+	s := newTestService(t)
+	s.SetNoShowPolicy(0, 100)
+	arrivedDriver(t, s)
+	s.onlineDriver("d2", 43.2389, 76.8897)
+
+	if _, err := s.ReportPassengerNoShow(context.Background(), "d2", "A"); !errors.Is(err, domain.ErrRideNotAssigned) {
+		t.Errorf("err = %v, want ErrRideNotAssigned", err)
+	}
+	if got := s.repo.currentRideID("d1"); got != "A" {
+		t.Errorf("d1's ride = %q, want still A", got)
+	}
+}
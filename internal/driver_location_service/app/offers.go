@@ -0,0 +1,482 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"ride-hail/internal/driver_location_service/domain"
+	"ride-hail/pkg/logger"
+)
+
+const (
+	// Upper bound on outstanding offers; new offers are refused beyond it
+	maxPendingOffers = 10000
+	// How often expired offers are swept in case their timeout goroutine never ran
+	offerSweepInterval = 30 * time.Second
+	// Look-back window for driver acceptance and completion rates
+	driverStatsWindow = 30 * 24 * time.Hour
+	// How long a driver's client has to acknowledge an offer by default
+	defaultOfferAckTimeout = 3 * time.Second
+	// Offers a ride may receive across all matching attempts by default
+	defaultMaxOffersPerRide = 30
+	// How long a cancelled ride keeps refusing new offers, covering a matching pass still in flight
+	voidedRideTTL = 10 * time.Minute
+	// How long an accepted ride keeps refusing new offers unless it is matched again
+	takenRideTTL = 10 * time.Minute
+	// How long an expired or withdrawn offer is remembered, so a late answer is
+	// told the offer is gone rather than that it never existed
+	closedOfferTTL = 10 * time.Minute
+	// How long a ride remembers the drivers who declined it or let its offer expire
+	declinedDriversTTL = 30 * time.Minute
+)
+
+// Reasons sent with offer_cancelled
+const (
+	offerCancelledReason = "Ride was cancelled, this offer can no longer be accepted"
+	offerTakenReason     = "Another driver accepted this ride"
+)
+
+// closedOffer is an offer that expired or was withdrawn before the driver answered
+type closedOffer struct {
+	driverID string
+	closedAt time.Time
+}
+
+// closeOfferLocked remembers an offer taken off the map without an answer.
+// The caller must hold offerMu.
+func (s *DriverLocationService) closeOfferLocked(offer *RideOffer, now time.Time) {
+	s.closedOffers[offer.OfferID] = closedOffer{driverID: offer.DriverID, closedAt: now}
+}
+
+// declinedDrivers are the drivers a ride is no longer offered to
+type declinedDrivers struct {
+	driverIDs map[string]struct{}
+	updatedAt time.Time
+}
+
+// declineLocked excludes the offer's driver from later offers for its ride.
+// The caller must hold offerMu.
+func (s *DriverLocationService) declineLocked(offer *RideOffer, now time.Time) {
+	declined, ok := s.declinedBy[offer.RideID]
+	if !ok {
+		declined = &declinedDrivers{driverIDs: make(map[string]struct{})}
+		s.declinedBy[offer.RideID] = declined
+	}
+	declined.driverIDs[offer.DriverID] = struct{}{}
+	declined.updatedAt = now
+}
+
+// declinedDriverIDs lists the drivers who declined the ride or let an offer for it expire
+func (s *DriverLocationService) declinedDriverIDs(rideID string) []string {
+	s.offerMu.RLock()
+	defer s.offerMu.RUnlock()
+
+	declined, ok := s.declinedBy[rideID]
+	if !ok {
+		return nil
+	}
+	ids := make([]string, 0, len(declined.driverIDs))
+	for id := range declined.driverIDs {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// forgetDeclines drops a ride's exclusions once it no longer needs a driver
+func (s *DriverLocationService) forgetDeclines(rideID string) {
+	s.offerMu.Lock()
+	defer s.offerMu.Unlock()
+	delete(s.declinedBy, rideID)
+}
+
+var (
+	errOffersFull = errors.New("pending offers at capacity")
+	errRideVoided = errors.New("ride was cancelled")
+	errRideTaken  = errors.New("ride was accepted")
+)
+
+// SetMaxOffersPerRide caps how many offers a ride receives across every matching
+// attempt, including re-offers and re-matches; 0 removes the cap
+func (s *DriverLocationService) SetMaxOffersPerRide(max int) {
+	s.maxOffersPerRide = max
+}
+
+// remainingOffers returns how many more offers the ride may receive, or -1 when
+// uncapped. ok is false once the cap has been reached. If the count can't be
+// read, matching goes ahead as if no offers had been sent.
+func (s *DriverLocationService) remainingOffers(ctx context.Context, rideID string) (remaining int, ok bool) {
+	if s.maxOffersPerRide <= 0 {
+		return -1, true
+	}
+	sent, err := s.repo.CountOffersSent(ctx, rideID)
+	if err != nil {
+		s.log.WithFields(logger.LogFields{"ride_id": rideID}).Error("count_offers_sent_failed", err)
+		return s.maxOffersPerRide, true
+	}
+	remaining = s.maxOffersPerRide - sent
+	return remaining, remaining > 0
+}
+
+// SetOfferAckTimeout sets how long matching waits for a driver's offer_ack before
+// moving on to the next driver; 0 counts an offer as delivered once it is sent
+func (s *DriverLocationService) SetOfferAckTimeout(timeout time.Duration) {
+	s.offerAckTimeout = timeout
+}
+
+// storeOffer records a pending offer, sweeping expired offers first when the map is full.
+// It returns errOffersFull if the map is still at capacity, or errRideVoided if the
+// ride was cancelled while matching was under way.
+func (s *DriverLocationService) storeOffer(offer *RideOffer) error {
+	s.offerMu.Lock()
+	defer s.offerMu.Unlock()
+
+	if _, voided := s.voidedRides[offer.RideID]; voided {
+		return errRideVoided
+	}
+	if _, taken := s.takenRides[offer.RideID]; taken {
+		return errRideTaken
+	}
+	if len(s.pendingOffers) >= maxPendingOffers {
+		s.sweepExpiredOffersLocked(s.clock.Now())
+		if len(s.pendingOffers) >= maxPendingOffers {
+			s.offersRejected++
+			return errOffersFull
+		}
+	}
+	if offer.ack == nil {
+		offer.ack = make(chan struct{})
+	}
+	s.pendingOffers[offer.OfferID] = offer
+	return nil
+}
+
+// AcknowledgeOffer records that the driver's client received an offer
+func (s *DriverLocationService) AcknowledgeOffer(driverID, offerID string) error {
+	s.offerMu.Lock()
+	defer s.offerMu.Unlock()
+
+	offer, exists := s.pendingOffers[offerID]
+	if !exists || offer.Cancelled || offer.DriverID != driverID {
+		return domain.ErrOfferNotFound
+	}
+	s.ackLocked(offer)
+	return nil
+}
+
+// ackLocked marks the offer as delivered, releasing a matching pass waiting in
+// awaitOfferAck. The caller must hold offerMu.
+func (s *DriverLocationService) ackLocked(offer *RideOffer) {
+	if !offer.acked && offer.ack != nil {
+		offer.acked = true
+		close(offer.ack)
+	}
+}
+
+// takeRideLocked claims the ride for an accepting driver and reports whether it
+// was still free. Matching stops offering a taken ride. The caller must hold offerMu.
+func (s *DriverLocationService) takeRideLocked(rideID string, now time.Time) bool {
+	if _, taken := s.takenRides[rideID]; taken {
+		return false
+	}
+	s.takenRides[rideID] = now
+	return true
+}
+
+// releaseRide undoes takeRideLocked when the acceptance didn't go through, or
+// when the ride needs a driver again
+func (s *DriverLocationService) releaseRide(rideID string) {
+	s.offerMu.Lock()
+	defer s.offerMu.Unlock()
+	delete(s.takenRides, rideID)
+}
+
+// rideTaken reports whether a driver has accepted the ride
+func (s *DriverLocationService) rideTaken(rideID string) bool {
+	s.offerMu.RLock()
+	defer s.offerMu.RUnlock()
+	_, taken := s.takenRides[rideID]
+	return taken
+}
+
+// ListDriverOffers returns the driver's offers that can still be answered, soonest
+// to expire first. Listing counts as delivery, so the offers are acknowledged.
+func (s *DriverLocationService) ListDriverOffers(driverID string) []*domain.DriverOffer {
+	s.offerMu.Lock()
+	defer s.offerMu.Unlock()
+
+	now := s.clock.Now()
+	offers := []*domain.DriverOffer{}
+	for _, offer := range s.pendingOffers {
+		if offer.DriverID != driverID || offer.Cancelled || !now.Before(offer.ExpiresAt) {
+			continue
+		}
+		s.ackLocked(offer)
+		req := offer.RideRequest
+		offers = append(offers, &domain.DriverOffer{
+			OfferID:             offer.OfferID,
+			RideID:              offer.RideID,
+			RideNumber:          req.RideNumber,
+			PickupLocation:      req.PickupLocation,
+			DestinationLocation: req.DestinationLocation,
+			EstimatedFare:       req.EstimatedFare,
+			DriverEarnings:      s.CalculateDriverEarnings(req.EstimatedFare),
+			ExpiresAt:           offer.ExpiresAt,
+		})
+	}
+	sort.Slice(offers, func(i, j int) bool {
+		return offers[i].ExpiresAt.Before(offers[j].ExpiresAt)
+	})
+	return offers
+}
+
+// awaitOfferAck waits up to offerAckTimeout for the driver to acknowledge the
+// offer and reports whether they did
+func (s *DriverLocationService) awaitOfferAck(ctx context.Context, offer *RideOffer) bool {
+	if s.offerAckTimeout <= 0 {
+		return true
+	}
+	select {
+	case <-offer.ack:
+		return true
+	case <-s.clock.After(s.offerAckTimeout):
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// withdrawOffer removes an offer that never reached the driver
+func (s *DriverLocationService) withdrawOffer(offerID string) {
+	s.offerMu.Lock()
+	defer s.offerMu.Unlock()
+
+	if offer, exists := s.pendingOffers[offerID]; exists {
+		offer.Cancelled = true
+		delete(s.pendingOffers, offerID)
+		s.closeOfferLocked(offer, s.clock.Now())
+		go s.forgetOffers(offerID)
+	}
+}
+
+// sweepExpiredOffers removes offers past their expiry and returns how many were removed
+func (s *DriverLocationService) sweepExpiredOffers(now time.Time) int {
+	s.offerMu.Lock()
+	defer s.offerMu.Unlock()
+	return s.sweepExpiredOffersLocked(now)
+}
+
+// sweepExpiredOffersLocked is sweepExpiredOffers for callers already holding offerMu.
+// Swept offers are marked cancelled so a late handleOfferTimeout sees them as handled.
+func (s *DriverLocationService) sweepExpiredOffersLocked(now time.Time) int {
+	var removed []string
+	expiredRides := make(map[string]*domain.RideMatchingRequest)
+	for id, offer := range s.pendingOffers {
+		if now.After(offer.ExpiresAt) {
+			offer.Cancelled = true
+			delete(s.pendingOffers, id)
+			s.closeOfferLocked(offer, now)
+			s.declineLocked(offer, now)
+			removed = append(removed, id)
+			expiredRides[offer.RideID] = offer.RideRequest
+			go s.recordOfferResponse(offer, domain.OfferResponseExpired)
+		}
+	}
+	if len(removed) > 0 {
+		go s.forgetOffers(removed...)
+	}
+	for rideID, req := range expiredRides {
+		if s.reofferNeededLocked(rideID) {
+			go s.reofferRide(req)
+		}
+	}
+	s.offersSwept += uint64(len(removed))
+
+	for rideID, voidedAt := range s.voidedRides {
+		if now.Sub(voidedAt) > voidedRideTTL {
+			delete(s.voidedRides, rideID)
+		}
+	}
+	for rideID, takenAt := range s.takenRides {
+		if now.Sub(takenAt) > takenRideTTL {
+			delete(s.takenRides, rideID)
+		}
+	}
+	for offerID, closed := range s.closedOffers {
+		if now.Sub(closed.closedAt) > closedOfferTTL {
+			delete(s.closedOffers, offerID)
+		}
+	}
+	for rideID, declined := range s.declinedBy {
+		if now.Sub(declined.updatedAt) > declinedDriversTTL {
+			delete(s.declinedBy, rideID)
+		}
+	}
+	return len(removed)
+}
+
+// cancelOffersForRide withdraws every pending offer for a cancelled ride and
+// returns them. The ride is remembered for voidedRideTTL so a matching pass
+// still in flight can't store new offers for it.
+func (s *DriverLocationService) cancelOffersForRide(rideID string) []*RideOffer {
+	s.offerMu.Lock()
+	defer s.offerMu.Unlock()
+
+	now := s.clock.Now()
+	s.voidedRides[rideID] = now
+	delete(s.declinedBy, rideID)
+	return s.withdrawRideOffersLocked(rideID, now)
+}
+
+// withdrawRideOffersLocked removes every pending offer for the ride and returns
+// them. The caller must hold offerMu.
+func (s *DriverLocationService) withdrawRideOffersLocked(rideID string, now time.Time) []*RideOffer {
+	var removed []*RideOffer
+	var ids []string
+	for id, offer := range s.pendingOffers {
+		if offer.RideID == rideID {
+			offer.Cancelled = true
+			delete(s.pendingOffers, id)
+			s.closeOfferLocked(offer, now)
+			removed = append(removed, offer)
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) > 0 {
+		go s.forgetOffers(ids...)
+	}
+	return removed
+}
+
+// reofferNeededLocked reports whether a ride whose offer just fell through should
+// go back to matching: no other offer for it is pending, and it was neither
+// accepted nor cancelled. Deciding under the same lock that removed the offer
+// means only the last of several offers to fall through re-offers the ride.
+// The caller must hold offerMu.
+func (s *DriverLocationService) reofferNeededLocked(rideID string) bool {
+	if _, taken := s.takenRides[rideID]; taken {
+		return false
+	}
+	if _, voided := s.voidedRides[rideID]; voided {
+		return false
+	}
+	for _, o := range s.pendingOffers {
+		if o.RideID == rideID && !o.Cancelled {
+			return false
+		}
+	}
+	return true
+}
+
+// persistOffer saves a stored offer so it survives a restart. Failures are only
+// logged; the offer still works in memory.
+func (s *DriverLocationService) persistOffer(ctx context.Context, offer *RideOffer) {
+	err := s.repo.SavePendingOffer(ctx, &domain.PendingOffer{
+		OfferID:   offer.OfferID,
+		RideID:    offer.RideID,
+		DriverID:  offer.DriverID,
+		Request:   offer.RideRequest,
+		ExpiresAt: offer.ExpiresAt,
+	})
+	if err != nil {
+		s.log.WithFields(logger.LogFields{
+			"driver_id": offer.DriverID,
+			"offer_id":  offer.OfferID,
+		}).Error("persist_offer_failed", err)
+	}
+}
+
+// forgetOffers deletes persisted offers that are no longer pending; failures are only logged
+func (s *DriverLocationService) forgetOffers(offerIDs ...string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := s.repo.DeletePendingOffers(ctx, offerIDs); err != nil {
+		s.log.WithFields(logger.LogFields{
+			"offers": len(offerIDs),
+		}).Error("forget_offers_failed", err)
+	}
+}
+
+// RestorePendingOffers reloads offers persisted before a restart and re-arms their
+// timeouts. Offers that expired while the service was down are recorded as expired
+// and dropped. Call it before consuming ride status updates so a cancellation
+// queued during the downtime withdraws the restored offers.
+func (s *DriverLocationService) RestorePendingOffers(ctx context.Context) (int, error) {
+	stored, err := s.repo.ListPendingOffers(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	now := s.clock.Now()
+	restored := 0
+	var dropped []string
+	for _, p := range stored {
+		offer := &RideOffer{
+			OfferID:     p.OfferID,
+			RideID:      p.RideID,
+			DriverID:    p.DriverID,
+			RideRequest: p.Request,
+			ExpiresAt:   p.ExpiresAt,
+		}
+		if !now.Before(offer.ExpiresAt) {
+			dropped = append(dropped, offer.OfferID)
+			go s.recordOfferResponse(offer, domain.OfferResponseExpired)
+			continue
+		}
+		if err := s.storeOffer(offer); err != nil {
+			dropped = append(dropped, offer.OfferID)
+			continue
+		}
+		go s.handleOfferTimeout(offer)
+		restored++
+	}
+
+	if err := s.repo.DeletePendingOffers(ctx, dropped); err != nil {
+		s.log.Error("restore_offers_cleanup_failed", err)
+	}
+	return restored, nil
+}
+
+// rideVoided reports whether the ride was recently cancelled
+func (s *DriverLocationService) rideVoided(rideID string) bool {
+	s.offerMu.RLock()
+	defer s.offerMu.RUnlock()
+	_, voided := s.voidedRides[rideID]
+	return voided
+}
+
+// StartOfferSweeper periodically removes expired offers until ctx is cancelled
+func (s *DriverLocationService) StartOfferSweeper(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(offerSweepInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if removed := s.sweepExpiredOffers(s.clock.Now()); removed > 0 {
+					s.log.WithFields(logger.LogFields{
+						"removed": removed,
+					}).Info("offers_swept", fmt.Sprintf("Swept %d expired offers", removed))
+				}
+			}
+		}
+	}()
+}
+
+// OfferStats returns a snapshot of the pending offer map
+func (s *DriverLocationService) OfferStats() domain.OfferStats {
+	s.offerMu.RLock()
+	defer s.offerMu.RUnlock()
+	return domain.OfferStats{
+		Pending:  len(s.pendingOffers),
+		Capacity: maxPendingOffers,
+		Swept:    s.offersSwept,
+		Rejected: s.offersRejected,
+	}
+}
@@ -0,0 +1,411 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"ride-hail/internal/driver_location_service/domain"
+	"ride-hail/pkg/clock"
+)
+
+// pendingOffer returns an offer of ride to d1 that expires after ttl
+func (ts *testService) pendingOffer(rideID string, ttl time.Duration) *RideOffer {
+	return &RideOffer{
+		OfferID:     offerID(rideID, "d1"),
+		RideID:      rideID,
+		DriverID:    "d1",
+		RideRequest: rideRequest(rideID, 43.2390, 76.8900),
+		ExpiresAt:   ts.clock.Now().Add(ttl),
+	}
+}
+
+func TestSweepRemovesExpiredOffers(t *testing.T) {
+	s := newTestService(t)
+	expiring := s.pendingOffer("A", time.Second)
+	live := s.pendingOffer("B", time.Minute)
+	for _, o := range []*RideOffer{expiring, live} {
+		if err := s.storeOffer(o); err != nil {
+			t.Fatalf("storeOffer(%s): %v", o.OfferID, err)
+		}
+	}
+
+	s.clock.Advance(2 * time.Second)
+	if removed := s.sweepExpiredOffers(s.clock.Now()); removed != 1 {
+		t.Fatalf("swept %d offers, want 1", removed)
+	}
+
+	stats := s.OfferStats()
+	if stats.Pending != 1 || stats.Swept != 1 {
+		t.Errorf("stats = %+v, want 1 pending and 1 swept", stats)
+	}
+	if !expiring.Cancelled || live.Cancelled {
+		t.Errorf("cancelled: expiring=%v live=%v, want only the expired offer", expiring.Cancelled, live.Cancelled)
+	}
+	waitFor(t, "the expiry to be recorded", func() bool {
+		got := s.repo.responsesFor(expiring.OfferID)
+		return len(got) == 1 && got[0] == domain.OfferResponseExpired
+	})
+}
+
+func TestSweepAndTimeoutRecordExpiryOnce(t *testing.T) {
+	s := newTestService(t)
+	offer := s.pendingOffer("A", time.Second)
+	if err := s.storeOffer(offer); err != nil {
+		t.Fatalf("storeOffer: %v", err)
+	}
+	timedOut := make(chan struct{})
+	go func() {
+		s.handleOfferTimeout(offer)
+		close(timedOut)
+	}()
+	waitFor(t, "the offer timeout to start", func() bool { return s.clock.Waiters() == 1 })
+
+	// The sweeper gets there first, then the timeout fires
+	s.clock.Set(offer.ExpiresAt.Add(time.Millisecond))
+	s.sweepExpiredOffers(s.clock.Now())
+	<-timedOut
+
+	waitFor(t, "the expiry to be recorded", func() bool { return len(s.repo.responsesFor(offer.OfferID)) > 0 })
+	time.Sleep(20 * time.Millisecond)
+	if got := s.repo.responsesFor(offer.OfferID); len(got) != 1 {
+		t.Errorf("responses recorded = %v, want a single EXPIRED", got)
+	}
+}
+
+func TestStoreOfferRefusesBeyondCapacity(t *testing.T) {
+	s := newTestService(t)
+	s.offerMu.Lock()
+	for i := 0; i < maxPendingOffers; i++ {
+		id := fmt.Sprintf("offer-%d", i)
+		s.pendingOffers[id] = &RideOffer{OfferID: id, RideID: id, DriverID: "d1", ExpiresAt: s.clock.Now().Add(time.Minute)}
+	}
+	s.offerMu.Unlock()
+
+	if err := s.storeOffer(s.pendingOffer("A", time.Minute)); !errors.Is(err, errOffersFull) {
+		t.Fatalf("storeOffer at capacity: err = %v, want errOffersFull", err)
+	}
+	stats := s.OfferStats()
+	if stats.Pending != maxPendingOffers || stats.Capacity != maxPendingOffers || stats.Rejected != 1 {
+		t.Errorf("stats = %+v, want a full map and 1 rejection", stats)
+	}
+}
+
+func TestStoreOfferSweepsWhenFull(t *testing.T) {
+	s := newTestService(t)
+	s.offerMu.Lock()
+	for i := 0; i < maxPendingOffers; i++ {
+		id := fmt.Sprintf("offer-%d", i)
+		// The first one has already expired; the rest are live
+		ttl := time.Minute
+		if i == 0 {
+			ttl = -time.Second
+		}
+		s.pendingOffers[id] = &RideOffer{OfferID: id, RideID: "taken", DriverID: "d1", ExpiresAt: s.clock.Now().Add(ttl)}
+	}
+	// Keep the swept offer's ride from going back to matching
+	s.takenRides["taken"] = s.clock.Now()
+	s.offerMu.Unlock()
+
+	if err := s.storeOffer(s.pendingOffer("A", time.Minute)); err != nil {
+		t.Fatalf("storeOffer: %v", err)
+	}
+	stats := s.OfferStats()
+	if stats.Pending != maxPendingOffers || stats.Swept != 1 || stats.Rejected != 0 {
+		t.Errorf("stats = %+v, want the expired offer swept to make room", stats)
+	}
+}
+
+// restart returns a fresh service over ts's repository, as after a redeploy,
+// with its own clock starting at ts's current time
+func (ts *testService) restart() *testService {
+	c := clock.NewFake(ts.clock.Now())
+	next := &testService{repo: ts.repo, pub: &fakePublisher{}, ws: newFakeWS(), clock: c}
+	next.DriverLocationService = NewDriverLocationService(nopLogger{}, next.repo, next.pub, next.ws)
+	next.SetClock(c)
+	next.SetOfferAckTimeout(0)
+	next.SetRadiusExpansion(0, 0, 0)
+	return next
+}
+
+// storedOffer returns the persisted offer, nil if there is none
+func (r *fakeRepo) storedOffer(offerID string) *domain.PendingOffer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.pendingOffers[offerID]
+}
+
+func TestRestartRestoresPendingOfferWithRemainingTimeout(t *testing.T) {
+	s := newTestService(t)
+	s.onlineDriver("d1", 43.2389, 76.8897)
+	if err := s.HandleRideMatchingRequest(context.Background(), rideRequest("A", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("HandleRideMatchingRequest: %v", err)
+	}
+	id := offerID("A", "d1")
+	waitFor(t, "the offer to be persisted", func() bool { return s.repo.storedOffer(id) != nil })
+	expiresAt := s.repo.storedOffer(id).ExpiresAt
+
+	// The service goes down 10s into the 30s offer
+	s.clock.Advance(10 * time.Second)
+	restarted := s.restart()
+
+	n, err := restarted.RestorePendingOffers(context.Background())
+	if err != nil {
+		t.Fatalf("RestorePendingOffers: %v", err)
+	}
+	if n != 1 || restarted.OfferStats().Pending != 1 {
+		t.Fatalf("restored %d offers, %d pending; want the one still valid", n, restarted.OfferStats().Pending)
+	}
+	waitFor(t, "the restored timeout to be armed", func() bool { return restarted.clock.Waiters() == 1 })
+
+	// Only the remaining 20s are waited out, not a fresh 30s
+	restarted.clock.Set(expiresAt.Add(-time.Second))
+	if restarted.clock.Waiters() != 1 || restarted.OfferStats().Pending != 1 {
+		t.Fatal("restored offer expired before its original deadline")
+	}
+	restarted.clock.Set(expiresAt)
+	waitFor(t, "the restored offer to expire", func() bool {
+		got := restarted.repo.responsesFor(id)
+		return len(got) > 0 && got[len(got)-1] == domain.OfferResponseExpired
+	})
+	waitFor(t, "the expired offer to be deleted", func() bool { return restarted.repo.storedOffer(id) == nil })
+}
+
+func TestRestartDropsOffersThatExpiredWhileDown(t *testing.T) {
+	s := newTestService(t)
+	stale := &domain.PendingOffer{
+		OfferID:   offerID("A", "d1"),
+		RideID:    "A",
+		DriverID:  "d1",
+		Request:   rideRequest("A", 43.2390, 76.8900),
+		ExpiresAt: s.clock.Now().Add(-time.Second),
+	}
+	if err := s.repo.SavePendingOffer(context.Background(), stale); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := s.RestorePendingOffers(context.Background())
+	if err != nil {
+		t.Fatalf("RestorePendingOffers: %v", err)
+	}
+	if n != 0 || s.OfferStats().Pending != 0 {
+		t.Errorf("restored %d offers, %d pending; want none", n, s.OfferStats().Pending)
+	}
+	if s.repo.storedOffer(stale.OfferID) != nil {
+		t.Error("expired offer is still persisted")
+	}
+	waitFor(t, "the expiry to be recorded", func() bool {
+		got := s.repo.responsesFor(stale.OfferID)
+		return len(got) == 1 && got[0] == domain.OfferResponseExpired
+	})
+}
+
+func TestMatchingStopsAtOfferCap(t *testing.T) {
+	s := newTestService(t)
+	s.SetMaxOffersPerRide(2)
+	s.onlineDriver("d1", 43.2390, 76.8900)
+	s.onlineDriver("d2", 43.2400, 76.8910)
+	s.onlineDriver("d3", 43.2410, 76.8920)
+
+	if err := s.HandleRideMatchingRequest(context.Background(), rideRequest("A", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("HandleRideMatchingRequest: %v", err)
+	}
+	if got := s.repo.offeredTo("A"); len(got) != 2 || got[0] != "d1" || got[1] != "d2" {
+		t.Errorf("ride offered to %v, want the nearest two", got)
+	}
+	if got := s.ws.sentTo("d3"); len(got) != 0 {
+		t.Errorf("d3 was sent %v past the cap, want nothing", got)
+	}
+}
+
+func TestOfferCapSpansRematches(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	s.SetMaxOffersPerRide(2)
+	s.onlineDriver("d1", 43.2390, 76.8900)
+	s.onlineDriver("d2", 43.2400, 76.8910)
+	if err := s.HandleRideMatchingRequest(ctx, rideRequest("A", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("first match: %v", err)
+	}
+
+	// Both decline and a new driver comes online; the ride has used its offers
+	for _, d := range []string{"d1", "d2"} {
+		if err := s.HandleDriverRideResponse(ctx, d, offerID("A", d), "A", false); err != nil {
+			t.Fatalf("%s declines: %v", d, err)
+		}
+	}
+	s.onlineDriver("d3", 43.2410, 76.8920)
+	if err := s.HandleRideMatchingRequest(ctx, rideRequest("A", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("rematch: %v", err)
+	}
+
+	if got := s.repo.offeredTo("A"); len(got) != 2 {
+		t.Errorf("ride offered to %v, want no offers beyond the first two", got)
+	}
+	waitFor(t, "a no-driver result", func() bool { return s.pub.rejections("A") >= 1 })
+}
+
+func TestOfferTelemetryIsRecordedPerRide(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	s.onlineDriver("d1", 43.2390, 76.8900)
+	s.onlineDriver("d2", 43.2400, 76.8910)
+	if err := s.HandleRideMatchingRequest(ctx, rideRequest("A", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("HandleRideMatchingRequest: %v", err)
+	}
+
+	if err := s.HandleDriverRideResponse(ctx, "d1", offerID("A", "d1"), "A", false); err != nil {
+		t.Fatalf("d1 declines: %v", err)
+	}
+	if err := s.HandleDriverRideResponse(ctx, "d2", offerID("A", "d2"), "A", true); err != nil {
+		t.Fatalf("d2 accepts: %v", err)
+	}
+
+	if n, _ := s.repo.CountOffersSent(ctx, "A"); n != 2 {
+		t.Errorf("%d offers sent recorded, want 2", n)
+	}
+	waitFor(t, "both answers to be recorded", func() bool {
+		d1, d2 := s.repo.responsesFor(offerID("A", "d1")), s.repo.responsesFor(offerID("A", "d2"))
+		return len(d1) == 1 && d1[0] == domain.OfferResponseRejected &&
+			len(d2) == 1 && d2[0] == domain.OfferResponseAccepted
+	})
+}
+
+func TestListDriverOffersShowsOnlyAnswerableOffers(t *testing.T) {
+	s := newTestService(t)
+	later := s.pendingOffer("A", time.Minute)
+	sooner := s.pendingOffer("B", 30*time.Second)
+	expired := s.pendingOffer("C", time.Second)
+	withdrawn := s.pendingOffer("D", time.Minute)
+	withdrawn.Cancelled = true
+	other := s.pendingOffer("E", time.Minute)
+	other.OfferID, other.DriverID = offerID("E", "d2"), "d2"
+	for _, o := range []*RideOffer{later, sooner, expired, withdrawn, other} {
+		if err := s.storeOffer(o); err != nil {
+			t.Fatalf("storeOffer(%s): %v", o.OfferID, err)
+		}
+	}
+	s.clock.Advance(2 * time.Second)
+
+	offers := s.ListDriverOffers("d1")
+
+	var got []string
+	for _, o := range offers {
+		got = append(got, o.RideID)
+	}
+	if fmt.Sprint(got) != "[B A]" {
+		t.Fatalf("listed rides %v, want [B A], soonest to expire first", got)
+	}
+	if o := offers[1]; o.OfferID != later.OfferID || !o.ExpiresAt.Equal(later.ExpiresAt) || o.DriverEarnings == 0 {
+		t.Errorf("offer = %+v, want A's id, expiry and earnings", o)
+	}
+	// Listing is how a client without a socket learns of the offer
+	for _, o := range []*RideOffer{later, sooner} {
+		select {
+		case <-o.ack:
+		default:
+			t.Errorf("offer for %s not acknowledged by listing", o.RideID)
+		}
+	}
+}
+
+func TestListDriverOffersIsEmptyNotNil(t *testing.T) {
+	s := newTestService(t)
+	if offers := s.ListDriverOffers("d1"); offers == nil || len(offers) != 0 {
+		t.Errorf("offers = %#v, want an empty list", offers)
+	}
+}
+
+func TestLateAnswerToAnExpiredOfferIsExpired(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	s.onlineDriver("d1", 43.2389, 76.8897)
+	offer := s.pendingOffer("A", time.Second)
+	if err := s.storeOffer(offer); err != nil {
+		t.Fatalf("storeOffer: %v", err)
+	}
+	s.clock.Advance(2 * time.Second)
+
+	// Past expiry, before any sweep or timeout has removed it
+	if err := s.HandleDriverRideResponse(ctx, "d1", offer.OfferID, "A", true); !errors.Is(err, domain.ErrOfferExpired) {
+		t.Fatalf("unswept: err = %v, want ErrOfferExpired", err)
+	}
+
+	s.sweepExpiredOffers(s.clock.Now())
+	for _, accepted := range []bool{true, false} {
+		if err := s.HandleDriverRideResponse(ctx, "d1", offer.OfferID, "", accepted); !errors.Is(err, domain.ErrOfferExpired) {
+			t.Errorf("swept, accepted=%v: err = %v, want ErrOfferExpired", accepted, err)
+		}
+	}
+	if got := s.repo.currentRideID("d1"); got != "" {
+		t.Errorf("d1 bound to ride %q by an expired offer", got)
+	}
+
+	// Another driver learns nothing about it
+	if err := s.HandleDriverRideResponse(ctx, "d2", offer.OfferID, "", true); !errors.Is(err, domain.ErrOfferNotFound) {
+		t.Errorf("other driver: err = %v, want ErrOfferNotFound", err)
+	}
+}
+
+func TestLateAnswerToAWithdrawnOfferIsExpired(t *testing.T) {
+	s := newTestService(t)
+	offer := s.pendingOffer("A", time.Minute)
+	if err := s.storeOffer(offer); err != nil {
+		t.Fatalf("storeOffer: %v", err)
+	}
+	s.withdrawOffer(offer.OfferID)
+
+	if err := s.HandleDriverRideResponse(context.Background(), "d1", offer.OfferID, "", true); !errors.Is(err, domain.ErrOfferExpired) {
+		t.Errorf("err = %v, want ErrOfferExpired", err)
+	}
+}
+
+func TestClosedOffersAreForgottenAfterAWhile(t *testing.T) {
+	s := newTestService(t)
+	offer := s.pendingOffer("A", time.Second)
+	if err := s.storeOffer(offer); err != nil {
+		t.Fatalf("storeOffer: %v", err)
+	}
+	s.clock.Advance(2 * time.Second)
+	s.sweepExpiredOffers(s.clock.Now())
+
+	s.clock.Advance(closedOfferTTL + time.Second)
+	s.sweepExpiredOffers(s.clock.Now())
+
+	if err := s.HandleDriverRideResponse(context.Background(), "d1", offer.OfferID, "", true); !errors.Is(err, domain.ErrOfferNotFound) {
+		t.Errorf("err = %v, want ErrOfferNotFound once the closed offer is forgotten", err)
+	}
+}
+
+func TestOfferExpiresWhenTheClockReachesItsDeadline(t *testing.T) {
+	s := newTestService(t)
+	s.onlineDriver("d1", 43.2390, 76.8900)
+	req := rideRequest("A", 43.2390, 76.8900) // 30s to answer
+	if err := s.HandleRideMatchingRequest(context.Background(), req); err != nil {
+		t.Fatalf("match: %v", err)
+	}
+	id := offerID("A", "d1")
+	waitFor(t, "the offer timeout to start", func() bool { return s.clock.Waiters() == 1 })
+
+	s.clock.Advance(29 * time.Second)
+	if n := s.OfferStats().Pending; n != 1 {
+		t.Fatalf("%d offers pending a second before expiry, want 1", n)
+	}
+	if got := s.repo.responsesFor(id); len(got) != 0 {
+		t.Fatalf("responses recorded %v before expiry, want none", got)
+	}
+
+	s.clock.Advance(time.Second)
+	waitFor(t, "the offer to expire", func() bool {
+		got := s.repo.responsesFor(id)
+		return len(got) == 1 && got[0] == domain.OfferResponseExpired
+	})
+	if offers := s.ListDriverOffers("d1"); len(offers) != 0 {
+		t.Errorf("d1 still lists %d offers", len(offers))
+	}
+	if err := s.HandleDriverRideResponse(context.Background(), "d1", id, "A", true); !errors.Is(err, domain.ErrOfferExpired) {
+		t.Errorf("accept after expiry: err = %v, want ErrOfferExpired", err)
+	}
+}
@@ -0,0 +1,53 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"ride-hail/internal/driver_location_service/domain"
+)
+
+func TestGoOnlineMinimumRating(t *testing.T) {
+	tests := []struct {
+		name    string
+		minimum float64
+		rating  float64
+		blocked bool
+	}{
+		{"below the minimum", 4.0, 3.99, true},
+		{"at the minimum", 4.0, 4.0, false},
+		{"above the minimum", 4.0, 4.8, false},
+		{"no minimum", 0, 1.0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t)
+			s.SetMinOnlineRating(tt.minimum)
+			s.repo.addDriver("d1").Rating = tt.rating
+
+			_, err := s.DriverGoOnline(context.Background(), "d1", 43.2, 76.8, "Abay Ave 10")
+			if !tt.blocked {
+				if err != nil {
+					t.Fatalf("DriverGoOnline: %v", err)
+				}
+				if got := s.repo.status("d1"); got != domain.DriverStatusAvailable {
+					t.Errorf("status = %s, want AVAILABLE", got)
+				}
+				return
+			}
+			if !errors.Is(err, domain.ErrRatingTooLow) {
+				t.Fatalf("err = %v, want ErrRatingTooLow", err)
+			}
+			if got := s.repo.status("d1"); got != domain.DriverStatusOffline {
+				t.Errorf("status = %s, want the driver left OFFLINE", got)
+			}
+			if n := len(s.repo.openSessions("d1")); n != 0 {
+				t.Errorf("%d sessions opened, want none", n)
+			}
+			if msgs := s.pub.to("driver_topic"); len(msgs) != 0 {
+				t.Errorf("published %d status messages, want none", len(msgs))
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"ride-hail/internal/driver_location_service/domain"
+)
+
+const (
+	// Default limit on how old a driver's last location may be when offered a ride
+	defaultMaxLocationAge = 2 * time.Minute
+	// Default age after which a driver is offered a ride only after fresher ones
+	defaultStaleLocationAge = 30 * time.Second
+)
+
+// SetMaxLocationAge sets how recent a driver's location must be to receive an
+// offer; 0 turns the check off
+func (s *DriverLocationService) SetMaxLocationAge(age time.Duration) {
+	s.maxLocationAge = age
+}
+
+// SetStaleLocationAge sets how old a driver's location may be before they are
+// ranked behind drivers with fresher positions; 0 turns the re-ordering off
+func (s *DriverLocationService) SetStaleLocationAge(age time.Duration) {
+	s.staleLocationAge = age
+}
+
+// markStaleness sets each driver's StalenessSeconds and moves drivers whose
+// location is older than staleLocationAge behind the fresh ones, keeping the
+// existing order within each group
+func (s *DriverLocationService) markStaleness(drivers []*domain.NearbyDriver) {
+	now := s.clock.Now()
+	for _, d := range drivers {
+		d.StalenessSeconds = max(now.Sub(d.LocationUpdatedAt).Seconds(), 0)
+	}
+	if s.staleLocationAge <= 0 {
+		return
+	}
+	limit := s.staleLocationAge.Seconds()
+	sort.SliceStable(drivers, func(i, j int) bool {
+		return drivers[i].StalenessSeconds <= limit && drivers[j].StalenessSeconds > limit
+	})
+}
+
+// checkDriverPresence re-checks a nearby driver right before an offer is sent.
+// The nearby list may be stale by then, so the socket is looked up again and the
+// location must be recent. It returns why the driver is not present, or "" if
+// they are.
+func (s *DriverLocationService) checkDriverPresence(driver *domain.NearbyDriver, now time.Time) string {
+	if !s.wsMgr.IsDriverConnected(driver.DriverID) {
+		return "websocket disconnected"
+	}
+	if s.maxLocationAge > 0 {
+		if age := now.Sub(driver.LocationUpdatedAt); age > s.maxLocationAge {
+			return fmt.Sprintf("location is %s old", age.Truncate(time.Second))
+		}
+	}
+	return ""
+}
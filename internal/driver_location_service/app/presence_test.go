@@ -0,0 +1,181 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"ride-hail/internal/driver_location_service/domain"
+)
+
+// ageLocation makes the driver's last reported location age old
+func (ts *testService) ageLocation(driverID string, age time.Duration) {
+	ts.repo.mu.Lock()
+	defer ts.repo.mu.Unlock()
+	loc := ts.repo.currentLocation[driverID]
+	loc.Timestamp = ts.clock.Now().Add(-age)
+	ts.repo.currentLocation[driverID] = loc
+}
+
+func TestDriverWithStaleLocationIsSkipped(t *testing.T) {
+	s := newTestService(t)
+	s.SetMaxLocationAge(2 * time.Minute)
+	// The stale driver is nearer, so only the presence check keeps them from the offer
+	s.onlineDriver("stale", 43.2390, 76.8900)
+	s.ageLocation("stale", 5*time.Minute)
+	s.onlineDriver("fresh", 43.2410, 76.8920)
+
+	if err := s.HandleRideMatchingRequest(context.Background(), rideRequest("A", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("HandleRideMatchingRequest: %v", err)
+	}
+	if got := s.repo.offeredTo("A"); len(got) != 1 || got[0] != "fresh" {
+		t.Errorf("ride offered to %v, want only fresh", got)
+	}
+	if got := s.ws.sentTo("stale"); len(got) != 0 {
+		t.Errorf("stale driver was sent %v, want nothing", got)
+	}
+}
+
+func TestNoPresentDriverIsReportedAsNoDrivers(t *testing.T) {
+	s := newTestService(t)
+	s.SetMaxLocationAge(2 * time.Minute)
+	s.onlineDriver("stale", 43.2390, 76.8900)
+	s.ageLocation("stale", 5*time.Minute)
+
+	if err := s.HandleRideMatchingRequest(context.Background(), rideRequest("A", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("HandleRideMatchingRequest: %v", err)
+	}
+	if got := s.repo.offeredTo("A"); len(got) != 0 {
+		t.Errorf("ride offered to %v, want nobody", got)
+	}
+	if n := s.pub.rejections("A"); n != 1 {
+		t.Errorf("%d rejections published, want 1", n)
+	}
+}
+
+func TestMaxLocationAgeDisabled(t *testing.T) {
+	s := newTestService(t)
+	s.SetMaxLocationAge(0)
+	s.onlineDriver("stale", 43.2390, 76.8900)
+	s.ageLocation("stale", time.Hour)
+
+	if err := s.HandleRideMatchingRequest(context.Background(), rideRequest("A", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("HandleRideMatchingRequest: %v", err)
+	}
+	if got := s.repo.offeredTo("A"); len(got) != 1 || got[0] != "stale" {
+		t.Errorf("ride offered to %v, want stale with the check off", got)
+	}
+}
+
+func TestCheckDriverPresence(t *testing.T) {
+	s := newTestService(t)
+	s.SetMaxLocationAge(2 * time.Minute)
+	s.onlineDriver("live", 43.2390, 76.8900)
+	now := s.clock.Now()
+
+	tests := []struct {
+		name     string
+		driverID string
+		age      time.Duration
+		want     string
+	}{
+		{"fresh location and live socket", "live", 10 * time.Second, ""},
+		{"at the age limit", "live", 2 * time.Minute, ""},
+		{"stale location and live socket", "live", 3 * time.Minute, "location is 3m0s old"},
+		{"fresh location and no socket", "gone", 10 * time.Second, "websocket disconnected"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			driver := &domain.NearbyDriver{DriverID: tt.driverID, Connected: true, LocationUpdatedAt: now.Add(-tt.age)}
+			got := s.checkDriverPresence(driver, now)
+			if got != tt.want {
+				t.Errorf("checkDriverPresence = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindNearbyDriversReportsStaleness(t *testing.T) {
+	s := newTestService(t)
+	s.SetStaleLocationAge(30 * time.Second)
+	// The stale driver is nearest, so distance alone would list them first
+	s.onlineDriver("stale", 43.2390, 76.8900)
+	s.ageLocation("stale", 45*time.Second)
+	s.onlineDriver("fresh", 43.2410, 76.8920)
+	s.ageLocation("fresh", 5*time.Second)
+
+	drivers, err := s.FindNearbyDrivers(context.Background(), 43.2390, 76.8900, "ECONOMY", 5, 1, 10)
+	if err != nil {
+		t.Fatalf("FindNearbyDrivers: %v", err)
+	}
+	if len(drivers) != 2 {
+		t.Fatalf("found %d drivers, want 2", len(drivers))
+	}
+	if d := drivers[0]; d.DriverID != "fresh" || d.StalenessSeconds != 5 {
+		t.Errorf("first driver %s %.0fs stale, want fresh 5s stale", d.DriverID, d.StalenessSeconds)
+	}
+	if d := drivers[1]; d.DriverID != "stale" || d.StalenessSeconds != 45 {
+		t.Errorf("second driver %s %.0fs stale, want stale 45s stale", d.DriverID, d.StalenessSeconds)
+	}
+}
+
+func TestMarkStalenessKeepsOrderWithinEachGroup(t *testing.T) {
+	s := newTestService(t)
+	s.SetStaleLocationAge(30 * time.Second)
+	now := s.clock.Now()
+	drivers := []*domain.NearbyDriver{
+		{DriverID: "stale-near", LocationUpdatedAt: now.Add(-time.Minute)},
+		{DriverID: "fresh-near", LocationUpdatedAt: now.Add(-20 * time.Second)},
+		{DriverID: "stale-far", LocationUpdatedAt: now.Add(-40 * time.Second)},
+		{DriverID: "at-limit", LocationUpdatedAt: now.Add(-30 * time.Second)},
+		// A timestamp slightly ahead of the service's clock is not negative staleness
+		{DriverID: "ahead", LocationUpdatedAt: now.Add(time.Second)},
+	}
+
+	s.markStaleness(drivers)
+
+	var got []string
+	for _, d := range drivers {
+		got = append(got, d.DriverID)
+	}
+	if want := "[fresh-near at-limit ahead stale-near stale-far]"; fmt.Sprint(got) != want {
+		t.Errorf("order = %v, want %s", got, want)
+	}
+	if d := drivers[2]; d.StalenessSeconds != 0 {
+		t.Errorf("%s is %.0fs stale, want 0", d.DriverID, d.StalenessSeconds)
+	}
+}
+
+func TestStaleDriverIsOfferedAfterFresherOne(t *testing.T) {
+	s := newTestService(t)
+	s.SetMaxLocationAge(2 * time.Minute)
+	s.SetStaleLocationAge(30 * time.Second)
+	// Nearer but stale, yet still inside the hard cut-off
+	s.onlineDriver("stale", 43.2390, 76.8900)
+	s.ageLocation("stale", time.Minute)
+	s.onlineDriver("fresh", 43.2410, 76.8920)
+
+	if err := s.HandleRideMatchingRequest(context.Background(), rideRequest("A", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("HandleRideMatchingRequest: %v", err)
+	}
+	if got := s.repo.offeredTo("A"); len(got) == 0 || got[0] != "fresh" {
+		t.Errorf("ride offered to %v, want fresh first", got)
+	}
+}
+
+func TestStaleLocationAgeDisabledKeepsDistanceOrder(t *testing.T) {
+	s := newTestService(t)
+	s.SetMaxLocationAge(2 * time.Minute)
+	s.SetStaleLocationAge(0)
+	s.onlineDriver("stale", 43.2390, 76.8900)
+	s.ageLocation("stale", time.Minute)
+	s.onlineDriver("fresh", 43.2410, 76.8920)
+
+	if err := s.HandleRideMatchingRequest(context.Background(), rideRequest("A", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("HandleRideMatchingRequest: %v", err)
+	}
+	if got := s.repo.offeredTo("A"); len(got) == 0 || got[0] != "stale" {
+		t.Errorf("ride offered to %v, want the nearer stale driver first with re-ordering off", got)
+	}
+}
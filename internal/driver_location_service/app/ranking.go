@@ -0,0 +1,66 @@
+package app
+
+import (
+	"math"
+	"sort"
+
+	"ride-hail/internal/driver_location_service/domain"
+	"ride-hail/pkg/geo"
+)
+
+const (
+	// Nominal traffic-free speed used when a driver has no recent speed reading
+	nominalSpeedKmh = 30.0
+	// Floor so a stationary driver's ETA stays finite
+	minSpeedKmh = 10.0
+	// Extra minutes for a driver heading directly away from the pickup (U-turn)
+	maxTurnPenaltyMinutes = 3.0
+)
+
+// rankDriversByETA re-orders drivers by estimated arrival time at the pickup,
+// penalising drivers whose last heading points away from it. Drivers without a
+// heading get no penalty. Ties fall back to rating.
+func rankDriversByETA(drivers []*domain.NearbyDriver, pickupLat, pickupLng float64) {
+	scores := make(map[string]float64, len(drivers))
+	for _, d := range drivers {
+		scores[d.DriverID] = driverETAMinutes(d, pickupLat, pickupLng)
+	}
+	sort.SliceStable(drivers, func(i, j int) bool {
+		si, sj := scores[drivers[i].DriverID], scores[drivers[j].DriverID]
+		if si != sj {
+			return si < sj
+		}
+		return drivers[i].Rating > drivers[j].Rating
+	})
+}
+
+// driverETAMinutes estimates minutes until the driver reaches the pickup
+func driverETAMinutes(d *domain.NearbyDriver, pickupLat, pickupLng float64) float64 {
+	speed := nominalSpeedKmh
+	if d.SpeedKmh > minSpeedKmh {
+		speed = d.SpeedKmh
+	} else if d.SpeedKmh > 0 {
+		speed = minSpeedKmh
+	}
+	eta := d.DistanceKm / speed * 60
+
+	if d.HeadingDegrees != nil {
+		bearing := geo.BearingDegrees(d.Latitude, d.Longitude, pickupLat, pickupLng)
+		eta += headingDifference(*d.HeadingDegrees, bearing) / 180 * maxTurnPenaltyMinutes
+	}
+	return eta
+}
+
+// distanceMeters returns the great-circle distance between two points
+func distanceMeters(lat1, lng1, lat2, lng2 float64) float64 {
+	return geo.HaversineKm(lat1, lng1, lat2, lng2) * 1000
+}
+
+// headingDifference returns the absolute angle between two headings in [0, 180]
+func headingDifference(a, b float64) float64 {
+	diff := math.Mod(math.Abs(a-b), 360)
+	if diff > 180 {
+		diff = 360 - diff
+	}
+	return diff
+}
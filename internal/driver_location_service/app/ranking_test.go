@@ -0,0 +1,122 @@
+package app
+
+import (
+	"math"
+	"testing"
+
+	"ride-hail/internal/driver_location_service/domain"
+	"ride-hail/pkg/geo"
+)
+
+const pickupLat, pickupLng = 43.238, 76.945
+
+// driverAt returns a driver offset north of the pickup by dLat degrees,
+// optionally with a heading
+func driverAt(id string, dLat float64, heading *float64, rating float64) *domain.NearbyDriver {
+	lat := pickupLat + dLat
+	return &domain.NearbyDriver{
+		DriverID:       id,
+		Rating:         rating,
+		Latitude:       lat,
+		Longitude:      pickupLng,
+		DistanceKm:     geo.HaversineKm(lat, pickupLng, pickupLat, pickupLng),
+		HeadingDegrees: heading,
+	}
+}
+
+func degrees(d float64) *float64 { return &d }
+
+func driverIDs(drivers []*domain.NearbyDriver) []string {
+	ids := make([]string, len(drivers))
+	for i, d := range drivers {
+		ids[i] = d.DriverID
+	}
+	return ids
+}
+
+func TestHeadingRerankPrefersDriverFacingPickup(t *testing.T) {
+	// "away" is 1 km south and driving south, away from the pickup; "toward"
+	// is 1.2 km north and also driving south, straight at it. By distance
+	// alone "away" is nearest, which is the order the repository returns.
+	byDistance := func() []*domain.NearbyDriver {
+		return []*domain.NearbyDriver{
+			driverAt("away", -0.009, degrees(180), 5.0),
+			driverAt("toward", 0.0108, degrees(180), 4.5),
+		}
+	}
+
+	plain := byDistance()
+	if got := driverIDs(plain); got[0] != "away" {
+		t.Fatalf("distance order = %v, want away first", got)
+	}
+
+	ranked := byDistance()
+	rankDriversByETA(ranked, pickupLat, pickupLng)
+	if got := driverIDs(ranked); got[0] != "toward" || got[1] != "away" {
+		t.Errorf("heading-aware order = %v, want [toward away]", got)
+	}
+
+	// A U-turn costs the full penalty on top of the drive itself
+	away := driverETAMinutes(plain[0], pickupLat, pickupLng)
+	want := plain[0].DistanceKm/nominalSpeedKmh*60 + maxTurnPenaltyMinutes
+	if math.Abs(away-want) > 0.05 {
+		t.Errorf("away ETA = %.2f min, want %.2f", away, want)
+	}
+}
+
+func TestHeadingRerankWithoutHeadingsKeepsDistanceOrder(t *testing.T) {
+	drivers := []*domain.NearbyDriver{
+		driverAt("near", -0.009, nil, 4.0),
+		driverAt("far", 0.0108, nil, 5.0),
+	}
+	rankDriversByETA(drivers, pickupLat, pickupLng)
+	if got := driverIDs(drivers); got[0] != "near" {
+		t.Errorf("order = %v, want near first when no heading is known", got)
+	}
+}
+
+func TestHeadingRerankUsesReportedSpeed(t *testing.T) {
+	slow := driverAt("slow", -0.009, nil, 5.0)
+	slow.SpeedKmh = 5 // crawling, clamped to the floor
+	fast := driverAt("fast", 0.0108, nil, 5.0)
+	fast.SpeedKmh = 50
+
+	drivers := []*domain.NearbyDriver{slow, fast}
+	rankDriversByETA(drivers, pickupLat, pickupLng)
+	if got := driverIDs(drivers); got[0] != "fast" {
+		t.Errorf("order = %v, want the faster driver first", got)
+	}
+	if eta := driverETAMinutes(slow, pickupLat, pickupLng); math.Abs(eta-slow.DistanceKm/minSpeedKmh*60) > 0.01 {
+		t.Errorf("slow ETA = %.2f min, want it computed at %v km/h", eta, minSpeedKmh)
+	}
+}
+
+func TestHeadingRerankTiesFallBackToRating(t *testing.T) {
+	drivers := []*domain.NearbyDriver{
+		driverAt("low", -0.009, degrees(0), 4.2),
+		driverAt("high", -0.009, degrees(0), 4.9),
+	}
+	rankDriversByETA(drivers, pickupLat, pickupLng)
+	if got := driverIDs(drivers); got[0] != "high" {
+		t.Errorf("order = %v, want the higher rating first on equal ETA", got)
+	}
+}
+
+func TestHeadingDifference(t *testing.T) {
+	tests := []struct {
+		a, b, want float64
+	}{
+		{0, 0, 0},
+		{90, 180, 90},
+		{350, 10, 20},
+		{10, 350, 20},
+		{0, 180, 180},
+		{270, 90, 180},
+		{45, 405, 0},
+	}
+	for _, tt := range tests {
+		if got := headingDifference(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("headingDifference(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
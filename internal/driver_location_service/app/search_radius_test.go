@@ -0,0 +1,84 @@
+package app
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRideRequestRadiusIsClampedToTheMaximum(t *testing.T) {
+	s := newTestService(t)
+	s.SetSearchRadius(5, 20)
+	s.onlineDriver("d1", 43.2390+0.063, 76.8900)
+
+	req := rideRequest("A", 43.2390, 76.8900)
+	req.MaxDistanceKM = 1000
+	if err := s.HandleRideMatchingRequest(context.Background(), req); err != nil {
+		t.Fatalf("match: %v", err)
+	}
+
+	if got := s.repo.searchRadii(); len(got) != 1 || got[0] != 20 {
+		t.Errorf("searched radii %v km, want [20]", got)
+	}
+	if got := s.ws.sentTo("d1"); len(got) != 1 || got[0] != "ride_offer" {
+		t.Errorf("d1 was sent %v, want an offer within the clamped radius", got)
+	}
+}
+
+func TestRideRequestWithoutRadiusUsesTheDefault(t *testing.T) {
+	s := newTestService(t)
+	s.SetSearchRadius(3, 20)
+
+	for i, requested := range []float64{0, -5} {
+		req := rideRequest(string(rune('A'+i)), 43.2390, 76.8900)
+		req.MaxDistanceKM = requested
+		if err := s.HandleRideMatchingRequest(context.Background(), req); err != nil {
+			t.Fatalf("match: %v", err)
+		}
+		if got := s.repo.searchRadii(); len(got) != i+1 || got[i] != 3 {
+			t.Errorf("max_distance_km %v searched radii %v km, want the 3 km default", requested, got)
+		}
+	}
+}
+
+func TestNearbyDriversRadiusIsBounded(t *testing.T) {
+	s := newTestService(t)
+	s.SetSearchRadius(4, 25)
+	ctx := context.Background()
+
+	tests := []struct {
+		requestedKm float64
+		wantMeters  float64
+	}{
+		{0, 4000},
+		{10, 10000},
+		{25, 25000},
+		{1000, 25000},
+	}
+	for _, tt := range tests {
+		if _, err := s.FindNearbyDrivers(ctx, 43.2390, 76.8900, "ECONOMY", tt.requestedKm, 0, 10); err != nil {
+			t.Fatalf("FindNearbyDrivers: %v", err)
+		}
+		if got := s.repo.lastSearch().radiusMeters; got != tt.wantMeters {
+			t.Errorf("radius %v km searched %v m, want %v", tt.requestedKm, got, tt.wantMeters)
+		}
+	}
+}
+
+func TestRadiusExpansionStopsAtTheSearchMaximum(t *testing.T) {
+	s := newTestService(t)
+	s.SetRadiusExpansion(5, 30, 0)
+	s.SetSearchRadius(5, 12)
+	// ~20 km away, beyond the search maximum
+	s.onlineDriver("d1", 43.2390+0.18, 76.8900)
+
+	if err := s.HandleRideMatchingRequest(context.Background(), rideRequest("A", 43.2390, 76.8900)); err != nil {
+		t.Fatalf("match: %v", err)
+	}
+
+	if got := s.repo.searchRadii(); len(got) != 3 || got[0] != 5 || got[1] != 10 || got[2] != 12 {
+		t.Errorf("searched radii %v km, want [5 10 12]", got)
+	}
+	if n := s.pub.rejections("A"); n != 1 {
+		t.Errorf("%d rejections published, want 1", n)
+	}
+}
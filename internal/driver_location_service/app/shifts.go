@@ -0,0 +1,74 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"ride-hail/internal/driver_location_service/domain"
+	"ride-hail/pkg/logger"
+)
+
+const (
+	defaultShiftPageSize = 20
+	maxShiftPageSize     = 100
+)
+
+// SetShiftNotifier sets where shift summaries are delivered after they are
+// stored; nil, the default, only stores them
+func (s *DriverLocationService) SetShiftNotifier(n domain.ShiftSummaryNotifier) {
+	s.shiftNotifier = n
+}
+
+// recordShiftSummary stores the summary of a session that just ended and hands
+// it to the notifier. Failures are logged; going offline has already happened.
+func (s *DriverLocationService) recordShiftSummary(ctx context.Context, session *domain.DriverSession) {
+	summary := domain.NewShiftSummary(session)
+	if summary == nil {
+		return
+	}
+	log := s.log.WithFields(logger.LogFields{
+		"driver_id":  summary.DriverID,
+		"session_id": summary.SessionID,
+	})
+	if err := s.repo.SaveShiftSummary(ctx, summary); err != nil {
+		log.Error("save_shift_summary_failed", err)
+		return
+	}
+	if s.shiftNotifier == nil {
+		return
+	}
+	if err := s.shiftNotifier.NotifyShiftSummary(ctx, summary); err != nil {
+		log.Error("notify_shift_summary_failed", err)
+	}
+}
+
+// ListShifts returns one page of the driver's past shifts, newest first. One
+// extra row is fetched to tell whether another page follows.
+func (s *DriverLocationService) ListShifts(ctx context.Context, driverID string, limit, offset int) (*domain.ShiftPage, error) {
+	if limit == 0 {
+		limit = defaultShiftPageSize
+	}
+	if limit < 0 || limit > maxShiftPageSize {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", domain.ErrInvalidShiftQuery, maxShiftPageSize)
+	}
+	if offset < 0 {
+		return nil, fmt.Errorf("%w: offset must not be negative", domain.ErrInvalidShiftQuery)
+	}
+
+	shifts, err := s.repo.ListShiftSummaries(ctx, driverID, limit+1, offset)
+	if err != nil {
+		s.log.WithFields(logger.LogFields{"driver_id": driverID}).Error("list_shifts_failed", err)
+		return nil, err
+	}
+
+	page := &domain.ShiftPage{
+		Shifts: shifts,
+		Limit:  limit,
+		Offset: offset,
+	}
+	if len(shifts) > limit {
+		page.Shifts = shifts[:limit]
+		page.HasMore = true
+	}
+	return page, nil
+}
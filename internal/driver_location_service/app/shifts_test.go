@@ -0,0 +1,132 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ride-hail/internal/driver_location_service/domain"
+)
+
+// fakeShiftNotifier records the summaries it is handed
+type fakeShiftNotifier struct {
+	err       error
+	summaries []*domain.ShiftSummary
+}
+
+func (n *fakeShiftNotifier) NotifyShiftSummary(ctx context.Context, summary *domain.ShiftSummary) error {
+	n.summaries = append(n.summaries, summary)
+	return n.err
+}
+
+// workShift takes d1 online for length, then offline
+func (ts *testService) workShift(t *testing.T, length time.Duration) {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := ts.DriverGoOnline(ctx, "d1", 43.2389, 76.8897, ""); err != nil {
+		t.Fatalf("DriverGoOnline: %v", err)
+	}
+	ts.clock.Advance(length)
+	if _, err := ts.DriverGoOffline(ctx, "d1"); err != nil {
+		t.Fatalf("DriverGoOffline: %v", err)
+	}
+}
+
+func TestGoingOfflineStoresShiftSummary(t *testing.T) {
+	s := newTestService(t)
+	notifier := &fakeShiftNotifier{}
+	s.SetShiftNotifier(notifier)
+	s.repo.addDriver("d1")
+	ctx := context.Background()
+
+	sessionID, err := s.DriverGoOnline(ctx, "d1", 43.2389, 76.8897, "")
+	if err != nil {
+		t.Fatalf("DriverGoOnline: %v", err)
+	}
+	if err := s.repo.UpdateDriverSessionStats(ctx, "d1", 2, 2320); err != nil {
+		t.Fatal(err)
+	}
+	s.clock.Advance(4 * time.Hour)
+	s.repo.busy = 3 * time.Hour
+	if _, err := s.DriverGoOffline(ctx, "d1"); err != nil {
+		t.Fatalf("DriverGoOffline: %v", err)
+	}
+
+	if len(s.repo.shifts) != 1 {
+		t.Fatalf("%d shift summaries stored, want 1", len(s.repo.shifts))
+	}
+	got := s.repo.shifts[0]
+	want := domain.ShiftSummary{
+		SessionID:      sessionID,
+		DriverID:       "d1",
+		StartedAt:      testNow,
+		EndedAt:        testNow.Add(4 * time.Hour),
+		RidesCompleted: 2,
+		Earnings:       2320,
+		BusyDuration:   3 * time.Hour,
+	}
+	if *got != want {
+		t.Errorf("stored %+v, want %+v", *got, want)
+	}
+	if len(notifier.summaries) != 1 || notifier.summaries[0] != got {
+		t.Errorf("notifier got %v, want the stored summary", notifier.summaries)
+	}
+}
+
+func TestShiftNotifierFailureDoesNotFailOffline(t *testing.T) {
+	s := newTestService(t)
+	s.SetShiftNotifier(&fakeShiftNotifier{err: errors.New("smtp down")})
+	s.repo.addDriver("d1")
+
+	s.workShift(t, time.Hour)
+
+	if len(s.repo.shifts) != 1 {
+		t.Errorf("%d shift summaries stored, want 1", len(s.repo.shifts))
+	}
+	if got := s.repo.status("d1"); got != domain.DriverStatusOffline {
+		t.Errorf("status = %s, want OFFLINE", got)
+	}
+}
+
+func TestListShiftsPagesNewestFirst(t *testing.T) {
+	s := newTestService(t)
+	s.repo.addDriver("d1")
+	for _, length := range []time.Duration{time.Hour, 2 * time.Hour, 3 * time.Hour} {
+		s.workShift(t, length)
+		s.clock.Advance(time.Hour)
+	}
+	ctx := context.Background()
+
+	first, err := s.ListShifts(ctx, "d1", 2, 0)
+	if err != nil {
+		t.Fatalf("ListShifts: %v", err)
+	}
+	if len(first.Shifts) != 2 || !first.HasMore || first.Shifts[0].Duration() != 3*time.Hour || first.Shifts[1].Duration() != 2*time.Hour {
+		t.Errorf("first page = %d shifts, has_more %v; want the 3h and 2h shifts with more to come", len(first.Shifts), first.HasMore)
+	}
+	second, err := s.ListShifts(ctx, "d1", 2, 2)
+	if err != nil {
+		t.Fatalf("ListShifts: %v", err)
+	}
+	if len(second.Shifts) != 1 || second.HasMore || second.Shifts[0].Duration() != time.Hour {
+		t.Errorf("second page = %d shifts, has_more %v; want only the 1h shift", len(second.Shifts), second.HasMore)
+	}
+
+	page, err := s.ListShifts(ctx, "d1", 0, 0)
+	if err != nil {
+		t.Fatalf("ListShifts: %v", err)
+	}
+	if page.Limit != defaultShiftPageSize || len(page.Shifts) != 3 {
+		t.Errorf("default page = limit %d with %d shifts, want %d with 3", page.Limit, len(page.Shifts), defaultShiftPageSize)
+	}
+}
+
+func TestListShiftsRejectsBadPaging(t *testing.T) {
+	s := newTestService(t)
+	for _, p := range []struct{ limit, offset int }{{-1, 0}, {maxShiftPageSize + 1, 0}, {10, -1}} {
+		if _, err := s.ListShifts(context.Background(), "d1", p.limit, p.offset); !errors.Is(err, domain.ErrInvalidShiftQuery) {
+			t.Errorf("limit %d offset %d: err = %v, want ErrInvalidShiftQuery", p.limit, p.offset, err)
+		}
+	}
+}
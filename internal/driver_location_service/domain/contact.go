@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"strings"
+	"unicode"
+)
+
+// PassengerContact is what a driver may see about their passenger: a first name,
+// a phone number with all but the last two digits hidden, and the in-app chat
+// channel for the ride. The raw email and phone never leave the repository.
+type PassengerContact struct {
+	PassengerID string `json:"passenger_id"`
+	FirstName   string `json:"first_name"`
+	MaskedPhone string `json:"masked_phone,omitempty"`
+	ChatID      string `json:"chat_id"`
+}
+
+// NewPassengerContact masks the passenger's name and phone for display to a driver
+func NewPassengerContact(rideID, passengerID, fullName, phone string) PassengerContact {
+	firstName := "Passenger"
+	if fields := strings.Fields(fullName); len(fields) > 0 {
+		firstName = fields[0]
+	}
+	return PassengerContact{
+		PassengerID: passengerID,
+		FirstName:   firstName,
+		MaskedPhone: maskPhone(phone),
+		ChatID:      "ride:" + rideID,
+	}
+}
+
+// maskPhone keeps only the last two digits, e.g. "+7 701 234 5678" -> "*********78"
+func maskPhone(phone string) string {
+	var digits []rune
+	for _, r := range phone {
+		if unicode.IsDigit(r) {
+			digits = append(digits, r)
+		}
+	}
+	if len(digits) < 4 {
+		return ""
+	}
+	return strings.Repeat("*", len(digits)-2) + string(digits[len(digits)-2:])
+}
@@ -0,0 +1,26 @@
+package domain
+
+import "testing"
+
+func TestNewPassengerContactMasksPII(t *testing.T) {
+	tests := []struct {
+		name, fullName, phone string
+		wantName, wantPhone   string
+	}{
+		{"full name and phone", "Aigerim Sadykova", "+7 701 123 45 67", "Aigerim", "*********67"},
+		{"single name", "Aigerim", "87011234567", "Aigerim", "*********67"},
+		{"no name", "  ", "+77011234567", "Passenger", "*********67"},
+		{"no phone", "Aigerim Sadykova", "", "Aigerim", ""},
+		// Too short to hide anything by masking all but two digits
+		{"short phone", "Aigerim", "123", "Aigerim", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewPassengerContact("ride-1", "passenger-1", tt.fullName, tt.phone)
+			want := PassengerContact{PassengerID: "passenger-1", FirstName: tt.wantName, MaskedPhone: tt.wantPhone, ChatID: "ride:ride-1"}
+			if c != want {
+				t.Errorf("NewPassengerContact = %+v, want %+v", c, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package domain
+
+import "errors"
+
+// Domain errors
+var (
+	ErrRideNotAssigned     = errors.New("ride is not assigned to this driver")
+	ErrRideNotCancellable  = errors.New("ride can no longer be cancelled")
+	ErrNoActiveSession     = errors.New("driver is not online")
+	ErrDriverNotAvailable  = errors.New("driver must be available to take a break")
+	ErrDriverNotOnBreak    = errors.New("driver is not on break")
+	ErrInvalidHeatmapArea  = errors.New("invalid heatmap area")
+	ErrInvalidHistoryQuery = errors.New("invalid location history query")
+	ErrInvalidShiftQuery   = errors.New("invalid shift query")
+	ErrRideNotArrived      = errors.New("driver has not arrived at the pickup")
+	ErrRideNotMatched      = errors.New("ride is not waiting for its driver to set off")
+	ErrRideNotPickingUp    = errors.New("ride is not on its way to the pickup")
+	ErrRideNotStartable    = errors.New("driver is not on the way to or at the pickup")
+	ErrNoShowTooEarly      = errors.New("passenger no-show wait has not elapsed")
+	ErrOfferNotFound       = errors.New("offer not found or expired")
+	ErrOfferExpired        = errors.New("offer has expired or was withdrawn")
+	ErrDriverAssigned      = errors.New("driver is already assigned to another ride")
+	ErrLocationRateLimited = errors.New("rate limit exceeded: max 1 location update per 3 seconds")
+	ErrRatingTooLow        = errors.New("driver rating is below the minimum required to go online")
+)
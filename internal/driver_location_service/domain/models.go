@@ -1,6 +1,10 @@
 package domain
 
-import "time"
+import (
+	"time"
+
+	"ride-hail/pkg/apitime"
+)
 
 // Driver represents a driver in the system
 type Driver struct {
@@ -16,8 +20,30 @@ type Driver struct {
 	IsVerified     bool
 	CurrentRideID  string
 	CurrentSession *DriverSession
+	Stats          *DriverStats
 }
 
+// DriverStats summarises how a driver handled offers and rides over a recent window
+type DriverStats struct {
+	Since                 time.Time
+	OffersReceived        int // offers answered or expired
+	OffersAccepted        int
+	AcceptanceRatePercent float64
+	RidesMatched          int
+	RidesCompleted        int
+	CompletionRatePercent float64
+}
+
+// Offer responses recorded for acceptance-rate tracking
+const (
+	OfferResponseAccepted = "ACCEPTED"
+	OfferResponseRejected = "REJECTED"
+	OfferResponseExpired  = "EXPIRED"
+	// The driver accepted but the ride couldn't be assigned to them; counts
+	// neither for nor against the acceptance rate
+	OfferResponseAcceptFailed = "ACCEPT_FAILED"
+)
+
 // DriverSession tracks online/offline times
 type DriverSession struct {
 	ID            string
@@ -26,6 +52,77 @@ type DriverSession struct {
 	EndedAt       *time.Time
 	TotalRides    int
 	TotalEarnings float64
+
+	// Time spent on rides (matched through completion) during the session
+	BusyDuration time.Duration
+}
+
+// Duration returns how long the session has been open, up to EndedAt if ended
+func (s *DriverSession) Duration() time.Duration {
+	if s.EndedAt != nil {
+		return s.EndedAt.Sub(s.StartedAt)
+	}
+	return time.Since(s.StartedAt)
+}
+
+// Utilization returns the share of the session spent on rides and the idle time in hours
+func (s *DriverSession) Utilization() (utilizationPercent float64, idleHours float64) {
+	total := s.Duration()
+	if total <= 0 {
+		return 0, 0
+	}
+	busy := s.BusyDuration
+	if busy > total {
+		busy = total
+	}
+	return float64(busy) / float64(total) * 100, (total - busy).Hours()
+}
+
+// ShiftSummary is the stored record of an ended driver session
+type ShiftSummary struct {
+	SessionID      string
+	DriverID       string
+	StartedAt      time.Time
+	EndedAt        time.Time
+	RidesCompleted int
+	Earnings       float64
+	BusyDuration   time.Duration
+}
+
+// NewShiftSummary records an ended session; it returns nil while the session is open
+func NewShiftSummary(s *DriverSession) *ShiftSummary {
+	if s.EndedAt == nil {
+		return nil
+	}
+	return &ShiftSummary{
+		SessionID:      s.ID,
+		DriverID:       s.DriverID,
+		StartedAt:      s.StartedAt,
+		EndedAt:        *s.EndedAt,
+		RidesCompleted: s.TotalRides,
+		Earnings:       s.TotalEarnings,
+		BusyDuration:   s.BusyDuration,
+	}
+}
+
+// Duration returns the length of the shift
+func (s *ShiftSummary) Duration() time.Duration {
+	return s.EndedAt.Sub(s.StartedAt)
+}
+
+// Utilization returns the share of the shift spent on rides and the idle time in hours
+func (s *ShiftSummary) Utilization() (utilizationPercent float64, idleHours float64) {
+	ended := s.EndedAt
+	session := DriverSession{StartedAt: s.StartedAt, EndedAt: &ended, BusyDuration: s.BusyDuration}
+	return session.Utilization()
+}
+
+// ShiftPage is one page of a driver's past shifts, newest first
+type ShiftPage struct {
+	Shifts  []*ShiftSummary
+	Limit   int
+	Offset  int
+	HasMore bool
 }
 
 // Coordinate represents a location point
@@ -56,15 +153,56 @@ type Location struct {
 type RideMatchingRequest struct {
 	RideID              string   `json:"ride_id"`
 	RideNumber          string   `json:"ride_number"`
+	PassengerID         string   `json:"passenger_id"`
 	PickupLocation      Location `json:"pickup_location"`
 	DestinationLocation Location `json:"destination_location"`
 	RideType            string   `json:"ride_type"`
 	EstimatedFare       float64  `json:"estimated_fare"`
+	PassengerCount      int      `json:"passenger_count"` // Seats needed; 0 from older publishers means 1
 	MaxDistanceKM       float64  `json:"max_distance_km"`
 	TimeoutSeconds      int      `json:"timeout_seconds"`
 	CorrelationID       string   `json:"correlation_id"`
 }
 
+// RideMessage is a chat message from a passenger, relayed by the ride service
+// to the ride's assigned driver.
+type RideMessage struct {
+	MessageID   string    `json:"message_id"`
+	RideID      string    `json:"ride_id"`
+	SenderID    string    `json:"sender_id"`
+	SenderRole  string    `json:"sender_role"`
+	RecipientID string    `json:"recipient_id"`
+	Text        string    `json:"text"`
+	SentAt      time.Time `json:"sent_at"`
+}
+
+// RideDestinationChange is a passenger's new drop-off for a ride in progress,
+// published by the ride service once the ride is updated.
+type RideDestinationChange struct {
+	RideID                string    `json:"ride_id"`
+	PassengerID           string    `json:"passenger_id"`
+	DriverID              string    `json:"driver_id"`
+	DestinationLocation   Location  `json:"destination_location"`
+	PreviousDestination   Location  `json:"previous_destination_location"`
+	EstimatedFare         float64   `json:"estimated_fare"`
+	PreviousEstimatedFare float64   `json:"previous_estimated_fare"`
+	ChangedAt             time.Time `json:"changed_at"`
+}
+
+// Driver control actions sent by the admin service
+const DriverControlForceOffline = "force_offline"
+
+// DriverControl is an admin instruction for a driver's live connection. For a
+// forced offline the admin service has already freed any ride and set the
+// driver OFFLINE; the driver service closes the socket and ends the session.
+type DriverControl struct {
+	DriverID  string    `json:"driver_id"`
+	Action    string    `json:"action"`
+	AdminID   string    `json:"admin_id"`
+	Reason    string    `json:"reason"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
 // LocationUpdate represents a real-time location update from driver
 type LocationUpdate struct {
 	DriverID       string
@@ -77,18 +215,70 @@ type LocationUpdate struct {
 	Timestamp      time.Time
 }
 
+// MaxLocationBatchSize caps how many buffered points a driver may flush in one request
+const MaxLocationBatchSize = 500
+
 // LocationHistory archives past location data
 type LocationHistory struct {
-	ID             string
-	CoordinateID   string
-	DriverID       string
-	Latitude       float64
-	Longitude      float64
-	AccuracyMeters float64
-	SpeedKmh       float64
-	HeadingDegrees float64
-	RecordedAt     time.Time
-	RideID         string
+	ID             string       `json:"id"`
+	CoordinateID   string       `json:"coordinate_id,omitempty"`
+	DriverID       string       `json:"driver_id"`
+	Latitude       float64      `json:"latitude"`
+	Longitude      float64      `json:"longitude"`
+	AccuracyMeters float64      `json:"accuracy_meters"`
+	SpeedKmh       float64      `json:"speed_kmh"`
+	HeadingDegrees float64      `json:"heading_degrees"`
+	RecordedAt     apitime.Time `json:"recorded_at"`
+	RideID         string       `json:"ride_id,omitempty"`
+}
+
+// LocationHistoryQuery selects a page of a driver's archived locations, oldest first.
+// Zero values mean no filter; From is inclusive and To exclusive.
+type LocationHistoryQuery struct {
+	RideID string
+	From   time.Time
+	To     time.Time
+	Limit  int
+	Offset int
+}
+
+// LocationHistoryPage is one page of archived locations
+type LocationHistoryPage struct {
+	Points  []*LocationHistory `json:"points"`
+	Limit   int                `json:"limit"`
+	Offset  int                `json:"offset"`
+	HasMore bool               `json:"has_more"`
+}
+
+// RideAssignment is the driver-relevant view of a ride owned by the ride service
+type RideAssignment struct {
+	RideID      string
+	RideNumber  string
+	PassengerID string
+	DriverID    string
+	Status      string
+	ArrivedAt   *time.Time // When the ride entered ARRIVED, nil before that
+}
+
+// NoShow is the outcome of a driver reporting a passenger no-show
+type NoShow struct {
+	RideID      string
+	Fee         float64 // Charged to the passenger
+	Earnings    float64 // Driver's share of the fee
+	CancelledAt time.Time
+}
+
+// CurrentRide is a driver's active assignment with what they need to reach the passenger
+type CurrentRide struct {
+	RideID        string
+	RideNumber    string
+	Status        string
+	PassengerID   string
+	Passenger     PassengerContact
+	Pickup        Location
+	Destination   Location
+	EstimatedFare float64
+	MatchedAt     *time.Time
 }
 
 // NearbyDriver represents a driver found near a location
@@ -101,6 +291,92 @@ type NearbyDriver struct {
 	Longitude   float64
 	DistanceKm  float64
 	VehicleInfo map[string]interface{}
+
+	// Last reported motion, used for heading-aware ranking (nil heading if unknown)
+	HeadingDegrees *float64
+	SpeedKmh       float64
+
+	// When the driver's location was last written, and how many seconds ago
+	// that was when the list was built
+	LocationUpdatedAt time.Time
+	StalenessSeconds  float64
+
+	// Driver has a live WebSocket and can receive offers
+	Connected bool
+}
+
+// OfferStats reports the size of the pending ride offer map
+type OfferStats struct {
+	Pending  int    `json:"pending"`
+	Capacity int    `json:"capacity"`
+	Swept    uint64 `json:"swept"`    // expired offers removed by the sweeper
+	Rejected uint64 `json:"rejected"` // offers refused because the map was full
+}
+
+// PendingOffer is an unanswered ride offer as persisted across restarts
+type PendingOffer struct {
+	OfferID   string
+	RideID    string
+	DriverID  string
+	Request   *RideMatchingRequest
+	ExpiresAt time.Time
+}
+
+// RideFareBasis is what a ride's estimated fare was priced from
+type RideFareBasis struct {
+	EstimatedFare float64
+	VehicleType   string
+	Pickup        Location
+	Destination   Location
+}
+
+// RideEarnings is a completed ride's payout next to the preview shown in the
+// offer. DistanceAdjustment is Earnings minus PreviewEarnings and comes only
+// from the actual distance differing from the estimated one.
+type RideEarnings struct {
+	EstimatedFare       float64
+	FinalFare           float64
+	EstimatedDistanceKm float64
+	ActualDistanceKm    float64
+	PreviewEarnings     float64
+	Earnings            float64
+	DistanceAdjustment  float64
+}
+
+// DriverOffer is a pending ride offer as shown to the driver it was made to
+type DriverOffer struct {
+	OfferID             string
+	RideID              string
+	RideNumber          string
+	PickupLocation      Location
+	DestinationLocation Location
+	EstimatedFare       float64
+	DriverEarnings      float64
+	ExpiresAt           time.Time
+}
+
+// BoundingBox is a lat/lng rectangle
+type BoundingBox struct {
+	MinLat float64 `json:"min_lat"`
+	MinLng float64 `json:"min_lng"`
+	MaxLat float64 `json:"max_lat"`
+	MaxLng float64 `json:"max_lng"`
+}
+
+// HeatmapCell counts waiting ride requests in one grid cell; the point is the cell center
+type HeatmapCell struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Count     int     `json:"count"`
+}
+
+// Heatmap is the demand grid over a bounding box
+type Heatmap struct {
+	Bounds      BoundingBox   `json:"bounds"`
+	CellSizeDeg float64       `json:"cell_size_deg"`
+	Cells       []HeatmapCell `json:"cells"`
+	TotalRides  int           `json:"total_rides"`
+	GeneratedAt apitime.Time  `json:"generated_at"`
 }
 
 // Driver status constants
@@ -109,11 +385,26 @@ const (
 	DriverStatusAvailable = "AVAILABLE"
 	DriverStatusBusy      = "BUSY"
 	DriverStatusEnRoute   = "EN_ROUTE"
+	DriverStatusOnBreak   = "ON_BREAK" // session stays open but no offers are sent
 )
 
+// Cancellation parties
+const (
+	CancelledByPassenger = "PASSENGER"
+	CancelledByDriver    = "DRIVER"
+)
+
+// DefaultVehicleSeats is assumed for vehicles whose vehicle_attrs has no "seats"
+const DefaultVehicleSeats = 4
+
+// ReasonPassengerNoShow is the cancellation reason when the driver waited at the
+// pickup and the passenger never came
+const ReasonPassengerNoShow = "PASSENGER_NO_SHOW"
+
 // Vehicle type constants
 const (
 	VehicleTypeEconomy = "ECONOMY"
 	VehicleTypePremium = "PREMIUM"
+	VehicleTypeLuxury  = "LUXURY"
 	VehicleTypeXL      = "XL"
 )
@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDriverSessionUtilization(t *testing.T) {
+	start := time.Date(2024, 12, 16, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		length        time.Duration
+		busy          time.Duration
+		wantPercent   float64
+		wantIdleHours float64
+	}{
+		{"three of four hours on rides", 4 * time.Hour, 3 * time.Hour, 75, 1},
+		{"no rides", 2 * time.Hour, 0, 0, 2},
+		{"on rides throughout", 90 * time.Minute, 90 * time.Minute, 100, 0},
+		// A ride matched before going online can report more busy time than the session
+		{"busy longer than the session", time.Hour, 2 * time.Hour, 100, 0},
+		{"empty session", 0, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			end := start.Add(tt.length)
+			s := &DriverSession{StartedAt: start, EndedAt: &end, BusyDuration: tt.busy}
+
+			percent, idle := s.Utilization()
+			if math.Abs(percent-tt.wantPercent) > 1e-9 || math.Abs(idle-tt.wantIdleHours) > 1e-9 {
+				t.Errorf("Utilization() = %v%%, %v idle hours; want %v%%, %v", percent, idle, tt.wantPercent, tt.wantIdleHours)
+			}
+		})
+	}
+}
@@ -16,36 +16,73 @@ type DriverLocationRepository interface {
 
 	// Session operations
 	CreateDriverSession(ctx context.Context, driverID string) (string, error)
-	EndDriverSession(ctx context.Context, sessionID string) (*DriverSession, error)
+	EndDriverSession(ctx context.Context, sessionID string, endedAt time.Time) (*DriverSession, error)
 	GetActiveSession(ctx context.Context, driverID string) (*DriverSession, error)
+	SaveShiftSummary(ctx context.Context, summary *ShiftSummary) error
+	ListShiftSummaries(ctx context.Context, driverID string, limit, offset int) ([]*ShiftSummary, error)
 
 	// Location operations
 	SaveDriverLocation(ctx context.Context, driverID string, latitude, longitude float64, address string) (string, error)
 	UpdateLocationWithMetrics(ctx context.Context, coordinateID string, accuracy, speed, heading float64) error
 	ArchiveLocation(ctx context.Context, driverID string, lat, lng, accuracy, speed, heading float64, rideID string) error
+	SaveLocationBatch(ctx context.Context, driverID string, points []LocationUpdate, address string) (string, error)
 	GetCurrentLocation(ctx context.Context, driverID string) (*Coordinate, error)
 	GetLastLocationUpdate(ctx context.Context, driverID string) (*time.Time, error)
+	GetLocationHistory(ctx context.Context, driverID string, q LocationHistoryQuery) ([]*LocationHistory, error)
 
 	// Matching operations
-	FindNearbyDrivers(ctx context.Context, latitude, longitude float64, vehicleType string, radiusMeters float64, limit int) ([]*NearbyDriver, error)
+	FindNearbyDrivers(ctx context.Context, latitude, longitude float64, vehicleType string, radiusMeters, minRating float64, minSeats, limit int, excludeDriverIDs []string) ([]*NearbyDriver, error)
 
 	// Ride tracking
 	SetDriverCurrentRide(ctx context.Context, driverID string, rideID string) error
 	ClearDriverCurrentRide(ctx context.Context, driverID string) error
 
-	GetEstimatedFare(ctx context.Context, rideID string) (float64, error)
+	GetRideFareBasis(ctx context.Context, rideID string) (*RideFareBasis, error)
+	GetDriverBusyDuration(ctx context.Context, driverID string, from, to time.Time) (time.Duration, error)
+	GetRideAssignment(ctx context.Context, rideID string) (*RideAssignment, error)
+	GetDriverCurrentRide(ctx context.Context, driverID string) (*CurrentRide, error)
+	GetPassengerContact(ctx context.Context, rideID string) (*PassengerContact, error)
+	RecordOfferResponse(ctx context.Context, driverID, rideID, offerID, response string) error
+	RecordOfferSent(ctx context.Context, driverID, rideID, offerID string) error
+	CountOffersSent(ctx context.Context, rideID string) (int, error)
+	SavePendingOffer(ctx context.Context, offer *PendingOffer) error
+	DeletePendingOffers(ctx context.Context, offerIDs []string) error
+	ListPendingOffers(ctx context.Context) ([]*PendingOffer, error)
+	GetDriverStats(ctx context.Context, driverID string, since time.Time) (*DriverStats, error)
+	CountRequestedRidesByCell(ctx context.Context, box BoundingBox, cellSizeDeg float64) ([]HeatmapCell, error)
 }
 
 // DriverLocationService exposes the business operations used by adapters.
 type DriverLocationService interface {
+	GetDriver(ctx context.Context, driverID string) (*Driver, error)
 	DriverGoOnline(ctx context.Context, driverID string, latitude, longitude float64, address string) (string, error)
 	DriverGoOffline(ctx context.Context, driverID string) (*DriverSession, error)
+	HandleDriverIdle(ctx context.Context, driverID string) error
+	DriverStartBreak(ctx context.Context, driverID string) error
+	DriverEndBreak(ctx context.Context, driverID string) error
 	UpdateDriverLocation(ctx context.Context, driverID string, latitude, longitude, accuracy, speed, heading float64, address string) (string, error)
+	UpdateDriverLocationBatch(ctx context.Context, driverID string, points []LocationUpdate, address string) (string, error)
+	DriverEnRoute(ctx context.Context, driverID, rideID string) error
+	DriverArrived(ctx context.Context, driverID, rideID string) error
 	StartRide(ctx context.Context, driverID, rideID string) error
-	CompleteRide(ctx context.Context, driverID, rideID string, actualDistanceKM float64, actualDurationMin int) (float64, error)
+	CompleteRide(ctx context.Context, driverID, rideID string, actualDistanceKM float64, actualDurationMin int) (*RideEarnings, error)
+	CancelRide(ctx context.Context, driverID, rideID, reason string) error
+	ReportPassengerNoShow(ctx context.Context, driverID, rideID string) (*NoShow, error)
+	GetCurrentRide(ctx context.Context, driverID string) (*CurrentRide, error)
+	GetLocationHistory(ctx context.Context, driverID string, q LocationHistoryQuery) (*LocationHistoryPage, error)
+	ListShifts(ctx context.Context, driverID string, limit, offset int) (*ShiftPage, error)
+	FindNearbyDrivers(ctx context.Context, latitude, longitude float64, vehicleType string, radiusKm float64, minSeats, limit int) ([]*NearbyDriver, error)
+	IsDriverConnected(driverID string) bool
+	GetDemandHeatmap(ctx context.Context, box BoundingBox, cellSizeDeg float64) (*Heatmap, error)
 	HandleRideMatchingRequest(ctx context.Context, req *RideMatchingRequest) error
 	HandleRideStatusUpdate(ctx context.Context, rideID string, driverID string, status string, finalFare float64) error
+	HandleRideMessage(ctx context.Context, msg *RideMessage) error
+	HandleRideDestinationChange(ctx context.Context, change *RideDestinationChange) error
+	HandleDriverControl(ctx context.Context, msg *DriverControl) error
 	HandleDriverRideResponse(ctx context.Context, driverID, offerID, rideID string, accepted bool) error
+	AcknowledgeOffer(driverID, offerID string) error
+	ListDriverOffers(driverID string) []*DriverOffer
+	OfferStats() OfferStats
 }
 
 // DriverLocationPublisher handles publishing events to message queues
@@ -59,6 +96,16 @@ type DriverLocationPublisher interface {
 type DriverLocationSubscriber interface {
 	ConsumeDriverMatching(ctx context.Context, handler func(amqp.Delivery)) error
 	ConsumeRideStatus(ctx context.Context, handler func(amqp.Delivery)) error
+	ConsumeRideMessages(ctx context.Context, handler func(amqp.Delivery)) error
+	ConsumeRideDestinations(ctx context.Context, handler func(amqp.Delivery)) error
+	ConsumeDriverControl(ctx context.Context, handler func(amqp.Delivery)) error
+}
+
+// ShiftSummaryNotifier delivers a driver's shift summary once it is stored, e.g.
+// by email. It is called while the go-offline request waits, so a slow channel
+// should queue the delivery.
+type ShiftSummaryNotifier interface {
+	NotifyShiftSummary(ctx context.Context, summary *ShiftSummary) error
 }
 
 // WebSocketManager manages WebSocket connections for drivers
@@ -66,6 +113,11 @@ type WebSocketManager interface {
 	SendRideOffer(driverID string, offer interface{}) error
 	SendRideDetails(driverID string, details interface{}) error
 	SendRideCancelled(driverID string, rideID string) error
+	SendRideStatus(driverID string, update interface{}) error
+	SendOfferCancelled(driverID, offerID, rideID, reason string) error
+	SendRideMessage(driverID string, message interface{}) error
+	SendDestinationChanged(driverID string, change interface{}) error
 	BroadcastToAll(message interface{}) error
 	IsDriverConnected(driverID string) bool
+	ForceDisconnect(driverID string)
 }
@@ -5,7 +5,9 @@ import (
 	"fmt"
 
 	"ride-hail/internal/ride-service/domain"
+	"ride-hail/pkg/apitime"
 	"ride-hail/pkg/logger"
+	"ride-hail/pkg/wsmsg"
 )
 
 // CancelRideCommand represents the input for cancelling a ride
@@ -15,11 +17,26 @@ type CancelRideCommand struct {
 	Reason      string
 }
 
+// CancellationDTO is the result of a cancellation
+type CancellationDTO struct {
+	RideID      string       `json:"ride_id"`
+	Status      string       `json:"status"`
+	Reason      string       `json:"reason"`
+	CancelledBy string       `json:"cancelled_by"`
+	CancelledAt apitime.Time `json:"cancelled_at"`
+}
+
+// RideStreams ends the live update streams (SSE) open for a ride
+type RideStreams interface {
+	End(rideID string, eventType wsmsg.Type, data interface{})
+}
+
 // CancelRideUseCase handles the business workflow for cancelling a ride
 type CancelRideUseCase struct {
 	rideRepo       domain.RideRepository
 	eventPublisher EventPublisher
 	logger         logger.Logger
+	streams        RideStreams
 }
 
 // NewCancelRideUseCase creates a new use case instance
@@ -35,8 +52,15 @@ func NewCancelRideUseCase(
 	}
 }
 
+// SetRideStreams sets where the final status of a cancelled ride is sent so open
+// ride streams close. The ride service's own consumer never sees a passenger's
+// cancellation, so nothing else would end them.
+func (uc *CancelRideUseCase) SetRideStreams(streams RideStreams) {
+	uc.streams = streams
+}
+
 // Execute runs the use case
-func (uc *CancelRideUseCase) Execute(ctx context.Context, cmd CancelRideCommand) error {
+func (uc *CancelRideUseCase) Execute(ctx context.Context, cmd CancelRideCommand) (*CancellationDTO, error) {
 	// 1. Retrieve ride and verify ownership
 	ride, err := uc.rideRepo.FindByPassenger(ctx, cmd.RideID, cmd.PassengerID)
 	if err != nil {
@@ -44,7 +68,7 @@ func (uc *CancelRideUseCase) Execute(ctx context.Context, cmd CancelRideCommand)
 			"ride_id":      cmd.RideID,
 			"passenger_id": cmd.PassengerID,
 		}).Error("ride_not_found", err)
-		return fmt.Errorf("ride not found: %w", err)
+		return nil, fmt.Errorf("find ride: %w", err)
 	}
 
 	uc.logger.WithFields(logger.LogFields{
@@ -54,18 +78,18 @@ func (uc *CancelRideUseCase) Execute(ctx context.Context, cmd CancelRideCommand)
 	}).Info("ride_retrieved", "Ride retrieved for cancellation")
 
 	// 2. Cancel ride (domain logic)
-	if err := ride.Cancel(cmd.Reason); err != nil {
+	if err := ride.Cancel(cmd.Reason, domain.CancelledByPassenger); err != nil {
 		uc.logger.WithFields(logger.LogFields{
 			"ride_id": cmd.RideID,
 			"status":  ride.Status().String(),
 		}).Error("cancel_ride_failed", err)
-		return fmt.Errorf("cannot cancel ride: %w", err)
+		return nil, fmt.Errorf("cannot cancel ride: %w", err)
 	}
 
 	// 3. Persist changes
 	if err := uc.rideRepo.Update(ctx, ride); err != nil {
 		uc.logger.Error("update_ride_failed", err)
-		return fmt.Errorf("failed to update ride: %w", err)
+		return nil, fmt.Errorf("failed to update ride: %w", err)
 	}
 
 	uc.logger.WithFields(logger.LogFields{
@@ -73,15 +97,22 @@ func (uc *CancelRideUseCase) Execute(ctx context.Context, cmd CancelRideCommand)
 		"reason":  cmd.Reason,
 	}).Info("ride_cancelled", "Ride cancelled successfully")
 
-	// 4. Publish cancellation event
+	// 4. Record and publish the cancellation; neither failure undoes it
 	event := domain.RideCancelledEvent{
 		RideID:      ride.ID(),
 		PassengerID: ride.PassengerID(),
 		DriverID:    ride.DriverID(),
 		Reason:      cmd.Reason,
+		CancelledBy: ride.CancelledBy(),
 		CancelledAt: *ride.CancelledAt(),
 	}
 
+	if err := uc.rideRepo.SaveEvent(ctx, ride.ID(), event); err != nil {
+		uc.logger.WithFields(logger.LogFields{
+			"ride_id": cmd.RideID,
+			"error":   err.Error(),
+		}).Error("save_cancellation_event_failed", err)
+	}
 	if err := uc.eventPublisher.Publish(ctx, event); err != nil {
 		// Log error but don't fail the request
 		uc.logger.WithFields(logger.LogFields{
@@ -90,5 +121,21 @@ func (uc *CancelRideUseCase) Execute(ctx context.Context, cmd CancelRideCommand)
 		}).Error("publish_cancellation_event_failed", err)
 	}
 
-	return nil
+	if uc.streams != nil {
+		uc.streams.End(ride.ID(), wsmsg.TypeRideStatusUpdate, wsmsg.NewNotification(wsmsg.TypeRideStatusUpdate, map[string]interface{}{
+			"ride_id":      ride.ID(),
+			"status":       ride.Status().String(),
+			"reason":       ride.CancelReason(),
+			"cancelled_by": ride.CancelledBy(),
+			"timestamp":    apitime.New(*ride.CancelledAt()),
+		}))
+	}
+
+	return &CancellationDTO{
+		RideID:      ride.ID(),
+		Status:      ride.Status().String(),
+		Reason:      ride.CancelReason(),
+		CancelledBy: ride.CancelledBy(),
+		CancelledAt: apitime.New(*ride.CancelledAt()),
+	}, nil
 }
@@ -0,0 +1,152 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ride-hail/internal/ride-service/domain"
+	"ride-hail/pkg/apitime"
+	"ride-hail/pkg/geocode"
+	"ride-hail/pkg/logger"
+)
+
+// ChangeDestinationCommand represents the input for moving a ride's drop-off
+type ChangeDestinationCommand struct {
+	RideID      string
+	PassengerID string
+	Latitude    float64
+	Longitude   float64
+	Address     string
+}
+
+// DestinationDTO is the result of a destination change
+type DestinationDTO struct {
+	RideID                string       `json:"ride_id"`
+	Latitude              float64      `json:"destination_latitude"`
+	Longitude             float64      `json:"destination_longitude"`
+	Address               string       `json:"destination_address"`
+	EstimatedFare         float64      `json:"estimated_fare"`
+	PreviousEstimatedFare float64      `json:"previous_estimated_fare"`
+	ChangedAt             apitime.Time `json:"changed_at"`
+}
+
+// ChangeDestinationUseCase lets a passenger redirect a ride that is under way
+type ChangeDestinationUseCase struct {
+	rideRepo       domain.RideRepository
+	eventPublisher EventPublisher
+	fareCalculator *domain.FareCalculator
+	logger         logger.Logger
+	geocoder       geocode.Geocoder
+	serviceArea    domain.ServiceArea
+}
+
+// NewChangeDestinationUseCase creates a new use case instance
+func NewChangeDestinationUseCase(
+	rideRepo domain.RideRepository,
+	eventPublisher EventPublisher,
+	fareCalculator *domain.FareCalculator,
+	logger logger.Logger,
+) *ChangeDestinationUseCase {
+	return &ChangeDestinationUseCase{
+		rideRepo:       rideRepo,
+		eventPublisher: eventPublisher,
+		fareCalculator: fareCalculator,
+		logger:         logger,
+		geocoder:       geocode.Noop{},
+	}
+}
+
+// SetGeocoder sets the reverse geocoder used to fill an empty destination address
+func (uc *ChangeDestinationUseCase) SetGeocoder(g geocode.Geocoder) {
+	uc.geocoder = g
+}
+
+// SetServiceArea restricts new destinations to the given box
+func (uc *ChangeDestinationUseCase) SetServiceArea(area domain.ServiceArea) {
+	uc.serviceArea = area
+}
+
+// Execute runs the use case
+func (uc *ChangeDestinationUseCase) Execute(ctx context.Context, cmd ChangeDestinationCommand) (*DestinationDTO, error) {
+	// 1. Retrieve ride and verify ownership
+	ride, err := uc.rideRepo.FindByPassenger(ctx, cmd.RideID, cmd.PassengerID)
+	if err != nil {
+		return nil, fmt.Errorf("find ride: %w", err)
+	}
+
+	// 2. Validate the new destination
+	dest, err := domain.NewCoordinate(
+		cmd.Latitude,
+		cmd.Longitude,
+		geocode.FillAddress(uc.geocoder, cmd.Address, cmd.Latitude, cmd.Longitude),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid destination location: %w", err)
+	}
+	if !uc.serviceArea.Contains(dest) {
+		return nil, fmt.Errorf("destination: %w", domain.ErrOutsideServiceArea)
+	}
+
+	// 3. Re-estimate from the pickup, as the original fare was
+	oldDest := ride.DestLocation()
+	oldFare := ride.EstimatedFare()
+	fare := uc.fareCalculator.Calculate(ride.PickupLocation(), dest, ride.RideTypeValue())
+	if err := ride.ChangeDestination(dest, fare); err != nil {
+		return nil, err
+	}
+
+	// 4. Persist changes
+	if err := uc.rideRepo.UpdateDestination(ctx, ride); err != nil {
+		return nil, fmt.Errorf("failed to update destination: %w", err)
+	}
+
+	driverID := ""
+	if ride.DriverID() != nil {
+		driverID = *ride.DriverID()
+	}
+	event := domain.RideDestinationChangedEvent{
+		RideID:         ride.ID(),
+		PassengerID:    ride.PassengerID(),
+		DriverID:       driverID,
+		OldDestination: oldDest,
+		NewDestination: dest,
+		OldFare:        oldFare,
+		NewFare:        fare,
+		ChangedAt:      time.Now(),
+	}
+
+	uc.logger.WithFields(logger.LogFields{
+		"ride_id":   ride.ID(),
+		"driver_id": driverID,
+		"dest_lat":  dest.Latitude(),
+		"dest_lng":  dest.Longitude(),
+		"old_fare":  oldFare,
+		"new_fare":  fare,
+	}).Info("ride_destination_changed", "Ride destination changed")
+
+	// 5. Record and publish; the new destination is already stored, so neither
+	// failure undoes it
+	if err := uc.rideRepo.SaveEvent(ctx, ride.ID(), event); err != nil {
+		uc.logger.WithFields(logger.LogFields{
+			"ride_id": ride.ID(),
+			"error":   err.Error(),
+		}).Error("save_destination_event_failed", err)
+	}
+	if err := uc.eventPublisher.Publish(ctx, event); err != nil {
+		uc.logger.WithFields(logger.LogFields{
+			"ride_id": ride.ID(),
+			"error":   err.Error(),
+		}).Error("publish_destination_event_failed", err)
+	}
+
+	return &DestinationDTO{
+		RideID:                ride.ID(),
+		Latitude:              dest.Latitude(),
+		Longitude:             dest.Longitude(),
+		Address:               dest.Address(),
+		EstimatedFare:         fare,
+		PreviousEstimatedFare: oldFare,
+		ChangedAt:             apitime.New(event.ChangedAt),
+	}, nil
+}
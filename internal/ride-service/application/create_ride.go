@@ -7,7 +7,10 @@ import (
 	"time"
 
 	"ride-hail/internal/ride-service/domain"
+	"ride-hail/pkg/apitime"
+	"ride-hail/pkg/geocode"
 	"ride-hail/pkg/logger"
+	"ride-hail/pkg/ratelimit"
 )
 
 // generateUUID generates a UUID v4 string using crypto/rand
@@ -29,6 +32,7 @@ type CreateRideCommand struct {
 	DestinationLongitude float64
 	DestinationAddress   string
 	RideType             string
+	PassengerCount       int // 0 means a single passenger
 }
 
 // RideDTO represents the output data transfer object
@@ -47,12 +51,30 @@ type EventPublisher interface {
 	Publish(ctx context.Context, event domain.DomainEvent) error
 }
 
+// Default ride creation rate limit: rideRequestLimit requests per passenger per window
+const (
+	rideRequestLimit  = 3
+	rideRequestWindow = time.Minute
+)
+
 // CreateRideUseCase handles the business workflow for creating a ride
 type CreateRideUseCase struct {
 	rideRepo       domain.RideRepository
 	eventPublisher EventPublisher
 	fareCalculator *domain.FareCalculator
 	logger         logger.Logger
+	geocoder       geocode.Geocoder
+
+	// Rides must start and end inside serviceArea and be between minDistanceKm and
+	// maxDistanceKm long (0 = no limit)
+	serviceArea   domain.ServiceArea
+	minDistanceKm float64
+	maxDistanceKm float64
+
+	// At most requestLimit ride requests per passenger in any requestWindow
+	requestLimiter ratelimit.RateLimiter
+	requestLimit   int
+	requestWindow  time.Duration
 }
 
 // NewCreateRideUseCase creates a new use case instance
@@ -67,16 +89,106 @@ func NewCreateRideUseCase(
 		eventPublisher: eventPublisher,
 		fareCalculator: fareCalculator,
 		logger:         logger,
+		geocoder:       geocode.Noop{},
+		requestLimiter: ratelimit.NewMemoryRateLimiter(),
+		requestLimit:   rideRequestLimit,
+		requestWindow:  rideRequestWindow,
+	}
+}
+
+// SetRequestLimit allows a passenger at most limit ride requests per window,
+// counted by limiter (e.g. a Redis-backed one shared by all instances); a
+// limit of 0 turns the check off
+func (uc *CreateRideUseCase) SetRequestLimit(limiter ratelimit.RateLimiter, limit int, window time.Duration) {
+	uc.requestLimiter = limiter
+	uc.requestLimit = limit
+	uc.requestWindow = window
+}
+
+// SetGeocoder sets the reverse geocoder used to fill empty pickup/destination addresses
+func (uc *CreateRideUseCase) SetGeocoder(g geocode.Geocoder) {
+	uc.geocoder = g
+}
+
+// SetServiceArea restricts pickups and destinations to the given box
+func (uc *CreateRideUseCase) SetServiceArea(area domain.ServiceArea) {
+	uc.serviceArea = area
+}
+
+// SetMaxDistance sets the longest straight-line ride accepted, in km; 0 removes the limit
+func (uc *CreateRideUseCase) SetMaxDistance(km float64) {
+	uc.maxDistanceKm = km
+}
+
+// SetMinDistance sets the shortest straight-line ride accepted, in km; 0 only
+// rejects identical pickup and destination
+func (uc *CreateRideUseCase) SetMinDistance(km float64) {
+	uc.minDistanceKm = km
+}
+
+// checkServiceLimits rejects rides that leave the service area or are too short or long to serve
+func (uc *CreateRideUseCase) checkServiceLimits(pickup, dest domain.Coordinate) error {
+	if !uc.serviceArea.Contains(pickup) {
+		return fmt.Errorf("pickup: %w", domain.ErrOutsideServiceArea)
 	}
+	if !uc.serviceArea.Contains(dest) {
+		return fmt.Errorf("destination: %w", domain.ErrOutsideServiceArea)
+	}
+	d := pickup.DistanceTo(dest)
+	if d == 0 || d < uc.minDistanceKm {
+		return fmt.Errorf("%w: %.0f m is under the %.0f m minimum", domain.ErrRideTooShort, d*1000, uc.minDistanceKm*1000)
+	}
+	if uc.maxDistanceKm > 0 && d > uc.maxDistanceKm {
+		return fmt.Errorf("%w: %.1f km is over the %.0f km limit", domain.ErrRideTooLong, d, uc.maxDistanceKm)
+	}
+	return nil
+}
+
+// allowRequest records a ride request and reports whether the passenger is
+// within the limit. A limiter failure lets the request through: the active-ride
+// check still stops duplicates.
+func (uc *CreateRideUseCase) allowRequest(ctx context.Context, passengerID string) bool {
+	if uc.requestLimit <= 0 || uc.requestWindow <= 0 {
+		return true
+	}
+	allowed, err := uc.requestLimiter.AllowN(ctx, passengerID, uc.requestLimit, uc.requestWindow)
+	if err != nil {
+		uc.logger.WithFields(logger.LogFields{
+			"passenger_id": passengerID,
+		}).Error("ride_rate_limit_failed", err)
+		return true
+	}
+	return allowed
 }
 
 // Execute runs the use case
 func (uc *CreateRideUseCase) Execute(ctx context.Context, cmd CreateRideCommand) (*RideDTO, error) {
+	// 0. Guard against request floods and duplicate active rides
+	if !uc.allowRequest(ctx, cmd.PassengerID) {
+		uc.logger.WithFields(logger.LogFields{
+			"passenger_id": cmd.PassengerID,
+		}).Error("ride_rate_limited", domain.ErrTooManyRideRequests)
+		return nil, domain.ErrTooManyRideRequests
+	}
+
+	activeRides, err := uc.rideRepo.FindActiveByPassenger(ctx, cmd.PassengerID)
+	if err != nil {
+		uc.logger.Error("find_active_rides_failed", err)
+		return nil, fmt.Errorf("failed to check active rides: %w", err)
+	}
+	if len(activeRides) > 0 {
+		uc.logger.WithFields(logger.LogFields{
+			"passenger_id": cmd.PassengerID,
+			"ride_id":      activeRides[0].ID(),
+		}).Error("active_ride_exists", domain.ErrActiveRideExists)
+		return nil, domain.ErrActiveRideExists
+	}
+
 	// 1. Validate and create pickup coordinate
 	pickup, err := domain.NewCoordinate(
 		cmd.PickupLatitude,
 		cmd.PickupLongitude,
-		cmd.PickupAddress,
+		geocode.FillAddress(uc.geocoder, cmd.PickupAddress, cmd.PickupLatitude, cmd.PickupLongitude),
 	)
 	if err != nil {
 		uc.logger.Error("invalid_pickup_coordinate", err)
@@ -87,19 +199,32 @@ func (uc *CreateRideUseCase) Execute(ctx context.Context, cmd CreateRideCommand)
 	dest, err := domain.NewCoordinate(
 		cmd.DestinationLatitude,
 		cmd.DestinationLongitude,
-		cmd.DestinationAddress,
+		geocode.FillAddress(uc.geocoder, cmd.DestinationAddress, cmd.DestinationLatitude, cmd.DestinationLongitude),
 	)
 	if err != nil {
 		uc.logger.Error("invalid_destination_coordinate", err)
 		return nil, fmt.Errorf("invalid destination location: %w", err)
 	}
 
+	if err := uc.checkServiceLimits(pickup, dest); err != nil {
+		uc.logger.WithFields(logger.LogFields{
+			"passenger_id": cmd.PassengerID,
+		}).Error("ride_outside_service_limits", err)
+		return nil, err
+	}
+
 	// 3. Validate ride type
 	rideType := domain.RideType(cmd.RideType)
 	if !rideType.IsValid() {
 		uc.logger.Error("invalid_ride_type", domain.ErrInvalidRideType)
 		return nil, domain.ErrInvalidRideType
 	}
+	if err := uc.fareCalculator.CheckPricing(rideType); err != nil {
+		uc.logger.WithFields(logger.LogFields{
+			"ride_type": cmd.RideType,
+		}).Error("ride_type_not_priced", err)
+		return nil, err
+	}
 
 	// 4. Calculate estimated fare using domain service
 	estimatedFare := uc.fareCalculator.Calculate(pickup, dest, rideType)
@@ -110,28 +235,25 @@ func (uc *CreateRideUseCase) Execute(ctx context.Context, cmd CreateRideCommand)
 		"estimated_fare": estimatedFare,
 	}).Info("fare_calculated", "Estimated fare calculated")
 
-	// 5. Get today's ride count for ride number generation
-	todayRideCount, err := uc.rideRepo.GetTodayRideCount(ctx)
-	if err != nil {
-		uc.logger.Error("get_today_ride_count_failed", err)
-		return nil, fmt.Errorf("failed to get today's ride count: %w", err)
-	}
-
-	// 6. Create ride domain entity
+	// 5. Create ride domain entity
 	ride, err := domain.NewRide(
 		cmd.PassengerID,
 		pickup,
 		dest,
 		rideType,
 		estimatedFare,
-		todayRideCount,
 	)
 	if err != nil {
 		uc.logger.Error("create_ride_entity_failed", err)
 		return nil, fmt.Errorf("failed to create ride: %w", err)
 	}
+	if cmd.PassengerCount != 0 {
+		if err := ride.SetPassengerCount(cmd.PassengerCount); err != nil {
+			return nil, err
+		}
+	}
 
-	// 7. Generate and set ride ID
+	// 6. Generate and set ride ID
 	rideID := generateUUID()
 	ride.SetID(rideID)
 
@@ -140,7 +262,7 @@ func (uc *CreateRideUseCase) Execute(ctx context.Context, cmd CreateRideCommand)
 		"passenger_id": cmd.PassengerID,
 	}).Info("ride_entity_created", "Ride entity created")
 
-	// 8. Persist ride (infrastructure layer)
+	// 7. Persist ride (infrastructure layer); this also allocates the ride number
 	if err := uc.rideRepo.Save(ctx, ride); err != nil {
 		uc.logger.Error("save_ride_failed", err)
 		return nil, fmt.Errorf("failed to save ride: %w", err)
@@ -150,7 +272,7 @@ func (uc *CreateRideUseCase) Execute(ctx context.Context, cmd CreateRideCommand)
 		"ride_id": rideID,
 	}).Info("ride_persisted", "Ride saved to database")
 
-	// 9. Publish domain event (for async processing)
+	// 8. Publish domain event (for async processing)
 	event := domain.RideRequestedEvent{
 		RideID:      ride.ID(),
 		PassengerID: ride.PassengerID(),
@@ -158,6 +280,7 @@ func (uc *CreateRideUseCase) Execute(ctx context.Context, cmd CreateRideCommand)
 		Destination: ride.DestLocation(),
 		RideType:    ride.RideTypeValue(),
 		Fare:        ride.EstimatedFare(),
+		Passengers:  ride.PassengerCount(),
 		RequestedAt: ride.RequestedAt(),
 	}
 
@@ -174,7 +297,7 @@ func (uc *CreateRideUseCase) Execute(ctx context.Context, cmd CreateRideCommand)
 		}).Info("event_published", "Domain event published")
 	}
 
-	// 10. Return DTO
+	// 9. Return DTO
 	return toRideDTO(ride), nil
 }
 
@@ -187,6 +310,6 @@ func toRideDTO(ride *domain.Ride) *RideDTO {
 		Status:        ride.Status().String(),
 		RideType:      ride.RideTypeValue().String(),
 		EstimatedFare: ride.EstimatedFare(),
-		RequestedAt:   ride.RequestedAt().Format(time.RFC3339),
+		RequestedAt:   apitime.Format(ride.RequestedAt()),
 	}
 }
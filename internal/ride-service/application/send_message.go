@@ -0,0 +1,129 @@
+package application
+
+import (
+	"context"
+	"fmt"
+
+	"ride-hail/internal/ride-service/domain"
+	"ride-hail/pkg/apitime"
+	"ride-hail/pkg/logger"
+	"ride-hail/pkg/wsmsg"
+)
+
+// SendMessageCommand represents the input for sending a ride chat message
+type SendMessageCommand struct {
+	RideID   string
+	SenderID string
+	Text     string
+}
+
+// MessageDTO is a sent chat message. Delivered reports whether it was handed
+// to the recipient's channel; undelivered messages are still kept in the thread.
+type MessageDTO struct {
+	ID          string       `json:"id"`
+	RideID      string       `json:"ride_id"`
+	SenderID    string       `json:"sender_id"`
+	SenderRole  string       `json:"sender_role"`
+	RecipientID string       `json:"recipient_id"`
+	Text        string       `json:"text"`
+	SentAt      apitime.Time `json:"sent_at"`
+	Delivered   bool         `json:"delivered"`
+}
+
+// PassengerNotifier pushes a message to a passenger's WebSocket
+type PassengerNotifier interface {
+	SendToUser(userID string, message interface{}) error
+}
+
+// SendMessageUseCase relays chat messages between a ride's passenger and driver.
+// Passengers are reached over the ride service's own WebSocket; drivers are
+// connected to the driver location service, so their messages go through RabbitMQ.
+type SendMessageUseCase struct {
+	rideRepo       domain.RideRepository
+	eventPublisher EventPublisher
+	passengers     PassengerNotifier
+	logger         logger.Logger
+}
+
+// NewSendMessageUseCase creates a new use case instance
+func NewSendMessageUseCase(
+	rideRepo domain.RideRepository,
+	eventPublisher EventPublisher,
+	passengers PassengerNotifier,
+	logger logger.Logger,
+) *SendMessageUseCase {
+	return &SendMessageUseCase{
+		rideRepo:       rideRepo,
+		eventPublisher: eventPublisher,
+		passengers:     passengers,
+		logger:         logger,
+	}
+}
+
+// Execute runs the use case
+func (uc *SendMessageUseCase) Execute(ctx context.Context, cmd SendMessageCommand) (*MessageDTO, error) {
+	// 1. Retrieve ride and check the sender is one of its parties
+	ride, err := uc.rideRepo.FindByID(ctx, cmd.RideID)
+	if err != nil {
+		return nil, fmt.Errorf("find ride: %w", err)
+	}
+	msg, err := domain.NewRideMessage(ride, cmd.SenderID, cmd.Text)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Persist before delivering so the thread is complete even if the
+	// recipient is offline
+	if err := uc.rideRepo.SaveMessage(ctx, msg); err != nil {
+		return nil, fmt.Errorf("save message: %w", err)
+	}
+
+	// 3. Deliver to the other party
+	log := uc.logger.WithFields(logger.LogFields{
+		"ride_id":      msg.RideID,
+		"message_id":   msg.ID,
+		"sender_role":  msg.SenderRole,
+		"recipient_id": msg.RecipientID,
+	})
+	delivered := true
+	if msg.SenderRole == domain.SenderDriver {
+		notification := wsmsg.NewNotification(wsmsg.TypeRideMessage, map[string]interface{}{
+			"message_id":  msg.ID,
+			"ride_id":     msg.RideID,
+			"sender_id":   msg.SenderID,
+			"sender_role": msg.SenderRole,
+			"text":        msg.Text,
+			"sent_at":     apitime.Format(msg.SentAt),
+		})
+		if err := uc.passengers.SendToUser(msg.RecipientID, notification); err != nil {
+			log.Error("ride_message_delivery_failed", err)
+			delivered = false
+		}
+	} else {
+		event := domain.RideMessageSentEvent{
+			MessageID:   msg.ID,
+			RideID:      msg.RideID,
+			SenderID:    msg.SenderID,
+			SenderRole:  msg.SenderRole,
+			RecipientID: msg.RecipientID,
+			Text:        msg.Text,
+			SentAt:      msg.SentAt,
+		}
+		if err := uc.eventPublisher.Publish(ctx, event); err != nil {
+			log.Error("publish_ride_message_failed", err)
+			delivered = false
+		}
+	}
+	log.Info("ride_message_sent", "Ride message relayed")
+
+	return &MessageDTO{
+		ID:          msg.ID,
+		RideID:      msg.RideID,
+		SenderID:    msg.SenderID,
+		SenderRole:  msg.SenderRole,
+		RecipientID: msg.RecipientID,
+		Text:        msg.Text,
+		SentAt:      apitime.New(msg.SentAt),
+		Delivered:   delivered,
+	}, nil
+}
@@ -2,7 +2,8 @@ package domain
 
 import (
 	"errors"
-	"math"
+
+	"ride-hail/pkg/geo"
 )
 
 // Coordinate errors
@@ -56,7 +57,12 @@ func (c Coordinate) Validate() error {
 // DistanceTo calculates the distance to another coordinate in kilometers
 // Uses the Haversine formula
 func (c Coordinate) DistanceTo(other Coordinate) float64 {
-	return haversineDistance(c.latitude, c.longitude, other.latitude, other.longitude)
+	return geo.HaversineKm(c.latitude, c.longitude, other.latitude, other.longitude)
+}
+
+// BearingTo returns the initial compass bearing to another coordinate in degrees
+func (c Coordinate) BearingTo(other Coordinate) float64 {
+	return geo.BearingDegrees(c.latitude, c.longitude, other.latitude, other.longitude)
 }
 
 // Getters (encapsulation - coordinates are immutable)
@@ -64,34 +70,6 @@ func (c Coordinate) Latitude() float64  { return c.latitude }
 func (c Coordinate) Longitude() float64 { return c.longitude }
 func (c Coordinate) Address() string    { return c.address }
 
-// haversineDistance calculates the distance between two points on Earth
-// Returns distance in kilometers
-func haversineDistance(lat1, lng1, lat2, lng2 float64) float64 {
-	const earthRadius = 6371.0 // Earth's radius in kilometers
-
-	// Convert degrees to radians
-	dLat := toRadians(lat2 - lat1)
-	dLng := toRadians(lng2 - lng1)
-
-	lat1Rad := toRadians(lat1)
-	lat2Rad := toRadians(lat2)
-
-	// Haversine formula
-	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
-		math.Cos(lat1Rad)*math.Cos(lat2Rad)*
-			math.Sin(dLng/2)*math.Sin(dLng/2)
-
-	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
-
-	distance := earthRadius * c
-	return distance
-}
-
-// toRadians converts degrees to radians
-func toRadians(degrees float64) float64 {
-	return degrees * math.Pi / 180
-}
-
 // ValidateCoordinates is a helper function for validation
 func ValidateCoordinates(lat, lng float64) error {
 	if lat < -90 || lat > 90 {
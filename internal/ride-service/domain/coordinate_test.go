@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"math"
+	"testing"
+)
+
+func mustCoordinate(t *testing.T, lat, lng float64) Coordinate {
+	t.Helper()
+	c, err := NewCoordinate(lat, lng, "")
+	if err != nil {
+		t.Fatalf("NewCoordinate(%v, %v): %v", lat, lng, err)
+	}
+	return c
+}
+
+func TestCoordinateDistanceAndBearing(t *testing.T) {
+	london := mustCoordinate(t, 51.5074, -0.1278)
+	paris := mustCoordinate(t, 48.8566, 2.3522)
+	almaty := mustCoordinate(t, 43.2389, 76.8897)
+	astana := mustCoordinate(t, 51.1694, 71.4491)
+
+	tests := []struct {
+		name        string
+		from, to    Coordinate
+		km, bearing float64
+	}{
+		{"London to Paris", london, paris, 343.6, 148.1},
+		{"Paris to London", paris, london, 343.6, 330.0},
+		{"Almaty to Astana", almaty, astana, 972.3, 337.0},
+		{"same point", almaty, almaty, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.from.DistanceTo(tt.to); math.Abs(got-tt.km) > 0.5 {
+				t.Errorf("DistanceTo = %.2f km, want %.1f", got, tt.km)
+			}
+			if got := tt.from.BearingTo(tt.to); math.Abs(got-tt.bearing) > 0.1 {
+				t.Errorf("BearingTo = %.2f°, want %.1f", got, tt.bearing)
+			}
+		})
+	}
+}
+
+func TestNewCoordinateRejectsInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		lat, lng float64
+		want     error
+	}{
+		{"latitude too high", 90.5, 10, ErrInvalidLatitude},
+		{"longitude too low", 10, -180.5, ErrInvalidLongitude},
+		{"null island", 0, 0, ErrZeroCoordinates},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewCoordinate(tt.lat, tt.lng, ""); err != tt.want {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -16,6 +16,7 @@ type RideRequestedEvent struct {
 	Destination Coordinate
 	RideType    RideType
 	Fare        float64
+	Passengers  int // Seats the matched vehicle must have
 	RequestedAt time.Time
 }
 
@@ -49,6 +50,8 @@ type RideCancelledEvent struct {
 	PassengerID string
 	DriverID    *string
 	Reason      string
+	CancelledBy string  // PASSENGER, DRIVER, ...
+	Fee         float64 // Charged to the passenger, e.g. for a no-show
 	CancelledAt time.Time
 }
 
@@ -92,3 +95,61 @@ func (e RideStatusChangedEvent) EventType() string {
 func (e RideStatusChangedEvent) OccurredAt() time.Time {
 	return e.ChangedAt
 }
+
+// DriverAssignmentExpiredEvent is raised when a matched driver never set off for
+// the pickup in time and the ride was sent back to matching
+type DriverAssignmentExpiredEvent struct {
+	RideID    string
+	DriverID  string
+	Timeout   time.Duration
+	ExpiredAt time.Time
+}
+
+func (e DriverAssignmentExpiredEvent) EventType() string {
+	return "ride.assignment_expired"
+}
+
+func (e DriverAssignmentExpiredEvent) OccurredAt() time.Time {
+	return e.ExpiredAt
+}
+
+// RideMessageSentEvent is raised when a passenger sends a chat message to the
+// assigned driver; it is relayed to the driver's WebSocket by the driver service
+type RideMessageSentEvent struct {
+	MessageID   string
+	RideID      string
+	SenderID    string
+	SenderRole  string
+	RecipientID string
+	Text        string
+	SentAt      time.Time
+}
+
+func (e RideMessageSentEvent) EventType() string {
+	return "ride.message.sent"
+}
+
+func (e RideMessageSentEvent) OccurredAt() time.Time {
+	return e.SentAt
+}
+
+// RideDestinationChangedEvent is raised when the passenger moves the drop-off of
+// a ride in progress; the driver service pushes it to the driver's WebSocket
+type RideDestinationChangedEvent struct {
+	RideID         string
+	PassengerID    string
+	DriverID       string
+	OldDestination Coordinate
+	NewDestination Coordinate
+	OldFare        float64
+	NewFare        float64
+	ChangedAt      time.Time
+}
+
+func (e RideDestinationChangedEvent) EventType() string {
+	return "ride.destination.changed"
+}
+
+func (e RideDestinationChangedEvent) OccurredAt() time.Time {
+	return e.ChangedAt
+}
@@ -1,34 +1,41 @@
 package domain
 
+import (
+	"errors"
+	"fmt"
+
+	"ride-hail/pkg/pricing"
+)
+
+// ErrRideTypeNotPriced is returned for a ride type with no fare rates configured
+var ErrRideTypeNotPriced = errors.New("ride type has no pricing configured")
+
 // FareCalculator is a domain service for calculating ride fares
 // Domain services contain business logic that doesn't naturally fit in an entity
 type FareCalculator struct {
-	baseFares  map[RideType]float64
-	perKmRates map[RideType]float64
+	table *pricing.Table
 }
 
 // NewFareCalculator creates a new fare calculator with default rates
 func NewFareCalculator() *FareCalculator {
-	return &FareCalculator{
-		baseFares: map[RideType]float64{
-			RideTypeEconomy: 100.0, // Base fare in currency units
-			RideTypePremium: 150.0,
-			RideTypeLuxury:  250.0,
-		},
-		perKmRates: map[RideType]float64{
-			RideTypeEconomy: 15.0, // Per kilometer rate
-			RideTypePremium: 25.0,
-			RideTypeLuxury:  40.0,
-		},
-	}
+	return NewFareCalculatorWithTable(pricing.DefaultTable())
 }
 
-// NewFareCalculatorWithRates creates a fare calculator with custom rates
-func NewFareCalculatorWithRates(baseFares, perKmRates map[RideType]float64) *FareCalculator {
-	return &FareCalculator{
-		baseFares:  baseFares,
-		perKmRates: perKmRates,
+// NewFareCalculatorWithTable creates a fare calculator over a configured table,
+// the same one the driver service prices completed rides with. Ride types the
+// table has no rates for are priced as pricing.FallbackRideType; CreateRide
+// rejects them through CheckPricing before they get that far.
+func NewFareCalculatorWithTable(table *pricing.Table) *FareCalculator {
+	return &FareCalculator{table: table}
+}
+
+// CheckPricing returns ErrRideTypeNotPriced if rideType has no rates, or only
+// zero rates, so a ride of that type would be free
+func (fc *FareCalculator) CheckPricing(rideType RideType) error {
+	if !fc.table.Priced(rideType.String()) {
+		return fmt.Errorf("%w: %s", ErrRideTypeNotPriced, rideType)
 	}
+	return nil
 }
 
 // Calculate calculates the estimated fare for a ride
@@ -39,34 +46,28 @@ func (fc *FareCalculator) Calculate(pickup, dest Coordinate, rideType RideType)
 
 // CalculateByDistance calculates fare based on distance and ride type
 func (fc *FareCalculator) CalculateByDistance(distanceKm float64, rideType RideType) float64 {
-	baseFare, ok := fc.baseFares[rideType]
-	if !ok {
-		baseFare = fc.baseFares[RideTypeEconomy] // Default to economy
-	}
-
-	perKm, ok := fc.perKmRates[rideType]
-	if !ok {
-		perKm = fc.perKmRates[RideTypeEconomy] // Default to economy
-	}
+	return fc.table.Fare(fc.ratesFor(rideType), distanceKm)
+}
 
-	totalFare := baseFare + (distanceKm * perKm)
-	return totalFare
+// EstimateDurationMinutes estimates trip time at the configured average speed
+func (fc *FareCalculator) EstimateDurationMinutes(distanceKm float64) float64 {
+	return fc.table.EstimateMinutes(distanceKm)
 }
 
 // GetBaseFare returns the base fare for a ride type
 func (fc *FareCalculator) GetBaseFare(rideType RideType) float64 {
-	if fare, ok := fc.baseFares[rideType]; ok {
-		return fare
-	}
-	return fc.baseFares[RideTypeEconomy]
+	return fc.ratesFor(rideType).Base
 }
 
 // GetPerKmRate returns the per kilometer rate for a ride type
 func (fc *FareCalculator) GetPerKmRate(rideType RideType) float64 {
-	if rate, ok := fc.perKmRates[rideType]; ok {
-		return rate
-	}
-	return fc.perKmRates[RideTypeEconomy]
+	return fc.ratesFor(rideType).PerKm
+}
+
+// ratesFor returns the rates for a ride type, or the fallback type's
+func (fc *FareCalculator) ratesFor(rideType RideType) pricing.Rates {
+	rates, _ := fc.table.RatesFor(rideType.String())
+	return rates
 }
 
 // CalculateFare is a convenience function for calculating fare
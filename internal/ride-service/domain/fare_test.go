@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+
+	"ride-hail/pkg/pricing"
+)
+
+func TestCheckPricing(t *testing.T) {
+	table, err := pricing.NewTable(map[string]pricing.Rates{
+		"ECONOMY": {Base: 100, PerKm: 15},
+		"PREMIUM": {},             // zeroed by the operator
+		"LUXURY":  {Minimum: 500}, // a floor alone still charges something
+	}, 30)
+	if err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+	fc := NewFareCalculatorWithTable(table)
+
+	tests := []struct {
+		rideType RideType
+		priced   bool
+	}{
+		{RideTypeEconomy, true},
+		{RideTypeLuxury, true},
+		{RideTypePremium, false},
+		{RideType("SCOOTER"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rideType.String(), func(t *testing.T) {
+			err := fc.CheckPricing(tt.rideType)
+			if tt.priced && err != nil {
+				t.Errorf("CheckPricing = %v, want priced", err)
+			}
+			if !tt.priced && !errors.Is(err, ErrRideTypeNotPriced) {
+				t.Errorf("CheckPricing = %v, want ErrRideTypeNotPriced", err)
+			}
+		})
+	}
+}
+
+func TestDefaultPricingCoversEveryRideType(t *testing.T) {
+	fc := NewFareCalculator()
+	for _, rideType := range []RideType{RideTypeEconomy, RideTypePremium, RideTypeLuxury} {
+		if err := fc.CheckPricing(rideType); err != nil {
+			t.Errorf("%s: %v", rideType, err)
+		}
+	}
+}
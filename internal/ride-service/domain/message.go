@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxMessageLength caps a chat message, in characters
+const MaxMessageLength = 1000
+
+// Who sent a ride message
+const (
+	SenderPassenger = "PASSENGER"
+	SenderDriver    = "DRIVER"
+)
+
+var (
+	ErrNotRideParticipant = errors.New("only the ride's passenger and assigned driver can send messages")
+	ErrMessagingClosed    = errors.New("messages can only be sent while a driver is assigned to an active ride")
+	ErrInvalidMessage     = fmt.Errorf("message text must be 1 to %d characters", MaxMessageLength)
+)
+
+// RideMessage is one chat message between a ride's passenger and driver
+type RideMessage struct {
+	ID          string
+	RideID      string
+	SenderID    string
+	SenderRole  string // SenderPassenger or SenderDriver
+	RecipientID string
+	Text        string
+	SentAt      time.Time
+}
+
+// NewRideMessage checks that senderID may message the other party of the ride
+// and addresses the message to them
+func NewRideMessage(ride *Ride, senderID, text string) (*RideMessage, error) {
+	text = strings.TrimSpace(text)
+	if text == "" || utf8.RuneCountInString(text) > MaxMessageLength {
+		return nil, ErrInvalidMessage
+	}
+
+	msg := &RideMessage{
+		RideID:   ride.ID(),
+		SenderID: senderID,
+		Text:     text,
+		SentAt:   time.Now(),
+	}
+	switch {
+	case senderID == ride.PassengerID():
+		msg.SenderRole = SenderPassenger
+		if ride.HasDriver() {
+			msg.RecipientID = *ride.DriverID()
+		}
+	case ride.HasDriver() && senderID == *ride.DriverID():
+		msg.SenderRole = SenderDriver
+		msg.RecipientID = ride.PassengerID()
+	default:
+		return nil, ErrNotRideParticipant
+	}
+
+	// Before matching there is nobody to talk to, and the thread is closed
+	// once the ride is over
+	if !ride.HasDriver() || !ride.IsActive() || ride.Status() == StatusRequested {
+		return nil, ErrMessagingClosed
+	}
+	return msg, nil
+}
@@ -1,6 +1,9 @@
 package domain
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // RideRepository is the interface (port) for ride persistence
 // This belongs in domain layer - implementation is in infrastructure
@@ -11,6 +14,10 @@ type RideRepository interface {
 	// Update updates an existing ride
 	Update(ctx context.Context, ride *Ride) error
 
+	// UpdateDestination stores a new destination and estimated fare for a ride
+	// that is still in progress
+	UpdateDestination(ctx context.Context, ride *Ride) error
+
 	// FindByID retrieves a ride by its ID
 	FindByID(ctx context.Context, rideID string) (*Ride, error)
 
@@ -20,15 +27,19 @@ type RideRepository interface {
 	// FindActiveByPassenger retrieves active rides for a passenger
 	FindActiveByPassenger(ctx context.Context, passengerID string) ([]*Ride, error)
 
-	// FindByStatus retrieves rides by status
-	FindByStatus(ctx context.Context, status RideStatus) ([]*Ride, error)
+	// FindByStatus retrieves rides in status, oldest request first; a positive
+	// olderThan keeps only rides requested at least that long ago
+	FindByStatus(ctx context.Context, status RideStatus, olderThan time.Duration) ([]*Ride, error)
 
 	// Delete removes a ride (soft delete recommended)
 	Delete(ctx context.Context, rideID string) error
 
-	// GetTodayRideCount returns count of rides created today (for ride number generation)
-	GetTodayRideCount(ctx context.Context) (int, error)
-
 	// SaveEvent saves a domain event to the ride_events table
 	SaveEvent(ctx context.Context, rideID string, event DomainEvent) error
+
+	// FindRoute retrieves the driver positions recorded during a ride, oldest first
+	FindRoute(ctx context.Context, rideID string) ([]RoutePoint, error)
+
+	// SaveMessage persists a chat message, filling in its ID and SentAt
+	SaveMessage(ctx context.Context, msg *RideMessage) error
 }
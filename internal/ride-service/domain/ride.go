@@ -8,14 +8,22 @@ import (
 
 // Domain errors
 var (
+	ErrRideNotFound              = errors.New("ride not found")
 	ErrInvalidCoordinates        = errors.New("invalid coordinates")
 	ErrCannotAssignDriver        = errors.New("cannot assign driver to ride")
 	ErrCannotCancelRide          = errors.New("cannot cancel ride")
 	ErrCannotCancelCompletedRide = errors.New("cannot cancel completed ride")
 	ErrRideAlreadyMatched        = errors.New("ride already matched with driver")
 	ErrInvalidRideType           = errors.New("invalid ride type")
+	ErrActiveRideExists          = errors.New("passenger already has an active ride")
+	ErrTooManyRideRequests       = errors.New("too many ride requests")
+	ErrCannotChangeDestination   = errors.New("destination can only be changed while the ride is in progress")
+	ErrInvalidPassengerCount     = fmt.Errorf("passenger_count must be between 1 and %d", MaxPassengerCount)
 )
 
+// MaxPassengerCount is the most passengers a single ride can carry
+const MaxPassengerCount = 8
+
 // RideStatus represents the state of a ride
 type RideStatus string
 
@@ -44,6 +52,21 @@ func (s RideStatus) IsValid() bool {
 	return false
 }
 
+// Cancellation parties
+const (
+	CancelledByPassenger = "PASSENGER"
+	CancelledByDriver    = "DRIVER"
+	CancelledBySystem    = "SYSTEM"
+	CancelledByAdmin     = "ADMIN"
+)
+
+// ReasonNoDriverFound is the cancellation reason for rides no driver accepted in time
+const ReasonNoDriverFound = "NO_DRIVER_FOUND"
+
+// ReasonExpired is the cancellation reason for ride requests left REQUESTED past
+// the maximum age, however often they were re-matched
+const ReasonExpired = "EXPIRED"
+
 // RideType represents the vehicle category
 type RideType string
 
@@ -85,6 +108,8 @@ type Ride struct {
 	completedAt    *time.Time
 	cancelledAt    *time.Time
 	cancelReason   string
+	cancelledBy    string // one of the CancelledBy* parties, empty unless cancelled
+	passengerCount int
 }
 
 // NewRide creates a new ride with validation
@@ -94,7 +119,6 @@ func NewRide(
 	dest Coordinate,
 	rideType RideType,
 	estimatedFare float64,
-	todayRideCount int,
 ) (*Ride, error) {
 	// Validate ride type
 	if !rideType.IsValid() {
@@ -109,18 +133,16 @@ func NewRide(
 		return nil, fmt.Errorf("invalid destination location: %w", err)
 	}
 
-	// Generate ride number
-	rideNumber := generateRideNumber(todayRideCount)
-
+	// The ride number is allocated when the ride is saved
 	return &Ride{
 		passengerID:    passengerID,
-		rideNumber:     rideNumber,
 		status:         StatusRequested,
 		rideType:       rideType,
 		pickupLocation: pickup,
 		destLocation:   dest,
 		estimatedFare:  estimatedFare,
 		requestedAt:    time.Now(),
+		passengerCount: 1,
 	}, nil
 }
 
@@ -142,6 +164,7 @@ func ReconstructRide(
 	completedAt *time.Time,
 	cancelledAt *time.Time,
 	cancelReason string,
+	cancelledBy string,
 ) *Ride {
 	return &Ride{
 		id:             id,
@@ -160,6 +183,8 @@ func ReconstructRide(
 		completedAt:    completedAt,
 		cancelledAt:    cancelledAt,
 		cancelReason:   cancelReason,
+		cancelledBy:    cancelledBy,
+		passengerCount: 1,
 	}
 }
 
@@ -206,20 +231,35 @@ func (r *Ride) CompleteTrip(finalFare float64) error {
 	return nil
 }
 
-// Cancel cancels the ride with a reason
-func (r *Ride) Cancel(reason string) error {
+// Cancel cancels the ride with a reason on behalf of cancelledBy, one of the
+// CancelledBy* parties
+func (r *Ride) Cancel(reason, cancelledBy string) error {
 	if !r.CanBeCancelled() {
 		return ErrCannotCancelRide
 	}
 
 	r.status = StatusCancelled
 	r.cancelReason = reason
+	r.cancelledBy = cancelledBy
 	now := time.Now()
 	r.cancelledAt = &now
 
 	return nil
 }
 
+// ChangeDestination moves the drop-off of a ride that is under way and replaces
+// its estimated fare
+func (r *Ride) ChangeDestination(dest Coordinate, estimatedFare float64) error {
+	if r.status != StatusInProgress {
+		return ErrCannotChangeDestination
+	}
+
+	r.destLocation = dest
+	r.estimatedFare = estimatedFare
+
+	return nil
+}
+
 // UpdateStatus updates the ride status
 func (r *Ride) UpdateStatus(newStatus RideStatus) error {
 	if !newStatus.IsValid() {
@@ -297,19 +337,33 @@ func (r *Ride) StartedAt() *time.Time      { return r.startedAt }
 func (r *Ride) CompletedAt() *time.Time    { return r.completedAt }
 func (r *Ride) CancelledAt() *time.Time    { return r.cancelledAt }
 func (r *Ride) CancelReason() string       { return r.cancelReason }
+func (r *Ride) CancelledBy() string        { return r.cancelledBy }
+func (r *Ride) PassengerCount() int        { return r.passengerCount }
 
 // SetID sets the ride ID (used after persistence)
 func (r *Ride) SetID(id string) {
 	r.id = id
 }
 
+// SetRideNumber records the number allocated by the repository on save
+func (r *Ride) SetRideNumber(rideNumber string) {
+	r.rideNumber = rideNumber
+}
+
+// SetPassengerCount sets how many seats the ride needs
+func (r *Ride) SetPassengerCount(n int) error {
+	if n < 1 || n > MaxPassengerCount {
+		return ErrInvalidPassengerCount
+	}
+	r.passengerCount = n
+	return nil
+}
+
 // Helper functions
 
-// generateRideNumber generates a unique ride number in format RIDE_YYYYMMDD_XXX
-func generateRideNumber(todayRideCount int) string {
-	today := time.Now().Format("20060102")
-	counter := todayRideCount + 1
-	return fmt.Sprintf("RIDE_%s_%03d", today, counter)
+// FormatRideNumber formats the n-th ride of a day as RIDE_YYYYMMDD_XXX
+func FormatRideNumber(day time.Time, n int) string {
+	return fmt.Sprintf("RIDE_%s_%03d", day.Format("20060102"), n)
 }
 
 // ValidateRideType validates a ride type string
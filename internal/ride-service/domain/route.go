@@ -0,0 +1,10 @@
+package domain
+
+import "time"
+
+// RoutePoint is one recorded driver position along a ride, from location_history
+type RoutePoint struct {
+	Latitude   float64
+	Longitude  float64
+	RecordedAt time.Time
+}
@@ -0,0 +1,31 @@
+package domain
+
+import "errors"
+
+// Service area errors
+var (
+	ErrOutsideServiceArea = errors.New("location is outside the service area")
+	ErrRideTooLong        = errors.New("ride distance exceeds the maximum")
+	ErrRideTooShort       = errors.New("pickup and destination are too close together")
+)
+
+// ServiceArea is the lat/lng box rides must start and end in.
+// The zero value covers the whole map.
+type ServiceArea struct {
+	MinLat, MinLng float64
+	MaxLat, MaxLng float64
+}
+
+// Unbounded reports whether no area has been configured
+func (a ServiceArea) Unbounded() bool {
+	return a == ServiceArea{}
+}
+
+// Contains reports whether the coordinate lies inside the area
+func (a ServiceArea) Contains(c Coordinate) bool {
+	if a.Unbounded() {
+		return true
+	}
+	return c.latitude >= a.MinLat && c.latitude <= a.MaxLat &&
+		c.longitude >= a.MinLng && c.longitude <= a.MaxLng
+}
@@ -0,0 +1,107 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"ride-hail/internal/ride-service/domain"
+	"ride-hail/pkg/apitime"
+	"ride-hail/pkg/auth"
+	"ride-hail/pkg/logger"
+)
+
+// ActiveRidesHandler lets a passenger find the rides they still have under way,
+// e.g. after reopening the app
+type ActiveRidesHandler struct {
+	rideRepo domain.RideRepository
+	logger   logger.Logger
+}
+
+// NewActiveRidesHandler creates a new active rides handler
+func NewActiveRidesHandler(rideRepo domain.RideRepository, logger logger.Logger) *ActiveRidesHandler {
+	return &ActiveRidesHandler{
+		rideRepo: rideRepo,
+		logger:   logger,
+	}
+}
+
+// ActiveRideResponse describes one ride that is neither completed nor cancelled
+type ActiveRideResponse struct {
+	RideID        string        `json:"ride_id"`
+	RideNumber    string        `json:"ride_number"`
+	Status        string        `json:"status"`
+	RideType      string        `json:"ride_type"`
+	DriverID      *string       `json:"driver_id"` // null until a driver accepts
+	Pickup        locationJSON  `json:"pickup_location"`
+	Destination   locationJSON  `json:"destination_location"`
+	EstimatedFare float64       `json:"estimated_fare"`
+	RequestedAt   apitime.Time  `json:"requested_at"`
+	MatchedAt     *apitime.Time `json:"matched_at,omitempty"`
+	StartedAt     *apitime.Time `json:"started_at,omitempty"`
+}
+
+type locationJSON struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Address   string  `json:"address"`
+}
+
+// ListActiveRides handles GET /rides/active
+func (h *ActiveRidesHandler) ListActiveRides(w http.ResponseWriter, r *http.Request) {
+	claims, ok := auth.GetClaims(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized - missing claims")
+		return
+	}
+	if claims.Role != auth.RolePassenger {
+		writeError(w, http.StatusForbidden, CodeForbidden, "Only passengers can list their active rides")
+		return
+	}
+
+	rides, err := h.rideRepo.FindActiveByPassenger(r.Context(), claims.UserID)
+	if err != nil {
+		h.logger.WithFields(logger.LogFields{
+			"passenger_id": claims.UserID,
+			"error":        err.Error(),
+		}).Error("list_active_rides_failed", err)
+		writeDomainError(w, err)
+		return
+	}
+
+	// Always an array, so clients don't have to special-case null
+	resp := make([]ActiveRideResponse, 0, len(rides))
+	for _, ride := range rides {
+		resp = append(resp, toActiveRideResponse(ride))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"rides": resp})
+}
+
+func toActiveRideResponse(ride *domain.Ride) ActiveRideResponse {
+	pickup, dest := ride.PickupLocation(), ride.DestLocation()
+	return ActiveRideResponse{
+		RideID:        ride.ID(),
+		RideNumber:    ride.RideNumber(),
+		Status:        ride.Status().String(),
+		RideType:      ride.RideTypeValue().String(),
+		DriverID:      ride.DriverID(),
+		Pickup:        locationJSON{Latitude: pickup.Latitude(), Longitude: pickup.Longitude(), Address: pickup.Address()},
+		Destination:   locationJSON{Latitude: dest.Latitude(), Longitude: dest.Longitude(), Address: dest.Address()},
+		EstimatedFare: ride.EstimatedFare(),
+		RequestedAt:   apitime.New(ride.RequestedAt()),
+		MatchedAt:     optionalTime(ride.MatchedAt()),
+		StartedAt:     optionalTime(ride.StartedAt()),
+	}
+}
+
+// optionalTime keeps an unset timestamp out of the response
+func optionalTime(t *time.Time) *apitime.Time {
+	if t == nil {
+		return nil
+	}
+	at := apitime.New(*t)
+	return &at
+}
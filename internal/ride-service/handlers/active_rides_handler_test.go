@@ -0,0 +1,107 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ride-hail/internal/ride-service/domain"
+	"ride-hail/pkg/auth"
+)
+
+// getActiveRides lists the active rides of the caller authorized by authorization
+func getActiveRides(t *testing.T, repo *fakeRideRepo, authorization string) (int, []byte) {
+	t.Helper()
+	h := NewActiveRidesHandler(repo, nopLogger{})
+	mux := http.NewServeMux()
+	mux.Handle("GET /rides/active", withAuth(h.ListActiveRides))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/rides/active", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", authorization)
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("GET /rides/active: %v", err)
+	}
+	defer resp.Body.Close()
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return resp.StatusCode, raw
+}
+
+func TestActiveRidesListsThePassengersRideUnderWay(t *testing.T) {
+	repo := newFakeRideRepo(
+		testRide(t, "ride-1", "passenger-1", domain.StatusEnRoute),
+		testRide(t, "ride-2", "passenger-1", domain.StatusCompleted),
+		testRide(t, "ride-3", "passenger-2", domain.StatusInProgress),
+	)
+
+	status, raw := getActiveRides(t, repo, bearer(t, "passenger-1", auth.RolePassenger))
+	if status != http.StatusOK {
+		t.Fatalf("status = %d (%s), want 200", status, raw)
+	}
+	var body struct {
+		Rides []ActiveRideResponse `json:"rides"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		t.Fatalf("decode %s: %v", raw, err)
+	}
+	if len(body.Rides) != 1 {
+		t.Fatalf("got %d rides, want passenger-1's ride under way", len(body.Rides))
+	}
+	ride := body.Rides[0]
+	if ride.RideID != "ride-1" || ride.Status != "EN_ROUTE" || ride.DriverID == nil || *ride.DriverID != "driver-1" {
+		t.Errorf("ride = %+v, want ride-1 EN_ROUTE with driver-1", ride)
+	}
+	if ride.Pickup.Address != "Abay Ave 10" || ride.Destination.Address != "Dostyk Ave 5" || ride.EstimatedFare != 1450 {
+		t.Errorf("ride = %+v, want the route and fare", ride)
+	}
+}
+
+func TestActiveRidesWithoutDriverYet(t *testing.T) {
+	repo := newFakeRideRepo(testRide(t, "ride-1", "passenger-1", domain.StatusRequested))
+
+	_, raw := getActiveRides(t, repo, bearer(t, "passenger-1", auth.RolePassenger))
+	var body struct {
+		Rides []map[string]interface{} `json:"rides"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		t.Fatalf("decode %s: %v", raw, err)
+	}
+	if len(body.Rides) != 1 {
+		t.Fatalf("got %s, want the requested ride", raw)
+	}
+	if driver, ok := body.Rides[0]["driver_id"]; !ok || driver != nil {
+		t.Errorf("driver_id = %v (present %v), want null", driver, ok)
+	}
+}
+
+func TestActiveRidesEmptyIsAnArray(t *testing.T) {
+	repo := newFakeRideRepo(testRide(t, "ride-1", "passenger-1", domain.StatusCancelled))
+
+	status, raw := getActiveRides(t, repo, bearer(t, "passenger-1", auth.RolePassenger))
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	if string(raw) != `{"rides":[]}` {
+		t.Errorf("body = %s, want an empty rides array", raw)
+	}
+}
+
+func TestActiveRidesOnlyForPassengers(t *testing.T) {
+	repo := newFakeRideRepo()
+
+	if status, _ := getActiveRides(t, repo, bearer(t, "driver-1", auth.RoleDriver)); status != http.StatusForbidden {
+		t.Errorf("driver: status = %d, want 403", status)
+	}
+	if status, _ := getActiveRides(t, repo, ""); status != http.StatusUnauthorized {
+		t.Errorf("no token: status = %d, want 401", status)
+	}
+}
@@ -0,0 +1,84 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"ride-hail/internal/ride-service/application"
+	"ride-hail/pkg/auth"
+	"ride-hail/pkg/logger"
+	"ride-hail/pkg/validation"
+)
+
+// DestinationHandler lets a passenger redirect a ride in progress
+type DestinationHandler struct {
+	changeDestinationUseCase *application.ChangeDestinationUseCase
+	logger                   logger.Logger
+}
+
+// NewDestinationHandler creates a new destination change handler
+func NewDestinationHandler(changeDestinationUseCase *application.ChangeDestinationUseCase, logger logger.Logger) *DestinationHandler {
+	return &DestinationHandler{
+		changeDestinationUseCase: changeDestinationUseCase,
+		logger:                   logger,
+	}
+}
+
+// ChangeDestinationRequest represents the HTTP request for changing a ride's destination
+type ChangeDestinationRequest struct {
+	DestinationLatitude  float64 `json:"destination_latitude"`
+	DestinationLongitude float64 `json:"destination_longitude"`
+	DestinationAddress   string  `json:"destination_address,omitempty"`
+}
+
+// ChangeDestination handles PATCH /rides/{ride_id}/destination
+func (h *DestinationHandler) ChangeDestination(w http.ResponseWriter, r *http.Request) {
+	rideID := r.PathValue("ride_id")
+	if rideID == "" {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "Ride ID is required")
+		return
+	}
+
+	claims, ok := auth.GetClaims(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized - missing claims")
+		return
+	}
+	if claims.Role != auth.RolePassenger {
+		writeError(w, http.StatusForbidden, CodeForbidden, "Only the ride's passenger can change its destination")
+		return
+	}
+
+	var req ChangeDestinationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, validation.DecodeErrorMessage(err))
+		return
+	}
+	if code, errs := validateChangeDestination(req); code != "" {
+		writeValidationError(w, code, errs)
+		return
+	}
+
+	// FindByPassenger reports another passenger's ride as not found
+	result, err := h.changeDestinationUseCase.Execute(r.Context(), application.ChangeDestinationCommand{
+		RideID:      rideID,
+		PassengerID: claims.UserID,
+		Latitude:    req.DestinationLatitude,
+		Longitude:   req.DestinationLongitude,
+		Address:     req.DestinationAddress,
+	})
+	if err != nil {
+		if _, code := mapError(err); code == CodeInternal {
+			h.logger.WithFields(logger.LogFields{
+				"ride_id": rideID,
+				"error":   err.Error(),
+			}).Error("change_destination_failed", err)
+		}
+		writeDomainError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
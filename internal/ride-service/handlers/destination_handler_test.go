@@ -0,0 +1,117 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ride-hail/internal/ride-service/application"
+	"ride-hail/internal/ride-service/domain"
+	"ride-hail/pkg/auth"
+)
+
+// newDestinationServer serves destination changes over repo
+func newDestinationServer(t *testing.T, repo *fakeRideRepo, events *fakeEventPublisher) *httptest.Server {
+	t.Helper()
+	uc := application.NewChangeDestinationUseCase(repo, events, domain.NewFareCalculator(), nopLogger{})
+	h := NewDestinationHandler(uc, nopLogger{})
+	mux := http.NewServeMux()
+	mux.Handle("PATCH /rides/{ride_id}/destination", withAuth(h.ChangeDestination))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func patchDestination(t *testing.T, srv *httptest.Server, rideID, authorization, body string) (int, map[string]interface{}) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPatch, srv.URL+"/rides/"+rideID+"/destination", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", authorization)
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("PATCH destination: %v", err)
+	}
+	defer resp.Body.Close()
+	var out map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&out)
+	return resp.StatusCode, out
+}
+
+// fartherAway is well past the original Dostyk Ave drop-off
+const fartherAway = `{"destination_latitude": 43.20, "destination_longitude": 76.80, "destination_address": "Al-Farabi Ave 77"}`
+
+func TestChangeDestinationMidTrip(t *testing.T) {
+	ride := testRide(t, "ride-1", "passenger-1", domain.StatusInProgress)
+	repo := newFakeRideRepo(ride)
+	events := &fakeEventPublisher{}
+	srv := newDestinationServer(t, repo, events)
+
+	status, body := patchDestination(t, srv, "ride-1", bearer(t, "passenger-1", auth.RolePassenger), fartherAway)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d (%v), want 200", status, body)
+	}
+
+	original := testRide(t, "ride-1", "passenger-1", domain.StatusInProgress)
+	oldFare := domain.NewFareCalculator().Calculate(original.PickupLocation(), original.DestLocation(), domain.RideTypeEconomy)
+	newFare, _ := body["estimated_fare"].(float64)
+	if newFare <= oldFare {
+		t.Errorf("estimated_fare = %v, want more than the original %v for a farther drop-off", newFare, oldFare)
+	}
+	if body["previous_estimated_fare"] != 1450.0 {
+		t.Errorf("previous_estimated_fare = %v, want the stored 1450", body["previous_estimated_fare"])
+	}
+	stored := repo.rides["ride-1"]
+	if stored.DestLocation().Latitude() != 43.20 || stored.DestLocation().Address() != "Al-Farabi Ave 77" || stored.EstimatedFare() != newFare {
+		t.Errorf("stored destination %+v fare %v, want the new drop-off and fare", stored.DestLocation(), stored.EstimatedFare())
+	}
+
+	// The driver service picks this up and pushes it to driver-1's socket
+	if len(events.events) != 1 {
+		t.Fatalf("published %d events, want 1", len(events.events))
+	}
+	ev, ok := events.events[0].(domain.RideDestinationChangedEvent)
+	if !ok || ev.DriverID != "driver-1" || ev.NewFare != newFare || ev.OldDestination.Address() != "Dostyk Ave 5" {
+		t.Errorf("published %+v, want the change addressed to driver-1", events.events[0])
+	}
+	if len(repo.events) != 1 || repo.events[0].EventType() != ev.EventType() {
+		t.Errorf("ride_events got %v, want the destination change recorded", repo.events)
+	}
+}
+
+func TestChangeDestinationBeforePickupIsRejected(t *testing.T) {
+	for _, status := range []domain.RideStatus{domain.StatusRequested, domain.StatusMatched, domain.StatusEnRoute, domain.StatusArrived, domain.StatusCompleted} {
+		t.Run(status.String(), func(t *testing.T) {
+			repo := newFakeRideRepo(testRide(t, "ride-1", "passenger-1", status))
+			events := &fakeEventPublisher{}
+			srv := newDestinationServer(t, repo, events)
+
+			code, body := patchDestination(t, srv, "ride-1", bearer(t, "passenger-1", auth.RolePassenger), fartherAway)
+			if code != http.StatusConflict || body["code"] != CodeRideNotInProgress {
+				t.Fatalf("status = %d (%v), want 409 %s", code, body, CodeRideNotInProgress)
+			}
+			if ride := repo.rides["ride-1"]; ride.DestLocation().Address() != "Dostyk Ave 5" || ride.EstimatedFare() != 1450 {
+				t.Errorf("ride changed to %+v fare %v despite the rejection", ride.DestLocation(), ride.EstimatedFare())
+			}
+			if len(events.events) != 0 || len(repo.events) != 0 {
+				t.Errorf("recorded %v and published %v, want nothing", repo.events, events.events)
+			}
+		})
+	}
+}
+
+func TestChangeDestinationOnlyByTheRidesPassenger(t *testing.T) {
+	repo := newFakeRideRepo(testRide(t, "ride-1", "passenger-1", domain.StatusInProgress))
+	srv := newDestinationServer(t, repo, &fakeEventPublisher{})
+
+	if code, _ := patchDestination(t, srv, "ride-1", bearer(t, "driver-1", auth.RoleDriver), fartherAway); code != http.StatusForbidden {
+		t.Errorf("driver: status = %d, want 403", code)
+	}
+	if code, body := patchDestination(t, srv, "ride-1", bearer(t, "passenger-2", auth.RolePassenger), fartherAway); code != http.StatusNotFound || body["code"] != CodeRideNotFound {
+		t.Errorf("other passenger: status = %d (%v), want 404", code, body)
+	}
+}
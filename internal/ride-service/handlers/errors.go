@@ -0,0 +1,110 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"ride-hail/internal/ride-service/domain"
+	"ride-hail/pkg/validation"
+)
+
+// Error codes returned in the "code" field of error responses
+const (
+	CodeInvalidRequest     = "INVALID_REQUEST"
+	CodeInvalidCoordinates = "INVALID_COORDINATES"
+	CodeInvalidRideType    = "INVALID_RIDE_TYPE"
+	CodeRideTypeNotPriced  = "RIDE_TYPE_NOT_PRICED"
+	CodeInvalidPassengers  = "INVALID_PASSENGER_COUNT"
+	CodeSameLocation       = "SAME_LOCATION"
+	CodeOutsideServiceArea = "OUTSIDE_SERVICE_AREA"
+	CodeRideTooLong        = "RIDE_TOO_LONG"
+	CodeRideTooShort       = "RIDE_TOO_SHORT"
+	CodeRideNotFound       = "RIDE_NOT_FOUND"
+	CodeRideNotCancellable = "RIDE_NOT_CANCELLABLE"
+	CodeRideNotInProgress  = "RIDE_NOT_IN_PROGRESS"
+	CodeActiveRideExists   = "ACTIVE_RIDE_EXISTS"
+	CodeInvalidMessage     = "INVALID_MESSAGE"
+	CodeMessagingClosed    = "MESSAGING_CLOSED"
+	CodeRateLimited        = "RATE_LIMITED"
+	CodeUnauthorized       = "UNAUTHORIZED"
+	CodeForbidden          = "FORBIDDEN"
+	CodeMethodNotAllowed   = "METHOD_NOT_ALLOWED"
+	CodeInternal           = "INTERNAL_ERROR"
+)
+
+// errorMapping ties a domain error to its HTTP status and error code
+type errorMapping struct {
+	err    error
+	status int
+	code   string
+}
+
+// domainErrors is checked in order with errors.Is; the first match wins
+var domainErrors = []errorMapping{
+	{domain.ErrRideNotFound, http.StatusNotFound, CodeRideNotFound},
+	{domain.ErrInvalidCoordinates, http.StatusBadRequest, CodeInvalidCoordinates},
+	{domain.ErrInvalidLatitude, http.StatusBadRequest, CodeInvalidCoordinates},
+	{domain.ErrInvalidLongitude, http.StatusBadRequest, CodeInvalidCoordinates},
+	{domain.ErrZeroCoordinates, http.StatusBadRequest, CodeInvalidCoordinates},
+	{domain.ErrInvalidRideType, http.StatusBadRequest, CodeInvalidRideType},
+	{domain.ErrRideTypeNotPriced, http.StatusUnprocessableEntity, CodeRideTypeNotPriced},
+	{domain.ErrInvalidPassengerCount, http.StatusBadRequest, CodeInvalidPassengers},
+	{domain.ErrOutsideServiceArea, http.StatusBadRequest, CodeOutsideServiceArea},
+	{domain.ErrRideTooLong, http.StatusBadRequest, CodeRideTooLong},
+	{domain.ErrRideTooShort, http.StatusBadRequest, CodeRideTooShort},
+	{domain.ErrCannotCancelRide, http.StatusConflict, CodeRideNotCancellable},
+	{domain.ErrCannotCancelCompletedRide, http.StatusConflict, CodeRideNotCancellable},
+	{domain.ErrCannotChangeDestination, http.StatusConflict, CodeRideNotInProgress},
+	{domain.ErrActiveRideExists, http.StatusConflict, CodeActiveRideExists},
+	{domain.ErrTooManyRideRequests, http.StatusTooManyRequests, CodeRateLimited},
+	{domain.ErrInvalidMessage, http.StatusBadRequest, CodeInvalidMessage},
+	{domain.ErrNotRideParticipant, http.StatusForbidden, CodeForbidden},
+	{domain.ErrMessagingClosed, http.StatusConflict, CodeMessagingClosed},
+}
+
+// mapError maps a domain error to an HTTP status code and error code
+func mapError(err error) (int, string) {
+	for _, m := range domainErrors {
+		if errors.Is(err, m.err) {
+			return m.status, m.code
+		}
+	}
+	return http.StatusInternalServerError, CodeInternal
+}
+
+// writeDomainError writes the JSON error response for a use case error.
+// Internal errors are not echoed back to the client.
+func writeDomainError(w http.ResponseWriter, err error) {
+	status, code := mapError(err)
+	message := err.Error()
+	if status == http.StatusInternalServerError {
+		message = "internal server error"
+	}
+	writeError(w, status, code, message)
+}
+
+// writeError writes a JSON error response
+func writeError(w http.ResponseWriter, status int, code string, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":   http.StatusText(status),
+		"code":    code,
+		"message": message,
+	})
+}
+
+// writeValidationError writes a 400 listing every invalid field under "errors".
+// code is that of the first problem and message summarises them all, for clients
+// that only read those fields.
+func writeValidationError(w http.ResponseWriter, code string, errs validation.Errors) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":   http.StatusText(http.StatusBadRequest),
+		"code":    code,
+		"message": errs.Error(),
+		"errors":  errs,
+	})
+}
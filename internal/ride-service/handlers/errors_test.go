@@ -0,0 +1,93 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ride-hail/internal/ride-service/domain"
+)
+
+func TestMapErrorCoversEveryDomainError(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{domain.ErrRideNotFound, http.StatusNotFound, CodeRideNotFound},
+		{domain.ErrInvalidCoordinates, http.StatusBadRequest, CodeInvalidCoordinates},
+		{domain.ErrInvalidLatitude, http.StatusBadRequest, CodeInvalidCoordinates},
+		{domain.ErrInvalidLongitude, http.StatusBadRequest, CodeInvalidCoordinates},
+		{domain.ErrZeroCoordinates, http.StatusBadRequest, CodeInvalidCoordinates},
+		{domain.ErrInvalidRideType, http.StatusBadRequest, CodeInvalidRideType},
+		{domain.ErrRideTypeNotPriced, http.StatusUnprocessableEntity, CodeRideTypeNotPriced},
+		{domain.ErrInvalidPassengerCount, http.StatusBadRequest, CodeInvalidPassengers},
+		{domain.ErrOutsideServiceArea, http.StatusBadRequest, CodeOutsideServiceArea},
+		{domain.ErrRideTooLong, http.StatusBadRequest, CodeRideTooLong},
+		{domain.ErrRideTooShort, http.StatusBadRequest, CodeRideTooShort},
+		{domain.ErrCannotCancelRide, http.StatusConflict, CodeRideNotCancellable},
+		{domain.ErrCannotCancelCompletedRide, http.StatusConflict, CodeRideNotCancellable},
+		{domain.ErrCannotChangeDestination, http.StatusConflict, CodeRideNotInProgress},
+		{domain.ErrActiveRideExists, http.StatusConflict, CodeActiveRideExists},
+		{domain.ErrTooManyRideRequests, http.StatusTooManyRequests, CodeRateLimited},
+		{domain.ErrInvalidMessage, http.StatusBadRequest, CodeInvalidMessage},
+		{domain.ErrNotRideParticipant, http.StatusForbidden, CodeForbidden},
+		{domain.ErrMessagingClosed, http.StatusConflict, CodeMessagingClosed},
+	}
+	if len(tests) != len(domainErrors) {
+		t.Errorf("%d cases for %d mapped errors; add a case for each new mapping", len(tests), len(domainErrors))
+	}
+	for _, tt := range tests {
+		t.Run(tt.code+"/"+tt.err.Error(), func(t *testing.T) {
+			// Use cases wrap domain errors with context
+			for _, err := range []error{tt.err, fmt.Errorf("pickup: %w", tt.err)} {
+				status, code := mapError(err)
+				if status != tt.status || code != tt.code {
+					t.Errorf("mapError(%q) = %d %s, want %d %s", err, status, code, tt.status, tt.code)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteDomainErrorHidesInternalErrors(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeDomainError(rec, errors.New("pq: connection refused"))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", rec.Code)
+	}
+	var body map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body["code"] != CodeInternal || body["message"] != "internal server error" {
+		t.Errorf("body = %v, want INTERNAL_ERROR without the cause", body)
+	}
+}
+
+func TestWriteDomainErrorBody(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeDomainError(rec, domain.ErrRideNotFound)
+
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	var body map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"error":   http.StatusText(http.StatusNotFound),
+		"code":    CodeRideNotFound,
+		"message": domain.ErrRideNotFound.Error(),
+	}
+	for k, v := range want {
+		if body[k] != v {
+			t.Errorf("%s = %q, want %q", k, body[k], v)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"ride-hail/internal/ride-service/domain"
+	"ride-hail/pkg/auth"
+	"ride-hail/pkg/logger"
+)
+
+const testSecret = "test-secret"
+
+// testNow is when every test ride was requested
+var testNow = time.Date(2024, 12, 16, 10, 0, 0, 0, time.UTC)
+
+type nopLogger struct{}
+
+func (l nopLogger) WithFields(logger.LogFields) logger.Logger { return l }
+func (nopLogger) Info(action, message string)                 {}
+func (nopLogger) Debug(action, message string)                {}
+func (nopLogger) Warn(action, message string)                 {}
+func (nopLogger) Error(action string, err error)              {}
+
+// fakeRideRepo keeps rides in memory. Methods a test does not set up fall
+// through to the nil embedded interface and panic.
+type fakeRideRepo struct {
+	domain.RideRepository
+
+	mu       sync.Mutex
+	rides    map[string]*domain.Ride
+	routes   map[string][]domain.RoutePoint // returned by FindRoute
+	messages []*domain.RideMessage          // SaveMessage calls
+	events   []domain.DomainEvent           // SaveEvent calls
+}
+
+func newFakeRideRepo(rides ...*domain.Ride) *fakeRideRepo {
+	r := &fakeRideRepo{rides: make(map[string]*domain.Ride)}
+	for _, ride := range rides {
+		r.rides[ride.ID()] = ride
+	}
+	return r
+}
+
+func (r *fakeRideRepo) FindByID(ctx context.Context, rideID string) (*domain.Ride, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ride, ok := r.rides[rideID]
+	if !ok {
+		return nil, domain.ErrRideNotFound
+	}
+	return ride, nil
+}
+
+func (r *fakeRideRepo) FindByPassenger(ctx context.Context, rideID string, passengerID string) (*domain.Ride, error) {
+	ride, err := r.FindByID(ctx, rideID)
+	if err != nil {
+		return nil, err
+	}
+	if ride.PassengerID() != passengerID {
+		return nil, domain.ErrRideNotFound
+	}
+	return ride, nil
+}
+
+// Save stores a new ride, numbering it like the Postgres repository
+func (r *fakeRideRepo) Save(ctx context.Context, ride *domain.Ride) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ride.SetRideNumber(fmt.Sprintf("RIDE_20241216_%03d", len(r.rides)+1))
+	r.rides[ride.ID()] = ride
+	return nil
+}
+
+// FindActiveByPassenger returns the passenger's rides that are neither completed nor cancelled
+func (r *fakeRideRepo) FindActiveByPassenger(ctx context.Context, passengerID string) ([]*domain.Ride, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var active []*domain.Ride
+	for _, ride := range r.rides {
+		if ride.PassengerID() == passengerID && ride.IsActive() {
+			active = append(active, ride)
+		}
+	}
+	return active, nil
+}
+
+func (r *fakeRideRepo) FindRoute(ctx context.Context, rideID string) ([]domain.RoutePoint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.routes[rideID], nil
+}
+
+// SaveMessage stores msg, filling in its ID like the Postgres repository
+func (r *fakeRideRepo) SaveMessage(ctx context.Context, msg *domain.RideMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	msg.ID = fmt.Sprintf("msg-%d", len(r.messages)+1)
+	r.messages = append(r.messages, msg)
+	return nil
+}
+
+func (r *fakeRideRepo) Update(ctx context.Context, ride *domain.Ride) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rides[ride.ID()] = ride
+	return nil
+}
+
+func (r *fakeRideRepo) UpdateDestination(ctx context.Context, ride *domain.Ride) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rides[ride.ID()] = ride
+	return nil
+}
+
+func (r *fakeRideRepo) SaveEvent(ctx context.Context, rideID string, event domain.DomainEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+// fakeEventPublisher records the events published
+type fakeEventPublisher struct {
+	mu     sync.Mutex
+	events []domain.DomainEvent
+}
+
+func (p *fakeEventPublisher) Publish(ctx context.Context, event domain.DomainEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, event)
+	return nil
+}
+
+// fakePassengers records what passengers were pushed; connected passengers
+// have a live socket and the rest fail to deliver
+type fakePassengers struct {
+	connected map[string]bool
+
+	mu   sync.Mutex
+	sent map[string][]interface{}
+}
+
+func (p *fakePassengers) SendToUser(userID string, message interface{}) error {
+	if !p.connected[userID] {
+		return fmt.Errorf("user %s not connected", userID)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.sent == nil {
+		p.sent = make(map[string][]interface{})
+	}
+	p.sent[userID] = append(p.sent[userID], message)
+	return nil
+}
+
+// testRide returns an economy ride across Almaty in the given status
+func testRide(t *testing.T, id, passengerID string, status domain.RideStatus) *domain.Ride {
+	t.Helper()
+	pickup, err := domain.NewCoordinate(43.238949, 76.889709, "Abay Ave 10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest, err := domain.NewCoordinate(43.222015, 76.851511, "Dostyk Ave 5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var driverID *string
+	if status != domain.StatusRequested && status != domain.StatusCancelled {
+		d := "driver-1"
+		driverID = &d
+	}
+	return domain.ReconstructRide(id, "RIDE_20241216_001", passengerID, driverID, status, domain.RideTypeEconomy,
+		pickup, dest, 1450, nil, testNow, nil, nil, nil, nil, "", "")
+}
+
+// withAuth wraps handler in the JWT middleware main uses
+func withAuth(handler http.HandlerFunc) http.Handler {
+	return auth.NewJWTManager(testSecret, time.Hour).AuthMiddleware(handler)
+}
+
+// bearer returns an Authorization header value for userID in role
+func bearer(t *testing.T, userID string, role auth.Role) string {
+	t.Helper()
+	tok, err := auth.NewJWTManager(testSecret, time.Hour).GenerateToken(userID, role)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	return "Bearer " + tok
+}
@@ -7,6 +7,7 @@ import (
 	"ride-hail/internal/ride-service/application"
 	"ride-hail/pkg/auth"
 	"ride-hail/pkg/logger"
+	"ride-hail/pkg/validation"
 )
 
 // RideHandler handles HTTP requests for rides using clean architecture
@@ -39,6 +40,7 @@ type CreateRideRequest struct {
 	DestinationLongitude float64 `json:"destination_longitude"`
 	DestinationAddress   string  `json:"destination_address,omitempty"`
 	RideType             string  `json:"ride_type"`
+	PassengerCount       int     `json:"passenger_count,omitempty"` // Defaults to 1
 }
 
 // CreateRideResponse represents the HTTP response for creating a ride
@@ -52,7 +54,7 @@ type CreateRideResponse struct {
 // CreateRide handles POST /rides
 func (h *RideHandler) CreateRide(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -62,7 +64,7 @@ func (h *RideHandler) CreateRide(w http.ResponseWriter, r *http.Request) {
 		h.logger.WithFields(logger.LogFields{
 			"error": err.Error(),
 		}).Error("parse_request_failed", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, validation.DecodeErrorMessage(err))
 		return
 	}
 
@@ -74,18 +76,23 @@ func (h *RideHandler) CreateRide(w http.ResponseWriter, r *http.Request) {
 		"ride_type":  req.RideType,
 	}).Info("create_ride_request", "Received create ride request")
 
+	if code, errs := validateCreateRide(req); code != "" {
+		writeValidationError(w, code, errs)
+		return
+	}
+
 	// 2. Extract passenger ID from JWT context
 	claims, ok := auth.GetClaims(r.Context())
 	if !ok {
 		h.logger.Error("missing_claims", nil)
-		http.Error(w, "Unauthorized - missing claims", http.StatusUnauthorized)
+		writeError(w, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized - missing claims")
 		return
 	}
 
 	// Verify the user is a passenger
 	if claims.Role != auth.RolePassenger {
 		h.logger.Error("invalid_role", nil)
-		http.Error(w, "Only passengers can create rides", http.StatusForbidden)
+		writeError(w, http.StatusForbidden, CodeForbidden, "Only passengers can create rides")
 		return
 	}
 
@@ -93,7 +100,7 @@ func (h *RideHandler) CreateRide(w http.ResponseWriter, r *http.Request) {
 
 	// 3. Convert HTTP request to application command
 	cmd := application.CreateRideCommand{
-		PassengerID:          req.PassengerID,
+		PassengerID:          passengerID,
 		PickupLatitude:       req.PickupLatitude,
 		PickupLongitude:      req.PickupLongitude,
 		PickupAddress:        req.PickupAddress,
@@ -101,6 +108,7 @@ func (h *RideHandler) CreateRide(w http.ResponseWriter, r *http.Request) {
 		DestinationLongitude: req.DestinationLongitude,
 		DestinationAddress:   req.DestinationAddress,
 		RideType:             req.RideType,
+		PassengerCount:       req.PassengerCount,
 	}
 	// 4. Execute use case (business logic is here)
 	result, err := h.createRideUseCase.Execute(r.Context(), cmd)
@@ -111,8 +119,7 @@ func (h *RideHandler) CreateRide(w http.ResponseWriter, r *http.Request) {
 		}).Error("create_ride_failed", err)
 
 		// Map domain errors to HTTP status codes
-		statusCode := mapErrorToStatusCode(err)
-		http.Error(w, err.Error(), statusCode)
+		writeDomainError(w, err)
 		return
 	}
 
@@ -139,17 +146,23 @@ type CancelRideRequest struct {
 	Reason string `json:"reason,omitempty"`
 }
 
+// CancelRideResponse represents the HTTP response for cancelling a ride
+type CancelRideResponse struct {
+	Message string `json:"message"`
+	*application.CancellationDTO
+}
+
 // CancelRide handles POST /rides/{ride_id}/cancel
 func (h *RideHandler) CancelRide(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	// 1. Get ride ID from URL path
 	rideID := r.PathValue("ride_id")
 	if rideID == "" {
-		http.Error(w, "Ride ID is required", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "Ride ID is required")
 		return
 	}
 
@@ -165,7 +178,7 @@ func (h *RideHandler) CancelRide(w http.ResponseWriter, r *http.Request) {
 	// 3. Extract passenger ID from JWT context
 	claims, ok := auth.GetClaims(r.Context())
 	if !ok {
-		http.Error(w, "Unauthorized - missing claims", http.StatusUnauthorized)
+		writeError(w, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized - missing claims")
 		return
 	}
 
@@ -185,14 +198,14 @@ func (h *RideHandler) CancelRide(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 5. Execute use case
-	if err := h.cancelRideUseCase.Execute(r.Context(), cmd); err != nil {
+	result, err := h.cancelRideUseCase.Execute(r.Context(), cmd)
+	if err != nil {
 		h.logger.WithFields(logger.LogFields{
 			"error":   err.Error(),
 			"ride_id": rideID,
 		}).Error("cancel_ride_failed", err)
 
-		statusCode := mapErrorToStatusCode(err)
-		http.Error(w, err.Error(), statusCode)
+		writeDomainError(w, err)
 		return
 	}
 
@@ -204,9 +217,9 @@ func (h *RideHandler) CancelRide(w http.ResponseWriter, r *http.Request) {
 	// 6. Send response
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Ride cancelled successfully",
-		"ride_id": rideID,
+	json.NewEncoder(w).Encode(CancelRideResponse{
+		Message:         "Ride cancelled successfully",
+		CancellationDTO: result,
 	})
 }
 
@@ -216,56 +229,3 @@ func (h *RideHandler) Health(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
-
-// mapErrorToStatusCode maps domain errors to HTTP status codes
-func mapErrorToStatusCode(err error) int {
-	errMsg := err.Error()
-
-	// Check for common domain errors
-	switch {
-	case contains(errMsg, "invalid"):
-		return http.StatusBadRequest
-	case contains(errMsg, "not found"):
-		return http.StatusNotFound
-	case contains(errMsg, "cannot cancel"):
-		return http.StatusConflict
-	case contains(errMsg, "unauthorized"):
-		return http.StatusUnauthorized
-	default:
-		return http.StatusInternalServerError
-	}
-}
-
-// contains checks if s contains substr (case insensitive)
-func contains(s, substr string) bool {
-	return len(s) >= len(substr) && (s == substr || len(s) > 0 && containsIgnoreCase(s, substr))
-}
-
-func containsIgnoreCase(s, substr string) bool {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if equalFold(s[i:i+len(substr)], substr) {
-			return true
-		}
-	}
-	return false
-}
-
-func equalFold(s, t string) bool {
-	if len(s) != len(t) {
-		return false
-	}
-	for i := 0; i < len(s); i++ {
-		sr := s[i]
-		tr := t[i]
-		if sr >= 'A' && sr <= 'Z' {
-			sr = sr + 32
-		}
-		if tr >= 'A' && tr <= 'Z' {
-			tr = tr + 32
-		}
-		if sr != tr {
-			return false
-		}
-	}
-	return true
-}
@@ -0,0 +1,566 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ride-hail/internal/ride-service/application"
+	"ride-hail/internal/ride-service/domain"
+	"ride-hail/pkg/auth"
+	"ride-hail/pkg/clock"
+	"ride-hail/pkg/geocode"
+	"ride-hail/pkg/pricing"
+	"ride-hail/pkg/ratelimit"
+)
+
+const createRideBody = `{"pickup_latitude":43.238949,"pickup_longitude":76.889709,"destination_latitude":43.222015,"destination_longitude":76.851511,"ride_type":"ECONOMY"}`
+
+// newCreateRideServer serves POST /rides with a limit of limit requests per
+// minute, measured on the returned clock. Configure, if given, further sets up
+// the use case.
+func newCreateRideServer(t *testing.T, repo *fakeRideRepo, limit int, configure ...func(*application.CreateRideUseCase)) (*httptest.Server, *clock.Fake) {
+	t.Helper()
+	clk := clock.NewFake(testNow)
+	limiter := ratelimit.NewMemoryRateLimiter()
+	limiter.SetClock(clk)
+
+	uc := application.NewCreateRideUseCase(repo, &fakeEventPublisher{}, domain.NewFareCalculator(), nopLogger{})
+	uc.SetRequestLimit(limiter, limit, time.Minute)
+	for _, f := range configure {
+		f(uc)
+	}
+	h := NewRideHandler(uc, nil, nopLogger{})
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /rides", withAuth(h.CreateRide))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, clk
+}
+
+// createRide posts a ride request as passengerID and returns the status and decoded body
+func createRide(t *testing.T, srv *httptest.Server, passengerID string) (int, map[string]interface{}) {
+	t.Helper()
+	return postRide(t, srv, passengerID, createRideBody)
+}
+
+// postRide posts body to /rides as passengerID
+func postRide(t *testing.T, srv *httptest.Server, passengerID, body string) (int, map[string]interface{}) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/rides", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", bearer(t, passengerID, auth.RolePassenger))
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("POST /rides: %v", err)
+	}
+	defer resp.Body.Close()
+	var decoded map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return resp.StatusCode, decoded
+}
+
+// cancelAll cancels the passenger's active rides so they may request another
+func cancelAll(t *testing.T, repo *fakeRideRepo, passengerID string) {
+	t.Helper()
+	rides, _ := repo.FindActiveByPassenger(context.Background(), passengerID)
+	for _, ride := range rides {
+		if err := ride.Cancel("changed plans", domain.CancelledByPassenger); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCreateRideRejectsSecondActiveRide(t *testing.T) {
+	repo := newFakeRideRepo()
+	srv, _ := newCreateRideServer(t, repo, 10)
+
+	status, body := createRide(t, srv, "p1")
+	if status != http.StatusCreated {
+		t.Fatalf("first ride: status = %d (%v), want 201", status, body)
+	}
+
+	status, body = createRide(t, srv, "p1")
+	if status != http.StatusConflict || body["code"] != CodeActiveRideExists {
+		t.Errorf("second ride: status = %d, code = %v; want 409 %s", status, body["code"], CodeActiveRideExists)
+	}
+	if n := len(repo.rides); n != 1 {
+		t.Errorf("%d rides stored, want 1", n)
+	}
+
+	// Another passenger is not held up
+	if status, _ := createRide(t, srv, "p2"); status != http.StatusCreated {
+		t.Errorf("other passenger: status = %d, want 201", status)
+	}
+
+	// Once the first ride is over, the passenger may request again
+	cancelAll(t, repo, "p1")
+	if status, _ := createRide(t, srv, "p1"); status != http.StatusCreated {
+		t.Errorf("after cancelling: status = %d, want 201", status)
+	}
+}
+
+func TestCreateRideRateLimited(t *testing.T) {
+	repo := newFakeRideRepo()
+	srv, clk := newCreateRideServer(t, repo, 2)
+
+	for i := 0; i < 2; i++ {
+		if status, body := createRide(t, srv, "p1"); status != http.StatusCreated {
+			t.Fatalf("request %d: status = %d (%v), want 201", i+1, status, body)
+		}
+		cancelAll(t, repo, "p1")
+	}
+
+	status, body := createRide(t, srv, "p1")
+	if status != http.StatusTooManyRequests || body["code"] != CodeRateLimited {
+		t.Fatalf("third request: status = %d, code = %v; want 429 %s", status, body["code"], CodeRateLimited)
+	}
+	if n := len(repo.rides); n != 2 {
+		t.Errorf("%d rides stored, want the limited request dropped", n)
+	}
+
+	clk.Advance(time.Minute)
+	if status, _ := createRide(t, srv, "p1"); status != http.StatusCreated {
+		t.Errorf("after the window: status = %d, want 201", status)
+	}
+}
+
+// fakeGeocoder names each point by its latitude and counts lookups
+type fakeGeocoder struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (g *fakeGeocoder) ReverseGeocode(lat, lng float64) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.calls++
+	return fmt.Sprintf("Street at %.4f", lat), nil
+}
+
+func TestCreateRideGeocodesEmptyAddresses(t *testing.T) {
+	repo := newFakeRideRepo()
+	g := &fakeGeocoder{}
+	srv, _ := newCreateRideServer(t, repo, 0, func(uc *application.CreateRideUseCase) {
+		uc.SetGeocoder(geocode.NewCache(g))
+	})
+
+	for i := 0; i < 2; i++ {
+		if status, body := createRide(t, srv, "p1"); status != http.StatusCreated {
+			t.Fatalf("request %d: status = %d (%v), want 201", i+1, status, body)
+		}
+		cancelAll(t, repo, "p1")
+	}
+
+	for _, ride := range repo.rides {
+		if got := ride.PickupLocation().Address(); got != "Street at 43.2389" {
+			t.Errorf("pickup address = %q, want the geocoded one", got)
+		}
+		if got := ride.DestLocation().Address(); got != "Street at 43.2220" {
+			t.Errorf("destination address = %q, want the geocoded one", got)
+		}
+	}
+	// Pickup and destination once each; the second ride is served from the cache
+	if g.calls != 2 {
+		t.Errorf("geocoder called %d times, want 2", g.calls)
+	}
+}
+
+func TestCreateRideRejectsInvalidFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		code    string
+		field   string
+		message string
+	}{
+		{
+			"pickup latitude",
+			`{"pickup_latitude":91,"pickup_longitude":76.88,"destination_latitude":43.22,"destination_longitude":76.85,"ride_type":"ECONOMY"}`,
+			CodeInvalidCoordinates, "pickup_latitude", "must be between -90 and 90",
+		},
+		{
+			"pickup longitude",
+			`{"pickup_latitude":43.23,"pickup_longitude":-180.5,"destination_latitude":43.22,"destination_longitude":76.85,"ride_type":"ECONOMY"}`,
+			CodeInvalidCoordinates, "pickup_longitude", "must be between -180 and 180",
+		},
+		{
+			"destination latitude",
+			`{"pickup_latitude":43.23,"pickup_longitude":76.88,"destination_latitude":-90.1,"destination_longitude":76.85,"ride_type":"ECONOMY"}`,
+			CodeInvalidCoordinates, "destination_latitude", "must be between -90 and 90",
+		},
+		{
+			"destination longitude",
+			`{"pickup_latitude":43.23,"pickup_longitude":76.88,"destination_latitude":43.22,"destination_longitude":200,"ride_type":"ECONOMY"}`,
+			CodeInvalidCoordinates, "destination_longitude", "must be between -180 and 180",
+		},
+		{
+			"unknown ride type",
+			`{"pickup_latitude":43.23,"pickup_longitude":76.88,"destination_latitude":43.22,"destination_longitude":76.85,"ride_type":"HELICOPTER"}`,
+			CodeInvalidRideType, "ride_type", "must be one of ECONOMY, PREMIUM, LUXURY",
+		},
+		{
+			"missing ride type",
+			`{"pickup_latitude":43.23,"pickup_longitude":76.88,"destination_latitude":43.22,"destination_longitude":76.85}`,
+			CodeInvalidRideType, "ride_type", "must be one of ECONOMY, PREMIUM, LUXURY",
+		},
+		{
+			"same pickup and destination",
+			`{"pickup_latitude":43.23,"pickup_longitude":76.88,"destination_latitude":43.23,"destination_longitude":76.88,"ride_type":"ECONOMY"}`,
+			CodeSameLocation, "destination", "must be different from pickup",
+		},
+		{
+			"too many passengers",
+			`{"pickup_latitude":43.23,"pickup_longitude":76.88,"destination_latitude":43.22,"destination_longitude":76.85,"ride_type":"ECONOMY","passenger_count":9}`,
+			CodeInvalidPassengers, "passenger_count", "must be between 1 and 8",
+		},
+		{
+			"negative passengers",
+			`{"pickup_latitude":43.23,"pickup_longitude":76.88,"destination_latitude":43.22,"destination_longitude":76.85,"ride_type":"ECONOMY","passenger_count":-1}`,
+			CodeInvalidPassengers, "passenger_count", "must be between 1 and 8",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRideRepo()
+			srv, _ := newCreateRideServer(t, repo, 0)
+
+			status, body := postRide(t, srv, "p1", tt.body)
+			if status != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", status)
+			}
+			if body["code"] != tt.code || body["message"] != tt.field+" "+tt.message {
+				t.Errorf("error = %v %q, want %s %q", body["code"], body["message"], tt.code, tt.field+" "+tt.message)
+			}
+			errs, _ := body["errors"].([]interface{})
+			if len(errs) != 1 {
+				t.Fatalf("errors = %v, want only %s", body["errors"], tt.field)
+			}
+			if fe := errs[0].(map[string]interface{}); fe["field"] != tt.field || fe["message"] != tt.message {
+				t.Errorf("field error = %v, want %s %q", fe, tt.field, tt.message)
+			}
+			if len(repo.rides) != 0 {
+				t.Errorf("%d rides stored, want the request stopped at the handler", len(repo.rides))
+			}
+		})
+	}
+}
+
+func TestCreateRideAcceptsBoundaryCoordinates(t *testing.T) {
+	repo := newFakeRideRepo()
+	srv, _ := newCreateRideServer(t, repo, 0)
+
+	// In range, if not somewhere the service area would allow
+	body := `{"pickup_latitude":-90,"pickup_longitude":-180,"destination_latitude":90,"destination_longitude":180,"ride_type":"LUXURY"}`
+	status, resp := postRide(t, srv, "p1", body)
+	if status == http.StatusBadRequest && (resp["code"] == CodeInvalidCoordinates || resp["code"] == CodeInvalidRideType) {
+		t.Errorf("boundary coordinates rejected at the edge: %v", resp)
+	}
+}
+
+func TestCreateRideReportsEveryInvalidField(t *testing.T) {
+	repo := newFakeRideRepo()
+	srv, _ := newCreateRideServer(t, repo, 0)
+
+	body := `{"pickup_latitude":91,"pickup_longitude":76.88,"destination_latitude":43.22,"destination_longitude":200,"ride_type":"HELICOPTER"}`
+	status, resp := postRide(t, srv, "p1", body)
+	if status != http.StatusBadRequest || resp["code"] != CodeInvalidCoordinates {
+		t.Fatalf("status = %d, code = %v; want 400 with the first problem's code %s", status, resp["code"], CodeInvalidCoordinates)
+	}
+
+	var fields []string
+	for _, e := range resp["errors"].([]interface{}) {
+		fields = append(fields, e.(map[string]interface{})["field"].(string))
+	}
+	want := []string{"pickup_latitude", "destination_longitude", "ride_type"}
+	if strings.Join(fields, ",") != strings.Join(want, ",") {
+		t.Errorf("invalid fields = %v, want %v", fields, want)
+	}
+}
+
+// almatyArea is a box around Almaty with a 30 km ride limit
+func almatyArea(uc *application.CreateRideUseCase) {
+	uc.SetServiceArea(domain.ServiceArea{MinLat: 43.0, MinLng: 76.6, MaxLat: 43.5, MaxLng: 77.2})
+	uc.SetMaxDistance(30)
+}
+
+func TestCreateRideInsideServiceArea(t *testing.T) {
+	repo := newFakeRideRepo()
+	srv, _ := newCreateRideServer(t, repo, 0, almatyArea)
+
+	if status, body := createRide(t, srv, "p1"); status != http.StatusCreated {
+		t.Errorf("status = %d (%v), want 201", status, body)
+	}
+}
+
+func TestCreateRideRejectsServiceLimits(t *testing.T) {
+	maxDistanceOnly := func(uc *application.CreateRideUseCase) { uc.SetMaxDistance(30) }
+	tests := []struct {
+		name, body, code string
+		configure        func(*application.CreateRideUseCase)
+	}{
+		{
+			// Almaty to Astana, ~970 km, with no area configured
+			name:      "over the maximum distance",
+			body:      `{"pickup_latitude":43.238949,"pickup_longitude":76.889709,"destination_latitude":51.128207,"destination_longitude":71.430411,"ride_type":"ECONOMY"}`,
+			code:      CodeRideTooLong,
+			configure: maxDistanceOnly,
+		},
+		{
+			name:      "pickup outside the area",
+			body:      `{"pickup_latitude":43.6,"pickup_longitude":76.889709,"destination_latitude":43.222015,"destination_longitude":76.851511,"ride_type":"ECONOMY"}`,
+			code:      CodeOutsideServiceArea,
+			configure: almatyArea,
+		},
+		{
+			name:      "destination outside the area",
+			body:      `{"pickup_latitude":43.238949,"pickup_longitude":76.889709,"destination_latitude":43.222015,"destination_longitude":77.3,"ride_type":"ECONOMY"}`,
+			code:      CodeOutsideServiceArea,
+			configure: almatyArea,
+		},
+		{
+			// Inside the box, but the box is wider than the limit
+			name:      "across the area",
+			body:      `{"pickup_latitude":43.01,"pickup_longitude":76.61,"destination_latitude":43.49,"destination_longitude":77.19,"ride_type":"ECONOMY"}`,
+			code:      CodeRideTooLong,
+			configure: almatyArea,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRideRepo()
+			srv, _ := newCreateRideServer(t, repo, 0, tt.configure)
+
+			status, body := postRide(t, srv, "p1", tt.body)
+			if status != http.StatusBadRequest || body["code"] != tt.code {
+				t.Errorf("status = %d, code = %v; want 400 %s", status, body["code"], tt.code)
+			}
+			if n := len(repo.rides); n != 0 {
+				t.Errorf("%d rides stored, want none", n)
+			}
+		})
+	}
+}
+
+// rideNorth returns a ride request whose destination is dLat degrees north of
+// the pickup; 0.0009 degrees is about 100 m
+func rideNorth(dLat float64) string {
+	return fmt.Sprintf(`{"pickup_latitude":43.238949,"pickup_longitude":76.889709,"destination_latitude":%f,"destination_longitude":76.889709,"ride_type":"ECONOMY"}`, 43.238949+dLat)
+}
+
+func TestCreateRideMinimumDistance(t *testing.T) {
+	tests := []struct {
+		name       string
+		minKm      float64
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"identical coordinates", 0.1, rideNorth(0), http.StatusBadRequest, CodeSameLocation},
+		{"identical with no minimum", 0, rideNorth(0), http.StatusBadRequest, CodeSameLocation},
+		{"50 m apart", 0.1, rideNorth(0.00045), http.StatusBadRequest, CodeRideTooShort},
+		{"just over the minimum", 0.1, rideNorth(0.00091), http.StatusCreated, ""},
+		{"10 m apart with no minimum", 0, rideNorth(0.00009), http.StatusCreated, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRideRepo()
+			srv, _ := newCreateRideServer(t, repo, 0, func(uc *application.CreateRideUseCase) { uc.SetMinDistance(tt.minKm) })
+
+			status, body := postRide(t, srv, "p1", tt.body)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d (%v), want %d", status, body, tt.wantStatus)
+			}
+			if tt.wantCode != "" && body["code"] != tt.wantCode {
+				t.Errorf("code = %v, want %s", body["code"], tt.wantCode)
+			}
+			wantStored := 0
+			if tt.wantStatus == http.StatusCreated {
+				wantStored = 1
+			}
+			if len(repo.rides) != wantStored {
+				t.Errorf("%d rides stored, want %d", len(repo.rides), wantStored)
+			}
+		})
+	}
+}
+
+func TestCreateRideKeepsPassengerCount(t *testing.T) {
+	repo := newFakeRideRepo()
+	srv, _ := newCreateRideServer(t, repo, 0)
+
+	withCount := strings.TrimSuffix(createRideBody, "}") + `,"passenger_count":4}`
+	if status, body := postRide(t, srv, "p1", withCount); status != http.StatusCreated {
+		t.Fatalf("status = %d (%v), want 201", status, body)
+	}
+	if status, body := createRide(t, srv, "p2"); status != http.StatusCreated {
+		t.Fatalf("status = %d (%v), want 201", status, body)
+	}
+
+	want := map[string]int{"p1": 4, "p2": 1}
+	for _, ride := range repo.rides {
+		if got := ride.PassengerCount(); got != want[ride.PassengerID()] {
+			t.Errorf("%s's ride needs %d seats, want %d", ride.PassengerID(), got, want[ride.PassengerID()])
+		}
+	}
+	if len(repo.rides) != 2 {
+		t.Errorf("%d rides stored, want 2", len(repo.rides))
+	}
+}
+
+// newPricedRideServer serves POST /rides with fares from rates
+func newPricedRideServer(t *testing.T, repo *fakeRideRepo, rates map[string]pricing.Rates) *httptest.Server {
+	t.Helper()
+	table, err := pricing.NewTable(rates, 30)
+	if err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+	uc := application.NewCreateRideUseCase(repo, &fakeEventPublisher{}, domain.NewFareCalculatorWithTable(table), nopLogger{})
+	h := NewRideHandler(uc, nil, nopLogger{})
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /rides", withAuth(h.CreateRide))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCreateRideRequiresPricingForTheRideType(t *testing.T) {
+	repo := newFakeRideRepo()
+	srv := newPricedRideServer(t, repo, map[string]pricing.Rates{
+		"ECONOMY": {Base: 100, PerKm: 15},
+		"PREMIUM": {},
+	})
+
+	for _, rideType := range []string{"PREMIUM", "LUXURY"} {
+		body := strings.Replace(createRideBody, "ECONOMY", rideType, 1)
+		status, resp := postRide(t, srv, "p-"+rideType, body)
+		if status != http.StatusUnprocessableEntity || resp["code"] != CodeRideTypeNotPriced {
+			t.Errorf("%s: status = %d (%v), want 422 %s", rideType, status, resp, CodeRideTypeNotPriced)
+		}
+	}
+	if len(repo.rides) != 0 {
+		t.Errorf("%d rides stored for unpriced types, want none", len(repo.rides))
+	}
+
+	status, resp := createRide(t, srv, "p-economy")
+	if status != http.StatusCreated {
+		t.Fatalf("ECONOMY: status = %d (%v), want 201", status, resp)
+	}
+	if fare, _ := resp["estimated_fare"].(float64); fare <= 100 {
+		t.Errorf("estimated_fare = %v, want the base fare plus distance", resp["estimated_fare"])
+	}
+}
+
+// cancelRide posts a cancellation of rideID as passengerID
+func cancelRide(t *testing.T, repo *fakeRideRepo, events *fakeEventPublisher, rideID, passengerID, body string) (int, map[string]interface{}) {
+	t.Helper()
+	uc := application.NewCancelRideUseCase(repo, events, nopLogger{})
+	h := NewRideHandler(nil, uc, nopLogger{})
+	mux := http.NewServeMux()
+	mux.Handle("POST /rides/{ride_id}/cancel", withAuth(h.CancelRide))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/rides/"+rideID+"/cancel", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", bearer(t, passengerID, auth.RolePassenger))
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("POST cancel: %v", err)
+	}
+	defer resp.Body.Close()
+	var decoded map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return resp.StatusCode, decoded
+}
+
+func TestPassengerCancellationRecordsTheParty(t *testing.T) {
+	repo := newFakeRideRepo(testRide(t, "ride-1", "passenger-1", domain.StatusMatched))
+	events := &fakeEventPublisher{}
+
+	status, body := cancelRide(t, repo, events, "ride-1", "passenger-1", `{"reason":"changed plans"}`)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d (%v), want 200", status, body)
+	}
+	if body["status"] != "CANCELLED" || body["reason"] != "changed plans" || body["cancelled_by"] != domain.CancelledByPassenger {
+		t.Errorf("response = %v, want CANCELLED by PASSENGER with the reason", body)
+	}
+	at, _ := body["cancelled_at"].(string)
+	if _, err := time.Parse(time.RFC3339, at); err != nil || !strings.HasSuffix(at, "Z") {
+		t.Errorf("cancelled_at = %q, want an RFC 3339 UTC time", at)
+	}
+
+	if ride := repo.rides["ride-1"]; ride.CancelledBy() != domain.CancelledByPassenger || ride.CancelledAt() == nil {
+		t.Errorf("stored ride cancelled by %q at %v, want PASSENGER with a time", ride.CancelledBy(), ride.CancelledAt())
+	}
+	if len(repo.events) != 1 {
+		t.Fatalf("ride_events got %d events, want the cancellation", len(repo.events))
+	}
+	ev, ok := repo.events[0].(domain.RideCancelledEvent)
+	if !ok || ev.CancelledBy != domain.CancelledByPassenger || ev.Reason != "changed plans" || ev.DriverID == nil || *ev.DriverID != "driver-1" {
+		t.Errorf("recorded %+v, want a passenger cancellation naming the driver", repo.events[0])
+	}
+	if len(events.events) != 1 || events.events[0].(domain.RideCancelledEvent).CancelledBy != domain.CancelledByPassenger {
+		t.Errorf("published %v, want the passenger cancellation", events.events)
+	}
+}
+
+func TestCancellationOfFinishedRideRecordsNothing(t *testing.T) {
+	repo := newFakeRideRepo(testRide(t, "ride-1", "passenger-1", domain.StatusCompleted))
+	events := &fakeEventPublisher{}
+
+	if status, body := cancelRide(t, repo, events, "ride-1", "passenger-1", ""); status < 400 {
+		t.Fatalf("status = %d (%v), want an error", status, body)
+	}
+	if ride := repo.rides["ride-1"]; ride.CancelledBy() != "" {
+		t.Errorf("completed ride marked cancelled by %q", ride.CancelledBy())
+	}
+	if len(repo.events) != 0 || len(events.events) != 0 {
+		t.Errorf("recorded %v and published %v, want nothing", repo.events, events.events)
+	}
+}
+
+func TestCreateRideExplainsUndecodableBodies(t *testing.T) {
+	tests := []struct {
+		name, body, want string
+	}{
+		{"empty", ``, "Request body is empty"},
+		{"malformed", `{"pickup_latitude":43.23 "pickup_longitude":76.88}`, "Request body is malformed JSON at byte 26: invalid character '\"' after object key:value pair"},
+		{"wrong type", `{"pickup_latitude":43.23,"pickup_longitude":76.88,"destination_latitude":43.22,"destination_longitude":76.85,"ride_type":"ECONOMY","passenger_count":"two"}`, `Field "passenger_count" must be an integer, got string`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRideRepo()
+			srv, _ := newCreateRideServer(t, repo, 0)
+
+			status, body := postRide(t, srv, "p1", tt.body)
+			if status != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400", status)
+			}
+			if body["code"] != CodeInvalidRequest || body["message"] != tt.want {
+				t.Errorf("error = %v %q, want %s %q", body["code"], body["message"], CodeInvalidRequest, tt.want)
+			}
+			if len(repo.rides) != 0 {
+				t.Errorf("%d rides saved, want none", len(repo.rides))
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"ride-hail/internal/ride-service/application"
+	"ride-hail/internal/ride-service/domain"
+	"ride-hail/pkg/auth"
+	"ride-hail/pkg/logger"
+	"ride-hail/pkg/validation"
+)
+
+// MessageHandler relays chat messages between a ride's passenger and driver
+type MessageHandler struct {
+	sendMessageUseCase *application.SendMessageUseCase
+	logger             logger.Logger
+}
+
+// NewMessageHandler creates a new ride message handler
+func NewMessageHandler(sendMessageUseCase *application.SendMessageUseCase, logger logger.Logger) *MessageHandler {
+	return &MessageHandler{
+		sendMessageUseCase: sendMessageUseCase,
+		logger:             logger,
+	}
+}
+
+// SendMessageRequest represents the HTTP request for sending a ride message
+type SendMessageRequest struct {
+	Text string `json:"text"`
+}
+
+// SendMessage handles POST /rides/{ride_id}/messages
+func (h *MessageHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
+	rideID := r.PathValue("ride_id")
+	if rideID == "" {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "Ride ID is required")
+		return
+	}
+
+	claims, ok := auth.GetClaims(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized - missing claims")
+		return
+	}
+
+	var req SendMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, validation.DecodeErrorMessage(err))
+		return
+	}
+
+	result, err := h.sendMessageUseCase.Execute(r.Context(), application.SendMessageCommand{
+		RideID:   rideID,
+		SenderID: claims.UserID,
+		Text:     req.Text,
+	})
+	if err != nil {
+		if errors.Is(err, domain.ErrNotRideParticipant) {
+			h.logger.WithFields(logger.LogFields{
+				"ride_id": rideID,
+				"user_id": claims.UserID,
+				"role":    claims.Role,
+			}).Warn("ride_message_rejected", "Sender is not a party to the ride")
+		} else if _, code := mapError(err); code == CodeInternal {
+			h.logger.WithFields(logger.LogFields{
+				"ride_id": rideID,
+				"error":   err.Error(),
+			}).Error("send_ride_message_failed", err)
+		}
+		writeDomainError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(result)
+}
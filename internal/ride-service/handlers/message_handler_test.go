@@ -0,0 +1,196 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ride-hail/internal/ride-service/application"
+	"ride-hail/internal/ride-service/domain"
+	"ride-hail/pkg/auth"
+	"ride-hail/pkg/wsmsg"
+)
+
+// messageServer serves the message relay over repo, with passenger-1 connected
+type messageServer struct {
+	*httptest.Server
+	events     *fakeEventPublisher
+	passengers *fakePassengers
+}
+
+func newMessageServer(t *testing.T, repo *fakeRideRepo) *messageServer {
+	t.Helper()
+	s := &messageServer{
+		events:     &fakeEventPublisher{},
+		passengers: &fakePassengers{connected: map[string]bool{"passenger-1": true}},
+	}
+	uc := application.NewSendMessageUseCase(repo, s.events, s.passengers, nopLogger{})
+	h := NewMessageHandler(uc, nopLogger{})
+	mux := http.NewServeMux()
+	mux.Handle("POST /rides/{ride_id}/messages", withAuth(h.SendMessage))
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *messageServer) send(t *testing.T, rideID, authorization, text string) *http.Response {
+	t.Helper()
+	body, _ := json.Marshal(SendMessageRequest{Text: text})
+	req, err := http.NewRequest(http.MethodPost, s.URL+"/rides/"+rideID+"/messages", strings.NewReader(string(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", authorization)
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		t.Fatalf("POST message: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func decodeMessage(t *testing.T, resp *http.Response) application.MessageDTO {
+	t.Helper()
+	var m application.MessageDTO
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return m
+}
+
+func TestDriverMessageReachesThePassenger(t *testing.T) {
+	repo := newFakeRideRepo(testRide(t, "ride-1", "passenger-1", domain.StatusEnRoute))
+	srv := newMessageServer(t, repo)
+
+	resp := srv.send(t, "ride-1", bearer(t, "driver-1", auth.RoleDriver), "  Two minutes away  ")
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want 201", resp.StatusCode)
+	}
+	m := decodeMessage(t, resp)
+	if m.SenderRole != domain.SenderDriver || m.RecipientID != "passenger-1" || m.Text != "Two minutes away" || !m.Delivered {
+		t.Errorf("message = %+v, want a delivered driver message to passenger-1", m)
+	}
+
+	sent := srv.passengers.sent["passenger-1"]
+	if len(sent) != 1 {
+		t.Fatalf("passenger was pushed %d messages, want 1", len(sent))
+	}
+	n, _ := sent[0].(wsmsg.Notification)
+	if n["type"] != wsmsg.TypeRideMessage || n["text"] != "Two minutes away" || n["sender_id"] != "driver-1" || n["message_id"] != m.ID {
+		t.Errorf("pushed %v, want the driver's ride message", sent[0])
+	}
+	if len(srv.events.events) != 0 {
+		t.Errorf("published %v, want nothing for a driver's message", srv.events.events)
+	}
+	if len(repo.messages) != 1 || repo.messages[0].ID != m.ID {
+		t.Errorf("stored %v, want the message in the thread", repo.messages)
+	}
+}
+
+func TestPassengerMessageIsPublishedForTheDriver(t *testing.T) {
+	repo := newFakeRideRepo(testRide(t, "ride-1", "passenger-1", domain.StatusMatched))
+	srv := newMessageServer(t, repo)
+
+	resp := srv.send(t, "ride-1", bearer(t, "passenger-1", auth.RolePassenger), "I'm by the blue gate")
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want 201", resp.StatusCode)
+	}
+	m := decodeMessage(t, resp)
+	if m.SenderRole != domain.SenderPassenger || m.RecipientID != "driver-1" || !m.Delivered {
+		t.Errorf("message = %+v, want a delivered passenger message to driver-1", m)
+	}
+
+	if len(srv.events.events) != 1 {
+		t.Fatalf("published %d events, want 1", len(srv.events.events))
+	}
+	e, ok := srv.events.events[0].(domain.RideMessageSentEvent)
+	if !ok || e.RideID != "ride-1" || e.RecipientID != "driver-1" || e.SenderID != "passenger-1" || e.Text != "I'm by the blue gate" || e.MessageID != m.ID {
+		t.Errorf("published %+v, want the passenger's message for driver-1", srv.events.events[0])
+	}
+	if len(srv.passengers.sent) != 0 {
+		t.Errorf("pushed %v to passengers, want nothing", srv.passengers.sent)
+	}
+	if len(repo.messages) != 1 {
+		t.Errorf("stored %d messages, want 1", len(repo.messages))
+	}
+}
+
+func TestMessageToAnOfflinePassengerIsKept(t *testing.T) {
+	repo := newFakeRideRepo(testRide(t, "ride-1", "passenger-2", domain.StatusEnRoute))
+	srv := newMessageServer(t, repo)
+
+	resp := srv.send(t, "ride-1", bearer(t, "driver-1", auth.RoleDriver), "Here")
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want 201", resp.StatusCode)
+	}
+	if m := decodeMessage(t, resp); m.Delivered {
+		t.Error("delivered = true for a passenger with no socket")
+	}
+	if len(repo.messages) != 1 {
+		t.Errorf("stored %d messages, want the undelivered one kept", len(repo.messages))
+	}
+}
+
+func TestMessageRejections(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        domain.RideStatus
+		authorization func(t *testing.T) string
+		text          string
+		want          int
+	}{
+		{"another passenger", domain.StatusEnRoute, func(t *testing.T) string { return bearer(t, "passenger-2", auth.RolePassenger) }, "hi", http.StatusForbidden},
+		{"another driver", domain.StatusEnRoute, func(t *testing.T) string { return bearer(t, "driver-2", auth.RoleDriver) }, "hi", http.StatusForbidden},
+		{"no token", domain.StatusEnRoute, func(*testing.T) string { return "" }, "hi", http.StatusUnauthorized},
+		{"not matched yet", domain.StatusRequested, func(t *testing.T) string { return bearer(t, "passenger-1", auth.RolePassenger) }, "hi", http.StatusConflict},
+		{"ride over", domain.StatusCompleted, func(t *testing.T) string { return bearer(t, "driver-1", auth.RoleDriver) }, "hi", http.StatusConflict},
+		{"blank text", domain.StatusEnRoute, func(t *testing.T) string { return bearer(t, "driver-1", auth.RoleDriver) }, "   ", http.StatusBadRequest},
+		{"text too long", domain.StatusEnRoute, func(t *testing.T) string { return bearer(t, "driver-1", auth.RoleDriver) }, strings.Repeat("a", domain.MaxMessageLength+1), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRideRepo(testRide(t, "ride-1", "passenger-1", tt.status))
+			srv := newMessageServer(t, repo)
+
+			resp := srv.send(t, "ride-1", tt.authorization(t), tt.text)
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+			if len(repo.messages) != 0 || len(srv.events.events) != 0 || len(srv.passengers.sent) != 0 {
+				t.Errorf("stored %d, published %d, pushed %d; want nothing", len(repo.messages), len(srv.events.events), len(srv.passengers.sent))
+			}
+		})
+	}
+}
+
+func TestMessageToAnUnknownRide(t *testing.T) {
+	srv := newMessageServer(t, newFakeRideRepo())
+
+	resp := srv.send(t, "ride-9", bearer(t, "passenger-1", auth.RolePassenger), "hi")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestMessageSentAtIsUTC(t *testing.T) {
+	srv := newMessageServer(t, newFakeRideRepo(testRide(t, "ride-1", "passenger-1", domain.StatusEnRoute)))
+
+	resp := srv.send(t, "ride-1", bearer(t, "driver-1", auth.RoleDriver), "Here")
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want 201", resp.StatusCode)
+	}
+	var got struct {
+		SentAt string `json:"sent_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	sentAt, err := time.Parse(time.RFC3339, got.SentAt)
+	if err != nil || sentAt.Location() != time.UTC || sentAt.Nanosecond() != 0 {
+		t.Errorf("sent_at = %q, want RFC 3339 in UTC with whole seconds", got.SentAt)
+	}
+}
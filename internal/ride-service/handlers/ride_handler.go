@@ -27,6 +27,16 @@ func New(db *pgxpool.Pool, rabbit *rabbitmq.Connection, log logger.Logger) *Hand
 }
 
 func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	// Missing exchanges or queues mean messages are silently dropped, so the
+	// service is not ready even though RabbitMQ itself is reachable. What is
+	// missing is only logged; the endpoint is public.
+	if err := h.rabbit.CheckTopology(); err != nil {
+		h.log.Error("health_check", err)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"status": "degraded"})
+		return
+	}
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
@@ -0,0 +1,127 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"ride-hail/internal/ride-service/domain"
+	"ride-hail/pkg/apitime"
+	"ride-hail/pkg/auth"
+	"ride-hail/pkg/logger"
+)
+
+// RouteHandler exports the recorded route of a ride
+type RouteHandler struct {
+	rideRepo domain.RideRepository
+	logger   logger.Logger
+}
+
+// NewRouteHandler creates a new route export handler
+func NewRouteHandler(rideRepo domain.RideRepository, logger logger.Logger) *RouteHandler {
+	return &RouteHandler{
+		rideRepo: rideRepo,
+		logger:   logger,
+	}
+}
+
+// geoJSONFeature is a GeoJSON (RFC 7946) Feature; Geometry is nil when there
+// are too few points to form a line
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   *geoJSONLineString     `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// geoJSONLineString holds positions as [longitude, latitude] pairs
+type geoJSONLineString struct {
+	Type        string       `json:"type"`
+	Coordinates [][2]float64 `json:"coordinates"`
+}
+
+// ExportRoute handles GET /rides/{ride_id}/route.geojson
+func (h *RouteHandler) ExportRoute(w http.ResponseWriter, r *http.Request) {
+	rideID := r.PathValue("ride_id")
+	if rideID == "" {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "Ride ID is required")
+		return
+	}
+
+	claims, ok := auth.GetClaims(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized - missing claims")
+		return
+	}
+
+	ride, err := h.rideRepo.FindByID(r.Context(), rideID)
+	if err != nil {
+		if !errors.Is(err, domain.ErrRideNotFound) {
+			h.logger.WithFields(logger.LogFields{
+				"ride_id": rideID,
+				"error":   err.Error(),
+			}).Error("route_export_failed", err)
+		}
+		writeDomainError(w, err)
+		return
+	}
+
+	// Other users' rides are reported as missing rather than forbidden
+	if !canViewRoute(ride, claims) {
+		writeError(w, http.StatusNotFound, CodeRideNotFound, "Ride not found")
+		return
+	}
+
+	points, err := h.rideRepo.FindRoute(r.Context(), rideID)
+	if err != nil {
+		h.logger.WithFields(logger.LogFields{
+			"ride_id": rideID,
+			"error":   err.Error(),
+		}).Error("route_export_failed", err)
+		writeDomainError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/geo+json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(buildRouteFeature(ride, points))
+}
+
+// canViewRoute allows the ride's passenger, its assigned driver and admins
+func canViewRoute(ride *domain.Ride, claims *auth.AppClaims) bool {
+	switch claims.Role {
+	case auth.RoleAdmin:
+		return true
+	case auth.RolePassenger:
+		return ride.PassengerID() == claims.UserID
+	case auth.RoleDriver:
+		return ride.DriverID() != nil && *ride.DriverID() == claims.UserID
+	}
+	return false
+}
+
+// buildRouteFeature turns the recorded points into a LineString feature,
+// keeping each point's timestamp in the same order under "timestamps"
+func buildRouteFeature(ride *domain.Ride, points []domain.RoutePoint) geoJSONFeature {
+	coordinates := make([][2]float64, 0, len(points))
+	timestamps := make([]apitime.Time, 0, len(points))
+	for _, p := range points {
+		coordinates = append(coordinates, [2]float64{p.Longitude, p.Latitude})
+		timestamps = append(timestamps, apitime.New(p.RecordedAt))
+	}
+
+	feature := geoJSONFeature{
+		Type: "Feature",
+		Properties: map[string]interface{}{
+			"ride_id":     ride.ID(),
+			"ride_number": ride.RideNumber(),
+			"status":      ride.Status().String(),
+			"point_count": len(points),
+			"timestamps":  timestamps,
+		},
+	}
+	// A LineString needs at least two positions
+	if len(coordinates) >= 2 {
+		feature.Geometry = &geoJSONLineString{Type: "LineString", Coordinates: coordinates}
+	}
+	return feature
+}
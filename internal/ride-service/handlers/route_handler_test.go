@@ -0,0 +1,178 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ride-hail/internal/ride-service/domain"
+	"ride-hail/pkg/auth"
+)
+
+// routeFeature is the GeoJSON the route export returns
+type routeFeature struct {
+	Type     string `json:"type"`
+	Geometry *struct {
+		Type        string       `json:"type"`
+		Coordinates [][2]float64 `json:"coordinates"`
+	} `json:"geometry"`
+	Properties struct {
+		RideID     string   `json:"ride_id"`
+		PointCount int      `json:"point_count"`
+		Timestamps []string `json:"timestamps"`
+	} `json:"properties"`
+}
+
+func newRouteServer(t *testing.T, repo *fakeRideRepo) *httptest.Server {
+	t.Helper()
+	h := NewRouteHandler(repo, nopLogger{})
+	mux := http.NewServeMux()
+	mux.Handle("GET /rides/{ride_id}/route.geojson", withAuth(h.ExportRoute))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func getRoute(t *testing.T, srv *httptest.Server, rideID, authorization string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/rides/"+rideID+"/route.geojson", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", authorization)
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("GET route: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// completedRideWithRoute returns a repo holding ride-1, driven by driver-1 for
+// passenger-1, with a three-point route
+func completedRideWithRoute(t *testing.T) *fakeRideRepo {
+	repo := newFakeRideRepo(testRide(t, "ride-1", "passenger-1", domain.StatusCompleted))
+	repo.routes = map[string][]domain.RoutePoint{"ride-1": {
+		{Latitude: 43.2389, Longitude: 76.8897, RecordedAt: testNow},
+		{Latitude: 43.2300, Longitude: 76.8700, RecordedAt: testNow.Add(time.Minute)},
+		{Latitude: 43.2220, Longitude: 76.8512, RecordedAt: testNow.Add(2 * time.Minute)},
+	}}
+	return repo
+}
+
+func TestExportRouteReturnsLineStringInRecordedOrder(t *testing.T) {
+	srv := newRouteServer(t, completedRideWithRoute(t))
+
+	resp := getRoute(t, srv, "ride-1", bearer(t, "passenger-1", auth.RolePassenger))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/geo+json" {
+		t.Errorf("Content-Type = %q, want application/geo+json", ct)
+	}
+	var f routeFeature
+	if err := json.NewDecoder(resp.Body).Decode(&f); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if f.Type != "Feature" || f.Geometry == nil || f.Geometry.Type != "LineString" {
+		t.Fatalf("got %+v, want a LineString feature", f)
+	}
+	// GeoJSON positions are longitude first
+	want := [][2]float64{{76.8897, 43.2389}, {76.8700, 43.2300}, {76.8512, 43.2220}}
+	if len(f.Geometry.Coordinates) != len(want) {
+		t.Fatalf("coordinates = %v, want %v", f.Geometry.Coordinates, want)
+	}
+	for i := range want {
+		if f.Geometry.Coordinates[i] != want[i] {
+			t.Errorf("coordinates[%d] = %v, want %v", i, f.Geometry.Coordinates[i], want[i])
+		}
+	}
+	if f.Properties.RideID != "ride-1" || f.Properties.PointCount != 3 {
+		t.Errorf("properties = %+v, want ride-1 with 3 points", f.Properties)
+	}
+	if n := len(f.Properties.Timestamps); n != 3 || f.Properties.Timestamps[0] >= f.Properties.Timestamps[2] {
+		t.Errorf("timestamps = %v, want one per point, oldest first", f.Properties.Timestamps)
+	}
+}
+
+func TestExportRouteWithTooFewPointsHasNoGeometry(t *testing.T) {
+	for name, points := range map[string][]domain.RoutePoint{
+		"none": nil,
+		"one":  {{Latitude: 43.2389, Longitude: 76.8897, RecordedAt: testNow}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			repo := newFakeRideRepo(testRide(t, "ride-1", "passenger-1", domain.StatusCompleted))
+			repo.routes = map[string][]domain.RoutePoint{"ride-1": points}
+			srv := newRouteServer(t, repo)
+
+			resp := getRoute(t, srv, "ride-1", bearer(t, "passenger-1", auth.RolePassenger))
+			var raw map[string]json.RawMessage
+			if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			// RFC 7946 allows a null geometry; a one-point LineString is invalid
+			if string(raw["geometry"]) != "null" {
+				t.Errorf("geometry = %s, want null", raw["geometry"])
+			}
+		})
+	}
+}
+
+func TestExportRouteOnlyForTheRidesParticipants(t *testing.T) {
+	srv := newRouteServer(t, completedRideWithRoute(t))
+
+	tests := []struct {
+		name       string
+		userID     string
+		role       auth.Role
+		wantStatus int
+	}{
+		{"passenger", "passenger-1", auth.RolePassenger, http.StatusOK},
+		{"driver", "driver-1", auth.RoleDriver, http.StatusOK},
+		{"admin", "admin-1", auth.RoleAdmin, http.StatusOK},
+		{"another passenger", "passenger-2", auth.RolePassenger, http.StatusNotFound},
+		{"another driver", "driver-2", auth.RoleDriver, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := getRoute(t, srv, "ride-1", bearer(t, tt.userID, tt.role))
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+
+	if resp := getRoute(t, srv, "ride-1", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("without a token: status = %d, want 401", resp.StatusCode)
+	}
+	if resp := getRoute(t, srv, "missing", bearer(t, "admin-1", auth.RoleAdmin)); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown ride: status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestExportRouteTimestampsAreUTC(t *testing.T) {
+	almaty := time.FixedZone("ALMT", 5*60*60)
+	repo := newFakeRideRepo(testRide(t, "ride-1", "passenger-1", domain.StatusCompleted))
+	repo.routes = map[string][]domain.RoutePoint{"ride-1": {
+		{Latitude: 43.2389, Longitude: 76.8897, RecordedAt: time.Date(2024, 12, 16, 15, 30, 0, 250_000_000, almaty)},
+		{Latitude: 43.2220, Longitude: 76.8512, RecordedAt: time.Date(2024, 12, 16, 15, 31, 0, 0, almaty)},
+	}}
+	srv := newRouteServer(t, repo)
+
+	resp := getRoute(t, srv, "ride-1", bearer(t, "passenger-1", auth.RolePassenger))
+	var f routeFeature
+	if err := json.NewDecoder(resp.Body).Decode(&f); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := []string{"2024-12-16T10:30:00Z", "2024-12-16T10:31:00Z"}
+	if len(f.Properties.Timestamps) != len(want) {
+		t.Fatalf("timestamps = %v, want %v", f.Properties.Timestamps, want)
+	}
+	for i, ts := range f.Properties.Timestamps {
+		if _, err := time.Parse(time.RFC3339, ts); err != nil || ts != want[i] {
+			t.Errorf("timestamps[%d] = %q, want %q", i, ts, want[i])
+		}
+	}
+}
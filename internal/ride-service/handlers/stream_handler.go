@@ -0,0 +1,140 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"ride-hail/internal/ride-service/domain"
+	"ride-hail/internal/ride-service/infrastructure/stream"
+	"ride-hail/pkg/apitime"
+	"ride-hail/pkg/auth"
+	"ride-hail/pkg/logger"
+	"ride-hail/pkg/wsmsg"
+)
+
+// streamKeepAlive is how often a comment line is sent to keep proxies from closing idle streams
+const streamKeepAlive = 15 * time.Second
+
+// StreamHandler serves ride updates as Server-Sent Events for clients that can't use WebSockets
+type StreamHandler struct {
+	rideRepo domain.RideRepository
+	hub      *stream.Hub
+	logger   logger.Logger
+}
+
+// NewStreamHandler creates a new SSE handler
+func NewStreamHandler(rideRepo domain.RideRepository, hub *stream.Hub, logger logger.Logger) *StreamHandler {
+	return &StreamHandler{
+		rideRepo: rideRepo,
+		hub:      hub,
+		logger:   logger,
+	}
+}
+
+// StreamRide handles GET /rides/{ride_id}/stream
+func (h *StreamHandler) StreamRide(w http.ResponseWriter, r *http.Request) {
+	rideID := r.PathValue("ride_id")
+	if rideID == "" {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "Ride ID is required")
+		return
+	}
+
+	claims, ok := auth.GetClaims(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized - missing claims")
+		return
+	}
+	if claims.Role != auth.RolePassenger {
+		writeError(w, http.StatusForbidden, CodeForbidden, "Only passengers can stream rides")
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, CodeInternal, "Streaming not supported")
+		return
+	}
+
+	// Ownership check: the ride must belong to the authenticated passenger
+	ride, err := h.rideRepo.FindByPassenger(r.Context(), rideID, claims.UserID)
+	if err != nil {
+		h.logger.WithFields(logger.LogFields{
+			"ride_id":      rideID,
+			"passenger_id": claims.UserID,
+			"error":        err.Error(),
+		}).Error("stream_ride_not_found", err)
+		writeError(w, http.StatusNotFound, CodeRideNotFound, "Ride not found")
+		return
+	}
+
+	// Subscribe before sending the snapshot so no update is missed in between
+	events, unsubscribe := h.hub.Subscribe(rideID)
+	defer unsubscribe()
+
+	// The stream outlives the server's WriteTimeout; lift the deadline for this response
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.Error("stream_clear_write_deadline_failed", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	log := h.logger.WithFields(logger.LogFields{
+		"ride_id":      rideID,
+		"passenger_id": claims.UserID,
+	})
+	log.Info("stream_opened", "Ride SSE stream opened")
+	defer log.Info("stream_closed", "Ride SSE stream closed")
+
+	snapshot := wsmsg.NewNotification(wsmsg.TypeRideStatusUpdate, map[string]interface{}{
+		"ride_id":   ride.ID(),
+		"status":    ride.Status().String(),
+		"timestamp": apitime.Format(time.Now()),
+	})
+	if err := writeSSE(w, wsmsg.TypeRideStatusUpdate, snapshot); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	if !ride.IsActive() {
+		return
+	}
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case event := <-events:
+			if err := writeSSE(w, event.Type, event.Data); err != nil {
+				log.Error("stream_write_failed", err)
+				return
+			}
+			flusher.Flush()
+			if event.Terminal {
+				return
+			}
+		}
+	}
+}
+
+// writeSSE writes a single named Server-Sent Event with a JSON payload
+func writeSSE(w http.ResponseWriter, event wsmsg.Type, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+	return err
+}
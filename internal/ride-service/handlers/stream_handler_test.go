@@ -0,0 +1,225 @@
+package http
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ride-hail/internal/ride-service/domain"
+	"ride-hail/internal/ride-service/infrastructure/stream"
+	"ride-hail/pkg/auth"
+	"ride-hail/pkg/wsmsg"
+)
+
+// sseEvent is one parsed Server-Sent Event
+type sseEvent struct {
+	name string
+	data map[string]interface{}
+}
+
+// readEvent reads the next event, skipping keepalive comments; ok is false at end of stream
+func readEvent(t *testing.T, r *bufio.Reader) (ev sseEvent, ok bool) {
+	t.Helper()
+	for {
+		line, err := r.ReadString('\n')
+		if err == io.EOF {
+			return ev, false
+		}
+		if err != nil {
+			t.Fatalf("read stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && ev.name != "":
+			return ev, true
+		case strings.HasPrefix(line, "event: "):
+			ev.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev.data); err != nil {
+				t.Fatalf("decode event data %q: %v", line, err)
+			}
+		}
+	}
+}
+
+func newStreamServer(t *testing.T, hub *stream.Hub, rides ...*domain.Ride) *httptest.Server {
+	t.Helper()
+	h := NewStreamHandler(newFakeRideRepo(rides...), hub, nopLogger{})
+	mux := http.NewServeMux()
+	mux.Handle("GET /rides/{ride_id}/stream", withAuth(h.StreamRide))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func openStream(t *testing.T, srv *httptest.Server, rideID, authorization string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/rides/"+rideID+"/stream", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", authorization)
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("GET stream: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestStreamRideEmitsUpdatesAndClosesOnCompletion(t *testing.T) {
+	hub := stream.NewHub(nopLogger{})
+	srv := newStreamServer(t, hub, testRide(t, "ride-1", "p1", domain.StatusMatched))
+
+	resp := openStream(t, srv, "ride-1", bearer(t, "p1", auth.RolePassenger))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body := bufio.NewReader(resp.Body)
+
+	// The snapshot arrives once the handler has subscribed, so nothing below is missed
+	snapshot, ok := readEvent(t, body)
+	if !ok || snapshot.name != string(wsmsg.TypeRideStatusUpdate) {
+		t.Fatalf("first event = %+v, want the status snapshot", snapshot)
+	}
+	if snapshot.data["status"] != "MATCHED" || snapshot.data["ride_id"] != "ride-1" {
+		t.Errorf("snapshot = %v, want ride-1 MATCHED", snapshot.data)
+	}
+
+	hub.Publish("ride-1", stream.Event{
+		Type: wsmsg.TypeRideStatusUpdate,
+		Data: map[string]interface{}{"ride_id": "ride-1", "status": "EN_ROUTE"},
+	})
+	hub.Publish("ride-1", stream.Event{
+		Type: wsmsg.TypeDriverLocationUpdate,
+		Data: map[string]interface{}{"ride_id": "ride-1", "latitude": 43.23},
+	})
+	hub.Publish("ride-2", stream.Event{
+		Type: wsmsg.TypeRideStatusUpdate,
+		Data: map[string]interface{}{"ride_id": "ride-2", "status": "CANCELLED"},
+	})
+	hub.End("ride-1", wsmsg.TypeRideStatusUpdate, map[string]interface{}{"ride_id": "ride-1", "status": "COMPLETED"})
+
+	want := []struct{ name, status string }{
+		{string(wsmsg.TypeRideStatusUpdate), "EN_ROUTE"},
+		{string(wsmsg.TypeDriverLocationUpdate), ""},
+		{string(wsmsg.TypeRideStatusUpdate), "COMPLETED"},
+	}
+	for _, w := range want {
+		ev, ok := readEvent(t, body)
+		if !ok {
+			t.Fatalf("stream ended early, want %s", w.name)
+		}
+		if ev.name != w.name || (w.status != "" && ev.data["status"] != w.status) {
+			t.Errorf("event = %s %v, want %s %s", ev.name, ev.data, w.name, w.status)
+		}
+		if ev.data["ride_id"] != "ride-1" {
+			t.Errorf("event for ride %v leaked into ride-1's stream", ev.data["ride_id"])
+		}
+	}
+
+	if ev, ok := readEvent(t, body); ok {
+		t.Errorf("got %+v after COMPLETED, want the stream closed", ev)
+	}
+	waitFor(t, func() bool { return hub.SubscriberCount("ride-1") == 0 })
+}
+
+func TestStreamRideEndsAfterSnapshotForFinishedRide(t *testing.T) {
+	hub := stream.NewHub(nopLogger{})
+	srv := newStreamServer(t, hub, testRide(t, "ride-1", "p1", domain.StatusCompleted))
+
+	body := bufio.NewReader(openStream(t, srv, "ride-1", bearer(t, "p1", auth.RolePassenger)).Body)
+	snapshot, ok := readEvent(t, body)
+	if !ok || snapshot.data["status"] != "COMPLETED" {
+		t.Fatalf("snapshot = %+v, want status COMPLETED", snapshot)
+	}
+	if ev, ok := readEvent(t, body); ok {
+		t.Errorf("got %+v, want the stream closed after the snapshot", ev)
+	}
+}
+
+func TestStreamRideClosesWhenClientLeaves(t *testing.T) {
+	hub := stream.NewHub(nopLogger{})
+	srv := newStreamServer(t, hub, testRide(t, "ride-1", "p1", domain.StatusInProgress))
+
+	resp := openStream(t, srv, "ride-1", bearer(t, "p1", auth.RolePassenger))
+	if _, ok := readEvent(t, bufio.NewReader(resp.Body)); !ok {
+		t.Fatal("no snapshot")
+	}
+	resp.Body.Close()
+
+	waitFor(t, func() bool { return hub.SubscriberCount("ride-1") == 0 })
+}
+
+func TestStreamRideRejectsOthers(t *testing.T) {
+	hub := stream.NewHub(nopLogger{})
+	srv := newStreamServer(t, hub, testRide(t, "ride-1", "p1", domain.StatusMatched))
+
+	tests := []struct {
+		name          string
+		rideID        string
+		authorization string
+		want          int
+	}{
+		{"no token", "ride-1", "", http.StatusUnauthorized},
+		{"driver", "ride-1", bearer(t, "driver-1", auth.RoleDriver), http.StatusForbidden},
+		{"other passenger", "ride-1", bearer(t, "p2", auth.RolePassenger), http.StatusNotFound},
+		{"unknown ride", "ride-9", bearer(t, "p1", auth.RolePassenger), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := openStream(t, srv, tt.rideID, tt.authorization)
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+	if n := hub.SubscriberCount("ride-1"); n != 0 {
+		t.Errorf("%d subscribers left, want rejected requests never to subscribe", n)
+	}
+}
+
+// waitFor polls cond until it holds or a second has passed
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 1s")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestStreamRideOutlivesServerWriteTimeout(t *testing.T) {
+	hub := stream.NewHub(nopLogger{})
+	h := NewStreamHandler(newFakeRideRepo(testRide(t, "ride-1", "p1", domain.StatusMatched)), hub, nopLogger{})
+	mux := http.NewServeMux()
+	mux.Handle("GET /rides/{ride_id}/stream", withAuth(h.StreamRide))
+	srv := httptest.NewUnstartedServer(mux)
+	srv.Config.WriteTimeout = 50 * time.Millisecond
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	resp := openStream(t, srv, "ride-1", bearer(t, "p1", auth.RolePassenger))
+	body := bufio.NewReader(resp.Body)
+	if _, ok := readEvent(t, body); !ok {
+		t.Fatal("stream ended before the snapshot")
+	}
+
+	time.Sleep(4 * srv.Config.WriteTimeout)
+	hub.End("ride-1", wsmsg.TypeRideStatusUpdate, map[string]interface{}{"ride_id": "ride-1", "status": "COMPLETED"})
+
+	ev, ok := readEvent(t, body)
+	if !ok || ev.data["status"] != "COMPLETED" {
+		t.Errorf("event after the write timeout = %+v (ok=%v), want COMPLETED", ev, ok)
+	}
+}
@@ -0,0 +1,71 @@
+package http
+
+import (
+	"fmt"
+	"strings"
+
+	"ride-hail/internal/ride-service/domain"
+	"ride-hail/pkg/geo"
+	"ride-hail/pkg/validation"
+)
+
+// validateCreateRide checks the request fields before they reach the use case.
+// It returns every invalid field along with the error code of the first one,
+// or an empty code if the request is valid.
+func validateCreateRide(req CreateRideRequest) (string, validation.Errors) {
+	var (
+		code string
+		errs validation.Errors
+	)
+	fail := func(c, field, message string) {
+		if code == "" {
+			code = c
+		}
+		errs.Add(field, message)
+	}
+
+	coords := []struct {
+		field string
+		value float64
+		valid func(float64) bool
+		rng   string
+	}{
+		{"pickup_latitude", req.PickupLatitude, geo.ValidLatitude, "-90 and 90"},
+		{"pickup_longitude", req.PickupLongitude, geo.ValidLongitude, "-180 and 180"},
+		{"destination_latitude", req.DestinationLatitude, geo.ValidLatitude, "-90 and 90"},
+		{"destination_longitude", req.DestinationLongitude, geo.ValidLongitude, "-180 and 180"},
+	}
+	for _, c := range coords {
+		if !c.valid(c.value) {
+			fail(CodeInvalidCoordinates, c.field, "must be between "+c.rng)
+		}
+	}
+
+	if !domain.RideType(req.RideType).IsValid() {
+		fail(CodeInvalidRideType, "ride_type", fmt.Sprintf("must be one of %s", strings.Join([]string{
+			domain.RideTypeEconomy.String(), domain.RideTypePremium.String(), domain.RideTypeLuxury.String(),
+		}, ", ")))
+	}
+
+	if req.PassengerCount < 0 || req.PassengerCount > domain.MaxPassengerCount {
+		fail(CodeInvalidPassengers, "passenger_count", fmt.Sprintf("must be between 1 and %d", domain.MaxPassengerCount))
+	}
+
+	if code != CodeInvalidCoordinates &&
+		req.PickupLatitude == req.DestinationLatitude && req.PickupLongitude == req.DestinationLongitude {
+		fail(CodeSameLocation, "destination", "must be different from pickup")
+	}
+	return code, errs
+}
+
+// validateChangeDestination checks the new destination coordinates, returning
+// the code of the first invalid field or an empty code
+func validateChangeDestination(req ChangeDestinationRequest) (string, validation.Errors) {
+	var errs validation.Errors
+	errs.Check(geo.ValidLatitude(req.DestinationLatitude), "destination_latitude", "must be between -90 and 90")
+	errs.Check(geo.ValidLongitude(req.DestinationLongitude), "destination_longitude", "must be between -180 and 180")
+	if len(errs) > 0 {
+		return CodeInvalidCoordinates, errs
+	}
+	return "", nil
+}
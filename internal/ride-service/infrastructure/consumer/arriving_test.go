@@ -0,0 +1,115 @@
+package consumer
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"ride-hail/internal/ride-service/domain"
+	"ride-hail/pkg/wsmsg"
+)
+
+const pickupLat, pickupLng = 43.2389, 76.8897
+
+// matchedRide returns ride-1 for passenger-1, matched to driver-1 and picked up
+// at pickupLat, pickupLng
+func matchedRide(t *testing.T) *domain.Ride {
+	t.Helper()
+	pickup, err := domain.NewCoordinate(pickupLat, pickupLng, "Abay Ave 10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest, err := domain.NewCoordinate(43.2220, 76.8515, "Dostyk Ave 5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	driverID := "driver-1"
+	return domain.ReconstructRide("ride-1", "RIDE_20241216_001", "passenger-1", &driverID, domain.StatusMatched,
+		domain.RideTypeEconomy, pickup, dest, 1450, nil, now, &now, nil, nil, nil, "", "")
+}
+
+// newArrivingConsumer returns a consumer tracking driver-1 on ride-1 for a
+// connected passenger-1
+func newArrivingConsumer(t *testing.T) (*RideConsumer, *fakeBroker, *fakeRideStore, *fakeSockets) {
+	t.Helper()
+	c, broker, store, sockets := newTestConsumer()
+	store.rides = map[string]*domain.Ride{"ride-1": matchedRide(t)}
+	sockets.connected["passenger-1"] = true
+	accepted := `{"ride_id":"ride-1","driver_id":"driver-1","passenger_id":"passenger-1","accepted":true}`
+	broker.deliver("driver_responses", delivery(&fakeAcknowledger{}, "msg-1", accepted))
+	return c, broker, store, sockets
+}
+
+// driverAt delivers a location for driver-1 dLat degrees north of the pickup
+// (0.001 degrees is about 111 m)
+func driverAt(broker *fakeBroker, dLat float64) {
+	body := fmt.Sprintf(`{"driver_id":"driver-1","location":{"latitude":%f,"longitude":%f}}`, pickupLat+dLat, pickupLng)
+	broker.deliver(locationQueue, delivery(&fakeAcknowledger{}, "", body))
+}
+
+func TestDriverArrivingFiresOnceWhenCrossingRadius(t *testing.T) {
+	_, broker, store, sockets := newArrivingConsumer(t)
+
+	driverAt(broker, 0.01) // ~1.1 km
+	if n := sockets.count("passenger-1", wsmsg.TypeDriverArriving); n != 0 {
+		t.Fatalf("arriving sent %d times while the driver is far away", n)
+	}
+
+	driverAt(broker, 0.0015) // ~170 m
+	if n := sockets.count("passenger-1", wsmsg.TypeDriverArriving); n != 1 {
+		t.Fatalf("arriving sent %d times after crossing the radius, want 1", n)
+	}
+	arriving := sockets.last["passenger-1"].(wsmsg.Notification)
+	if arriving["ride_id"] != "ride-1" || arriving["distance_meters"].(int) > 200 {
+		t.Errorf("notification = %v, want ride-1 within 200 m", arriving)
+	}
+
+	driverAt(broker, 0.0004) // ~45 m
+	driverAt(broker, 0.0020) // drifts back out
+	driverAt(broker, 0.0001)
+	if n := sockets.count("passenger-1", wsmsg.TypeDriverArriving); n != 1 {
+		t.Errorf("arriving sent %d times, want it once per ride", n)
+	}
+	if n := sockets.count("passenger-1", wsmsg.TypeDriverLocationUpdate); n != 5 {
+		t.Errorf("%d location updates sent, want all 5", n)
+	}
+	if store.lookups != 1 {
+		t.Errorf("ride loaded %d times, want the pickup cached after the first", store.lookups)
+	}
+}
+
+func TestDriverArrivingRespectsConfiguredRadius(t *testing.T) {
+	c, broker, _, sockets := newArrivingConsumer(t)
+	c.SetArrivingRadius(500)
+
+	driverAt(broker, 0.004) // ~445 m
+	if n := sockets.count("passenger-1", wsmsg.TypeDriverArriving); n != 1 {
+		t.Errorf("arriving sent %d times inside a 500 m radius, want 1", n)
+	}
+}
+
+func TestDriverArrivingDisabled(t *testing.T) {
+	c, broker, store, sockets := newArrivingConsumer(t)
+	c.SetArrivingRadius(0)
+
+	driverAt(broker, 0.0001)
+	if n := sockets.count("passenger-1", wsmsg.TypeDriverArriving); n != 0 {
+		t.Errorf("arriving sent %d times with the push disabled", n)
+	}
+	if store.lookups != 0 {
+		t.Errorf("ride loaded %d times, want no lookup when disabled", store.lookups)
+	}
+}
+
+func TestDriverArrivingNotSentAfterArrival(t *testing.T) {
+	_, broker, _, sockets := newArrivingConsumer(t)
+
+	arrived := `{"driver_id":"driver-1","ride_id":"ride-1","passenger_id":"passenger-1","old_status":"EN_ROUTE","new_status":"ARRIVED"}`
+	broker.deliver("driver_status", delivery(&fakeAcknowledger{}, "msg-2", arrived))
+	driverAt(broker, 0.0001)
+
+	if n := sockets.count("passenger-1", wsmsg.TypeDriverArriving); n != 0 {
+		t.Errorf("arriving sent %d times after the driver reported arriving", n)
+	}
+}
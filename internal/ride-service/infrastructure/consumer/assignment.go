@@ -0,0 +1,136 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"ride-hail/internal/ride-service/domain"
+	"ride-hail/internal/ride-service/infrastructure/repository"
+	"ride-hail/internal/ride-service/infrastructure/stream"
+	"ride-hail/pkg/logger"
+	"ride-hail/pkg/wsmsg"
+)
+
+const (
+	// defaultAssignmentTimeout is how long a matched driver has to set off for the pickup
+	defaultAssignmentTimeout = 10 * time.Minute
+	// Longest gap between assignment checks
+	maxAssignmentSweepInterval = 15 * time.Second
+)
+
+// SetAssignmentTimeout sets how long a driver may hold a matched ride without
+// reporting en route before the ride is re-matched; 0 disables the check
+func (c *RideConsumer) SetAssignmentTimeout(d time.Duration) {
+	c.assignmentTimeout = d
+}
+
+// startAssignmentSweeper periodically takes back rides whose driver never set off,
+// until stopCtx is cancelled
+func (c *RideConsumer) startAssignmentSweeper(ctx, stopCtx context.Context) {
+	if c.assignmentTimeout <= 0 {
+		return
+	}
+
+	interval := c.assignmentTimeout / 4
+	if interval > maxAssignmentSweepInterval {
+		interval = maxAssignmentSweepInterval
+	}
+
+	c.running.Add(1)
+	go func() {
+		defer c.running.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stopCtx.Done():
+				return
+			case <-ticker.C:
+				c.expireAssignments(ctx)
+			}
+		}
+	}()
+}
+
+// expireAssignments sends overdue MATCHED rides back to matching
+func (c *RideConsumer) expireAssignments(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// The conditional update is the source of truth: a ride the driver set off
+	// for, or that was cancelled, since it was matched is left alone
+	expired, err := c.repo.ExpireAssignments(ctx, c.assignmentTimeout)
+	if err != nil {
+		c.log.Error("expire_assignments_failed", err)
+		return
+	}
+	for _, a := range expired {
+		c.rematchExpiredAssignment(ctx, a)
+	}
+}
+
+// rematchExpiredAssignment frees the driver in the driver service, records the
+// expiry, re-publishes the ride for matching and tells the passenger
+func (c *RideConsumer) rematchExpiredAssignment(ctx context.Context, a repository.ExpiredAssignment) {
+	log := c.log.WithFields(logger.LogFields{
+		"ride_id":   a.RideID,
+		"driver_id": a.DriverID,
+	})
+	c.tracker.Untrack(a.DriverID, a.RideID)
+
+	log.Info("driver_assignment_expired", "Driver did not set off for the pickup in time, re-matching")
+
+	expired := domain.DriverAssignmentExpiredEvent{
+		RideID:    a.RideID,
+		DriverID:  a.DriverID,
+		Timeout:   c.assignmentTimeout,
+		ExpiredAt: time.Now(),
+	}
+	if err := c.repo.SaveEvent(ctx, a.RideID, expired); err != nil {
+		log.Error("save_assignment_expired_event_failed", err)
+	}
+
+	// The driver service frees the driver and tells them the ride was taken away
+	body, _ := json.Marshal(map[string]interface{}{
+		"ride_id":   a.RideID,
+		"driver_id": a.DriverID,
+		"status":    "REQUESTED",
+		"reason":    "assignment_expired",
+		"timestamp": time.Now(),
+	})
+	if err := c.rabbit.Publish(ctx, "ride_topic", "ride.status.REQUESTED", body); err != nil {
+		log.Error("publish_driver_release_failed", err)
+	}
+
+	ride, err := c.repo.FindByID(ctx, a.RideID)
+	if err != nil {
+		log.Error("load_ride_for_rematch_failed", err)
+		return
+	}
+	requested := domain.RideRequestedEvent{
+		RideID:      ride.ID(),
+		PassengerID: ride.PassengerID(),
+		Pickup:      ride.PickupLocation(),
+		Destination: ride.DestLocation(),
+		RideType:    ride.RideTypeValue(),
+		Fare:        ride.EstimatedFare(),
+		Passengers:  ride.PassengerCount(),
+		RequestedAt: time.Now(),
+	}
+	if err := c.publisher.Publish(ctx, requested); err != nil {
+		log.Error("publish_rematch_failed", err)
+	}
+
+	notification := wsmsg.NewNotification(wsmsg.TypeRideStatusUpdate, map[string]interface{}{
+		"ride_id":   a.RideID,
+		"status":    "REQUESTED",
+		"message":   "Your driver is unavailable, finding you a new driver",
+		"timestamp": time.Now(),
+	})
+	c.publishToStream(a.RideID, stream.Event{Type: wsmsg.TypeRideStatusUpdate, Data: notification})
+	if err := c.wsManager.SendToUser(a.PassengerID, notification); err != nil {
+		log.Error("websocket_rematch_notification_failed", err)
+	}
+}
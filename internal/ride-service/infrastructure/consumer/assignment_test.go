@@ -0,0 +1,74 @@
+package consumer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ride-hail/internal/ride-service/domain"
+	"ride-hail/internal/ride-service/infrastructure/repository"
+	"ride-hail/pkg/wsmsg"
+)
+
+func TestExpiredAssignmentIsRematchedAndDriverFreed(t *testing.T) {
+	c, broker, store, sockets := newArrivingConsumer(t)
+	c.SetAssignmentTimeout(5 * time.Minute)
+	store.expired = []repository.ExpiredAssignment{{RideID: "ride-1", DriverID: "driver-1", PassengerID: "passenger-1"}}
+
+	c.expireAssignments(context.Background())
+
+	if store.expiryTimeout != 5*time.Minute {
+		t.Errorf("expired assignments older than %v, want the configured 5m", store.expiryTimeout)
+	}
+	if got := store.events[len(store.events)-1]; got != "ride-1/ride.assignment_expired" {
+		t.Errorf("last event = %s, want the expiry recorded", got)
+	}
+
+	// The driver service frees the driver on ride.status.REQUESTED
+	var release *publishedMessage
+	for i, m := range broker.published {
+		if m.exchange == "ride_topic" && m.routingKey == "ride.status.REQUESTED" {
+			release = &broker.published[i]
+		}
+	}
+	if release == nil {
+		t.Fatalf("published %v, want a ride.status.REQUESTED release", broker.published)
+	}
+	if release.body["ride_id"] != "ride-1" || release.body["driver_id"] != "driver-1" || release.body["reason"] != "assignment_expired" {
+		t.Errorf("release body = %v, want ride-1 taken from driver-1", release.body)
+	}
+	if _, tracked := c.tracker.Lookup("driver-1"); tracked {
+		t.Error("driver-1 still tracked on the ride")
+	}
+
+	events := c.publisher.(*fakeEventPublisher).events
+	if len(events) != 1 {
+		t.Fatalf("published %d events for matching, want 1", len(events))
+	}
+	if req, ok := events[0].(domain.RideRequestedEvent); !ok || req.RideID != "ride-1" || req.Fare != 1450 {
+		t.Errorf("re-match event = %+v, want ride-1 requested again", events[0])
+	}
+
+	n, _ := sockets.last["passenger-1"].(wsmsg.Notification)
+	if n["type"] != wsmsg.TypeRideStatusUpdate {
+		t.Fatalf("passenger was last sent %v, want a status update", sockets.last["passenger-1"])
+	}
+	if n["status"] != "REQUESTED" || n["ride_id"] != "ride-1" {
+		t.Errorf("passenger told %v, want ride-1 back to REQUESTED", n)
+	}
+}
+
+func TestAssignmentSweepWithNothingOverdue(t *testing.T) {
+	c, broker, store, sockets := newArrivingConsumer(t)
+	c.SetAssignmentTimeout(5 * time.Minute)
+	events, sent := len(store.events), sockets.total()
+
+	c.expireAssignments(context.Background())
+
+	if len(store.events) != events || sockets.total() != sent || len(broker.published) != 0 {
+		t.Error("sweep with nothing overdue recorded, published or notified something")
+	}
+	if _, tracked := c.tracker.Lookup("driver-1"); !tracked {
+		t.Error("driver-1 no longer tracked on the ride")
+	}
+}
@@ -0,0 +1,135 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"ride-hail/internal/ride-service/domain"
+	"ride-hail/internal/ride-service/infrastructure/stream"
+	"ride-hail/pkg/logger"
+	"ride-hail/pkg/notify"
+	"ride-hail/pkg/wsmsg"
+)
+
+const (
+	// defaultMatchingDeadline is how long a ride may wait for any driver to accept
+	defaultMatchingDeadline = 5 * time.Minute
+	// Longest gap between deadline checks
+	maxDeadlineSweepInterval = 15 * time.Second
+)
+
+// SetMatchingDeadline sets how long a ride may stay REQUESTED, across all matching
+// retries, before it is cancelled as unmatched; 0 disables the deadline
+func (c *RideConsumer) SetMatchingDeadline(d time.Duration) {
+	c.matchingDeadline = d
+}
+
+// startMatchingDeadlineSweeper periodically cancels rides no driver accepted in time,
+// until stopCtx is cancelled
+func (c *RideConsumer) startMatchingDeadlineSweeper(ctx, stopCtx context.Context) {
+	if c.matchingDeadline <= 0 {
+		return
+	}
+
+	interval := c.matchingDeadline / 4
+	if interval > maxDeadlineSweepInterval {
+		interval = maxDeadlineSweepInterval
+	}
+
+	c.running.Add(1)
+	go func() {
+		defer c.running.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stopCtx.Done():
+				return
+			case <-ticker.C:
+				c.cancelUnmatchedRides(ctx)
+			}
+		}
+	}()
+}
+
+// cancelUnmatchedRides moves overdue REQUESTED rides to CANCELLED, tells the driver
+// service to drop any outstanding offers and notifies the passenger
+func (c *RideConsumer) cancelUnmatchedRides(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	rides, err := c.repo.CancelUnmatchedRides(ctx, c.matchingDeadline, domain.ReasonNoDriverFound)
+	if err != nil {
+		c.log.Error("cancel_unmatched_rides_failed", err)
+		return
+	}
+
+	for _, ride := range rides {
+		c.log.WithFields(logger.LogFields{
+			"ride_id":      ride.RideID,
+			"passenger_id": ride.PassengerID,
+		}).Info("ride_no_driver_found", "No driver accepted the ride in time, cancelling")
+		c.announceSystemCancellation(ctx, ride.RideID, ride.PassengerID, domain.ReasonNoDriverFound,
+			"No driver found", "No driver was found for your ride. Please try again.")
+	}
+}
+
+// announceSystemCancellation records a ride the system cancelled for reason,
+// tells the driver service to drop any outstanding offers and notifies the
+// passenger with title and message
+func (c *RideConsumer) announceSystemCancellation(ctx context.Context, rideID, passengerID, reason, title, message string) {
+	log := c.log.WithFields(logger.LogFields{
+		"ride_id":      rideID,
+		"passenger_id": passengerID,
+		"reason":       reason,
+	})
+
+	cancelled := domain.RideCancelledEvent{
+		RideID:      rideID,
+		PassengerID: passengerID,
+		Reason:      reason,
+		CancelledBy: domain.CancelledBySystem,
+		CancelledAt: time.Now(),
+	}
+	if err := c.repo.SaveEvent(ctx, rideID, cancelled); err != nil {
+		log.Error("save_cancelled_event_failed", err)
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"ride_id":      rideID,
+		"passenger_id": passengerID,
+		"status":       "CANCELLED",
+		"reason":       reason,
+		"cancelled_by": domain.CancelledBySystem,
+		"timestamp":    time.Now(),
+	})
+	if err := c.rabbit.Publish(ctx, "ride_topic", "ride.status.CANCELLED", body); err != nil {
+		log.Error("publish_system_cancellation_failed", err)
+	}
+
+	notification := wsmsg.NewNotification(wsmsg.TypeRideStatusUpdate, map[string]interface{}{
+		"ride_id":      rideID,
+		"status":       "CANCELLED",
+		"reason":       reason,
+		"cancelled_by": domain.CancelledBySystem,
+		"message":      message,
+		"timestamp":    time.Now(),
+	})
+	c.publishToStream(rideID, stream.Event{Type: wsmsg.TypeRideStatusUpdate, Data: notification, Terminal: true})
+	c.push(passengerID, notify.Payload{
+		Type:  string(wsmsg.TypeRideStatusUpdate),
+		Title: title,
+		Body:  message,
+		Data: map[string]interface{}{
+			"ride_id":      rideID,
+			"status":       "CANCELLED",
+			"reason":       reason,
+			"cancelled_by": domain.CancelledBySystem,
+		},
+	})
+	if err := c.wsManager.SendToUser(passengerID, notification); err != nil {
+		log.Error("websocket_system_cancellation_failed", err)
+	}
+}
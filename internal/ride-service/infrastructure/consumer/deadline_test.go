@@ -0,0 +1,105 @@
+package consumer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ride-hail/internal/ride-service/domain"
+	"ride-hail/internal/ride-service/infrastructure/repository"
+	"ride-hail/internal/ride-service/infrastructure/stream"
+	"ride-hail/pkg/wsmsg"
+)
+
+func TestUnmatchedRideIsCancelledAfterDeadline(t *testing.T) {
+	c, broker, store, sockets := newTestConsumer()
+	c.streams = stream.NewHub(nopLogger{})
+	events, unsubscribe := c.streams.Subscribe("ride-1")
+	defer unsubscribe()
+	c.SetMatchingDeadline(3 * time.Minute)
+	store.unmatched = []repository.UnmatchedRide{{RideID: "ride-1", PassengerID: "passenger-1"}}
+
+	c.cancelUnmatchedRides(context.Background())
+
+	if store.unmatchedWait != 3*time.Minute || store.unmatchedCause != domain.ReasonNoDriverFound {
+		t.Errorf("cancelled rides waiting over %v for %q, want 3m and NO_DRIVER_FOUND", store.unmatchedWait, store.unmatchedCause)
+	}
+	if len(store.statuses) != 1 || store.statuses[0] != "ride-1/CANCELLED" {
+		t.Errorf("ride statuses = %v, want ride-1 CANCELLED", store.statuses)
+	}
+	if len(store.events) != 1 || store.events[0] != "ride-1/ride.cancelled" {
+		t.Errorf("events = %v, want the cancellation recorded", store.events)
+	}
+
+	// The driver service withdraws any offers still out for the ride
+	if len(broker.published) != 1 {
+		t.Fatalf("published %v, want one ride.status.CANCELLED", broker.published)
+	}
+	if m := broker.published[0]; m.routingKey != "ride.status.CANCELLED" || m.body["reason"] != domain.ReasonNoDriverFound || m.body["cancelled_by"] != domain.CancelledBySystem {
+		t.Errorf("published %s %v, want a system cancellation for no driver", m.routingKey, m.body)
+	}
+
+	n, _ := sockets.last["passenger-1"].(wsmsg.Notification)
+	if n["type"] != wsmsg.TypeRideStatusUpdate || n["status"] != "CANCELLED" || n["reason"] != domain.ReasonNoDriverFound {
+		t.Errorf("passenger was sent %v, want a no-driver cancellation", sockets.last["passenger-1"])
+	}
+
+	select {
+	case ev := <-events:
+		if !ev.Terminal {
+			t.Error("stream event is not terminal, want the stream ended")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no event on the ride stream")
+	}
+}
+
+func TestDeadlineSweepWithNothingOverdue(t *testing.T) {
+	c, broker, store, sockets := newTestConsumer()
+
+	c.cancelUnmatchedRides(context.Background())
+
+	if len(store.statuses) != 0 || len(store.events) != 0 || len(broker.published) != 0 || sockets.total() != 0 {
+		t.Error("sweep with nothing overdue cancelled, published or notified something")
+	}
+}
+
+func TestMatchingDeadlineSweeperRunsUntilStopped(t *testing.T) {
+	c, _, store, sockets := newTestConsumer()
+	c.SetMatchingDeadline(40 * time.Millisecond) // swept every 10ms
+	store.mu.Lock()
+	store.unmatched = []repository.UnmatchedRide{{RideID: "ride-1", PassengerID: "passenger-1"}}
+	store.mu.Unlock()
+
+	stop, cancel := context.WithCancel(context.Background())
+	c.startMatchingDeadlineSweeper(context.Background(), stop)
+	deadline := time.Now().Add(2 * time.Second)
+	for sockets.total() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("overdue ride never cancelled")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	c.running.Wait()
+}
+
+func TestMatchingDeadlineDisabled(t *testing.T) {
+	c, _, _, _ := newTestConsumer()
+	c.SetMatchingDeadline(0)
+
+	stop, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.startMatchingDeadlineSweeper(context.Background(), stop)
+
+	done := make(chan struct{})
+	go func() {
+		c.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("sweeper started with the deadline disabled")
+	}
+}
@@ -0,0 +1,78 @@
+package consumer
+
+import (
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"ride-hail/pkg/clock"
+)
+
+const (
+	// processedCacheSize bounds how many message ids are remembered
+	processedCacheSize = 10000
+
+	// processedTTL is how long a processed id is remembered; redeliveries arrive well within this window
+	processedTTL = 30 * time.Minute
+)
+
+// processedCache is a bounded, TTL-based record of message ids that have already been handled
+type processedCache struct {
+	mu      sync.Mutex
+	entries map[string]time.Time // message id -> processed at
+	order   []string             // insertion order for eviction
+	size    int
+	ttl     time.Duration
+	clock   clock.Clock
+}
+
+func newProcessedCache(size int, ttl time.Duration) *processedCache {
+	return &processedCache{
+		entries: make(map[string]time.Time, size),
+		order:   make([]string, 0, size),
+		size:    size,
+		ttl:     ttl,
+		clock:   clock.New(),
+	}
+}
+
+// markProcessed records id and reports whether it was already seen.
+// Checking and marking happen atomically so concurrent redeliveries are handled once.
+func (p *processedCache) markProcessed(id string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.clock.Now()
+	if at, ok := p.entries[id]; ok && now.Sub(at) < p.ttl {
+		return true
+	}
+
+	for len(p.order) > 0 && (len(p.order) >= p.size || now.Sub(p.entries[p.order[0]]) >= p.ttl) {
+		delete(p.entries, p.order[0])
+		p.order = p.order[1:]
+	}
+
+	p.entries[id] = now
+	p.order = append(p.order, id)
+	return false
+}
+
+// forget drops id so a requeued message is handled again when it comes back
+func (p *processedCache) forget(id string) {
+	if id == "" {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.entries, id) // the stale order slot goes on eviction
+}
+
+// messageKey returns the dedupe key of a delivery: MessageId, falling back to CorrelationId.
+// An empty key means the message can't be deduplicated.
+func messageKey(msg amqp.Delivery) string {
+	if msg.MessageId != "" {
+		return msg.MessageId
+	}
+	return msg.CorrelationId
+}
@@ -0,0 +1,148 @@
+package consumer
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"ride-hail/pkg/clock"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// delivery returns a message with the given id, settled through ack
+func delivery(ack *fakeAcknowledger, messageID, body string) amqp.Delivery {
+	return amqp.Delivery{Acknowledger: ack, MessageId: messageID, Body: []byte(body)}
+}
+
+func TestRedeliveredDriverResponseIsNoOp(t *testing.T) {
+	_, broker, store, sockets := newTestConsumer()
+	ack := &fakeAcknowledger{}
+	body := `{"ride_id":"ride-1","driver_id":"driver-1","passenger_id":"passenger-1","accepted":true}`
+
+	broker.deliver("driver_responses", delivery(ack, "msg-1", body))
+	redelivered := delivery(ack, "msg-1", body)
+	redelivered.Redelivered = true
+	broker.deliver("driver_responses", redelivered)
+
+	if len(store.assigned) != 1 {
+		t.Errorf("driver assigned %d times, want 1: %v", len(store.assigned), store.assigned)
+	}
+	if len(store.events) != 1 {
+		t.Errorf("%d events saved, want 1: %v", len(store.events), store.events)
+	}
+	if n := sockets.sent["passenger-1"]; n != 1 {
+		t.Errorf("passenger notified %d times, want 1", n)
+	}
+	if ack.acks != 2 || ack.nacks != 0 {
+		t.Errorf("acks=%d nacks=%d, want both deliveries acked", ack.acks, ack.nacks)
+	}
+}
+
+func TestRedeliveredDriverStatusIsNoOp(t *testing.T) {
+	_, broker, store, sockets := newTestConsumer()
+	ack := &fakeAcknowledger{}
+	body := `{"driver_id":"driver-1","ride_id":"ride-1","passenger_id":"passenger-1","old_status":"IN_PROGRESS","new_status":"COMPLETED"}`
+
+	broker.deliver("driver_status", delivery(ack, "msg-1", body))
+	broker.deliver("driver_status", delivery(ack, "msg-1", body))
+
+	if len(store.statuses) != 1 {
+		t.Errorf("ride status written %d times, want 1: %v", len(store.statuses), store.statuses)
+	}
+	// STATUS_CHANGED and RIDE_COMPLETED, once each
+	if len(store.events) != 2 {
+		t.Errorf("%d events saved, want 2: %v", len(store.events), store.events)
+	}
+	if n := sockets.sent["passenger-1"]; n != 1 {
+		t.Errorf("passenger notified %d times, want 1", n)
+	}
+	if ack.acks != 2 {
+		t.Errorf("acks=%d, want both deliveries acked", ack.acks)
+	}
+}
+
+func TestCorrelationIDDedupesWithoutMessageID(t *testing.T) {
+	_, broker, store, _ := newTestConsumer()
+	ack := &fakeAcknowledger{}
+	body := `{"ride_id":"ride-1","driver_id":"driver-1","passenger_id":"passenger-1","accepted":true}`
+
+	for i := 0; i < 2; i++ {
+		broker.deliver("driver_responses", amqp.Delivery{Acknowledger: ack, CorrelationId: "corr-1", Body: []byte(body)})
+	}
+
+	if len(store.assigned) != 1 {
+		t.Errorf("driver assigned %d times, want 1", len(store.assigned))
+	}
+}
+
+func TestMessagesWithoutIDAreAlwaysHandled(t *testing.T) {
+	_, broker, store, _ := newTestConsumer()
+	ack := &fakeAcknowledger{}
+	body := `{"ride_id":"ride-1","driver_id":"driver-1","passenger_id":"passenger-1","accepted":true}`
+
+	broker.deliver("driver_responses", delivery(ack, "", body))
+	broker.deliver("driver_responses", delivery(ack, "", body))
+
+	if len(store.assigned) != 2 {
+		t.Errorf("driver assigned %d times, want 2", len(store.assigned))
+	}
+}
+
+func TestRequeuedMessageIsHandledOnRedelivery(t *testing.T) {
+	_, broker, store, _ := newTestConsumer()
+	store.failures = 1
+	ack := &fakeAcknowledger{}
+	body := `{"ride_id":"ride-1","driver_id":"driver-1","passenger_id":"passenger-1","accepted":true}`
+
+	broker.deliver("driver_responses", delivery(ack, "msg-1", body))
+	if broker.requeued != 1 || ack.nacks != 1 {
+		t.Fatalf("requeued=%d nacks=%d, want the failed delivery requeued", broker.requeued, ack.nacks)
+	}
+
+	broker.deliver("driver_responses", delivery(ack, "msg-1", body))
+	if len(store.assigned) != 1 {
+		t.Errorf("driver assigned %d times after redelivery, want 1", len(store.assigned))
+	}
+
+	// Now that it went through, a further redelivery is skipped
+	broker.deliver("driver_responses", delivery(ack, "msg-1", body))
+	if len(store.assigned) != 1 {
+		t.Errorf("driver assigned %d times, want 1", len(store.assigned))
+	}
+}
+
+func TestProcessedCacheForgetsAfterTTL(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, 12, 16, 10, 0, 0, 0, time.UTC))
+	p := newProcessedCache(10, time.Minute)
+	p.clock = clk
+
+	if p.markProcessed("msg-1") {
+		t.Fatal("first delivery reported as seen")
+	}
+	clk.Advance(59 * time.Second)
+	if !p.markProcessed("msg-1") {
+		t.Fatal("redelivery within the TTL not reported as seen")
+	}
+	clk.Advance(time.Second)
+	if p.markProcessed("msg-1") {
+		t.Error("delivery after the TTL reported as seen")
+	}
+}
+
+func TestProcessedCacheEvictsOldestWhenFull(t *testing.T) {
+	p := newProcessedCache(3, time.Hour)
+	for i := 0; i < 4; i++ {
+		p.markProcessed(fmt.Sprintf("msg-%d", i))
+	}
+
+	if len(p.entries) != 3 {
+		t.Errorf("cache holds %d ids, want 3", len(p.entries))
+	}
+	if p.markProcessed("msg-0") {
+		t.Error("evicted id still reported as seen")
+	}
+	if !p.markProcessed("msg-3") {
+		t.Error("newest id not reported as seen")
+	}
+}
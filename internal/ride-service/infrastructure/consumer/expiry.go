@@ -0,0 +1,85 @@
+package consumer
+
+import (
+	"context"
+	"time"
+
+	"ride-hail/internal/ride-service/domain"
+	"ride-hail/pkg/logger"
+)
+
+const (
+	// defaultRideExpiry is the longest a ride may stay REQUESTED after it was requested
+	defaultRideExpiry = 30 * time.Minute
+	// Longest gap between expiry checks
+	maxExpirySweepInterval = time.Minute
+)
+
+// SetRideExpiry sets how long after it was requested a ride still REQUESTED is
+// expired; 0 disables expiry. Unlike the matching deadline, which restarts when
+// a ride is sent back to matching, this bounds the ride's whole wait.
+func (c *RideConsumer) SetRideExpiry(age time.Duration) {
+	c.rideExpiry = age
+}
+
+// startRideExpirySweeper periodically expires stale ride requests, until stopCtx
+// is cancelled
+func (c *RideConsumer) startRideExpirySweeper(ctx, stopCtx context.Context) {
+	if c.rideExpiry <= 0 {
+		return
+	}
+
+	interval := c.rideExpiry / 4
+	if interval > maxExpirySweepInterval {
+		interval = maxExpirySweepInterval
+	}
+
+	c.running.Add(1)
+	go func() {
+		defer c.running.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stopCtx.Done():
+				return
+			case <-ticker.C:
+				c.expireStaleRides(ctx)
+			}
+		}
+	}()
+}
+
+// expireStaleRides cancels rides requested longer than the expiry age ago that
+// are still REQUESTED, with reason EXPIRED, and notifies their passengers. A
+// ride matched between the lookup and its cancellation is left alone.
+func (c *RideConsumer) expireStaleRides(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	rides, err := c.repo.FindByStatus(ctx, domain.StatusRequested, c.rideExpiry)
+	if err != nil {
+		c.log.Error("find_stale_rides_failed", err)
+		return
+	}
+
+	for _, ride := range rides {
+		log := c.log.WithFields(logger.LogFields{
+			"ride_id":      ride.ID(),
+			"passenger_id": ride.PassengerID(),
+		})
+		expired, err := c.repo.ExpireRide(ctx, ride.ID(), domain.ReasonExpired)
+		if err != nil {
+			log.Error("expire_ride_failed", err)
+			continue
+		}
+		if !expired {
+			continue
+		}
+
+		log.Info("ride_expired", "Ride request expired without a driver, cancelling")
+		c.announceSystemCancellation(ctx, ride.ID(), ride.PassengerID(), domain.ReasonExpired,
+			"Ride request expired", "Your ride request expired without a driver. Please try again.")
+	}
+}
@@ -0,0 +1,88 @@
+package consumer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ride-hail/internal/ride-service/domain"
+	"ride-hail/pkg/wsmsg"
+)
+
+// requestedRide returns rideID for passengerID, still waiting for a driver
+func requestedRide(t *testing.T, rideID, passengerID string) *domain.Ride {
+	t.Helper()
+	pickup, err := domain.NewCoordinate(pickupLat, pickupLng, "Abay Ave 10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest, err := domain.NewCoordinate(43.2220, 76.8515, "Dostyk Ave 5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return domain.ReconstructRide(rideID, "RIDE_20241216_001", passengerID, nil, domain.StatusRequested,
+		domain.RideTypeEconomy, pickup, dest, 1450, nil, time.Now().Add(-time.Hour), nil, nil, nil, nil, "", "")
+}
+
+func TestStaleRideRequestIsExpired(t *testing.T) {
+	c, broker, store, sockets := newTestConsumer()
+	c.SetRideExpiry(30 * time.Minute)
+	store.stale = []*domain.Ride{requestedRide(t, "ride-1", "passenger-1")}
+
+	c.expireStaleRides(context.Background())
+
+	if store.staleStatus != domain.StatusRequested || store.staleAge != 30*time.Minute {
+		t.Errorf("looked up %s rides older than %v, want REQUESTED and 30m", store.staleStatus, store.staleAge)
+	}
+	if len(store.expiredAs) != 1 || store.expiredAs[0] != "ride-1/"+domain.ReasonExpired {
+		t.Errorf("expired %v, want ride-1 with reason EXPIRED", store.expiredAs)
+	}
+	if len(store.events) != 1 || store.events[0] != "ride-1/ride.cancelled" {
+		t.Errorf("events = %v, want the cancellation recorded", store.events)
+	}
+	if len(broker.published) != 1 {
+		t.Fatalf("published %v, want one ride.status.CANCELLED", broker.published)
+	}
+	if m := broker.published[0]; m.routingKey != "ride.status.CANCELLED" || m.body["reason"] != domain.ReasonExpired || m.body["cancelled_by"] != domain.CancelledBySystem {
+		t.Errorf("published %s %v, want a system cancellation for expiry", m.routingKey, m.body)
+	}
+	n, _ := sockets.last["passenger-1"].(wsmsg.Notification)
+	if n["type"] != wsmsg.TypeRideStatusUpdate || n["status"] != "CANCELLED" || n["reason"] != domain.ReasonExpired {
+		t.Errorf("passenger was sent %v, want an expiry cancellation", sockets.last["passenger-1"])
+	}
+}
+
+func TestRideMatchedSinceLookupIsNotExpired(t *testing.T) {
+	c, broker, store, sockets := newTestConsumer()
+	ride := requestedRide(t, "ride-1", "passenger-1")
+	if err := ride.AssignDriver("driver-1"); err != nil {
+		t.Fatal(err)
+	}
+	store.stale = []*domain.Ride{ride}
+
+	c.expireStaleRides(context.Background())
+
+	if len(store.expiredAs) != 0 || len(store.events) != 0 || len(broker.published) != 0 || sockets.total() != 0 {
+		t.Error("a ride matched since the lookup was expired, published or notified")
+	}
+}
+
+func TestRideExpiryDisabled(t *testing.T) {
+	c, _, _, _ := newTestConsumer()
+	c.SetRideExpiry(0)
+
+	stop, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.startRideExpirySweeper(context.Background(), stop)
+
+	done := make(chan struct{})
+	go func() {
+		c.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expiry sweeper started with expiry off")
+	}
+}
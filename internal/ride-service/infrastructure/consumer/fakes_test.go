@@ -0,0 +1,347 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"ride-hail/internal/ride-service/domain"
+	"ride-hail/internal/ride-service/infrastructure/repository"
+	"ride-hail/pkg/logger"
+	"ride-hail/pkg/notify"
+	"ride-hail/pkg/rabbitmq"
+	"ride-hail/pkg/wsmsg"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type nopLogger struct{}
+
+func (l nopLogger) WithFields(logger.LogFields) logger.Logger { return l }
+func (nopLogger) Info(action, message string)                 {}
+func (nopLogger) Debug(action, message string)                {}
+func (nopLogger) Warn(action, message string)                 {}
+func (nopLogger) Error(action string, err error)              {}
+
+// fakeBroker hands the consumer's handlers to the test instead of a channel,
+// and settles deliveries the way rabbitmq.Connection does
+type fakeBroker struct {
+	mu           sync.Mutex
+	handlers     map[string]func(amqp.Delivery)
+	requeued     int
+	deadLettered int
+	published    []publishedMessage
+}
+
+// publishedMessage is one Publish call
+type publishedMessage struct {
+	exchange, routingKey string
+	body                 map[string]interface{}
+}
+
+func newFakeBroker() *fakeBroker {
+	return &fakeBroker{handlers: make(map[string]func(amqp.Delivery))}
+}
+
+func (b *fakeBroker) ConsumeContext(ctx context.Context, queueName string, handler func(amqp.Delivery)) (<-chan struct{}, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[queueName] = handler
+	stopped := make(chan struct{})
+	close(stopped)
+	return stopped, nil
+}
+
+func (b *fakeBroker) DeclareInstanceQueue(base, exchange string, routingKeys ...string) (string, error) {
+	return base, nil
+}
+
+func (b *fakeBroker) Publish(ctx context.Context, exchange, routingKey string, body []byte) error {
+	var m map[string]interface{}
+	_ = json.Unmarshal(body, &m)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.published = append(b.published, publishedMessage{exchange, routingKey, m})
+	return nil
+}
+
+func (b *fakeBroker) Settle(queue string, d amqp.Delivery, err error) bool {
+	if err != nil && rabbitmq.IsRetryable(err) {
+		b.mu.Lock()
+		b.requeued++
+		b.mu.Unlock()
+		d.Nack(false, true)
+		return true
+	}
+	if err != nil {
+		b.mu.Lock()
+		b.deadLettered++
+		b.mu.Unlock()
+	}
+	d.Ack(false)
+	return false
+}
+
+// deliver runs the handler registered for queue on d
+func (b *fakeBroker) deliver(queue string, d amqp.Delivery) {
+	b.mu.Lock()
+	handler := b.handlers[queue]
+	b.mu.Unlock()
+	handler(d)
+}
+
+// fakeAcknowledger counts how each delivery was settled
+type fakeAcknowledger struct {
+	mu    sync.Mutex
+	acks  int
+	nacks int
+}
+
+func (a *fakeAcknowledger) Ack(tag uint64, multiple bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.acks++
+	return nil
+}
+
+func (a *fakeAcknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.nacks++
+	return nil
+}
+
+func (a *fakeAcknowledger) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+// fakeRideStore records the writes the consumer makes. Failures, when set, is
+// how many AssignDriver and UpdateRideStatus calls fail before they succeed;
+// they fail with failErr, or a timeout when that is nil.
+type fakeRideStore struct {
+	mu       sync.Mutex
+	assigned []string
+	statuses []string
+	events   []string
+	failures int
+	failErr  error
+
+	cancelled []cancellation
+	lookups   int                     // FindByID calls
+	rides     map[string]*domain.Ride // returned by FindByID
+
+	expired       []repository.ExpiredAssignment // returned by the next ExpireAssignments
+	expiryTimeout time.Duration                  // and the timeout it was called with
+
+	unmatched      []repository.UnmatchedRide // cancelled by the next CancelUnmatchedRides
+	unmatchedWait  time.Duration              // and the wait and reason it was called with
+	unmatchedCause string
+
+	stale       []*domain.Ride // returned by FindByStatus; ExpireRide only cancels REQUESTED ones
+	staleStatus domain.RideStatus
+	staleAge    time.Duration // and the status and age it was last called with
+	expiredAs   []string      // "rideID/reason" per ride ExpireRide cancelled
+}
+
+// cancellation is one CancelRide call
+type cancellation struct {
+	rideID, reason, cancelledBy string
+	fee                         float64
+}
+
+func (s *fakeRideStore) FindByID(ctx context.Context, rideID string) (*domain.Ride, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lookups++
+	if ride, ok := s.rides[rideID]; ok {
+		return ride, nil
+	}
+	return nil, domain.ErrRideNotFound
+}
+
+// fail uses up one of the configured failures; the caller holds s.mu
+func (s *fakeRideStore) fail() error {
+	if s.failures == 0 {
+		return nil
+	}
+	s.failures--
+	if s.failErr != nil {
+		return s.failErr
+	}
+	return context.DeadlineExceeded
+}
+
+func (s *fakeRideStore) AssignDriver(ctx context.Context, rideID, driverID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.fail(); err != nil {
+		return err
+	}
+	s.assigned = append(s.assigned, rideID+"/"+driverID)
+	return nil
+}
+
+func (s *fakeRideStore) UpdateRideStatus(ctx context.Context, rideID, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.fail(); err != nil {
+		return err
+	}
+	s.statuses = append(s.statuses, rideID+"/"+status)
+	return nil
+}
+
+func (s *fakeRideStore) CancelRide(ctx context.Context, rideID, reason, cancelledBy string, fee float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statuses = append(s.statuses, rideID+"/CANCELLED")
+	s.cancelled = append(s.cancelled, cancellation{rideID, reason, cancelledBy, fee})
+	return nil
+}
+
+func (s *fakeRideStore) CancelUnmatchedRides(ctx context.Context, maxWait time.Duration, reason string) ([]repository.UnmatchedRide, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unmatchedWait, s.unmatchedCause = maxWait, reason
+	rides := s.unmatched
+	s.unmatched = nil
+	for _, r := range rides {
+		s.statuses = append(s.statuses, r.RideID+"/CANCELLED")
+	}
+	return rides, nil
+}
+
+func (s *fakeRideStore) FindByStatus(ctx context.Context, status domain.RideStatus, olderThan time.Duration) ([]*domain.Ride, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.staleStatus, s.staleAge = status, olderThan
+	return s.stale, nil
+}
+
+func (s *fakeRideStore) ExpireRide(ctx context.Context, rideID, reason string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ride := range s.stale {
+		if ride.ID() != rideID || ride.Status() != domain.StatusRequested {
+			continue
+		}
+		if err := ride.Cancel(reason, domain.CancelledBySystem); err != nil {
+			return false, err
+		}
+		s.expiredAs = append(s.expiredAs, rideID+"/"+reason)
+		return true, nil
+	}
+	return false, nil
+}
+
+func (s *fakeRideStore) ExpireAssignments(ctx context.Context, timeout time.Duration) ([]repository.ExpiredAssignment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expiryTimeout = timeout
+	expired := s.expired
+	s.expired = nil
+	return expired, nil
+}
+
+func (s *fakeRideStore) SaveEvent(ctx context.Context, rideID string, event domain.DomainEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, rideID+"/"+event.EventType())
+	return nil
+}
+
+// fakeEventPublisher records the domain events published for matching
+type fakeEventPublisher struct {
+	mu     sync.Mutex
+	events []domain.DomainEvent
+}
+
+func (p *fakeEventPublisher) Publish(ctx context.Context, event domain.DomainEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, event)
+	return nil
+}
+
+// fakeSockets counts the messages sent to each user and keeps the last one and
+// the type of each. Users in connected have a socket on this instance.
+type fakeSockets struct {
+	mu        sync.Mutex
+	sent      map[string]int
+	last      map[string]interface{}
+	types     map[string][]interface{}
+	connected map[string]bool
+}
+
+func (s *fakeSockets) SendToUser(userID string, message interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sent == nil {
+		s.sent = make(map[string]int)
+		s.last = make(map[string]interface{})
+		s.types = make(map[string][]interface{})
+	}
+	s.sent[userID]++
+	s.last[userID] = message
+	if n, ok := message.(wsmsg.Notification); ok {
+		s.types[userID] = append(s.types[userID], n["type"])
+	}
+	return nil
+}
+
+// count returns how many notifications of type t were sent to userID
+func (s *fakeSockets) count(userID string, t wsmsg.Type) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, sent := range s.types[userID] {
+		if sent == t {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *fakeSockets) IsUserConnected(userID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connected[userID]
+}
+
+// total returns how many messages were sent to anyone
+func (s *fakeSockets) total() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, c := range s.sent {
+		n += c
+	}
+	return n
+}
+
+// newTestConsumer returns a consumer wired to fakes with its response, status
+// and location handlers registered on the broker
+func newTestConsumer() (*RideConsumer, *fakeBroker, *fakeRideStore, *fakeSockets) {
+	broker := newFakeBroker()
+	store := &fakeRideStore{}
+	sockets := &fakeSockets{connected: make(map[string]bool)}
+	c := &RideConsumer{
+		rabbit:    broker,
+		publisher: &fakeEventPublisher{},
+		log:       nopLogger{},
+		wsManager: sockets,
+		repo:      store,
+		processed: newProcessedCache(processedCacheSize, processedTTL),
+		tracker:   newRideTracker(),
+		notifier:  notify.Noop{},
+		pushes:    make(chan pushJob, pushQueueSize),
+
+		arrivingRadiusKm: defaultArrivingRadiusMeters / 1000.0,
+	}
+	ctx := context.Background()
+	c.consumeDriverResponses(ctx, ctx)
+	c.consumeDriverStatus(ctx, ctx)
+	c.consumeLocationUpdates(ctx, ctx)
+	return c, broker, store, sockets
+}
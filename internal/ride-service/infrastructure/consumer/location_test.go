@@ -0,0 +1,120 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"ride-hail/internal/ride-service/infrastructure/stream"
+	"ride-hail/pkg/wsmsg"
+)
+
+const locationQueue = "location_updates_ride"
+
+// locationBody is a driver service location update, which nests the position
+func locationBody(driverID, rideID, passengerID string) string {
+	return fmt.Sprintf(`{"driver_id":%q,"ride_id":%q,"passenger_id":%q,"location":{"latitude":43.2389,"longitude":76.8897},"heading_degrees":90}`,
+		driverID, rideID, passengerID)
+}
+
+func TestLocationForUntrackedDriverIsDropped(t *testing.T) {
+	_, broker, store, sockets := newTestConsumer()
+	sockets.connected["passenger-1"] = true
+
+	broker.deliver(locationQueue, delivery(&fakeAcknowledger{}, "", locationBody("driver-1", "", "")))
+
+	if n := sockets.total(); n != 0 {
+		t.Errorf("sent %d messages for a driver with no ride, want none", n)
+	}
+	if store.lookups != 0 {
+		t.Errorf("looked up %d rides, want none", store.lookups)
+	}
+}
+
+func TestLocationForPassengerOnAnotherInstanceIsDropped(t *testing.T) {
+	c, broker, _, sockets := newTestConsumer()
+	c.streams = stream.NewHub(nopLogger{})
+
+	broker.deliver(locationQueue, delivery(&fakeAcknowledger{}, "", locationBody("driver-1", "ride-1", "passenger-1")))
+
+	if n := sockets.total(); n != 0 {
+		t.Errorf("sent %d messages to a passenger not connected here, want none", n)
+	}
+	if _, ok := c.tracker.Lookup("driver-1"); ok {
+		t.Error("driver tracked by an instance that does not hold the passenger")
+	}
+}
+
+func TestLocationReachesTrackedPassenger(t *testing.T) {
+	_, broker, _, sockets := newTestConsumer()
+	sockets.connected["passenger-1"] = true
+
+	// The match tells this instance which passenger the driver serves
+	accepted := `{"ride_id":"ride-1","driver_id":"driver-1","passenger_id":"passenger-1","accepted":true}`
+	broker.deliver("driver_responses", delivery(&fakeAcknowledger{}, "msg-1", accepted))
+	matched := sockets.sent["passenger-1"]
+
+	broker.deliver(locationQueue, delivery(&fakeAcknowledger{}, "", locationBody("driver-1", "", "")))
+
+	if n := sockets.sent["passenger-1"] - matched; n != 1 {
+		t.Fatalf("passenger sent %d location updates, want 1", n)
+	}
+	n := sockets.last["passenger-1"].(wsmsg.Notification)
+	if n["type"] != wsmsg.TypeDriverLocationUpdate || n["ride_id"] != "ride-1" || n["latitude"] != 43.2389 {
+		t.Errorf("notification = %v, want the driver's location for ride-1", n)
+	}
+}
+
+func TestLocationReachesStreamSubscriber(t *testing.T) {
+	c, broker, _, sockets := newTestConsumer()
+	c.streams = stream.NewHub(nopLogger{})
+	events, unsubscribe := c.streams.Subscribe("ride-1")
+	defer unsubscribe()
+
+	broker.deliver(locationQueue, delivery(&fakeAcknowledger{}, "", locationBody("driver-1", "ride-1", "passenger-1")))
+
+	select {
+	case ev := <-events:
+		if ev.Type != wsmsg.TypeDriverLocationUpdate {
+			t.Errorf("event type = %s, want %s", ev.Type, wsmsg.TypeDriverLocationUpdate)
+		}
+	default:
+		t.Fatal("no event published to the ride's stream")
+	}
+	if sockets.sent["passenger-1"] != 1 {
+		t.Errorf("passenger sent %d messages, want 1", sockets.sent["passenger-1"])
+	}
+}
+
+// BenchmarkIrrelevantLocationUpdate measures dropping an update from a driver
+// this instance has no passenger for, the common case with many instances
+func BenchmarkIrrelevantLocationUpdate(b *testing.B) {
+	c, _, _, _ := newTestConsumer()
+	c.streams = stream.NewHub(nopLogger{})
+	for i := 0; i < 1000; i++ {
+		c.tracker.Track(fmt.Sprintf("driver-%d", i), fmt.Sprintf("ride-%d", i), fmt.Sprintf("passenger-%d", i))
+	}
+	body := []byte(locationBody("driver-untracked", "", ""))
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.handleLocationUpdate(ctx, body)
+	}
+}
+
+// BenchmarkRelevantLocationUpdate is the same update for a passenger connected here
+func BenchmarkRelevantLocationUpdate(b *testing.B) {
+	c, _, _, sockets := newTestConsumer()
+	c.tracker.Track("driver-1", "ride-1", "passenger-1")
+	sockets.connected["passenger-1"] = true
+	body := []byte(locationBody("driver-1", "", ""))
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.handleLocationUpdate(ctx, body)
+	}
+}
@@ -3,34 +3,119 @@ package consumer
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
 	"time"
 
 	"ride-hail/internal/ride-service/domain"
+	"ride-hail/internal/ride-service/infrastructure/messaging"
 	"ride-hail/internal/ride-service/infrastructure/repository"
+	"ride-hail/internal/ride-service/infrastructure/stream"
 	"ride-hail/pkg/logger"
+	"ride-hail/pkg/notify"
 	"ride-hail/pkg/rabbitmq"
 	"ride-hail/pkg/websocket"
+	"ride-hail/pkg/wsmsg"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
+// broker is the part of the RabbitMQ connection the consumer uses
+type broker interface {
+	ConsumeContext(ctx context.Context, queueName string, handler func(amqp.Delivery)) (<-chan struct{}, error)
+	DeclareInstanceQueue(base, exchange string, routingKeys ...string) (string, error)
+	Publish(ctx context.Context, exchange, routingKey string, body []byte) error
+	Settle(queue string, d amqp.Delivery, err error) bool
+}
+
+// rideStore is the part of the ride repository the consumer uses
+type rideStore interface {
+	FindByID(ctx context.Context, rideID string) (*domain.Ride, error)
+	AssignDriver(ctx context.Context, rideID, driverID string) error
+	UpdateRideStatus(ctx context.Context, rideID, status string) error
+	CancelRide(ctx context.Context, rideID, reason, cancelledBy string, fee float64) error
+	CancelUnmatchedRides(ctx context.Context, maxWait time.Duration, reason string) ([]repository.UnmatchedRide, error)
+	FindByStatus(ctx context.Context, status domain.RideStatus, olderThan time.Duration) ([]*domain.Ride, error)
+	ExpireRide(ctx context.Context, rideID, reason string) (bool, error)
+	ExpireAssignments(ctx context.Context, timeout time.Duration) ([]repository.ExpiredAssignment, error)
+	SaveEvent(ctx context.Context, rideID string, event domain.DomainEvent) error
+}
+
+// userSockets reaches passengers connected over WebSocket
+type userSockets interface {
+	SendToUser(userID string, message interface{}) error
+	IsUserConnected(userID string) bool
+}
+
+// eventPublisher publishes ride events for the other services
+type eventPublisher interface {
+	Publish(ctx context.Context, event domain.DomainEvent) error
+}
+
 // RideConsumer handles incoming messages for the Ride Service
 type RideConsumer struct {
-	rabbit    *rabbitmq.Connection
+	rabbit    broker
 	log       logger.Logger
-	wsManager *websocket.Manager
-	repo      *repository.PostgresRideRepository
+	wsManager userSockets
+	repo      rideStore
+	streams   *stream.Hub
+	processed *processedCache
+	tracker   *rideTracker
+
+	// Distance to pickup at which the passenger gets a one-time driver_arriving push
+	arrivingRadiusKm float64
+
+	// Rides still MATCHED after this long are taken back and re-matched
+	publisher         eventPublisher
+	assignmentTimeout time.Duration
+
+	// Rides still REQUESTED after this long are cancelled as unmatched
+	matchingDeadline time.Duration
+	// Rides still REQUESTED this long after they were requested are expired
+	rideExpiry time.Duration
+
+	// Reaches passengers outside the WebSocket for key ride events; pushes are
+	// queued for a fixed set of workers
+	notifier notify.Notifier
+	pushes   chan pushJob
+
+	// Stops the queue consumers; running tracks them until they have drained
+	stopConsuming context.CancelFunc
+	running       sync.WaitGroup
 }
 
-func New(rabbit *rabbitmq.Connection, log logger.Logger, wsManager *websocket.Manager, repo *repository.PostgresRideRepository) *RideConsumer {
+// defaultArrivingRadiusMeters is used when no arriving radius is configured
+const defaultArrivingRadiusMeters = 200
+
+func New(rabbit *rabbitmq.Connection, log logger.Logger, wsManager *websocket.Manager, repo *repository.PostgresRideRepository, streams *stream.Hub) *RideConsumer {
 	return &RideConsumer{
 		rabbit:    rabbit,
 		log:       log,
 		wsManager: wsManager,
 		repo:      repo,
+		streams:   streams,
+		processed: newProcessedCache(processedCacheSize, processedTTL),
+		tracker:   newRideTracker(),
+
+		arrivingRadiusKm: defaultArrivingRadiusMeters / 1000.0,
+
+		publisher:         messaging.NewRabbitMQEventPublisher(rabbit, log),
+		assignmentTimeout: defaultAssignmentTimeout,
+		matchingDeadline:  defaultMatchingDeadline,
+		rideExpiry:        defaultRideExpiry,
+
+		notifier: notify.Noop{},
+		pushes:   make(chan pushJob, pushQueueSize),
 	}
 }
 
+// SetArrivingRadius sets how close (in meters) the driver must get to the pickup
+// before the passenger is told the driver is arriving; 0 disables the push
+func (c *RideConsumer) SetArrivingRadius(meters float64) {
+	c.arrivingRadiusKm = meters / 1000
+}
+
 // DriverResponseMessage represents driver acceptance/rejection
 type DriverResponseMessage struct {
 	RideID           string    `json:"ride_id"`
@@ -46,11 +131,19 @@ type DriverStatusMessage struct {
 	DriverID    string    `json:"driver_id"`
 	RideID      string    `json:"ride_id,omitempty"`
 	PassengerID string    `json:"passenger_id,omitempty"` // Added for WebSocket notification
+	Status      string    `json:"status,omitempty"`       // used when new_status is absent
 	OldStatus   string    `json:"old_status"`
 	NewStatus   string    `json:"new_status"`
 	Latitude    float64   `json:"latitude,omitempty"`
 	Longitude   float64   `json:"longitude,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	CancelledBy string    `json:"cancelled_by,omitempty"`
+	NoShowFee   float64   `json:"no_show_fee,omitempty"` // Set when the driver reported a passenger no-show
 	Timestamp   time.Time `json:"timestamp"`
+
+	// Set by the admin service, which has already written the ride and its
+	// audit trail; the update is only passed on to the passenger
+	Recorded bool `json:"recorded,omitempty"`
 }
 
 // LocationUpdateMessage represents driver location updates
@@ -58,29 +151,76 @@ type LocationUpdateMessage struct {
 	DriverID       string    `json:"driver_id"`
 	RideID         string    `json:"ride_id,omitempty"`
 	PassengerID    string    `json:"passenger_id"` // Added for WebSocket notification
+	RideStatus     string    `json:"ride_status,omitempty"`
 	Latitude       float64   `json:"latitude"`
 	Longitude      float64   `json:"longitude"`
 	HeadingDegrees float64   `json:"heading_degrees"`
 	Timestamp      time.Time `json:"timestamp"`
+
+	// The driver service nests the position as {"location": {"latitude", "longitude"}}
+	Location *struct {
+		Latitude  float64 `json:"latitude"`
+		Longitude float64 `json:"longitude"`
+	} `json:"location,omitempty"`
 }
 
-// StartConsuming starts all message consumers
+// StartConsuming starts all message consumers. Handlers run with ctx; use Stop,
+// not ctx, to end consumption so in-flight handlers can finish their work.
 func (c *RideConsumer) StartConsuming(ctx context.Context) error {
+	var stopCtx context.Context
+	stopCtx, c.stopConsuming = context.WithCancel(context.Background())
+
 	// Start consuming driver responses
-	go c.consumeDriverResponses(ctx)
+	c.consumeDriverResponses(ctx, stopCtx)
 
 	// Start consuming driver status updates
-	go c.consumeDriverStatus(ctx)
+	c.consumeDriverStatus(ctx, stopCtx)
 
 	// Start consuming location updates
-	go c.consumeLocationUpdates(ctx)
+	c.consumeLocationUpdates(ctx, stopCtx)
+
+	c.startPushWorkers(ctx, stopCtx)
+	c.startMatchingDeadlineSweeper(ctx, stopCtx)
+	c.startRideExpirySweeper(ctx, stopCtx)
+	c.startAssignmentSweeper(ctx, stopCtx)
 
 	c.log.Info("consumers_started", "All message consumers started")
 	return nil
 }
 
+// Stop stops taking messages and the sweepers and waits for in-flight messages
+// to be handled, giving up when ctx is done
+func (c *RideConsumer) Stop(ctx context.Context) error {
+	if c.stopConsuming == nil {
+		return nil
+	}
+	c.stopConsuming()
+
+	drained := make(chan struct{})
+	go func() {
+		c.running.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		c.log.Info("consumers_stopped", "All message consumers stopped")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("consumers did not drain: %w", ctx.Err())
+	}
+}
+
+// track keeps Stop waiting until the consumer behind stopped has exited
+func (c *RideConsumer) track(stopped <-chan struct{}) {
+	c.running.Add(1)
+	go func() {
+		defer c.running.Done()
+		<-stopped
+	}()
+}
+
 // consumeDriverResponses handles driver.response.{ride_id} messages
-func (c *RideConsumer) consumeDriverResponses(ctx context.Context) {
+func (c *RideConsumer) consumeDriverResponses(ctx, stopCtx context.Context) {
 	queueName := "driver_responses"
 
 	c.log.WithFields(logger.LogFields{
@@ -88,20 +228,28 @@ func (c *RideConsumer) consumeDriverResponses(ctx context.Context) {
 	}).Info("consumer_starting", "Starting driver response consumer")
 
 	// Use the correct Consume API - pass handler function
-	err := c.rabbit.Consume(queueName, func(msg amqp.Delivery) {
-		c.handleDriverResponse(ctx, msg.Body)
-		msg.Ack(false)
+	stopped, err := c.rabbit.ConsumeContext(stopCtx, queueName, func(msg amqp.Delivery) {
+		if c.isDuplicate(queueName, msg) {
+			msg.Ack(false)
+			return
+		}
+		c.settle(queueName, msg, c.handleDriverResponse(ctx, msg.Body))
 	})
 	if err != nil {
 		c.log.Error("consume_driver_responses_failed", err)
+		return
 	}
+	c.track(stopped)
 }
 
-func (c *RideConsumer) handleDriverResponse(ctx context.Context, body []byte) {
+// handleDriverResponse applies a driver's answer to an offer. The ride is only
+// tracked and the passenger only notified once the match is stored, so a failed
+// update leaves nothing behind when the message is requeued.
+func (c *RideConsumer) handleDriverResponse(ctx context.Context, body []byte) error {
 	var response DriverResponseMessage
 	if err := json.Unmarshal(body, &response); err != nil {
 		c.log.Error("unmarshal_driver_response_failed", err)
-		return
+		return rabbitmq.Fatal(err)
 	}
 
 	c.log.WithFields(logger.LogFields{
@@ -112,25 +260,19 @@ func (c *RideConsumer) handleDriverResponse(ctx context.Context, body []byte) {
 	}).Info("driver_response_received", "Driver response message received")
 
 	if response.Accepted {
-		// Update ride status to MATCHED in database
-		if err := c.repo.UpdateRideStatus(ctx, response.RideID, "MATCHED"); err != nil {
-			c.log.WithFields(logger.LogFields{
-				"ride_id": response.RideID,
-				"error":   err.Error(),
-			}).Error("update_ride_status_failed", err)
-			// Continue with WebSocket notification even if DB update fails
-		}
-
-		// Assign driver to the ride
+		// Assign driver to the ride; it moves to MATCHED unless the driver's
+		// en-route status got here first
 		if err := c.repo.AssignDriver(ctx, response.RideID, response.DriverID); err != nil {
 			c.log.WithFields(logger.LogFields{
 				"ride_id":   response.RideID,
 				"driver_id": response.DriverID,
 				"error":     err.Error(),
 			}).Error("assign_driver_failed", err)
-			// Continue with WebSocket notification even if assignment fails
+			return err
 		}
 
+		c.tracker.Track(response.DriverID, response.RideID, response.PassengerID)
+
 		// Save DRIVER_MATCHED event to ride_events table
 		matchedEvent := domain.RideMatchedEvent{
 			RideID:      response.RideID,
@@ -151,14 +293,21 @@ func (c *RideConsumer) handleDriverResponse(ctx context.Context, body []byte) {
 		}
 
 		// Send WebSocket notification to passenger
-		notification := map[string]interface{}{
-			"type":              "ride_matched",
+		notification := wsmsg.NewNotification(wsmsg.TypeRideMatched, map[string]interface{}{
 			"ride_id":           response.RideID,
 			"driver_id":         response.DriverID,
 			"status":            "MATCHED",
 			"estimated_arrival": response.EstimatedArrival,
 			"timestamp":         time.Now(),
-		}
+		})
+
+		c.publishToStream(response.RideID, stream.Event{Type: wsmsg.TypeRideMatched, Data: notification})
+		c.push(response.PassengerID, notify.Payload{
+			Type:  string(wsmsg.TypeRideMatched),
+			Title: "Driver found",
+			Body:  "A driver accepted your ride and is on the way",
+			Data:  map[string]interface{}{"ride_id": response.RideID, "driver_id": response.DriverID},
+		})
 
 		// Send notification to the passenger via WebSocket
 		if err := c.wsManager.SendToUser(response.PassengerID, notification); err != nil {
@@ -184,10 +333,11 @@ func (c *RideConsumer) handleDriverResponse(ctx context.Context, body []byte) {
 		// Could send rejection notification to passenger if needed
 		// For now, the ride remains in REQUESTED status for other drivers
 	}
+	return nil
 }
 
 // consumeDriverStatus handles driver.status.* messages
-func (c *RideConsumer) consumeDriverStatus(ctx context.Context) {
+func (c *RideConsumer) consumeDriverStatus(ctx, stopCtx context.Context) {
 	queueName := "driver_status"
 
 	c.log.WithFields(logger.LogFields{
@@ -195,20 +345,52 @@ func (c *RideConsumer) consumeDriverStatus(ctx context.Context) {
 	}).Info("consumer_starting", "Starting driver status consumer")
 
 	// Use the correct Consume API - pass handler function
-	err := c.rabbit.Consume(queueName, func(msg amqp.Delivery) {
-		c.handleDriverStatus(ctx, msg.Body)
-		msg.Ack(false)
+	stopped, err := c.rabbit.ConsumeContext(stopCtx, queueName, func(msg amqp.Delivery) {
+		if c.isDuplicate(queueName, msg) {
+			msg.Ack(false)
+			return
+		}
+		c.settle(queueName, msg, c.handleDriverStatus(ctx, msg.Body))
 	})
 	if err != nil {
 		c.log.Error("consume_driver_status_failed", err)
+		return
 	}
+	c.track(stopped)
+}
+
+// rideStatusByDriverStatus is the allow-list of inbound driver statuses that
+// move a ride, mapped to the ride status they set
+var rideStatusByDriverStatus = map[string]string{
+	"REQUESTED":   "REQUESTED",
+	"MATCHED":     "MATCHED",
+	"EN_ROUTE":    "EN_ROUTE",
+	"ARRIVED":     "ARRIVED",
+	"STARTED":     "IN_PROGRESS",
+	"IN_PROGRESS": "IN_PROGRESS",
+	"COMPLETED":   "COMPLETED",
+	"CANCELLED":   "CANCELLED",
+}
+
+// availabilityStatuses are known driver statuses that don't concern any ride
+var availabilityStatuses = map[string]bool{
+	"AVAILABLE": true,
+	"BUSY":      true,
+	"OFFLINE":   true,
+	"ON_BREAK":  true,
 }
 
-func (c *RideConsumer) handleDriverStatus(ctx context.Context, body []byte) {
+// handleDriverStatus applies a driver status update to the ride and notifies the
+// passenger. It fails for payloads that cannot be parsed and when the ride itself
+// cannot be updated; failing to record the audit event does not hold it up.
+func (c *RideConsumer) handleDriverStatus(ctx context.Context, body []byte) error {
 	var status DriverStatusMessage
 	if err := json.Unmarshal(body, &status); err != nil {
 		c.log.Error("unmarshal_driver_status_failed", err)
-		return
+		return rabbitmq.Fatal(err)
+	}
+	if status.NewStatus == "" {
+		status.NewStatus = status.Status
 	}
 
 	c.log.WithFields(logger.LogFields{
@@ -219,41 +401,52 @@ func (c *RideConsumer) handleDriverStatus(ctx context.Context, body []byte) {
 		"new_status":   status.NewStatus,
 	}).Info("driver_status_received", "Driver status update received")
 
-	// Update ride status in database based on driver status
-	var rideStatus string
-	switch status.NewStatus {
-	case "EN_ROUTE":
-		rideStatus = "EN_ROUTE"
-	case "ARRIVED":
-		rideStatus = "ARRIVED"
-	case "STARTED", "IN_PROGRESS":
-		rideStatus = "IN_PROGRESS"
-	case "COMPLETED":
-		rideStatus = "COMPLETED"
-	case "MATCHED":
-		rideStatus = "MATCHED"
-	case "REQUESTED":
-		rideStatus = "REQUESTED"
-	case "CANCELLED":
-		rideStatus = "CANCELLED"
-	default:
-		// Unknown or empty status, log and skip DB update
+	// Only allow-listed statuses touch the ride or reach the passenger
+	rideStatus, ok := rideStatusByDriverStatus[status.NewStatus]
+	if !ok {
+		if availabilityStatuses[status.NewStatus] {
+			c.log.WithFields(logger.LogFields{
+				"driver_id": status.DriverID,
+				"status":    status.NewStatus,
+			}).Debug("driver_availability_status", "Driver availability change, no ride update")
+			return nil
+		}
 		c.log.WithFields(logger.LogFields{
-			"status": status.NewStatus,
-		}).Info("unknown_driver_status", "Unknown or empty driver status received, skipping DB update")
-		// Don't update the database with invalid status
-		rideStatus = ""
+			"driver_id": status.DriverID,
+			"ride_id":   status.RideID,
+			"status":    status.NewStatus,
+		}).Warn("unknown_driver_status", "Unknown or empty driver status received, ignoring")
+		return nil
 	}
 
-	// Update ride status in database if we have a valid ride_id and status
-	if status.RideID != "" && rideStatus != "" {
+	switch {
+	case status.RideID == "":
+	case rideStatus == "COMPLETED" || rideStatus == "CANCELLED" || rideStatus == "REQUESTED":
+		c.tracker.Untrack(status.DriverID, status.RideID)
+	default:
+		c.tracker.Track(status.DriverID, status.RideID, status.PassengerID)
+		if rideStatus == "ARRIVED" || rideStatus == "IN_PROGRESS" {
+			// Past pickup, an arriving push would be stale
+			c.tracker.MarkArriving(status.DriverID, status.RideID)
+		}
+	}
+
+	// Update ride status in database if we have a valid ride_id and status.
+	// Recorded updates are already written; writing them again could also undo
+	// a match made since a ride was sent back to REQUESTED.
+	writeRide := status.RideID != "" && !status.Recorded
+	if writeRide && rideStatus == "CANCELLED" {
+		if err := c.handleRideCancelled(ctx, status); err != nil {
+			return err
+		}
+	} else if writeRide {
 		if err := c.repo.UpdateRideStatus(ctx, status.RideID, rideStatus); err != nil {
 			c.log.WithFields(logger.LogFields{
 				"ride_id": status.RideID,
 				"status":  rideStatus,
 				"error":   err.Error(),
 			}).Error("update_ride_status_failed", err)
-			// Continue with WebSocket notification even if DB update fails
+			return err
 		}
 
 		// Save status change event to ride_events table
@@ -295,14 +488,45 @@ func (c *RideConsumer) handleDriverStatus(ctx context.Context, body []byte) {
 	}
 
 	// Send WebSocket notification to passenger
-	notification := map[string]interface{}{
-		"type":      "ride_status_update",
+	notification := wsmsg.NewNotification(wsmsg.TypeRideStatusUpdate, map[string]interface{}{
 		"ride_id":   status.RideID,
 		"driver_id": status.DriverID,
 		"status":    status.NewStatus,
 		"latitude":  status.Latitude,
 		"longitude": status.Longitude,
 		"timestamp": status.Timestamp,
+	})
+	if rideStatus == "CANCELLED" {
+		notification["reason"] = status.Reason
+		notification["cancelled_by"] = status.CancelledBy
+		if status.NoShowFee > 0 {
+			notification["no_show_fee"] = status.NoShowFee
+		}
+	}
+	if rideStatus == "REQUESTED" {
+		notification["reason"] = status.Reason
+		notification["message"] = "Your driver is unavailable, finding you a new driver"
+	}
+
+	if status.RideID != "" {
+		c.publishToStream(status.RideID, stream.Event{
+			Type:     wsmsg.TypeRideStatusUpdate,
+			Data:     notification,
+			Terminal: rideStatus == "COMPLETED" || rideStatus == "CANCELLED",
+		})
+	}
+	if rideStatus == "CANCELLED" {
+		c.push(status.PassengerID, notify.Payload{
+			Type:  string(wsmsg.TypeRideStatusUpdate),
+			Title: "Ride cancelled",
+			Body:  "Your ride was cancelled",
+			Data: map[string]interface{}{
+				"ride_id":      status.RideID,
+				"status":       rideStatus,
+				"reason":       status.Reason,
+				"cancelled_by": status.CancelledBy,
+			},
+		})
 	}
 
 	// Send notification to passenger via WebSocket
@@ -321,24 +545,80 @@ func (c *RideConsumer) handleDriverStatus(ctx context.Context, body []byte) {
 			}).Info("status_notification_sent", "Status update sent via WebSocket")
 		}
 	}
+	return nil
+}
+
+// handleRideCancelled cancels the ride and records who cancelled it
+func (c *RideConsumer) handleRideCancelled(ctx context.Context, status DriverStatusMessage) error {
+	reason := status.Reason
+	if reason == "" {
+		reason = "Cancelled by driver"
+	}
+	cancelledBy := status.CancelledBy
+	if cancelledBy == "" {
+		cancelledBy = domain.CancelledByDriver
+	}
+
+	if err := c.repo.CancelRide(ctx, status.RideID, reason, cancelledBy, status.NoShowFee); err != nil {
+		c.log.WithFields(logger.LogFields{
+			"ride_id": status.RideID,
+			"error":   err.Error(),
+		}).Error("cancel_ride_failed", err)
+		return err
+	}
+
+	var driverID *string
+	if status.DriverID != "" {
+		driverID = &status.DriverID
+	}
+	cancelledEvent := domain.RideCancelledEvent{
+		RideID:      status.RideID,
+		PassengerID: status.PassengerID,
+		DriverID:    driverID,
+		Reason:      reason,
+		CancelledBy: cancelledBy,
+		Fee:         status.NoShowFee,
+		CancelledAt: time.Now(),
+	}
+	if err := c.repo.SaveEvent(ctx, status.RideID, cancelledEvent); err != nil {
+		c.log.WithFields(logger.LogFields{
+			"ride_id": status.RideID,
+			"error":   err.Error(),
+		}).Error("save_cancelled_event_failed", err)
+		return nil
+	}
+
+	c.log.WithFields(logger.LogFields{
+		"ride_id":      status.RideID,
+		"cancelled_by": cancelledBy,
+	}).Info("event_saved", "RIDE_CANCELLED event saved to ride_events")
+	return nil
 }
 
-// consumeLocationUpdates handles location updates from location_fanout
-func (c *RideConsumer) consumeLocationUpdates(ctx context.Context) {
-	queueName := "location_updates_ride"
+// consumeLocationUpdates handles location updates from location_fanout. The
+// passenger may be connected to any instance, so each one binds its own queue
+// and gets every update.
+func (c *RideConsumer) consumeLocationUpdates(ctx, stopCtx context.Context) {
+	queueName, err := c.rabbit.DeclareInstanceQueue("location_updates_ride", "location_fanout")
+	if err != nil {
+		c.log.Error("declare_location_queue_failed", err)
+		return
+	}
 
 	c.log.WithFields(logger.LogFields{
 		"queue": queueName,
 	}).Info("consumer_starting", "Starting location update consumer")
 
 	// Use the correct Consume API - pass handler function
-	err := c.rabbit.Consume(queueName, func(msg amqp.Delivery) {
+	stopped, err := c.rabbit.ConsumeContext(stopCtx, queueName, func(msg amqp.Delivery) {
 		c.handleLocationUpdate(ctx, msg.Body)
 		msg.Ack(false)
 	})
 	if err != nil {
 		c.log.Error("consume_location_updates_failed", err)
+		return
 	}
+	c.track(stopped)
 }
 
 func (c *RideConsumer) handleLocationUpdate(ctx context.Context, body []byte) {
@@ -347,6 +627,15 @@ func (c *RideConsumer) handleLocationUpdate(ctx context.Context, body []byte) {
 		c.log.Error("unmarshal_location_update_failed", err)
 		return
 	}
+	if location.Location != nil {
+		location.Latitude = location.Location.Latitude
+		location.Longitude = location.Location.Longitude
+	}
+
+	// Every instance sees every driver's location; drop irrelevant ones early
+	if !c.resolveLocationTarget(&location) {
+		return
+	}
 
 	// Only log occasionally to avoid spam (debug level)
 	c.log.WithFields(logger.LogFields{
@@ -359,15 +648,16 @@ func (c *RideConsumer) handleLocationUpdate(ctx context.Context, body []byte) {
 
 	// Send WebSocket notification to passenger with driver location
 	if location.RideID != "" && location.PassengerID != "" {
-		notification := map[string]interface{}{
-			"type":            "driver_location_update",
+		notification := wsmsg.NewNotification(wsmsg.TypeDriverLocationUpdate, map[string]interface{}{
 			"ride_id":         location.RideID,
 			"driver_id":       location.DriverID,
 			"latitude":        location.Latitude,
 			"longitude":       location.Longitude,
 			"heading_degrees": location.HeadingDegrees,
 			"timestamp":       location.Timestamp,
-		}
+		})
+
+		c.publishToStream(location.RideID, stream.Event{Type: wsmsg.TypeDriverLocationUpdate, Data: notification})
 
 		// Send notification to passenger via WebSocket
 		if err := c.wsManager.SendToUser(location.PassengerID, notification); err != nil {
@@ -378,7 +668,144 @@ func (c *RideConsumer) handleLocationUpdate(ctx context.Context, body []byte) {
 			}).Debug("websocket_location_notification_failed", "Failed to send location update") // Debug to avoid spam
 		}
 		// No success log for location updates to avoid spam
+
+		c.checkDriverArriving(ctx, location)
+	}
+}
+
+// checkDriverArriving pushes a one-time driver_arriving notification the first
+// time the driver comes within the arriving radius of the pickup
+func (c *RideConsumer) checkDriverArriving(ctx context.Context, location LocationUpdateMessage) {
+	if c.arrivingRadiusKm <= 0 {
+		return
+	}
+	// Past pickup the push would be stale
+	if location.RideStatus != "" && location.RideStatus != "MATCHED" && location.RideStatus != "EN_ROUTE" {
+		return
+	}
+	tracked, ok := c.tracker.Lookup(location.DriverID)
+	if (!ok || tracked.RideID != location.RideID) && location.RideStatus != "" {
+		// The match was handled by another instance; the driver service named the ride
+		c.tracker.Track(location.DriverID, location.RideID, location.PassengerID)
+		tracked, ok = c.tracker.Lookup(location.DriverID)
+	}
+	if !ok || tracked.RideID != location.RideID || tracked.arrivingSent {
+		return
+	}
+
+	pickup := tracked.pickup
+	if pickup == nil {
+		ride, err := c.repo.FindByID(ctx, location.RideID)
+		if err != nil {
+			c.log.WithFields(logger.LogFields{
+				"ride_id": location.RideID,
+				"error":   err.Error(),
+			}).Error("load_pickup_failed", err)
+			return
+		}
+		p := ride.PickupLocation()
+		pickup = &p
+		c.tracker.SetPickup(location.DriverID, location.RideID, p)
+	}
+
+	driverPos, err := domain.NewCoordinate(location.Latitude, location.Longitude, "")
+	if err != nil {
+		return
+	}
+	distanceKm := driverPos.DistanceTo(*pickup)
+	if distanceKm > c.arrivingRadiusKm || !c.tracker.MarkArriving(location.DriverID, location.RideID) {
+		return
+	}
+
+	notification := wsmsg.NewNotification(wsmsg.TypeDriverArriving, map[string]interface{}{
+		"ride_id":         location.RideID,
+		"driver_id":       location.DriverID,
+		"distance_meters": int(distanceKm * 1000),
+		"message":         "Your driver is arriving",
+	})
+	c.publishToStream(location.RideID, stream.Event{Type: wsmsg.TypeDriverArriving, Data: notification})
+	c.push(location.PassengerID, notify.Payload{
+		Type:  string(wsmsg.TypeDriverArriving),
+		Title: "Driver arriving",
+		Body:  "Your driver is arriving",
+		Data:  map[string]interface{}{"ride_id": location.RideID, "driver_id": location.DriverID},
+	})
+	if err := c.wsManager.SendToUser(location.PassengerID, notification); err != nil {
+		c.log.WithFields(logger.LogFields{
+			"passenger_id": location.PassengerID,
+			"ride_id":      location.RideID,
+			"error":        err.Error(),
+		}).Error("websocket_arriving_notification_failed", err)
+		return
+	}
+
+	c.log.WithFields(logger.LogFields{
+		"ride_id":         location.RideID,
+		"driver_id":       location.DriverID,
+		"distance_meters": int(distanceKm * 1000),
+	}).Info("driver_arriving_sent", "Driver arriving notification sent")
+}
+
+// resolveLocationTarget fills in the ride and passenger for a location update from
+// the tracker and reports whether anyone on this instance is listening for it
+func (c *RideConsumer) resolveLocationTarget(location *LocationUpdateMessage) bool {
+	if location.RideID == "" || location.PassengerID == "" {
+		tracked, ok := c.tracker.Lookup(location.DriverID)
+		if !ok {
+			return false
+		}
+		location.RideID = tracked.RideID
+		location.PassengerID = tracked.PassengerID
+	}
+	if location.PassengerID == "" {
+		return false
 	}
 
-	// TODO: Calculate distance to pickup/destination and send arrival estimates
+	if c.wsManager.IsUserConnected(location.PassengerID) {
+		return true
+	}
+	return c.streams != nil && c.streams.SubscriberCount(location.RideID) > 0
+}
+
+// isDuplicate reports whether the delivery was already processed, based on its message id
+func (c *RideConsumer) isDuplicate(queueName string, msg amqp.Delivery) bool {
+	key := messageKey(msg)
+	if key == "" {
+		return false
+	}
+	if !c.processed.markProcessed(key) {
+		return false
+	}
+
+	c.log.WithFields(logger.LogFields{
+		"queue":       queueName,
+		"message_id":  key,
+		"redelivered": msg.Redelivered,
+	}).Info("duplicate_message_skipped", "Message already processed, skipping")
+	return true
+}
+
+// settle acks, retries or dead-letters msg according to the handler's error. A
+// retried message is forgotten so its redelivery isn't skipped as a duplicate.
+func (c *RideConsumer) settle(queueName string, msg amqp.Delivery, err error) {
+	if c.rabbit.Settle(queueName, msg, classify(err)) {
+		c.processed.forget(messageKey(msg))
+	}
+}
+
+// classify marks errors redelivery cannot fix as fatal; the rest are left to
+// rabbitmq.IsRetryable
+func classify(err error) error {
+	if errors.Is(err, domain.ErrRideNotFound) {
+		return rabbitmq.Fatal(err)
+	}
+	return err
+}
+
+// publishToStream forwards a passenger notification to SSE subscribers of the ride
+func (c *RideConsumer) publishToStream(rideID string, event stream.Event) {
+	if c.streams == nil {
+		return
+	}
+	c.streams.Publish(rideID, event)
 }
@@ -0,0 +1,205 @@
+package consumer
+
+import (
+	"fmt"
+	"testing"
+
+	"ride-hail/internal/ride-service/domain"
+	"ride-hail/pkg/wsmsg"
+)
+
+func TestDriverCancellationCancelsRideAndNotifiesPassenger(t *testing.T) {
+	_, broker, store, sockets := newTestConsumer()
+	body := `{"driver_id":"driver-1","ride_id":"ride-1","passenger_id":"passenger-1","old_status":"EN_ROUTE","new_status":"CANCELLED","reason":"Passenger unreachable","cancelled_by":"DRIVER"}`
+
+	broker.deliver("driver_status", delivery(&fakeAcknowledger{}, "msg-1", body))
+
+	if len(store.cancelled) != 1 {
+		t.Fatalf("CancelRide called %d times, want 1", len(store.cancelled))
+	}
+	got := store.cancelled[0]
+	if got.rideID != "ride-1" || got.cancelledBy != domain.CancelledByDriver || got.reason != "Passenger unreachable" {
+		t.Errorf("cancellation = %+v, want ride-1 cancelled by DRIVER with the driver's reason", got)
+	}
+	if len(store.events) != 1 || store.events[0] != "ride-1/ride.cancelled" {
+		t.Errorf("events = %v, want one ride.cancelled", store.events)
+	}
+
+	n, ok := sockets.last["passenger-1"].(wsmsg.Notification)
+	if !ok {
+		t.Fatalf("passenger sent %T, want a notification", sockets.last["passenger-1"])
+	}
+	if n["type"] != wsmsg.TypeRideStatusUpdate || n["status"] != "CANCELLED" || n["cancelled_by"] != domain.CancelledByDriver {
+		t.Errorf("passenger notification = %v, want a CANCELLED status update naming the driver", n)
+	}
+}
+
+func TestDriverCancellationDefaultsParty(t *testing.T) {
+	_, broker, store, _ := newTestConsumer()
+	body := `{"driver_id":"driver-1","ride_id":"ride-1","passenger_id":"passenger-1","new_status":"CANCELLED"}`
+
+	broker.deliver("driver_status", delivery(&fakeAcknowledger{}, "msg-1", body))
+
+	if len(store.cancelled) != 1 || store.cancelled[0].cancelledBy != domain.CancelledByDriver {
+		t.Errorf("cancellations = %+v, want one by DRIVER", store.cancelled)
+	}
+}
+
+// statusBody is a driver_status message moving ride-1 to newStatus
+func statusBody(newStatus string) string {
+	return `{"driver_id":"driver-1","ride_id":"ride-1","passenger_id":"passenger-1","old_status":"MATCHED","new_status":"` + newStatus + `"}`
+}
+
+func TestKnownDriverStatusUpdatesRideAndNotifies(t *testing.T) {
+	tests := []struct {
+		driverStatus, rideStatus string
+	}{
+		{"EN_ROUTE", "EN_ROUTE"},
+		{"ARRIVED", "ARRIVED"},
+		{"STARTED", "IN_PROGRESS"},
+		{"IN_PROGRESS", "IN_PROGRESS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.driverStatus, func(t *testing.T) {
+			_, broker, store, sockets := newTestConsumer()
+			ack := &fakeAcknowledger{}
+
+			broker.deliver("driver_status", delivery(ack, "msg-1", statusBody(tt.driverStatus)))
+
+			if len(store.statuses) != 1 || store.statuses[0] != "ride-1/"+tt.rideStatus {
+				t.Errorf("ride statuses = %v, want [ride-1/%s]", store.statuses, tt.rideStatus)
+			}
+			n, _ := sockets.last["passenger-1"].(wsmsg.Notification)
+			if sockets.sent["passenger-1"] != 1 || n["type"] != wsmsg.TypeRideStatusUpdate {
+				t.Errorf("passenger sent %d messages, last %v; want one status update", sockets.sent["passenger-1"], n)
+			}
+			if ack.acks != 1 {
+				t.Errorf("acks = %d, want 1", ack.acks)
+			}
+		})
+	}
+}
+
+func TestRideBackInMatchingNotifiesWithoutWriting(t *testing.T) {
+	_, broker, store, sockets := newTestConsumer()
+	body := `{"driver_id":"driver-1","ride_id":"ride-1","passenger_id":"passenger-1","old_status":"MATCHED","new_status":"REQUESTED","reason":"admin_force_offline","recorded":true}`
+
+	broker.deliver("driver_status", delivery(&fakeAcknowledger{}, "msg-1", body))
+
+	if len(store.statuses) != 0 {
+		t.Errorf("ride statuses = %v, want nothing written for a recorded update", store.statuses)
+	}
+	n, ok := sockets.last["passenger-1"].(wsmsg.Notification)
+	if !ok {
+		t.Fatalf("passenger sent %T, want a notification", sockets.last["passenger-1"])
+	}
+	if n["status"] != "REQUESTED" || n["reason"] != "admin_force_offline" || n["message"] == nil {
+		t.Errorf("passenger notification = %v, want REQUESTED with the reason and a message", n)
+	}
+}
+
+func TestAdminCancellationNotifiesWithoutWriting(t *testing.T) {
+	_, broker, store, sockets := newTestConsumer()
+	body := `{"driver_id":"driver-1","ride_id":"ride-1","passenger_id":"passenger-1","old_status":"EN_ROUTE","new_status":"CANCELLED","reason":"Duplicate booking","cancelled_by":"ADMIN","recorded":true}`
+
+	broker.deliver("driver_status", delivery(&fakeAcknowledger{}, "msg-1", body))
+
+	if len(store.cancelled) != 0 || len(store.events) != 0 {
+		t.Errorf("cancellations %+v, events %v; want nothing written for a recorded cancellation", store.cancelled, store.events)
+	}
+	n, _ := sockets.last["passenger-1"].(wsmsg.Notification)
+	if n["status"] != "CANCELLED" || n["reason"] != "Duplicate booking" || n["cancelled_by"] != domain.CancelledByAdmin {
+		t.Errorf("passenger notification = %v, want CANCELLED by ADMIN with the reason", n)
+	}
+}
+
+func TestUnknownDriverStatusIsIgnored(t *testing.T) {
+	for _, status := range []string{"TELEPORTED", "en_route", "", "AVAILABLE", "ON_BREAK"} {
+		t.Run(status, func(t *testing.T) {
+			_, broker, store, sockets := newTestConsumer()
+			ack := &fakeAcknowledger{}
+
+			broker.deliver("driver_status", delivery(ack, "msg-1", statusBody(status)))
+
+			if len(store.statuses) != 0 || len(store.events) != 0 {
+				t.Errorf("ride statuses %v, events %v; want nothing written", store.statuses, store.events)
+			}
+			if n := sockets.total(); n != 0 {
+				t.Errorf("sent %d notifications, want none", n)
+			}
+			// Redelivery can't make it known, so it is settled, not requeued
+			if ack.acks != 1 || broker.requeued != 0 || broker.deadLettered != 0 {
+				t.Errorf("acks=%d requeued=%d dead-lettered=%d, want a plain ack", ack.acks, broker.requeued, broker.deadLettered)
+			}
+		})
+	}
+}
+
+func TestMalformedDriverStatusIsDeadLettered(t *testing.T) {
+	_, broker, store, sockets := newTestConsumer()
+
+	broker.deliver("driver_status", delivery(&fakeAcknowledger{}, "msg-1", `{"driver_id":`))
+
+	if broker.deadLettered != 1 || broker.requeued != 0 {
+		t.Errorf("dead-lettered=%d requeued=%d, want the message dead-lettered", broker.deadLettered, broker.requeued)
+	}
+	if len(store.statuses) != 0 || sockets.total() != 0 {
+		t.Error("malformed message updated the ride or notified someone")
+	}
+}
+
+func TestRideNotFoundIsDeadLettered(t *testing.T) {
+	_, broker, store, sockets := newTestConsumer()
+	store.failures = 1
+	store.failErr = fmt.Errorf("update ride status: %w", domain.ErrRideNotFound)
+	ack := &fakeAcknowledger{}
+
+	broker.deliver("driver_status", delivery(ack, "msg-1", statusBody("EN_ROUTE")))
+
+	if broker.deadLettered != 1 || broker.requeued != 0 || ack.acks != 1 {
+		t.Errorf("dead-lettered=%d requeued=%d acks=%d, want the message dead-lettered", broker.deadLettered, broker.requeued, ack.acks)
+	}
+	if sockets.total() != 0 {
+		t.Error("passenger notified about a ride that does not exist")
+	}
+}
+
+func TestDatabaseTimeoutIsRequeued(t *testing.T) {
+	_, broker, store, _ := newTestConsumer()
+	store.failures = 1
+	ack := &fakeAcknowledger{}
+
+	broker.deliver("driver_status", delivery(ack, "msg-1", statusBody("EN_ROUTE")))
+
+	if broker.requeued != 1 || broker.deadLettered != 0 || ack.nacks != 1 {
+		t.Fatalf("requeued=%d dead-lettered=%d nacks=%d, want the message requeued", broker.requeued, broker.deadLettered, ack.nacks)
+	}
+	broker.deliver("driver_status", delivery(ack, "msg-1", statusBody("EN_ROUTE")))
+	if len(store.statuses) != 1 || store.statuses[0] != "ride-1/EN_ROUTE" {
+		t.Errorf("ride statuses = %v, want EN_ROUTE written on redelivery", store.statuses)
+	}
+}
+
+func TestPassengerNoShowChargesFeeAndNotifiesPassenger(t *testing.T) {
+	_, broker, store, sockets := newTestConsumer()
+	body := `{"driver_id":"driver-1","ride_id":"ride-1","passenger_id":"passenger-1","old_status":"ARRIVED","new_status":"CANCELLED","reason":"PASSENGER_NO_SHOW","cancelled_by":"DRIVER","no_show_fee":100}`
+
+	broker.deliver("driver_status", delivery(&fakeAcknowledger{}, "msg-1", body))
+
+	if len(store.cancelled) != 1 {
+		t.Fatalf("CancelRide called %d times, want 1", len(store.cancelled))
+	}
+	if got := store.cancelled[0]; got.reason != "PASSENGER_NO_SHOW" || got.fee != 100 {
+		t.Errorf("cancellation = %+v, want PASSENGER_NO_SHOW with fee 100", got)
+	}
+	if len(store.events) != 1 || store.events[0] != "ride-1/ride.cancelled" {
+		t.Errorf("events = %v, want one ride.cancelled", store.events)
+	}
+	n, ok := sockets.last["passenger-1"].(wsmsg.Notification)
+	if !ok {
+		t.Fatalf("passenger sent %T, want a notification", sockets.last["passenger-1"])
+	}
+	if n["reason"] != "PASSENGER_NO_SHOW" || n["no_show_fee"] != 100.0 {
+		t.Errorf("passenger notification = %v, want the no-show reason and fee", n)
+	}
+}
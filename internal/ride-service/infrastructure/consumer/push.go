@@ -0,0 +1,82 @@
+package consumer
+
+import (
+	"context"
+	"time"
+
+	"ride-hail/pkg/logger"
+	"ride-hail/pkg/notify"
+)
+
+const (
+	// Pushes in flight at once; the provider is slow next to the queues feeding it
+	pushWorkers = 4
+	// Pushes waiting for a worker before new ones are dropped
+	pushQueueSize = 256
+	// Longest a single push may take before it is abandoned
+	pushTimeout = 5 * time.Second
+)
+
+// pushJob is one notification waiting for a push worker
+type pushJob struct {
+	userID  string
+	payload notify.Payload
+}
+
+// SetNotifier sets where key ride events (matched, arriving, cancelled) are sent
+// besides the WebSocket, so passengers whose app is in the background hear about them
+func (c *RideConsumer) SetNotifier(n notify.Notifier) {
+	c.notifier = n
+}
+
+// startPushWorkers starts the workers that deliver queued pushes, until stopCtx
+// is cancelled. Each push runs with ctx and its own timeout.
+func (c *RideConsumer) startPushWorkers(ctx, stopCtx context.Context) {
+	for i := 0; i < pushWorkers; i++ {
+		c.running.Add(1)
+		go func() {
+			defer c.running.Done()
+			for {
+				select {
+				case <-stopCtx.Done():
+					return
+				case job := <-c.pushes:
+					c.sendPush(ctx, job)
+				}
+			}
+		}()
+	}
+}
+
+// push queues a notification without holding up the consumer. When the workers
+// are that far behind the push is dropped; the WebSocket and SSE stream carry
+// the event too.
+func (c *RideConsumer) push(userID string, payload notify.Payload) {
+	if userID == "" {
+		return
+	}
+	select {
+	case c.pushes <- pushJob{userID: userID, payload: payload}:
+	default:
+		c.log.WithFields(logger.LogFields{
+			"user_id": userID,
+			"type":    payload.Type,
+			"ride_id": payload.Data["ride_id"],
+		}).Warn("push_queue_full", "Push queue full, dropping notification")
+	}
+}
+
+// sendPush delivers one notification; failures are only logged
+func (c *RideConsumer) sendPush(ctx context.Context, job pushJob) {
+	ctx, cancel := context.WithTimeout(ctx, pushTimeout)
+	defer cancel()
+
+	if err := c.notifier.SendPush(ctx, job.userID, job.payload); err != nil {
+		c.log.WithFields(logger.LogFields{
+			"user_id": job.userID,
+			"type":    job.payload.Type,
+			"ride_id": job.payload.Data["ride_id"],
+			"error":   err.Error(),
+		}).Error("push_notification_failed", err)
+	}
+}
@@ -0,0 +1,212 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"ride-hail/internal/ride-service/domain"
+	"ride-hail/internal/ride-service/infrastructure/repository"
+	"ride-hail/pkg/notify"
+	"ride-hail/pkg/wsmsg"
+)
+
+// sentPush is one SendPush call
+type sentPush struct {
+	userID  string
+	payload notify.Payload
+}
+
+// fakeNotifier records pushes on sent; the next failures of them return an error
+type fakeNotifier struct {
+	sent chan sentPush
+
+	mu       sync.Mutex
+	failures int
+}
+
+func (n *fakeNotifier) SendPush(ctx context.Context, userID string, payload notify.Payload) error {
+	if _, ok := ctx.Deadline(); !ok {
+		return errors.New("push without a deadline")
+	}
+	n.sent <- sentPush{userID, payload}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.failures > 0 {
+		n.failures--
+		return errors.New("provider unavailable")
+	}
+	return nil
+}
+
+// withNotifier gives c a fake notifier and starts its push workers until the
+// test ends; pushes queued before the call are delivered too
+func withNotifier(t *testing.T, c *RideConsumer) *fakeNotifier {
+	t.Helper()
+	n := &fakeNotifier{sent: make(chan sentPush, 16)}
+	c.SetNotifier(n)
+	ctx, stop := context.WithCancel(context.Background())
+	c.startPushWorkers(ctx, ctx)
+	t.Cleanup(func() {
+		stop()
+		c.running.Wait()
+	})
+	return n
+}
+
+// pushes waits for want pushes and returns them by type
+func (n *fakeNotifier) pushes(t *testing.T, want int) map[string]sentPush {
+	t.Helper()
+	byType := make(map[string]sentPush)
+	for i := 0; i < want; i++ {
+		select {
+		case p := <-n.sent:
+			byType[p.payload.Type] = p
+		case <-time.After(time.Second):
+			t.Fatalf("got %d pushes, want %d", i, want)
+		}
+	}
+	return byType
+}
+
+// none fails if anything is pushed shortly after the call
+func (n *fakeNotifier) none(t *testing.T) {
+	t.Helper()
+	select {
+	case p := <-n.sent:
+		t.Errorf("pushed %+v, want nothing", p)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestMatchAndArrivalArePushedToPassenger(t *testing.T) {
+	c, broker, _, _ := newArrivingConsumer(t)
+	n := withNotifier(t, c)
+
+	matched := n.pushes(t, 1)[string(wsmsg.TypeRideMatched)]
+	if matched.userID != "passenger-1" || matched.payload.Data["ride_id"] != "ride-1" || matched.payload.Data["driver_id"] != "driver-1" {
+		t.Errorf("match push = %+v, want passenger-1 told driver-1 took ride-1", matched)
+	}
+	if matched.payload.Title == "" || matched.payload.Body == "" {
+		t.Errorf("match push %+v has no text to show", matched.payload)
+	}
+
+	driverAt(broker, 0.01) // far away, no push
+	n.none(t)
+	driverAt(broker, 0.0015)
+	arriving := n.pushes(t, 1)[string(wsmsg.TypeDriverArriving)]
+	if arriving.userID != "passenger-1" || arriving.payload.Data["ride_id"] != "ride-1" {
+		t.Errorf("arriving push = %+v, want passenger-1 about ride-1", arriving)
+	}
+}
+
+func TestMatchPushUsesTheDriverServicePayload(t *testing.T) {
+	c, broker, store, sockets := newTestConsumer()
+	n := withNotifier(t, c)
+	// Exactly what the driver service's sendDriverResponse publishes on acceptance
+	body := `{
+		"ride_id": "ride-1",
+		"driver_id": "driver-1",
+		"passenger_id": "passenger-1",
+		"accepted": true,
+		"correlation_id": "corr-1",
+		"timestamp": "2024-12-16T10:00:00Z",
+		"driver_info": {"name": "driver@example.com", "rating": 4.8, "vehicle": {"make": "Toyota"}},
+		"driver_location": {"latitude": 43.2390, "longitude": 76.8900},
+		"estimated_arrival_minutes": 3
+	}`
+
+	broker.deliver("driver_responses", delivery(&fakeAcknowledger{}, "msg-1", body))
+
+	if len(store.assigned) != 1 || store.assigned[0] != "ride-1/driver-1" {
+		t.Fatalf("assigned %v, want ride-1 assigned to driver-1", store.assigned)
+	}
+	if sockets.count("passenger-1", wsmsg.TypeRideMatched) != 1 {
+		t.Error("passenger-1 not told over the socket")
+	}
+	p := n.pushes(t, 1)[string(wsmsg.TypeRideMatched)]
+	if p.userID != "passenger-1" || p.payload.Data["ride_id"] != "ride-1" {
+		t.Errorf("match push = %+v, want passenger-1 about ride-1", p)
+	}
+}
+
+func TestDriverCancellationIsPushedToPassenger(t *testing.T) {
+	c, broker, _, _ := newTestConsumer()
+	n := withNotifier(t, c)
+	body := `{"driver_id":"driver-1","ride_id":"ride-1","passenger_id":"passenger-1","old_status":"EN_ROUTE","new_status":"CANCELLED","reason":"Passenger unreachable","cancelled_by":"DRIVER"}`
+
+	broker.deliver("driver_status", delivery(&fakeAcknowledger{}, "msg-1", body))
+
+	p := n.pushes(t, 1)[string(wsmsg.TypeRideStatusUpdate)]
+	if p.userID != "passenger-1" || p.payload.Data["status"] != "CANCELLED" || p.payload.Data["cancelled_by"] != domain.CancelledByDriver || p.payload.Data["reason"] != "Passenger unreachable" {
+		t.Errorf("push = %+v, want passenger-1 told the driver cancelled", p)
+	}
+}
+
+func TestNoDriverCancellationIsPushedToPassenger(t *testing.T) {
+	c, _, store, _ := newTestConsumer()
+	n := withNotifier(t, c)
+	store.unmatched = []repository.UnmatchedRide{{RideID: "ride-1", PassengerID: "passenger-1"}}
+
+	c.cancelUnmatchedRides(context.Background())
+
+	p := n.pushes(t, 1)[string(wsmsg.TypeRideStatusUpdate)]
+	if p.userID != "passenger-1" || p.payload.Data["reason"] != domain.ReasonNoDriverFound || p.payload.Data["cancelled_by"] != domain.CancelledBySystem {
+		t.Errorf("push = %+v, want passenger-1 told no driver was found", p)
+	}
+}
+
+func TestRoutineStatusChangesAreNotPushed(t *testing.T) {
+	c, broker, _, sockets := newTestConsumer()
+	n := withNotifier(t, c)
+
+	for i, status := range []string{"EN_ROUTE", "ARRIVED", "STARTED"} {
+		broker.deliver("driver_status", delivery(&fakeAcknowledger{}, string(rune('a'+i)), statusBody(status)))
+	}
+
+	if sockets.count("passenger-1", wsmsg.TypeRideStatusUpdate) != 3 {
+		t.Errorf("passenger sent %d status updates over the socket, want 3", sockets.count("passenger-1", wsmsg.TypeRideStatusUpdate))
+	}
+	n.none(t)
+}
+
+func TestFailedPushDoesNotStopLaterOnes(t *testing.T) {
+	c, _, _, _ := newTestConsumer()
+	n := withNotifier(t, c)
+	n.failures = 1
+
+	c.push("passenger-1", notify.Payload{Type: "first"})
+	n.pushes(t, 1)
+	c.push("passenger-1", notify.Payload{Type: "second"})
+	if p := n.pushes(t, 1); p["second"].userID != "passenger-1" {
+		t.Errorf("pushes after a failure = %v, want the second delivered", p)
+	}
+}
+
+func TestPushesWithoutPassengerOrRoomAreDropped(t *testing.T) {
+	c, _, _, _ := newTestConsumer()
+
+	c.push("", notify.Payload{Type: "anonymous"})
+	if len(c.pushes) != 0 {
+		t.Errorf("%d pushes queued without a user, want none", len(c.pushes))
+	}
+
+	// With no workers draining the queue, a full queue drops instead of blocking
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < pushQueueSize+10; i++ {
+			c.push("passenger-1", notify.Payload{Type: "flood"})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("push blocked on a full queue")
+	}
+	if len(c.pushes) != pushQueueSize {
+		t.Errorf("%d pushes queued, want the queue full at %d", len(c.pushes), pushQueueSize)
+	}
+}
@@ -0,0 +1,86 @@
+package consumer
+
+import (
+	"sync"
+
+	"ride-hail/internal/ride-service/domain"
+)
+
+// trackedRide is the ride a driver is currently serving
+type trackedRide struct {
+	RideID      string
+	PassengerID string
+
+	pickup       *domain.Coordinate // loaded lazily on the first location update
+	arrivingSent bool               // driver_arriving already pushed (or no longer relevant)
+}
+
+// rideTracker maps driver IDs to their active ride so location updates
+// for drivers without a ride can be dropped before any WebSocket work
+type rideTracker struct {
+	rides map[string]trackedRide // driverID -> ride
+	mu    sync.RWMutex
+}
+
+func newRideTracker() *rideTracker {
+	return &rideTracker{rides: make(map[string]trackedRide)}
+}
+
+// Track records that driverID is serving the given ride
+func (t *rideTracker) Track(driverID, rideID, passengerID string) {
+	if driverID == "" || rideID == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if r, ok := t.rides[driverID]; ok && r.RideID == rideID {
+		// Status updates for the same ride keep the cached pickup and arrival state
+		if passengerID != "" {
+			r.PassengerID = passengerID
+		}
+		t.rides[driverID] = r
+		return
+	}
+	t.rides[driverID] = trackedRide{RideID: rideID, PassengerID: passengerID}
+}
+
+// SetPickup caches the pickup location of the driver's ride
+func (t *rideTracker) SetPickup(driverID, rideID string, pickup domain.Coordinate) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if r, ok := t.rides[driverID]; ok && r.RideID == rideID {
+		r.pickup = &pickup
+		t.rides[driverID] = r
+	}
+}
+
+// MarkArriving records that the driver_arriving push for the ride is done.
+// It returns true only for the call that flips the flag, so the push fires once.
+func (t *rideTracker) MarkArriving(driverID, rideID string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.rides[driverID]
+	if !ok || r.RideID != rideID || r.arrivingSent {
+		return false
+	}
+	r.arrivingSent = true
+	t.rides[driverID] = r
+	return true
+}
+
+// Untrack forgets the driver's ride if it is still rideID
+func (t *rideTracker) Untrack(driverID, rideID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if r, ok := t.rides[driverID]; ok && r.RideID == rideID {
+		delete(t.rides, driverID)
+	}
+}
+
+// Lookup returns the ride the driver is serving, if any
+func (t *rideTracker) Lookup(driverID string) (trackedRide, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	r, ok := t.rides[driverID]
+	return r, ok
+}
@@ -7,17 +7,21 @@ import (
 
 	"ride-hail/internal/ride-service/domain"
 	"ride-hail/pkg/logger"
-	"ride-hail/pkg/rabbitmq"
 )
 
+// broker is the part of the RabbitMQ connection the publisher uses
+type broker interface {
+	Publish(ctx context.Context, exchange, routingKey string, body []byte) error
+}
+
 // RabbitMQEventPublisher implements EventPublisher interface
 type RabbitMQEventPublisher struct {
-	rabbit *rabbitmq.Connection
+	rabbit broker
 	logger logger.Logger
 }
 
 // NewRabbitMQEventPublisher creates a new RabbitMQ event publisher
-func NewRabbitMQEventPublisher(rabbit *rabbitmq.Connection, logger logger.Logger) *RabbitMQEventPublisher {
+func NewRabbitMQEventPublisher(rabbit broker, logger logger.Logger) *RabbitMQEventPublisher {
 	return &RabbitMQEventPublisher{
 		rabbit: rabbit,
 		logger: logger,
@@ -68,20 +72,28 @@ func (p *RabbitMQEventPublisher) eventToMessage(event domain.DomainEvent) (inter
 				"longitude": e.Destination.Longitude(),
 				"address":   e.Destination.Address(),
 			},
-			"ride_type":      e.RideType.String(),
-			"estimated_fare": e.Fare,
-			"requested_at":   e.RequestedAt,
+			"ride_type":       e.RideType.String(),
+			"estimated_fare":  e.Fare,
+			"passenger_count": e.Passengers,
+			"requested_at":    e.RequestedAt,
 		}, fmt.Sprintf("ride.request.%s", e.RideType.String())
 
 	case domain.RideCancelledEvent:
+		driverID := ""
+		if e.DriverID != nil {
+			driverID = *e.DriverID
+		}
+		// Routed as a status update so the driver service frees and notifies the assigned driver
 		return map[string]interface{}{
 			"ride_id":      e.RideID,
 			"passenger_id": e.PassengerID,
-			"driver_id":    e.DriverID,
+			"driver_id":    driverID,
 			"status":       "CANCELLED",
 			"reason":       e.Reason,
+			"cancelled_by": e.CancelledBy,
 			"cancelled_at": e.CancelledAt,
-		}, fmt.Sprintf("ride.cancelled.%s", e.RideID)
+			"timestamp":    e.CancelledAt,
+		}, "ride.status.CANCELLED"
 
 	case domain.RideMatchedEvent:
 		return map[string]interface{}{
@@ -102,6 +114,37 @@ func (p *RabbitMQEventPublisher) eventToMessage(event domain.DomainEvent) (inter
 			"completed_at": e.CompletedAt,
 		}, fmt.Sprintf("ride.completed.%s", e.RideID)
 
+	case domain.RideMessageSentEvent:
+		return map[string]interface{}{
+			"message_id":   e.MessageID,
+			"ride_id":      e.RideID,
+			"sender_id":    e.SenderID,
+			"sender_role":  e.SenderRole,
+			"recipient_id": e.RecipientID,
+			"text":         e.Text,
+			"sent_at":      e.SentAt,
+		}, fmt.Sprintf("ride.message.%s", e.RideID)
+
+	case domain.RideDestinationChangedEvent:
+		return map[string]interface{}{
+			"ride_id":      e.RideID,
+			"passenger_id": e.PassengerID,
+			"driver_id":    e.DriverID,
+			"destination_location": map[string]interface{}{
+				"latitude":  e.NewDestination.Latitude(),
+				"longitude": e.NewDestination.Longitude(),
+				"address":   e.NewDestination.Address(),
+			},
+			"previous_destination_location": map[string]interface{}{
+				"latitude":  e.OldDestination.Latitude(),
+				"longitude": e.OldDestination.Longitude(),
+				"address":   e.OldDestination.Address(),
+			},
+			"estimated_fare":          e.NewFare,
+			"previous_estimated_fare": e.OldFare,
+			"changed_at":              e.ChangedAt,
+		}, fmt.Sprintf("ride.destination.%s", e.RideID)
+
 	default:
 		return nil, ""
 	}
@@ -0,0 +1,322 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	driverrabbit "ride-hail/internal/driver_location_service/adapter/rabbitmq"
+	driverapp "ride-hail/internal/driver_location_service/app"
+	driverdomain "ride-hail/internal/driver_location_service/domain"
+	"ride-hail/internal/ride-service/application"
+	"ride-hail/internal/ride-service/domain"
+	"ride-hail/pkg/logger"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type nopLogger struct{}
+
+func (l nopLogger) WithFields(logger.LogFields) logger.Logger { return l }
+func (nopLogger) Info(action, message string)                 {}
+func (nopLogger) Debug(action, message string)                {}
+func (nopLogger) Warn(action, message string)                 {}
+func (nopLogger) Error(action string, err error)              {}
+
+// bus stands in for the broker between the two services: it keeps what was
+// published and hands ride_topic messages to whoever consumes the queue bound
+// to their routing key, as the topology's bindings do
+type bus struct {
+	mu        sync.Mutex
+	published []publishedMessage
+	handlers  map[string]func(amqp.Delivery)
+}
+
+type publishedMessage struct {
+	exchange, routingKey string
+	body                 map[string]interface{}
+}
+
+func newBus() *bus {
+	return &bus{handlers: make(map[string]func(amqp.Delivery))}
+}
+
+func (b *bus) Publish(ctx context.Context, exchange, routingKey string, body []byte) error {
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	b.published = append(b.published, publishedMessage{exchange, routingKey, decoded})
+	var handler func(amqp.Delivery)
+	for prefix, queue := range rideTopicBindings {
+		if exchange == "ride_topic" && strings.HasPrefix(routingKey, prefix) {
+			handler = b.handlers[queue]
+		}
+	}
+	b.mu.Unlock()
+
+	if handler != nil {
+		handler(amqp.Delivery{Acknowledger: nopAcknowledger{}, Exchange: exchange, RoutingKey: routingKey, Body: body})
+	}
+	return nil
+}
+
+// rideTopicBindings maps routing key prefixes on ride_topic to the queue bound
+// to them; the driver service's instance queues are named after their base
+var rideTopicBindings = map[string]string{
+	"ride.status.":  "ride_status",
+	"ride.message.": "ride_messages",
+}
+
+func (b *bus) Consume(queueName string, handler func(amqp.Delivery)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[queueName] = handler
+	return nil
+}
+
+func (b *bus) DeclareInstanceQueue(base, exchange string, routingKeys ...string) (string, error) {
+	return base, nil
+}
+
+func (b *bus) Settle(queue string, d amqp.Delivery, err error) bool {
+	d.Ack(false)
+	return false
+}
+
+type nopAcknowledger struct{}
+
+func (nopAcknowledger) Ack(tag uint64, multiple bool) error                { return nil }
+func (nopAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error { return nil }
+func (nopAcknowledger) Reject(tag uint64, requeue bool) error              { return nil }
+
+// rideRepo holds one ride for the cancel use case
+type rideRepo struct {
+	domain.RideRepository
+	ride *domain.Ride
+}
+
+func (r *rideRepo) FindByPassenger(ctx context.Context, rideID, passengerID string) (*domain.Ride, error) {
+	if r.ride.ID() != rideID || r.ride.PassengerID() != passengerID {
+		return nil, domain.ErrRideNotFound
+	}
+	return r.ride, nil
+}
+
+func (r *rideRepo) FindByID(ctx context.Context, rideID string) (*domain.Ride, error) {
+	if r.ride.ID() != rideID {
+		return nil, domain.ErrRideNotFound
+	}
+	return r.ride, nil
+}
+
+func (r *rideRepo) SaveMessage(ctx context.Context, msg *domain.RideMessage) error {
+	msg.ID = "msg-1"
+	return nil
+}
+
+func (r *rideRepo) Update(ctx context.Context, ride *domain.Ride) error { return nil }
+
+func (r *rideRepo) SaveEvent(ctx context.Context, rideID string, event domain.DomainEvent) error {
+	return nil
+}
+
+// driverRepo keeps driver statuses for the driver service
+type driverRepo struct {
+	driverdomain.DriverLocationRepository
+	mu       sync.Mutex
+	statuses map[string]string
+}
+
+func (r *driverRepo) UpdateDriverStatus(ctx context.Context, driverID, status string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statuses[driverID] = status
+	return nil
+}
+
+func (r *driverRepo) ClearDriverCurrentRide(ctx context.Context, driverID string) error {
+	return r.UpdateDriverStatus(ctx, driverID, driverdomain.DriverStatusAvailable)
+}
+
+// driverSockets records the cancellations and chat messages pushed to connected drivers
+type driverSockets struct {
+	driverdomain.WebSocketManager
+	mu        sync.Mutex
+	cancelled map[string][]string // driverID -> ride IDs
+	messages  map[string][]*driverdomain.RideMessage
+}
+
+func (s *driverSockets) IsDriverConnected(driverID string) bool { return true }
+
+func (s *driverSockets) SendRideCancelled(driverID, rideID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cancelled[driverID] = append(s.cancelled[driverID], rideID)
+	return nil
+}
+
+func (s *driverSockets) SendRideMessage(driverID string, message interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.messages == nil {
+		s.messages = make(map[string][]*driverdomain.RideMessage)
+	}
+	s.messages[driverID] = append(s.messages[driverID], message.(*driverdomain.RideMessage))
+	return nil
+}
+
+// driverPublisher drops what the driver service publishes
+type driverPublisher struct{}
+
+func (driverPublisher) PublishDriverResponse(ctx context.Context, exchange, routingKey string, body []byte) error {
+	return nil
+}
+
+func (driverPublisher) PublishDriverStatus(ctx context.Context, exchange, routingKey string, body []byte) error {
+	return nil
+}
+
+func (driverPublisher) PublishLocationUpdate(ctx context.Context, exchange string, body []byte) error {
+	return nil
+}
+
+// matchedRide returns a ride already assigned to driverID
+func matchedRide(t *testing.T, driverID string) *domain.Ride {
+	t.Helper()
+	pickup, err := domain.NewCoordinate(43.238949, 76.889709, "Abay Ave 10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest, err := domain.NewCoordinate(43.222015, 76.851511, "Dostyk Ave 5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	var driver *string
+	status := domain.StatusRequested
+	if driverID != "" {
+		driver, status = &driverID, domain.StatusMatched
+	}
+	return domain.ReconstructRide("ride-1", "RIDE_20241216_001", "passenger-1", driver, status,
+		domain.RideTypeEconomy, pickup, dest, 1450, nil, now, &now, nil, nil, nil, "", "")
+}
+
+func TestRideCancelledIsPublishedAsStatusUpdate(t *testing.T) {
+	tests := []struct {
+		name     string
+		driverID string
+	}{
+		{"matched", "driver-1"},
+		{"not yet matched", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBus()
+			p := NewRabbitMQEventPublisher(b, nopLogger{})
+			ride := matchedRide(t, tt.driverID)
+			if err := ride.Cancel("changed plans", domain.CancelledByPassenger); err != nil {
+				t.Fatal(err)
+			}
+
+			err := p.Publish(context.Background(), domain.RideCancelledEvent{
+				RideID:      ride.ID(),
+				PassengerID: ride.PassengerID(),
+				DriverID:    ride.DriverID(),
+				Reason:      "changed plans",
+				CancelledBy: ride.CancelledBy(),
+				CancelledAt: *ride.CancelledAt(),
+			})
+			if err != nil {
+				t.Fatalf("Publish: %v", err)
+			}
+
+			if len(b.published) != 1 {
+				t.Fatalf("published %d messages, want 1", len(b.published))
+			}
+			m := b.published[0]
+			if m.exchange != "ride_topic" || m.routingKey != "ride.status.CANCELLED" {
+				t.Errorf("published to %s %s, want ride_topic ride.status.CANCELLED", m.exchange, m.routingKey)
+			}
+			// A plain string, never null, so the driver service can decode it
+			if m.body["driver_id"] != tt.driverID || m.body["status"] != "CANCELLED" {
+				t.Errorf("body = %v, want driver_id %q and status CANCELLED", m.body, tt.driverID)
+			}
+			if _, err := time.Parse(time.RFC3339, m.body["timestamp"].(string)); err != nil {
+				t.Errorf("timestamp %v: %v", m.body["timestamp"], err)
+			}
+		})
+	}
+}
+
+func TestPassengerCancelFreesAndNotifiesDriver(t *testing.T) {
+	ctx := context.Background()
+	b := newBus()
+
+	// Driver service, consuming ride_status from the bus
+	drivers := &driverRepo{statuses: map[string]string{"driver-1": driverdomain.DriverStatusEnRoute}}
+	sockets := &driverSockets{cancelled: make(map[string][]string)}
+	driverSvc := driverapp.NewDriverLocationService(nopLogger{}, drivers, driverPublisher{}, sockets)
+	if err := driverrabbit.NewDriverLocationConsumer(b, driverSvc, nopLogger{}).ConsumeRideStatus(ctx); err != nil {
+		t.Fatalf("ConsumeRideStatus: %v", err)
+	}
+
+	// Ride service, publishing to the bus
+	cancel := application.NewCancelRideUseCase(&rideRepo{ride: matchedRide(t, "driver-1")}, NewRabbitMQEventPublisher(b, nopLogger{}), nopLogger{})
+	if _, err := cancel.Execute(ctx, application.CancelRideCommand{RideID: "ride-1", PassengerID: "passenger-1", Reason: "changed plans"}); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+
+	if got := drivers.statuses["driver-1"]; got != driverdomain.DriverStatusAvailable {
+		t.Errorf("driver status = %s, want AVAILABLE", got)
+	}
+	if got := sockets.cancelled["driver-1"]; len(got) != 1 || got[0] != "ride-1" {
+		t.Errorf("driver socket got cancellations %v, want [ride-1]", got)
+	}
+}
+
+// passengerSockets drops what the ride service pushes to passengers
+type passengerSockets struct{}
+
+func (passengerSockets) SendToUser(userID string, message interface{}) error { return nil }
+
+func TestPassengerMessageReachesDriverSocket(t *testing.T) {
+	ctx := context.Background()
+	b := newBus()
+
+	// Driver service, consuming its instance's ride_messages queue from the bus
+	sockets := &driverSockets{}
+	driverSvc := driverapp.NewDriverLocationService(nopLogger{}, &driverRepo{}, driverPublisher{}, sockets)
+	if err := driverrabbit.NewDriverLocationConsumer(b, driverSvc, nopLogger{}).ConsumeRideMessages(ctx); err != nil {
+		t.Fatalf("ConsumeRideMessages: %v", err)
+	}
+
+	// Ride service, publishing to the bus
+	send := application.NewSendMessageUseCase(&rideRepo{ride: matchedRide(t, "driver-1")}, NewRabbitMQEventPublisher(b, nopLogger{}), passengerSockets{}, nopLogger{})
+	sent, err := send.Execute(ctx, application.SendMessageCommand{RideID: "ride-1", SenderID: "passenger-1", Text: "I'm by the blue gate"})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if !sent.Delivered {
+		t.Error("message reported undelivered")
+	}
+
+	if len(b.published) != 1 || b.published[0].routingKey != "ride.message.ride-1" {
+		t.Fatalf("published %v, want one ride.message.ride-1", b.published)
+	}
+	got := sockets.messages["driver-1"]
+	if len(got) != 1 {
+		t.Fatalf("driver socket got %d messages, want 1", len(got))
+	}
+	m := got[0]
+	if m.MessageID != "msg-1" || m.RideID != "ride-1" || m.SenderID != "passenger-1" || m.Text != "I'm by the blue gate" {
+		t.Errorf("driver got %+v, want the passenger's message", m)
+	}
+	if m.SentAt.IsZero() {
+		t.Error("sent_at lost on the way to the driver")
+	}
+}
@@ -2,40 +2,80 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"ride-hail/internal/ride-service/domain"
+	"ride-hail/pkg/apitime"
+	"ride-hail/pkg/clock"
+	"ride-hail/pkg/db"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // PostgresRideRepository implements domain.RideRepository interface
 type PostgresRideRepository struct {
-	db *pgxpool.Pool
+	db    *pgxpool.Pool
+	clock clock.Clock // Dates ride numbers
+
+	// Upper bound on each method call, 0 = only the caller's deadline applies
+	queryTimeout time.Duration
 }
 
 // NewPostgresRideRepository creates a new PostgreSQL repository
 func NewPostgresRideRepository(db *pgxpool.Pool) *PostgresRideRepository {
 	return &PostgresRideRepository{
-		db: db,
+		db:    db,
+		clock: clock.New(),
 	}
 }
 
+// SetQueryTimeout bounds every repository call, so consumers that pass a
+// context without a deadline can't hang on a stuck query; 0 disables it
+func (r *PostgresRideRepository) SetQueryTimeout(timeout time.Duration) {
+	r.queryTimeout = timeout
+}
+
+// SetClock replaces the system clock used to date ride numbers
+func (r *PostgresRideRepository) SetClock(c clock.Clock) {
+	r.clock = c
+}
+
 // Save persists a new ride
 func (r *PostgresRideRepository) Save(ctx context.Context, ride *domain.Ride) error {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
 		return fmt.Errorf("begin transaction: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
+	// Allocate the next ride number for today. The counter row stays locked until
+	// commit, so concurrent saves queue here and a rolled-back save frees its number.
+	var day time.Time
+	var seq int
+	err = tx.QueryRow(ctx, `
+		INSERT INTO ride_number_counters (day, last_value)
+		VALUES ($1::date, 1)
+		ON CONFLICT (day) DO UPDATE SET last_value = ride_number_counters.last_value + 1
+		RETURNING day, last_value
+	`, r.clock.Now().Format("2006-01-02")).Scan(&day, &seq)
+	if err != nil {
+		return fmt.Errorf("allocate ride number: %w", err)
+	}
+	ride.SetRideNumber(domain.FormatRideNumber(day, seq))
+
 	// Insert ride
 	_, err = tx.Exec(ctx, `
 		INSERT INTO rides (
 			id, ride_number, passenger_id, status, vehicle_type,
-			estimated_fare, requested_at, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+			estimated_fare, requested_at, passenger_count, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
 	`,
 		ride.ID(),
 		ride.RideNumber(),
@@ -44,6 +84,7 @@ func (r *PostgresRideRepository) Save(ctx context.Context, ride *domain.Ride) er
 		ride.RideTypeValue().String(),
 		ride.EstimatedFare(),
 		ride.RequestedAt(),
+		ride.PassengerCount(),
 	)
 	if err != nil {
 		return fmt.Errorf("insert ride: %w", err)
@@ -104,6 +145,9 @@ func (r *PostgresRideRepository) Save(ctx context.Context, ride *domain.Ride) er
 
 // Update updates an existing ride
 func (r *PostgresRideRepository) Update(ctx context.Context, ride *domain.Ride) error {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	_, err := r.db.Exec(ctx, `
 		UPDATE rides
 		SET
@@ -115,8 +159,9 @@ func (r *PostgresRideRepository) Update(ctx context.Context, ride *domain.Ride)
 			completed_at = $6,
 			cancelled_at = $7,
 			cancellation_reason = $8,
+			cancelled_by = NULLIF($9, ''),
 			updated_at = NOW()
-		WHERE id = $9
+		WHERE id = $10
 	`,
 		ride.Status().String(),
 		ride.DriverID(),
@@ -126,6 +171,7 @@ func (r *PostgresRideRepository) Update(ctx context.Context, ride *domain.Ride)
 		ride.CompletedAt(),
 		ride.CancelledAt(),
 		ride.CancelReason(),
+		ride.CancelledBy(),
 		ride.ID(),
 	)
 	if err != nil {
@@ -135,8 +181,58 @@ func (r *PostgresRideRepository) Update(ctx context.Context, ride *domain.Ride)
 	return nil
 }
 
+// UpdateDestination points the ride at a newly inserted destination coordinate
+// and stores the recalculated estimate. The status check guards against the
+// ride completing or being cancelled since it was loaded.
+func (r *PostgresRideRepository) UpdateDestination(ctx context.Context, ride *domain.Ride) error {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var destCoordID string
+	dest := ride.DestLocation()
+	err = tx.QueryRow(ctx, `
+		INSERT INTO coordinates (
+			entity_id, entity_type, address, latitude, longitude, is_current
+		) VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`,
+		ride.PassengerID(),
+		"passenger",
+		dest.Address(),
+		dest.Latitude(),
+		dest.Longitude(),
+		false,
+	).Scan(&destCoordID)
+	if err != nil {
+		return fmt.Errorf("insert destination coordinate: %w", err)
+	}
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE rides
+		SET destination_coordinate_id = $1, estimated_fare = $2, updated_at = NOW()
+		WHERE id = $3 AND status = 'IN_PROGRESS'
+	`, destCoordID, ride.EstimatedFare(), ride.ID())
+	if err != nil {
+		return fmt.Errorf("update ride destination: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrCannotChangeDestination
+	}
+
+	return tx.Commit(ctx)
+}
+
 // FindByID retrieves a ride by its ID
 func (r *PostgresRideRepository) FindByID(ctx context.Context, rideID string) (*domain.Ride, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	var (
 		id            string
 		rideNumber    string
@@ -152,21 +248,24 @@ func (r *PostgresRideRepository) FindByID(ctx context.Context, rideID string) (*
 		completedAt   *interface{}
 		cancelledAt   *interface{}
 		cancelReason  string
+		cancelledBy   string
 		pickupLat     float64
 		pickupLng     float64
 		pickupAddr    string
 		destLat       float64
 		destLng       float64
 		destAddr      string
+		passengers    int
 	)
 
 	err := r.db.QueryRow(ctx, `
 		SELECT
 			r.id, r.ride_number, r.passenger_id, r.driver_id, r.status, r.vehicle_type,
 			r.estimated_fare, r.final_fare, r.requested_at, r.matched_at, r.started_at,
-			r.completed_at, r.cancelled_at, COALESCE(r.cancellation_reason, ''),
+			r.completed_at, r.cancelled_at, COALESCE(r.cancellation_reason, ''), COALESCE(r.cancelled_by, ''),
 			COALESCE(cp.latitude, 0), COALESCE(cp.longitude, 0), COALESCE(cp.address, ''),
-			COALESCE(cd.latitude, 0), COALESCE(cd.longitude, 0), COALESCE(cd.address, '')
+			COALESCE(cd.latitude, 0), COALESCE(cd.longitude, 0), COALESCE(cd.address, ''),
+			r.passenger_count
 		FROM rides r
 		LEFT JOIN coordinates cp ON r.pickup_coordinate_id = cp.id
 		LEFT JOIN coordinates cd ON r.destination_coordinate_id = cd.id
@@ -174,10 +273,13 @@ func (r *PostgresRideRepository) FindByID(ctx context.Context, rideID string) (*
 	`, rideID).Scan(
 		&id, &rideNumber, &passengerID, &driverID, &status, &rideType,
 		&estimatedFare, &finalFare, &requestedAt, &matchedAt, &startedAt,
-		&completedAt, &cancelledAt, &cancelReason,
+		&completedAt, &cancelledAt, &cancelReason, &cancelledBy,
 		&pickupLat, &pickupLng, &pickupAddr,
-		&destLat, &destLng, &destAddr,
+		&destLat, &destLng, &destAddr, &passengers,
 	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrRideNotFound
+	}
 	if err != nil {
 		return nil, fmt.Errorf("query ride: %w", err)
 	}
@@ -185,14 +287,17 @@ func (r *PostgresRideRepository) FindByID(ctx context.Context, rideID string) (*
 	return reconstructRide(
 		id, rideNumber, passengerID, driverID, status, rideType,
 		estimatedFare, finalFare, requestedAt, matchedAt, startedAt,
-		completedAt, cancelledAt, cancelReason,
+		completedAt, cancelledAt, cancelReason, cancelledBy,
 		pickupLat, pickupLng, pickupAddr,
-		destLat, destLng, destAddr,
+		destLat, destLng, destAddr, passengers,
 	)
 }
 
 // FindByPassenger retrieves a ride by ID and verifies passenger ownership
 func (r *PostgresRideRepository) FindByPassenger(ctx context.Context, rideID string, passengerID string) (*domain.Ride, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	var (
 		id            string
 		rideNumber    string
@@ -208,21 +313,24 @@ func (r *PostgresRideRepository) FindByPassenger(ctx context.Context, rideID str
 		completedAt   *interface{}
 		cancelledAt   *interface{}
 		cancelReason  string
+		cancelledBy   string
 		pickupLat     float64
 		pickupLng     float64
 		pickupAddr    string
 		destLat       float64
 		destLng       float64
 		destAddr      string
+		passengers    int
 	)
 
 	err := r.db.QueryRow(ctx, `
 		SELECT
 			r.id, r.ride_number, r.passenger_id, r.driver_id, r.status, r.vehicle_type,
 			r.estimated_fare, r.final_fare, r.requested_at, r.matched_at, r.started_at,
-			r.completed_at, r.cancelled_at, COALESCE(r.cancellation_reason, ''),
+			r.completed_at, r.cancelled_at, COALESCE(r.cancellation_reason, ''), COALESCE(r.cancelled_by, ''),
 			COALESCE(cp.latitude, 0), COALESCE(cp.longitude, 0), COALESCE(cp.address, ''),
-			COALESCE(cd.latitude, 0), COALESCE(cd.longitude, 0), COALESCE(cd.address, '')
+			COALESCE(cd.latitude, 0), COALESCE(cd.longitude, 0), COALESCE(cd.address, ''),
+			r.passenger_count
 		FROM rides r
 		LEFT JOIN coordinates cp ON r.pickup_coordinate_id = cp.id
 		LEFT JOIN coordinates cd ON r.destination_coordinate_id = cd.id
@@ -230,10 +338,13 @@ func (r *PostgresRideRepository) FindByPassenger(ctx context.Context, rideID str
 	`, rideID, passengerID).Scan(
 		&id, &rideNumber, &pID, &driverID, &status, &rideType,
 		&estimatedFare, &finalFare, &requestedAt, &matchedAt, &startedAt,
-		&completedAt, &cancelledAt, &cancelReason,
+		&completedAt, &cancelledAt, &cancelReason, &cancelledBy,
 		&pickupLat, &pickupLng, &pickupAddr,
-		&destLat, &destLng, &destAddr,
+		&destLat, &destLng, &destAddr, &passengers,
 	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrRideNotFound
+	}
 	if err != nil {
 		return nil, fmt.Errorf("query ride: %w", err)
 	}
@@ -241,21 +352,25 @@ func (r *PostgresRideRepository) FindByPassenger(ctx context.Context, rideID str
 	return reconstructRide(
 		id, rideNumber, pID, driverID, status, rideType,
 		estimatedFare, finalFare, requestedAt, matchedAt, startedAt,
-		completedAt, cancelledAt, cancelReason,
+		completedAt, cancelledAt, cancelReason, cancelledBy,
 		pickupLat, pickupLng, pickupAddr,
-		destLat, destLng, destAddr,
+		destLat, destLng, destAddr, passengers,
 	)
 }
 
 // FindActiveByPassenger retrieves active rides for a passenger
 func (r *PostgresRideRepository) FindActiveByPassenger(ctx context.Context, passengerID string) ([]*domain.Ride, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	rows, err := r.db.Query(ctx, `
 		SELECT
 			r.id, r.ride_number, r.passenger_id, r.driver_id, r.status, r.vehicle_type,
 			r.estimated_fare, r.final_fare, r.requested_at, r.matched_at, r.started_at,
-			r.completed_at, r.cancelled_at, COALESCE(r.cancellation_reason, ''),
+			r.completed_at, r.cancelled_at, COALESCE(r.cancellation_reason, ''), COALESCE(r.cancelled_by, ''),
 			COALESCE(cp.latitude, 0), COALESCE(cp.longitude, 0), COALESCE(cp.address, ''),
-			COALESCE(cd.latitude, 0), COALESCE(cd.longitude, 0), COALESCE(cd.address, '')
+			COALESCE(cd.latitude, 0), COALESCE(cd.longitude, 0), COALESCE(cd.address, ''),
+			r.passenger_count
 		FROM rides r
 		LEFT JOIN coordinates cp ON r.pickup_coordinate_id = cp.id
 		LEFT JOIN coordinates cd ON r.destination_coordinate_id = cd.id
@@ -267,6 +382,40 @@ func (r *PostgresRideRepository) FindActiveByPassenger(ctx context.Context, pass
 	}
 	defer rows.Close()
 
+	return scanRides(rows)
+}
+
+// FindByStatus retrieves rides in status, oldest request first. A positive
+// olderThan keeps only rides requested at least that long ago.
+func (r *PostgresRideRepository) FindByStatus(ctx context.Context, status domain.RideStatus, olderThan time.Duration) ([]*domain.Ride, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.db.Query(ctx, `
+		SELECT
+			r.id, r.ride_number, r.passenger_id, r.driver_id, r.status, r.vehicle_type,
+			r.estimated_fare, r.final_fare, r.requested_at, r.matched_at, r.started_at,
+			r.completed_at, r.cancelled_at, COALESCE(r.cancellation_reason, ''), COALESCE(r.cancelled_by, ''),
+			COALESCE(cp.latitude, 0), COALESCE(cp.longitude, 0), COALESCE(cp.address, ''),
+			COALESCE(cd.latitude, 0), COALESCE(cd.longitude, 0), COALESCE(cd.address, ''),
+			r.passenger_count
+		FROM rides r
+		LEFT JOIN coordinates cp ON r.pickup_coordinate_id = cp.id
+		LEFT JOIN coordinates cd ON r.destination_coordinate_id = cd.id
+		WHERE r.status = $1
+			AND ($2::float8 <= 0 OR r.requested_at <= NOW() - make_interval(secs => $2::float8))
+		ORDER BY r.requested_at
+	`, status.String(), olderThan.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("query rides by status: %w", err)
+	}
+	defer rows.Close()
+
+	return scanRides(rows)
+}
+
+// scanRides reads the rows of a ride query selecting the columns FindActiveByPassenger does
+func scanRides(rows pgx.Rows) ([]*domain.Ride, error) {
 	var rides []*domain.Ride
 	for rows.Next() {
 		var (
@@ -284,20 +433,22 @@ func (r *PostgresRideRepository) FindActiveByPassenger(ctx context.Context, pass
 			completedAt   *interface{}
 			cancelledAt   *interface{}
 			cancelReason  string
+			cancelledBy   string
 			pickupLat     float64
 			pickupLng     float64
 			pickupAddr    string
 			destLat       float64
 			destLng       float64
 			destAddr      string
+			passengers    int
 		)
 
 		err := rows.Scan(
 			&id, &rideNumber, &pID, &driverID, &status, &rideType,
 			&estimatedFare, &finalFare, &requestedAt, &matchedAt, &startedAt,
-			&completedAt, &cancelledAt, &cancelReason,
+			&completedAt, &cancelledAt, &cancelReason, &cancelledBy,
 			&pickupLat, &pickupLng, &pickupAddr,
-			&destLat, &destLng, &destAddr,
+			&destLat, &destLng, &destAddr, &passengers,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("scan ride: %w", err)
@@ -306,9 +457,9 @@ func (r *PostgresRideRepository) FindActiveByPassenger(ctx context.Context, pass
 		ride, err := reconstructRide(
 			id, rideNumber, pID, driverID, status, rideType,
 			estimatedFare, finalFare, requestedAt, matchedAt, startedAt,
-			completedAt, cancelledAt, cancelReason,
+			completedAt, cancelledAt, cancelReason, cancelledBy,
 			pickupLat, pickupLng, pickupAddr,
-			destLat, destLng, destAddr,
+			destLat, destLng, destAddr, passengers,
 		)
 		if err != nil {
 			return nil, err
@@ -317,18 +468,14 @@ func (r *PostgresRideRepository) FindActiveByPassenger(ctx context.Context, pass
 		rides = append(rides, ride)
 	}
 
-	return rides, nil
-}
-
-// FindByStatus retrieves rides by status
-func (r *PostgresRideRepository) FindByStatus(ctx context.Context, status domain.RideStatus) ([]*domain.Ride, error) {
-	// Implementation similar to FindActiveByPassenger
-	// Left as exercise or can be implemented later
-	return nil, fmt.Errorf("not implemented")
+	return rides, rows.Err()
 }
 
 // Delete removes a ride (soft delete recommended in production)
 func (r *PostgresRideRepository) Delete(ctx context.Context, rideID string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	_, err := r.db.Exec(ctx, `DELETE FROM rides WHERE id = $1`, rideID)
 	if err != nil {
 		return fmt.Errorf("delete ride: %w", err)
@@ -336,6 +483,52 @@ func (r *PostgresRideRepository) Delete(ctx context.Context, rideID string) erro
 	return nil
 }
 
+// FindRoute retrieves the driver positions recorded during a ride, oldest first
+func (r *PostgresRideRepository) FindRoute(ctx context.Context, rideID string) ([]domain.RoutePoint, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.db.Query(ctx, `
+		SELECT latitude, longitude, recorded_at
+		FROM location_history
+		WHERE ride_id = $1
+		ORDER BY recorded_at, id
+	`, rideID)
+	if err != nil {
+		return nil, fmt.Errorf("query route: %w", err)
+	}
+	defer rows.Close()
+
+	points := make([]domain.RoutePoint, 0)
+	for rows.Next() {
+		var p domain.RoutePoint
+		if err := rows.Scan(&p.Latitude, &p.Longitude, &p.RecordedAt); err != nil {
+			return nil, fmt.Errorf("scan route point: %w", err)
+		}
+		points = append(points, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate route: %w", err)
+	}
+	return points, nil
+}
+
+// SaveMessage persists a chat message, filling in its ID and SentAt
+func (r *PostgresRideRepository) SaveMessage(ctx context.Context, msg *domain.RideMessage) error {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO ride_messages (ride_id, sender_id, sender_role, recipient_id, body)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, msg.RideID, msg.SenderID, msg.SenderRole, msg.RecipientID, msg.Text).Scan(&msg.ID, &msg.SentAt)
+	if err != nil {
+		return fmt.Errorf("insert ride message: %w", err)
+	}
+	return nil
+}
+
 // Helper function to reconstruct ride from database row
 func reconstructRide(
 	id, rideNumber, passengerID string,
@@ -344,9 +537,10 @@ func reconstructRide(
 	estimatedFare float64,
 	finalFare *float64,
 	requestedAt, matchedAt, startedAt, completedAt, cancelledAt interface{},
-	cancelReason string,
+	cancelReason, cancelledBy string,
 	pickupLat, pickupLng float64, pickupAddr string,
 	destLat, destLng float64, destAddr string,
+	passengers int,
 ) (*domain.Ride, error) {
 	// Reconstruct coordinates
 	pickup, _ := domain.NewCoordinate(pickupLat, pickupLng, pickupAddr)
@@ -359,7 +553,7 @@ func reconstructRide(
 	completeAt := parseTimeFromInterface(completedAt)
 	cancelAt := parseTimeFromInterface(cancelledAt)
 
-	return domain.ReconstructRide(
+	ride := domain.ReconstructRide(
 		id,
 		rideNumber,
 		passengerID,
@@ -376,7 +570,12 @@ func reconstructRide(
 		completeAt,
 		cancelAt,
 		cancelReason,
-	), nil
+		cancelledBy,
+	)
+	if err := ride.SetPassengerCount(passengers); err != nil {
+		return nil, fmt.Errorf("ride %s: %w", id, err)
+	}
+	return ride, nil
 }
 
 // Helper functions for time parsing
@@ -404,35 +603,173 @@ func parseTimeFromInterface(t interface{}) *time.Time {
 // Consumer-friendly methods (for backward compatibility)
 // ============================================
 
-// UpdateRideStatus updates only the ride status (used by consumers)
+// UpdateRideStatus updates only the ride status (used by consumers); an unknown
+// ride is ErrRideNotFound
 func (r *PostgresRideRepository) UpdateRideStatus(ctx context.Context, rideID string, status string) error {
-	_, err := r.db.Exec(ctx, `
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	tag, err := r.db.Exec(ctx, `
 		UPDATE rides
-		SET status = $1, updated_at = NOW()
+		SET status = $1,
+			arrived_at = CASE WHEN $1 = 'ARRIVED' THEN NOW() ELSE arrived_at END,
+			updated_at = NOW()
 		WHERE id = $2
 	`, status, rideID)
 	if err != nil {
 		return fmt.Errorf("update ride status: %w", err)
 	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrRideNotFound
+	}
 	return nil
 }
 
-// AssignDriver assigns a driver to a ride (used by consumers)
+// CancelRide cancels an active ride with a reason on behalf of cancelledBy (used
+// by consumers). A non-zero fee (e.g. for a passenger no-show) is charged as the
+// ride's final fare.
+func (r *PostgresRideRepository) CancelRide(ctx context.Context, rideID, reason, cancelledBy string, fee float64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	_, err := r.db.Exec(ctx, `
+		UPDATE rides
+		SET status = 'CANCELLED', cancelled_at = NOW(), cancellation_reason = $1,
+			cancelled_by = $4,
+			final_fare = CASE WHEN $3::numeric > 0 THEN $3::numeric ELSE final_fare END,
+			updated_at = NOW()
+		WHERE id = $2 AND status NOT IN ('COMPLETED', 'CANCELLED')
+	`, reason, rideID, fee, cancelledBy)
+	if err != nil {
+		return fmt.Errorf("cancel ride: %w", err)
+	}
+	return nil
+}
+
+// UnmatchedRide identifies a ride cancelled for waiting too long for a driver
+type UnmatchedRide struct {
+	RideID      string
+	PassengerID string
+}
+
+// CancelUnmatchedRides cancels rides that have been waiting in REQUESTED for longer
+// than maxWait. The wait is measured from the last status change, so a ride sent
+// back to matching gets a fresh window.
+func (r *PostgresRideRepository) CancelUnmatchedRides(ctx context.Context, maxWait time.Duration, reason string) ([]UnmatchedRide, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.db.Query(ctx, `
+		UPDATE rides
+		SET status = 'CANCELLED', cancelled_at = NOW(), cancellation_reason = $1,
+			cancelled_by = $3, updated_at = NOW()
+		WHERE status = 'REQUESTED' AND updated_at < NOW() - make_interval(secs => $2)
+		RETURNING id, passenger_id
+	`, reason, maxWait.Seconds(), domain.CancelledBySystem)
+	if err != nil {
+		return nil, fmt.Errorf("cancel unmatched rides: %w", err)
+	}
+	defer rows.Close()
+
+	var rides []UnmatchedRide
+	for rows.Next() {
+		var ride UnmatchedRide
+		if err := rows.Scan(&ride.RideID, &ride.PassengerID); err != nil {
+			return nil, fmt.Errorf("scan unmatched ride: %w", err)
+		}
+		rides = append(rides, ride)
+	}
+	return rides, rows.Err()
+}
+
+// ExpireRide cancels a ride on behalf of the system with reason if it is still
+// REQUESTED, and reports whether it was. A ride matched or cancelled since it
+// was found is left alone.
+func (r *PostgresRideRepository) ExpireRide(ctx context.Context, rideID, reason string) (bool, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	tag, err := r.db.Exec(ctx, `
+		UPDATE rides
+		SET status = 'CANCELLED', cancelled_at = NOW(), cancellation_reason = $2,
+			cancelled_by = $3, updated_at = NOW()
+		WHERE id = $1 AND status = 'REQUESTED'
+	`, rideID, reason, domain.CancelledBySystem)
+	if err != nil {
+		return false, fmt.Errorf("expire ride: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// AssignDriver assigns a driver to a ride (used by consumers). A REQUESTED ride
+// becomes MATCHED; a ride the driver already reported en route keeps that status.
 func (r *PostgresRideRepository) AssignDriver(ctx context.Context, rideID string, driverID string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	now := time.Now()
-	_, err := r.db.Exec(ctx, `
+	tag, err := r.db.Exec(ctx, `
 		UPDATE rides
-		SET driver_id = $1, matched_at = $2, updated_at = NOW()
+		SET driver_id = $1, matched_at = $2, updated_at = NOW(),
+			status = CASE WHEN status = 'REQUESTED' THEN 'MATCHED' ELSE status END
 		WHERE id = $3
 	`, driverID, now, rideID)
 	if err != nil {
 		return fmt.Errorf("assign driver: %w", err)
 	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrRideNotFound
+	}
 	return nil
 }
 
+// ExpiredAssignment identifies a ride taken back from a driver who never set off
+type ExpiredAssignment struct {
+	RideID      string
+	DriverID    string
+	PassengerID string
+}
+
+// ExpireAssignments sends rides that have been MATCHED for longer than timeout
+// back to REQUESTED and returns them with the driver they were taken from. A ride
+// the driver reported en route, started or cancelled is left alone; rides locked
+// by another instance's sweep are skipped.
+func (r *PostgresRideRepository) ExpireAssignments(ctx context.Context, timeout time.Duration) ([]ExpiredAssignment, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.db.Query(ctx, `
+		UPDATE rides r
+		SET status = 'REQUESTED', driver_id = NULL, matched_at = NULL, updated_at = NOW()
+		FROM (
+			SELECT id, driver_id FROM rides
+			WHERE status = 'MATCHED' AND matched_at < NOW() - make_interval(secs => $1)
+			FOR UPDATE SKIP LOCKED
+		) old
+		WHERE r.id = old.id
+		RETURNING r.id, old.driver_id, r.passenger_id
+	`, timeout.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("expire assignments: %w", err)
+	}
+	defer rows.Close()
+
+	var expired []ExpiredAssignment
+	for rows.Next() {
+		var a ExpiredAssignment
+		if err := rows.Scan(&a.RideID, &a.DriverID, &a.PassengerID); err != nil {
+			return nil, fmt.Errorf("scan expired assignment: %w", err)
+		}
+		expired = append(expired, a)
+	}
+	return expired, rows.Err()
+}
+
 // SaveEvent saves a domain event to the ride_events table
 func (r *PostgresRideRepository) SaveEvent(ctx context.Context, rideID string, event domain.DomainEvent) error {
+	ctx, cancel := db.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	// Map domain event type to database event type
 	eventType := mapEventType(event.EventType())
 
@@ -449,20 +786,6 @@ func (r *PostgresRideRepository) SaveEvent(ctx context.Context, rideID string, e
 	return nil
 }
 
-// GetTodayRideCount returns count of rides created today (for ride number generation)
-func (r *PostgresRideRepository) GetTodayRideCount(ctx context.Context) (int, error) {
-	var count int
-	err := r.db.QueryRow(ctx, `
-		SELECT COUNT(*)
-		FROM rides
-		WHERE DATE(requested_at) = CURRENT_DATE
-	`).Scan(&count)
-	if err != nil {
-		return 0, fmt.Errorf("get today ride count: %w", err)
-	}
-	return count, nil
-}
-
 // mapEventType maps domain event type to database enum value
 func mapEventType(eventType string) string {
 	switch eventType {
@@ -474,8 +797,10 @@ func mapEventType(eventType string) string {
 		return "RIDE_CANCELLED"
 	case "ride.completed":
 		return "RIDE_COMPLETED"
-	case "ride.status.changed":
+	case "ride.status.changed", "ride.assignment_expired":
 		return "STATUS_CHANGED"
+	case "ride.destination.changed":
+		return "DESTINATION_CHANGED"
 	default:
 		return "STATUS_CHANGED"
 	}
@@ -497,15 +822,52 @@ func buildEventData(event domain.DomainEvent) string {
 		if e.DriverID != nil {
 			driverID = *e.DriverID
 		}
-		return fmt.Sprintf(`{"passenger_id": "%s", "driver_id": "%s", "reason": "%s"}`,
-			e.PassengerID, driverID, e.Reason)
+		return marshalEventData(map[string]interface{}{
+			"passenger_id": e.PassengerID,
+			"driver_id":    driverID,
+			"reason":       e.Reason,
+			"cancelled_by": e.CancelledBy,
+			"cancelled_at": apitime.Format(e.CancelledAt),
+			"fee":          e.Fee,
+		})
 	case domain.RideCompletedEvent:
 		return fmt.Sprintf(`{"passenger_id": "%s", "driver_id": "%s", "final_fare": %.2f}`,
 			e.PassengerID, e.DriverID, e.FinalFare)
 	case domain.RideStatusChangedEvent:
 		return fmt.Sprintf(`{"old_status": "%s", "new_status": "%s"}`,
 			e.OldStatus.String(), e.NewStatus.String())
+	case domain.DriverAssignmentExpiredEvent:
+		return fmt.Sprintf(`{"action": "assignment_expired", "driver_id": "%s", "old_status": "MATCHED", "new_status": "REQUESTED", "timeout_seconds": %d}`,
+			e.DriverID, int(e.Timeout.Seconds()))
+	case domain.RideDestinationChangedEvent:
+		return marshalEventData(map[string]interface{}{
+			"passenger_id":       e.PassengerID,
+			"driver_id":          e.DriverID,
+			"old_destination":    eventLocation(e.OldDestination),
+			"new_destination":    eventLocation(e.NewDestination),
+			"old_estimated_fare": e.OldFare,
+			"new_estimated_fare": e.NewFare,
+		})
 	default:
 		return `{}`
 	}
 }
+
+// marshalEventData encodes event data with json.Marshal, which escapes
+// user-entered text such as addresses and cancellation reasons
+func marshalEventData(data map[string]interface{}) string {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return `{}`
+	}
+	return string(b)
+}
+
+// eventLocation is a coordinate in the lat/lng shape of the other event payloads
+func eventLocation(c domain.Coordinate) map[string]interface{} {
+	return map[string]interface{}{
+		"lat":     c.Latitude(),
+		"lng":     c.Longitude(),
+		"address": c.Address(),
+	}
+}
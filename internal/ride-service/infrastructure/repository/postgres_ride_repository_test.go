@@ -0,0 +1,437 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"ride-hail/internal/ride-service/domain"
+	"ride-hail/pkg/clock"
+	"ride-hail/pkg/dbtest"
+)
+
+// newTestRepo returns a repository over a fresh test database, dated by clk
+func newTestRepo(t *testing.T, clk clock.Clock) *PostgresRideRepository {
+	t.Helper()
+	repo := NewPostgresRideRepository(dbtest.Pool(t))
+	repo.SetClock(clk)
+	return repo
+}
+
+// seedPassenger adds a passenger and returns its ID
+func seedPassenger(t *testing.T, repo *PostgresRideRepository) string {
+	t.Helper()
+	var id string
+	err := repo.db.QueryRow(context.Background(), `
+		INSERT INTO users (email, role, password_hash) VALUES ('passenger@repo.test', 'PASSENGER', 'x') RETURNING id
+	`).Scan(&id)
+	if err != nil {
+		t.Fatalf("seed passenger: %v", err)
+	}
+	return id
+}
+
+// newTestRide returns an unsaved economy ride for passengerID with a fresh ID
+func newTestRide(t *testing.T, repo *PostgresRideRepository, passengerID string) *domain.Ride {
+	t.Helper()
+	pickup, _ := domain.NewCoordinate(43.2389, 76.8897, "Abay Ave 10")
+	dest, _ := domain.NewCoordinate(43.2220, 76.8512, "Dostyk Ave 5")
+	ride, err := domain.NewRide(passengerID, pickup, dest, domain.RideTypeEconomy, 1450)
+	if err != nil {
+		t.Fatalf("NewRide: %v", err)
+	}
+	var id string
+	if err := repo.db.QueryRow(context.Background(), `SELECT gen_random_uuid()`).Scan(&id); err != nil {
+		t.Fatalf("ride id: %v", err)
+	}
+	ride.SetID(id)
+	return ride
+}
+
+func TestConcurrentSavesGetUniqueGaplessRideNumbers(t *testing.T) {
+	day := time.Date(2024, 12, 16, 10, 0, 0, 0, time.UTC)
+	repo := newTestRepo(t, clock.NewFake(day))
+	passengerID := seedPassenger(t, repo)
+
+	const rides = 25
+	numbers := make([]string, rides)
+	var wg sync.WaitGroup
+	for i := range numbers {
+		ride := newTestRide(t, repo, passengerID)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := repo.Save(context.Background(), ride); err != nil {
+				t.Errorf("Save: %v", err)
+				return
+			}
+			numbers[i] = ride.RideNumber()
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for _, n := range numbers {
+		if seen[n] {
+			t.Errorf("ride number %s handed out twice", n)
+		}
+		seen[n] = true
+	}
+	for i := 1; i <= rides; i++ {
+		if want := fmt.Sprintf("RIDE_20241216_%03d", i); !seen[want] {
+			t.Errorf("%s never handed out, want numbers 001 to %03d without gaps", want, rides)
+		}
+	}
+}
+
+func TestRideNumbersRestartEachDay(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, 12, 16, 23, 59, 0, 0, time.UTC))
+	repo := newTestRepo(t, clk)
+	passengerID := seedPassenger(t, repo)
+	ctx := context.Background()
+
+	for _, want := range []string{"RIDE_20241216_001", "RIDE_20241216_002"} {
+		ride := newTestRide(t, repo, passengerID)
+		if err := repo.Save(ctx, ride); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		if ride.RideNumber() != want {
+			t.Errorf("ride number %s, want %s", ride.RideNumber(), want)
+		}
+	}
+
+	clk.Advance(2 * time.Minute)
+	ride := newTestRide(t, repo, passengerID)
+	if err := repo.Save(ctx, ride); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if want := "RIDE_20241217_001"; ride.RideNumber() != want {
+		t.Errorf("first ride of the next day numbered %s, want %s", ride.RideNumber(), want)
+	}
+}
+
+func TestFindRouteReturnsPointsOldestFirst(t *testing.T) {
+	repo := newTestRepo(t, clock.New())
+	passengerID := seedPassenger(t, repo)
+	ctx := context.Background()
+	ride, other := newTestRide(t, repo, passengerID), newTestRide(t, repo, passengerID)
+	for _, r := range []*domain.Ride{ride, other} {
+		if err := repo.Save(ctx, r); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+
+	start := time.Date(2024, 12, 16, 10, 0, 0, 0, time.UTC)
+	// Inserted out of order; the other ride's point falls between them
+	points := []struct {
+		rideID   string
+		lat      float64
+		afterMin int
+	}{
+		{ride.ID(), 43.2220, 2},
+		{ride.ID(), 43.2389, 0},
+		{other.ID(), 43.1000, 1},
+		{ride.ID(), 43.2300, 1},
+	}
+	for _, p := range points {
+		_, err := repo.db.Exec(ctx, `
+			INSERT INTO location_history (latitude, longitude, recorded_at, ride_id) VALUES ($1, 76.88, $2, $3)
+		`, p.lat, start.Add(time.Duration(p.afterMin)*time.Minute), p.rideID)
+		if err != nil {
+			t.Fatalf("seed point: %v", err)
+		}
+	}
+
+	route, err := repo.FindRoute(ctx, ride.ID())
+	if err != nil {
+		t.Fatalf("FindRoute: %v", err)
+	}
+	var got []string
+	for _, p := range route {
+		got = append(got, fmt.Sprintf("%.4f@%s", p.Latitude, p.RecordedAt.UTC().Format("15:04")))
+	}
+	if want := "[43.2389@10:00 43.2300@10:01 43.2220@10:02]"; fmt.Sprint(got) != want {
+		t.Errorf("route = %v, want %s", got, want)
+	}
+}
+
+func TestFindRouteWithoutPointsIsEmpty(t *testing.T) {
+	repo := newTestRepo(t, clock.New())
+	ride := newTestRide(t, repo, seedPassenger(t, repo))
+	if err := repo.Save(context.Background(), ride); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	route, err := repo.FindRoute(context.Background(), ride.ID())
+	if err != nil {
+		t.Fatalf("FindRoute: %v", err)
+	}
+	if route == nil || len(route) != 0 {
+		t.Errorf("route = %#v, want an empty slice", route)
+	}
+}
+
+func TestSlowQueryIsCancelledByQueryTimeout(t *testing.T) {
+	repo := newTestRepo(t, clock.New())
+	ctx := context.Background()
+	ride := newTestRide(t, repo, seedPassenger(t, repo))
+	if err := repo.Save(ctx, ride); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	// Another transaction holds the ride's row, so the update waits on it
+	tx, err := repo.db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `SELECT id FROM rides WHERE id = $1 FOR UPDATE`, ride.ID()); err != nil {
+		t.Fatalf("lock ride: %v", err)
+	}
+
+	repo.SetQueryTimeout(100 * time.Millisecond)
+	start := time.Now()
+	err = repo.UpdateRideStatus(ctx, ride.ID(), "CANCELLED")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want the query timeout to cancel the update", err)
+	}
+	if took := time.Since(start); took > 5*time.Second {
+		t.Errorf("update gave up after %v, want about the 100ms timeout", took)
+	}
+}
+
+func TestCancelUnmatchedRidesExpiresOnlyOldRequests(t *testing.T) {
+	repo := newTestRepo(t, clock.New())
+	ctx := context.Background()
+	passengerID := seedPassenger(t, repo)
+
+	save := func(status string, age time.Duration) string {
+		t.Helper()
+		ride := newTestRide(t, repo, passengerID)
+		if err := repo.Save(ctx, ride); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		_, err := repo.db.Exec(ctx, `
+			UPDATE rides SET status = $2, updated_at = NOW() - make_interval(secs => $3) WHERE id = $1
+		`, ride.ID(), status, age.Seconds())
+		if err != nil {
+			t.Fatalf("age ride: %v", err)
+		}
+		return ride.ID()
+	}
+	old := save("REQUESTED", 10*time.Minute)
+	fresh := save("REQUESTED", time.Minute)
+	matched := save("MATCHED", 10*time.Minute)
+
+	cancelled, err := repo.CancelUnmatchedRides(ctx, 5*time.Minute, domain.ReasonNoDriverFound)
+	if err != nil {
+		t.Fatalf("CancelUnmatchedRides: %v", err)
+	}
+	if len(cancelled) != 1 || cancelled[0].RideID != old || cancelled[0].PassengerID != passengerID {
+		t.Errorf("cancelled %+v, want only the old request", cancelled)
+	}
+
+	for id, want := range map[string]string{old: "CANCELLED", fresh: "REQUESTED", matched: "MATCHED"} {
+		var status string
+		var reason, by *string
+		err := repo.db.QueryRow(ctx, `
+			SELECT status, cancellation_reason, cancelled_by FROM rides WHERE id = $1
+		`, id).Scan(&status, &reason, &by)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != want {
+			t.Errorf("ride %s = %s, want %s", id, status, want)
+		}
+		if id == old && (reason == nil || *reason != domain.ReasonNoDriverFound || by == nil || *by != domain.CancelledBySystem) {
+			t.Errorf("expired ride reason %v by %v, want NO_DRIVER_FOUND by the system", reason, by)
+		}
+	}
+
+	// A second sweep finds nothing left to expire
+	if again, err := repo.CancelUnmatchedRides(ctx, 5*time.Minute, domain.ReasonNoDriverFound); err != nil || len(again) != 0 {
+		t.Errorf("second sweep = %+v, %v; want nothing", again, err)
+	}
+}
+
+func TestFindByStatusFiltersByRequestAge(t *testing.T) {
+	repo := newTestRepo(t, clock.New())
+	ctx := context.Background()
+	passengerID := seedPassenger(t, repo)
+
+	save := func(status string, age time.Duration) string {
+		t.Helper()
+		ride := newTestRide(t, repo, passengerID)
+		if err := repo.Save(ctx, ride); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		_, err := repo.db.Exec(ctx, `
+			UPDATE rides SET status = $2, requested_at = NOW() - make_interval(secs => $3) WHERE id = $1
+		`, ride.ID(), status, age.Seconds())
+		if err != nil {
+			t.Fatalf("age ride: %v", err)
+		}
+		return ride.ID()
+	}
+	older := save("REQUESTED", time.Hour)
+	old := save("REQUESTED", 40*time.Minute)
+	fresh := save("REQUESTED", time.Minute)
+	save("MATCHED", time.Hour)
+
+	rides, err := repo.FindByStatus(ctx, domain.StatusRequested, 30*time.Minute)
+	if err != nil {
+		t.Fatalf("FindByStatus: %v", err)
+	}
+	if len(rides) != 2 || rides[0].ID() != older || rides[1].ID() != old {
+		t.Fatalf("found %d rides, want the two old requests, oldest first", len(rides))
+	}
+
+	// With no age every request is found
+	if all, err := repo.FindByStatus(ctx, domain.StatusRequested, 0); err != nil || len(all) != 3 {
+		t.Errorf("FindByStatus without age = %d rides, %v; want 3", len(all), err)
+	}
+
+	if ok, err := repo.ExpireRide(ctx, old, domain.ReasonExpired); err != nil || !ok {
+		t.Fatalf("ExpireRide = %v, %v; want the request expired", ok, err)
+	}
+	var status string
+	var reason, by *string
+	err = repo.db.QueryRow(ctx, `
+		SELECT status, cancellation_reason, cancelled_by FROM rides WHERE id = $1
+	`, old).Scan(&status, &reason, &by)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != "CANCELLED" || reason == nil || *reason != domain.ReasonExpired || by == nil || *by != domain.CancelledBySystem {
+		t.Errorf("expired ride = %s, reason %v by %v; want CANCELLED, EXPIRED by the system", status, reason, by)
+	}
+
+	// An expired ride is not expired twice, and a matched one not at all
+	if ok, err := repo.ExpireRide(ctx, old, domain.ReasonExpired); err != nil || ok {
+		t.Errorf("second ExpireRide = %v, %v; want nothing expired", ok, err)
+	}
+	if _, err := repo.db.Exec(ctx, `UPDATE rides SET status = 'MATCHED' WHERE id = $1`, fresh); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := repo.ExpireRide(ctx, fresh, domain.ReasonExpired); err != nil || ok {
+		t.Errorf("ExpireRide of a matched ride = %v, %v; want it left alone", ok, err)
+	}
+}
+
+func TestFindActiveByPassengerSkipsFinishedRides(t *testing.T) {
+	repo := newTestRepo(t, clock.New())
+	ctx := context.Background()
+	passengerID := seedPassenger(t, repo)
+
+	save := func(status string, age time.Duration) string {
+		t.Helper()
+		ride := newTestRide(t, repo, passengerID)
+		if err := repo.Save(ctx, ride); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		_, err := repo.db.Exec(ctx, `
+			UPDATE rides SET status = $2, requested_at = NOW() - make_interval(secs => $3) WHERE id = $1
+		`, ride.ID(), status, age.Seconds())
+		if err != nil {
+			t.Fatalf("set ride status: %v", err)
+		}
+		return ride.ID()
+	}
+	older := save("IN_PROGRESS", 20*time.Minute)
+	save("COMPLETED", 10*time.Minute)
+	save("CANCELLED", 5*time.Minute)
+	newer := save("REQUESTED", time.Minute)
+
+	rides, err := repo.FindActiveByPassenger(ctx, passengerID)
+	if err != nil {
+		t.Fatalf("FindActiveByPassenger: %v", err)
+	}
+	if len(rides) != 2 || rides[0].ID() != newer || rides[1].ID() != older {
+		t.Fatalf("found %d rides, want the REQUESTED then the IN_PROGRESS ride", len(rides))
+	}
+	if rides[1].Status() != domain.StatusInProgress || rides[1].PickupLocation().Address() != "Abay Ave 10" {
+		t.Errorf("ride = %s at %q, want IN_PROGRESS from Abay Ave 10", rides[1].Status(), rides[1].PickupLocation().Address())
+	}
+
+	var otherID string
+	if err := repo.db.QueryRow(ctx, `
+		INSERT INTO users (email, role, password_hash) VALUES ('other@repo.test', 'PASSENGER', 'x') RETURNING id
+	`).Scan(&otherID); err != nil {
+		t.Fatalf("seed passenger: %v", err)
+	}
+	if rides, err := repo.FindActiveByPassenger(ctx, otherID); err != nil || len(rides) != 0 {
+		t.Errorf("other passenger: %d rides, %v; want none", len(rides), err)
+	}
+}
+
+func TestCancellationPartyIsStoredAndRecorded(t *testing.T) {
+	repo := newTestRepo(t, clock.New())
+	ctx := context.Background()
+	passengerID := seedPassenger(t, repo)
+
+	byPassenger := newTestRide(t, repo, passengerID)
+	if err := repo.Save(ctx, byPassenger); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := byPassenger.Cancel("changed plans", domain.CancelledByPassenger); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if err := repo.Update(ctx, byPassenger); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	err := repo.SaveEvent(ctx, byPassenger.ID(), domain.RideCancelledEvent{
+		RideID:      byPassenger.ID(),
+		PassengerID: passengerID,
+		Reason:      "changed plans",
+		CancelledBy: domain.CancelledByPassenger,
+		CancelledAt: *byPassenger.CancelledAt(),
+	})
+	if err != nil {
+		t.Fatalf("SaveEvent: %v", err)
+	}
+
+	byDriver := newTestRide(t, repo, passengerID)
+	if err := repo.Save(ctx, byDriver); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := repo.CancelRide(ctx, byDriver.ID(), "vehicle trouble", domain.CancelledByDriver, 0); err != nil {
+		t.Fatalf("CancelRide: %v", err)
+	}
+
+	for id, want := range map[string]string{byPassenger.ID(): domain.CancelledByPassenger, byDriver.ID(): domain.CancelledByDriver} {
+		ride, err := repo.FindByID(ctx, id)
+		if err != nil {
+			t.Fatalf("FindByID: %v", err)
+		}
+		if ride.Status() != domain.StatusCancelled || ride.CancelledBy() != want || ride.CancelledAt() == nil {
+			t.Errorf("ride %s = %s by %q at %v, want CANCELLED by %s", id, ride.Status(), ride.CancelledBy(), ride.CancelledAt(), want)
+		}
+	}
+
+	var recorded string
+	err = repo.db.QueryRow(ctx, `
+		SELECT event_data ->> 'cancelled_by' FROM ride_events WHERE ride_id = $1 AND event_type = 'RIDE_CANCELLED'
+	`, byPassenger.ID()).Scan(&recorded)
+	if err != nil {
+		t.Fatalf("read ride event: %v", err)
+	}
+	if recorded != domain.CancelledByPassenger {
+		t.Errorf("ride_events cancelled_by = %q, want PASSENGER", recorded)
+	}
+
+	// A ride that was never cancelled has nobody recorded
+	open := newTestRide(t, repo, passengerID)
+	if err := repo.Save(ctx, open); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	ride, err := repo.FindByID(ctx, open.ID())
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	if ride.CancelledBy() != "" {
+		t.Errorf("open ride cancelled by %q, want nobody", ride.CancelledBy())
+	}
+}
@@ -0,0 +1,89 @@
+package stream
+
+import (
+	"sync"
+
+	"ride-hail/pkg/logger"
+	"ride-hail/pkg/wsmsg"
+)
+
+// subscriberBuffer is how many events a slow subscriber may lag behind before events are dropped
+const subscriberBuffer = 32
+
+// Event is a single ride update delivered to stream subscribers
+type Event struct {
+	Type     wsmsg.Type
+	Data     interface{}
+	Terminal bool // true when the ride reached COMPLETED or CANCELLED
+}
+
+// Hub fans ride updates out to subscribers of a specific ride (used by the SSE endpoint)
+type Hub struct {
+	subscribers map[string]map[chan Event]struct{} // ride_id -> subscriber channels
+	mu          sync.RWMutex
+	log         logger.Logger
+}
+
+// NewHub creates a new ride update hub
+func NewHub(log logger.Logger) *Hub {
+	return &Hub{
+		subscribers: make(map[string]map[chan Event]struct{}),
+		log:         log,
+	}
+}
+
+// Subscribe registers interest in a ride's updates.
+// The returned function must be called to release the subscription.
+func (h *Hub) Subscribe(rideID string) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	h.mu.Lock()
+	if h.subscribers[rideID] == nil {
+		h.subscribers[rideID] = make(map[chan Event]struct{})
+	}
+	h.subscribers[rideID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			delete(h.subscribers[rideID], ch)
+			if len(h.subscribers[rideID]) == 0 {
+				delete(h.subscribers, rideID)
+			}
+		})
+	}
+
+	return ch, unsubscribe
+}
+
+// Publish sends an event to every subscriber of the ride without blocking
+func (h *Hub) Publish(rideID string, event Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for ch := range h.subscribers[rideID] {
+		select {
+		case ch <- event:
+		default:
+			h.log.WithFields(logger.LogFields{
+				"ride_id":    rideID,
+				"event_type": event.Type,
+			}).Debug("stream_subscriber_slow", "Dropping event for slow stream subscriber")
+		}
+	}
+}
+
+// End sends a terminal event to every subscriber of the ride, which closes their streams
+func (h *Hub) End(rideID string, eventType wsmsg.Type, data interface{}) {
+	h.Publish(rideID, Event{Type: eventType, Data: data, Terminal: true})
+}
+
+// SubscriberCount returns the number of subscribers for a ride
+func (h *Hub) SubscriberCount(rideID string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subscribers[rideID])
+}
@@ -0,0 +1,79 @@
+package stream
+
+import (
+	"testing"
+
+	"ride-hail/pkg/logger"
+	"ride-hail/pkg/wsmsg"
+)
+
+type nopLogger struct{}
+
+func (l nopLogger) WithFields(logger.LogFields) logger.Logger { return l }
+func (nopLogger) Info(action, message string)                 {}
+func (nopLogger) Debug(action, message string)                {}
+func (nopLogger) Warn(action, message string)                 {}
+func (nopLogger) Error(action string, err error)              {}
+
+func TestHubDeliversToTheRidesSubscribersOnly(t *testing.T) {
+	h := NewHub(nopLogger{})
+	a, unsubA := h.Subscribe("ride-1")
+	defer unsubA()
+	b, unsubB := h.Subscribe("ride-1")
+	defer unsubB()
+	other, unsubOther := h.Subscribe("ride-2")
+	defer unsubOther()
+
+	h.Publish("ride-1", Event{Type: wsmsg.TypeRideStatusUpdate, Data: "EN_ROUTE"})
+	h.End("ride-1", wsmsg.TypeRideStatusUpdate, "COMPLETED")
+
+	for name, ch := range map[string]<-chan Event{"a": a, "b": b} {
+		if ev := <-ch; ev.Data != "EN_ROUTE" || ev.Terminal {
+			t.Errorf("%s: first event = %+v, want EN_ROUTE", name, ev)
+		}
+		if ev := <-ch; ev.Data != "COMPLETED" || !ev.Terminal {
+			t.Errorf("%s: second event = %+v, want terminal COMPLETED", name, ev)
+		}
+	}
+	select {
+	case ev := <-other:
+		t.Errorf("ride-2 subscriber got %+v", ev)
+	default:
+	}
+}
+
+func TestHubUnsubscribe(t *testing.T) {
+	h := NewHub(nopLogger{})
+	_, unsub1 := h.Subscribe("ride-1")
+	_, unsub2 := h.Subscribe("ride-1")
+	if n := h.SubscriberCount("ride-1"); n != 2 {
+		t.Fatalf("SubscriberCount = %d, want 2", n)
+	}
+
+	unsub1()
+	unsub1() // safe to call twice
+	if n := h.SubscriberCount("ride-1"); n != 1 {
+		t.Errorf("after one unsubscribe: %d, want 1", n)
+	}
+	unsub2()
+	if n := len(h.subscribers); n != 0 {
+		t.Errorf("%d rides still tracked, want none", n)
+	}
+}
+
+func TestHubDropsEventsForSlowSubscriber(t *testing.T) {
+	h := NewHub(nopLogger{})
+	ch, unsub := h.Subscribe("ride-1")
+	defer unsub()
+
+	// Nobody reads; Publish must not block once the buffer is full
+	for i := 0; i < subscriberBuffer+10; i++ {
+		h.Publish("ride-1", Event{Type: wsmsg.TypeDriverLocationUpdate, Data: i})
+	}
+	if n := len(ch); n != subscriberBuffer {
+		t.Errorf("buffered %d events, want %d", n, subscriberBuffer)
+	}
+	if ev := <-ch; ev.Data != 0 {
+		t.Errorf("first event = %v, want the oldest kept", ev.Data)
+	}
+}
@@ -35,7 +35,8 @@ create index idx_drivers_status on drivers(status);
   "vehicle_model": "Camry",
   "vehicle_color": "White",
   "vehicle_plate": "KZ 123 ABC",
-  "vehicle_year": 2020
+  "vehicle_year": 2020,
+  "seats": 4
 }
 */
 
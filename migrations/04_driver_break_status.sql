@@ -0,0 +1,10 @@
+begin;
+
+-- Driver paused offers but kept the session open
+insert into
+    "driver_status" ("value")
+values
+    ('ON_BREAK')
+on conflict do nothing;
+
+commit;
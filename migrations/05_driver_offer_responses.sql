@@ -0,0 +1,24 @@
+begin;
+
+-- How a driver answered each ride offer; feeds the acceptance rate
+create table "offer_response"("value" text not null primary key);
+insert into
+    "offer_response" ("value")
+values
+    ('ACCEPTED'),     -- Driver accepted the offer
+    ('REJECTED'),     -- Driver declined the offer
+    ('EXPIRED')       -- Offer timed out without an answer
+;
+
+create table driver_offer_responses (
+                                        id uuid primary key default gen_random_uuid(),
+                                        created_at timestamptz not null default now(),
+                                        driver_id uuid references drivers(id) not null,
+                                        ride_id uuid references rides(id) not null,
+                                        offer_id text not null,
+                                        response text references "offer_response"(value) not null
+);
+
+create index idx_driver_offer_responses_driver on driver_offer_responses(driver_id, created_at);
+
+commit;
@@ -0,0 +1,17 @@
+begin;
+
+-- Per-day ride number counter; the row lock taken while a ride is inserted keeps
+-- numbers unique and gapless under concurrent requests
+create table ride_number_counters (
+                                      day date primary key,
+                                      last_value integer not null check (last_value >= 0)
+);
+
+-- Continue today's numbering from rides created before this migration
+insert into ride_number_counters (day, last_value)
+select current_date, count(*)
+from rides
+where requested_at::date = current_date
+on conflict (day) do nothing;
+
+commit;
@@ -0,0 +1,9 @@
+begin;
+
+-- Seats the ride needs; matching only offers it to drivers whose
+-- vehicle_attrs.seats is at least this
+alter table rides
+    add column passenger_count integer not null default 1
+        check (passenger_count between 1 and 8);
+
+commit;
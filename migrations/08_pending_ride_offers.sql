@@ -0,0 +1,16 @@
+begin;
+
+-- Offers sent to drivers and not yet answered; the driver location service
+-- reloads these on startup so a restart does not drop in-flight offers
+create table pending_ride_offers (
+                                     offer_id text primary key,
+                                     created_at timestamptz not null default now(),
+                                     ride_id uuid references rides(id) not null,
+                                     driver_id uuid references drivers(id) not null,
+                                     request jsonb not null,  -- The matching request the offer was made for
+                                     expires_at timestamptz not null
+);
+
+create index idx_pending_ride_offers_ride on pending_ride_offers(ride_id);
+
+commit;
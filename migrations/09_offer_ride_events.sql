@@ -0,0 +1,13 @@
+begin;
+
+-- Per-ride offer telemetry; OFFER_SENT events also enforce the per-ride offer cap
+insert into
+    "ride_event_type" ("value")
+values
+    ('OFFER_SENT'),       -- Offer delivered to a driver
+    ('OFFER_ACCEPTED'),   -- Driver accepted the offer
+    ('OFFER_REJECTED'),   -- Driver declined the offer
+    ('OFFER_EXPIRED')     -- Offer timed out without an answer
+on conflict do nothing;
+
+commit;
@@ -0,0 +1,19 @@
+begin;
+
+-- Close all but the newest open session per driver so the index below can be built
+update driver_sessions s
+set ended_at = now()
+where s.ended_at is null
+  and exists (
+    select 1 from driver_sessions newer
+    where newer.driver_id = s.driver_id
+      and newer.ended_at is null
+      and (newer.started_at, newer.id) > (s.started_at, s.id)
+  );
+
+-- At most one open session per driver, enforced across service instances
+create unique index if not exists driver_sessions_one_active_idx
+    on driver_sessions (driver_id)
+    where ended_at is null;
+
+commit;
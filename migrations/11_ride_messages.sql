@@ -0,0 +1,17 @@
+begin;
+
+-- In-app chat between a ride's passenger and its assigned driver, relayed by
+-- the ride service so neither side sees the other's phone number
+create table ride_messages (
+                               id uuid primary key default gen_random_uuid(),
+                               created_at timestamptz not null default now(),
+                               ride_id uuid references rides(id) not null,
+                               sender_id uuid references users(id) not null,
+                               sender_role text not null check (sender_role in ('PASSENGER', 'DRIVER')),
+                               recipient_id uuid references users(id) not null,
+                               body text not null check (length(body) between 1 and 1000)
+);
+
+create index idx_ride_messages_ride on ride_messages(ride_id, created_at);
+
+commit;
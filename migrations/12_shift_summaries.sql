@@ -0,0 +1,18 @@
+begin;
+
+-- One row per ended driver session, written when the driver goes offline so
+-- past shifts can be listed without recomputing ride time
+create table shift_summaries (
+                                 session_id uuid primary key references driver_sessions(id),
+                                 created_at timestamptz not null default now(),
+                                 driver_id uuid references drivers(id) not null,
+                                 started_at timestamptz not null,
+                                 ended_at timestamptz not null,
+                                 rides_completed integer not null default 0,
+                                 earnings decimal(10,2) not null default 0,
+                                 busy_seconds integer not null default 0 check (busy_seconds >= 0)
+);
+
+create index idx_shift_summaries_driver on shift_summaries(driver_id, ended_at desc);
+
+commit;
@@ -0,0 +1,10 @@
+begin;
+
+-- Passenger moved the drop-off of a ride in progress
+insert into
+    "ride_event_type" ("value")
+values
+    ('DESTINATION_CHANGED')
+on conflict do nothing;
+
+commit;
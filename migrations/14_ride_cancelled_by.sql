@@ -0,0 +1,21 @@
+begin;
+
+-- Who cancelled the ride; null until it is cancelled
+alter table rides
+    add column cancelled_by text
+        check (cancelled_by in ('PASSENGER', 'DRIVER', 'SYSTEM', 'ADMIN'));
+
+-- Backfill from the audit trail, which has recorded the party all along
+update rides r
+set cancelled_by = (
+    select e.event_data ->> 'cancelled_by'
+    from ride_events e
+    where e.ride_id = r.id
+      and e.event_type = 'RIDE_CANCELLED'
+      and e.event_data ->> 'cancelled_by' in ('PASSENGER', 'DRIVER', 'SYSTEM', 'ADMIN')
+    order by e.created_at desc
+    limit 1
+)
+where r.status = 'CANCELLED';
+
+commit;
@@ -0,0 +1,17 @@
+begin;
+
+-- An accept that could not be honoured (ride already taken, driver busy, ride
+-- cancelled); kept apart so it doesn't count as an acceptance
+insert into
+    "offer_response" ("value")
+values
+    ('ACCEPT_FAILED')
+on conflict do nothing;
+
+insert into
+    "ride_event_type" ("value")
+values
+    ('OFFER_ACCEPT_FAILED')
+on conflict do nothing;
+
+commit;
@@ -0,0 +1,14 @@
+begin;
+
+-- The ride a driver accepted, set by the driver service as soon as the accept
+-- binds them; rides.driver_id only follows once the ride service has caught up
+alter table drivers
+    add column current_ride_id uuid references rides(id) on delete set null;
+
+update drivers d
+set current_ride_id = r.id
+from rides r
+where r.driver_id = d.id
+  and r.status in ('MATCHED', 'EN_ROUTE', 'ARRIVED', 'IN_PROGRESS');
+
+commit;
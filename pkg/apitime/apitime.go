@@ -0,0 +1,29 @@
+// Package apitime keeps timestamps in API responses in one format: RFC 3339 in
+// UTC with whole seconds, e.g. "2024-12-16T10:30:00Z".
+package apitime
+
+import "time"
+
+// Layout is the format of every timestamp an API response carries
+const Layout = time.RFC3339
+
+// Format renders t in UTC using Layout
+func Format(t time.Time) string {
+	return t.UTC().Format(Layout)
+}
+
+// Time is a time.Time that marshals to JSON using Format. It unmarshals any
+// RFC 3339 timestamp, like time.Time.
+type Time struct {
+	time.Time
+}
+
+// New wraps t for a response field
+func New(t time.Time) Time {
+	return Time{Time: t}
+}
+
+// MarshalJSON implements json.Marshaler
+func (t Time) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + Format(t.Time) + `"`), nil
+}
@@ -0,0 +1,54 @@
+package apitime
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestFormatIsUTCWithWholeSeconds(t *testing.T) {
+	almaty := time.FixedZone("ALMT", 5*60*60)
+	tests := []struct {
+		name string
+		in   time.Time
+		want string
+	}{
+		{"utc", time.Date(2024, 12, 16, 10, 30, 0, 0, time.UTC), "2024-12-16T10:30:00Z"},
+		{"offset converted", time.Date(2024, 12, 16, 15, 30, 0, 0, almaty), "2024-12-16T10:30:00Z"},
+		{"fraction dropped", time.Date(2024, 12, 16, 10, 30, 0, 999_999_999, time.UTC), "2024-12-16T10:30:00Z"},
+		{"day rolls back", time.Date(2024, 12, 17, 2, 0, 0, 0, almaty), "2024-12-16T21:00:00Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Format(tt.in); got != tt.want {
+				t.Errorf("Format = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeMarshalsLikeFormat(t *testing.T) {
+	in := time.Date(2024, 12, 16, 15, 30, 0, 123_000_000, time.FixedZone("ALMT", 5*60*60))
+	body, err := json.Marshal(struct {
+		At Time `json:"at"`
+	}{New(in)})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if want := `{"at":"2024-12-16T10:30:00Z"}`; string(body) != want {
+		t.Errorf("body = %s, want %s", body, want)
+	}
+}
+
+func TestTimeUnmarshalsAnyRFC3339(t *testing.T) {
+	var got struct {
+		At Time `json:"at"`
+	}
+	if err := json.Unmarshal([]byte(`{"at":"2024-12-16T15:30:00.5+05:00"}`), &got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	want := time.Date(2024, 12, 16, 10, 30, 0, 500_000_000, time.UTC)
+	if !got.At.Equal(want) {
+		t.Errorf("At = %v, want %v", got.At, want)
+	}
+}
@@ -0,0 +1,75 @@
+// Package buildinfo reports which build of a service is running. Version,
+// Commit and BuildTime are set at link time, e.g.
+//
+//	go build -ldflags "-X ride-hail/pkg/buildinfo.Version=1.2.0 \
+//	  -X ride-hail/pkg/buildinfo.Commit=$(git rev-parse --short HEAD) \
+//	  -X ride-hail/pkg/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/ride-service
+package buildinfo
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+)
+
+// Set through -ldflags "-X"; left empty they fall back to the VCS stamp Go
+// embeds in the binary, and then to "unknown"
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+const unknown = "unknown"
+
+// Info describes the running build of a service
+type Info struct {
+	Service   string `json:"service"`
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build metadata of the named service
+func Get(service string) Info {
+	info := Info{
+		Service:   service,
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.BuildTime == "":
+				info.BuildTime = s.Value
+			}
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = unknown
+	}
+	if info.BuildTime == "" {
+		info.BuildTime = unknown
+	}
+	return info
+}
+
+// Handler serves GET /version for the named service
+func Handler(service string) http.HandlerFunc {
+	info := Get(service)
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(info)
+	}
+}
@@ -0,0 +1,72 @@
+package buildinfo
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+// setBuild sets the link-time variables for the test, restoring them after
+func setBuild(t *testing.T, version, commit, buildTime string) {
+	t.Helper()
+	oldVersion, oldCommit, oldBuildTime := Version, Commit, BuildTime
+	t.Cleanup(func() { Version, Commit, BuildTime = oldVersion, oldCommit, oldBuildTime })
+	Version, Commit, BuildTime = version, commit, buildTime
+}
+
+// getVersion serves GET /version for service and decodes the response
+func getVersion(t *testing.T, service string) Info {
+	t.Helper()
+	w := httptest.NewRecorder()
+	Handler(service).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/version", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &fields); err != nil {
+		t.Fatalf("decode %s: %v", w.Body, err)
+	}
+	for _, name := range []string{"service", "version", "commit", "build_time", "go_version"} {
+		if _, ok := fields[name].(string); !ok {
+			t.Errorf("response %s has no %q string", w.Body, name)
+		}
+	}
+	var info Info
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatalf("decode %s: %v", w.Body, err)
+	}
+	return info
+}
+
+func TestVersionReportsLinkedMetadata(t *testing.T) {
+	setBuild(t, "1.2.0", "abc1234", "2024-12-16T10:00:00Z")
+
+	want := Info{
+		Service:   "ride-service",
+		Version:   "1.2.0",
+		Commit:    "abc1234",
+		BuildTime: "2024-12-16T10:00:00Z",
+		GoVersion: runtime.Version(),
+	}
+	if got := getVersion(t, "ride-service"); got != want {
+		t.Errorf("GET /version = %+v, want %+v", got, want)
+	}
+}
+
+func TestVersionDefaultsWithoutLdflags(t *testing.T) {
+	// Test binaries carry no VCS stamp to fall back to
+	setBuild(t, "", "", "")
+
+	got := getVersion(t, "auth-service")
+	if got.Service != "auth-service" || got.Version != "dev" || got.Commit != "unknown" || got.BuildTime != "unknown" {
+		t.Errorf("GET /version = %+v, want auth-service dev with unknown commit and build time", got)
+	}
+	if got.GoVersion != runtime.Version() {
+		t.Errorf("go_version = %q, want %q", got.GoVersion, runtime.Version())
+	}
+}
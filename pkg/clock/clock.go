@@ -0,0 +1,23 @@
+// Package clock abstracts the wall clock so time-dependent logic (offer expiry,
+// session summaries, ride numbers) can be driven deterministically.
+package clock
+
+import "time"
+
+// Clock tells the time and waits for it to pass.
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	// After delivers the current time on the returned channel once d has elapsed
+	After(d time.Duration) <-chan time.Time
+}
+
+// Real is the system clock.
+type Real struct{}
+
+// New returns the system clock.
+func New() Clock { return Real{} }
+
+func (Real) Now() time.Time                         { return time.Now() }
+func (Real) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (Real) After(d time.Duration) <-chan time.Time { return time.After(d) }
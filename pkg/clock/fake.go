@@ -0,0 +1,84 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Fake is a Clock that only moves when told to. Waiters registered with After
+// fire as Advance or Set passes their deadline.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// NewFake returns a fake clock stopped at now.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+// After fires immediately for d <= 0, otherwise once the clock reaches now+d.
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- f.now
+		return ch
+	}
+	f.waiters = append(f.waiters, fakeWaiter{at: f.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock forward by d and fires every waiter now due.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	f.setLocked(f.now.Add(d))
+	f.mu.Unlock()
+}
+
+// Set moves the clock to t and fires every waiter now due. Moving backwards
+// fires nothing.
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	f.setLocked(t)
+	f.mu.Unlock()
+}
+
+// Waiters returns how many After calls are still pending, so a test can wait
+// for a goroutine to start sleeping before advancing the clock.
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
+
+func (f *Fake) setLocked(t time.Time) {
+	f.now = t
+	pending := f.waiters[:0]
+	for _, w := range f.waiters {
+		if !w.at.After(t) {
+			w.ch <- t
+			continue
+		}
+		pending = append(pending, w)
+	}
+	f.waiters = pending
+}
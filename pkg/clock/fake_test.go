@@ -0,0 +1,95 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 12, 16, 10, 0, 0, 0, time.UTC)
+
+// fired reports whether ch has a value ready, without blocking
+func fired(ch <-chan time.Time) (time.Time, bool) {
+	select {
+	case t := <-ch:
+		return t, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func TestFakeStandsStillUntilMoved(t *testing.T) {
+	f := NewFake(start)
+
+	if !f.Now().Equal(start) {
+		t.Fatalf("Now = %v, want %v", f.Now(), start)
+	}
+	f.Advance(90 * time.Second)
+	if got := f.Since(start); got != 90*time.Second {
+		t.Errorf("Since = %v, want 90s", got)
+	}
+	f.Set(start.Add(time.Hour))
+	if !f.Now().Equal(start.Add(time.Hour)) {
+		t.Errorf("Now after Set = %v, want an hour in", f.Now())
+	}
+}
+
+func TestFakeAfterFiresOnceTheDeadlinePasses(t *testing.T) {
+	f := NewFake(start)
+	soon, later := f.After(10*time.Second), f.After(time.Minute)
+	if n := f.Waiters(); n != 2 {
+		t.Fatalf("Waiters = %d, want 2", n)
+	}
+
+	f.Advance(9 * time.Second)
+	if _, ok := fired(soon); ok {
+		t.Fatal("fired a second early")
+	}
+
+	f.Advance(time.Second)
+	if at, ok := fired(soon); !ok || !at.Equal(start.Add(10*time.Second)) {
+		t.Errorf("at the deadline: fired=%v at %v, want fired at 10s", ok, at)
+	}
+	if _, ok := fired(later); ok {
+		t.Error("the later waiter fired early")
+	}
+	if n := f.Waiters(); n != 1 {
+		t.Errorf("Waiters = %d, want the later one left", n)
+	}
+
+	// Jumping past several deadlines fires each waiter once
+	f.Set(start.Add(time.Hour))
+	if _, ok := fired(later); !ok {
+		t.Error("the later waiter did not fire")
+	}
+	f.Advance(time.Hour)
+	if _, ok := fired(later); ok {
+		t.Error("a waiter fired twice")
+	}
+}
+
+func TestFakeAfterWithoutDelayFiresImmediately(t *testing.T) {
+	f := NewFake(start)
+
+	for _, d := range []time.Duration{0, -time.Second} {
+		if at, ok := fired(f.After(d)); !ok || !at.Equal(start) {
+			t.Errorf("After(%v): fired=%v at %v, want fired now", d, ok, at)
+		}
+	}
+	if n := f.Waiters(); n != 0 {
+		t.Errorf("Waiters = %d, want none", n)
+	}
+}
+
+func TestFakeMovingBackwardsFiresNothing(t *testing.T) {
+	f := NewFake(start)
+	ch := f.After(time.Second)
+
+	f.Set(start.Add(-time.Hour))
+	if _, ok := fired(ch); ok {
+		t.Error("fired after moving backwards")
+	}
+	f.Set(start.Add(time.Second))
+	if _, ok := fired(ch); !ok {
+		t.Error("did not fire once the deadline was reached")
+	}
+}
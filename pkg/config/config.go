@@ -3,55 +3,223 @@ package config
 import (
 	"bufio"
 	"fmt"
+	"net/http"
 	"os"
 	"strconv"
 	"strings"
+	"time"
+
+	"ride-hail/pkg/pricing"
 )
 
 type Config struct {
-	DB struct {
+	Env string // Deployment environment, "production" unless set; "development" relaxes browser-facing checks
+	DB  struct {
 		Host     string
 		Port     int
 		User     string
 		Password string
 		Database string
+		// Match drivers with a Go-side haversine filter when PostGIS is not installed
+		PostGISFallback bool
+		// Startup connection attempts and the base wait between them (doubled per retry, with jitter)
+		ConnectRetries       int
+		ConnectRetryInterval time.Duration
+		// Upper bound on each repository call, whatever context it was given, 0 = none
+		QueryTimeout time.Duration
 	}
 	RabbitMQ struct {
-		Host     string
-		Port     int
-		User     string
-		Password string
+		Host       string
+		Port       int
+		User       string
+		Password   string
+		VHost      string
+		UseTLS     bool
+		CACertPath string // Optional CA bundle for verifying the broker
+		CertPath   string // Optional client certificate for mutual TLS
+		KeyPath    string // Optional client key for mutual TLS
 	}
 	Websocket struct {
-		Port int
+		Port           int
+		AllowedOrigins []string // Browser origins allowed to open a WebSocket; empty = same host only
+		// permessage-deflate for clients that offer it, at a compress/flate level (-2 to 9)
+		Compression      bool
+		CompressionLevel int
 	}
 	Services struct {
 		RideService           int
 		DriverLocationService int
 		AdminService          int
+		AuthService           int
+	}
+	Auth struct {
+		JWTSecret string
+		// Shared secret callers of the /internal/ endpoints send as a bearer
+		// token; empty closes those endpoints
+		ServiceToken string
+	}
+	Pricing struct {
+		DriverSharePercent float64 // Driver's cut of the fare, 0-100
+		AverageSpeedKmh    float64 // Turns trip distance into minutes for per-minute rates
+		Economy            pricing.Rates
+		Premium            pricing.Rates
+		Luxury             pricing.Rates
+	}
+	Location struct {
+		PublishEpsilonMeters float64 // Minimum move before a driver location is re-broadcast
+	}
+	RateLimit struct {
+		Backend string // "memory" (per instance) or "redis" (shared by all instances)
+		// Ride requests a passenger may make per window, 0 = no limit
+		RideRequests      int
+		RideRequestWindow time.Duration
+	}
+	Redis struct {
+		Addr     string
+		Password string
+		DB       int
+	}
+	ServiceArea struct {
+		// Box rides must start and end in; all zero = no restriction
+		MinLat, MinLng    float64
+		MaxLat, MaxLng    float64
+		MinRideDistanceKm float64 // Shortest straight-line ride accepted
+		MaxRideDistanceKm float64 // Longest straight-line ride accepted, 0 = no limit
 	}
+	NoShow struct {
+		WaitS int     // Seconds a driver must wait at the pickup before reporting a no-show
+		Fee   float64 // Charged to the passenger for a no-show
+	}
+	Notifications struct {
+		ArrivingRadiusMeters float64 // Driver distance to pickup that triggers the driver_arriving push
+	}
+	Drivers struct {
+		MinOnlineRating float64 // Lowest driver rating allowed to go online, 0 = no floor
+	}
+	Matching struct {
+		HeadingRerank      bool    // Re-rank nearby drivers by heading-aware ETA
+		RadiusStepKm       float64 // Search radius growth per expansion when no driver is found
+		MaxRadiusKm        float64 // Largest search radius before matching gives up
+		ExpansionDelayS    int     // Seconds to wait between radius expansions
+		AssignmentTimeoutS int     // Seconds a matched driver has to report en route before re-matching, 0 = off
+		DeadlineS          int     // Seconds a ride may wait for a driver before it is cancelled, 0 = off
+		RideExpiryS        int     // Seconds after its request a ride still REQUESTED is expired, however often re-matched, 0 = off
+		MinRatingPremium   float64 // Lowest driver rating matched for PREMIUM rides, 0 = no floor
+		MinRatingLuxury    float64 // Lowest driver rating matched for LUXURY rides, 0 = no floor
+		MaxLocationAgeS    int     // Seconds since a driver's last location before they stop getting offers, 0 = off
+		StaleLocationAgeS  int     // Seconds since a driver's last location before fresher drivers are offered first, 0 = off
+		OfferAckTimeoutMs  int     // Milliseconds a driver's client has to acknowledge an offer, 0 = don't wait
+		MaxOffersPerRide   int     // Offers a ride may receive across all matching attempts, 0 = no cap
+
+		DefaultSearchRadiusKm float64 // Search radius when a request names none
+		MaxSearchRadiusKm     float64 // Largest search radius a request may ask for; larger ones are clamped
+	}
+	HTTPServer   HTTPServerConfig
 	TestVariable string
 }
 
+// Rate limiter backends accepted in RATE_LIMIT_BACKEND
+const (
+	RateLimitMemory = "memory"
+	RateLimitRedis  = "redis"
+)
+
+// HTTPServerConfig holds the timeouts applied to every service's HTTP server
+type HTTPServerConfig struct {
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration // Grace period for in-flight requests on shutdown
+}
+
+// NewServer builds an http.Server with the configured timeouts
+func (c HTTPServerConfig) NewServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:         addr,
+		Handler:      handler,
+		ReadTimeout:  c.ReadTimeout,
+		WriteTimeout: c.WriteTimeout,
+		IdleTimeout:  c.IdleTimeout,
+	}
+}
+
 func LoadConfig(filename string) (*Config, error) {
 	err := loadEnvFile(filename)
 	if err != nil {
 		return nil, err
 	}
 	cfg := &Config{}
+	cfg.Env = getEnv("APP_ENV", "production")
 	cfg.DB.Host = getEnv("DB_HOST", "localhost")
 	cfg.DB.Port = getEnvAsInt("DB_PORT", 5432)
 	cfg.DB.User = getEnv("DB_USER", "ridehail_user")
 	cfg.DB.Password = getEnv("DB_PASS", "ridehail_pass")
 	cfg.DB.Database = getEnv("DB_NAME", "ridehail_db")
+	cfg.DB.PostGISFallback = getEnvAsBool("DB_POSTGIS_FALLBACK", false)
+	cfg.DB.ConnectRetries = getEnvAsInt("DB_CONNECT_RETRIES", 8)
+	cfg.DB.ConnectRetryInterval = time.Duration(getEnvAsInt("DB_CONNECT_RETRY_INTERVAL_MS", 1000)) * time.Millisecond
+	cfg.DB.QueryTimeout = time.Duration(getEnvAsInt("DB_QUERY_TIMEOUT_MS", 5000)) * time.Millisecond
 	cfg.RabbitMQ.Host = getEnv("RABBITMQ_HOST", "localhost")
 	cfg.RabbitMQ.Port = getEnvAsInt("RABBITMQ_PORT", 5672)
 	cfg.RabbitMQ.User = getEnv("RABBITMQ_USER", "guest")
 	cfg.RabbitMQ.Password = getEnv("RABBITMQ_PASS", "guest")
+	cfg.RabbitMQ.VHost = getEnv("RABBITMQ_VHOST", "")
+	cfg.RabbitMQ.UseTLS = getEnvAsBool("RABBITMQ_USE_TLS", false)
+	cfg.RabbitMQ.CACertPath = getEnv("RABBITMQ_CA_CERT", "")
+	cfg.RabbitMQ.CertPath = getEnv("RABBITMQ_CLIENT_CERT", "")
+	cfg.RabbitMQ.KeyPath = getEnv("RABBITMQ_CLIENT_KEY", "")
 	cfg.Websocket.Port = getEnvAsInt("WEBSOCKET_PORT", 8080)
+	cfg.Websocket.AllowedOrigins = getEnvAsList("WS_ALLOWED_ORIGINS")
+	cfg.Websocket.Compression = getEnvAsBool("WS_COMPRESSION", true)
+	cfg.Websocket.CompressionLevel = getEnvAsInt("WS_COMPRESSION_LEVEL", 1)
 	cfg.Services.RideService = getEnvAsInt("SERVICES_RIDE_SERVICE", 3000)
 	cfg.Services.DriverLocationService = getEnvAsInt("DRIVER_LOCATION_SERVICE", 3001)
 	cfg.Services.AdminService = getEnvAsInt("ADMIN_SERVICE", 3004)
+	cfg.Services.AuthService = getEnvAsInt("AUTH_SERVICE_PORT", 3005)
+	cfg.Auth.JWTSecret = getEnv("JWT_SECRET_KEY", "")
+	cfg.Auth.ServiceToken = getEnv("INTERNAL_SERVICE_TOKEN", "")
+	cfg.Pricing.DriverSharePercent = getEnvAsFloat("PRICING_DRIVER_SHARE_PERCENT", 80)
+	cfg.Pricing.AverageSpeedKmh = getEnvAsFloat("PRICING_AVERAGE_SPEED_KMH", 30)
+	defaultRates := pricing.DefaultRates()
+	cfg.Pricing.Economy = getFareRates("ECONOMY", defaultRates["ECONOMY"])
+	cfg.Pricing.Premium = getFareRates("PREMIUM", defaultRates["PREMIUM"])
+	cfg.Pricing.Luxury = getFareRates("LUXURY", defaultRates["LUXURY"])
+	cfg.Matching.HeadingRerank = getEnvAsBool("MATCHING_HEADING_RERANK", false)
+	cfg.Matching.RadiusStepKm = getEnvAsFloat("MATCHING_RADIUS_STEP_KM", 5)
+	cfg.Matching.MaxRadiusKm = getEnvAsFloat("MATCHING_MAX_RADIUS_KM", 15)
+	cfg.Matching.ExpansionDelayS = getEnvAsInt("MATCHING_EXPANSION_DELAY_SECONDS", 2)
+	cfg.Matching.AssignmentTimeoutS = getEnvAsInt("MATCHING_ASSIGNMENT_TIMEOUT_SECONDS", 600)
+	cfg.Matching.DeadlineS = getEnvAsInt("MATCHING_DEADLINE_SECONDS", 300)
+	cfg.Matching.RideExpiryS = getEnvAsInt("MATCHING_RIDE_EXPIRY_SECONDS", 1800)
+	cfg.Matching.MinRatingPremium = getEnvAsFloat("MATCHING_MIN_RATING_PREMIUM", 4.5)
+	cfg.Matching.MinRatingLuxury = getEnvAsFloat("MATCHING_MIN_RATING_LUXURY", 4.8)
+	cfg.Matching.MaxLocationAgeS = getEnvAsInt("MATCHING_MAX_LOCATION_AGE_SECONDS", 120)
+	cfg.Matching.StaleLocationAgeS = getEnvAsInt("MATCHING_STALE_LOCATION_AGE_SECONDS", 30)
+	cfg.Matching.OfferAckTimeoutMs = getEnvAsInt("MATCHING_OFFER_ACK_TIMEOUT_MS", 3000)
+	cfg.Matching.MaxOffersPerRide = getEnvAsInt("MATCHING_MAX_OFFERS_PER_RIDE", 30)
+	cfg.Matching.DefaultSearchRadiusKm = getEnvAsFloat("MATCHING_DEFAULT_SEARCH_RADIUS_KM", 5)
+	cfg.Matching.MaxSearchRadiusKm = getEnvAsFloat("MATCHING_MAX_SEARCH_RADIUS_KM", 50)
+	cfg.Location.PublishEpsilonMeters = getEnvAsFloat("LOCATION_PUBLISH_EPSILON_METERS", 5)
+	cfg.RateLimit.Backend = getEnv("RATE_LIMIT_BACKEND", RateLimitMemory)
+	cfg.RateLimit.RideRequests = getEnvAsInt("RATE_LIMIT_RIDE_REQUESTS", 3)
+	cfg.RateLimit.RideRequestWindow = time.Duration(getEnvAsInt("RATE_LIMIT_RIDE_REQUEST_WINDOW_SECONDS", 60)) * time.Second
+	cfg.Redis.Addr = getEnv("REDIS_ADDR", "localhost:6379")
+	cfg.Redis.Password = getEnv("REDIS_PASSWORD", "")
+	cfg.Redis.DB = getEnvAsInt("REDIS_DB", 0)
+	cfg.ServiceArea.MinLat = getEnvAsFloat("SERVICE_AREA_MIN_LAT", 0)
+	cfg.ServiceArea.MinLng = getEnvAsFloat("SERVICE_AREA_MIN_LNG", 0)
+	cfg.ServiceArea.MaxLat = getEnvAsFloat("SERVICE_AREA_MAX_LAT", 0)
+	cfg.ServiceArea.MaxLng = getEnvAsFloat("SERVICE_AREA_MAX_LNG", 0)
+	cfg.ServiceArea.MinRideDistanceKm = getEnvAsFloat("MIN_RIDE_DISTANCE_KM", 0.1)
+	cfg.ServiceArea.MaxRideDistanceKm = getEnvAsFloat("MAX_RIDE_DISTANCE_KM", 100)
+	cfg.NoShow.WaitS = getEnvAsInt("NO_SHOW_WAIT_SECONDS", 300)
+	cfg.NoShow.Fee = getEnvAsFloat("NO_SHOW_FEE", 100)
+	cfg.Notifications.ArrivingRadiusMeters = getEnvAsFloat("NOTIFY_ARRIVING_RADIUS_METERS", 200)
+	cfg.Drivers.MinOnlineRating = getEnvAsFloat("DRIVER_MIN_ONLINE_RATING", 0)
+	cfg.HTTPServer.ReadTimeout = time.Duration(getEnvAsInt("HTTP_READ_TIMEOUT_SECONDS", 5)) * time.Second
+	cfg.HTTPServer.WriteTimeout = time.Duration(getEnvAsInt("HTTP_WRITE_TIMEOUT_SECONDS", 10)) * time.Second
+	cfg.HTTPServer.IdleTimeout = time.Duration(getEnvAsInt("HTTP_IDLE_TIMEOUT_SECONDS", 120)) * time.Second
+	cfg.HTTPServer.ShutdownTimeout = time.Duration(getEnvAsInt("HTTP_SHUTDOWN_TIMEOUT_SECONDS", 10)) * time.Second
 	cfg.TestVariable = getEnv("TEST_VARIABLE", "default_value")
 
 	return cfg, nil
@@ -103,6 +271,20 @@ func loadEnvFile(filename string) error {
 	return nil
 }
 
+// FareTable builds the fare table both the ride and driver services price with
+func (c *Config) FareTable() (*pricing.Table, error) {
+	return pricing.NewTable(map[string]pricing.Rates{
+		"ECONOMY": c.Pricing.Economy,
+		"PREMIUM": c.Pricing.Premium,
+		"LUXURY":  c.Pricing.Luxury,
+	}, c.Pricing.AverageSpeedKmh)
+}
+
+// IsDevelopment reports whether the service runs in the local development environment
+func (c *Config) IsDevelopment() bool {
+	return strings.EqualFold(c.Env, "development")
+}
+
 func getEnv(key, fallback string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
@@ -110,6 +292,44 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+func getEnvAsFloat(key string, fallback float64) float64 {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseFloat(valueStr, 64); err == nil {
+		return value
+	}
+	return fallback
+}
+
+// getFareRates reads PRICING_<rideType>_{BASE_FARE,PER_KM,PER_MINUTE,MIN_FARE}
+func getFareRates(rideType string, fallback pricing.Rates) pricing.Rates {
+	prefix := "PRICING_" + rideType + "_"
+	return pricing.Rates{
+		Base:      getEnvAsFloat(prefix+"BASE_FARE", fallback.Base),
+		PerKm:     getEnvAsFloat(prefix+"PER_KM", fallback.PerKm),
+		PerMinute: getEnvAsFloat(prefix+"PER_MINUTE", fallback.PerMinute),
+		Minimum:   getEnvAsFloat(prefix+"MIN_FARE", fallback.Minimum),
+	}
+}
+
+func getEnvAsBool(key string, fallback bool) bool {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+	return fallback
+}
+
+// getEnvAsList splits a comma-separated variable, dropping empty entries
+func getEnvAsList(key string) []string {
+	var out []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
 func getEnvAsInt(key string, fallback int) int {
 	valueStr := getEnv(key, "")
 	if value, err := strconv.Atoi(valueStr); err == nil {
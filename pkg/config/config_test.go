@@ -0,0 +1,117 @@
+package config
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewServerAppliesTimeouts(t *testing.T) {
+	cfg := HTTPServerConfig{
+		ReadTimeout:     3 * time.Second,
+		WriteTimeout:    7 * time.Second,
+		IdleTimeout:     90 * time.Second,
+		ShutdownTimeout: 15 * time.Second,
+	}
+	handler := http.NotFoundHandler()
+
+	srv := cfg.NewServer(":3000", handler)
+
+	if srv.Addr != ":3000" || srv.Handler == nil {
+		t.Errorf("server addr %q, handler %v; want :3000 and the handler", srv.Addr, srv.Handler)
+	}
+	if srv.ReadTimeout != 3*time.Second || srv.WriteTimeout != 7*time.Second || srv.IdleTimeout != 90*time.Second {
+		t.Errorf("timeouts read=%v write=%v idle=%v, want 3s, 7s and 90s", srv.ReadTimeout, srv.WriteTimeout, srv.IdleTimeout)
+	}
+}
+
+func TestLoadConfigHTTPTimeouts(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), ".env")
+
+	t.Run("defaults", func(t *testing.T) {
+		for _, key := range []string{"HTTP_READ_TIMEOUT_SECONDS", "HTTP_WRITE_TIMEOUT_SECONDS", "HTTP_IDLE_TIMEOUT_SECONDS", "HTTP_SHUTDOWN_TIMEOUT_SECONDS"} {
+			t.Setenv(key, "")
+		}
+		cfg, err := LoadConfig(missing)
+		if err != nil {
+			t.Fatalf("LoadConfig: %v", err)
+		}
+		want := HTTPServerConfig{ReadTimeout: 5 * time.Second, WriteTimeout: 10 * time.Second, IdleTimeout: 120 * time.Second, ShutdownTimeout: 10 * time.Second}
+		if cfg.HTTPServer != want {
+			t.Errorf("HTTPServer = %+v, want %+v", cfg.HTTPServer, want)
+		}
+	})
+
+	t.Run("from environment", func(t *testing.T) {
+		t.Setenv("HTTP_READ_TIMEOUT_SECONDS", "2")
+		t.Setenv("HTTP_WRITE_TIMEOUT_SECONDS", "30")
+		t.Setenv("HTTP_IDLE_TIMEOUT_SECONDS", "60")
+		t.Setenv("HTTP_SHUTDOWN_TIMEOUT_SECONDS", "20")
+		cfg, err := LoadConfig(missing)
+		if err != nil {
+			t.Fatalf("LoadConfig: %v", err)
+		}
+		want := HTTPServerConfig{ReadTimeout: 2 * time.Second, WriteTimeout: 30 * time.Second, IdleTimeout: 60 * time.Second, ShutdownTimeout: 20 * time.Second}
+		if cfg.HTTPServer != want {
+			t.Errorf("HTTPServer = %+v, want %+v", cfg.HTTPServer, want)
+		}
+	})
+}
+
+func TestLoadConfigWebsocketOrigins(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), ".env")
+
+	t.Run("defaults to production with no extra origins", func(t *testing.T) {
+		t.Setenv("APP_ENV", "")
+		t.Setenv("WS_ALLOWED_ORIGINS", "")
+		cfg, err := LoadConfig(missing)
+		if err != nil {
+			t.Fatalf("LoadConfig: %v", err)
+		}
+		if cfg.IsDevelopment() || len(cfg.Websocket.AllowedOrigins) != 0 {
+			t.Errorf("env %q, origins %v; want production and none", cfg.Env, cfg.Websocket.AllowedOrigins)
+		}
+	})
+
+	t.Run("from environment", func(t *testing.T) {
+		t.Setenv("APP_ENV", "Development")
+		t.Setenv("WS_ALLOWED_ORIGINS", "https://app.example.com, https://admin.example.com")
+		cfg, err := LoadConfig(missing)
+		if err != nil {
+			t.Fatalf("LoadConfig: %v", err)
+		}
+		if !cfg.IsDevelopment() {
+			t.Errorf("env %q is not development", cfg.Env)
+		}
+		if got := cfg.Websocket.AllowedOrigins; len(got) != 2 || got[0] != "https://app.example.com" || got[1] != "https://admin.example.com" {
+			t.Errorf("origins = %q, want both listed", got)
+		}
+	})
+}
+
+func TestLoadConfigFareRates(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), ".env")
+	t.Setenv("PRICING_ECONOMY_PER_KM", "30")
+	t.Setenv("PRICING_ECONOMY_MIN_FARE", "500")
+	t.Setenv("PRICING_AVERAGE_SPEED_KMH", "40")
+
+	cfg, err := LoadConfig(missing)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	table, err := cfg.FareTable()
+	if err != nil {
+		t.Fatalf("FareTable: %v", err)
+	}
+	economy, _ := table.RatesFor("ECONOMY")
+	if economy.Base != 100 || economy.PerKm != 30 || economy.Minimum != 500 {
+		t.Errorf("ECONOMY rates = %+v, want the default base with the configured per-km and minimum", economy)
+	}
+	if got := table.Fare(economy, 20); got != 700 {
+		t.Errorf("20 km economy fare = %v, want 700", got)
+	}
+	if got := table.EstimateMinutes(20); got != 30 {
+		t.Errorf("20 km takes %v minutes, want 30 at 40 km/h", got)
+	}
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"ride-hail/pkg/pricing"
+)
+
+// Validate checks the settings every service needs to start and reports all
+// problems at once, so a missing .env doesn't silently run on blank values
+func (c *Config) Validate() error {
+	errs := c.validateDB()
+	errs = append(errs, c.validateRabbitMQ()...)
+
+	sa := c.ServiceArea
+	if sa.MinLat != 0 || sa.MinLng != 0 || sa.MaxLat != 0 || sa.MaxLng != 0 {
+		if sa.MinLat >= sa.MaxLat || sa.MinLng >= sa.MaxLng {
+			errs = append(errs, errors.New("SERVICE_AREA_MIN_LAT/LNG must be below SERVICE_AREA_MAX_LAT/LNG"))
+		}
+	}
+	if sa.MaxRideDistanceKm < 0 {
+		errs = append(errs, errors.New("MAX_RIDE_DISTANCE_KM must not be negative"))
+	}
+	if sa.MinRideDistanceKm < 0 {
+		errs = append(errs, errors.New("MIN_RIDE_DISTANCE_KM must not be negative"))
+	} else if sa.MaxRideDistanceKm > 0 && sa.MinRideDistanceKm >= sa.MaxRideDistanceKm {
+		errs = append(errs, errors.New("MIN_RIDE_DISTANCE_KM must be below MAX_RIDE_DISTANCE_KM"))
+	}
+	errs = append(errs, c.validatePricing()...)
+	if c.Websocket.CompressionLevel < -2 || c.Websocket.CompressionLevel > 9 {
+		errs = append(errs, fmt.Errorf("WS_COMPRESSION_LEVEL must be between -2 and 9, got %d", c.Websocket.CompressionLevel))
+	}
+	switch c.RateLimit.Backend {
+	case RateLimitMemory:
+	case RateLimitRedis:
+		if strings.TrimSpace(c.Redis.Addr) == "" {
+			errs = append(errs, errors.New("REDIS_ADDR is required when RATE_LIMIT_BACKEND is redis"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("RATE_LIMIT_BACKEND must be %q or %q, got %q", RateLimitMemory, RateLimitRedis, c.RateLimit.Backend))
+	}
+	if c.NoShow.WaitS < 0 || c.NoShow.Fee < 0 {
+		errs = append(errs, errors.New("NO_SHOW_WAIT_SECONDS and NO_SHOW_FEE must not be negative"))
+	}
+	if m := c.Matching; m.DefaultSearchRadiusKm <= 0 || m.MaxSearchRadiusKm < m.DefaultSearchRadiusKm {
+		errs = append(errs, errors.New("MATCHING_DEFAULT_SEARCH_RADIUS_KM must be positive and at most MATCHING_MAX_SEARCH_RADIUS_KM"))
+	}
+	if c.Drivers.MinOnlineRating < 0 || c.Drivers.MinOnlineRating > 5 {
+		errs = append(errs, fmt.Errorf("DRIVER_MIN_ONLINE_RATING must be between 0 and 5, got %g", c.Drivers.MinOnlineRating))
+	}
+
+	if strings.TrimSpace(c.Auth.JWTSecret) == "" {
+		errs = append(errs, errors.New("JWT_SECRET_KEY is required"))
+	}
+	for _, p := range []struct {
+		name string
+		port int
+	}{
+		{"SERVICES_RIDE_SERVICE", c.Services.RideService},
+		{"DRIVER_LOCATION_SERVICE", c.Services.DriverLocationService},
+		{"ADMIN_SERVICE", c.Services.AdminService},
+		{"AUTH_SERVICE_PORT", c.Services.AuthService},
+		{"WEBSOCKET_PORT", c.Websocket.Port},
+	} {
+		if err := validatePort(p.name, p.port); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return joinConfigErrors(errs)
+}
+
+// ValidateDB checks only the database settings, for tools such as the migrator
+func (c *Config) ValidateDB() error {
+	return joinConfigErrors(c.validateDB())
+}
+
+func (c *Config) validateDB() []error {
+	var errs []error
+	if strings.TrimSpace(c.DB.Host) == "" {
+		errs = append(errs, errors.New("DB_HOST is required"))
+	}
+	if strings.TrimSpace(c.DB.User) == "" {
+		errs = append(errs, errors.New("DB_USER is required"))
+	}
+	if strings.TrimSpace(c.DB.Database) == "" {
+		errs = append(errs, errors.New("DB_NAME is required"))
+	}
+	if err := validatePort("DB_PORT", c.DB.Port); err != nil {
+		errs = append(errs, err)
+	}
+	if c.DB.ConnectRetries < 1 {
+		errs = append(errs, errors.New("DB_CONNECT_RETRIES must be at least 1"))
+	}
+	if c.DB.ConnectRetryInterval < 0 {
+		errs = append(errs, errors.New("DB_CONNECT_RETRY_INTERVAL_MS must not be negative"))
+	}
+	if c.DB.QueryTimeout < 0 {
+		errs = append(errs, errors.New("DB_QUERY_TIMEOUT_MS must not be negative"))
+	}
+	return errs
+}
+
+func (c *Config) validatePricing() []error {
+	var errs []error
+	if c.Pricing.AverageSpeedKmh <= 0 {
+		errs = append(errs, errors.New("PRICING_AVERAGE_SPEED_KMH must be positive"))
+	}
+	for _, f := range []struct {
+		rideType string
+		rates    pricing.Rates
+	}{
+		{"ECONOMY", c.Pricing.Economy},
+		{"PREMIUM", c.Pricing.Premium},
+		{"LUXURY", c.Pricing.Luxury},
+	} {
+		r := f.rates
+		if r.Base < 0 || r.PerKm < 0 || r.PerMinute < 0 || r.Minimum < 0 {
+			errs = append(errs, fmt.Errorf("PRICING_%s_* fare rates must not be negative", f.rideType))
+		}
+	}
+	return errs
+}
+
+func (c *Config) validateRabbitMQ() []error {
+	var errs []error
+	if strings.TrimSpace(c.RabbitMQ.Host) == "" {
+		errs = append(errs, errors.New("RABBITMQ_HOST is required"))
+	}
+	if err := validatePort("RABBITMQ_PORT", c.RabbitMQ.Port); err != nil {
+		errs = append(errs, err)
+	}
+	if (c.RabbitMQ.CertPath == "") != (c.RabbitMQ.KeyPath == "") {
+		errs = append(errs, errors.New("RABBITMQ_CLIENT_CERT and RABBITMQ_CLIENT_KEY must be set together"))
+	}
+	return errs
+}
+
+func validatePort(name string, port int) error {
+	if port <= 0 || port > 65535 {
+		return fmt.Errorf("%s must be a port between 1 and 65535, got %d", name, port)
+	}
+	return nil
+}
+
+// joinConfigErrors folds the problems into one error listing each on its own line
+func joinConfigErrors(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return fmt.Errorf("invalid configuration:\n  - %s", strings.Join(msgs, "\n  - "))
+}
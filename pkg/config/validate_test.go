@@ -0,0 +1,119 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// validConfig returns a config that passes Validate
+func validConfig() *Config {
+	c := &Config{}
+	c.DB.Host, c.DB.Port, c.DB.User, c.DB.Database = "localhost", 5432, "ridehail_user", "ridehail_db"
+	c.DB.ConnectRetries = 5
+	c.RabbitMQ.Host, c.RabbitMQ.Port = "localhost", 5672
+	c.Websocket.Port = 8080
+	c.Services.RideService, c.Services.DriverLocationService, c.Services.AdminService, c.Services.AuthService = 3000, 3001, 3004, 3005
+	c.Auth.JWTSecret = "secret"
+	c.Pricing.AverageSpeedKmh = 30
+	c.RateLimit.Backend = RateLimitMemory
+	c.Matching.DefaultSearchRadiusKm, c.Matching.MaxSearchRadiusKm = 5, 20
+	return c
+}
+
+func TestValidConfigPasses(t *testing.T) {
+	if err := validConfig().Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
+
+func TestValidateReportsMissingFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(*Config)
+		want   string
+	}{
+		{"db host", func(c *Config) { c.DB.Host = " " }, "DB_HOST is required"},
+		{"db user", func(c *Config) { c.DB.User = "" }, "DB_USER is required"},
+		{"db name", func(c *Config) { c.DB.Database = "" }, "DB_NAME is required"},
+		{"db port", func(c *Config) { c.DB.Port = 0 }, "DB_PORT must be a port"},
+		{"db query timeout", func(c *Config) { c.DB.QueryTimeout = -time.Second }, "DB_QUERY_TIMEOUT_MS must not be negative"},
+		{"rabbitmq host", func(c *Config) { c.RabbitMQ.Host = "" }, "RABBITMQ_HOST is required"},
+		{"jwt secret", func(c *Config) { c.Auth.JWTSecret = "" }, "JWT_SECRET_KEY is required"},
+		{"service port", func(c *Config) { c.Services.RideService = 0 }, "SERVICES_RIDE_SERVICE must be a port"},
+		{"websocket port", func(c *Config) { c.Websocket.Port = 70000 }, "WEBSOCKET_PORT must be a port between 1 and 65535, got 70000"},
+		{"rate limit backend", func(c *Config) { c.RateLimit.Backend = "memcached" }, `RATE_LIMIT_BACKEND must be "memory" or "redis"`},
+		{"redis addr", func(c *Config) { c.RateLimit.Backend = RateLimitRedis }, "REDIS_ADDR is required"},
+		{"ws compression level", func(c *Config) { c.Websocket.CompressionLevel = 10 }, "WS_COMPRESSION_LEVEL must be between -2 and 9, got 10"},
+		{"negative min ride", func(c *Config) { c.ServiceArea.MinRideDistanceKm = -1 }, "MIN_RIDE_DISTANCE_KM must not be negative"},
+		{"negative min online rating", func(c *Config) { c.Drivers.MinOnlineRating = -1 }, "DRIVER_MIN_ONLINE_RATING must be between 0 and 5, got -1"},
+		{"min online rating over 5", func(c *Config) { c.Drivers.MinOnlineRating = 5.5 }, "DRIVER_MIN_ONLINE_RATING must be between 0 and 5, got 5.5"},
+		{"no default search radius", func(c *Config) { c.Matching.DefaultSearchRadiusKm = 0 }, "MATCHING_DEFAULT_SEARCH_RADIUS_KM must be positive"},
+		{"default search radius over max", func(c *Config) {
+			c.Matching.DefaultSearchRadiusKm, c.Matching.MaxSearchRadiusKm = 30, 20
+		}, "at most MATCHING_MAX_SEARCH_RADIUS_KM"},
+		{"min ride over max", func(c *Config) {
+			c.ServiceArea.MinRideDistanceKm, c.ServiceArea.MaxRideDistanceKm = 5, 5
+		}, "MIN_RIDE_DISTANCE_KM must be below MAX_RIDE_DISTANCE_KM"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := validConfig()
+			tt.mutate(c)
+			err := c.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateListsEveryProblem(t *testing.T) {
+	c := validConfig()
+	c.DB.Host = ""
+	c.RabbitMQ.Host = ""
+	c.Auth.JWTSecret = ""
+
+	err := c.Validate()
+	if err == nil {
+		t.Fatal("Validate passed a config missing three settings")
+	}
+	msg := err.Error()
+	if !strings.HasPrefix(msg, "invalid configuration:") || strings.Count(msg, "\n  - ") != 3 {
+		t.Errorf("Validate = %q, want the three problems listed one per line", msg)
+	}
+}
+
+func TestValidateDBIgnoresOtherServices(t *testing.T) {
+	c := validConfig()
+	c.Auth.JWTSecret = ""
+	c.RabbitMQ.Host = ""
+	if err := c.ValidateDB(); err != nil {
+		t.Errorf("ValidateDB = %v, want only database settings checked", err)
+	}
+
+	c.DB.User = ""
+	if err := c.ValidateDB(); err == nil || !strings.Contains(err.Error(), "DB_USER") {
+		t.Errorf("ValidateDB = %v, want DB_USER reported", err)
+	}
+}
+
+func TestLoadedDefaultsNeedOnlyTheJWTSecret(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), ".env")
+	t.Setenv("JWT_SECRET_KEY", "")
+
+	cfg, err := LoadConfig(missing)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	err = cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "JWT_SECRET_KEY is required") {
+		t.Fatalf("Validate = %v, want the missing JWT secret reported", err)
+	}
+
+	cfg.Auth.JWTSecret = "secret"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate with defaults and a secret: %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package db
 import (
 	"context"
 	"fmt"
+	"math/rand/v2"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -10,12 +11,12 @@ import (
 	"ride-hail/pkg/logger"
 )
 
-const (
-	maxRetries    = 5
-	retryInterval = 3 * time.Second
-)
+// Cap on the wait between connection attempts
+const maxRetryBackoff = 30 * time.Second
 
-// NewConnection creates a new PostgreSQL connection pool with retry logic.
+// NewConnection creates a new PostgreSQL connection pool, retrying with
+// exponential backoff and jitter so a service started before Postgres is
+// ready waits for it instead of exiting.
 func NewConnection(cfg *config.Config, log logger.Logger) (*pgxpool.Pool, error) {
 	dsn := fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=disable",
 		cfg.DB.User,
@@ -24,28 +25,75 @@ func NewConnection(cfg *config.Config, log logger.Logger) (*pgxpool.Pool, error)
 		cfg.DB.Port,
 		cfg.DB.Database,
 	)
-	var pool *pgxpool.Pool
-	var err error
+	maxRetries := cfg.DB.ConnectRetries
+	if maxRetries < 1 {
+		maxRetries = 1
+	}
 
 	log.Info("db_connect", "Connecting to database...")
 
-	for i := 0; i < maxRetries; i++ {
-		pool, err = pgxpool.New(context.Background(), dsn)
+	var pool *pgxpool.Pool
+	err := retry(maxRetries, cfg.DB.ConnectRetryInterval, func(attempt int) error {
+		p, err := pgxpool.New(context.Background(), dsn)
 		if err != nil {
-			log.Error("db_connect_failed", fmt.Errorf("failed to connect to database(attempt %d/%d): %w ", i+1, maxRetries, err))
-			time.Sleep(retryInterval)
-			continue
+			log.Error("db_connect_failed", fmt.Errorf("failed to connect to database(attempt %d/%d): %w ", attempt, maxRetries, err))
+			return err
+		}
+		if err := p.Ping(context.Background()); err != nil {
+			log.Error("db_ping_failed", fmt.Errorf("failed to connect to database(attempt %d/%d): %w", attempt, maxRetries, err))
+			p.Close()
+			return err
+		}
+		pool = p
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database after %d attempts: %w", maxRetries, err)
+	}
+
+	log.Info("db_connected_success", "Successfully connected to database")
+	return pool, nil
+}
+
+// retry calls try up to attempts times, numbering the attempts from 1 and
+// waiting retryDelay between them. It returns nil on the first success, or the
+// last error.
+func retry(attempts int, base time.Duration, try func(attempt int) error) error {
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			time.Sleep(retryDelay(base, i))
 		}
-		err = pool.Ping(context.Background())
-		if err == nil {
-			log.Info("db_connected_success", "Successfully connected to database")
-			return pool, nil
+		if err = try(i + 1); err == nil {
+			return nil
 		}
+	}
+	return err
+}
 
-		log.Error("db_ping_failed", fmt.Errorf("failed to connect to database(attempt %d/%d): %w", i+1, maxRetries, err))
-		pool.Close()
-		time.Sleep(retryInterval)
+// retryDelay returns the wait before the given retry: base doubled per attempt,
+// capped at maxRetryBackoff, with up to 50% random jitter so services
+// restarted together don't hit the database in lockstep.
+func retryDelay(base time.Duration, retry int) time.Duration {
+	if base <= 0 {
+		return 0
 	}
+	delay := base
+	for i := 1; i < retry && delay < maxRetryBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryBackoff {
+		delay = maxRetryBackoff
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
 
-	return nil, fmt.Errorf("failed to connect to database after %d attempts: %w", maxRetries, err)
+// WithQueryTimeout bounds a repository call by timeout. Consumers often pass a
+// context without a deadline; a sooner deadline already on ctx still applies,
+// and a timeout of 0 leaves ctx as it is.
+func WithQueryTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
 }
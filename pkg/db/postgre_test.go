@@ -0,0 +1,101 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetrySucceedsAfterTransientFailures(t *testing.T) {
+	refused := errors.New("connection refused")
+	var attempts []int
+	err := retry(5, time.Millisecond, func(attempt int) error {
+		attempts = append(attempts, attempt)
+		if attempt <= 2 {
+			return refused
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if len(attempts) != 3 || attempts[2] != 3 {
+		t.Errorf("attempts = %v, want [1 2 3]", attempts)
+	}
+}
+
+func TestRetryGivesUpWithLastError(t *testing.T) {
+	calls := 0
+	err := retry(3, time.Millisecond, func(attempt int) error {
+		calls++
+		return errors.New("still starting")
+	})
+	if err == nil || err.Error() != "still starting" {
+		t.Errorf("err = %v, want the last attempt's error", err)
+	}
+	if calls != 3 {
+		t.Errorf("%d attempts, want 3", calls)
+	}
+}
+
+func TestRetryDelayBacksOffWithJitter(t *testing.T) {
+	tests := []struct {
+		base  time.Duration
+		retry int
+		max   time.Duration
+	}{
+		{time.Second, 1, time.Second},
+		{time.Second, 2, 2 * time.Second},
+		{time.Second, 4, 8 * time.Second},
+		{time.Second, 10, maxRetryBackoff},
+		{20 * time.Second, 3, maxRetryBackoff},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 50; i++ {
+			got := retryDelay(tt.base, tt.retry)
+			if got < tt.max/2 || got > tt.max {
+				t.Fatalf("retryDelay(%v, %d) = %v, want between %v and %v", tt.base, tt.retry, got, tt.max/2, tt.max)
+			}
+		}
+	}
+	if got := retryDelay(0, 3); got != 0 {
+		t.Errorf("retryDelay without an interval = %v, want 0", got)
+	}
+}
+
+func TestWithQueryTimeoutSetsDeadline(t *testing.T) {
+	ctx, cancel := WithQueryTimeout(context.Background(), time.Minute)
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		t.Fatal("no deadline set")
+	}
+	if left := time.Until(deadline); left <= 0 || left > time.Minute {
+		t.Errorf("deadline in %v, want within a minute", left)
+	}
+}
+
+func TestWithQueryTimeoutZeroLeavesContext(t *testing.T) {
+	parent := context.Background()
+	ctx, cancel := WithQueryTimeout(parent, 0)
+	defer cancel()
+	if ctx != parent {
+		t.Error("a zero timeout wrapped the context")
+	}
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("a zero timeout set a deadline")
+	}
+}
+
+func TestWithQueryTimeoutKeepsSoonerDeadline(t *testing.T) {
+	parent, cancelParent := context.WithTimeout(context.Background(), time.Second)
+	defer cancelParent()
+	want, _ := parent.Deadline()
+
+	ctx, cancel := WithQueryTimeout(parent, time.Hour)
+	defer cancel()
+	if got, _ := ctx.Deadline(); !got.Equal(want) {
+		t.Errorf("deadline = %v, want the caller's %v", got, want)
+	}
+}
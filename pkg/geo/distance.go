@@ -0,0 +1,49 @@
+package geo
+
+import "math"
+
+// EarthRadiusKm is the mean Earth radius used for distance calculations
+const EarthRadiusKm = 6371.0
+
+// HaversineKm returns the great-circle distance between two points in kilometers
+func HaversineKm(lat1, lng1, lat2, lng2 float64) float64 {
+	phi1 := lat1 * math.Pi / 180
+	phi2 := lat2 * math.Pi / 180
+	dPhi := (lat2 - lat1) * math.Pi / 180
+	dLng := (lng2 - lng1) * math.Pi / 180
+
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) + math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * EarthRadiusKm * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// BearingDegrees returns the initial compass bearing from point 1 to point 2,
+// in degrees clockwise from north within [0, 360)
+func BearingDegrees(lat1, lng1, lat2, lng2 float64) float64 {
+	phi1 := lat1 * math.Pi / 180
+	phi2 := lat2 * math.Pi / 180
+	dLng := (lng2 - lng1) * math.Pi / 180
+
+	y := math.Sin(dLng) * math.Cos(phi2)
+	x := math.Cos(phi1)*math.Sin(phi2) - math.Sin(phi1)*math.Cos(phi2)*math.Cos(dLng)
+	return math.Mod(math.Atan2(y, x)*180/math.Pi+360, 360)
+}
+
+// BoundingBox returns a lat/lng box that contains every point within radiusKm of
+// the center. wrapsLng is true when the box crosses a pole or the antimeridian,
+// in which case the longitude bounds should not be used as a filter.
+func BoundingBox(lat, lng, radiusKm float64) (minLat, maxLat, minLng, maxLng float64, wrapsLng bool) {
+	// Angular radius on the same sphere HaversineKm uses, so the box never cuts
+	// off a point the distance check would keep
+	delta := radiusKm / EarthRadiusKm
+	dLat := delta * 180 / math.Pi
+	minLat, maxLat = lat-dLat, lat+dLat
+
+	cosLat := math.Cos(lat * math.Pi / 180)
+	if math.Sin(delta) >= cosLat || minLat < -90 || maxLat > 90 {
+		return minLat, maxLat, -180, 180, true
+	}
+	// Widest longitude reached by the circle
+	dLng := math.Asin(math.Sin(delta)/cosLat) * 180 / math.Pi
+	minLng, maxLng = lng-dLng, lng+dLng
+	return minLat, maxLat, minLng, maxLng, minLng < -180 || maxLng > 180
+}
@@ -0,0 +1,109 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHaversineKm(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lng1, lat2, lng2 float64
+		want                   float64
+	}{
+		{"same point", 43.2389, 76.8897, 43.2389, 76.8897, 0},
+		{"one degree of latitude", 0, 0, 1, 0, 111.19},
+		{"one degree of longitude at the equator", 0, 0, 0, 1, 111.19},
+		{"across the antimeridian", 0, 179.5, 0, -179.5, 111.19},
+		{"Almaty to Astana", 43.2389, 76.8897, 51.1694, 71.4491, 972.3},
+		{"London to Paris", 51.5074, -0.1278, 48.8566, 2.3522, 343.6},
+		{"New York to Los Angeles", 40.7128, -74.0060, 34.0522, -118.2437, 3935.7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := HaversineKm(tt.lat1, tt.lng1, tt.lat2, tt.lng2)
+			if math.Abs(got-tt.want) > 0.5 {
+				t.Errorf("HaversineKm = %.2f, want %.2f", got, tt.want)
+			}
+			if back := HaversineKm(tt.lat2, tt.lng2, tt.lat1, tt.lng1); math.Abs(back-got) > 1e-9 {
+				t.Errorf("distance back = %v, want %v", back, got)
+			}
+		})
+	}
+}
+
+func TestBearingDegrees(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lng1, lat2, lng2 float64
+		want                   float64
+	}{
+		{"due north", 0, 0, 1, 0, 0},
+		{"due east", 0, 0, 0, 1, 90},
+		{"due south", 0, 0, -1, 0, 180},
+		{"due west", 0, 0, 0, -1, 270},
+		{"across the antimeridian", 0, 179.5, 0, -179.5, 90},
+		{"London to Paris", 51.5074, -0.1278, 48.8566, 2.3522, 148.1},
+		{"Almaty to Astana", 43.2389, 76.8897, 51.1694, 71.4491, 337.0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BearingDegrees(tt.lat1, tt.lng1, tt.lat2, tt.lng2)
+			if math.Abs(got-tt.want) > 0.1 {
+				t.Errorf("BearingDegrees = %.2f, want %.1f", got, tt.want)
+			}
+			if got < 0 || got >= 360 {
+				t.Errorf("BearingDegrees = %v, want within [0, 360)", got)
+			}
+		})
+	}
+}
+
+func TestBoundingBoxContainsRadius(t *testing.T) {
+	const lat, lng, radiusKm = 43.2389, 76.8897, 5.0
+	minLat, maxLat, minLng, maxLng, wraps := BoundingBox(lat, lng, radiusKm)
+	if wraps {
+		t.Fatal("box around Almaty reported as wrapping")
+	}
+
+	// Points on the edge of the radius in every direction fall in the box
+	for bearing := 0.0; bearing < 360; bearing += 5 {
+		pLat, pLng := destination(lat, lng, bearing, radiusKm*(1-1e-9))
+		if HaversineKm(lat, lng, pLat, pLng) > radiusKm {
+			t.Fatalf("test point at %v° is outside the radius", bearing)
+		}
+		if pLat < minLat || pLat > maxLat || pLng < minLng || pLng > maxLng {
+			t.Errorf("point at %v° (%f, %f) outside box [%f..%f, %f..%f]", bearing, pLat, pLng, minLat, maxLat, minLng, maxLng)
+		}
+	}
+}
+
+func TestBoundingBoxWraps(t *testing.T) {
+	tests := []struct {
+		name     string
+		lat, lng float64
+	}{
+		{"near the north pole", 89.99, 10},
+		{"near the south pole", -89.99, 10},
+		{"across the antimeridian", 0, 179.99},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, _, _, wraps := BoundingBox(tt.lat, tt.lng, 5); !wraps {
+				t.Error("wrapsLng = false, want the longitude filter skipped")
+			}
+		})
+	}
+}
+
+// destination returns the point distanceKm from lat, lng along bearing
+func destination(lat, lng, bearing, distanceKm float64) (float64, float64) {
+	phi1 := lat * math.Pi / 180
+	lambda1 := lng * math.Pi / 180
+	theta := bearing * math.Pi / 180
+	delta := distanceKm / EarthRadiusKm
+
+	phi2 := math.Asin(math.Sin(phi1)*math.Cos(delta) + math.Cos(phi1)*math.Sin(delta)*math.Cos(theta))
+	lambda2 := lambda1 + math.Atan2(math.Sin(theta)*math.Sin(delta)*math.Cos(phi1), math.Cos(delta)-math.Sin(phi1)*math.Sin(phi2))
+	return phi2 * 180 / math.Pi, lambda2 * 180 / math.Pi
+}
@@ -0,0 +1,17 @@
+// Package geo holds coordinate helpers shared by the services.
+package geo
+
+// ValidLatitude reports whether lat is within [-90, 90]
+func ValidLatitude(lat float64) bool {
+	return lat >= -90 && lat <= 90
+}
+
+// ValidLongitude reports whether lng is within [-180, 180]
+func ValidLongitude(lng float64) bool {
+	return lng >= -180 && lng <= 180
+}
+
+// ValidCoordinates reports whether both latitude and longitude are in range
+func ValidCoordinates(lat, lng float64) bool {
+	return ValidLatitude(lat) && ValidLongitude(lng)
+}
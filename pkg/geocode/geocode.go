@@ -0,0 +1,91 @@
+// Package geocode provides a pluggable reverse geocoding hook used to fill in
+// empty addresses on coordinates.
+package geocode
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+// Geocoder resolves coordinates to a human-readable address.
+type Geocoder interface {
+	ReverseGeocode(lat, lng float64) (string, error)
+}
+
+// Noop is the default Geocoder; it never resolves an address.
+type Noop struct{}
+
+// ReverseGeocode always returns an empty address.
+func (Noop) ReverseGeocode(lat, lng float64) (string, error) {
+	return "", nil
+}
+
+const (
+	// Coordinates are rounded to 4 decimal places (~11m) so nearby points share a cache entry
+	cachePrecision = 1e4
+
+	defaultCacheSize = 10000
+)
+
+// Cache wraps a Geocoder and memoizes results for nearby points.
+type Cache struct {
+	next    Geocoder
+	size    int
+	entries map[string]string
+	mu      sync.Mutex
+}
+
+// NewCache returns a caching Geocoder in front of next.
+func NewCache(next Geocoder) *Cache {
+	return &Cache{
+		next:    next,
+		size:    defaultCacheSize,
+		entries: make(map[string]string),
+	}
+}
+
+// ReverseGeocode returns the cached address for the point or asks the wrapped
+// Geocoder. Empty results and errors are not cached.
+func (c *Cache) ReverseGeocode(lat, lng float64) (string, error) {
+	key := cacheKey(lat, lng)
+
+	c.mu.Lock()
+	address, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok {
+		return address, nil
+	}
+
+	address, err := c.next.ReverseGeocode(lat, lng)
+	if err != nil || address == "" {
+		return address, err
+	}
+
+	c.mu.Lock()
+	if len(c.entries) >= c.size {
+		// Full: start over rather than track recency
+		c.entries = make(map[string]string)
+	}
+	c.entries[key] = address
+	c.mu.Unlock()
+
+	return address, nil
+}
+
+func cacheKey(lat, lng float64) string {
+	return fmt.Sprintf("%.4f,%.4f", math.Round(lat*cachePrecision)/cachePrecision, math.Round(lng*cachePrecision)/cachePrecision)
+}
+
+// FillAddress returns address unchanged if set, otherwise the geocoded address
+// for the point. Lookup failures yield an empty address so saves never fail on them.
+func FillAddress(g Geocoder, address string, lat, lng float64) string {
+	if address != "" || g == nil {
+		return address
+	}
+	resolved, err := g.ReverseGeocode(lat, lng)
+	if err != nil {
+		return ""
+	}
+	return resolved
+}
@@ -0,0 +1,103 @@
+package geocode
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+// fakeGeocoder answers from a fixed table and counts lookups
+type fakeGeocoder struct {
+	mu      sync.Mutex
+	address string
+	err     error
+	calls   int
+}
+
+func (g *fakeGeocoder) ReverseGeocode(lat, lng float64) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.calls++
+	return g.address, g.err
+}
+
+func TestCacheReusesResultForNearbyPoints(t *testing.T) {
+	g := &fakeGeocoder{address: "Abay Ave 10"}
+	c := NewCache(g)
+
+	for _, p := range [][2]float64{
+		{43.23890, 76.88970},
+		{43.23890, 76.88970},
+		{43.23891, 76.88972}, // a metre or two away, same cache cell
+	} {
+		got, err := c.ReverseGeocode(p[0], p[1])
+		if err != nil || got != "Abay Ave 10" {
+			t.Fatalf("ReverseGeocode(%v) = %q, %v", p, got, err)
+		}
+	}
+	if g.calls != 1 {
+		t.Errorf("geocoder called %d times, want 1", g.calls)
+	}
+
+	// ~100m away is a different cell
+	if _, err := c.ReverseGeocode(43.2399, 76.8897); err != nil {
+		t.Fatal(err)
+	}
+	if g.calls != 2 {
+		t.Errorf("geocoder called %d times, want a lookup for the distant point", g.calls)
+	}
+}
+
+func TestCacheSkipsEmptyAndFailedLookups(t *testing.T) {
+	g := &fakeGeocoder{}
+	c := NewCache(g)
+
+	c.ReverseGeocode(43.2389, 76.8897)
+	g.err = errors.New("provider down")
+	if _, err := c.ReverseGeocode(43.2389, 76.8897); err == nil {
+		t.Error("lookup error not returned")
+	}
+	g.address, g.err = "Abay Ave 10", nil
+	got, _ := c.ReverseGeocode(43.2389, 76.8897)
+
+	if got != "Abay Ave 10" || g.calls != 3 {
+		t.Errorf("got %q after %d lookups, want the address on the third", got, g.calls)
+	}
+}
+
+func TestCacheStartsOverWhenFull(t *testing.T) {
+	g := &fakeGeocoder{address: "somewhere"}
+	c := NewCache(g)
+	c.size = 2
+
+	c.ReverseGeocode(43.1, 76.1)
+	c.ReverseGeocode(43.2, 76.2)
+	c.ReverseGeocode(43.3, 76.3)
+	if len(c.entries) != 1 {
+		t.Errorf("cache holds %d entries, want only the newest", len(c.entries))
+	}
+	c.ReverseGeocode(43.1, 76.1)
+	if g.calls != 4 {
+		t.Errorf("geocoder called %d times, want the dropped point looked up again", g.calls)
+	}
+}
+
+func TestFillAddress(t *testing.T) {
+	g := &fakeGeocoder{address: "Abay Ave 10"}
+	if got := FillAddress(g, "Dostyk Ave 5", 43.2, 76.8); got != "Dostyk Ave 5" || g.calls != 0 {
+		t.Errorf("given address: got %q after %d lookups, want it kept without a lookup", got, g.calls)
+	}
+	if got := FillAddress(g, "", 43.2, 76.8); got != "Abay Ave 10" {
+		t.Errorf("empty address: got %q, want the geocoded one", got)
+	}
+	if got := FillAddress(nil, "", 43.2, 76.8); got != "" {
+		t.Errorf("nil geocoder: got %q, want empty", got)
+	}
+	failing := &fakeGeocoder{address: "partial", err: errors.New("timeout")}
+	if got := FillAddress(failing, "", 43.2, 76.8); got != "" {
+		t.Errorf("failed lookup: got %q, want empty", got)
+	}
+	if got := FillAddress(Noop{}, "", 43.2, 76.8); got != "" {
+		t.Errorf("Noop: got %q, want empty", got)
+	}
+}
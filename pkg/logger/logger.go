@@ -15,6 +15,7 @@ type LogLevel string
 const (
 	LevelInfo  LogLevel = "INFO"
 	LevelDebug LogLevel = "DEBUG"
+	LevelWarn  LogLevel = "WARN"
 	LevelError LogLevel = "ERROR"
 )
 
@@ -25,6 +26,7 @@ type Logger interface {
 
 	Info(action, message string)
 	Debug(action, message string)
+	Warn(action, message string)
 	Error(action string, err error)
 }
 
@@ -110,6 +112,11 @@ func (l *jsonLogger) Debug(action, message string) {
 	l.log(LevelDebug, action, message, nil)
 }
 
+// Warn logs an unexpected but handled condition at the WARN level.
+func (l *jsonLogger) Warn(action, message string) {
+	l.log(LevelWarn, action, message, nil)
+}
+
 // Error logs an error, including a stack trace.
 func (l *jsonLogger) Error(action string, err error) {
 	// Capture stack trace
@@ -152,8 +159,8 @@ func (l *jsonLogger) log(level LogLevel, action, message string, errData *errorE
 				entry.RequestID = reqID
 			}
 		default:
-			// Put other fields in the generic 'fields' map
-			entry.Fields[k] = v
+			// Put other fields in the generic 'fields' map, masking credentials
+			entry.Fields[k] = redactValue(k, v)
 		}
 	}
 
@@ -0,0 +1,92 @@
+package logger
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Redacted replaces the value of a sensitive field in log output
+const Redacted = "[REDACTED]"
+
+// sensitiveKeyParts mark a field as sensitive when its lower-cased name contains
+// any of them, e.g. "password", "new_password", "access_token", "Authorization"
+var sensitiveKeyParts = []string{"password", "token", "secret", "authorization", "cookie", "api_key", "api-key"}
+
+// isSensitiveKey reports whether values logged under key must be masked
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, part := range sensitiveKeyParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactFields returns a copy of fields with sensitive values masked, including
+// inside nested maps and slices. Every log entry goes through it.
+func RedactFields(fields LogFields) LogFields {
+	if fields == nil {
+		return nil
+	}
+	out := make(LogFields, len(fields))
+	for k, v := range fields {
+		out[k] = redactValue(k, v)
+	}
+	return out
+}
+
+// MaskEmail keeps enough of an email to correlate log lines without logging the
+// address: "jane.doe@example.com" becomes "j***@example.com"
+func MaskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 1 {
+		return Redacted
+	}
+	return email[:1] + "***" + email[at:]
+}
+
+func redactValue(key string, v interface{}) interface{} {
+	if key != "" && isSensitiveKey(key) {
+		return Redacted
+	}
+	switch val := v.(type) {
+	case LogFields:
+		return RedactFields(val)
+	case map[string]interface{}:
+		return map[string]interface{}(RedactFields(val))
+	case map[string]string:
+		out := make(map[string]string, len(val))
+		for k, s := range val {
+			if isSensitiveKey(k) {
+				s = Redacted
+			}
+			out[k] = s
+		}
+		return out
+	case http.Header:
+		return redactHeader(val)
+	case map[string][]string:
+		return redactHeader(val)
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = redactValue("", item)
+		}
+		return out
+	}
+	return v
+}
+
+// redactHeader masks whole header values, so an "Authorization: Bearer ..."
+// never reaches the log whatever the header name's case
+func redactHeader(h map[string][]string) map[string][]string {
+	out := make(map[string][]string, len(h))
+	for k, vs := range h {
+		if isSensitiveKey(k) {
+			vs = []string{Redacted}
+		}
+		out[k] = vs
+	}
+	return out
+}
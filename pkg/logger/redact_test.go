@@ -0,0 +1,167 @@
+package logger
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+// loginBody is what a client posts to /auth/login
+const loginBody = `{"email":"jane.doe@example.com","password":"hunter2"}`
+
+// captureLogger returns a logger writing to a file and a func reading back the
+// entries written so far
+func captureLogger(t *testing.T) (Logger, func() []logEntry) {
+	t.Helper()
+	f, err := os.CreateTemp(t.TempDir(), "log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	l := &jsonLogger{out: f, service: "test", hostname: "host", baseFields: make(LogFields)}
+	return l, func() []logEntry {
+		t.Helper()
+		data, err := os.ReadFile(f.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var entries []logEntry
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			var e logEntry
+			if err := json.Unmarshal([]byte(line), &e); err != nil {
+				t.Fatalf("decode %q: %v", line, err)
+			}
+			entries = append(entries, e)
+		}
+		return entries
+	}
+}
+
+func TestLoggedLoginPayloadHasPasswordMasked(t *testing.T) {
+	log, entries := captureLogger(t)
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal([]byte(loginBody), &payload); err != nil {
+		t.Fatal(err)
+	}
+	log.WithFields(LogFields{"payload": payload}).Info("login_request", "Login attempt")
+
+	got := entries()
+	if len(got) != 1 {
+		t.Fatalf("wrote %d entries, want 1", len(got))
+	}
+	raw, _ := json.Marshal(got[0])
+	if strings.Contains(string(raw), "hunter2") {
+		t.Fatalf("log entry %s contains the password", raw)
+	}
+	fields := got[0].Fields
+	if p, _ := fields["payload"].(map[string]interface{}); p["password"] != Redacted || p["email"] != "jane.doe@example.com" {
+		t.Errorf("payload = %v, want the password masked and the email kept", fields["payload"])
+	}
+}
+
+func TestSensitiveFieldsAreMaskedWhenWritten(t *testing.T) {
+	log, entries := captureLogger(t)
+
+	log.WithFields(LogFields{
+		"ride_id":       "ride-1",
+		"Authorization": "Bearer abc.def",
+		"access_token":  "abc.def",
+		"headers":       map[string]string{"Cookie": "session=1", "Accept": "*/*"},
+		"attempts":      []interface{}{map[string]interface{}{"new_password": "x", "user": "u1"}},
+	}).Error("request_failed", os.ErrPermission)
+
+	e := entries()[0]
+	if e.RideID != "ride-1" {
+		t.Errorf("ride_id = %q, want ride-1", e.RideID)
+	}
+	if e.Fields["Authorization"] != Redacted || e.Fields["access_token"] != Redacted {
+		t.Errorf("fields = %v, want the credentials masked", e.Fields)
+	}
+	if h, _ := e.Fields["headers"].(map[string]interface{}); h["Cookie"] != Redacted || h["Accept"] != "*/*" {
+		t.Errorf("headers = %v, want only Cookie masked", e.Fields["headers"])
+	}
+	attempts, _ := e.Fields["attempts"].([]interface{})
+	if len(attempts) != 1 {
+		t.Fatalf("attempts = %v", e.Fields["attempts"])
+	}
+	if a, _ := attempts[0].(map[string]interface{}); a["new_password"] != Redacted || a["user"] != "u1" {
+		t.Errorf("attempt = %v, want new_password masked", attempts[0])
+	}
+}
+
+func TestLoggedRequestHeadersHaveCredentialsMasked(t *testing.T) {
+	log, entries := captureLogger(t)
+
+	req := http.Header{}
+	req.Set("Authorization", "Bearer abc.def")
+	req.Set("Content-Type", "application/json")
+	log.WithFields(LogFields{
+		"headers":  req,
+		"upstream": map[string][]string{"authorization": {"Bearer ghi.jkl"}, "x-api-key": {"k1"}},
+	}).Info("request_received", "Request received")
+
+	e := entries()[0]
+	raw, _ := json.Marshal(e)
+	for _, secret := range []string{"abc.def", "ghi.jkl", "k1"} {
+		if strings.Contains(string(raw), secret) {
+			t.Fatalf("log entry %s contains %q", raw, secret)
+		}
+	}
+	h, _ := e.Fields["headers"].(map[string]interface{})
+	if auth, _ := h["Authorization"].([]interface{}); len(auth) != 1 || auth[0] != Redacted {
+		t.Errorf("Authorization = %v, want it masked", h["Authorization"])
+	}
+	if ct, _ := h["Content-Type"].([]interface{}); len(ct) != 1 || ct[0] != "application/json" {
+		t.Errorf("Content-Type = %v, want it kept", h["Content-Type"])
+	}
+	u, _ := e.Fields["upstream"].(map[string]interface{})
+	if auth, _ := u["authorization"].([]interface{}); len(auth) != 1 || auth[0] != Redacted {
+		t.Errorf("authorization = %v, want it masked", u["authorization"])
+	}
+}
+
+func TestSensitiveKeys(t *testing.T) {
+	for _, key := range []string{"password", "new_password", "Password", "token", "refresh_token", "client_secret", "Authorization", "Set-Cookie", "api_key", "X-Api-Key"} {
+		if !isSensitiveKey(key) {
+			t.Errorf("isSensitiveKey(%q) = false, want true", key)
+		}
+	}
+	for _, key := range []string{"email", "ride_id", "driver_id", "status", "Content-Type"} {
+		if isSensitiveKey(key) {
+			t.Errorf("isSensitiveKey(%q) = true, want false", key)
+		}
+	}
+}
+
+func TestRedactFieldsLeavesInputAlone(t *testing.T) {
+	fields := LogFields{"password": "hunter2", "email": "a@b.c"}
+
+	got := RedactFields(fields)
+	if got["password"] != Redacted || got["email"] != "a@b.c" {
+		t.Errorf("RedactFields = %v, want the password masked", got)
+	}
+	if fields["password"] != "hunter2" {
+		t.Error("RedactFields changed the caller's map")
+	}
+	if RedactFields(nil) != nil {
+		t.Error("RedactFields(nil) is not nil")
+	}
+}
+
+func TestMaskEmail(t *testing.T) {
+	tests := []struct{ email, want string }{
+		{"jane.doe@example.com", "j***@example.com"},
+		{"a@b.kz", "a***@b.kz"},
+		{"no-at-sign", Redacted},
+		{"@example.com", Redacted},
+		{"", Redacted},
+	}
+	for _, tt := range tests {
+		if got := MaskEmail(tt.email); got != tt.want {
+			t.Errorf("MaskEmail(%q) = %q, want %q", tt.email, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// Package middleware holds the HTTP middleware shared by every service:
+// panic recovery, access logging, CORS and JWT authentication.
+package middleware
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"ride-hail/pkg/auth"
+	"ride-hail/pkg/logger"
+)
+
+// Middleware wraps an http.Handler
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps h in mws; the first middleware is the outermost
+func Chain(h http.Handler, mws ...Middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// Recovery turns a panic in a handler into a logged 500 instead of a dropped connection
+func Recovery(log logger.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				if rec == http.ErrAbortHandler {
+					// Deliberate abort; let net/http handle it quietly
+					panic(rec)
+				}
+				log.WithFields(logger.LogFields{
+					"method": r.Method,
+					"path":   r.URL.Path,
+					"stack":  string(debug.Stack()),
+				}).Error("http_panic", fmt.Errorf("panic: %v", rec))
+				writeError(w, http.StatusInternalServerError, "internal server error")
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Logging writes one access log entry per request with its status and duration
+func Logging(log logger.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			log.WithFields(logger.LogFields{
+				"method":      r.Method,
+				"path":        r.URL.Path,
+				"status":      status,
+				"duration_ms": time.Since(start).Milliseconds(),
+				"remote_addr": r.RemoteAddr,
+			}).Info("http_request", r.Method+" "+r.URL.Path)
+		})
+	}
+}
+
+// CORS allows cross-origin browser requests from origins and answers preflights.
+// No origins, or "*", allows any origin.
+func CORS(origins ...string) Middleware {
+	allowAll := len(origins) == 0
+	allowed := make(map[string]bool, len(origins))
+	for _, o := range origins {
+		if o == "*" {
+			allowAll = true
+		}
+		allowed[strings.TrimRight(o, "/")] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			switch {
+			case allowAll:
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			case allowed[origin]:
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Add("Vary", "Origin")
+			}
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+			// Handle preflight requests
+			if r.Method == http.MethodOptions {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Auth rejects requests without a valid bearer token and puts its claims in the
+// request context (see auth.GetClaims)
+func Auth(jwt *auth.JWTManager) Middleware {
+	return jwt.AuthMiddleware
+}
+
+// statusRecorder captures the response status for Logging. It passes Flush and
+// Hijack through so SSE streams and WebSocket upgrades keep working.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	// A hijacked connection is handed over as a protocol switch
+	r.status = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"error":   http.StatusText(status),
+		"message": msg,
+	})
+}
@@ -0,0 +1,218 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"ride-hail/pkg/logger"
+)
+
+// entry is one call to a recordingLogger
+type entry struct {
+	level, action string
+	fields        logger.LogFields
+}
+
+// recordingLogger keeps every entry logged through it or a WithFields copy
+type recordingLogger struct {
+	mu      *sync.Mutex
+	entries *[]entry
+	fields  logger.LogFields
+}
+
+func newRecordingLogger() recordingLogger {
+	return recordingLogger{mu: &sync.Mutex{}, entries: &[]entry{}}
+}
+
+func (l recordingLogger) WithFields(fields logger.LogFields) logger.Logger {
+	merged := make(logger.LogFields, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return recordingLogger{mu: l.mu, entries: l.entries, fields: merged}
+}
+
+func (l recordingLogger) log(level, action string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	*l.entries = append(*l.entries, entry{level, action, l.fields})
+}
+
+func (l recordingLogger) Info(action, message string)    { l.log("INFO", action) }
+func (l recordingLogger) Debug(action, message string)   { l.log("DEBUG", action) }
+func (l recordingLogger) Warn(action, message string)    { l.log("WARN", action) }
+func (l recordingLogger) Error(action string, err error) { l.log("ERROR", action) }
+
+// find returns the first entry logged with action
+func (l recordingLogger) find(action string) (entry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, e := range *l.entries {
+		if e.action == action {
+			return e, true
+		}
+	}
+	return entry{}, false
+}
+
+func TestRecoveryTurnsPanicIntoLogged500(t *testing.T) {
+	log := newRecordingLogger()
+	h := Recovery(log)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rides map[string]int
+		rides["ride-1"]++ // nil map write
+	}))
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/rides/ride-1")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", resp.StatusCode)
+	}
+	var body map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body["message"] != "internal server error" {
+		t.Errorf("body = %v, want a generic internal error", body)
+	}
+
+	e, ok := log.find("http_panic")
+	if !ok {
+		t.Fatal("panic was not logged")
+	}
+	if e.level != "ERROR" || e.fields["path"] != "/rides/ride-1" || e.fields["method"] != http.MethodGet {
+		t.Errorf("logged %+v, want an error naming the request", e)
+	}
+	if stack, _ := e.fields["stack"].(string); !strings.Contains(stack, "middleware_test.go") {
+		t.Errorf("stack does not reach the panicking handler:\n%s", stack)
+	}
+
+	// The server is still serving after the panic
+	again, err := http.Get(srv.URL + "/rides/ride-1")
+	if err != nil {
+		t.Fatalf("second GET: %v", err)
+	}
+	again.Body.Close()
+}
+
+func TestRecoveryLetsAbortHandlerThrough(t *testing.T) {
+	log := newRecordingLogger()
+	h := Recovery(log)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if rec := recover(); rec != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler re-raised", rec)
+		}
+		if _, ok := log.find("http_panic"); ok {
+			t.Error("deliberate abort was logged as a panic")
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestLoggingRecordsStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    int
+	}{
+		{"explicit status", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTeapot) }, http.StatusTeapot},
+		{"implicit 200", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) }, http.StatusOK},
+		{"no write", func(w http.ResponseWriter, r *http.Request) {}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := newRecordingLogger()
+			rec := httptest.NewRecorder()
+			Logging(log)(tt.handler).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/rides", nil))
+
+			e, ok := log.find("http_request")
+			if !ok {
+				t.Fatal("request was not logged")
+			}
+			if e.fields["status"] != tt.want || e.fields["path"] != "/rides" {
+				t.Errorf("logged %v, want status %d for /rides", e.fields, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoggingKeepsFlusher(t *testing.T) {
+	rec := httptest.NewRecorder()
+	h := Logging(newRecordingLogger())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, ok := w.(http.Flusher)
+		if !ok {
+			t.Fatal("wrapped writer is not a Flusher")
+		}
+		f.Flush()
+	}))
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rides/ride-1/stream", nil))
+	if !rec.Flushed {
+		t.Error("Flush did not reach the underlying writer")
+	}
+}
+
+func TestCORS(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	tests := []struct {
+		name       string
+		origins    []string
+		method     string
+		origin     string
+		wantAllow  string
+		wantStatus int
+	}{
+		{"any origin by default", nil, http.MethodGet, "https://app.example", "*", http.StatusNoContent},
+		{"allowlisted origin echoed", []string{"https://app.example/"}, http.MethodGet, "https://app.example", "https://app.example", http.StatusNoContent},
+		{"other origin not allowed", []string{"https://app.example"}, http.MethodGet, "https://evil.example", "", http.StatusNoContent},
+		{"preflight answered", []string{"https://app.example"}, http.MethodOptions, "https://app.example", "https://app.example", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/rides", nil)
+			req.Header.Set("Origin", tt.origin)
+			rec := httptest.NewRecorder()
+			CORS(tt.origins...)(next).ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllow {
+				t.Errorf("Allow-Origin = %q, want %q", got, tt.wantAllow)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestChainRunsFirstMiddlewareOutermost(t *testing.T) {
+	var order []string
+	mark := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	}), mark("a"), mark("b"))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := strings.Join(order, ","); got != "a,b,handler" {
+		t.Errorf("order = %s, want a,b,handler", got)
+	}
+}
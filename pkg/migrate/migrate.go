@@ -0,0 +1,188 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"ride-hail/pkg/logger"
+)
+
+// lockID is the Postgres advisory lock key that serializes concurrent migration runs
+const lockID = 727274
+
+// initdbMigrations are the migrations docker-compose applies through the
+// Postgres init scripts, with a query reporting whether each one already ran.
+// A database created that way has their tables but no schema_migrations rows.
+// Each query may rely on the tables of the ones before it.
+var initdbMigrations = []struct {
+	version string
+	applied string
+}{
+	{"01_ride_service", `SELECT to_regclass('public.rides') IS NOT NULL`},
+	{"02_driver_location_service", `SELECT to_regclass('public.driver_sessions') IS NOT NULL`},
+	{"03_mock_data", `SELECT EXISTS (SELECT 1 FROM users WHERE id = '11111111-1111-1111-1111-111111111111')`},
+}
+
+// Migrator applies ordered SQL migration files and records them in schema_migrations.
+// Each file is executed as-is, so files should wrap their statements in begin/commit.
+type Migrator struct {
+	pool *pgxpool.Pool
+	dir  string
+	log  logger.Logger
+}
+
+// New creates a migrator reading *.sql files from dir
+func New(pool *pgxpool.Pool, dir string, log logger.Logger) *Migrator {
+	return &Migrator{
+		pool: pool,
+		dir:  dir,
+		log:  log,
+	}
+}
+
+// Up applies every migration that has not been recorded yet, in filename order.
+// It returns the versions applied during this run.
+func (m *Migrator) Up(ctx context.Context) ([]string, error) {
+	files, err := m.migrationFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", lockID); err != nil {
+		return nil, fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", lockID)
+
+	if err := m.enablePostGIS(ctx, conn); err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version text primary key,
+			applied_at timestamptz not null default now()
+		)
+	`); err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	applied := make(map[string]bool)
+	rows, err := conn.Query(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("query applied migrations: %w", err)
+	}
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan migration version: %w", err)
+		}
+		applied[version] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate applied migrations: %w", err)
+	}
+	if err := m.baselineInitdb(ctx, conn, applied); err != nil {
+		return nil, err
+	}
+
+	var done []string
+	for _, file := range files {
+		version := strings.TrimSuffix(filepath.Base(file), ".sql")
+		if applied[version] {
+			continue
+		}
+
+		body, err := os.ReadFile(file)
+		if err != nil {
+			return done, fmt.Errorf("read migration %s: %w", version, err)
+		}
+
+		log := m.log.WithFields(logger.LogFields{"version": version})
+		log.Info("migration_applying", "Applying migration")
+
+		if _, err := conn.Exec(ctx, string(body)); err != nil {
+			return done, fmt.Errorf("apply migration %s: %w", version, err)
+		}
+		if _, err := conn.Exec(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, version); err != nil {
+			return done, fmt.Errorf("record migration %s: %w", version, err)
+		}
+
+		log.Info("migration_applied", "Migration applied")
+		done = append(done, version)
+	}
+
+	return done, nil
+}
+
+// enablePostGIS creates the postgis extension when the server ships it. None of
+// the migrations need it: without it the driver service matches drivers with
+// its haversine fallback (DB_POSTGIS_FALLBACK=true).
+func (m *Migrator) enablePostGIS(ctx context.Context, conn *pgxpool.Conn) error {
+	var available bool
+	if err := conn.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = 'postgis')`,
+	).Scan(&available); err != nil {
+		return fmt.Errorf("check postgis: %w", err)
+	}
+	if !available {
+		m.log.Warn("postgis_unavailable", "PostGIS is not installed; set DB_POSTGIS_FALLBACK=true for the driver service")
+		return nil
+	}
+	if _, err := conn.Exec(ctx, `CREATE EXTENSION IF NOT EXISTS postgis`); err != nil {
+		return fmt.Errorf("enable postgis: %w", err)
+	}
+	return nil
+}
+
+// baselineInitdb records the init-script migrations as applied when their
+// tables already exist, so a database created by docker-compose is not
+// migrated twice. applied is updated in place.
+func (m *Migrator) baselineInitdb(ctx context.Context, conn *pgxpool.Conn, applied map[string]bool) error {
+	for _, mig := range initdbMigrations {
+		if applied[mig.version] {
+			continue
+		}
+		var exists bool
+		if err := conn.QueryRow(ctx, mig.applied).Scan(&exists); err != nil {
+			return fmt.Errorf("check migration %s: %w", mig.version, err)
+		}
+		if !exists {
+			// The init scripts run in order, so nothing after a missing one ran either
+			break
+		}
+		if _, err := conn.Exec(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, mig.version); err != nil {
+			return fmt.Errorf("record migration %s: %w", mig.version, err)
+		}
+		m.log.WithFields(logger.LogFields{"version": mig.version}).
+			Info("migration_baselined", "Schema already present, recorded migration as applied")
+		applied[mig.version] = true
+	}
+	return nil
+}
+
+// migrationFiles lists the *.sql files in the migration directory sorted by name
+func (m *Migrator) migrationFiles() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(m.dir, "*.sql"))
+	if err != nil {
+		return nil, fmt.Errorf("list migrations: %w", err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no migrations found in %s", m.dir)
+	}
+	sort.Strings(files)
+	return files, nil
+}
@@ -0,0 +1,184 @@
+package migrate_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"ride-hail/pkg/dbtest"
+	"ride-hail/pkg/migrate"
+)
+
+func TestUpWithoutMigrations(t *testing.T) {
+	// Fails before touching the database
+	if _, err := migrate.New(nil, t.TempDir(), dbtest.Logger{}).Up(context.Background()); err == nil {
+		t.Fatal("expected an error for a directory without migrations")
+	}
+}
+
+func TestUpAppliesRepositoryMigrationsOnce(t *testing.T) {
+	pool := dbtest.EmptyPool(t)
+	ctx := context.Background()
+	m := migrate.New(pool, dbtest.MigrationsDir(t), dbtest.Logger{})
+
+	applied, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("first Up: %v", err)
+	}
+	want := migrationVersions(t, dbtest.MigrationsDir(t))
+	if !reflect.DeepEqual(applied, want) {
+		t.Errorf("applied %v, want every migration in order %v", applied, want)
+	}
+	if got := recordedVersions(t, pool); !reflect.DeepEqual(got, want) {
+		t.Errorf("schema_migrations = %v, want %v", got, want)
+	}
+
+	again, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("second Up: %v", err)
+	}
+	if len(again) != 0 {
+		t.Errorf("second Up applied %v, want nothing", again)
+	}
+
+	for _, table := range []string{"users", "drivers", "rides", "coordinates", "location_history", "driver_sessions", "ride_events"} {
+		var exists bool
+		if err := pool.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, "public."+table).Scan(&exists); err != nil {
+			t.Fatal(err)
+		}
+		if !exists {
+			t.Errorf("table %s missing after migrating", table)
+		}
+	}
+}
+
+func TestUpAppliesOnlyNewFiles(t *testing.T) {
+	pool := dbtest.EmptyPool(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	writeMigration(t, dir, "01_widgets", `CREATE TABLE widgets (id int primary key);`)
+	writeMigration(t, dir, "02_widget_rows", `INSERT INTO widgets VALUES (1);`)
+
+	m := migrate.New(pool, dir, dbtest.Logger{})
+	if applied, err := m.Up(ctx); err != nil || !reflect.DeepEqual(applied, []string{"01_widgets", "02_widget_rows"}) {
+		t.Fatalf("first Up = %v, %v", applied, err)
+	}
+
+	// Re-running 01 or 02 would fail or insert a duplicate row
+	writeMigration(t, dir, "03_more_rows", `INSERT INTO widgets VALUES (2);`)
+	applied, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("second Up: %v", err)
+	}
+	if !reflect.DeepEqual(applied, []string{"03_more_rows"}) {
+		t.Errorf("second Up applied %v, want only the new file", applied)
+	}
+	var rows int
+	if err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM widgets`).Scan(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if rows != 2 {
+		t.Errorf("widgets has %d rows, want 2", rows)
+	}
+}
+
+func TestUpStopsAtFailingMigration(t *testing.T) {
+	pool := dbtest.EmptyPool(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	writeMigration(t, dir, "01_ok", `CREATE TABLE ok (id int);`)
+	writeMigration(t, dir, "02_broken", `CREATE TABLE broken (id nonexistent_type);`)
+	writeMigration(t, dir, "03_after", `CREATE TABLE after_broken (id int);`)
+
+	applied, err := migrate.New(pool, dir, dbtest.Logger{}).Up(ctx)
+	if err == nil || !strings.Contains(err.Error(), "02_broken") {
+		t.Fatalf("err = %v, want the broken migration named", err)
+	}
+	if !reflect.DeepEqual(applied, []string{"01_ok"}) {
+		t.Errorf("applied %v, want only 01_ok", applied)
+	}
+	if got := recordedVersions(t, pool); !reflect.DeepEqual(got, []string{"01_ok"}) {
+		t.Errorf("schema_migrations = %v, want only 01_ok recorded", got)
+	}
+}
+
+func TestUpBaselinesInitScriptDatabase(t *testing.T) {
+	pool := dbtest.EmptyPool(t)
+	ctx := context.Background()
+	dir := dbtest.MigrationsDir(t)
+
+	// What docker-compose's init scripts leave behind: the first migrations' tables
+	// and no schema_migrations
+	for _, version := range []string{"01_ride_service", "02_driver_location_service", "03_mock_data"} {
+		body, err := os.ReadFile(filepath.Join(dir, version+".sql"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := pool.Exec(ctx, string(body)); err != nil {
+			t.Fatalf("init script %s: %v", version, err)
+		}
+	}
+
+	applied, err := migrate.New(pool, dir, dbtest.Logger{}).Up(ctx)
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	for _, version := range applied {
+		if version <= "03_mock_data" {
+			t.Errorf("re-applied init script migration %s", version)
+		}
+	}
+	if got, want := recordedVersions(t, pool), migrationVersions(t, dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("schema_migrations = %v, want %v", got, want)
+	}
+}
+
+func writeMigration(t *testing.T, dir, version, body string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, version+".sql"), []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// migrationVersions lists the versions of the *.sql files in dir, sorted
+func migrationVersions(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versions []string
+	for _, f := range files {
+		versions = append(versions, strings.TrimSuffix(filepath.Base(f), ".sql"))
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// recordedVersions lists schema_migrations, sorted
+func recordedVersions(t *testing.T, pool *pgxpool.Pool) []string {
+	t.Helper()
+	rows, err := pool.Query(context.Background(), `SELECT version FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		t.Fatalf("query schema_migrations: %v", err)
+	}
+	defer rows.Close()
+	var versions []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			t.Fatal(err)
+		}
+		versions = append(versions, v)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return versions
+}
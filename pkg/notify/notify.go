@@ -0,0 +1,27 @@
+// Package notify provides a pluggable hook for reaching users whose app is not
+// connected, e.g. through a push or SMS provider.
+package notify
+
+import "context"
+
+// Payload is one notification for a user's device
+type Payload struct {
+	Type  string                 // Same as the WebSocket message type, e.g. "ride_matched"
+	Title string                 // Short headline for the notification
+	Body  string                 // Text shown to the user
+	Data  map[string]interface{} // Machine-readable fields for the app, e.g. ride_id
+}
+
+// Notifier delivers a notification to a user's devices outside the WebSocket.
+// Implementations should give up when ctx is done.
+type Notifier interface {
+	SendPush(ctx context.Context, userID string, payload Payload) error
+}
+
+// Noop is the default Notifier; it sends nothing.
+type Noop struct{}
+
+// SendPush does nothing.
+func (Noop) SendPush(ctx context.Context, userID string, payload Payload) error {
+	return nil
+}
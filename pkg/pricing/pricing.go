@@ -0,0 +1,91 @@
+// Package pricing holds the fare rate table. The ride service quotes estimates
+// from it and the driver service prices completed rides from it, so both must
+// be built from the same table.
+package pricing
+
+import (
+	"fmt"
+	"math"
+)
+
+// DefaultAverageSpeedKmh converts trip distance into minutes for per-minute pricing
+const DefaultAverageSpeedKmh = 30.0
+
+// FallbackRideType is priced for ride types the table has no rates for
+const FallbackRideType = "ECONOMY"
+
+// Rates is the pricing for one ride type
+type Rates struct {
+	Base      float64 // Flat amount charged on every ride
+	PerKm     float64 // Charged per kilometer of trip distance
+	PerMinute float64 // Charged per estimated minute of trip time
+	Minimum   float64 // Floor on the total fare, 0 = no floor
+}
+
+// IsZero reports whether the rates charge nothing at all
+func (r Rates) IsZero() bool {
+	return r.Base == 0 && r.PerKm == 0 && r.PerMinute == 0 && r.Minimum == 0
+}
+
+// DefaultRates returns the built-in pricing per ride type, in currency units
+func DefaultRates() map[string]Rates {
+	return map[string]Rates{
+		"ECONOMY": {Base: 100, PerKm: 15},
+		"PREMIUM": {Base: 150, PerKm: 25},
+		"LUXURY":  {Base: 250, PerKm: 40},
+	}
+}
+
+// Table prices trips by ride type and distance
+type Table struct {
+	rates           map[string]Rates
+	averageSpeedKmh float64
+}
+
+// NewTable returns a table over rates, keyed by ride type. A non-positive
+// averageSpeedKmh falls back to DefaultAverageSpeedKmh.
+func NewTable(rates map[string]Rates, averageSpeedKmh float64) (*Table, error) {
+	copied := make(map[string]Rates, len(rates))
+	for rideType, r := range rates {
+		if r.Base < 0 || r.PerKm < 0 || r.PerMinute < 0 || r.Minimum < 0 {
+			return nil, fmt.Errorf("fare rates for %s must not be negative", rideType)
+		}
+		copied[rideType] = r
+	}
+	if averageSpeedKmh <= 0 {
+		averageSpeedKmh = DefaultAverageSpeedKmh
+	}
+	return &Table{rates: copied, averageSpeedKmh: averageSpeedKmh}, nil
+}
+
+// DefaultTable returns a table over DefaultRates at DefaultAverageSpeedKmh
+func DefaultTable() *Table {
+	return &Table{rates: DefaultRates(), averageSpeedKmh: DefaultAverageSpeedKmh}
+}
+
+// Priced reports whether rideType has rates of its own that charge something
+func (t *Table) Priced(rideType string) bool {
+	r, ok := t.rates[rideType]
+	return ok && !r.IsZero()
+}
+
+// RatesFor returns the rates for rideType, or FallbackRideType's when it has
+// none; ok is false when neither is in the table
+func (t *Table) RatesFor(rideType string) (Rates, bool) {
+	if r, ok := t.rates[rideType]; ok {
+		return r, true
+	}
+	r, ok := t.rates[FallbackRideType]
+	return r, ok
+}
+
+// Fare prices a trip of distanceKm with rates, never below their minimum
+func (t *Table) Fare(rates Rates, distanceKm float64) float64 {
+	fare := rates.Base + distanceKm*rates.PerKm + t.EstimateMinutes(distanceKm)*rates.PerMinute
+	return math.Max(fare, rates.Minimum)
+}
+
+// EstimateMinutes estimates trip time at the table's average speed
+func (t *Table) EstimateMinutes(distanceKm float64) float64 {
+	return distanceKm / t.averageSpeedKmh * 60
+}
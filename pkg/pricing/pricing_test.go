@@ -0,0 +1,68 @@
+package pricing
+
+import (
+	"math"
+	"testing"
+)
+
+func assertFare(t *testing.T, what string, got, want float64) {
+	t.Helper()
+	if math.Abs(got-want) > 1e-9 {
+		t.Errorf("%s = %v, want %v", what, got, want)
+	}
+}
+
+func TestFareScalesWithPerKmRate(t *testing.T) {
+	table := DefaultTable()
+	base := Rates{Base: 100, PerKm: 15}
+	doubled := Rates{Base: 100, PerKm: 30}
+
+	for _, km := range []float64{1, 5, 12.5} {
+		single := table.Fare(base, km) - base.Base
+		double := table.Fare(doubled, km) - doubled.Base
+		assertFare(t, "distance charge", single, 15*km)
+		assertFare(t, "doubled distance charge", double, 2*single)
+	}
+}
+
+func TestFareAppliesMinimum(t *testing.T) {
+	table := DefaultTable()
+	rates := Rates{Base: 100, PerKm: 15, Minimum: 500}
+
+	assertFare(t, "short trip", table.Fare(rates, 2), 500)
+	assertFare(t, "long trip", table.Fare(rates, 30), 100+30*15)
+	assertFare(t, "no floor", table.Fare(Rates{Base: 100, PerKm: 15}, 2), 130)
+}
+
+func TestFareChargesEstimatedMinutes(t *testing.T) {
+	table, err := NewTable(map[string]Rates{"ECONOMY": {PerMinute: 10}}, 60)
+	if err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+	rates, _ := table.RatesFor("ECONOMY")
+
+	// 15 km at 60 km/h is 15 minutes
+	assertFare(t, "minutes", table.EstimateMinutes(15), 15)
+	assertFare(t, "fare", table.Fare(rates, 15), 150)
+}
+
+func TestNewTable(t *testing.T) {
+	if _, err := NewTable(map[string]Rates{"LUXURY": {Base: 250, PerKm: -1}}, 30); err == nil {
+		t.Error("negative per-km rate accepted")
+	}
+
+	table, err := NewTable(map[string]Rates{"ECONOMY": {Base: 100}, "PREMIUM": {}}, 0)
+	if err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+	assertFare(t, "default speed minutes", table.EstimateMinutes(DefaultAverageSpeedKmh), 60)
+	if table.Priced("PREMIUM") {
+		t.Error("PREMIUM with zero rates is reported as priced")
+	}
+	if table.Priced("LUXURY") {
+		t.Error("LUXURY without rates is reported as priced")
+	}
+	if rates, ok := table.RatesFor("LUXURY"); !ok || rates.Base != 100 {
+		t.Errorf("RatesFor(LUXURY) = %+v, %v; want the ECONOMY fallback", rates, ok)
+	}
+}
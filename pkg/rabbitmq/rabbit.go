@@ -2,7 +2,16 @@ package rabbitmq
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,6 +24,9 @@ import (
 const (
 	maxRetries    = 10
 	retryInterval = 3 * time.Second
+
+	// CheckTopology reuses a VerifyTopology result for this long
+	topologyCheckInterval = 5 * time.Second
 )
 
 // Connection is a wrapper around the amqp.Connection that handles auto-reconnection.
@@ -22,26 +34,96 @@ type Connection struct {
 	logger      logger.Logger
 	config      *config.Config
 	dsn         string
+	tlsConfig   *tls.Config // nil unless RabbitMQ.UseTLS
 	conn        *amqp.Connection
 	pubChannel  *amqp.Channel // A dedicated channel for publishing
 	mu          sync.RWMutex  // Protects conn and pubChannel during reconnects
 	isConnected bool
 	notifyClose chan *amqp.Error
 	done        chan bool // Signals graceful shutdown
+
+	// Names this process's own queues; see DeclareInstanceQueue
+	instanceID     string
+	instanceQueues []instanceQueue
+	instanceMu     sync.Mutex
+
+	// First delay before Settle retries a delivery, 0 = defaultRetryDelay
+	retryBase time.Duration
+
+	// The last VerifyTopology result and when CheckTopology took it
+	topologyMu        sync.Mutex
+	topologyCheckedAt time.Time
+	topologyErr       error
+}
+
+// instanceQueue is a queue only this process consumes. It is exclusive and
+// auto-deleted, so the broker drops it with the connection and SetupTopology
+// declares it again after a reconnect.
+type instanceQueue struct {
+	Name        string
+	Exchange    string
+	RoutingKeys []string
+}
+
+// buildDSN builds the broker URL: amqps:// when TLS is enabled, with the
+// credentials and vhost escaped.
+func buildDSN(cfg *config.Config) string {
+	scheme := "amqp"
+	if cfg.RabbitMQ.UseTLS {
+		scheme = "amqps"
+	}
+	u := url.URL{
+		Scheme:  scheme,
+		User:    url.UserPassword(cfg.RabbitMQ.User, cfg.RabbitMQ.Password),
+		Host:    net.JoinHostPort(cfg.RabbitMQ.Host, strconv.Itoa(cfg.RabbitMQ.Port)),
+		Path:    "/" + cfg.RabbitMQ.VHost,
+		RawPath: "/" + url.PathEscape(cfg.RabbitMQ.VHost),
+	}
+	return u.String()
+}
+
+// buildTLSConfig loads the optional CA bundle and client certificate.
+func buildTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	tlsCfg := &tls.Config{
+		ServerName: cfg.RabbitMQ.Host,
+		MinVersion: tls.VersionTLS12,
+	}
+	if cfg.RabbitMQ.CACertPath != "" {
+		caPEM, err := os.ReadFile(cfg.RabbitMQ.CACertPath)
+		if err != nil {
+			return nil, fmt.Errorf("read CA cert: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.RabbitMQ.CACertPath)
+		}
+		tlsCfg.RootCAs = pool
+	}
+	if cfg.RabbitMQ.CertPath != "" || cfg.RabbitMQ.KeyPath != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.RabbitMQ.CertPath, cfg.RabbitMQ.KeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("load client cert: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	return tlsCfg, nil
 }
 
 func NewConnection(cfg *config.Config, log logger.Logger) (*Connection, error) {
-	dsn := fmt.Sprintf("amqp://%s:%s@%s:%d/",
-		cfg.RabbitMQ.User,
-		cfg.RabbitMQ.Password,
-		cfg.RabbitMQ.Host,
-		cfg.RabbitMQ.Port,
-	)
 	c := &Connection{
 		logger: log,
 		config: cfg,
-		dsn:    dsn,
+		dsn:    buildDSN(cfg),
 		done:   make(chan bool),
+
+		instanceID: newMessageID()[:12],
+	}
+	if cfg.RabbitMQ.UseTLS {
+		tlsCfg, err := buildTLSConfig(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure RabbitMQ TLS: %w", err)
+		}
+		c.tlsConfig = tlsCfg
 	}
 	var err error
 	for i := 0; i < maxRetries; i++ {
@@ -67,7 +149,11 @@ func (c *Connection) connect() error {
 	defer c.mu.Unlock()
 
 	var err error
-	c.conn, err = amqp.Dial(c.dsn)
+	if c.tlsConfig != nil {
+		c.conn, err = amqp.DialTLS(c.dsn, c.tlsConfig)
+	} else {
+		c.conn, err = amqp.Dial(c.dsn)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to dial: %w", err)
 	}
@@ -103,18 +189,23 @@ func (c *Connection) reconnectLoop() {
 				for {
 					c.logger.Info("rabbitmq_reconnect_attempt", fmt.Sprintf("Attempting to reconnect in %s...", backoff))
 					time.Sleep(backoff)
+					next := time.Duration(float64(backoff) * 1.5)
+					if next > 30*time.Second {
+						next = 30 * time.Second
+					}
 
 					if err := c.connect(); err != nil {
 						c.logger.Error("rabbitmq_reconnect_failed", fmt.Errorf("failed to reconnect to RabbitMQ: %w", err))
-						backoff = time.Duration(float64(backoff) * 1.5)
-						if backoff > 30*time.Second {
-							backoff = 30 * time.Second
-						}
+						backoff = next
 						continue
 					}
 
 					if setupErr := c.SetupTopology(); setupErr != nil {
 						c.logger.Error("rabbitmq_reconnect_setup_failed", fmt.Errorf("failed to re-declare topology to RabbitMQ: %w", setupErr))
+						// Drop the half-ready connection so consumers don't attach before the
+						// queues exist, and so the next attempt doesn't leak it
+						c.dropConnection()
+						backoff = next
 						continue
 					}
 					c.logger.Info("rabbitmq_reconnect_success", "RabbitMQ connection established")
@@ -129,6 +220,61 @@ func (c *Connection) reconnectLoop() {
 	}
 }
 
+// dropConnection closes the current connection without stopping the reconnect loop
+func (c *Connection) dropConnection() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.isConnected = false
+	if c.pubChannel != nil {
+		c.pubChannel.Close()
+	}
+	if c.conn != nil {
+		c.conn.Close()
+	}
+}
+
+// retiredQueues are no longer part of the topology and are removed on setup.
+// They either had no consumer or were replaced by per-instance queues.
+var retiredQueues = []string{"ride_requests", "location_updates_ride"}
+
+// topologyExchanges are the durable exchanges every service expects
+var topologyExchanges = []struct {
+	Name string
+	Type string
+}{
+	{Name: "ride_topic", Type: "topic"},
+	{Name: "driver_topic", Type: "topic"},
+	{Name: "location_fanout", Type: "fanout"},
+	{Name: deadLetterExchange, Type: "fanout"},
+}
+
+// topologyQueues all have a consumer: driver_matching and ride_status in the
+// driver service, driver_responses and driver_status in the ride service.
+// ride.request.* is only matched by drivers. Chat (ride.message.*), destination
+// changes (ride.destination.*), admin control (driver.control.*) and
+// location_fanout go to per-instance queues; see DeclareInstanceQueue.
+// dead_letters has no consumer; it holds the deliveries Settle gave up on for inspection.
+var topologyQueues = []string{
+	"ride_status",
+	"driver_matching",
+	"driver_responses",
+	"driver_status",
+	deadLetterQueue,
+}
+
+// topologyBindings route each queue to its exchange
+var topologyBindings = []struct {
+	Queue      string
+	RoutingKey string
+	Exchange   string
+}{
+	{"ride_status", "ride.status.*", "ride_topic"},
+	{"driver_matching", "ride.request.*", "ride_topic"},
+	{"driver_responses", "driver.response.*", "driver_topic"},
+	{"driver_status", "driver.status.*", "driver_topic"},
+	{deadLetterQueue, "", deadLetterExchange},
+}
+
 // SetupTopology declares all required topology.
 func (c *Connection) SetupTopology() error {
 	c.mu.RLock()
@@ -146,54 +292,158 @@ func (c *Connection) SetupTopology() error {
 
 	c.logger.Info("rabbitmq_setup", "Declaring RabbitMQ topology")
 
-	exchanges := []struct {
-		Name string
-		Type string
-	}{
-		{Name: "ride_topic", Type: "topic"},
-		{Name: "driver_topic", Type: "topic"},
-		{Name: "location_fanout", Type: "fanout"},
-	}
-	for _, ex := range exchanges {
+	for _, ex := range topologyExchanges {
 		if err := ch.ExchangeDeclare(ex.Name, ex.Type, true, false, false, false, nil); err != nil {
 			return fmt.Errorf("failed to declare exchange %s: %w", ex.Name, err)
 		}
 	}
-
-	queues := []string{
-		"ride_requests",
-		"ride_status",
-		"driver_matching",
-		"driver_responses",
-		"driver_status",
-		"location_updates_ride",
-	}
-	for _, queue := range queues {
+	for _, queue := range topologyQueues {
 		if _, err := ch.QueueDeclare(queue, true, false, false, false, nil); err != nil {
 			return fmt.Errorf("failed to declare queue %s: %w", queue, err)
 		}
 	}
-	bindings := []struct {
-		Queue      string
-		RoutingKey string
-		Exchange   string
-	}{
-		{"ride_requests", "ride.request.*", "ride_topic"},
-		{"ride_status", "ride.status.*", "ride_topic"},
-		{"driver_matching", "ride.request.*", "ride_topic"},
-		{"driver_responses", "driver.response.*", "driver_topic"},
-		{"driver_status", "driver.status.*", "driver_topic"},
-		{"location_updates_ride", "", "location_fanout"}, // No routing key for fanout
-	}
-	for _, b := range bindings {
+	for _, b := range topologyBindings {
 		if err := ch.QueueBind(b.Queue, b.RoutingKey, b.Exchange, false, nil); err != nil {
 			return fmt.Errorf("failed to bind queue %s to %s: %w", b.Queue, b.Exchange, err)
 		}
 	}
+
+	// Queues earlier versions declared; drop them so they stop collecting
+	// copies of messages on existing brokers
+	for _, queue := range retiredQueues {
+		if _, err := ch.QueueDelete(queue, false, false, false); err != nil {
+			return fmt.Errorf("failed to delete retired queue %s: %w", queue, err)
+		}
+	}
+	for _, q := range c.ownQueues() {
+		if err := declareInstanceQueue(ch, q); err != nil {
+			return err
+		}
+	}
 	c.logger.Info("rabbitmq_setup_success", "Successfully declared RabbitMQ topology")
 	return nil
 }
 
+// CheckTopology is VerifyTopology for health probes: it runs the check at most
+// once per topologyCheckInterval and returns the last result in between, so
+// frequent probes don't open a channel per exchange and queue each time.
+// Concurrent callers wait for the one check in flight.
+func (c *Connection) CheckTopology() error {
+	c.topologyMu.Lock()
+	defer c.topologyMu.Unlock()
+	if !c.topologyCheckedAt.IsZero() && time.Since(c.topologyCheckedAt) < topologyCheckInterval {
+		return c.topologyErr
+	}
+	c.topologyErr = c.VerifyTopology()
+	c.topologyCheckedAt = time.Now()
+	return c.topologyErr
+}
+
+// VerifyTopology checks with passive declares that every expected exchange and
+// queue exists with the declared type and flags, without creating anything, and
+// reports all mismatches at once. AMQP has no passive form of queue.bind, so
+// bindings are not checked. A failed passive declare closes its channel, so each
+// check runs on a fresh one. The connection lock is only held to read the
+// connection, so a slow check doesn't hold up a reconnect.
+func (c *Connection) VerifyTopology() error {
+	c.mu.RLock()
+	conn, connected := c.conn, c.isConnected
+	c.mu.RUnlock()
+	if !connected || conn == nil {
+		return fmt.Errorf("RabbitMQ is not connected")
+	}
+
+	var problems []string
+	check := func(what string, declare func(ch *amqp.Channel) error) {
+		ch, err := conn.Channel()
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: open channel: %v", what, err))
+			return
+		}
+		defer ch.Close()
+		if err := declare(ch); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", what, err))
+		}
+	}
+	for _, ex := range topologyExchanges {
+		check("exchange "+ex.Name, func(ch *amqp.Channel) error {
+			return ch.ExchangeDeclarePassive(ex.Name, ex.Type, true, false, false, false, nil)
+		})
+	}
+	for _, queue := range topologyQueues {
+		check("queue "+queue, func(ch *amqp.Channel) error {
+			_, err := ch.QueueDeclarePassive(queue, true, false, false, false, nil)
+			return err
+		})
+	}
+	for _, q := range c.ownQueues() {
+		check("queue "+q.Name, func(ch *amqp.Channel) error {
+			_, err := ch.QueueDeclarePassive(q.Name, false, true, true, false, nil)
+			return err
+		})
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("RabbitMQ topology drift: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// DeclareInstanceQueue declares a queue of this process only, named
+// base.<instance id>, and binds it to exchange with each routing key (none for
+// a fanout exchange). Every running instance then gets its own copy of those
+// messages, which suits deliveries only the instance holding a user's WebSocket
+// can make. It returns the queue name to consume.
+func (c *Connection) DeclareInstanceQueue(base, exchange string, routingKeys ...string) (string, error) {
+	q := instanceQueue{
+		Name:        base + "." + c.instanceID,
+		Exchange:    exchange,
+		RoutingKeys: routingKeys,
+	}
+
+	c.mu.RLock()
+	if !c.isConnected {
+		c.mu.RUnlock()
+		return "", fmt.Errorf("RabbitMQ does not connected")
+	}
+	ch, err := c.conn.Channel()
+	c.mu.RUnlock()
+	if err != nil {
+		return "", fmt.Errorf("failed to open setup channel: %w", err)
+	}
+	defer ch.Close()
+
+	if err := declareInstanceQueue(ch, q); err != nil {
+		return "", err
+	}
+	c.instanceMu.Lock()
+	c.instanceQueues = append(c.instanceQueues, q)
+	c.instanceMu.Unlock()
+	return q.Name, nil
+}
+
+func declareInstanceQueue(ch *amqp.Channel, q instanceQueue) error {
+	if _, err := ch.QueueDeclare(q.Name, false, true, true, false, nil); err != nil {
+		return fmt.Errorf("failed to declare queue %s: %w", q.Name, err)
+	}
+	keys := q.RoutingKeys
+	if len(keys) == 0 {
+		keys = []string{""}
+	}
+	for _, key := range keys {
+		if err := ch.QueueBind(q.Name, key, q.Exchange, false, nil); err != nil {
+			return fmt.Errorf("failed to bind queue %s to %s: %w", q.Name, q.Exchange, err)
+		}
+	}
+	return nil
+}
+
+func (c *Connection) ownQueues() []instanceQueue {
+	c.instanceMu.Lock()
+	defer c.instanceMu.Unlock()
+	return append([]instanceQueue(nil), c.instanceQueues...)
+}
+
 // Publish sends a message to an exchange. It is goroutine-safe.
 func (c *Connection) Publish(ctx context.Context, exchange, routingkey string, body []byte) error {
 	c.mu.RLock()
@@ -207,19 +457,42 @@ func (c *Connection) Publish(ctx context.Context, exchange, routingkey string, b
 		Body:         body,
 		DeliveryMode: amqp.Persistent,
 		Timestamp:    time.Now(),
+		MessageId:    newMessageID(),
 	}
 	return c.pubChannel.Publish(exchange, routingkey, false, false, msg)
 }
 
+// newMessageID generates a random id so consumers can detect redelivered messages.
+func newMessageID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
 // Consume starts a consumer on a specific queue.
 // The handler function is executed for each message.
 // This method handles its own reconnection loop for the consumer.
 func (c *Connection) Consume(queueName string, handler func(amqp.Delivery)) error {
+	_, err := c.ConsumeContext(context.Background(), queueName, handler)
+	return err
+}
+
+// ConsumeContext is Consume that also stops when ctx is cancelled. On cancel the
+// consumer stops taking deliveries and waits for in-flight handlers before it
+// closes its channel, so their acks still go through. The returned channel is
+// closed once the consumer has fully stopped.
+func (c *Connection) ConsumeContext(ctx context.Context, queueName string, handler func(amqp.Delivery)) (<-chan struct{}, error) {
 	log := c.logger.WithFields(logger.LogFields{"queue": queueName})
 	log.Info("consumer_start", "Starting consumer goroutine")
 
+	stopped := make(chan struct{})
 	go func() {
+		defer close(stopped)
+		var inflight sync.WaitGroup
 		for {
+			if ctx.Err() != nil {
+				return
+			}
 			c.mu.RLock() // Read lock to check connection status
 			if !c.isConnected {
 				c.mu.RUnlock()
@@ -239,9 +512,10 @@ func (c *Connection) Consume(queueName string, handler func(amqp.Delivery)) erro
 			c.mu.RUnlock() // Unlock after getting channel
 
 			// Start consuming
+			consumerTag := "ctag-" + newMessageID()
 			msgs, err := ch.Consume(
 				queueName,
-				"",    // consumer tag
+				consumerTag,
 				false, // auto-ack (false = manual ack)
 				false, // exclusive
 				false, // no-local
@@ -269,6 +543,13 @@ func (c *Connection) Consume(queueName string, handler func(amqp.Delivery)) erro
 					ch.Close()
 					return // Exit goroutine
 
+				case <-ctx.Done():
+					log.Info("consumer_stop", "Consumer cancelled, draining in-flight messages")
+					ch.Cancel(consumerTag, false)
+					inflight.Wait()
+					ch.Close()
+					return
+
 				case err := <-notifyChanClose:
 					log.Error("consumer_channel_closed", fmt.Errorf("consumer channel closed: %v", err))
 					break consumerLoop // Exit loop to reconnect
@@ -280,12 +561,16 @@ func (c *Connection) Consume(queueName string, handler func(amqp.Delivery)) erro
 					}
 					// Run the handler in a new goroutine so one slow message
 					// doesn't block all other messages on this channel.
-					go handler(msg)
+					inflight.Add(1)
+					go func() {
+						defer inflight.Done()
+						handler(msg)
+					}()
 				}
 			}
 		}
 	}()
-	return nil
+	return stopped, nil
 }
 
 // Close gracefully shuts down the connection and the reconnect loop.
@@ -0,0 +1,223 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"ride-hail/pkg/logger"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Dead-lettered deliveries are republished here with the failure recorded in headers
+const (
+	deadLetterExchange = "dead_letter"
+	deadLetterQueue    = "dead_letters"
+)
+
+const (
+	// retryCountHeader counts how many times a delivery has been retried
+	retryCountHeader = "x-retry-count"
+
+	// A delivery that still fails after this many handler attempts is dead-lettered
+	maxHandlerAttempts = 5
+
+	// The first retry waits defaultRetryDelay; each one after waits twice as long, up to maxRetryDelay
+	defaultRetryDelay = time.Second
+	maxRetryDelay     = 30 * time.Second
+)
+
+// RetryableError marks a handler failure that may pass on redelivery, such as a
+// database timeout; the delivery is retried after a delay
+type RetryableError struct {
+	Err error
+}
+
+func (e *RetryableError) Error() string { return e.Err.Error() }
+func (e *RetryableError) Unwrap() error { return e.Err }
+
+// FatalError marks a handler failure redelivery cannot fix, such as a malformed
+// payload or a ride that does not exist; the delivery is dead-lettered
+type FatalError struct {
+	Err error
+}
+
+func (e *FatalError) Error() string { return e.Err.Error() }
+func (e *FatalError) Unwrap() error { return e.Err }
+
+// Retryable wraps err as a RetryableError; nil stays nil
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &RetryableError{Err: err}
+}
+
+// Fatal wraps err as a FatalError; nil stays nil
+func Fatal(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &FatalError{Err: err}
+}
+
+// IsRetryable reports whether a delivery that failed with err should be retried.
+// Explicitly classified errors decide for themselves; anything else is retryable
+// only if it looks transient (a timeout or a network error), so an unexpected
+// error can't send a message round the queue forever.
+func IsRetryable(err error) bool {
+	var fatal *FatalError
+	var retryable *RetryableError
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return false
+	case errors.As(err, &fatal):
+		return false
+	case errors.As(err, &retryable):
+		return true
+	case errors.Is(err, context.DeadlineExceeded):
+		return true
+	case errors.As(err, &netErr):
+		return true
+	}
+	return false
+}
+
+// Settle acks d when err is nil, retries it when err is retryable and
+// dead-letters it otherwise. A retry republishes d to queue after a growing
+// delay with its attempt count in the x-retry-count header, and d stays
+// unacked until then, so it is redelivered if the process stops first. After
+// maxHandlerAttempts a retryable failure is dead-lettered too. Settle reports
+// whether d will be retried, so consumers that remember handled message ids
+// can forget this one.
+func (c *Connection) Settle(queue string, d amqp.Delivery, err error) bool {
+	if err == nil {
+		d.Ack(false)
+		return false
+	}
+
+	log := c.logger.WithFields(logger.LogFields{
+		"queue":       queue,
+		"message_id":  d.MessageId,
+		"routing_key": d.RoutingKey,
+		"redelivered": d.Redelivered,
+		"error":       err.Error(),
+	})
+	if IsRetryable(err) {
+		attempt := retryCount(d) + 1
+		if attempt < maxHandlerAttempts {
+			delay := c.retryDelay(attempt)
+			log.WithFields(logger.LogFields{
+				"attempt":  attempt,
+				"delay_ms": delay.Milliseconds(),
+			}).Warn("message_retry_scheduled", "Retryable handler error, retrying message after a delay")
+			time.AfterFunc(delay, func() { c.retry(queue, d, attempt) })
+			return true
+		}
+		err = fmt.Errorf("gave up after %d attempts: %w", attempt, err)
+	}
+
+	if dlErr := c.DeadLetter(queue, d, err); dlErr != nil {
+		log.Error("dead_letter_failed", fmt.Errorf("dropping message: %w", dlErr))
+		d.Nack(false, false)
+		return false
+	}
+	log.Warn("message_dead_lettered", "Handler error, message moved to "+deadLetterQueue)
+	d.Ack(false)
+	return false
+}
+
+// DeadLetter republishes d to the dead_letters queue with its original queue,
+// exchange, routing key and the failure reason in headers. It does not settle d.
+func (c *Connection) DeadLetter(queue string, d amqp.Delivery, cause error) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if !c.isConnected {
+		return fmt.Errorf("RabbitMQ does not connected")
+	}
+	msg := amqp.Publishing{
+		Headers: amqp.Table{
+			"x-original-queue":       queue,
+			"x-original-exchange":    d.Exchange,
+			"x-original-routing-key": d.RoutingKey,
+			"x-error":                cause.Error(),
+		},
+		ContentType:  d.ContentType,
+		Body:         d.Body,
+		DeliveryMode: amqp.Persistent,
+		Timestamp:    time.Now(),
+		MessageId:    d.MessageId,
+	}
+	return c.pubChannel.Publish(deadLetterExchange, "", false, false, msg)
+}
+
+// retryCount is how many times d has been retried, from its x-retry-count header
+func retryCount(d amqp.Delivery) int {
+	switch n := d.Headers[retryCountHeader].(type) {
+	case int32:
+		return int(n)
+	case int64:
+		return int(n)
+	case int:
+		return n
+	}
+	return 0
+}
+
+// retryDelay is how long to wait before the given retry of a delivery
+func (c *Connection) retryDelay(attempt int) time.Duration {
+	delay := c.retryBase
+	if delay <= 0 {
+		delay = defaultRetryDelay
+	}
+	for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
+
+// retry republishes d straight to queue through the default exchange with its
+// retry count set to attempt, then acks the original. If the republish fails
+// the original is requeued as it is.
+func (c *Connection) retry(queue string, d amqp.Delivery, attempt int) {
+	log := c.logger.WithFields(logger.LogFields{
+		"queue":      queue,
+		"message_id": d.MessageId,
+		"attempt":    attempt,
+	})
+	if err := c.republish(queue, d, attempt); err != nil {
+		log.Error("message_retry_failed", fmt.Errorf("requeueing message: %w", err))
+		d.Nack(false, true)
+		return
+	}
+	d.Ack(false)
+}
+
+func (c *Connection) republish(queue string, d amqp.Delivery, attempt int) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if !c.isConnected {
+		return fmt.Errorf("RabbitMQ does not connected")
+	}
+	headers := amqp.Table{}
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	headers[retryCountHeader] = int32(attempt)
+	msg := amqp.Publishing{
+		Headers:       headers,
+		ContentType:   d.ContentType,
+		CorrelationId: d.CorrelationId,
+		Body:          d.Body,
+		DeliveryMode:  amqp.Persistent,
+		Timestamp:     d.Timestamp,
+		MessageId:     d.MessageId,
+	}
+	return c.pubChannel.Publish("", queue, false, false, msg)
+}
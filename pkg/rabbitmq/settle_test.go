@@ -0,0 +1,241 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestIsRetryable(t *testing.T) {
+	dial := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"unclassified", errors.New("bad data"), false},
+		{"marked retryable", Retryable(errors.New("lock held")), true},
+		{"marked fatal", Fatal(errors.New("ride not found")), false},
+		{"wrapped retryable", fmt.Errorf("assign: %w", Retryable(errors.New("lock held"))), true},
+		{"fatal wins over retryable", Fatal(Retryable(errors.New("lock held"))), false},
+		{"fatal timeout", Fatal(context.DeadlineExceeded), false},
+		{"db timeout", fmt.Errorf("update ride: %w", context.DeadlineExceeded), true},
+		{"network error", fmt.Errorf("query: %w", dial), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyingNilStaysNil(t *testing.T) {
+	if err := Retryable(nil); err != nil {
+		t.Errorf("Retryable(nil) = %v", err)
+	}
+	if err := Fatal(nil); err != nil {
+		t.Errorf("Fatal(nil) = %v", err)
+	}
+}
+
+func TestClassifiedErrorsKeepTheCause(t *testing.T) {
+	cause := errors.New("ride not found")
+	for _, err := range []error{Retryable(cause), Fatal(cause)} {
+		if !errors.Is(err, cause) || err.Error() != cause.Error() {
+			t.Errorf("%T: Is = %v, Error = %q; want the cause", err, errors.Is(err, cause), err.Error())
+		}
+	}
+}
+
+func TestRetryCountReadsTheHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers amqp.Table
+		want    int
+	}{
+		{"first delivery", nil, 0},
+		{"as published", amqp.Table{retryCountHeader: int32(2)}, 2},
+		{"wider int", amqp.Table{retryCountHeader: int64(3)}, 3},
+		{"not a number", amqp.Table{retryCountHeader: "3"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryCount(amqp.Delivery{Headers: tt.headers}); got != tt.want {
+				t.Errorf("retryCount = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryDelayDoublesUpToTheCap(t *testing.T) {
+	c := &Connection{}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 30 * time.Second, 30 * time.Second}
+	for i, w := range want {
+		if got := c.retryDelay(i + 1); got != w {
+			t.Errorf("retry %d waits %v, want %v", i+1, got, w)
+		}
+	}
+
+	c.retryBase = 10 * time.Millisecond
+	if got := c.retryDelay(3); got != 40*time.Millisecond {
+		t.Errorf("third retry from a 10ms base waits %v, want 40ms", got)
+	}
+}
+
+func TestSettleDeadLettersFatalAndRetriesRetryable(t *testing.T) {
+	c := brokerConnection(t)
+	c.retryBase = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, err := c.conn.Channel()
+	if err != nil {
+		t.Fatalf("Channel: %v", err)
+	}
+	defer ch.Close()
+	if _, err := ch.QueuePurge(deadLetterQueue, false); err != nil {
+		t.Fatalf("QueuePurge: %v", err)
+	}
+
+	queue, err := c.DeclareInstanceQueue("settle_test", "ride_topic", "settle.test")
+	if err != nil {
+		t.Fatalf("DeclareInstanceQueue: %v", err)
+	}
+	// "fatal" fails for good; "retry" fails once and then succeeds
+	type outcome struct {
+		body    string
+		retries int
+	}
+	settled := make(chan outcome, 8)
+	if _, err := c.ConsumeContext(ctx, queue, func(d amqp.Delivery) {
+		var handlerErr error
+		switch {
+		case string(d.Body) == `"fatal"`:
+			handlerErr = Fatal(errors.New("ride not found"))
+		case retryCount(d) == 0:
+			handlerErr = fmt.Errorf("update ride: %w", context.DeadlineExceeded)
+		}
+		c.Settle(queue, d, handlerErr)
+		settled <- outcome{string(d.Body), retryCount(d)}
+	}); err != nil {
+		t.Fatalf("ConsumeContext: %v", err)
+	}
+
+	for _, body := range []string{`"fatal"`, `"retry"`} {
+		if err := c.Publish(ctx, "ride_topic", "settle.test", []byte(body)); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+
+	seen := map[string]int{}
+	deadline := time.After(10 * time.Second)
+	for seen[`"fatal"`] < 1 || seen[`"retry"`] < 2 {
+		select {
+		case o := <-settled:
+			seen[o.body]++
+		case <-deadline:
+			t.Fatalf("deliveries seen %v, want fatal once and retry twice", seen)
+		}
+	}
+
+	var dead amqp.Delivery
+	for {
+		d, ok, err := ch.Get(deadLetterQueue, true)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if ok {
+			dead = d
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("nothing reached the dead letter queue")
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	if string(dead.Body) != `"fatal"` || dead.Headers["x-original-queue"] != queue ||
+		dead.Headers["x-original-routing-key"] != "settle.test" || dead.Headers["x-error"] != "ride not found" {
+		t.Errorf("dead letter %s with headers %v, want the fatal message and where it came from", dead.Body, dead.Headers)
+	}
+	if _, ok, _ := ch.Get(deadLetterQueue, true); ok {
+		t.Error("the retried message was dead-lettered too")
+	}
+	if n := seen[`"fatal"`]; n != 1 {
+		t.Errorf("fatal message delivered %d times, want once", n)
+	}
+}
+
+func TestSettleDeadLettersAfterTooManyAttempts(t *testing.T) {
+	c := brokerConnection(t)
+	c.retryBase = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, err := c.conn.Channel()
+	if err != nil {
+		t.Fatalf("Channel: %v", err)
+	}
+	defer ch.Close()
+	if _, err := ch.QueuePurge(deadLetterQueue, false); err != nil {
+		t.Fatalf("QueuePurge: %v", err)
+	}
+
+	queue, err := c.DeclareInstanceQueue("settle_attempts_test", "ride_topic", "settle.attempts")
+	if err != nil {
+		t.Fatalf("DeclareInstanceQueue: %v", err)
+	}
+	attempts := make(chan int, maxHandlerAttempts+1)
+	if _, err := c.ConsumeContext(ctx, queue, func(d amqp.Delivery) {
+		c.Settle(queue, d, Retryable(errors.New("lock held")))
+		attempts <- retryCount(d)
+	}); err != nil {
+		t.Fatalf("ConsumeContext: %v", err)
+	}
+	if err := c.Publish(ctx, "ride_topic", "settle.attempts", []byte(`"stuck"`)); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	deadline := time.After(10 * time.Second)
+	for want := 0; want < maxHandlerAttempts; want++ {
+		select {
+		case got := <-attempts:
+			if got != want {
+				t.Fatalf("delivery %d carried retry count %d", want+1, got)
+			}
+		case <-deadline:
+			t.Fatalf("only %d of %d attempts made", want, maxHandlerAttempts)
+		}
+	}
+
+	for {
+		d, ok, err := ch.Get(deadLetterQueue, true)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if ok {
+			if string(d.Body) != `"stuck"` || d.Headers["x-error"] != fmt.Sprintf("gave up after %d attempts: lock held", maxHandlerAttempts) {
+				t.Errorf("dead letter %s with headers %v, want the stuck message and why", d.Body, d.Headers)
+			}
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("nothing reached the dead letter queue")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	select {
+	case n := <-attempts:
+		t.Errorf("delivered again with retry count %d after being dead-lettered", n)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -0,0 +1,82 @@
+package rabbitmq
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// queueConsumers names, for each durable topology queue, the source file that
+// consumes it. A queue added to topologyQueues needs an entry here, or in
+// unconsumedQueues if nothing is meant to read it.
+var queueConsumers = map[string]string{
+	"driver_matching":  "internal/driver_location_service/adapter/rabbitmq/consumer.go",
+	"ride_status":      "internal/driver_location_service/adapter/rabbitmq/consumer.go",
+	"driver_responses": "internal/ride-service/infrastructure/consumer/message_consumer.go",
+	"driver_status":    "internal/ride-service/infrastructure/consumer/message_consumer.go",
+}
+
+// unconsumedQueues are declared on purpose without a consumer
+var unconsumedQueues = map[string]bool{
+	deadLetterQueue: true, // holds what Settle gave up on, for inspection
+}
+
+func TestTopologyQueuesHaveAConsumer(t *testing.T) {
+	for _, queue := range topologyQueues {
+		if unconsumedQueues[queue] {
+			continue
+		}
+		file, ok := queueConsumers[queue]
+		if !ok {
+			t.Errorf("queue %s has no known consumer", queue)
+			continue
+		}
+		src, err := os.ReadFile(filepath.Join("..", "..", file))
+		if err != nil {
+			t.Errorf("queue %s: %v", queue, err)
+			continue
+		}
+		if !strings.Contains(string(src), strconv.Quote(queue)) {
+			t.Errorf("queue %s is not consumed in %s", queue, file)
+		}
+	}
+}
+
+func TestTopologyBindsEveryDeclaredQueue(t *testing.T) {
+	declared := make(map[string]bool)
+	for _, queue := range topologyQueues {
+		declared[queue] = true
+	}
+	exchanges := make(map[string]bool)
+	for _, ex := range topologyExchanges {
+		exchanges[ex.Name] = true
+	}
+
+	bound := make(map[string]bool)
+	for _, b := range topologyBindings {
+		if !declared[b.Queue] {
+			t.Errorf("binding for undeclared queue %s", b.Queue)
+		}
+		if !exchanges[b.Exchange] {
+			t.Errorf("queue %s bound to undeclared exchange %s", b.Queue, b.Exchange)
+		}
+		bound[b.Queue] = true
+	}
+	for _, queue := range topologyQueues {
+		if !bound[queue] {
+			t.Errorf("queue %s is declared but never bound", queue)
+		}
+	}
+}
+
+func TestRetiredQueuesAreNotDeclared(t *testing.T) {
+	for _, retired := range retiredQueues {
+		for _, queue := range topologyQueues {
+			if queue == retired {
+				t.Errorf("retired queue %s is still declared", retired)
+			}
+		}
+	}
+}
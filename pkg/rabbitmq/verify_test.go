@@ -0,0 +1,130 @@
+package rabbitmq
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"ride-hail/pkg/config"
+	"ride-hail/pkg/dbtest"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// brokerConnection connects straight to the test broker and declares the topology
+func brokerConnection(t *testing.T) *Connection {
+	t.Helper()
+	url := os.Getenv(brokerURLEnv)
+	if url == "" {
+		t.Skipf("%s not set, skipping broker test", brokerURLEnv)
+	}
+	uri, err := amqp.ParseURI(url)
+	if err != nil {
+		t.Fatalf("parse %s: %v", brokerURLEnv, err)
+	}
+	cfg := &config.Config{}
+	cfg.RabbitMQ.Host = uri.Host
+	cfg.RabbitMQ.Port = uri.Port
+	cfg.RabbitMQ.User = uri.Username
+	cfg.RabbitMQ.Password = uri.Password
+	if uri.Vhost != "/" {
+		cfg.RabbitMQ.VHost = uri.Vhost
+	}
+	c, err := NewConnection(cfg, dbtest.Logger{})
+	if err != nil {
+		t.Fatalf("NewConnection: %v", err)
+	}
+	t.Cleanup(c.Close)
+	if err := c.SetupTopology(); err != nil {
+		t.Fatalf("SetupTopology: %v", err)
+	}
+	return c
+}
+
+// expectQueue adds queue to the expected topology for one test
+func expectQueue(t *testing.T, queue string) {
+	t.Helper()
+	saved := topologyQueues
+	topologyQueues = append(append([]string(nil), saved...), queue)
+	t.Cleanup(func() { topologyQueues = saved })
+}
+
+func TestVerifyTopologyPassesAfterSetup(t *testing.T) {
+	c := brokerConnection(t)
+
+	if err := c.VerifyTopology(); err != nil {
+		t.Errorf("VerifyTopology: %v", err)
+	}
+}
+
+func TestVerifyTopologyReportsAMissingQueue(t *testing.T) {
+	c := brokerConnection(t)
+	expectQueue(t, "verify_test_missing")
+
+	err := c.VerifyTopology()
+	if err == nil {
+		t.Fatal("VerifyTopology passed with a queue missing")
+	}
+	if !strings.Contains(err.Error(), "queue verify_test_missing") {
+		t.Errorf("error %q does not name the missing queue", err)
+	}
+	for _, queue := range topologyQueues[:len(topologyQueues)-1] {
+		if strings.Contains(err.Error(), "queue "+queue+":") {
+			t.Errorf("error %q reports queue %s, which exists", err, queue)
+		}
+	}
+
+	// The failed passive declare must not have created the queue or broken the connection
+	if err := c.VerifyTopology(); err == nil || !strings.Contains(err.Error(), "verify_test_missing") {
+		t.Errorf("second VerifyTopology = %v, want the queue still missing", err)
+	}
+	topologyQueues = topologyQueues[:len(topologyQueues)-1]
+	if err := c.VerifyTopology(); err != nil {
+		t.Errorf("VerifyTopology after the drift check: %v", err)
+	}
+}
+
+func TestVerifyTopologyReportsEveryMismatch(t *testing.T) {
+	c := brokerConnection(t)
+	expectQueue(t, "verify_test_missing_a")
+	expectQueue(t, "verify_test_missing_b")
+
+	err := c.VerifyTopology()
+	if err == nil {
+		t.Fatal("VerifyTopology passed with queues missing")
+	}
+	for _, queue := range []string{"verify_test_missing_a", "verify_test_missing_b"} {
+		if !strings.Contains(err.Error(), "queue "+queue) {
+			t.Errorf("error %q does not name %s", err, queue)
+		}
+	}
+}
+
+func TestVerifyTopologyWhileDisconnected(t *testing.T) {
+	c := &Connection{logger: dbtest.Logger{}}
+
+	if err := c.VerifyTopology(); err == nil {
+		t.Error("VerifyTopology passed without a connection")
+	}
+}
+
+func TestCheckTopologyReusesARecentResult(t *testing.T) {
+	c := &Connection{logger: dbtest.Logger{}}
+
+	first := c.CheckTopology()
+	if first == nil {
+		t.Fatal("CheckTopology passed without a connection")
+	}
+	// VerifyTopology builds a new error each run, so the same one means no recheck
+	if again := c.CheckTopology(); again != first {
+		t.Errorf("second CheckTopology = %v, want the cached %v", again, first)
+	}
+
+	c.topologyMu.Lock()
+	c.topologyCheckedAt = time.Now().Add(-topologyCheckInterval)
+	c.topologyMu.Unlock()
+	if later := c.CheckTopology(); later == first || later == nil {
+		t.Errorf("CheckTopology after the interval = %v, want a fresh failure", later)
+	}
+}
@@ -0,0 +1,100 @@
+// Package ratelimit admits a bounded number of events per key per window,
+// either in process memory or shared across instances through Redis.
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"ride-hail/pkg/clock"
+)
+
+// RateLimiter reports whether an event for key may proceed. Denied events are
+// not counted.
+type RateLimiter interface {
+	// Allow admits at most one event per key per interval
+	Allow(ctx context.Context, key string, interval time.Duration) (bool, error)
+	// AllowN admits at most limit events per key in any window
+	AllowN(ctx context.Context, key string, limit int, window time.Duration) (bool, error)
+}
+
+// Memory is the default RateLimiter. Its state is per process, so replicas
+// behind a load balancer each enforce the limit on their own.
+type Memory struct {
+	events map[string]memoryKey
+	mu     sync.Mutex
+	clock  clock.Clock
+
+	// Keys whose window has passed are dropped at most once per sweepEvery
+	lastSweep time.Time
+}
+
+// memoryKey holds the allowed events of a key still inside its window
+type memoryKey struct {
+	times  []time.Time
+	window time.Duration
+}
+
+// sweepEvery bounds how often Memory scans for expired keys
+const sweepEvery = time.Minute
+
+// NewMemoryRateLimiter returns an in-process RateLimiter.
+func NewMemoryRateLimiter() *Memory {
+	return &Memory{events: make(map[string]memoryKey), clock: clock.New()}
+}
+
+// SetClock replaces the clock windows are measured with, e.g. a clock.Fake in tests
+func (m *Memory) SetClock(c clock.Clock) {
+	m.clock = c
+}
+
+// Allow admits the event if interval has passed since the key's last allowed event.
+func (m *Memory) Allow(ctx context.Context, key string, interval time.Duration) (bool, error) {
+	return m.AllowN(ctx, key, 1, interval)
+}
+
+// AllowN admits the event if fewer than limit events were allowed for the key
+// in the last window.
+func (m *Memory) AllowN(ctx context.Context, key string, limit int, window time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.clock.Now()
+	m.sweep(now)
+
+	k := m.events[key]
+	recent := k.times[:0]
+	for _, t := range k.times {
+		if now.Sub(t) < window {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= limit {
+		m.events[key] = memoryKey{times: recent, window: window}
+		return false, nil
+	}
+	m.events[key] = memoryKey{times: append(recent, now), window: window}
+	return true, nil
+}
+
+// sweep drops keys whose newest event has left its window, so passengers or
+// drivers seen once don't stay in memory for the life of the process
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepEvery {
+		return
+	}
+	m.lastSweep = now
+	for key, k := range m.events {
+		if len(k.times) == 0 || now.Sub(k.times[len(k.times)-1]) >= k.window {
+			delete(m.events, key)
+		}
+	}
+}
+
+// Len returns the number of keys currently tracked
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.events)
+}
@@ -0,0 +1,158 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"ride-hail/pkg/clock"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisURLEnv names the variable holding a test Redis URL, e.g.
+// redis://localhost:6379/15. Redis tests skip without it.
+const redisURLEnv = "TEST_REDIS_URL"
+
+// window is short enough for the Redis backend to wait out in real time
+const window = 300 * time.Millisecond
+
+// backend is a limiter under test and a way to let time pass for it
+type backend struct {
+	limiter RateLimiter
+	advance func(time.Duration)
+}
+
+func memoryBackend(t *testing.T) backend {
+	clk := clock.NewFake(time.Date(2024, 12, 16, 10, 0, 0, 0, time.UTC))
+	m := NewMemoryRateLimiter()
+	m.SetClock(clk)
+	return backend{limiter: m, advance: clk.Advance}
+}
+
+// redisBackend returns a limiter on the test Redis with keys unique to the test,
+// deleted afterwards
+func redisBackend(t *testing.T) backend {
+	t.Helper()
+	url := os.Getenv(redisURLEnv)
+	if url == "" {
+		t.Skipf("%s not set, skipping Redis test", redisURLEnv)
+	}
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		t.Fatalf("parse %s: %v", redisURLEnv, err)
+	}
+	client := redis.NewClient(opts)
+	ctx := context.Background()
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Fatalf("ping Redis: %v", err)
+	}
+	prefix := fmt.Sprintf("ratelimit-test:%s:%d:", t.Name(), time.Now().UnixNano())
+	t.Cleanup(func() {
+		if keys, err := client.Keys(ctx, prefix+"*").Result(); err == nil && len(keys) > 0 {
+			client.Del(ctx, keys...)
+		}
+		client.Close()
+	})
+	return backend{limiter: NewRedisRateLimiter(client, prefix), advance: time.Sleep}
+}
+
+// forEachBackend runs test against every RateLimiter, which must all agree
+func forEachBackend(t *testing.T, test func(t *testing.T, b backend)) {
+	t.Run("memory", func(t *testing.T) { test(t, memoryBackend(t)) })
+	t.Run("redis", func(t *testing.T) { test(t, redisBackend(t)) })
+}
+
+// allow calls AllowN and fails the test on an error
+func allow(t *testing.T, l RateLimiter, key string, limit int) bool {
+	t.Helper()
+	ok, err := l.AllowN(context.Background(), key, limit, window)
+	if err != nil {
+		t.Fatalf("AllowN(%s): %v", key, err)
+	}
+	return ok
+}
+
+func TestAllowAdmitsOnePerInterval(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b backend) {
+		ctx := context.Background()
+		for i, want := range []bool{true, false, false} {
+			ok, err := b.limiter.Allow(ctx, "driver-1", window)
+			if err != nil {
+				t.Fatalf("Allow: %v", err)
+			}
+			if ok != want {
+				t.Errorf("call %d: allowed = %v, want %v", i+1, ok, want)
+			}
+		}
+
+		b.advance(window + 50*time.Millisecond)
+		if ok, _ := b.limiter.Allow(ctx, "driver-1", window); !ok {
+			t.Error("denied after the interval passed")
+		}
+	})
+}
+
+func TestAllowNAdmitsUpToTheLimit(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b backend) {
+		for i := 1; i <= 3; i++ {
+			if !allow(t, b.limiter, "passenger-1", 3) {
+				t.Errorf("event %d of 3 denied", i)
+			}
+		}
+		for i := 0; i < 2; i++ {
+			if allow(t, b.limiter, "passenger-1", 3) {
+				t.Error("event over the limit allowed")
+			}
+		}
+
+		// Denied events don't hold the key past its window
+		b.advance(window + 50*time.Millisecond)
+		if !allow(t, b.limiter, "passenger-1", 3) {
+			t.Error("denied in a new window")
+		}
+	})
+}
+
+func TestKeysAreLimitedSeparately(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b backend) {
+		if !allow(t, b.limiter, "driver-1", 1) {
+			t.Fatal("first event for driver-1 denied")
+		}
+		if !allow(t, b.limiter, "driver-2", 1) {
+			t.Error("driver-2 limited by driver-1's event")
+		}
+		if allow(t, b.limiter, "driver-1", 1) {
+			t.Error("second event for driver-1 allowed")
+		}
+	})
+}
+
+func TestRedisLimitIsSharedAcrossInstances(t *testing.T) {
+	b := redisBackend(t)
+	// A second limiter on the same keys, as another replica would have
+	r := b.limiter.(*Redis)
+	other := NewRedisRateLimiter(r.client, r.prefix)
+
+	if !allow(t, r, "driver-1", 1) {
+		t.Fatal("first event denied")
+	}
+	if allow(t, other, "driver-1", 1) {
+		t.Error("another instance allowed an event over the shared limit")
+	}
+}
+
+func TestMemorySweepsExpiredKeys(t *testing.T) {
+	b := memoryBackend(t)
+	m := b.limiter.(*Memory)
+	allow(t, m, "driver-1", 1)
+	allow(t, m, "driver-2", 1)
+
+	b.advance(sweepEvery)
+	allow(t, m, "driver-3", 1)
+	if n := m.Len(); n != 1 {
+		t.Errorf("%d keys tracked, want only driver-3 after the sweep", n)
+	}
+}
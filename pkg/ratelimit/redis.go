@@ -0,0 +1,46 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis is a RateLimiter shared by every instance using the same Redis server.
+type Redis struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisRateLimiter returns a RateLimiter storing its keys under prefix.
+func NewRedisRateLimiter(client *redis.Client, prefix string) *Redis {
+	return &Redis{client: client, prefix: prefix}
+}
+
+// Allow admits the event if the key has no live marker, setting one that
+// expires after interval. SET NX makes the check and the write atomic.
+func (r *Redis) Allow(ctx context.Context, key string, interval time.Duration) (bool, error) {
+	ok, err := r.client.SetNX(ctx, r.prefix+key, 1, interval).Result()
+	if err != nil {
+		return false, fmt.Errorf("redis rate limit: %w", err)
+	}
+	return ok, nil
+}
+
+// AllowN counts events in fixed windows: the first event creates a counter that
+// expires after window, and the event is admitted while the count is within
+// limit. Both steps run in one MULTI so a counter never outlives its window.
+func (r *Redis) AllowN(ctx context.Context, key string, limit int, window time.Duration) (bool, error) {
+	var count *redis.IntCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SetNX(ctx, r.prefix+key, 0, window)
+		count = pipe.Incr(ctx, r.prefix+key)
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("redis rate limit: %w", err)
+	}
+	return count.Val() <= int64(limit), nil
+}
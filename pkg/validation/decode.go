@@ -0,0 +1,60 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// DecodeErrorMessage describes why a JSON request body failed to decode, for a
+// 400 response: an empty body, malformed JSON (with the byte offset), a field of
+// the wrong type (naming the field) or an unknown field. Anything else gets a
+// generic message.
+func DecodeErrorMessage(err error) string {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, io.EOF):
+		return "Request body is empty"
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "Request body is malformed JSON: unexpected end of input"
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("Request body is malformed JSON at byte %d: %s", syntaxErr.Offset, syntaxErr.Error())
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return fmt.Sprintf("Request body must be %s, got %s", jsonKind(typeErr.Type), typeErr.Value)
+		}
+		return fmt.Sprintf("Field %q must be %s, got %s", typeErr.Field, jsonKind(typeErr.Type), typeErr.Value)
+	}
+	// encoding/json has no type for unknown fields, only the message
+	if i := strings.Index(err.Error(), "json: unknown field "); i >= 0 {
+		return "Unknown field " + strings.TrimPrefix(err.Error()[i:], "json: unknown field ")
+	}
+	return "Invalid request body"
+}
+
+// jsonKind names, with its article, the JSON type a Go type is decoded from
+func jsonKind(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	}
+	return t.String()
+}
@@ -0,0 +1,53 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecodeErrorMessage(t *testing.T) {
+	type point struct {
+		Latitude float64 `json:"latitude"`
+		Count    int     `json:"count"`
+		Tags     []string
+		Nested   struct {
+			Verified bool `json:"verified"`
+		} `json:"nested"`
+	}
+	tests := []struct {
+		name, body, want string
+	}{
+		{"empty body", ``, "Request body is empty"},
+		{"only whitespace", "  \n", "Request body is empty"},
+		{"truncated", `{"latitude": 43.2`, "Request body is malformed JSON: unexpected end of input"},
+		{"syntax error", `{"latitude": 43.2,}`, "Request body is malformed JSON at byte 19: invalid character '}' looking for beginning of object key string"},
+		{"string for a number", `{"latitude": "north"}`, `Field "latitude" must be a number, got string`},
+		{"fraction for an integer", `{"count": 1.5}`, `Field "count" must be an integer, got number 1.5`},
+		{"object for an array", `{"Tags": {}}`, `Field "Tags" must be an array, got object`},
+		{"nested field", `{"nested": {"verified": "yes"}}`, `Field "nested.verified" must be a boolean, got string`},
+		{"array for the body", `[1, 2]`, "Request body must be an object, got array"},
+		{"unknown field", `{"altitude": 800}`, `Unknown field "altitude"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := json.NewDecoder(strings.NewReader(tt.body))
+			dec.DisallowUnknownFields()
+			var p point
+			err := dec.Decode(&p)
+			if err == nil {
+				t.Fatalf("decoded %q without error", tt.body)
+			}
+			if got := DecodeErrorMessage(err); got != tt.want {
+				t.Errorf("DecodeErrorMessage(%v) = %q, want %q", err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeErrorMessageFallsBack(t *testing.T) {
+	if got := DecodeErrorMessage(errors.New("connection reset")); got != "Invalid request body" {
+		t.Errorf("DecodeErrorMessage = %q, want the generic message", got)
+	}
+}
@@ -0,0 +1,35 @@
+// Package validation collects field-level request errors so an API can report
+// every invalid field in one response instead of stopping at the first.
+package validation
+
+import "strings"
+
+// FieldError describes one invalid request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors accumulates the field errors of a request; empty means valid
+type Errors []FieldError
+
+// Add records an error for field
+func (e *Errors) Add(field, message string) {
+	*e = append(*e, FieldError{Field: field, Message: message})
+}
+
+// Check records an error for field unless ok holds
+func (e *Errors) Check(ok bool, field, message string) {
+	if !ok {
+		e.Add(field, message)
+	}
+}
+
+// Error joins the field errors into one line, e.g. "latitude must be between -90 and 90; ..."
+func (e Errors) Error() string {
+	parts := make([]string, len(e))
+	for i, fe := range e {
+		parts[i] = fe.Field + " " + fe.Message
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,40 @@
+package validation
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestErrorsCollectEveryField(t *testing.T) {
+	var errs Errors
+	errs.Check(true, "latitude", "must be between -90 and 90")
+	errs.Check(false, "longitude", "must be between -180 and 180")
+	errs.Add("speed_kmh", "must not be negative")
+
+	if len(errs) != 2 {
+		t.Fatalf("len = %d, want 2: %v", len(errs), errs)
+	}
+	want := "longitude must be between -180 and 180; speed_kmh must not be negative"
+	if got := errs.Error(); got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestErrorsMarshalAsFieldList(t *testing.T) {
+	errs := Errors{{Field: "latitude", Message: "is required"}}
+	b, err := json.Marshal(errs)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if got, want := string(b), `[{"field":"latitude","message":"is required"}]`; got != want {
+		t.Errorf("json = %s, want %s", got, want)
+	}
+}
+
+func TestNoErrorsWhenEveryCheckHolds(t *testing.T) {
+	var errs Errors
+	errs.Check(true, "latitude", "must be between -90 and 90")
+	if len(errs) != 0 {
+		t.Errorf("errs = %v, want none", errs)
+	}
+}
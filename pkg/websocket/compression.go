@@ -0,0 +1,55 @@
+package websocket
+
+import (
+	"compress/flate"
+	"fmt"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// Messages shorter than this are sent uncompressed; deflate framing would
+// cost about as much as it saves on small pings and acks
+const compressionThreshold = 128
+
+// compressionPolicy controls permessage-deflate (RFC 7692) on every endpoint.
+// It is off until SetCompression enables it. Clients that do not offer the
+// extension in the handshake always get uncompressed frames.
+var compressionPolicy = struct {
+	mu      sync.RWMutex
+	enabled bool
+	level   int
+}{level: flate.BestSpeed}
+
+// SetCompression enables or disables permessage-deflate for new connections.
+// level is a compress/flate level from -2 (Huffman only) to 9 (best compression).
+func SetCompression(enabled bool, level int) error {
+	if level < flate.HuffmanOnly || level > flate.BestCompression {
+		return fmt.Errorf("websocket compression level must be between %d and %d, got %d",
+			flate.HuffmanOnly, flate.BestCompression, level)
+	}
+	compressionPolicy.mu.Lock()
+	defer compressionPolicy.mu.Unlock()
+	compressionPolicy.enabled = enabled
+	compressionPolicy.level = level
+	return nil
+}
+
+// currentUpgrader returns the upgrader for a new connection with the
+// compression policy applied
+func currentUpgrader() *websocket.Upgrader {
+	compressionPolicy.mu.RLock()
+	defer compressionPolicy.mu.RUnlock()
+	u := upgrader
+	u.EnableCompression = compressionPolicy.enabled
+	return &u
+}
+
+// applyCompressionLevel sets the configured level on a freshly upgraded connection;
+// it has no effect unless the client negotiated compression
+func applyCompressionLevel(conn *websocket.Conn) {
+	compressionPolicy.mu.RLock()
+	level := compressionPolicy.level
+	compressionPolicy.mu.RUnlock()
+	conn.SetCompressionLevel(level)
+}
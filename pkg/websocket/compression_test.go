@@ -0,0 +1,193 @@
+package websocket
+
+import (
+	"compress/flate"
+	"encoding/json"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"ride-hail/pkg/auth"
+	"ride-hail/pkg/wsmsg"
+)
+
+// setCompression sets the compression policy for one test and turns it off after it
+func setCompression(t *testing.T, enabled bool, level int) {
+	t.Helper()
+	if err := SetCompression(enabled, level); err != nil {
+		t.Fatalf("SetCompression: %v", err)
+	}
+	t.Cleanup(func() { SetCompression(false, flate.BestSpeed) })
+}
+
+// countingConn counts the bytes read off the wire, before any decompression
+type countingConn struct {
+	net.Conn
+	read *atomic.Int64
+}
+
+func (c countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.read.Add(int64(n))
+	return n, err
+}
+
+// echoServer serves driver WebSockets registered with m; every message a
+// client sends is passed to received
+func echoServer(t *testing.T, m *Manager, received chan<- []byte) string {
+	t.Helper()
+	h := NewHandler(nopLogger{}, auth.NewJWTManager(testSecret, time.Hour), func(conn *Connection) {
+		userID := conn.Claims.UserID
+		m.AddConnection(userID, conn)
+		conn.ReadPump(func(_ int, p []byte) { received <- p }, func() { m.ReleaseConnection(userID, conn) })
+	}, auth.RoleDriver)
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+// dialCounting connects as driver d1, offering compression if offer is set, and
+// returns the connection, the negotiated extensions and a count of bytes read
+func dialCounting(t *testing.T, url string, offer bool) (*websocket.Conn, string, *atomic.Int64) {
+	t.Helper()
+	read := &atomic.Int64{}
+	dialer := websocket.Dialer{
+		EnableCompression: offer,
+		NetDial: func(network, addr string) (net.Conn, error) {
+			c, err := net.Dial(network, addr)
+			return countingConn{Conn: c, read: read}, err
+		},
+	}
+	conn, resp, err := dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	tok, err := auth.NewJWTManager(testSecret, time.Hour).GenerateToken("d1", auth.RoleDriver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.WriteJSON(map[string]interface{}{"type": wsmsg.TypeAuth, "message": "Bearer " + tok}); err != nil {
+		t.Fatalf("send auth: %v", err)
+	}
+	return conn, resp.Header.Get("Sec-WebSocket-Extensions"), read
+}
+
+// locationBatch is a large, repetitive payload like a burst of location updates
+func locationBatch() map[string]interface{} {
+	return map[string]interface{}{
+		"type":    "location_batch",
+		"payload": strings.Repeat(`{"latitude":43.238949,"longitude":76.889709},`, 100),
+	}
+}
+
+// receive reads one message from the server and reports how many bytes it took on the wire
+func receive(t *testing.T, conn *websocket.Conn, read *atomic.Int64) (map[string]interface{}, int64) {
+	t.Helper()
+	before := read.Load()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var got map[string]interface{}
+	if err := conn.ReadJSON(&got); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return got, read.Load() - before
+}
+
+func TestCompressionIsNegotiatedAndRoundTrips(t *testing.T) {
+	setCompression(t, true, flate.BestSpeed)
+	m := NewManager(nopLogger{})
+	received := make(chan []byte, 1)
+	url := echoServer(t, m, received)
+
+	conn, extensions, read := dialCounting(t, url, true)
+	if !strings.Contains(extensions, "permessage-deflate") {
+		t.Fatalf("Sec-WebSocket-Extensions = %q, want permessage-deflate", extensions)
+	}
+	waitConnected(t, m, 1)
+
+	// Server to client: decompressed intact, and much smaller on the wire
+	sent := locationBatch()
+	raw, _ := json.Marshal(sent)
+	if err := m.SendToUser("d1", sent); err != nil {
+		t.Fatalf("SendToUser: %v", err)
+	}
+	got, wire := receive(t, conn, read)
+	if got["payload"] != sent["payload"] {
+		t.Errorf("payload changed in transit")
+	}
+	if wire >= int64(len(raw))/2 {
+		t.Errorf("%d-byte message took %d bytes on the wire, want it compressed", len(raw), wire)
+	}
+
+	// Client to server
+	conn.EnableWriteCompression(true)
+	if err := conn.WriteMessage(websocket.TextMessage, raw); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	select {
+	case p := <-received:
+		if string(p) != string(raw) {
+			t.Error("server read a different message than the client sent")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("server never received the compressed message")
+	}
+}
+
+func TestCompressionDisabledSendsPlainFrames(t *testing.T) {
+	setCompression(t, false, flate.BestSpeed)
+	m := NewManager(nopLogger{})
+	url := echoServer(t, m, make(chan []byte, 1))
+
+	conn, extensions, read := dialCounting(t, url, true)
+	if extensions != "" {
+		t.Errorf("Sec-WebSocket-Extensions = %q, want none with compression off", extensions)
+	}
+	waitConnected(t, m, 1)
+
+	sent := locationBatch()
+	raw, _ := json.Marshal(sent)
+	m.SendToUser("d1", sent)
+	got, wire := receive(t, conn, read)
+	if got["payload"] != sent["payload"] || wire < int64(len(raw)) {
+		t.Errorf("got %d bytes on the wire for a %d-byte message, want it sent as is", wire, len(raw))
+	}
+}
+
+func TestClientWithoutCompressionStillConnects(t *testing.T) {
+	setCompression(t, true, flate.BestSpeed)
+	m := NewManager(nopLogger{})
+	url := echoServer(t, m, make(chan []byte, 1))
+
+	conn, extensions, read := dialCounting(t, url, false)
+	if extensions != "" {
+		t.Errorf("Sec-WebSocket-Extensions = %q, want none for a client that didn't offer it", extensions)
+	}
+	waitConnected(t, m, 1)
+
+	sent := locationBatch()
+	m.SendToUser("d1", sent)
+	if got, _ := receive(t, conn, read); got["payload"] != sent["payload"] {
+		t.Error("payload changed in transit")
+	}
+}
+
+func TestSetCompressionRejectsUnknownLevels(t *testing.T) {
+	t.Cleanup(func() { SetCompression(false, flate.BestSpeed) })
+	for _, level := range []int{-3, 10} {
+		if err := SetCompression(true, level); err == nil {
+			t.Errorf("level %d accepted", level)
+		}
+	}
+	for _, level := range []int{flate.HuffmanOnly, flate.DefaultCompression, flate.BestCompression} {
+		if err := SetCompression(true, level); err != nil {
+			t.Errorf("level %d: %v", level, err)
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package websocket
 
 import (
+	"context"
 	"sync"
+	"time"
 
 	"ride-hail/pkg/logger"
 )
@@ -27,8 +29,8 @@ func (m *Manager) AddConnection(userID string, conn *Connection) {
 	defer m.mu.Unlock()
 
 	// Close existing connection if any
-	if existing, ok := m.connections[userID]; ok {
-		existing.Close()
+	if existing, ok := m.connections[userID]; ok && existing != conn {
+		existing.CloseWithReason(CloseReplaced, ReasonReplaced)
 		m.log.WithFields(logger.LogFields{
 			"user_id": userID,
 		}).Info("websocket_replaced", "Replacing existing connection")
@@ -43,11 +45,33 @@ func (m *Manager) AddConnection(userID string, conn *Connection) {
 
 // RemoveConnection removes a connection
 func (m *Manager) RemoveConnection(userID string) {
+	m.Disconnect(userID, CloseSessionEnded, ReasonSessionEnded)
+}
+
+// Disconnect closes a user's connection with the given close code and reason
+// (e.g. CloseBanned) and removes it
+func (m *Manager) Disconnect(userID string, code int, reason string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	if conn, ok := m.connections[userID]; ok {
-		conn.Close()
+		conn.CloseWithReason(code, reason)
+		delete(m.connections, userID)
+		m.log.WithFields(logger.LogFields{
+			"user_id": userID,
+			"total":   len(m.connections),
+			"reason":  reason,
+		}).Info("websocket_disconnected", "Connection removed")
+	}
+}
+
+// ReleaseConnection removes conn if it is still the user's current connection.
+// Disconnect callbacks use this so a replaced connection does not evict its replacement.
+func (m *Manager) ReleaseConnection(userID string, conn *Connection) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if current, ok := m.connections[userID]; ok && current == conn {
 		delete(m.connections, userID)
 		m.log.WithFields(logger.LogFields{
 			"user_id": userID,
@@ -112,3 +136,55 @@ func (m *Manager) IsUserConnected(userID string) bool {
 	_, ok := m.connections[userID]
 	return ok
 }
+
+// ReapIdle removes every connection whose peer has been silent for longer than
+// maxIdle and closes it, returning the affected user IDs. Connections are closed
+// after the lock is released so a slow close doesn't stall sends to everyone else.
+func (m *Manager) ReapIdle(now time.Time, maxIdle time.Duration) []string {
+	m.mu.Lock()
+	var reaped []string
+	var idle []*Connection
+	for userID, conn := range m.connections {
+		if conn.idleFor(now) > maxIdle {
+			delete(m.connections, userID)
+			reaped = append(reaped, userID)
+			idle = append(idle, conn)
+		}
+	}
+	total := len(m.connections)
+	m.mu.Unlock()
+
+	for _, conn := range idle {
+		conn.CloseWithReason(CloseIdle, ReasonIdle)
+	}
+	for _, userID := range reaped {
+		m.log.WithFields(logger.LogFields{
+			"user_id": userID,
+			"total":   total,
+		}).Info("websocket_idle_reaped", "Closed idle connection")
+	}
+	return reaped
+}
+
+// StartIdleSweeper periodically reaps connections that stopped answering pings
+// (e.g. a read pump that never started) until ctx is cancelled. onReap, if set,
+// is called for each removed user.
+func (m *Manager) StartIdleSweeper(ctx context.Context, onReap func(userID string)) {
+	go func() {
+		ticker := time.NewTicker(pongWait)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				for _, userID := range m.ReapIdle(now, idleTimeout) {
+					if onReap != nil {
+						onReap(userID)
+					}
+				}
+			}
+		}
+	}()
+}
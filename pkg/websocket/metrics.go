@@ -0,0 +1,109 @@
+package websocket
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"ride-hail/pkg/logger"
+)
+
+const (
+	// A client is "near capacity" when its send queue is at least this full
+	slowQueueRatio = 0.8
+
+	// How long a client must stay near capacity before it is flagged as slow
+	slowClientAfter = 5 * time.Second
+)
+
+// ClientStats describes one connection's send queue
+type ClientStats struct {
+	UserID        string `json:"user_id"`
+	QueueDepth    int    `json:"queue_depth"`
+	QueueCapacity int    `json:"queue_capacity"`
+	Dropped       uint64 `json:"dropped"`
+	Slow          bool   `json:"slow"`
+}
+
+// Stats is the aggregate send-queue gauge across all connections. It holds
+// counts only, since it is served without authentication; which clients are
+// slow is logged as websocket_slow_client.
+type Stats struct {
+	Connections     int    `json:"connections"`
+	QueuedMessages  int    `json:"queued_messages"`
+	MaxQueueDepth   int    `json:"max_queue_depth"`
+	DroppedMessages uint64 `json:"dropped_messages"`
+	SlowClients     int    `json:"slow_clients"`
+}
+
+// Stats returns a snapshot of the connection's send queue
+func (c *Connection) Stats() ClientStats {
+	c.statsMu.Lock()
+	slow := c.slowFlagged
+	c.statsMu.Unlock()
+
+	return ClientStats{
+		UserID:        c.Claims.UserID,
+		QueueDepth:    len(c.send),
+		QueueCapacity: cap(c.send),
+		Dropped:       c.dropped.Load(),
+		Slow:          slow,
+	}
+}
+
+// observeQueue tracks how long the send queue has been near capacity and
+// logs once when the client crosses the slow threshold
+func (c *Connection) observeQueue() {
+	nearCapacity := float64(len(c.send)) >= float64(cap(c.send))*slowQueueRatio
+
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+
+	if !nearCapacity {
+		c.slowSince = time.Time{}
+		c.slowFlagged = false
+		return
+	}
+	if c.slowSince.IsZero() {
+		c.slowSince = time.Now()
+	}
+	if !c.slowFlagged && time.Since(c.slowSince) >= slowClientAfter {
+		c.slowFlagged = true
+		c.log.WithFields(logger.LogFields{
+			"user_id":     c.Claims.UserID,
+			"queue_depth": len(c.send),
+			"dropped":     c.dropped.Load(),
+		}).Info("websocket_slow_client", "Client send queue near capacity")
+	}
+}
+
+// Stats returns the aggregate send-queue gauge and how many clients are slow
+func (m *Manager) Stats() Stats {
+	m.mu.RLock()
+	connections := make([]*Connection, 0, len(m.connections))
+	for _, conn := range m.connections {
+		connections = append(connections, conn)
+	}
+	m.mu.RUnlock()
+
+	stats := Stats{Connections: len(connections)}
+	for _, conn := range connections {
+		cs := conn.Stats()
+		stats.QueuedMessages += cs.QueueDepth
+		stats.DroppedMessages += cs.Dropped
+		if cs.QueueDepth > stats.MaxQueueDepth {
+			stats.MaxQueueDepth = cs.QueueDepth
+		}
+		if cs.Slow {
+			stats.SlowClients++
+		}
+	}
+	return stats
+}
+
+// MetricsHandler serves the manager's Stats as JSON
+func (m *Manager) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(m.Stats())
+}
@@ -0,0 +1,152 @@
+package websocket
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ride-hail/pkg/auth"
+	"ride-hail/pkg/logger"
+)
+
+// recordingLogger keeps the actions logged at Info
+type recordingLogger struct {
+	nopLogger
+	mu      *sync.Mutex
+	actions *[]string
+}
+
+func newRecordingLogger() recordingLogger {
+	return recordingLogger{mu: &sync.Mutex{}, actions: new([]string)}
+}
+
+func (l recordingLogger) WithFields(logger.LogFields) logger.Logger { return l }
+
+func (l recordingLogger) Info(action, message string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	*l.actions = append(*l.actions, action)
+}
+
+func (l recordingLogger) count(action string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := 0
+	for _, a := range *l.actions {
+		if a == action {
+			n++
+		}
+	}
+	return n
+}
+
+// stalledConnection returns a connection with no write pump, so nothing
+// drains its send queue
+func stalledConnection(log logger.Logger, userID string) *Connection {
+	return newConnection(nil, log, &auth.AppClaims{UserID: userID, Role: auth.RoleDriver})
+}
+
+// fill queues messages until the send buffer is full
+func fill(t *testing.T, c *Connection) {
+	t.Helper()
+	for i := 0; i < cap(c.send); i++ {
+		if err := c.WriteJSON(map[string]int{"seq": i}); err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+	}
+}
+
+func TestFullSendBufferCountsDrops(t *testing.T) {
+	c := stalledConnection(nopLogger{}, "d1")
+	fill(t, c)
+
+	for i := 0; i < 3; i++ {
+		if err := c.WriteJSON("late"); err == nil {
+			t.Fatal("write to a full buffer succeeded")
+		}
+	}
+
+	stats := c.Stats()
+	if stats.Dropped != 3 {
+		t.Errorf("dropped = %d, want 3", stats.Dropped)
+	}
+	if stats.QueueDepth != stats.QueueCapacity {
+		t.Errorf("queue depth = %d, want it at capacity %d", stats.QueueDepth, stats.QueueCapacity)
+	}
+}
+
+func TestClientNearCapacityIsFlaggedSlow(t *testing.T) {
+	log := newRecordingLogger()
+	c := stalledConnection(log, "d1")
+	fill(t, c)
+	if c.Stats().Slow {
+		t.Fatal("client flagged slow as soon as its queue filled")
+	}
+
+	// Pretend the queue has been near capacity for the whole grace period
+	c.statsMu.Lock()
+	c.slowSince = time.Now().Add(-slowClientAfter)
+	c.statsMu.Unlock()
+
+	c.WriteJSON("late")
+	c.WriteJSON("later")
+	if !c.Stats().Slow {
+		t.Error("client not flagged slow after staying near capacity")
+	}
+	if n := log.count("websocket_slow_client"); n != 1 {
+		t.Errorf("slow client logged %d times, want once", n)
+	}
+
+	// Draining the queue clears the flag
+	for len(c.send) > 0 {
+		<-c.send
+	}
+	c.WriteJSON("caught up")
+	if c.Stats().Slow {
+		t.Error("client still flagged slow after its queue drained")
+	}
+}
+
+func TestManagerStatsAggregatesConnections(t *testing.T) {
+	m := NewManager(nopLogger{})
+	slow := stalledConnection(nopLogger{}, "d1")
+	fill(t, slow)
+	slow.WriteJSON("dropped")
+	slow.statsMu.Lock()
+	slow.slowFlagged = true
+	slow.statsMu.Unlock()
+
+	healthy := stalledConnection(nopLogger{}, "d2")
+	healthy.WriteJSON("one")
+	healthy.WriteJSON("two")
+
+	m.mu.Lock()
+	m.connections["d1"] = slow
+	m.connections["d2"] = healthy
+	m.mu.Unlock()
+
+	w := httptest.NewRecorder()
+	m.MetricsHandler(w, httptest.NewRequest(http.MethodGet, "/metrics/websocket", nil))
+	var stats Stats
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("decode metrics: %v", err)
+	}
+
+	capacity := cap(slow.send)
+	if stats.Connections != 2 || stats.QueuedMessages != capacity+2 || stats.MaxQueueDepth != capacity {
+		t.Errorf("stats = %+v, want 2 connections, %d queued, max depth %d", stats, capacity+2, capacity)
+	}
+	if stats.DroppedMessages != 1 {
+		t.Errorf("dropped = %d, want 1", stats.DroppedMessages)
+	}
+	if stats.SlowClients != 1 {
+		t.Errorf("slow clients = %d, want 1", stats.SlowClients)
+	}
+	if strings.Contains(w.Body.String(), "d1") || strings.Contains(w.Body.String(), "d2") {
+		t.Errorf("metrics %s name users, want counts only", w.Body.String())
+	}
+}
@@ -0,0 +1,62 @@
+package websocket
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// originPolicy decides which browser origins may open a WebSocket.
+// Until SetAllowedOrigins is called only same-host origins are accepted.
+var originPolicy = struct {
+	mu       sync.RWMutex
+	allowAll bool
+	allowed  map[string]bool
+}{allowed: map[string]bool{}}
+
+// SetAllowedOrigins configures the origins accepted by every WebSocket endpoint.
+// Entries are full origins such as "https://app.example.com"; "*" allows any origin.
+// allowAll keeps the handshake permissive (development mode) regardless of the list.
+func SetAllowedOrigins(origins []string, allowAll bool) {
+	allowed := make(map[string]bool, len(origins))
+	for _, o := range origins {
+		o = strings.TrimRight(strings.ToLower(strings.TrimSpace(o)), "/")
+		if o == "*" {
+			allowAll = true
+			continue
+		}
+		if o != "" {
+			allowed[o] = true
+		}
+	}
+
+	originPolicy.mu.Lock()
+	defer originPolicy.mu.Unlock()
+	originPolicy.allowAll = allowAll
+	originPolicy.allowed = allowed
+}
+
+// checkOrigin is the upgrader's origin check; a false result makes the
+// upgrader reply 403 Forbidden.
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		// Non-browser clients (mobile apps, services) send no Origin
+		return true
+	}
+
+	originPolicy.mu.RLock()
+	allowAll := originPolicy.allowAll
+	allowed := originPolicy.allowed[strings.TrimRight(strings.ToLower(origin), "/")]
+	originPolicy.mu.RUnlock()
+	if allowAll || allowed {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
@@ -0,0 +1,85 @@
+package websocket
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// allowOrigins sets the origin policy for one test and restores the default after it
+func allowOrigins(t *testing.T, origins []string, allowAll bool) {
+	t.Helper()
+	SetAllowedOrigins(origins, allowAll)
+	t.Cleanup(func() { SetAllowedOrigins(nil, false) })
+}
+
+// handshake opens a socket to url sending origin, if any, and returns the HTTP status
+func handshake(t *testing.T, url, origin string) int {
+	t.Helper()
+	header := http.Header{}
+	if origin != "" {
+		header.Set("Origin", origin)
+	}
+	conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+	if err == nil {
+		conn.Close()
+	} else if resp == nil {
+		t.Fatalf("dial: %v", err)
+	}
+	return resp.StatusCode
+}
+
+func TestOriginAllowlist(t *testing.T) {
+	url := newTestServer(t, NewManager(nopLogger{}))
+	sameHost := "http://" + strings.TrimPrefix(url, "ws://")
+	allowOrigins(t, []string{" https://App.Example.com/ ", "https://admin.example.com"}, false)
+
+	tests := []struct {
+		name, origin string
+		want         int
+	}{
+		{"allowlisted", "https://app.example.com", http.StatusSwitchingProtocols},
+		{"allowlisted, different case", "https://ADMIN.example.com", http.StatusSwitchingProtocols},
+		{"not allowlisted", "https://evil.example.com", http.StatusForbidden},
+		{"allowlisted host on another scheme", "http://app.example.com", http.StatusForbidden},
+		{"same host", sameHost, http.StatusSwitchingProtocols},
+		{"no origin from a non-browser client", "", http.StatusSwitchingProtocols},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := handshake(t, url, tt.origin); got != tt.want {
+				t.Errorf("handshake from %q: status = %d, want %d", tt.origin, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOriginDefaultIsSameHostOnly(t *testing.T) {
+	url := newTestServer(t, NewManager(nopLogger{}))
+
+	if got := handshake(t, url, "https://app.example.com"); got != http.StatusForbidden {
+		t.Errorf("cross-origin handshake: status = %d, want 403", got)
+	}
+}
+
+func TestOriginPermissiveInDevelopment(t *testing.T) {
+	url := newTestServer(t, NewManager(nopLogger{}))
+
+	for _, policy := range []struct {
+		name     string
+		origins  []string
+		allowAll bool
+	}{
+		{"development mode", nil, true},
+		{"wildcard entry", []string{"*"}, false},
+	} {
+		t.Run(policy.name, func(t *testing.T) {
+			allowOrigins(t, policy.origins, policy.allowAll)
+			if got := handshake(t, url, "http://localhost:5173"); got != http.StatusSwitchingProtocols {
+				t.Errorf("handshake: status = %d, want 101", got)
+			}
+		})
+	}
+}
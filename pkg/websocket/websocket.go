@@ -6,12 +6,14 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 
 	"ride-hail/pkg/auth"
 	"ride-hail/pkg/logger"
+	"ride-hail/pkg/wsmsg"
 )
 
 const (
@@ -29,25 +31,40 @@ const (
 
 	// Time allowed to send auth message
 	authTime = 5 * time.Second
+
+	// Time without a pong or message after which the idle sweeper closes a connection
+	idleTimeout = pongWait * 3
 )
 
-type wsErrorResponse struct {
-	Type    string `json:"type"`
-	Message string `json:"message"`
-}
+// Close codes sent in the close frame so clients can tell a kick from a network drop.
+// 4000-4999 is the range reserved for application use.
+const (
+	CloseReplaced     = 4000
+	CloseSessionEnded = 4001
+	CloseBanned       = 4002
+	CloseAuthFailed   = 4003
+	CloseIdle         = 4004
+)
+
+// Close reasons accompanying the close codes
+const (
+	ReasonReplaced      = "replaced by new connection"
+	ReasonSessionEnded  = "session ended"
+	ReasonBanned        = "banned"
+	ReasonForcedOffline = "forced offline by admin"
+	ReasonIdle          = "idle timeout"
+)
 
 // AuthRequest is the expected first message from the client.
 type authRequest struct {
-	Type  string `json:"type"`
-	Token string `json:"message"`
+	Type  wsmsg.Type `json:"type"`
+	Token string     `json:"message"`
 }
 
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+	CheckOrigin:     checkOrigin,
 }
 
 // Connection is a wrapper around the websocket.Conn
@@ -58,10 +75,19 @@ type Connection struct {
 	done       chan []byte
 	writeMutex sync.Mutex
 	Claims     *auth.AppClaims
+
+	// Send queue metrics
+	dropped     atomic.Uint64
+	statsMu     sync.Mutex
+	slowSince   time.Time
+	slowFlagged bool
+
+	// Unix nanoseconds of the last pong or message from the peer
+	lastSeen atomic.Int64
 }
 
 func newConnection(conn *websocket.Conn, log logger.Logger, claims *auth.AppClaims) *Connection {
-	return &Connection{
+	c := &Connection{
 		conn:       conn,
 		log:        log,
 		send:       make(chan []byte, 256),
@@ -69,6 +95,18 @@ func newConnection(conn *websocket.Conn, log logger.Logger, claims *auth.AppClai
 		writeMutex: sync.Mutex{},
 		Claims:     claims,
 	}
+	c.touch()
+	return c
+}
+
+// touch records activity from the peer
+func (c *Connection) touch() {
+	c.lastSeen.Store(time.Now().UnixNano())
+}
+
+// idleFor returns how long the peer has been silent as of now
+func (c *Connection) idleFor(now time.Time) time.Duration {
+	return now.Sub(time.Unix(0, c.lastSeen.Load()))
 }
 
 // writePump pumps messages from the send channel to the websocket connection.
@@ -106,6 +144,8 @@ func (c *Connection) write(mt int, payload []byte) error {
 	c.writeMutex.Lock()
 	defer c.writeMutex.Unlock()
 	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	// Only takes effect when permessage-deflate was negotiated
+	c.conn.EnableWriteCompression(len(payload) >= compressionThreshold)
 	return c.conn.WriteMessage(mt, payload)
 }
 
@@ -118,10 +158,13 @@ func (c *Connection) WriteJSON(v interface{}) error {
 
 	select {
 	case c.send <- data:
+		c.observeQueue()
 		return nil
 	case <-c.done:
 		return errors.New("connection closed")
 	default:
+		c.dropped.Add(1)
+		c.observeQueue()
 		c.log.WithFields(logger.LogFields{"user_id": c.Claims.UserID}).Error("websocket_send_buffer_full", errors.New("dropping message"))
 		return errors.New("send buffer full")
 	}
@@ -139,6 +182,7 @@ func (c *Connection) ReadPump(onMessage func(msgType int, p []byte), onDisconnec
 	c.conn.SetReadLimit(maxMessageSize)
 	c.conn.SetReadDeadline(time.Now().Add(pongWait))
 	c.conn.SetPongHandler(func(string) error {
+		c.touch()
 		c.conn.SetReadDeadline(time.Now().Add(pongWait))
 		return nil
 	})
@@ -155,12 +199,18 @@ func (c *Connection) ReadPump(onMessage func(msgType int, p []byte), onDisconnec
 
 		}
 
+		c.touch()
 		onMessage(msgType, msg)
 	}
 }
 
-// Close gracefully closes the connection.
+// Close gracefully closes the connection with a normal close frame.
 func (c *Connection) Close() {
+	c.CloseWithReason(websocket.CloseNormalClosure, ReasonSessionEnded)
+}
+
+// CloseWithReason sends a close frame carrying code and reason, then closes the connection.
+func (c *Connection) CloseWithReason(code int, reason string) {
 	c.writeMutex.Lock()
 	defer c.writeMutex.Unlock()
 
@@ -168,6 +218,8 @@ func (c *Connection) Close() {
 	case <-c.done:
 		return
 	default:
+		// Best effort: the peer may already be gone
+		c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(writeWait))
 		close(c.done)
 		close(c.send)
 		c.conn.Close()
@@ -193,11 +245,12 @@ func NewHandler(log logger.Logger, jwtManager *auth.JWTManager, onConnect func(c
 
 // ServeHTTP handles the HTTP request to upgrade it to a WebSocket.
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, err := currentUpgrader().Upgrade(w, r, nil)
 	if err != nil {
 		h.log.Error("websocket_upgrade_failed", err)
 		return
 	}
+	applyCompressionLevel(conn)
 
 	conn.SetReadDeadline(time.Now().Add(authTime))
 	_, msg, err := conn.ReadMessage()
@@ -213,7 +266,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		sendErrorAndClose(conn, "Invalid authentication request format")
 		return
 	}
-	if req.Type != "auth" || req.Token == "" {
+	if req.Type != wsmsg.TypeAuth || req.Token == "" {
 		h.log.Error("websocket_auth_format_error", errors.New("invalid auth message format"))
 		sendErrorAndClose(conn, "Invalid authentication request format")
 		return
@@ -245,9 +298,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 func sendErrorAndClose(conn *websocket.Conn, msg string) {
 	conn.SetWriteDeadline(time.Now().Add(writeWait))
-	conn.WriteJSON(wsErrorResponse{
-		Type:    "error",
-		Message: msg,
-	})
+	conn.WriteJSON(wsmsg.NewError(msg))
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(CloseAuthFailed, msg), time.Now().Add(writeWait))
 	conn.Close()
 }
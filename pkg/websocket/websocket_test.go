@@ -0,0 +1,185 @@
+package websocket
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"ride-hail/pkg/auth"
+	"ride-hail/pkg/logger"
+	"ride-hail/pkg/wsmsg"
+)
+
+const testSecret = "test-secret"
+
+type nopLogger struct{}
+
+func (l nopLogger) WithFields(logger.LogFields) logger.Logger { return l }
+func (nopLogger) Info(action, message string)                 {}
+func (nopLogger) Debug(action, message string)                {}
+func (nopLogger) Warn(action, message string)                 {}
+func (nopLogger) Error(action string, err error)              {}
+
+// newTestServer serves driver WebSockets that register with m, as the services do
+func newTestServer(t *testing.T, m *Manager) string {
+	t.Helper()
+	h := NewHandler(nopLogger{}, auth.NewJWTManager(testSecret, time.Hour), func(conn *Connection) {
+		userID := conn.Claims.UserID
+		m.AddConnection(userID, conn)
+		conn.ReadPump(func(int, []byte) {}, func() { m.ReleaseConnection(userID, conn) })
+	}, auth.RoleDriver)
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+// dial connects and authenticates as userID with role
+func dial(t *testing.T, url, userID string, role auth.Role) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	tok, err := auth.NewJWTManager(testSecret, time.Hour).GenerateToken(userID, role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.WriteJSON(map[string]interface{}{"type": wsmsg.TypeAuth, "message": "Bearer " + tok}); err != nil {
+		t.Fatalf("send auth: %v", err)
+	}
+	return conn
+}
+
+// closeFrame reads until the connection closes and returns the close frame received
+func closeFrame(t *testing.T, conn *websocket.Conn) *websocket.CloseError {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			var ce *websocket.CloseError
+			if !errors.As(err, &ce) {
+				t.Fatalf("connection ended with %v, want a close frame", err)
+			}
+			return ce
+		}
+	}
+}
+
+// waitConnected waits until m holds n connections
+func waitConnected(t *testing.T, m *Manager, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for m.GetConnectionCount() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d connections registered, want %d", m.GetConnectionCount(), n)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+}
+
+func TestReplacedConnectionGetsReplacedCloseFrame(t *testing.T) {
+	m := NewManager(nopLogger{})
+	url := newTestServer(t, m)
+
+	first := dial(t, url, "d1", auth.RoleDriver)
+	waitConnected(t, m, 1)
+	dial(t, url, "d1", auth.RoleDriver)
+
+	ce := closeFrame(t, first)
+	if ce.Code != CloseReplaced || ce.Text != ReasonReplaced {
+		t.Errorf("close frame = %d %q, want %d %q", ce.Code, ce.Text, CloseReplaced, ReasonReplaced)
+	}
+	if !m.IsUserConnected("d1") {
+		t.Error("the replacement connection was removed along with the old one")
+	}
+}
+
+func TestDisconnectSendsGivenCloseFrame(t *testing.T) {
+	tests := []struct {
+		name       string
+		disconnect func(m *Manager)
+		code       int
+		reason     string
+	}{
+		{"session ended", func(m *Manager) { m.RemoveConnection("d1") }, CloseSessionEnded, ReasonSessionEnded},
+		{"banned", func(m *Manager) { m.Disconnect("d1", CloseBanned, ReasonBanned) }, CloseBanned, ReasonBanned},
+		{"forced offline", func(m *Manager) { m.Disconnect("d1", CloseSessionEnded, ReasonForcedOffline) }, CloseSessionEnded, ReasonForcedOffline},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManager(nopLogger{})
+			url := newTestServer(t, m)
+			conn := dial(t, url, "d1", auth.RoleDriver)
+			waitConnected(t, m, 1)
+
+			tt.disconnect(m)
+
+			ce := closeFrame(t, conn)
+			if ce.Code != tt.code || ce.Text != tt.reason {
+				t.Errorf("close frame = %d %q, want %d %q", ce.Code, ce.Text, tt.code, tt.reason)
+			}
+			if m.IsUserConnected("d1") {
+				t.Error("connection still registered")
+			}
+		})
+	}
+}
+
+func TestIdleConnectionGetsIdleCloseFrame(t *testing.T) {
+	m := NewManager(nopLogger{})
+	url := newTestServer(t, m)
+	conn := dial(t, url, "d1", auth.RoleDriver)
+	waitConnected(t, m, 1)
+
+	reaped := m.ReapIdle(time.Now().Add(time.Hour), idleTimeout)
+	if len(reaped) != 1 || reaped[0] != "d1" {
+		t.Fatalf("reaped %v, want [d1]", reaped)
+	}
+	ce := closeFrame(t, conn)
+	if ce.Code != CloseIdle || ce.Text != ReasonIdle {
+		t.Errorf("close frame = %d %q, want %d %q", ce.Code, ce.Text, CloseIdle, ReasonIdle)
+	}
+}
+
+func TestReapIdleSparesActiveConnections(t *testing.T) {
+	m := NewManager(nopLogger{})
+	url := newTestServer(t, m)
+	dial(t, url, "d1", auth.RoleDriver)
+	dial(t, url, "d2", auth.RoleDriver)
+	waitConnected(t, m, 2)
+
+	if reaped := m.ReapIdle(time.Now().Add(idleTimeout/2), idleTimeout); len(reaped) != 0 {
+		t.Fatalf("reaped %v within the idle timeout, want none", reaped)
+	}
+
+	reaped := m.ReapIdle(time.Now().Add(idleTimeout+time.Second), idleTimeout)
+	if len(reaped) != 2 {
+		t.Fatalf("reaped %v past the idle timeout, want both", reaped)
+	}
+	for _, id := range []string{"d1", "d2"} {
+		if m.IsUserConnected(id) {
+			t.Errorf("%s still registered after being reaped", id)
+		}
+	}
+}
+
+func TestWrongRoleGetsAuthFailedCloseFrame(t *testing.T) {
+	m := NewManager(nopLogger{})
+	url := newTestServer(t, m)
+
+	conn := dial(t, url, "p1", auth.RolePassenger)
+
+	ce := closeFrame(t, conn)
+	if ce.Code != CloseAuthFailed {
+		t.Errorf("close code = %d, want %d", ce.Code, CloseAuthFailed)
+	}
+	if m.GetConnectionCount() != 0 {
+		t.Error("connection registered despite failing auth")
+	}
+}
@@ -0,0 +1,112 @@
+// Package wsmsg defines the WebSocket message kinds and envelopes shared by
+// the ride and driver services, so both sides agree on the wire format.
+package wsmsg
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Type identifies a WebSocket message kind.
+type Type string
+
+// Message kinds sent by clients.
+const (
+	TypeAuth           Type = "auth"
+	TypeRideResponse   Type = "ride_response"
+	TypeLocationUpdate Type = "location_update"
+	TypeOfferAck       Type = "offer_ack"
+)
+
+// Message kinds sent to drivers.
+const (
+	TypeRideOffer      Type = "ride_offer"
+	TypeRideDetails    Type = "ride_details"
+	TypeRideCancelled  Type = "ride_cancelled"
+	TypeOfferCancelled Type = "offer_cancelled"
+
+	TypeRideDestinationChanged Type = "ride_destination_changed"
+)
+
+// Message kinds sent to passengers (WebSocket and SSE).
+const (
+	TypeRideMatched          Type = "ride_matched"
+	TypeRideStatusUpdate     Type = "ride_status_update"
+	TypeDriverLocationUpdate Type = "driver_location_update"
+	TypeDriverArriving       Type = "driver_arriving"
+)
+
+// TypeRideMessage relays a chat message between a ride's passenger and driver.
+const TypeRideMessage Type = "ride_message"
+
+// TypeError reports a failed request to either side.
+const TypeError Type = "error"
+
+// Message is an outgoing {"type", "data"} envelope.
+type Message struct {
+	Type Type        `json:"type"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// New builds an outgoing envelope.
+func New(t Type, data interface{}) Message {
+	return Message{Type: t, Data: data}
+}
+
+// Envelope is an incoming {"type", "data"} message whose data is decoded lazily.
+type Envelope struct {
+	Type Type            `json:"type"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// Decode parses a raw WebSocket payload into an Envelope.
+func Decode(payload []byte) (Envelope, error) {
+	var env Envelope
+	if err := json.Unmarshal(payload, &env); err != nil {
+		return Envelope{}, fmt.Errorf("decode ws message: %w", err)
+	}
+	if env.Type == "" {
+		return Envelope{}, fmt.Errorf("decode ws message: missing type")
+	}
+	return env, nil
+}
+
+// Into decodes the envelope's data into v.
+func (e Envelope) Into(v interface{}) error {
+	if err := json.Unmarshal(e.Data, v); err != nil {
+		return fmt.Errorf("invalid %s format: %w", e.Type, err)
+	}
+	return nil
+}
+
+// ErrorMessage is the flat {"type":"error","message"} payload. Validation
+// failures also name the rejected message type and field.
+type ErrorMessage struct {
+	Type        Type   `json:"type"`
+	Message     string `json:"message"`
+	RequestType Type   `json:"request_type,omitempty"`
+	Field       string `json:"field,omitempty"`
+}
+
+// NewError builds an error message.
+func NewError(message string) ErrorMessage {
+	return ErrorMessage{Type: TypeError, Message: message}
+}
+
+// NewValidationError builds an error message for an invalid field of a request.
+func NewValidationError(requestType Type, field, message string) ErrorMessage {
+	return ErrorMessage{Type: TypeError, Message: message, RequestType: requestType, Field: field}
+}
+
+// Notification is a flat passenger notification: a "type" key alongside its fields.
+type Notification map[string]interface{}
+
+// NewNotification builds a passenger notification of the given type.
+func NewNotification(t Type, fields map[string]interface{}) Notification {
+	n := make(Notification, len(fields)+1)
+	for k, v := range fields {
+		n[k] = v
+	}
+	n["type"] = t
+	return n
+}
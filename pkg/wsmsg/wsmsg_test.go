@@ -0,0 +1,124 @@
+package wsmsg
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// allTypes is every message kind; a kind added to the package belongs here too
+var allTypes = []Type{
+	TypeAuth, TypeRideResponse, TypeLocationUpdate, TypeOfferAck,
+	TypeRideOffer, TypeRideDetails, TypeRideCancelled, TypeOfferCancelled, TypeRideDestinationChanged,
+	TypeRideMatched, TypeRideStatusUpdate, TypeDriverLocationUpdate, TypeDriverArriving,
+	TypeRideMessage, TypeError,
+}
+
+func TestTypesAreDistinct(t *testing.T) {
+	seen := make(map[Type]bool)
+	for _, typ := range allTypes {
+		if typ == "" || strings.ToLower(string(typ)) != string(typ) {
+			t.Errorf("type %q is not a lower-case wire name", typ)
+		}
+		if seen[typ] {
+			t.Errorf("type %q defined twice", typ)
+		}
+		seen[typ] = true
+	}
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	type payload struct {
+		RideID string  `json:"ride_id"`
+		Fare   float64 `json:"fare"`
+	}
+	want := payload{RideID: "ride-1", Fare: 1450.5}
+
+	for _, typ := range allTypes {
+		t.Run(string(typ), func(t *testing.T) {
+			raw, err := json.Marshal(New(typ, want))
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+
+			env, err := Decode(raw)
+			if err != nil {
+				t.Fatalf("Decode(%s): %v", raw, err)
+			}
+			if env.Type != typ {
+				t.Errorf("type = %q, want %q", env.Type, typ)
+			}
+			var got payload
+			if err := env.Into(&got); err != nil {
+				t.Fatalf("Into: %v", err)
+			}
+			if got != want {
+				t.Errorf("data = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestMessageWithoutDataOmitsIt(t *testing.T) {
+	raw, _ := json.Marshal(New(TypeOfferAck, nil))
+	if string(raw) != `{"type":"offer_ack"}` {
+		t.Errorf("marshalled %s, want only the type", raw)
+	}
+}
+
+func TestDecodeRejectsBadPayloads(t *testing.T) {
+	for _, payload := range []string{`not json`, `{}`, `{"data":{"ride_id":"r"}}`, `{"type":""}`} {
+		if _, err := Decode([]byte(payload)); err == nil {
+			t.Errorf("Decode(%s) succeeded", payload)
+		}
+	}
+}
+
+func TestIntoNamesTheMessageType(t *testing.T) {
+	env, err := Decode([]byte(`{"type":"ride_response","data":{"accepted":"yes"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v struct {
+		Accepted bool `json:"accepted"`
+	}
+	err = env.Into(&v)
+	if err == nil || !strings.HasPrefix(err.Error(), "invalid ride_response format") {
+		t.Errorf("err = %v, want it to name ride_response", err)
+	}
+}
+
+func TestErrorMessages(t *testing.T) {
+	raw, _ := json.Marshal(NewError("Not authenticated"))
+	if string(raw) != `{"type":"error","message":"Not authenticated"}` {
+		t.Errorf("NewError marshalled %s", raw)
+	}
+
+	raw, _ = json.Marshal(NewValidationError(TypeLocationUpdate, "latitude", "must be between -90 and 90"))
+	want := `{"type":"error","message":"must be between -90 and 90","request_type":"location_update","field":"latitude"}`
+	if string(raw) != want {
+		t.Errorf("NewValidationError marshalled %s, want %s", raw, want)
+	}
+}
+
+func TestNotificationRoundTrip(t *testing.T) {
+	fields := map[string]interface{}{"ride_id": "ride-1", "type": "overridden"}
+	n := NewNotification(TypeRideStatusUpdate, fields)
+	if fields["type"] != "overridden" {
+		t.Error("NewNotification modified the caller's fields")
+	}
+
+	raw, err := json.Marshal(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"type": "ride_status_update", "ride_id": "ride-1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("notification = %v, want %v", got, want)
+	}
+}